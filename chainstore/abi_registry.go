@@ -0,0 +1,518 @@
+package chainstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/0xPolygon/polygon-cli/bindings/funder"
+	"github.com/0xPolygon/polygon-cli/bindings/multicall3"
+	"github.com/0xPolygon/polygon-cli/bindings/tester"
+	"github.com/0xPolygon/polygon-cli/bindings/tokens"
+	"github.com/0xPolygon/polygon-cli/bindings/ulxly"
+	"github.com/0xPolygon/polygon-cli/bindings/ulxly/polygonrollupmanager"
+	"github.com/0xPolygon/polygon-cli/bindings/ulxly/polygonzkevmglobalexitrootl2"
+	"github.com/0xPolygon/polygon-cli/bindings/uniswapv3"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrSignatureNotFound is returned when a selector or topic is not in the registry
+var ErrSignatureNotFound = errors.New("signature not found in local ABI registry")
+
+// bindingABIs are the ABIs of the contract bindings shipped with polycli
+var bindingABIs = map[string]string{
+	"Funder":                             funder.FunderMetaData.ABI,
+	"Multicall3":                         multicall3.Multicall3MetaData.ABI,
+	"ConformanceTester":                  tester.ConformanceTesterMetaData.ABI,
+	"LoadTester":                         tester.LoadTesterMetaData.ABI,
+	"ERC20":                              tokens.ERC20MetaData.ABI,
+	"ERC721":                             tokens.ERC721MetaData.ABI,
+	"PolygonZkEVMBridge":                 ulxly.LegacyMetaData.ABI,
+	"PolygonZkEVMBridgeV2":               ulxly.UlxlyMetaData.ABI,
+	"PolygonRollupManager":               polygonrollupmanager.PolygonrollupmanagerMetaData.ABI,
+	"PolygonZkEVMGlobalExitRootL2":       polygonzkevmglobalexitrootl2.Polygonzkevmglobalexitrootl2MetaData.ABI,
+	"IUniswapV3Pool":                     uniswapv3.IUniswapV3PoolMetaData.ABI,
+	"NFTDescriptor":                      uniswapv3.NFTDescriptorMetaData.ABI,
+	"NonfungiblePositionManager":         uniswapv3.NonfungiblePositionManagerMetaData.ABI,
+	"NonfungibleTokenPositionDescriptor": uniswapv3.NonfungibleTokenPositionDescriptorMetaData.ABI,
+	"ProxyAdmin":                         uniswapv3.ProxyAdminMetaData.ABI,
+	"QuoterV2":                           uniswapv3.QuoterV2MetaData.ABI,
+	"SwapRouter02":                       uniswapv3.SwapRouter02MetaData.ABI,
+	"TickLens":                           uniswapv3.TickLensMetaData.ABI,
+	"TransparentUpgradeableProxy":        uniswapv3.TransparentUpgradeableProxyMetaData.ABI,
+	"UniswapInterfaceMulticall":          uniswapv3.UniswapInterfaceMulticallMetaData.ABI,
+	"UniswapV3Factory":                   uniswapv3.UniswapV3FactoryMetaData.ABI,
+	"UniswapV3Staker":                    uniswapv3.UniswapV3StakerMetaData.ABI,
+	"V3Migrator":                         uniswapv3.V3MigratorMetaData.ABI,
+	"WETH9":                              uniswapv3.WETH9MetaData.ABI,
+}
+
+// DecodedArg is a single decoded ABI argument
+type DecodedArg struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Value   any    `json:"value"`
+	Indexed bool   `json:"indexed,omitempty"`
+}
+
+// DecodedCall is calldata, an event log or revert data decoded against a known ABI
+type DecodedCall struct {
+	Contract  string       `json:"contract"`  // Name of the contract the ABI came from
+	Name      string       `json:"name"`      // Method, event or error name
+	Signature string       `json:"signature"` // Canonical signature, e.g. transfer(address,uint256)
+	Args      []DecodedArg `json:"args"`
+}
+
+// registryEntry ties an ABI element to the contract it was loaded from
+type registryEntry[T any] struct {
+	contract string
+	item     T
+}
+
+// ABIRegistry is a local registry of method, event and error definitions
+// loaded from ABI files, build artifacts and the bundled contract bindings.
+// It's consulted before 4byte.directory so decoding works offline and for
+// contracts 4byte doesn't know about.
+type ABIRegistry struct {
+	mu      sync.RWMutex
+	methods map[[4]byte][]registryEntry[abi.Method]
+	errors  map[[4]byte][]registryEntry[abi.Error]
+	events  map[common.Hash][]registryEntry[abi.Event]
+	sources int
+}
+
+// NewABIRegistry creates an empty ABI registry
+func NewABIRegistry() *ABIRegistry {
+	return &ABIRegistry{
+		methods: make(map[[4]byte][]registryEntry[abi.Method]),
+		errors:  make(map[[4]byte][]registryEntry[abi.Error]),
+		events:  make(map[common.Hash][]registryEntry[abi.Event]),
+	}
+}
+
+// LoadBindings adds the ABIs of the contract bindings bundled with polycli
+func (r *ABIRegistry) LoadBindings() error {
+	names := make([]string, 0, len(bindingABIs))
+	for name := range bindingABIs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		parsed, err := abi.JSON(strings.NewReader(bindingABIs[name]))
+		if err != nil {
+			return fmt.Errorf("failed to parse %s binding ABI: %w", name, err)
+		}
+		r.Add(name, &parsed)
+	}
+	return nil
+}
+
+// LoadPath adds every ABI found at path. Path may be a single file or a
+// directory which is walked recursively, so a Foundry out/ or Hardhat
+// artifacts/ directory can be passed directly. Files that don't contain an
+// ABI are skipped.
+func (r *ABIRegistry) LoadPath(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return r.LoadFile(path)
+	}
+
+	return filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// Skip Foundry build metadata which duplicates the artifacts
+			if d.Name() == "build-info" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(p))
+		if ext != ".json" && ext != ".abi" {
+			return nil
+		}
+		if err := r.LoadFile(p); err != nil {
+			log.Debug().Err(err).Str("file", p).Msg("Skipping file without a usable ABI")
+		}
+		return nil
+	})
+}
+
+// LoadFile adds the ABI in a file. Both plain ABI arrays and artifacts with
+// an "abi" field (Foundry, Hardhat, Truffle) are supported.
+func (r *ABIRegistry) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	rawABI, err := extractABI(data)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := abi.JSON(bytes.NewReader(rawABI))
+	if err != nil {
+		return fmt.Errorf("failed to parse ABI: %w", err)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	r.Add(name, &parsed)
+	return nil
+}
+
+// extractABI returns the raw ABI array from either a plain ABI file or a
+// build artifact
+func extractABI(data []byte) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, errors.New("empty file")
+	}
+
+	switch data[0] {
+	case '[':
+		return data, nil
+	case '{':
+		var artifact struct {
+			ABI json.RawMessage `json:"abi"`
+		}
+		if err := json.Unmarshal(data, &artifact); err != nil {
+			return nil, err
+		}
+		if len(artifact.ABI) == 0 || artifact.ABI[0] != '[' {
+			return nil, errors.New("artifact has no abi field")
+		}
+		return artifact.ABI, nil
+	default:
+		return nil, errors.New("not a JSON ABI")
+	}
+}
+
+// Add registers every method, event and error of a parsed ABI under the
+// given contract name. Duplicate definitions are kept once.
+func (r *ABIRegistry) Add(contract string, parsed *abi.ABI) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, method := range parsed.Methods {
+		var selector [4]byte
+		copy(selector[:], method.ID)
+		if !containsEntry(r.methods[selector], method.Sig, func(m abi.Method) string { return m.Sig }) {
+			r.methods[selector] = append(r.methods[selector], registryEntry[abi.Method]{contract, method})
+		}
+	}
+	for _, event := range parsed.Events {
+		if event.Anonymous {
+			continue
+		}
+		key := eventLayout(event)
+		if !containsEntry(r.events[event.ID], key, eventLayout) {
+			r.events[event.ID] = append(r.events[event.ID], registryEntry[abi.Event]{contract, event})
+		}
+	}
+	for _, abiErr := range parsed.Errors {
+		var selector [4]byte
+		copy(selector[:], abiErr.ID[:4])
+		if !containsEntry(r.errors[selector], abiErr.Sig, func(e abi.Error) string { return e.Sig }) {
+			r.errors[selector] = append(r.errors[selector], registryEntry[abi.Error]{contract, abiErr})
+		}
+	}
+	r.sources++
+}
+
+// containsEntry reports whether entries already hold an item with the given key
+func containsEntry[T any](entries []registryEntry[T], key string, keyFunc func(T) string) bool {
+	for _, entry := range entries {
+		if keyFunc(entry.item) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// eventLayout identifies an event by signature and which inputs are indexed,
+// since e.g. ERC20 and ERC721 Transfer share a topic but differ in layout
+func eventLayout(event abi.Event) string {
+	var sb strings.Builder
+	sb.WriteString(event.Sig)
+	for _, input := range event.Inputs {
+		if input.Indexed {
+			sb.WriteByte('i')
+		} else {
+			sb.WriteByte('d')
+		}
+	}
+	return sb.String()
+}
+
+// Stats returns the number of loaded sources, methods, events and errors
+func (r *ABIRegistry) Stats() (sources, methods, events, errs int) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sources, len(r.methods), len(r.events), len(r.errors)
+}
+
+// LookupSignatures returns the text signatures registered for a 4 byte
+// selector or a 32 byte event topic, in the same form as 4byte.directory
+func (r *ABIRegistry) LookupSignatures(hexSignature string) []Signature {
+	raw := common.FromHex(hexSignature)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var sigs []string
+	switch len(raw) {
+	case 4:
+		var selector [4]byte
+		copy(selector[:], raw)
+		for _, entry := range r.methods[selector] {
+			sigs = append(sigs, entry.item.Sig)
+		}
+		for _, entry := range r.errors[selector] {
+			sigs = append(sigs, entry.item.Sig)
+		}
+	case 32:
+		for _, entry := range r.events[common.BytesToHash(raw)] {
+			if !containsString(sigs, entry.item.Sig) {
+				sigs = append(sigs, entry.item.Sig)
+			}
+		}
+	}
+
+	result := make([]Signature, 0, len(sigs))
+	for _, sig := range sigs {
+		result = append(result, Signature{
+			TextSignature: sig,
+			HexSignature:  hexSignature,
+		})
+	}
+	return result
+}
+
+// containsString reports whether s is in list
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// DecodeCalldata decodes transaction input against the registered methods.
+// The first definition whose arguments unpack cleanly wins.
+func (r *ABIRegistry) DecodeCalldata(data []byte) (*DecodedCall, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("calldata too short: %d bytes", len(data))
+	}
+
+	var selector [4]byte
+	copy(selector[:], data[:4])
+
+	r.mu.RLock()
+	entries := r.methods[selector]
+	r.mu.RUnlock()
+
+	if len(entries) == 0 {
+		return nil, ErrSignatureNotFound
+	}
+
+	var lastErr error
+	for _, entry := range entries {
+		values, err := entry.item.Inputs.Unpack(data[4:])
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return &DecodedCall{
+			Contract:  entry.contract,
+			Name:      entry.item.RawName,
+			Signature: entry.item.Sig,
+			Args:      decodedArgs(entry.item.Inputs, values),
+		}, nil
+	}
+	return nil, fmt.Errorf("failed to unpack calldata: %w", lastErr)
+}
+
+// DecodeLog decodes an event log against the registered events, trying every
+// definition for the topic until one matches the indexed layout
+func (r *ABIRegistry) DecodeLog(topics []common.Hash, data []byte) (*DecodedCall, error) {
+	if len(topics) == 0 {
+		return nil, errors.New("anonymous events can't be decoded")
+	}
+
+	r.mu.RLock()
+	entries := r.events[topics[0]]
+	r.mu.RUnlock()
+
+	if len(entries) == 0 {
+		return nil, ErrSignatureNotFound
+	}
+
+	var lastErr error
+	for _, entry := range entries {
+		decoded, err := decodeEvent(entry.item, topics, data)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		decoded.Contract = entry.contract
+		return decoded, nil
+	}
+	return nil, fmt.Errorf("failed to unpack log: %w", lastErr)
+}
+
+// decodeEvent unpacks the indexed topics and data of a single event definition
+func decodeEvent(event abi.Event, topics []common.Hash, data []byte) (*DecodedCall, error) {
+	var indexed abi.Arguments
+	for _, input := range event.Inputs {
+		if input.Indexed {
+			indexed = append(indexed, input)
+		}
+	}
+	if len(indexed) != len(topics)-1 {
+		return nil, fmt.Errorf("expected %d indexed topics, got %d", len(indexed), len(topics)-1)
+	}
+
+	nonIndexed := event.Inputs.NonIndexed()
+	values, err := nonIndexed.Unpack(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Topics are parsed one at a time and kept by position, since unnamed
+	// indexed params would share a key in a single map
+	indexedValues := make([]any, len(indexed))
+	for idx, input := range indexed {
+		input.Name = "value"
+		parsed := make(map[string]any, 1)
+		if err := abi.ParseTopicsIntoMap(parsed, abi.Arguments{input}, topics[idx+1:idx+2]); err != nil {
+			return nil, err
+		}
+		indexedValues[idx] = parsed[input.Name]
+	}
+
+	decoded := &DecodedCall{
+		Name:      event.RawName,
+		Signature: event.Sig,
+	}
+
+	dataIdx, topicIdx := 0, 0
+	for _, input := range event.Inputs {
+		arg := DecodedArg{Name: input.Name, Type: input.Type.String(), Indexed: input.Indexed}
+		if input.Indexed {
+			arg.Value = indexedValues[topicIdx]
+			topicIdx++
+		} else {
+			arg.Value = values[dataIdx]
+			dataIdx++
+		}
+		decoded.Args = append(decoded.Args, arg)
+	}
+	return decoded, nil
+}
+
+// DecodeRevert decodes revert data. Solidity's Error(string) and
+// Panic(uint256) are always understood, custom errors need a registered ABI.
+func (r *ABIRegistry) DecodeRevert(data []byte) (*DecodedCall, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("revert data too short: %d bytes", len(data))
+	}
+
+	if reason, err := abi.UnpackRevert(data); err == nil {
+		name := "Error"
+		typ := "string"
+		if bytes.Equal(data[:4], panicSelector) {
+			name = "Panic"
+			typ = "uint256"
+		}
+		return &DecodedCall{
+			Contract:  "solidity",
+			Name:      name,
+			Signature: fmt.Sprintf("%s(%s)", name, typ),
+			Args:      []DecodedArg{{Name: "reason", Type: typ, Value: reason}},
+		}, nil
+	}
+
+	var selector [4]byte
+	copy(selector[:], data[:4])
+
+	r.mu.RLock()
+	entries := r.errors[selector]
+	r.mu.RUnlock()
+
+	if len(entries) == 0 {
+		return nil, ErrSignatureNotFound
+	}
+
+	var lastErr error
+	for _, entry := range entries {
+		values, err := entry.item.Inputs.Unpack(data[4:])
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return &DecodedCall{
+			Contract:  entry.contract,
+			Name:      entry.item.Name,
+			Signature: entry.item.Sig,
+			Args:      decodedArgs(entry.item.Inputs, values),
+		}, nil
+	}
+	return nil, fmt.Errorf("failed to unpack revert data: %w", lastErr)
+}
+
+// panicSelector is the selector of Solidity's Panic(uint256)
+var panicSelector = []byte{0x4e, 0x48, 0x7b, 0x71}
+
+// decodedArgs pairs ABI arguments with their unpacked values
+func decodedArgs(args abi.Arguments, values []any) []DecodedArg {
+	result := make([]DecodedArg, 0, len(args))
+	for i, arg := range args {
+		decoded := DecodedArg{Name: arg.Name, Type: arg.Type.String()}
+		if i < len(values) {
+			decoded.Value = values[i]
+		}
+		result = append(result, decoded)
+	}
+	return result
+}
+
+// FormatDecodedValue formats a decoded ABI value for display
+func FormatDecodedValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "<nil>"
+	case common.Address:
+		return v.Hex()
+	case common.Hash:
+		return v.Hex()
+	case *big.Int:
+		return v.String()
+	case []byte:
+		return fmt.Sprintf("0x%x", v)
+	case string:
+		return fmt.Sprintf("%q", v)
+	}
+
+	// Fixed size byte arrays (bytes1..bytes32) and composite values
+	if b, err := json.Marshal(value); err == nil {
+		return string(b)
+	}
+	return fmt.Sprintf("%v", value)
+}
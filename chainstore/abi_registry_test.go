@@ -0,0 +1,133 @@
+package chainstore
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+const testArtifactABI = `{"abi":[
+	{"type":"function","name":"setGreeting","inputs":[{"name":"greeting","type":"string"}],"outputs":[],"stateMutability":"nonpayable"},
+	{"type":"error","name":"Unauthorized","inputs":[{"name":"caller","type":"address"}]}
+],"bytecode":{"object":"0x"}}`
+
+func TestABIRegistryLoadPathArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	contractDir := filepath.Join(dir, "Greeter.sol")
+	require.NoError(t, os.MkdirAll(contractDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(contractDir, "Greeter.json"), []byte(testArtifactABI), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.json"), []byte(`{"foo":"bar"}`), 0o644))
+
+	registry := NewABIRegistry()
+	require.NoError(t, registry.LoadPath(dir))
+
+	parsed, err := abi.JSON(strings.NewReader(`[{"type":"function","name":"setGreeting","inputs":[{"name":"greeting","type":"string"}]}]`))
+	require.NoError(t, err)
+	input, err := parsed.Pack("setGreeting", "hello")
+	require.NoError(t, err)
+
+	decoded, err := registry.DecodeCalldata(input)
+	require.NoError(t, err)
+	require.Equal(t, "Greeter", decoded.Contract)
+	require.Equal(t, "setGreeting(string)", decoded.Signature)
+	require.Len(t, decoded.Args, 1)
+	require.Equal(t, "greeting", decoded.Args[0].Name)
+	require.Equal(t, "hello", decoded.Args[0].Value)
+
+	sigs := registry.LookupSignatures("0x" + common.Bytes2Hex(input[:4]))
+	require.Len(t, sigs, 1)
+	require.Equal(t, "setGreeting(string)", sigs[0].TextSignature)
+
+	caller := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	revert := append(crypto.Keccak256([]byte("Unauthorized(address)"))[:4], common.LeftPadBytes(caller.Bytes(), 32)...)
+	decodedRevert, err := registry.DecodeRevert(revert)
+	require.NoError(t, err)
+	require.Equal(t, "Unauthorized", decodedRevert.Name)
+	require.Equal(t, caller, decodedRevert.Args[0].Value)
+}
+
+func TestABIRegistryDecodeTransferLayouts(t *testing.T) {
+	registry := NewABIRegistry()
+	require.NoError(t, registry.LoadBindings())
+
+	from := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	to := common.HexToAddress("0x0000000000000000000000000000000000000002")
+	topic := crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+	amount := common.LeftPadBytes(big.NewInt(42).Bytes(), 32)
+
+	// ERC20 Transfer has the value in the data
+	erc20, err := registry.DecodeLog([]common.Hash{topic, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())}, amount)
+	require.NoError(t, err)
+	require.Equal(t, "Transfer", erc20.Name)
+	require.False(t, erc20.Args[2].Indexed)
+	require.Equal(t, big.NewInt(42), erc20.Args[2].Value)
+
+	// ERC721 Transfer has the token ID as a third topic
+	erc721, err := registry.DecodeLog([]common.Hash{topic, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes()), common.BytesToHash(amount)}, nil)
+	require.NoError(t, err)
+	require.True(t, erc721.Args[2].Indexed)
+	require.Equal(t, big.NewInt(42), erc721.Args[2].Value)
+}
+
+func TestABIRegistryDecodeUnnamedIndexedParams(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(`[{"type":"event","name":"Moved","inputs":[
+		{"name":"","type":"address","indexed":true},
+		{"name":"","type":"address","indexed":true},
+		{"name":"amount","type":"uint256","indexed":false}
+	]}]`))
+	require.NoError(t, err)
+
+	registry := NewABIRegistry()
+	registry.Add("Mover", &parsed)
+
+	from := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	to := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+	topic := crypto.Keccak256Hash([]byte("Moved(address,address,uint256)"))
+	amount := common.LeftPadBytes(big.NewInt(7).Bytes(), 32)
+
+	topics := []common.Hash{topic, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())}
+	decoded, err := registry.DecodeLog(topics, amount)
+	require.NoError(t, err)
+	require.Len(t, decoded.Args, 3)
+	require.Equal(t, from, decoded.Args[0].Value)
+	require.Equal(t, to, decoded.Args[1].Value)
+	require.Equal(t, big.NewInt(7), decoded.Args[2].Value)
+
+	// Parsed ABIs name unnamed params argN, events built by hand keep the
+	// empty names
+	event := parsed.Events["Moved"]
+	event.Inputs = abi.Arguments{
+		{Type: event.Inputs[0].Type, Indexed: true},
+		{Type: event.Inputs[1].Type, Indexed: true},
+		{Type: event.Inputs[2].Type},
+	}
+	decoded, err = decodeEvent(event, topics, amount)
+	require.NoError(t, err)
+	require.Equal(t, from, decoded.Args[0].Value)
+	require.Equal(t, to, decoded.Args[1].Value)
+}
+
+func TestABIRegistryDecodeRevertReason(t *testing.T) {
+	registry := NewABIRegistry()
+
+	stringType, err := abi.NewType("string", "", nil)
+	require.NoError(t, err)
+	packed, err := abi.Arguments{{Type: stringType}}.Pack("not enough balance")
+	require.NoError(t, err)
+	data := append(crypto.Keccak256([]byte("Error(string)"))[:4], packed...)
+
+	decoded, err := registry.DecodeRevert(data)
+	require.NoError(t, err)
+	require.Equal(t, "Error(string)", decoded.Signature)
+	require.Equal(t, "not enough balance", decoded.Args[0].Value)
+
+	_, err = registry.DecodeRevert([]byte{0xde, 0xad, 0xbe, 0xef})
+	require.ErrorIs(t, err, ErrSignatureNotFound)
+}
@@ -1,12 +1,19 @@
 package chainstore
 
 import (
+	"container/list"
 	"math/big"
 	"strconv"
 	"sync"
 	"time"
+
+	"github.com/ethereum/go-ethereum/common"
 )
 
+// Maximum number of transactions whose replay result is cached (LRU eviction
+// when exceeded)
+const maxRevertDataEntries = 1024
+
 // CachedValue represents a cached value with TTL
 type CachedValue[T any] struct {
 	value     T
@@ -100,13 +107,28 @@ type ChainCache struct {
 
 	// Signature data (1 hour TTL)
 	signatures map[string]*CachedValue[[]Signature]
+
+	// Replay results of mined transactions (LRU with TTL)
+	revertData    map[common.Hash]*list.Element
+	revertDataLRU *list.List
+}
+
+// revertDataEntry is the replay result of a transaction. Transactions whose
+// replay did not revert are cached too so they are not replayed again.
+type revertDataEntry struct {
+	txHash   common.Hash
+	data     []byte
+	reverted bool
+	cached   *CachedValue[struct{}]
 }
 
 // NewChainCache creates a new chain cache
 func NewChainCache() *ChainCache {
 	return &ChainCache{
-		feeHistories: make(map[feeHistoryCacheKey]*CachedValue[*FeeHistoryResult]),
-		signatures:   make(map[string]*CachedValue[[]Signature]),
+		feeHistories:  make(map[feeHistoryCacheKey]*CachedValue[*FeeHistoryResult]),
+		signatures:    make(map[string]*CachedValue[[]Signature]),
+		revertData:    make(map[common.Hash]*list.Element),
+		revertDataLRU: list.New(),
 	}
 }
 
@@ -319,3 +341,50 @@ func (cc *ChainCache) SetSignatures(hexSignature string, signatures []Signature,
 	defer cc.mu.Unlock()
 	cc.signatures[hexSignature] = NewCachedValue(signatures, ttl)
 }
+
+// GetRevertData gets the cached replay result of a transaction. The data is
+// only meaningful when reverted is true.
+func (cc *ChainCache) GetRevertData(txHash common.Hash) (data []byte, reverted bool, valid bool) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	elem, exists := cc.revertData[txHash]
+	if !exists {
+		return nil, false, false
+	}
+
+	entry := elem.Value.(*revertDataEntry)
+	if !entry.cached.IsValid() {
+		cc.revertDataLRU.Remove(elem)
+		delete(cc.revertData, txHash)
+		return nil, false, false
+	}
+	cc.revertDataLRU.MoveToFront(elem)
+	return entry.data, entry.reverted, true
+}
+
+// SetRevertData caches the replay result of a transaction, evicting the least
+// recently used result when the cache is full
+func (cc *ChainCache) SetRevertData(txHash common.Hash, data []byte, reverted bool, ttl time.Duration) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	entry := &revertDataEntry{
+		txHash:   txHash,
+		data:     data,
+		reverted: reverted,
+		cached:   NewCachedValue(struct{}{}, ttl),
+	}
+	if elem, exists := cc.revertData[txHash]; exists {
+		elem.Value = entry
+		cc.revertDataLRU.MoveToFront(elem)
+		return
+	}
+
+	cc.revertData[txHash] = cc.revertDataLRU.PushFront(entry)
+	for cc.revertDataLRU.Len() > maxRevertDataEntries {
+		oldest := cc.revertDataLRU.Back()
+		cc.revertDataLRU.Remove(oldest)
+		delete(cc.revertData, oldest.Value.(*revertDataEntry).txHash)
+	}
+}
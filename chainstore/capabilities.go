@@ -2,10 +2,12 @@ package chainstore
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/rs/zerolog/log"
 )
@@ -77,6 +79,7 @@ func (cm *CapabilityManager) RefreshCapabilities(ctx context.Context) error {
 		"engine_forkchoiceUpdatedV3",
 		"debug_getRawBlock",
 		"debug_getRawHeader",
+		"debug_traceTransaction",
 		"net_peerCount",
 	}
 
@@ -196,6 +199,17 @@ func (cm *CapabilityManager) testMethodCapability(ctx context.Context, method st
 		err := cm.client.CallContext(testCtx, &result, method, "latest")
 		return err == nil
 
+	case "debug_traceTransaction":
+		// Tracing an unknown hash fails, but with a JSON-RPC error other
+		// than "method not found" when the debug namespace is exposed
+		var result any
+		err := cm.client.CallContext(testCtx, &result, method, common.Hash{})
+		var rpcErr rpc.Error
+		if !errors.As(err, &rpcErr) {
+			return err == nil
+		}
+		return rpcErr.ErrorCode() != methodNotFoundCode && !isMethodNotFoundError(err)
+
 	default:
 		// For unknown methods, try a generic call
 		var result any
@@ -204,6 +218,9 @@ func (cm *CapabilityManager) testMethodCapability(ctx context.Context, method st
 	}
 }
 
+// methodNotFoundCode is the JSON-RPC error code for an unknown method
+const methodNotFoundCode = -32601
+
 // isMethodNotFoundError checks if the error indicates the method is not found
 func isMethodNotFoundError(err error) bool {
 	if err == nil {
@@ -7,6 +7,7 @@ import (
 
 	"github.com/0xPolygon/polygon-cli/rpctypes"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 )
 
 // FeeHistoryResult represents the result of eth_feeHistory
@@ -62,6 +63,8 @@ type ChainStore interface {
 	GetPendingTransactionCount(ctx context.Context) (*big.Int, error)
 	GetQueuedTransactionCount(ctx context.Context) (*big.Int, error)
 	GetTxPoolStatus(ctx context.Context) (map[string]any, error)
+	GetTxPoolContent(ctx context.Context) (*TxPoolContent, error)
+	GetTxPoolInspect(ctx context.Context) (*TxPoolInspect, error)
 	GetNetPeerCount(ctx context.Context) (*big.Int, error)
 
 	// === CAPABILITY & MANAGEMENT ===
@@ -77,10 +80,39 @@ type ChainStore interface {
 	// GetSignature retrieves function/event signatures from 4byte.directory
 	GetSignature(ctx context.Context, hexSignature string) ([]Signature, error)
 
+	// === CONTRACT DECODING ===
+	// DecodeCalldata, DecodeLog and DecodeRevert decode against local ABIs only
+	DecodeCalldata(data []byte) (*DecodedCall, error)
+	DecodeLog(topics []common.Hash, data []byte) (*DecodedCall, error)
+	DecodeRevert(data []byte) (*DecodedCall, error)
+	// GetRevertData replays a failed transaction to recover its revert data
+	GetRevertData(ctx context.Context, tx rpctypes.PolyTransaction) ([]byte, error)
+
+	// === TRACING ===
+	// GetCallTrace retrieves the call tree of a transaction using callTracer
+	GetCallTrace(ctx context.Context, txHash common.Hash) (*CallFrame, error)
+	// GetStateDiff retrieves the state changes of a transaction using prestateTracer in diff mode
+	GetStateDiff(ctx context.Context, txHash common.Hash) (*StateDiff, error)
+
 	// Close closes the store and releases any resources
 	Close() error
 }
 
+// TxPoolContent represents the result of txpool_content. Transactions are
+// keyed by sender address and then by decimal nonce.
+type TxPoolContent struct {
+	Pending map[string]map[string]rpctypes.RawTransactionResponse `json:"pending"`
+	Queued  map[string]map[string]rpctypes.RawTransactionResponse `json:"queued"`
+}
+
+// TxPoolInspect represents the result of txpool_inspect. Each transaction is
+// summarised as "to: value wei + gas gas × price wei", keyed by sender
+// address and then by decimal nonce.
+type TxPoolInspect struct {
+	Pending map[string]map[string]string `json:"pending"`
+	Queued  map[string]map[string]string `json:"queued"`
+}
+
 // Signature represents a function or event signature from 4byte.directory
 type Signature struct {
 	ID             int       `json:"id"`
@@ -97,3 +129,36 @@ type SignatureResponse struct {
 	Previous *string     `json:"previous"`
 	Results  []Signature `json:"results"`
 }
+
+// CallFrame is a single frame of a callTracer trace. Nested calls made by the
+// frame are in Calls.
+type CallFrame struct {
+	Type         string         `json:"type"`
+	From         common.Address `json:"from"`
+	To           common.Address `json:"to"`
+	Value        *hexutil.Big   `json:"value,omitempty"`
+	Gas          hexutil.Uint64 `json:"gas"`
+	GasUsed      hexutil.Uint64 `json:"gasUsed"`
+	Input        hexutil.Bytes  `json:"input"`
+	Output       hexutil.Bytes  `json:"output,omitempty"`
+	Error        string         `json:"error,omitempty"`
+	RevertReason string         `json:"revertReason,omitempty"`
+	Calls        []CallFrame    `json:"calls,omitempty"`
+}
+
+// StateDiff is the result of prestateTracer in diff mode. Pre holds the
+// original values of everything the transaction modified and Post holds only
+// the modified values. Accounts missing from Post were deleted and accounts
+// missing from Pre were created.
+type StateDiff struct {
+	Pre  map[common.Address]*AccountState `json:"pre"`
+	Post map[common.Address]*AccountState `json:"post"`
+}
+
+// AccountState is the state of an account as reported by prestateTracer
+type AccountState struct {
+	Balance *hexutil.Big                `json:"balance,omitempty"`
+	Nonce   uint64                      `json:"nonce,omitempty"`
+	Code    hexutil.Bytes               `json:"code,omitempty"`
+	Storage map[common.Hash]common.Hash `json:"storage,omitempty"`
+}
@@ -24,6 +24,10 @@ type ChainStoreConfig struct {
 	SignatureLookupTimeout     time.Duration // HTTP timeout for API calls (5 seconds)
 	SignatureLookupAPIURL      string        // 4byte.directory function signatures API endpoint
 	EventSignatureLookupAPIURL string        // 4byte.directory event signatures API endpoint
+
+	// Local ABI registry configuration
+	ABIPaths         []string // ABI files or directories (Foundry out/, Hardhat artifacts/) to load
+	LoadBindingsABIs bool     // Load the ABIs of the contract bindings bundled with polycli
 }
 
 // DefaultChainStoreConfig returns default configuration
@@ -44,5 +48,7 @@ func DefaultChainStoreConfig() *ChainStoreConfig {
 		SignatureLookupTimeout:     5 * time.Second,
 		SignatureLookupAPIURL:      "https://www.4byte.directory/api/v1/signatures/",
 		EventSignatureLookupAPIURL: "https://www.4byte.directory/api/v1/event-signatures/",
+
+		LoadBindingsABIs: true,
 	}
 }
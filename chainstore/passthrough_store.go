@@ -3,6 +3,7 @@ package chainstore
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
@@ -14,6 +15,7 @@ import (
 
 	"github.com/0xPolygon/polygon-cli/rpctypes"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/rs/zerolog/log"
 )
@@ -27,6 +29,7 @@ type PassthroughStore struct {
 	capabilities *CapabilityManager
 	config       *ChainStoreConfig
 	rpcURL       string
+	abis         *ABIRegistry
 }
 
 // NewPassthroughStore creates a new passthrough store with the given RPC client
@@ -58,6 +61,12 @@ func NewPassthroughStoreWithConfig(rpcURL string, config *ChainStoreConfig) (*Pa
 		Timeout: config.SignatureLookupTimeout,
 	}
 
+	abis, err := loadABIRegistry(config)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
 	store := &PassthroughStore{
 		client:       client,
 		httpClient:   httpClient,
@@ -65,6 +74,7 @@ func NewPassthroughStoreWithConfig(rpcURL string, config *ChainStoreConfig) (*Pa
 		capabilities: NewCapabilityManager(client, config.CapabilityTTL),
 		config:       config,
 		rpcURL:       rpcURL,
+		abis:         abis,
 	}
 
 	// Initialize capabilities in background
@@ -78,6 +88,34 @@ func NewPassthroughStoreWithConfig(rpcURL string, config *ChainStoreConfig) (*Pa
 	return store, nil
 }
 
+// loadABIRegistry builds the local ABI registry from the configured sources
+func loadABIRegistry(config *ChainStoreConfig) (*ABIRegistry, error) {
+	abis := NewABIRegistry()
+	if config.LoadBindingsABIs {
+		if err := abis.LoadBindings(); err != nil {
+			return nil, fmt.Errorf("failed to load binding ABIs: %w", err)
+		}
+	}
+	for _, path := range config.ABIPaths {
+		if err := abis.LoadPath(path); err != nil {
+			return nil, fmt.Errorf("failed to load ABIs from %s: %w", path, err)
+		}
+	}
+
+	sources, methods, events, errs := abis.Stats()
+	log.Debug().
+		Int("sources", sources).
+		Int("methods", methods).
+		Int("events", events).
+		Int("errors", errs).
+		Msg("Loaded local ABI registry")
+
+	return abis, nil
+}
+
+// traceTimeout bounds how long the node may spend tracing a transaction
+const traceTimeout = "15s"
+
 // === BLOCK DATA (existing BlockStore methods) ===
 
 // GetBlock retrieves a block by hash or number
@@ -478,6 +516,36 @@ func (s *PassthroughStore) GetTxPoolStatus(ctx context.Context) (map[string]any,
 	return result, nil
 }
 
+// GetTxPoolContent retrieves the full contents of the txpool (not cached, can be large)
+func (s *PassthroughStore) GetTxPoolContent(ctx context.Context) (*TxPoolContent, error) {
+	if !s.capabilities.IsMethodSupported("txpool_content") {
+		return nil, fmt.Errorf("txpool_content method not supported")
+	}
+
+	var result TxPoolContent
+	err := s.client.CallContext(ctx, &result, "txpool_content")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get txpool content: %w", err)
+	}
+
+	return &result, nil
+}
+
+// GetTxPoolInspect retrieves a textual summary of the txpool contents (not cached)
+func (s *PassthroughStore) GetTxPoolInspect(ctx context.Context) (*TxPoolInspect, error) {
+	if !s.capabilities.IsMethodSupported("txpool_inspect") {
+		return nil, fmt.Errorf("txpool_inspect method not supported")
+	}
+
+	var result TxPoolInspect
+	err := s.client.CallContext(ctx, &result, "txpool_inspect")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get txpool inspect: %w", err)
+	}
+
+	return &result, nil
+}
+
 // GetNetPeerCount retrieves the number of connected peers (cached very frequently)
 func (s *PassthroughStore) GetNetPeerCount(ctx context.Context) (*big.Int, error) {
 	if !s.capabilities.IsMethodSupported("net_peerCount") {
@@ -568,19 +636,25 @@ func (s *PassthroughStore) MeasureConnectionLatency(ctx context.Context) (time.D
 
 // === SIGNATURE LOOKUP ===
 
-// GetSignature retrieves function/event signatures from 4byte.directory
+// GetSignature retrieves function/event signatures from the local ABI
+// registry, falling back to 4byte.directory
 func (s *PassthroughStore) GetSignature(ctx context.Context, hexSignature string) ([]Signature, error) {
-	// Check if signature lookup is enabled
-	if !s.config.EnableSignatureLookup {
-		return nil, fmt.Errorf("signature lookup is disabled")
-	}
-
 	// Ensure hex signature is properly formatted
 	hexSignature = strings.ToLower(strings.TrimSpace(hexSignature))
 	if !strings.HasPrefix(hexSignature, "0x") {
 		hexSignature = "0x" + hexSignature
 	}
 
+	// Local ABIs are authoritative and don't need the network
+	if signatures := s.abis.LookupSignatures(hexSignature); len(signatures) > 0 {
+		return signatures, nil
+	}
+
+	// Check if signature lookup is enabled
+	if !s.config.EnableSignatureLookup {
+		return nil, fmt.Errorf("signature lookup is disabled")
+	}
+
 	// Determine signature type and API endpoint based on length
 	var apiURL string
 	var signatureType string
@@ -641,6 +715,123 @@ func (s *PassthroughStore) GetSignature(ctx context.Context, hexSignature string
 	return sigResponse.Results, nil
 }
 
+// === CONTRACT DECODING ===
+
+// DecodeCalldata decodes transaction input using the local ABI registry
+func (s *PassthroughStore) DecodeCalldata(data []byte) (*DecodedCall, error) {
+	return s.abis.DecodeCalldata(data)
+}
+
+// DecodeLog decodes an event log using the local ABI registry
+func (s *PassthroughStore) DecodeLog(topics []common.Hash, data []byte) (*DecodedCall, error) {
+	return s.abis.DecodeLog(topics, data)
+}
+
+// DecodeRevert decodes revert data using the local ABI registry
+func (s *PassthroughStore) DecodeRevert(data []byte) (*DecodedCall, error) {
+	return s.abis.DecodeRevert(data)
+}
+
+// errReplayDidNotRevert is returned when a failed transaction succeeds when
+// replayed on top of its parent block
+var errReplayDidNotRevert = errors.New("transaction did not revert when replayed")
+
+// GetRevertData replays a failed transaction with eth_call against the state
+// of its parent block and returns the revert data. Replaying on top of the
+// parent block ignores earlier transactions in the same block, so the result
+// is best effort.
+func (s *PassthroughStore) GetRevertData(ctx context.Context, tx rpctypes.PolyTransaction) ([]byte, error) {
+	blockNumber := tx.BlockNumber()
+	if blockNumber == nil || blockNumber.Sign() == 0 {
+		return nil, fmt.Errorf("transaction is not mined")
+	}
+
+	if data, reverted, valid := s.cache.GetRevertData(tx.Hash()); valid {
+		if !reverted {
+			return nil, errReplayDidNotRevert
+		}
+		return data, nil
+	}
+
+	call := map[string]any{
+		"from":  tx.From(),
+		"input": hexutil.Bytes(tx.Data()),
+		"gas":   hexutil.Uint64(tx.Gas()),
+		"value": (*hexutil.Big)(tx.Value()),
+	}
+	if to := tx.To(); to != (common.Address{}) {
+		call["to"] = to
+	}
+
+	parent := (*hexutil.Big)(new(big.Int).Sub(blockNumber, big.NewInt(1)))
+	var result hexutil.Bytes
+	err := s.client.CallContext(ctx, &result, "eth_call", call, parent)
+	if err == nil {
+		// The replay succeeded, so the failure depended on in-block state
+		s.cache.SetRevertData(tx.Hash(), nil, false, s.config.SemiStaticTTL)
+		return nil, errReplayDidNotRevert
+	}
+
+	var dataErr rpc.DataError
+	if !errors.As(err, &dataErr) {
+		return nil, fmt.Errorf("failed to replay transaction: %w", err)
+	}
+
+	var data []byte
+	switch v := dataErr.ErrorData().(type) {
+	case string:
+		data, err = hexutil.Decode(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid revert data: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("no revert data returned: %s", dataErr.Error())
+	}
+
+	s.cache.SetRevertData(tx.Hash(), data, true, s.config.SemiStaticTTL)
+	return data, nil
+}
+
+// === TRACING ===
+
+// GetCallTrace retrieves the call tree of a transaction using callTracer
+func (s *PassthroughStore) GetCallTrace(ctx context.Context, txHash common.Hash) (*CallFrame, error) {
+	if !s.capabilities.IsMethodSupported("debug_traceTransaction") {
+		return nil, fmt.Errorf("debug_traceTransaction method not supported")
+	}
+
+	var frame CallFrame
+	config := map[string]any{
+		"tracer":  "callTracer",
+		"timeout": traceTimeout,
+	}
+	if err := s.client.CallContext(ctx, &frame, "debug_traceTransaction", txHash, config); err != nil {
+		return nil, fmt.Errorf("failed to trace transaction: %w", err)
+	}
+
+	return &frame, nil
+}
+
+// GetStateDiff retrieves the state changes of a transaction using
+// prestateTracer in diff mode
+func (s *PassthroughStore) GetStateDiff(ctx context.Context, txHash common.Hash) (*StateDiff, error) {
+	if !s.capabilities.IsMethodSupported("debug_traceTransaction") {
+		return nil, fmt.Errorf("debug_traceTransaction method not supported")
+	}
+
+	var diff StateDiff
+	config := map[string]any{
+		"tracer":       "prestateTracer",
+		"tracerConfig": map[string]any{"diffMode": true},
+		"timeout":      traceTimeout,
+	}
+	if err := s.client.CallContext(ctx, &diff, "debug_traceTransaction", txHash, config); err != nil {
+		return nil, fmt.Errorf("failed to trace state diff: %w", err)
+	}
+
+	return &diff, nil
+}
+
 // Close closes the store and releases any resources
 func (s *PassthroughStore) Close() error {
 	if s.client != nil {
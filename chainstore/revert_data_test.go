@@ -0,0 +1,136 @@
+package chainstore
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-cli/rpctypes"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+// revertError is an eth_call error carrying revert data
+type revertError struct{ data string }
+
+func (e *revertError) Error() string          { return "execution reverted" }
+func (e *revertError) ErrorCode() int         { return 3 }
+func (e *revertError) ErrorData() interface{} { return e.data }
+
+// revertRPCService reverts calls with input, and succeeds otherwise
+type revertRPCService struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (s *revertRPCService) Call(_ context.Context, call map[string]any, _ string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if call["input"] != "0x" {
+		return "", &revertError{data: "0x08c379a0"}
+	}
+	return "0x", nil
+}
+
+func (s *revertRPCService) callCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+func newRevertTestStore(t *testing.T, ttl time.Duration) (*PassthroughStore, *revertRPCService) {
+	t.Helper()
+
+	server := rpc.NewServer()
+	service := &revertRPCService{}
+	require.NoError(t, server.RegisterName("eth", service))
+	client := rpc.DialInProc(server)
+	t.Cleanup(func() {
+		client.Close()
+		server.Stop()
+	})
+
+	store := &PassthroughStore{
+		client: client,
+		cache:  NewChainCache(),
+		config: &ChainStoreConfig{SemiStaticTTL: ttl},
+	}
+	return store, service
+}
+
+func failedTx(nonce uint64, input string) rpctypes.PolyTransaction {
+	return rpctypes.NewPolyTransaction(&rpctypes.RawTransactionResponse{
+		BlockNumber: "0xa",
+		Hash:        rpctypes.RawData32Response(common.BigToHash(new(big.Int).SetUint64(nonce)).Hex()),
+		Nonce:       rpctypes.RawQuantityResponse(fmt.Sprintf("0x%x", nonce)),
+		Input:       rpctypes.RawDataResponse(input),
+		Gas:         "0x5208",
+		Value:       "0x0",
+	})
+}
+
+func TestGetRevertDataCachesResults(t *testing.T) {
+	store, service := newRevertTestStore(t, time.Minute)
+
+	reverting := failedTx(1, "0x01")
+	for range 2 {
+		data, err := store.GetRevertData(t.Context(), reverting)
+		require.NoError(t, err)
+		require.Equal(t, []byte{0x08, 0xc3, 0x79, 0xa0}, data)
+	}
+	require.Equal(t, 1, service.callCount())
+
+	// A replay that does not revert is cached too
+	succeeding := failedTx(2, "0x")
+	for range 2 {
+		_, err := store.GetRevertData(t.Context(), succeeding)
+		require.ErrorIs(t, err, errReplayDidNotRevert)
+	}
+	require.Equal(t, 2, service.callCount())
+}
+
+func TestGetRevertDataRefetchesAfterTTLExpires(t *testing.T) {
+	const ttl = 10 * time.Millisecond
+	store, service := newRevertTestStore(t, ttl)
+
+	tx := failedTx(1, "0x")
+	_, err := store.GetRevertData(t.Context(), tx)
+	require.ErrorIs(t, err, errReplayDidNotRevert)
+
+	time.Sleep(2 * ttl)
+
+	_, err = store.GetRevertData(t.Context(), tx)
+	require.ErrorIs(t, err, errReplayDidNotRevert)
+	require.Equal(t, 2, service.callCount())
+}
+
+func TestSetRevertDataEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewChainCache()
+	hash := func(i int) common.Hash { return common.BigToHash(big.NewInt(int64(i))) }
+
+	for i := range maxRevertDataEntries {
+		cache.SetRevertData(hash(i), []byte{byte(i)}, true, time.Minute)
+	}
+	// Using the oldest result keeps it over the second oldest
+	_, _, valid := cache.GetRevertData(hash(0))
+	require.True(t, valid)
+
+	cache.SetRevertData(hash(maxRevertDataEntries), nil, false, time.Minute)
+	require.Len(t, cache.revertData, maxRevertDataEntries)
+	require.Equal(t, maxRevertDataEntries, cache.revertDataLRU.Len())
+
+	_, _, valid = cache.GetRevertData(hash(1))
+	require.False(t, valid)
+	data, reverted, valid := cache.GetRevertData(hash(0))
+	require.True(t, valid)
+	require.True(t, reverted)
+	require.Equal(t, []byte{0}, data)
+	_, reverted, valid = cache.GetRevertData(hash(maxRevertDataEntries))
+	require.True(t, valid)
+	require.False(t, reverted)
+}
@@ -25,6 +25,10 @@ var (
 	rpcURL       string
 	rendererType string
 	pprofAddr    string
+	promAddr     string
+	abiPaths     []string
+	noBindings   bool
+	no4byte      bool
 )
 
 var MonitorV2Cmd = &cobra.Command{
@@ -61,7 +65,11 @@ var MonitorV2Cmd = &cobra.Command{
 		}
 
 		// Create store
-		store, err := chainstore.NewPassthroughStore(rpcURL)
+		storeConfig := chainstore.DefaultChainStoreConfig()
+		storeConfig.ABIPaths = abiPaths
+		storeConfig.LoadBindingsABIs = !noBindings
+		storeConfig.EnableSignatureLookup = !no4byte
+		store, err := chainstore.NewPassthroughStoreWithConfig(rpcURL, storeConfig)
 		if err != nil {
 			return fmt.Errorf("failed to create store: %w", err)
 		}
@@ -92,8 +100,10 @@ var MonitorV2Cmd = &cobra.Command{
 			r = renderer.NewJSONRenderer(idx)
 		case "tview", "tui":
 			r = renderer.NewTviewRenderer(idx)
+		case "prometheus", "prom":
+			r = renderer.NewPrometheusRenderer(idx, promAddr)
 		default:
-			return fmt.Errorf("unknown renderer type: %s (supported: json, tview, tui)", rendererType)
+			return fmt.Errorf("unknown renderer type: %s (supported: json, tview, tui, prometheus, prom)", rendererType)
 		}
 
 		// Start rendering
@@ -104,6 +114,10 @@ var MonitorV2Cmd = &cobra.Command{
 
 func init() {
 	MonitorV2Cmd.Flags().StringVar(&rpcURL, flag.RPCURL, "", "RPC endpoint URL (required)")
-	MonitorV2Cmd.Flags().StringVar(&rendererType, "renderer", "tui", "renderer type (json, tview, tui)")
+	MonitorV2Cmd.Flags().StringVar(&rendererType, "renderer", "tui", "renderer type (json, tview, tui, prometheus, prom)")
 	MonitorV2Cmd.Flags().StringVar(&pprofAddr, "pprof", "", "pprof server address (e.g. 127.0.0.1:6060)")
+	MonitorV2Cmd.Flags().StringVar(&promAddr, "prom-addr", ":9090", "address the prometheus renderer serves /metrics on")
+	MonitorV2Cmd.Flags().StringSliceVar(&abiPaths, "abi", nil, "ABI files or directories (e.g. Foundry out/) used to decode transactions, events and reverts")
+	MonitorV2Cmd.Flags().BoolVar(&noBindings, "no-bindings-abi", false, "don't decode with the ABIs of the contracts bundled with polycli")
+	MonitorV2Cmd.Flags().BoolVar(&no4byte, "no-4byte", false, "disable signature lookups against 4byte.directory")
 }
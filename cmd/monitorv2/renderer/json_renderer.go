@@ -14,6 +14,11 @@ type JSONRenderer struct {
 	BaseRenderer
 }
 
+// jsonReorg wraps a reorg so it can be told apart from blocks in the output
+type jsonReorg struct {
+	Reorg indexer.ReorgEvent `json:"reorg"`
+}
+
 // NewJSONRenderer creates a new JSON renderer
 func NewJSONRenderer(indexer *indexer.Indexer) *JSONRenderer {
 	return &JSONRenderer{
@@ -25,8 +30,9 @@ func NewJSONRenderer(indexer *indexer.Indexer) *JSONRenderer {
 func (j *JSONRenderer) Start(ctx context.Context) error {
 	log.Info().Msg("Starting JSON renderer")
 
-	// Consume blocks from the indexer's channel
+	// Consume blocks and reorgs from the indexer's channels
 	blockChan := j.indexer.BlockChannel()
+	reorgChan := j.indexer.ReorgChannel()
 
 	for {
 		select {
@@ -40,6 +46,15 @@ func (j *JSONRenderer) Start(ctx context.Context) error {
 			if err := j.outputBlock(block); err != nil {
 				log.Error().Err(err).Msg("Error outputting block")
 			}
+		case reorg, ok := <-reorgChan:
+			if !ok {
+				// Stop selecting on the closed channel
+				reorgChan = nil
+				continue
+			}
+			if err := j.outputBlock(jsonReorg{Reorg: reorg}); err != nil {
+				log.Error().Err(err).Msg("Error outputting reorg")
+			}
 		}
 	}
 }
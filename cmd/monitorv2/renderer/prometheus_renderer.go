@@ -0,0 +1,386 @@
+package renderer
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/0xPolygon/polygon-cli/indexer"
+	"github.com/0xPolygon/polygon-cli/indexer/metrics"
+	"github.com/0xPolygon/polygon-cli/rpctypes"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+)
+
+// prometheusNamespace is the namespace used for all exported metrics
+const prometheusNamespace = "monitorv2"
+
+// PrometheusRenderer exposes indexer metrics and chain information as
+// Prometheus metrics over HTTP instead of drawing them to a terminal
+type PrometheusRenderer struct {
+	BaseRenderer
+	addr         string
+	pollInterval time.Duration
+	registry     *prometheus.Registry
+	server       *http.Server
+
+	// Chain identity
+	chainInfo *prometheus.GaugeVec
+
+	// Latest block metrics
+	blockNumber     prometheus.Gauge
+	blockTimestamp  prometheus.Gauge
+	blockGasUsed    prometheus.Gauge
+	blockGasLimit   prometheus.Gauge
+	blockTxCount    prometheus.Gauge
+	blockBaseFee    prometheus.Gauge
+	blockSize       prometheus.Gauge
+	blocksProcessed prometheus.Counter
+
+	// ChainStore metrics
+	safeBlock         prometheus.Gauge
+	finalizedBlock    prometheus.Gauge
+	txPoolPending     prometheus.Gauge
+	txPoolQueued      prometheus.Gauge
+	peerCount         prometheus.Gauge
+	gasPrice          prometheus.Gauge
+	connectionLatency prometheus.Gauge
+	fetchErrors       *prometheus.CounterVec
+
+	// Metric plugin values, flattened into one gauge per field
+	pluginMetrics *prometheus.GaugeVec
+}
+
+// NewPrometheusRenderer creates a new Prometheus renderer that serves metrics
+// on the given address (e.g. ":9090")
+func NewPrometheusRenderer(indexer *indexer.Indexer, addr string) *PrometheusRenderer {
+	registry := prometheus.NewRegistry()
+	factory := func(name, help string) prometheus.Gauge {
+		g := prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: prometheusNamespace,
+			Name:      name,
+			Help:      help,
+		})
+		registry.MustRegister(g)
+		return g
+	}
+
+	p := &PrometheusRenderer{
+		BaseRenderer: NewBaseRenderer(indexer),
+		addr:         addr,
+		pollInterval: 5 * time.Second,
+		registry:     registry,
+
+		chainInfo: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: prometheusNamespace,
+			Name:      "chain_info",
+			Help:      "Static information about the monitored chain (always 1)",
+		}, []string{"chain_id", "client_version", "rpc_url"}),
+
+		blockNumber:    factory("head_block_number", "Number of the latest indexed block"),
+		blockTimestamp: factory("head_block_timestamp", "Timestamp of the latest indexed block in Unix epoch seconds"),
+		blockGasUsed:   factory("head_block_gas_used", "Gas used by the latest indexed block"),
+		blockGasLimit:  factory("head_block_gas_limit", "Gas limit of the latest indexed block"),
+		blockTxCount:   factory("head_block_transactions", "Number of transactions in the latest indexed block"),
+		blockBaseFee:   factory("head_block_base_fee_wei", "Base fee of the latest indexed block in wei"),
+		blockSize:      factory("head_block_size_bytes", "Size of the latest indexed block in bytes"),
+		blocksProcessed: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: prometheusNamespace,
+			Name:      "blocks_processed_total",
+			Help:      "Number of blocks received from the indexer",
+		}),
+
+		safeBlock:         factory("safe_block_number", "Number of the safe block"),
+		finalizedBlock:    factory("finalized_block_number", "Number of the finalized block"),
+		txPoolPending:     factory("txpool_pending", "Number of pending transactions in the txpool"),
+		txPoolQueued:      factory("txpool_queued", "Number of queued transactions in the txpool"),
+		peerCount:         factory("peers", "Number of peers connected to the RPC node"),
+		gasPrice:          factory("gas_price_wei", "Suggested gas price in wei"),
+		connectionLatency: factory("connection_latency_seconds", "TCP connection latency to the RPC endpoint"),
+		fetchErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prometheusNamespace,
+			Name:      "fetch_errors_total",
+			Help:      "Number of failed chain data fetches by data type",
+		}, []string{"data"}),
+
+		pluginMetrics: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: prometheusNamespace,
+			Name:      "indexer_metric",
+			Help:      "Values computed by the indexer metric plugins",
+		}, []string{"metric", "field"}),
+	}
+
+	registry.MustRegister(p.chainInfo, p.blocksProcessed, p.fetchErrors, p.pluginMetrics)
+
+	return p
+}
+
+// Start serves the metrics endpoint and keeps the metrics up to date until the
+// context is cancelled or the indexer channels are closed
+func (p *PrometheusRenderer) Start(ctx context.Context) error {
+	log.Info().Str("addr", p.addr).Msg("Starting Prometheus renderer")
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(p.registry, promhttp.HandlerOpts{}))
+	p.server = &http.Server{
+		Addr:              p.addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		if err := p.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	go p.consumeMetrics(ctx)
+	go p.pollChainInfo(ctx)
+
+	blockChan := p.indexer.BlockChannel()
+	for {
+		select {
+		case <-ctx.Done():
+			return p.Stop()
+		case err := <-serverErr:
+			log.Error().Err(err).Msg("The Prometheus server failed")
+			return err
+		case block, ok := <-blockChan:
+			if !ok {
+				log.Info().Msg("Block channel closed, stopping Prometheus renderer")
+				return p.Stop()
+			}
+			p.updateBlockMetrics(block)
+		}
+	}
+}
+
+// Stop gracefully shuts down the metrics server
+func (p *PrometheusRenderer) Stop() error {
+	if p.server == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return p.server.Shutdown(ctx)
+}
+
+// updateBlockMetrics updates the head block gauges from the latest published
+// block. The indexer publishes blocks in order and republishes the new chain
+// after a reorg, so a lower block than the previous head is the canonical head.
+func (p *PrometheusRenderer) updateBlockMetrics(block rpctypes.PolyBlock) {
+	p.blocksProcessed.Inc()
+
+	number := block.Number()
+	if number == nil {
+		return
+	}
+
+	p.blockNumber.Set(float64(number.Uint64()))
+	p.blockTimestamp.Set(float64(block.Time()))
+	p.blockGasUsed.Set(float64(block.GasUsed()))
+	p.blockGasLimit.Set(float64(block.GasLimit()))
+	p.blockTxCount.Set(float64(len(block.Transactions())))
+	p.blockSize.Set(float64(block.Size()))
+	if baseFee := block.BaseFee(); baseFee != nil {
+		p.blockBaseFee.Set(bigToFloat(baseFee))
+	}
+}
+
+// consumeMetrics consumes metric plugin updates and exports their values
+func (p *PrometheusRenderer) consumeMetrics(ctx context.Context) {
+	metricsChan := p.indexer.MetricsChannel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case update, ok := <-metricsChan:
+			if !ok {
+				log.Info().Msg("Metrics channel closed")
+				return
+			}
+			p.updatePluginMetric(update)
+		}
+	}
+}
+
+// updatePluginMetric exports every numeric field of a metric plugin value
+func (p *PrometheusRenderer) updatePluginMetric(update metrics.MetricUpdate) {
+	for field, value := range flattenMetric(update.Value) {
+		p.pluginMetrics.WithLabelValues(update.Name, field).Set(value)
+	}
+}
+
+// pollChainInfo periodically refreshes the ChainStore backed metrics
+func (p *PrometheusRenderer) pollChainInfo(ctx context.Context) {
+	p.fetchChainIdentity(ctx)
+	p.fetchChainInfo(ctx)
+
+	ticker := time.NewTicker(p.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.fetchChainInfo(ctx)
+		}
+	}
+}
+
+// fetchChainIdentity sets the chain info metric from data that never changes
+func (p *PrometheusRenderer) fetchChainIdentity(ctx context.Context) {
+	chainID := "unknown"
+	if id, err := p.indexer.GetChainID(ctx); err == nil {
+		chainID = id.String()
+	} else {
+		p.fetchErrors.WithLabelValues("chain_id").Inc()
+	}
+
+	clientVersion := "unknown"
+	if version, err := p.indexer.GetClientVersion(ctx); err == nil {
+		clientVersion = version
+	} else {
+		p.fetchErrors.WithLabelValues("client_version").Inc()
+	}
+
+	p.chainInfo.WithLabelValues(chainID, clientVersion, p.indexer.GetRPCURL()).Set(1)
+}
+
+// fetchChainInfo fetches the latest chain data from the indexer. Metrics whose
+// data can't be fetched keep their previous value and bump the error counter.
+func (p *PrometheusRenderer) fetchChainInfo(ctx context.Context) {
+	setBig := func(name string, gauge prometheus.Gauge, fetch func(context.Context) (*big.Int, error)) {
+		value, err := fetch(ctx)
+		if err != nil || value == nil {
+			log.Debug().Err(err).Str("data", name).Msg("Failed to fetch chain data")
+			p.fetchErrors.WithLabelValues(name).Inc()
+			return
+		}
+		gauge.Set(bigToFloat(value))
+	}
+
+	setBig("safe_block", p.safeBlock, p.indexer.GetSafeBlock)
+	setBig("finalized_block", p.finalizedBlock, p.indexer.GetFinalizedBlock)
+	setBig("gas_price", p.gasPrice, p.indexer.GetGasPrice)
+	setBig("peer_count", p.peerCount, p.indexer.GetNetPeerCount)
+
+	if status, err := p.indexer.GetTxPoolStatus(ctx); err == nil {
+		if pending, err := hexToDecimal(status["pending"]); err == nil {
+			p.txPoolPending.Set(bigToFloat(pending))
+		}
+		if queued, err := hexToDecimal(status["queued"]); err == nil {
+			p.txPoolQueued.Set(bigToFloat(queued))
+		}
+	} else {
+		log.Debug().Err(err).Msg("Failed to fetch txpool status")
+		p.fetchErrors.WithLabelValues("txpool_status").Inc()
+	}
+
+	if latency, err := p.indexer.MeasureConnectionLatency(ctx); err == nil {
+		p.connectionLatency.Set(latency.Seconds())
+	} else {
+		log.Debug().Err(err).Msg("Failed to measure connection latency")
+		p.fetchErrors.WithLabelValues("connection_latency").Inc()
+	}
+}
+
+// bigToFloat converts a big.Int to a float64 for export
+func bigToFloat(value *big.Int) float64 {
+	f, _ := new(big.Float).SetInt(value).Float64()
+	return f
+}
+
+// flattenMetric converts a metric plugin value into a set of named numeric
+// values. Scalars are exported as "value", structs have one entry per
+// exported numeric field, and durations are exported in seconds.
+func flattenMetric(value any) map[string]float64 {
+	result := make(map[string]float64)
+	flattenValue(reflect.ValueOf(value), "", result)
+	return result
+}
+
+// flattenValue recursively walks a value and records its numeric leaves
+func flattenValue(v reflect.Value, prefix string, result map[string]float64) {
+	if !v.IsValid() {
+		return
+	}
+
+	name := prefix
+	if name == "" {
+		name = "value"
+	}
+
+	switch x := v.Interface().(type) {
+	case time.Duration:
+		result[name+"_seconds"] = x.Seconds()
+		return
+	case *big.Int:
+		if x != nil {
+			result[name] = bigToFloat(x)
+		}
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			flattenValue(v.Elem(), prefix, result)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			fieldName := toSnakeCase(field.Name)
+			if prefix != "" {
+				fieldName = prefix + "_" + fieldName
+			}
+			flattenValue(v.Field(i), fieldName, result)
+		}
+	case reflect.Float32, reflect.Float64:
+		result[name] = v.Float()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		result[name] = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		result[name] = float64(v.Uint())
+	case reflect.Bool:
+		if v.Bool() {
+			result[name] = 1
+		} else {
+			result[name] = 0
+		}
+	}
+}
+
+// toSnakeCase converts a Go field name such as "BaseFee10" or "TPS10" into
+// a Prometheus friendly label value such as "base_fee10" or "tps10"
+func toSnakeCase(name string) string {
+	runes := []rune(name)
+	var sb strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			prevLower := i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]))
+			nextLower := i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1])
+			if prevLower || nextLower {
+				sb.WriteRune('_')
+			}
+			sb.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,130 @@
+package renderer
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-cli/indexer/metrics"
+	"github.com/0xPolygon/polygon-cli/rpctypes"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFlattenMetricNames pins the field labels of the metric plugin values,
+// which dashboards query by name.
+func TestFlattenMetricNames(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  map[string]float64
+	}{
+		{
+			name:  "tps",
+			value: 12.5,
+			want:  map[string]float64{"value": 12.5},
+		},
+		{
+			name:  "basefee",
+			value: metrics.BaseFeeStats{BaseFee10: big.NewInt(7), BaseFee30: big.NewInt(9), BlocksAvailable: 30},
+			want:  map[string]float64{"base_fee10": 7, "base_fee30": 9, "blocks_available": 30},
+		},
+		{
+			name: "blockTime",
+			value: &metrics.BlockTimeStats{
+				AverageBlockTime: 2 * time.Second,
+				MinBlockTime:     time.Second,
+				MaxBlockTime:     3 * time.Second,
+				WindowSize:       10,
+				MaxWindowSize:    50,
+			},
+			want: map[string]float64{
+				"average_block_time_seconds": 2,
+				"min_block_time_seconds":     1,
+				"max_block_time_seconds":     3,
+				"window_size":                10,
+				"max_window_size":            50,
+			},
+		},
+		{
+			name:  "emptyBlockRate",
+			value: metrics.EmptyBlockStats{TotalBlocks: 4, EmptyBlocks: 1, OverallRate: 0.25, RecentRate: 0.5, RecentWindowSize: 2},
+			want: map[string]float64{
+				"total_blocks":       4,
+				"empty_blocks":       1,
+				"overall_rate":       0.25,
+				"recent_rate":        0.5,
+				"recent_window_size": 2,
+			},
+		},
+		{
+			name:  "throughput",
+			value: metrics.ThroughputStats{TPS10: 1, TPS30: 2, GPS10: 3, GPS30: 4, BlocksAvailable: 30},
+			want:  map[string]float64{"tps10": 1, "tps30": 2, "gps10": 3, "gps30": 4, "blocks_available": 30},
+		},
+		{
+			name:  "nil base fee",
+			value: metrics.BaseFeeStats{BlocksAvailable: 0},
+			want:  map[string]float64{"blocks_available": 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, flattenMetric(tt.value))
+		})
+	}
+}
+
+func TestToSnakeCase(t *testing.T) {
+	for name, want := range map[string]string{
+		"BaseFee10":        "base_fee10",
+		"TPS10":            "tps10",
+		"AverageBlockTime": "average_block_time",
+		"RPCURL":           "rpcurl",
+		"HTTPServer":       "http_server",
+		"Value":            "value",
+	} {
+		require.Equal(t, want, toSnakeCase(name), name)
+	}
+}
+
+func testBlock(number uint64, hash byte) rpctypes.PolyBlock {
+	return rpctypes.NewPolyBlock(&rpctypes.RawBlockResponse{
+		Number:        rpctypes.RawQuantityResponse(fmt.Sprintf("0x%x", number)),
+		Hash:          rpctypes.RawData32Response(fmt.Sprintf("0x%064x", hash)),
+		Timestamp:     rpctypes.RawQuantityResponse(fmt.Sprintf("0x%x", 1000+number)),
+		GasUsed:       "0x5208",
+		GasLimit:      "0x1c9c380",
+		Size:          "0x220",
+		BaseFeePerGas: "0x7",
+		Transactions:  []rpctypes.RawTransactionResponse{},
+	})
+}
+
+// TestUpdateBlockMetricsAfterReorg checks the head follows the chain the
+// indexer republishes after a reorg to a lower height.
+func TestUpdateBlockMetricsAfterReorg(t *testing.T) {
+	p := NewPrometheusRenderer(nil, ":0")
+
+	for _, number := range []uint64{10, 11, 12} {
+		p.updateBlockMetrics(testBlock(number, 1))
+	}
+	require.Equal(t, float64(12), testutil.ToFloat64(p.blockNumber))
+
+	// The new chain is shorter, forking after block 10
+	p.updateBlockMetrics(testBlock(11, 2))
+	require.Equal(t, float64(11), testutil.ToFloat64(p.blockNumber))
+	require.Equal(t, float64(1011), testutil.ToFloat64(p.blockTimestamp))
+	require.Equal(t, float64(4), testutil.ToFloat64(p.blocksProcessed))
+
+	names := make([]string, 0)
+	families, err := p.registry.Gather()
+	require.NoError(t, err)
+	for _, f := range families {
+		names = append(names, f.GetName())
+	}
+	require.True(t, slices.Contains(names, "monitorv2_head_block_number"))
+}
@@ -2,9 +2,12 @@ package renderer
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/0xPolygon/polygon-cli/rpctypes"
 	"github.com/gdamore/tcell/v2"
@@ -93,10 +96,15 @@ func (t *TviewRenderer) showBlockDetail(block rpctypes.PolyBlock) {
 		gasLimit := formatNumber(tx.Gas())
 		t.blockDetailLeft.SetCell(row, 3, tview.NewTableCell(gasLimit).SetAlign(tview.AlignRight))
 
-		// Column 4: First 4 bytes of input data
+		// Column 4: First 4 bytes of input data, named when known to the local ABI registry
 		inputData := "N/A"
 		if len(tx.Data()) >= 4 {
 			inputData = fmt.Sprintf("0x%x", tx.Data()[:4])
+			if t.indexer != nil {
+				if decoded, err := t.indexer.DecodeCalldata(tx.Data()); err == nil {
+					inputData = fmt.Sprintf("%s (%s)", decoded.Name, inputData)
+				}
+			}
 		} else if len(tx.Data()) > 0 {
 			inputData = fmt.Sprintf("0x%x", tx.Data())
 		}
@@ -130,6 +138,67 @@ func (t *TviewRenderer) showBlockDetail(block rpctypes.PolyBlock) {
 
 	// Set focus to the left pane (transaction table) by default
 	t.app.SetFocus(t.blockDetailLeft)
+
+	// Receipts aren't part of the block, so failed transactions are marked once fetched
+	go t.loadFailedTransactionsAsync(block)
+}
+
+// blockReceiptConcurrency bounds the receipts fetched at once for a block
+const blockReceiptConcurrency = 8
+
+// loadFailedTransactionsAsync fetches the receipts of a block's transactions
+// and marks the rows of failed ones with their revert reason
+func (t *TviewRenderer) loadFailedTransactionsAsync(block rpctypes.PolyBlock) {
+	if t.indexer == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	sem := make(chan struct{}, blockReceiptConcurrency)
+	var wg sync.WaitGroup
+	for i, tx := range block.Transactions() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			receipt, err := t.indexer.GetReceipt(ctx, tx.Hash())
+			if err != nil || receipt.Status() != 0 {
+				return
+			}
+			reason := t.revertSummary(ctx, tx)
+			t.app.QueueUpdateDraw(func() {
+				t.markFailedTransactionRow(block, i, reason)
+			})
+		}()
+	}
+	wg.Wait()
+}
+
+// markFailedTransactionRow colors the row of a failed transaction and adds its
+// revert reason to the input column, unless another block is shown by now
+func (t *TviewRenderer) markFailedTransactionRow(block rpctypes.PolyBlock, txIndex int, reason string) {
+	t.currentBlockMu.RLock()
+	currentBlock := t.currentBlock
+	t.currentBlockMu.RUnlock()
+	if currentBlock == nil || currentBlock.Hash() != block.Hash() {
+		return
+	}
+
+	row := txIndex + 1 // +1 to account for header row
+	for col := 0; col < 5; col++ {
+		t.blockDetailLeft.GetCell(row, col).SetTextColor(tcell.ColorRed)
+	}
+
+	status := "reverted"
+	if reason != "" {
+		status += ": " + reason
+	}
+	input := t.blockDetailLeft.GetCell(row, 4)
+	input.SetText(fmt.Sprintf("%s %s", input.Text, status))
 }
 
 func (t *TviewRenderer) clearBlockDetailRow(row int) {
@@ -0,0 +1,521 @@
+package renderer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0xPolygon/polygon-cli/chainstore"
+	"github.com/0xPolygon/polygon-cli/rpctypes"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/rs/zerolog/log"
+)
+
+// Maximum number of transaction rows shown in the mempool table
+const maxMempoolRows = 5000
+
+// Number of buckets in the mempool fee histograms
+const mempoolHistogramBuckets = 10
+
+// mempoolTx is a single txpool transaction annotated for display
+type mempoolTx struct {
+	tx     rpctypes.PolyTransaction
+	queued bool   // true if the transaction is in the queued (not executable) pool
+	gapAt  bool   // true if one or more nonces are missing right before this transaction
+	gapLen uint64 // Number of missing nonces before this transaction
+}
+
+// mempoolSender groups the txpool transactions of a single sender ordered by nonce
+type mempoolSender struct {
+	address common.Address
+	txs     []mempoolTx
+	pending int
+	queued  int
+	gaps    int
+}
+
+// mempoolView is the processed txpool content displayed on the mempool page
+type mempoolView struct {
+	source   string // RPC method the data came from
+	senders  []mempoolSender
+	pending  int
+	queued   int
+	maxFees  []*big.Int // Max fee per gas (or gas price for legacy txs)
+	tipFees  []*big.Int // Max priority fee per gas (or gas price for legacy txs)
+	loadedAt time.Time
+}
+
+// createMempoolPage creates the mempool explorer page with a grouped
+// transaction table on the left and summary statistics on the right
+func (t *TviewRenderer) createMempoolPage() {
+	t.mempoolTable = tview.NewTable().
+		SetBorders(false).
+		SetSelectable(true, false).
+		SetFixed(1, 0).
+		SetSeparator(' ')
+	t.mempoolTable.SetBorder(true).SetTitle(" Mempool ")
+
+	headers := []string{"SENDER", "NONCE", "POOL", "TO", "MAX FEE", "TIP", "GAS", "HASH"}
+	for col, header := range headers {
+		t.mempoolTable.SetCell(0, col, tview.NewTableCell(header).
+			SetTextColor(tview.Styles.PrimaryTextColor).
+			SetExpansion(1).
+			SetSelectable(false).
+			SetAttributes(tcell.AttrBold))
+	}
+
+	t.mempoolStats = tview.NewTextView().
+		SetDynamicColors(true).
+		SetWordWrap(true)
+	t.mempoolStats.SetBorder(true).SetTitle(" Fee Distribution ")
+	t.mempoolStats.SetText("Press 'm' to load the mempool")
+
+	t.mempoolPage = tview.NewFlex().
+		SetDirection(tview.FlexColumn).
+		AddItem(t.mempoolTable, 0, 2, true).
+		AddItem(t.mempoolStats, 0, 1, false)
+}
+
+// showMempool switches to the mempool page and loads the txpool asynchronously
+func (t *TviewRenderer) showMempool() {
+	t.pages.SwitchToPage("mempool")
+	t.app.SetFocus(t.mempoolTable)
+	go t.loadMempoolAsync()
+}
+
+// refreshMempool periodically reloads the txpool while the mempool page is visible
+func (t *TviewRenderer) refreshMempool(ctx context.Context) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Page state must be read on the application goroutine.
+			t.app.QueueUpdate(func() {
+				if page, _ := t.pages.GetFrontPage(); page == "mempool" {
+					go t.loadMempoolAsync()
+				}
+			})
+		}
+	}
+}
+
+// loadMempoolAsync fetches the txpool using the richest supported method and
+// redraws the mempool page
+func (t *TviewRenderer) loadMempoolAsync() {
+	if !t.mempoolLoading.CompareAndSwap(false, true) {
+		return
+	}
+	defer t.mempoolLoading.Store(false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	var (
+		view *mempoolView
+		err  error
+	)
+	switch {
+	case t.indexer.IsMethodSupported("txpool_content"):
+		var content *chainstore.TxPoolContent
+		if content, err = t.indexer.GetTxPoolContent(ctx); err == nil {
+			view = buildMempoolView(content, "txpool_content")
+		}
+	case t.indexer.IsMethodSupported("txpool_inspect"):
+		var inspect *chainstore.TxPoolInspect
+		if inspect, err = t.indexer.GetTxPoolInspect(ctx); err == nil {
+			view = buildMempoolView(inspectToContent(inspect), "txpool_inspect")
+		}
+	default:
+		err = fmt.Errorf("neither txpool_content nor txpool_inspect is supported by this RPC endpoint")
+	}
+
+	if err != nil {
+		log.Debug().Err(err).Msg("Failed to load mempool")
+		t.app.QueueUpdateDraw(func() {
+			t.mempoolStats.SetText(fmt.Sprintf("[red]Error loading mempool:[-] %v", err))
+		})
+		return
+	}
+
+	t.app.QueueUpdateDraw(func() {
+		t.renderMempool(view)
+	})
+}
+
+// renderMempool draws the mempool table and statistics (must run on the UI goroutine)
+func (t *TviewRenderer) renderMempool(view *mempoolView) {
+	// Keep the selection on the same transaction across refreshes when possible
+	var selectedHash common.Hash
+	if row, _ := t.mempoolTable.GetSelection(); row > 0 && row-1 < len(t.mempoolRows) {
+		if tx := t.mempoolRows[row-1]; tx != nil {
+			selectedHash = tx.Hash()
+		}
+	}
+
+	for row := t.mempoolTable.GetRowCount() - 1; row > 0; row-- {
+		t.mempoolTable.RemoveRow(row)
+	}
+	t.mempoolRows = t.mempoolRows[:0]
+
+	selectRow := 1
+	shown := 0
+	for _, sender := range view.senders {
+		if shown >= maxMempoolRows {
+			break
+		}
+
+		// Sender header row
+		row := t.mempoolTable.GetRowCount()
+		summary := fmt.Sprintf("%d pending, %d queued", sender.pending, sender.queued)
+		senderColor := tcell.ColorYellow
+		if sender.gaps > 0 {
+			summary += fmt.Sprintf(", %d gap(s)", sender.gaps)
+			senderColor = tcell.ColorRed
+		}
+		t.mempoolTable.SetCell(row, 0, tview.NewTableCell(sender.address.Hex()).
+			SetTextColor(senderColor).
+			SetAttributes(tcell.AttrBold))
+		t.mempoolTable.SetCell(row, 1, tview.NewTableCell(summary).SetTextColor(senderColor))
+		for col := 2; col < 8; col++ {
+			t.mempoolTable.SetCell(row, col, tview.NewTableCell(""))
+		}
+		t.mempoolRows = append(t.mempoolRows, nil)
+
+		for _, mtx := range sender.txs {
+			if shown >= maxMempoolRows {
+				break
+			}
+			shown++
+
+			row = t.mempoolTable.GetRowCount()
+			tx := mtx.tx
+
+			color := tcell.ColorWhite
+			pool := "pending"
+			if mtx.queued {
+				color = tcell.ColorGray
+				pool = "queued"
+			}
+
+			nonce := strconv.FormatUint(tx.Nonce(), 10)
+			if mtx.gapAt {
+				color = tcell.ColorRed
+				if mtx.gapLen > 0 {
+					nonce = fmt.Sprintf("%s (gap %d)", nonce, mtx.gapLen)
+				} else {
+					nonce += " (gap)"
+				}
+			}
+
+			to := "CONTRACT"
+			if tx.To() != zeroAddress {
+				to = truncateHash(tx.To().Hex(), 6, 4)
+			}
+
+			hash := "N/A"
+			if tx.Hash() != (common.Hash{}) {
+				hash = truncateHash(tx.Hash().Hex(), 8, 6)
+			}
+
+			maxFee, tip := mempoolFees(tx)
+			cells := []string{"", nonce, pool, to, formatBaseFee(maxFee), formatBaseFee(tip), formatNumber(tx.Gas()), hash}
+			for col, value := range cells {
+				t.mempoolTable.SetCell(row, col, tview.NewTableCell(value).
+					SetTextColor(color).
+					SetExpansion(1))
+			}
+			t.mempoolRows = append(t.mempoolRows, tx)
+
+			if selectedHash != (common.Hash{}) && tx.Hash() == selectedHash {
+				selectRow = row
+			}
+		}
+	}
+
+	title := fmt.Sprintf(" Mempool (%d pending, %d queued, %d senders) ", view.pending, view.queued, len(view.senders))
+	if shown < view.pending+view.queued {
+		title = fmt.Sprintf(" Mempool (%d pending, %d queued, %d senders, showing %d) ",
+			view.pending, view.queued, len(view.senders), shown)
+	}
+	t.mempoolTable.SetTitle(title)
+	if t.mempoolTable.GetRowCount() > 1 {
+		t.mempoolTable.Select(selectRow, 0)
+	}
+
+	t.mempoolStats.SetText(formatMempoolStats(view))
+}
+
+// showSelectedMempoolTransaction opens the transaction detail view for the
+// selected mempool row
+func (t *TviewRenderer) showSelectedMempoolTransaction() {
+	row, _ := t.mempoolTable.GetSelection()
+	if row <= 0 || row-1 >= len(t.mempoolRows) {
+		return
+	}
+
+	tx := t.mempoolRows[row-1]
+	if tx == nil {
+		return
+	}
+
+	// txpool_inspect doesn't include hashes so there's nothing to look up
+	if tx.Hash() == (common.Hash{}) {
+		log.Debug().Msg("Selected mempool transaction has no hash (txpool_inspect source)")
+		return
+	}
+
+	t.showTransactionDetail(tx, -1)
+	t.txDetailParent = "mempool"
+}
+
+// buildMempoolView groups txpool content by sender and computes nonce gaps and
+// fee distributions
+func buildMempoolView(content *chainstore.TxPoolContent, source string) *mempoolView {
+	view := &mempoolView{
+		source:   source,
+		loadedAt: time.Now(),
+	}
+	if content == nil {
+		return view
+	}
+
+	bySender := make(map[common.Address][]mempoolTx)
+	add := func(pool map[string]map[string]rpctypes.RawTransactionResponse, queued bool) {
+		for sender, txs := range pool {
+			address := common.HexToAddress(sender)
+			for nonce := range txs {
+				raw := txs[nonce]
+				if raw.From == "" {
+					raw.From = rpctypes.RawData20Response(sender)
+				}
+				tx := rpctypes.NewPolyTransaction(&raw)
+				bySender[address] = append(bySender[address], mempoolTx{tx: tx, queued: queued})
+
+				maxFee, tip := mempoolFees(tx)
+				view.maxFees = append(view.maxFees, maxFee)
+				view.tipFees = append(view.tipFees, tip)
+				if queued {
+					view.queued++
+				} else {
+					view.pending++
+				}
+			}
+		}
+	}
+	add(content.Pending, false)
+	add(content.Queued, true)
+
+	for address, txs := range bySender {
+		sort.Slice(txs, func(a, b int) bool { return txs[a].tx.Nonce() < txs[b].tx.Nonce() })
+
+		sender := mempoolSender{address: address, txs: txs}
+		for i := range txs {
+			if txs[i].queued {
+				sender.queued++
+			} else {
+				sender.pending++
+			}
+			if i == 0 {
+				// Queued transactions with nothing pending ahead of them are
+				// waiting on a nonce the pool hasn't seen
+				if txs[i].queued {
+					txs[i].gapAt = true
+					sender.gaps++
+				}
+				continue
+			}
+			prev, cur := txs[i-1].tx.Nonce(), txs[i].tx.Nonce()
+			if cur > prev+1 {
+				txs[i].gapAt = true
+				txs[i].gapLen = cur - prev - 1
+				sender.gaps++
+			}
+		}
+		view.senders = append(view.senders, sender)
+	}
+
+	// Senders with gaps first since they're the likely stuck ones, then by
+	// number of transactions
+	sort.Slice(view.senders, func(a, b int) bool {
+		sa, sb := view.senders[a], view.senders[b]
+		if (sa.gaps > 0) != (sb.gaps > 0) {
+			return sa.gaps > 0
+		}
+		if len(sa.txs) != len(sb.txs) {
+			return len(sa.txs) > len(sb.txs)
+		}
+		return sa.address.Hex() < sb.address.Hex()
+	})
+
+	return view
+}
+
+// mempoolFees returns the max fee and priority fee of a transaction, using the
+// gas price for both on legacy transactions
+func mempoolFees(tx rpctypes.PolyTransaction) (*big.Int, *big.Int) {
+	if tx.Type() >= 2 {
+		return new(big.Int).SetUint64(tx.MaxFeePerGas()), new(big.Int).SetUint64(tx.MaxPriorityFeePerGas())
+	}
+	gasPrice := tx.GasPrice()
+	if gasPrice == nil {
+		gasPrice = big.NewInt(0)
+	}
+	return gasPrice, gasPrice
+}
+
+// inspectToContent converts a txpool_inspect summary into txpool_content form
+// so both can share the same view. Hashes and calldata are not available.
+func inspectToContent(inspect *chainstore.TxPoolInspect) *chainstore.TxPoolContent {
+	if inspect == nil {
+		return nil
+	}
+
+	convert := func(pool map[string]map[string]string) map[string]map[string]rpctypes.RawTransactionResponse {
+		result := make(map[string]map[string]rpctypes.RawTransactionResponse, len(pool))
+		for sender, txs := range pool {
+			result[sender] = make(map[string]rpctypes.RawTransactionResponse, len(txs))
+			for nonce, summary := range txs {
+				raw, err := parseInspectSummary(sender, nonce, summary)
+				if err != nil {
+					log.Debug().Err(err).Str("summary", summary).Msg("Failed to parse txpool_inspect entry")
+					continue
+				}
+				result[sender][nonce] = raw
+			}
+		}
+		return result
+	}
+
+	return &chainstore.TxPoolContent{
+		Pending: convert(inspect.Pending),
+		Queued:  convert(inspect.Queued),
+	}
+}
+
+// parseInspectSummary parses a txpool_inspect entry of the form
+// "0xto: 1 wei + 21000 gas × 2 wei" into a raw transaction
+func parseInspectSummary(sender, nonce, summary string) (rpctypes.RawTransactionResponse, error) {
+	idx := strings.LastIndex(summary, ": ")
+	if idx < 0 {
+		return rpctypes.RawTransactionResponse{}, fmt.Errorf("missing recipient separator")
+	}
+	to := summary[:idx]
+
+	var value, gasPrice string
+	var gas uint64
+	if _, err := fmt.Sscanf(summary[idx+2:], "%s wei + %d gas × %s wei", &value, &gas, &gasPrice); err != nil {
+		return rpctypes.RawTransactionResponse{}, err
+	}
+
+	nonceInt, err := strconv.ParseUint(nonce, 10, 64)
+	if err != nil {
+		return rpctypes.RawTransactionResponse{}, err
+	}
+
+	valueInt, ok := new(big.Int).SetString(value, 10)
+	if !ok {
+		return rpctypes.RawTransactionResponse{}, fmt.Errorf("invalid value %q", value)
+	}
+	gasPriceInt, ok := new(big.Int).SetString(gasPrice, 10)
+	if !ok {
+		return rpctypes.RawTransactionResponse{}, fmt.Errorf("invalid gas price %q", gasPrice)
+	}
+
+	raw := rpctypes.RawTransactionResponse{
+		From:     rpctypes.RawData20Response(sender),
+		Nonce:    rpctypes.RawQuantityResponse(fmt.Sprintf("0x%x", nonceInt)),
+		Value:    rpctypes.RawQuantityResponse(fmt.Sprintf("0x%x", valueInt)),
+		Gas:      rpctypes.RawQuantityResponse(fmt.Sprintf("0x%x", gas)),
+		GasPrice: rpctypes.RawQuantityResponse(fmt.Sprintf("0x%x", gasPriceInt)),
+	}
+	if common.IsHexAddress(to) {
+		raw.To = rpctypes.RawData20Response(to)
+	}
+
+	return raw, nil
+}
+
+// formatMempoolStats renders the summary and fee histograms for the stats pane
+func formatMempoolStats(view *mempoolView) string {
+	var sb strings.Builder
+
+	gapSenders := 0
+	for _, sender := range view.senders {
+		if sender.gaps > 0 {
+			gapSenders++
+		}
+	}
+
+	fmt.Fprintf(&sb, "[::b]Source:[-:-:-] %s\n", view.source)
+	fmt.Fprintf(&sb, "[::b]Loaded:[-:-:-] %s\n", view.loadedAt.Format("15:04:05"))
+	fmt.Fprintf(&sb, "[::b]Pending:[-:-:-] %s\n", formatNumber(uint64(view.pending)))
+	fmt.Fprintf(&sb, "[::b]Queued:[-:-:-] %s\n", formatNumber(uint64(view.queued)))
+	fmt.Fprintf(&sb, "[::b]Senders:[-:-:-] %s\n", formatNumber(uint64(len(view.senders))))
+	fmt.Fprintf(&sb, "[::b]Senders with nonce gaps:[-:-:-] [red]%s[-]\n\n", formatNumber(uint64(gapSenders)))
+
+	sb.WriteString("[::b]Max fee per gas[-:-:-]\n")
+	sb.WriteString(formatFeeHistogram(view.maxFees, mempoolHistogramBuckets))
+	sb.WriteString("\n[::b]Priority fee per gas[-:-:-]\n")
+	sb.WriteString(formatFeeHistogram(view.tipFees, mempoolHistogramBuckets))
+
+	sb.WriteString("\n[gray]Red rows follow a nonce gap. Enter opens a transaction, Esc returns home.[-]")
+	return sb.String()
+}
+
+// formatFeeHistogram renders a text histogram of fees using evenly sized buckets
+func formatFeeHistogram(fees []*big.Int, buckets int) string {
+	if len(fees) == 0 {
+		return "  no transactions\n"
+	}
+
+	sorted := make([]*big.Int, len(fees))
+	copy(sorted, fees)
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a].Cmp(sorted[b]) < 0 })
+
+	minFee, maxFee := sorted[0], sorted[len(sorted)-1]
+	span := new(big.Int).Sub(maxFee, minFee)
+	if span.Sign() == 0 {
+		return fmt.Sprintf("  %s: all %d transactions\n", formatBaseFee(minFee), len(fees))
+	}
+
+	counts := make([]int, buckets)
+	width := new(big.Int).Div(span, big.NewInt(int64(buckets)))
+	if width.Sign() == 0 {
+		width.SetInt64(1)
+	}
+	for _, fee := range sorted {
+		idx := new(big.Int).Div(new(big.Int).Sub(fee, minFee), width).Int64()
+		if idx >= int64(buckets) {
+			idx = int64(buckets) - 1
+		}
+		counts[idx]++
+	}
+
+	maxCount := 0
+	for _, c := range counts {
+		maxCount = max(maxCount, c)
+	}
+
+	const barWidth = 20
+	var sb strings.Builder
+	for i, c := range counts {
+		lower := new(big.Int).Add(minFee, new(big.Int).Mul(width, big.NewInt(int64(i))))
+		bar := strings.Repeat("█", c*barWidth/maxCount)
+		if c > 0 && bar == "" {
+			bar = "▏"
+		}
+		fmt.Fprintf(&sb, "  ≥%-12s %-20s %d\n", formatBaseFee(lower), bar, c)
+	}
+	fmt.Fprintf(&sb, "  median %s, max %s\n", formatBaseFee(sorted[len(sorted)/2]), formatBaseFee(maxFee))
+	return sb.String()
+}
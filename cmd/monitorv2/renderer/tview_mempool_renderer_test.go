@@ -0,0 +1,245 @@
+package renderer
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/0xPolygon/polygon-cli/chainstore"
+	"github.com/0xPolygon/polygon-cli/rpctypes"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	senderA = "0x000000000000000000000000000000000000000a"
+	senderB = "0x000000000000000000000000000000000000000b"
+	senderC = "0x000000000000000000000000000000000000000c"
+)
+
+// poolTxs builds a txpool_content sender entry with legacy transactions at the
+// given nonces, priced at the nonce in gwei
+func poolTxs(nonces ...uint64) map[string]rpctypes.RawTransactionResponse {
+	txs := make(map[string]rpctypes.RawTransactionResponse, len(nonces))
+	for _, nonce := range nonces {
+		txs[strconv.FormatUint(nonce, 10)] = rpctypes.RawTransactionResponse{
+			Nonce:    rpctypes.RawQuantityResponse(fmt.Sprintf("0x%x", nonce)),
+			GasPrice: rpctypes.RawQuantityResponse(fmt.Sprintf("0x%x", (nonce+1)*1_000_000_000)),
+		}
+	}
+	return txs
+}
+
+func TestBuildMempoolView(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		for _, content := range []*chainstore.TxPoolContent{nil, {}} {
+			view := buildMempoolView(content, "txpool_content")
+			require.Equal(t, "txpool_content", view.source)
+			require.Empty(t, view.senders)
+			require.Zero(t, view.pending+view.queued)
+			require.Contains(t, formatMempoolStats(view), "no transactions")
+		}
+	})
+
+	t.Run("gaps", func(t *testing.T) {
+		content := &chainstore.TxPoolContent{
+			Pending: map[string]map[string]rpctypes.RawTransactionResponse{
+				senderA: poolTxs(0, 1),
+				senderB: poolTxs(5),
+			},
+			Queued: map[string]map[string]rpctypes.RawTransactionResponse{
+				senderA: poolTxs(4),
+				senderC: poolTxs(7),
+			},
+		}
+		view := buildMempoolView(content, "txpool_content")
+		require.Equal(t, 3, view.pending)
+		require.Equal(t, 2, view.queued)
+		require.Len(t, view.maxFees, 5)
+		require.Len(t, view.tipFees, 5)
+
+		// Senders with gaps come first, the busiest first
+		require.Len(t, view.senders, 3)
+		a, c, b := view.senders[0], view.senders[1], view.senders[2]
+		require.Equal(t, common.HexToAddress(senderA), a.address)
+		require.Equal(t, common.HexToAddress(senderC), c.address)
+		require.Equal(t, common.HexToAddress(senderB), b.address)
+
+		require.Equal(t, 2, a.pending)
+		require.Equal(t, 1, a.queued)
+		require.Equal(t, 1, a.gaps)
+		var nonces []uint64
+		for _, tx := range a.txs {
+			nonces = append(nonces, tx.tx.Nonce())
+			require.Equal(t, common.HexToAddress(senderA), tx.tx.From())
+		}
+		require.Equal(t, []uint64{0, 1, 4}, nonces)
+		require.False(t, a.txs[1].gapAt)
+		require.True(t, a.txs[2].gapAt)
+		require.Equal(t, uint64(2), a.txs[2].gapLen)
+
+		// A queued transaction with nothing ahead waits on an unseen nonce
+		require.Equal(t, 1, c.gaps)
+		require.True(t, c.txs[0].gapAt)
+		require.Zero(t, b.gaps)
+	})
+
+	t.Run("dynamic fees", func(t *testing.T) {
+		content := &chainstore.TxPoolContent{
+			Pending: map[string]map[string]rpctypes.RawTransactionResponse{
+				senderA: {"0": {
+					Type:                 "0x2",
+					Nonce:                "0x0",
+					MaxFeePerGas:         "0x64",
+					MaxPriorityFeePerGas: "0x2",
+				}},
+			},
+		}
+		view := buildMempoolView(content, "txpool_content")
+		require.Equal(t, []*big.Int{big.NewInt(100)}, view.maxFees)
+		require.Equal(t, []*big.Int{big.NewInt(2)}, view.tipFees)
+	})
+}
+
+func TestParseInspectSummary(t *testing.T) {
+	tests := []struct {
+		name    string
+		nonce   string
+		summary string
+		want    rpctypes.RawTransactionResponse
+		wantErr bool
+	}{
+		{
+			name:    "transfer",
+			nonce:   "3",
+			summary: "0x000000000000000000000000000000000000dEaD: 1000 wei + 21000 gas × 2000000000 wei",
+			want: rpctypes.RawTransactionResponse{
+				From:     rpctypes.RawData20Response(senderA),
+				To:       "0x000000000000000000000000000000000000dEaD",
+				Nonce:    "0x3",
+				Value:    "0x3e8",
+				Gas:      "0x5208",
+				GasPrice: "0x77359400",
+			},
+		},
+		{
+			name:    "contract creation",
+			nonce:   "0",
+			summary: "contract creation: 0 wei + 53000 gas × 1 wei",
+			want: rpctypes.RawTransactionResponse{
+				From:     rpctypes.RawData20Response(senderA),
+				Nonce:    "0x0",
+				Value:    "0x0",
+				Gas:      "0xcf08",
+				GasPrice: "0x1",
+			},
+		},
+		{
+			name:    "value larger than uint64",
+			nonce:   "1",
+			summary: "0x000000000000000000000000000000000000dEaD: 100000000000000000000 wei + 21000 gas × 1 wei",
+			want: rpctypes.RawTransactionResponse{
+				From:     rpctypes.RawData20Response(senderA),
+				To:       "0x000000000000000000000000000000000000dEaD",
+				Nonce:    "0x1",
+				Value:    "0x56bc75e2d63100000",
+				Gas:      "0x5208",
+				GasPrice: "0x1",
+			},
+		},
+		{name: "missing separator", nonce: "0", summary: "0xdead 1 wei + 21000 gas × 1 wei", wantErr: true},
+		{name: "missing gas", nonce: "0", summary: "0xdead: 1 wei + gas × 1 wei", wantErr: true},
+		{name: "truncated", nonce: "0", summary: "0xdead: 1 wei +", wantErr: true},
+		{name: "invalid value", nonce: "0", summary: "0xdead: 1e18 wei + 21000 gas × 1 wei", wantErr: true},
+		{name: "invalid gas price", nonce: "0", summary: "0xdead: 1 wei + 21000 gas × 0x1 wei", wantErr: true},
+		{name: "invalid nonce", nonce: "0x1", summary: "0xdead: 1 wei + 21000 gas × 1 wei", wantErr: true},
+		{name: "empty", nonce: "0", summary: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseInspectSummary(senderA, tt.nonce, tt.summary)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestInspectToContentSkipsMalformed(t *testing.T) {
+	content := inspectToContent(&chainstore.TxPoolInspect{
+		Pending: map[string]map[string]string{
+			senderA: {
+				"0": "0x000000000000000000000000000000000000dEaD: 1 wei + 21000 gas × 1 wei",
+				"1": "garbage",
+			},
+		},
+	})
+	require.Len(t, content.Pending[senderA], 1)
+	require.Contains(t, content.Pending[senderA], "0")
+	require.Empty(t, content.Queued)
+	require.Nil(t, inspectToContent(nil))
+}
+
+// histogramCounts returns the transaction count of each bucket line
+func histogramCounts(t *testing.T, histogram string) []int {
+	t.Helper()
+	var counts []int
+	for _, line := range strings.Split(strings.TrimSpace(histogram), "\n") {
+		if !strings.Contains(line, "≥") {
+			continue
+		}
+		fields := strings.Fields(line)
+		count, err := strconv.Atoi(fields[len(fields)-1])
+		require.NoError(t, err)
+		counts = append(counts, count)
+	}
+	return counts
+}
+
+func TestFormatFeeHistogram(t *testing.T) {
+	fees := func(values ...int64) []*big.Int {
+		result := make([]*big.Int, 0, len(values))
+		for _, v := range values {
+			result = append(result, big.NewInt(v))
+		}
+		return result
+	}
+
+	tests := []struct {
+		name    string
+		fees    []*big.Int
+		buckets int
+		want    []int
+		wantTxt string
+	}{
+		{name: "empty", fees: nil, buckets: 4, wantTxt: "  no transactions\n"},
+		{name: "same fee", fees: fees(7, 7, 7), buckets: 4, wantTxt: "  7 wei: all 3 transactions\n"},
+		{name: "single bucket", fees: fees(1, 5, 9), buckets: 1, want: []int{3}},
+		{name: "even", fees: fees(0, 10, 20, 30, 40), buckets: 4, want: []int{1, 1, 1, 2}},
+		{name: "span smaller than buckets", fees: fees(1, 2, 3), buckets: 10, want: []int{1, 1, 1, 0, 0, 0, 0, 0, 0, 0}},
+		{name: "unsorted", fees: fees(40, 0, 0, 0), buckets: 2, want: []int{3, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formatFeeHistogram(tt.fees, tt.buckets)
+			if tt.wantTxt != "" {
+				require.Equal(t, tt.wantTxt, got)
+				return
+			}
+			require.Equal(t, tt.want, histogramCounts(t, got))
+			require.Contains(t, got, "median")
+		})
+	}
+
+	// The input order is left alone
+	input := fees(3, 1, 2)
+	formatFeeHistogram(input, 2)
+	require.Equal(t, fees(3, 1, 2), input)
+}
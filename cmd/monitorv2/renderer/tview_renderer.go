@@ -12,6 +12,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/0xPolygon/polygon-cli/chainstore"
@@ -180,18 +181,34 @@ type TviewRenderer struct {
 	homeStatusPane   *tview.TextView // Left pane: Status information (1/3 width)
 	homeMetricsPane  *tview.Table    // Right pane: Metrics table (2/3 width)
 	homeTable        *tview.Table
-	homeTableCache   [][]string      // Cached table cell values for incremental redraw
-	homeTableRows    int             // Number of cached data rows
-	homeTableInnerW  int             // Cached inner width to detect resize-driven redraw invalidation
-	homeTableInnerH  int             // Cached inner height to detect resize-driven redraw invalidation
-	blockDetailPage  *tview.Flex     // Changed to Flex for side-by-side layout
-	blockDetailLeft  *tview.Table    // Left pane: Transaction table
-	blockDetailRight *tview.TextView // Right pane: Raw JSON
-	txDetailPage     *tview.Flex     // Transaction detail with human-readable left, stacked JSON right
-	txDetailLeft     *tview.TextView // Left pane: Human-readable transaction properties
-	txDetailRight    *tview.Flex     // Right pane: Container for stacked JSON views
-	txDetailTxJSON   *tview.TextView // Top right: Transaction JSON
-	txDetailRcptJSON *tview.TextView // Bottom right: Receipt JSON
+	homeTableCache   [][]string                 // Cached table cell values for incremental redraw
+	homeTableRows    int                        // Number of cached data rows
+	homeTableInnerW  int                        // Cached inner width to detect resize-driven redraw invalidation
+	homeTableInnerH  int                        // Cached inner height to detect resize-driven redraw invalidation
+	blockDetailPage  *tview.Flex                // Changed to Flex for side-by-side layout
+	blockDetailLeft  *tview.Table               // Left pane: Transaction table
+	blockDetailRight *tview.TextView            // Right pane: Raw JSON
+	txDetailPage     *tview.Flex                // Transaction detail with human-readable left, stacked JSON right
+	txDetailLeft     *tview.TextView            // Left pane: Human-readable transaction properties
+	txDetailRight    *tview.Flex                // Right pane: Container for stacked JSON views
+	txDetailTxJSON   *tview.TextView            // Top right: Transaction JSON
+	txDetailRcptJSON *tview.TextView            // Bottom right: Receipt JSON
+	txDetailParent   string                     // Page to return to when leaving the transaction detail
+	txDetailTx       rpctypes.PolyTransaction   // Transaction shown on the detail page
+	tracePage        *tview.Flex                // Transaction trace with tab bar and tabbed panes
+	traceTabs        *tview.TextView            // Tab bar showing the active trace view
+	tracePanes       *tview.Pages               // Call tree and state diff tabs
+	traceTree        *tview.TreeView            // Collapsible callTracer call tree
+	traceState       *tview.TextView            // prestateTracer state diff
+	mempoolPage      *tview.Flex                // Mempool explorer with grouped txs left, fee stats right
+	mempoolTable     *tview.Table               // Left pane: Transactions grouped by sender
+	mempoolStats     *tview.TextView            // Right pane: Summary and fee histograms
+	mempoolRows      []rpctypes.PolyTransaction // Transaction per table row (nil for sender rows)
+	mempoolLoading   atomic.Bool                // Prevents overlapping txpool fetches
+	reorgPage        *tview.Flex                // Reorg history with reorg table left, details right
+	reorgTable       *tview.Table               // Left pane: Detected reorgs, newest first
+	reorgDetail      *tview.TextView            // Right pane: Summary and selected reorg details
+	reorgHistory     []indexer.ReorgEvent       // Reorgs shown in the table, oldest first
 	infoPage         *tview.TextView
 	helpPage         *tview.TextView
 
@@ -279,6 +296,15 @@ func (t *TviewRenderer) createPages() {
 	// Create Transaction Detail page
 	t.createTransactionDetailPage()
 
+	// Create Transaction Trace page
+	t.createTracePage()
+
+	// Create Mempool page
+	t.createMempoolPage()
+
+	// Create Reorg History page
+	t.createReorgPage()
+
 	// Create Info page
 	t.createInfoPage()
 
@@ -295,6 +321,9 @@ func (t *TviewRenderer) createPages() {
 	t.pages.AddPage("home", t.homePage, true, true)
 	t.pages.AddPage("block-detail", t.blockDetailPage, true, false)
 	t.pages.AddPage("tx-detail", t.txDetailPage, true, false)
+	t.pages.AddPage("tx-trace", t.tracePage, true, false)
+	t.pages.AddPage("mempool", t.mempoolPage, true, false)
+	t.pages.AddPage("reorgs", t.reorgPage, true, false)
 	t.pages.AddPage("info", t.infoPage, true, false)
 	t.pages.AddPage("help", t.helpPage, true, false)
 	t.pages.AddPage("quit", t.quitModal, true, false)
@@ -328,9 +357,14 @@ func (t *TviewRenderer) createHelpPage() {
 q - Quit (with confirmation)
 h - Show this help page
 i - Show information page
+m - Show mempool explorer (requires txpool_content or txpool_inspect)
+o - Show reorg history
 / or s - Open search modal
 Esc - Go back to home page
 Enter - View block details (on home page)
+Enter - View transaction details (on mempool page)
+t - View call trace and state diff (on transaction page, requires debug_traceTransaction)
+Tab - Switch between call tree and state diff (on trace page)
 
 Navigation:
 ↑↓ - Scroll through blocks
@@ -427,6 +461,12 @@ func (t *TviewRenderer) setupKeyboardShortcuts() {
 		case 'i', 'I':
 			t.pages.SwitchToPage("info")
 			return nil
+		case 'm', 'M':
+			t.showMempool()
+			return nil
+		case 'o', 'O':
+			t.showReorgs()
+			return nil
 		case '/', 's', 'S':
 			t.showModal("search")
 			return nil
@@ -436,8 +476,17 @@ func (t *TviewRenderer) setupKeyboardShortcuts() {
 		if event.Key() == tcell.KeyEscape {
 			currentPage, _ = t.pages.GetFrontPage()
 			switch currentPage {
+			case "tx-trace":
+				// From the trace, go back to the transaction it belongs to
+				t.pages.SwitchToPage("tx-detail")
+				t.app.SetFocus(t.txDetailLeft)
 			case "tx-detail":
-				// From transaction detail, go back to block detail
+				// From transaction detail, go back to where it was opened from
+				if t.txDetailParent == "mempool" {
+					t.pages.SwitchToPage("mempool")
+					t.app.SetFocus(t.mempoolTable)
+					break
+				}
 				t.pages.SwitchToPage("block-detail")
 				if t.blockDetailLeft != nil {
 					t.app.SetFocus(t.blockDetailLeft)
@@ -534,6 +583,11 @@ func (t *TviewRenderer) setupKeyboardShortcuts() {
 				}
 				return nil
 			}
+		case "mempool":
+			if event.Key() == tcell.KeyEnter {
+				t.showSelectedMempoolTransaction()
+				return nil
+			}
 		case "tx-detail":
 			switch event.Key() {
 			case tcell.KeyTab:
@@ -549,6 +603,15 @@ func (t *TviewRenderer) setupKeyboardShortcuts() {
 				}
 				return nil
 			}
+			if event.Rune() == 't' || event.Rune() == 'T' {
+				t.showTransactionTrace()
+				return nil
+			}
+		case "tx-trace":
+			if event.Key() == tcell.KeyTab {
+				t.toggleTraceTab()
+				return nil
+			}
 		}
 
 		return event
@@ -609,6 +672,12 @@ func (t *TviewRenderer) Start(ctx context.Context) error {
 	// Start periodic network info updates
 	go t.updateNetworkInfo(ctx)
 
+	// Start consuming reorgs in a separate goroutine
+	go t.consumeReorgs(ctx)
+
+	// Start periodic mempool refreshes (only fetches while the page is visible)
+	go t.refreshMempool(ctx)
+
 	// Table selection is handled automatically by view state logic
 
 	// Start the TUI application
@@ -1536,6 +1605,22 @@ func (t *TviewRenderer) hideModal() {
 		if t.txDetailLeft != nil {
 			t.app.SetFocus(t.txDetailLeft)
 		}
+	case "tx-trace":
+		if t.tracePanes != nil {
+			if name, _ := t.tracePanes.GetFrontPage(); name == "state" {
+				t.app.SetFocus(t.traceState)
+			} else {
+				t.app.SetFocus(t.traceTree)
+			}
+		}
+	case "mempool":
+		if t.mempoolTable != nil {
+			t.app.SetFocus(t.mempoolTable)
+		}
+	case "reorgs":
+		if t.reorgTable != nil {
+			t.app.SetFocus(t.reorgTable)
+		}
 	case "info":
 		if t.infoPage != nil {
 			t.app.SetFocus(t.infoPage)
@@ -0,0 +1,254 @@
+package renderer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/0xPolygon/polygon-cli/indexer"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/rs/zerolog/log"
+)
+
+// Maximum number of orphaned transaction hashes listed in the reorg details
+const maxReorgDetailTxs = 50
+
+// createReorgPage creates the reorg history page with a table of detected
+// reorgs on the left and the details of the selected reorg on the right
+func (t *TviewRenderer) createReorgPage() {
+	t.reorgTable = tview.NewTable().
+		SetBorders(false).
+		SetSelectable(true, false).
+		SetFixed(1, 0).
+		SetSeparator(' ')
+	t.reorgTable.SetBorder(true).SetTitle(" Reorgs ")
+
+	headers := []string{"TIME", "DEPTH", "ANCESTOR", "OLD TIP", "NEW TIP", "ORPHANED TXS"}
+	for col, header := range headers {
+		t.reorgTable.SetCell(0, col, tview.NewTableCell(header).
+			SetTextColor(tview.Styles.PrimaryTextColor).
+			SetExpansion(1).
+			SetSelectable(false).
+			SetAttributes(tcell.AttrBold))
+	}
+
+	t.reorgTable.SetSelectionChangedFunc(func(row, column int) {
+		t.updateReorgDetail(row)
+	})
+
+	t.reorgDetail = tview.NewTextView().
+		SetDynamicColors(true).
+		SetWordWrap(true)
+	t.reorgDetail.SetBorder(true).SetTitle(" Reorg Details ")
+	t.reorgDetail.SetText("No reorgs detected yet")
+
+	t.reorgPage = tview.NewFlex().
+		SetDirection(tview.FlexColumn).
+		AddItem(t.reorgTable, 0, 3, true).
+		AddItem(t.reorgDetail, 0, 2, false)
+}
+
+// showReorgs switches to the reorg history page
+func (t *TviewRenderer) showReorgs() {
+	t.pages.SwitchToPage("reorgs")
+	t.app.SetFocus(t.reorgTable)
+	t.renderReorgs()
+}
+
+// consumeReorgs removes orphaned blocks from the blocks table as reorgs are
+// detected and refreshes the reorg page
+func (t *TviewRenderer) consumeReorgs(ctx context.Context) {
+	reorgChan := t.indexer.ReorgChannel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case reorg, ok := <-reorgChan:
+			if !ok {
+				log.Info().Msg("Reorg channel closed")
+				return
+			}
+
+			t.removeBlocks(reorg.OldHashes)
+
+			// UI updates must happen on the application goroutine.
+			t.app.QueueUpdateDraw(func() {
+				t.updateTable()
+				t.applyViewState()
+				t.renderReorgs()
+			})
+		}
+	}
+}
+
+// removeBlocks drops orphaned blocks from the blocks table
+func (t *TviewRenderer) removeBlocks(hashes []common.Hash) {
+	t.blocksMu.Lock()
+	defer t.blocksMu.Unlock()
+
+	orphaned := make(map[string]struct{}, len(hashes))
+	for _, hash := range hashes {
+		orphaned[hash.Hex()] = struct{}{}
+		delete(t.blocksByHash, hash.Hex())
+	}
+
+	kept := t.blocks[:0]
+	for _, block := range t.blocks {
+		if _, ok := orphaned[block.Hash().Hex()]; !ok {
+			kept = append(kept, block)
+		}
+	}
+	t.blocks = kept
+}
+
+// renderReorgs redraws the reorg table from the indexer's reorg history,
+// newest first. Must be called on the application goroutine.
+func (t *TviewRenderer) renderReorgs() {
+	reorgs := t.indexer.GetReorgs()
+	t.reorgHistory = reorgs
+
+	for row := t.reorgTable.GetRowCount() - 1; row > 0; row-- {
+		t.reorgTable.RemoveRow(row)
+	}
+
+	for idx := range reorgs {
+		reorg := reorgs[len(reorgs)-1-idx]
+		row := idx + 1
+
+		depth := fmt.Sprintf("%d", reorg.Depth)
+		if reorg.Truncated {
+			depth += "+"
+		}
+		ancestor := "unknown"
+		if reorg.CommonAncestor >= 0 {
+			ancestor = formatNumber(uint64(reorg.CommonAncestor))
+		}
+
+		color := tcell.ColorYellow
+		if reorg.Depth > 1 || reorg.Truncated {
+			color = tcell.ColorRed
+		}
+
+		cells := []string{
+			reorg.DetectedAt.Format("15:04:05"),
+			depth,
+			ancestor,
+			lastHashShort(reorg.OldHashes),
+			lastHashShort(reorg.NewHashes),
+			fmt.Sprintf("%d", len(reorg.OrphanedTxs)),
+		}
+		for col, text := range cells {
+			cell := tview.NewTableCell(text).SetExpansion(1)
+			if col == 1 {
+				cell.SetTextColor(color)
+			}
+			t.reorgTable.SetCell(row, col, cell)
+		}
+	}
+
+	t.reorgTable.SetTitle(fmt.Sprintf(" Reorgs (%d) ", len(reorgs)))
+
+	if len(reorgs) == 0 {
+		t.reorgDetail.SetText(formatReorgSummary(reorgs))
+		return
+	}
+
+	row, _ := t.reorgTable.GetSelection()
+	if row < 1 || row > len(reorgs) {
+		row = 1
+		t.reorgTable.Select(row, 0)
+	}
+	t.updateReorgDetail(row)
+}
+
+// updateReorgDetail shows the summary and the details of the reorg in the given table row
+func (t *TviewRenderer) updateReorgDetail(row int) {
+	reorgs := t.reorgHistory
+	if row < 1 || row > len(reorgs) {
+		return
+	}
+
+	reorg := reorgs[len(reorgs)-row]
+	t.reorgDetail.SetText(formatReorgSummary(reorgs) + "\n\n" + formatReorgDetail(reorg))
+	t.reorgDetail.ScrollToBeginning()
+}
+
+// formatReorgSummary formats frequency and depth statistics over all reorgs
+func formatReorgSummary(reorgs []indexer.ReorgEvent) string {
+	if len(reorgs) == 0 {
+		return "No reorgs detected yet"
+	}
+
+	maxDepth, totalDepth, orphanedTxs := 0, 0, 0
+	for _, reorg := range reorgs {
+		maxDepth = max(maxDepth, reorg.Depth)
+		totalDepth += reorg.Depth
+		orphanedTxs += len(reorg.OrphanedTxs)
+	}
+
+	lines := []string{
+		fmt.Sprintf("Reorgs: %d", len(reorgs)),
+		fmt.Sprintf("Max Depth: %d", maxDepth),
+		fmt.Sprintf("Average Depth: %.2f", float64(totalDepth)/float64(len(reorgs))),
+		fmt.Sprintf("Orphaned Txs: %d", orphanedTxs),
+	}
+
+	if len(reorgs) > 1 {
+		span := reorgs[len(reorgs)-1].DetectedAt.Sub(reorgs[0].DetectedAt)
+		if span > 0 {
+			lines = append(lines, fmt.Sprintf("Frequency: %.2f/hour", float64(len(reorgs)-1)/span.Hours()))
+		}
+	}
+	lines = append(lines, fmt.Sprintf("Last: %s ago", time.Since(reorgs[len(reorgs)-1].DetectedAt).Truncate(time.Second)))
+
+	return strings.Join(lines, "\n")
+}
+
+// formatReorgDetail formats the hashes and orphaned transactions of a reorg
+func formatReorgDetail(reorg indexer.ReorgEvent) string {
+	lines := []string{
+		fmt.Sprintf("Detected: %s", reorg.DetectedAt.Format(time.RFC3339)),
+		fmt.Sprintf("Depth: %d", reorg.Depth),
+	}
+	if reorg.CommonAncestor >= 0 {
+		lines = append(lines, fmt.Sprintf("Common Ancestor: %d", reorg.CommonAncestor))
+	} else {
+		lines = append(lines, "Common Ancestor: not found within reorg depth")
+	}
+
+	lines = append(lines, "", "Old Blocks:")
+	for idx, hash := range reorg.OldHashes {
+		lines = append(lines, fmt.Sprintf("  %d %s", reorg.FirstBlock+int64(idx), hash.Hex()))
+	}
+
+	lines = append(lines, "", "New Blocks:")
+	if len(reorg.NewHashes) == 0 {
+		lines = append(lines, "  (none yet)")
+	}
+	for idx, hash := range reorg.NewHashes {
+		lines = append(lines, fmt.Sprintf("  %d %s", reorg.FirstBlock+int64(idx), hash.Hex()))
+	}
+
+	lines = append(lines, "", fmt.Sprintf("Orphaned Transactions (%d):", len(reorg.OrphanedTxs)))
+	for idx, hash := range reorg.OrphanedTxs {
+		if idx == maxReorgDetailTxs {
+			lines = append(lines, fmt.Sprintf("  ... %d more", len(reorg.OrphanedTxs)-maxReorgDetailTxs))
+			break
+		}
+		lines = append(lines, "  "+hash.Hex())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// lastHashShort returns the truncated last hash of a list
+func lastHashShort(hashes []common.Hash) string {
+	if len(hashes) == 0 {
+		return "-"
+	}
+	return truncateHash(hashes[len(hashes)-1].Hex(), 6, 4)
+}
@@ -0,0 +1,432 @@
+package renderer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/0xPolygon/polygon-cli/chainstore"
+	"github.com/0xPolygon/polygon-cli/rpctypes"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/rs/zerolog/log"
+)
+
+// Call frames deeper than this start collapsed unless they lead to an error
+const traceExpandDepth = 2
+
+// createTracePage creates the transaction trace page with a collapsible call
+// tree tab and a state diff tab
+func (t *TviewRenderer) createTracePage() {
+	t.traceTabs = tview.NewTextView().
+		SetDynamicColors(true).
+		SetWrap(false)
+
+	t.traceTree = tview.NewTreeView().
+		SetGraphics(true).
+		SetTopLevel(0)
+	t.traceTree.SetBorder(true).SetTitle(" Call Tree ")
+	t.traceTree.SetSelectedFunc(func(node *tview.TreeNode) {
+		if len(node.GetChildren()) > 0 {
+			node.SetExpanded(!node.IsExpanded())
+		}
+	})
+
+	t.traceState = tview.NewTextView().
+		SetDynamicColors(true).
+		SetWordWrap(true)
+	t.traceState.SetBorder(true).SetTitle(" State Diff ")
+
+	t.tracePanes = tview.NewPages().
+		AddPage("calls", t.traceTree, true, true).
+		AddPage("state", t.traceState, true, false)
+
+	t.tracePage = tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(t.traceTabs, 1, 0, false).
+		AddItem(t.tracePanes, 0, 1, true)
+
+	t.setTraceTab("calls")
+}
+
+// setTraceTab shows the given trace tab ("calls" or "state") and updates the tab bar
+func (t *TviewRenderer) setTraceTab(tab string) {
+	t.tracePanes.SwitchToPage(tab)
+
+	calls, state := " Call Tree ", " State Diff "
+	if tab == "calls" {
+		calls = "[black:white]" + calls + "[-:-]"
+		t.app.SetFocus(t.traceTree)
+	} else {
+		state = "[black:white]" + state + "[-:-]"
+		t.app.SetFocus(t.traceState)
+	}
+	t.traceTabs.SetText(fmt.Sprintf("%s %s  [gray]Tab: switch view  Enter: expand/collapse  Esc: back[-]", calls, state))
+}
+
+// toggleTraceTab switches between the call tree and state diff tabs
+func (t *TviewRenderer) toggleTraceTab() {
+	if name, _ := t.tracePanes.GetFrontPage(); name == "calls" {
+		t.setTraceTab("state")
+	} else {
+		t.setTraceTab("calls")
+	}
+}
+
+// showTransactionTrace switches to the trace page for the transaction shown
+// on the detail page and loads both traces asynchronously
+func (t *TviewRenderer) showTransactionTrace() {
+	tx := t.txDetailTx
+	if tx == nil {
+		return
+	}
+
+	root := tview.NewTreeNode("Loading call trace...")
+	t.traceTree.SetRoot(root).SetCurrentNode(root)
+	t.traceTree.SetTitle(fmt.Sprintf(" Call Tree (Hash: %s) ", truncateHash(tx.Hash().Hex(), 8, 8)))
+	t.traceState.SetText("Loading state diff...")
+	t.traceState.ScrollToBeginning()
+
+	t.pages.SwitchToPage("tx-trace")
+	t.setTraceTab("calls")
+
+	if !t.indexer.IsMethodSupported("debug_traceTransaction") {
+		msg := "debug_traceTransaction is not supported by this RPC endpoint"
+		root.SetText(msg)
+		t.traceState.SetText(msg)
+		return
+	}
+
+	go t.loadCallTraceAsync(tx)
+	go t.loadStateDiffAsync(tx)
+}
+
+// loadCallTraceAsync fetches the callTracer trace and renders it as a tree
+func (t *TviewRenderer) loadCallTraceAsync(tx rpctypes.PolyTransaction) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	frame, err := t.indexer.GetCallTrace(ctx, tx.Hash())
+
+	t.app.QueueUpdateDraw(func() {
+		// Ignore results for a transaction that's no longer being viewed
+		if t.txDetailTx == nil || t.txDetailTx.Hash() != tx.Hash() {
+			return
+		}
+		if err != nil {
+			log.Debug().Err(err).Str("tx", tx.Hash().Hex()).Msg("Failed to trace transaction")
+			t.traceTree.GetRoot().SetText(fmt.Sprintf("Error tracing transaction: %s", tview.Escape(err.Error())))
+			return
+		}
+
+		root := t.buildCallTree(newCallTree(frame))
+		t.traceTree.SetRoot(root).SetCurrentNode(root)
+		t.traceTree.SetTitle(fmt.Sprintf(" Call Tree (%d frames) ", countCallFrames(frame)))
+	})
+}
+
+// loadStateDiffAsync fetches the prestateTracer diff and renders it
+func (t *TviewRenderer) loadStateDiffAsync(tx rpctypes.PolyTransaction) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	diff, err := t.indexer.GetStateDiff(ctx, tx.Hash())
+
+	var text string
+	if err != nil {
+		log.Debug().Err(err).Str("tx", tx.Hash().Hex()).Msg("Failed to trace state diff")
+		text = fmt.Sprintf("Error tracing state diff: %s", tview.Escape(err.Error()))
+	} else {
+		text = formatStateDiff(diff)
+	}
+
+	t.app.QueueUpdateDraw(func() {
+		if t.txDetailTx == nil || t.txDetailTx.Hash() != tx.Hash() {
+			return
+		}
+		t.traceState.SetText(text)
+	})
+}
+
+// callTreeEntry is a call frame with its place in the rendered call tree
+type callTreeEntry struct {
+	frame    *chainstore.CallFrame
+	depth    int
+	failed   bool // The frame itself failed
+	expanded bool // The frame starts expanded
+	children []*callTreeEntry
+}
+
+// newCallTree lays out a call frame and its children. Frames beyond
+// traceExpandDepth are collapsed unless an error lies below them, so the path
+// to a failure is visible straight away.
+func newCallTree(frame *chainstore.CallFrame) *callTreeEntry {
+	entry, _ := layoutCallFrame(frame, 0)
+	return entry
+}
+
+// layoutCallFrame lays out a frame at depth and returns whether the frame or
+// any of its descendants failed
+func layoutCallFrame(frame *chainstore.CallFrame, depth int) (*callTreeEntry, bool) {
+	entry := &callTreeEntry{
+		frame:  frame,
+		depth:  depth,
+		failed: frame.Error != "",
+	}
+
+	childFailed := false
+	for i := range frame.Calls {
+		child, failedBelow := layoutCallFrame(&frame.Calls[i], depth+1)
+		entry.children = append(entry.children, child)
+		childFailed = childFailed || failedBelow
+	}
+
+	entry.expanded = depth < traceExpandDepth || childFailed
+	return entry, entry.failed || childFailed
+}
+
+// buildCallTree converts a laid out call tree into tree nodes
+func (t *TviewRenderer) buildCallTree(entry *callTreeEntry) *tview.TreeNode {
+	node := tview.NewTreeNode(t.formatCallFrame(entry.frame)).
+		SetReference(entry.frame).
+		SetSelectable(true).
+		SetExpanded(entry.expanded)
+	if entry.failed {
+		node.SetColor(tcell.ColorRed)
+	}
+	for _, child := range entry.children {
+		node.AddChild(t.buildCallTree(child))
+	}
+	return node
+}
+
+// formatCallFrame formats a call frame as a single tree label
+func (t *TviewRenderer) formatCallFrame(frame *chainstore.CallFrame) string {
+	parts := []string{
+		frame.Type,
+		fmt.Sprintf("%s → %s", truncateHash(frame.From.Hex(), 6, 4), truncateHash(frame.To.Hex(), 6, 4)),
+	}
+
+	if method := t.callFrameMethod(frame); method != "" {
+		parts = append(parts, method)
+	}
+
+	if frame.Value != nil && frame.Value.ToInt().Sign() > 0 {
+		parts = append(parts, fmt.Sprintf("[yellow]%s ETH[-]", weiToEther(frame.Value.ToInt())))
+	}
+
+	parts = append(parts, fmt.Sprintf("gas %s/%s", formatNumber(uint64(frame.GasUsed)), formatNumber(uint64(frame.Gas))))
+
+	if frame.Error != "" {
+		parts = append(parts, fmt.Sprintf("✗ %s", tview.Escape(t.callFrameError(frame))))
+	}
+
+	return strings.Join(parts, "  ")
+}
+
+// callFrameMethod names the method a frame called, using the local ABI
+// registry where possible and the raw selector otherwise
+func (t *TviewRenderer) callFrameMethod(frame *chainstore.CallFrame) string {
+	if strings.HasPrefix(frame.Type, "CREATE") || len(frame.Input) < 4 {
+		return ""
+	}
+
+	if t.indexer != nil {
+		if decoded, err := t.indexer.DecodeCalldata(frame.Input); err == nil {
+			return decoded.Name
+		}
+	}
+	return fmt.Sprintf("0x%x", []byte(frame.Input[:4]))
+}
+
+// callFrameError formats the error of a failed frame including the revert
+// reason when one is available
+func (t *TviewRenderer) callFrameError(frame *chainstore.CallFrame) string {
+	if frame.RevertReason != "" {
+		return fmt.Sprintf("%s: %s", frame.Error, frame.RevertReason)
+	}
+
+	if t.indexer != nil && len(frame.Output) >= 4 {
+		if decoded, err := t.indexer.DecodeRevert(frame.Output); err == nil {
+			args := make([]string, 0, len(decoded.Args))
+			for _, arg := range decoded.Args {
+				args = append(args, chainstore.FormatDecodedValue(arg.Value))
+			}
+			return fmt.Sprintf("%s: %s(%s)", frame.Error, decoded.Name, strings.Join(args, ", "))
+		}
+	}
+	return frame.Error
+}
+
+// countCallFrames returns the number of frames in a call tree
+func countCallFrames(frame *chainstore.CallFrame) int {
+	count := 1
+	for i := range frame.Calls {
+		count += countCallFrames(&frame.Calls[i])
+	}
+	return count
+}
+
+// accountDiff is the change a transaction made to one account
+type accountDiff struct {
+	address    common.Address
+	created    bool
+	deleted    bool
+	balance    bool // The balance changed, from oldBalance to newBalance
+	oldBalance *big.Int
+	newBalance *big.Int
+	nonce      bool // The nonce changed, from oldNonce to newNonce
+	oldNonce   uint64
+	newNonce   uint64
+	code       bool // The code changed, from oldCode to newCode bytes
+	oldCode    int
+	newCode    int
+	storage    []slotDiff
+}
+
+// slotDiff is the change of one storage slot
+type slotDiff struct {
+	slot     common.Hash
+	oldValue common.Hash
+	newValue common.Hash
+}
+
+// diffAccounts lists the account changes of a prestateTracer diff, sorted by
+// address. Post omits unchanged fields, so only fields present in it (or every
+// field of a deleted account) are reported as changed.
+func diffAccounts(diff *chainstore.StateDiff) []accountDiff {
+	if diff == nil {
+		return nil
+	}
+
+	addresses := make(map[common.Address]struct{}, len(diff.Pre)+len(diff.Post))
+	for addr := range diff.Pre {
+		addresses[addr] = struct{}{}
+	}
+	for addr := range diff.Post {
+		addresses[addr] = struct{}{}
+	}
+
+	sorted := make([]common.Address, 0, len(addresses))
+	for addr := range addresses {
+		sorted = append(sorted, addr)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Cmp(sorted[j]) < 0
+	})
+
+	accounts := make([]accountDiff, 0, len(sorted))
+	for _, addr := range sorted {
+		pre, hasPre := diff.Pre[addr]
+		post, hasPost := diff.Post[addr]
+		if pre == nil {
+			pre = &chainstore.AccountState{}
+		}
+		if post == nil {
+			post = &chainstore.AccountState{}
+		}
+
+		accounts = append(accounts, accountDiff{
+			address:    addr,
+			created:    !hasPre,
+			deleted:    !hasPost,
+			balance:    post.Balance != nil || !hasPost,
+			oldBalance: bigOrZero(pre.Balance.ToInt()),
+			newBalance: bigOrZero(post.Balance.ToInt()),
+			nonce:      post.Nonce != 0 && post.Nonce != pre.Nonce,
+			oldNonce:   pre.Nonce,
+			newNonce:   post.Nonce,
+			code:       len(post.Code) > 0 || (!hasPost && len(pre.Code) > 0),
+			oldCode:    len(pre.Code),
+			newCode:    len(post.Code),
+			storage:    diffStorage(pre.Storage, post.Storage),
+		})
+	}
+	return accounts
+}
+
+// diffStorage lists changed storage slots, sorted by slot. Slots missing from
+// post were cleared, since prestateTracer omits zero values.
+func diffStorage(pre, post map[common.Hash]common.Hash) []slotDiff {
+	slots := make(map[common.Hash]struct{}, len(pre)+len(post))
+	for slot := range pre {
+		slots[slot] = struct{}{}
+	}
+	for slot := range post {
+		slots[slot] = struct{}{}
+	}
+
+	sorted := make([]slotDiff, 0, len(slots))
+	for slot := range slots {
+		sorted = append(sorted, slotDiff{slot: slot, oldValue: pre[slot], newValue: post[slot]})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].slot.Cmp(sorted[j].slot) < 0
+	})
+	return sorted
+}
+
+// formatStateDiff formats a prestateTracer diff as a per-account list of
+// balance, nonce, code and storage changes
+func formatStateDiff(diff *chainstore.StateDiff) string {
+	accounts := diffAccounts(diff)
+	if len(accounts) == 0 {
+		return "No state changes"
+	}
+
+	var lines []string
+	lines = append(lines, fmt.Sprintf("%d account(s) changed", len(accounts)), "")
+
+	for _, account := range accounts {
+		status := ""
+		switch {
+		case account.created:
+			status = " [green](created)[-]"
+		case account.deleted:
+			status = " [red](deleted)[-]"
+		}
+		lines = append(lines, fmt.Sprintf("[white::b]%s[-::-]%s", account.address.Hex(), status))
+
+		if account.balance {
+			delta := new(big.Int).Sub(account.newBalance, account.oldBalance)
+			sign := ""
+			if delta.Sign() > 0 {
+				sign = "+"
+			}
+			lines = append(lines, fmt.Sprintf("  Balance: %s → %s ETH (%s%s)",
+				weiToEther(account.oldBalance), weiToEther(account.newBalance), sign, weiToEther(delta)))
+		}
+
+		if account.nonce {
+			lines = append(lines, fmt.Sprintf("  Nonce: %d → %d", account.oldNonce, account.newNonce))
+		}
+
+		if account.code {
+			lines = append(lines, fmt.Sprintf("  Code: %s → %s bytes",
+				formatNumber(uint64(account.oldCode)), formatNumber(uint64(account.newCode))))
+		}
+
+		if len(account.storage) > 0 {
+			lines = append(lines, fmt.Sprintf("  Storage (%d slot(s)):", len(account.storage)))
+			for _, slot := range account.storage {
+				lines = append(lines, fmt.Sprintf("    %s", slot.slot.Hex()))
+				lines = append(lines, fmt.Sprintf("      %s → %s", slot.oldValue.Hex(), slot.newValue.Hex()))
+			}
+		}
+		lines = append(lines, "")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// bigOrZero returns n, or zero if n is nil
+func bigOrZero(n *big.Int) *big.Int {
+	if n == nil {
+		return new(big.Int)
+	}
+	return n
+}
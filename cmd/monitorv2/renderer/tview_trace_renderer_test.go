@@ -0,0 +1,195 @@
+package renderer
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/0xPolygon/polygon-cli/chainstore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+// layout is the shape of a call tree entry: whether it failed, starts
+// expanded, and its children
+type layout struct {
+	failed   bool
+	expanded bool
+	children []layout
+}
+
+func layoutOf(entry *callTreeEntry) layout {
+	l := layout{failed: entry.failed, expanded: entry.expanded}
+	for _, child := range entry.children {
+		l.children = append(l.children, layoutOf(child))
+	}
+	return l
+}
+
+// nest returns a chain of depth calls, the innermost failing if failInner
+func nest(depth int, failInner bool) chainstore.CallFrame {
+	frame := chainstore.CallFrame{Type: "CALL"}
+	if depth == 1 {
+		if failInner {
+			frame.Error = "execution reverted"
+		}
+		return frame
+	}
+	frame.Calls = []chainstore.CallFrame{nest(depth-1, failInner)}
+	return frame
+}
+
+func TestNewCallTree(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame chainstore.CallFrame
+		want  layout
+	}{
+		{
+			name:  "single frame",
+			frame: chainstore.CallFrame{Type: "CALL"},
+			want:  layout{expanded: true},
+		},
+		{
+			name:  "deep frames collapse",
+			frame: nest(4, false),
+			want: layout{expanded: true, children: []layout{
+				{expanded: true, children: []layout{
+					{children: []layout{{}}},
+				}},
+			}},
+		},
+		{
+			name:  "path to a deep revert stays expanded",
+			frame: nest(4, true),
+			want: layout{expanded: true, children: []layout{
+				{expanded: true, children: []layout{
+					{expanded: true, children: []layout{{failed: true}}},
+				}},
+			}},
+		},
+		{
+			name: "reverted sibling",
+			frame: chainstore.CallFrame{Type: "CALL", Error: "execution reverted", Calls: []chainstore.CallFrame{
+				{Type: "STATICCALL"},
+				{Type: "CALL", Calls: []chainstore.CallFrame{
+					nest(2, false),
+					nest(2, true),
+				}},
+			}},
+			want: layout{failed: true, expanded: true, children: []layout{
+				{expanded: true},
+				{expanded: true, children: []layout{
+					{children: []layout{{}}},
+					{expanded: true, children: []layout{{failed: true}}},
+				}},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := newCallTree(&tt.frame)
+			require.Equal(t, tt.want, layoutOf(tree))
+			require.Same(t, &tt.frame, tree.frame)
+		})
+	}
+
+	frame := nest(3, false)
+	frame.Calls = append(frame.Calls, chainstore.CallFrame{})
+	require.Equal(t, 4, countCallFrames(&frame))
+}
+
+func TestFormatCallFrame(t *testing.T) {
+	r := &TviewRenderer{}
+	frame := &chainstore.CallFrame{
+		Type:    "CALL",
+		From:    common.HexToAddress("0x1111111111111111111111111111111111111111"),
+		To:      common.HexToAddress("0x2222222222222222222222222222222222222222"),
+		Value:   (*hexutil.Big)(big.NewInt(1e18)),
+		Gas:     50000,
+		GasUsed: 21000,
+		Input:   hexutil.Bytes{0xa9, 0x05, 0x9c, 0xbb, 0x00},
+		Error:   "execution reverted",
+	}
+	label := r.formatCallFrame(frame)
+	require.Contains(t, label, "0xa9059cbb")
+	require.Contains(t, label, "1.000000 ETH")
+	require.Contains(t, label, "gas 21,000/50,000")
+	require.Contains(t, label, "✗ execution reverted")
+
+	frame.RevertReason = "insufficient balance"
+	require.Contains(t, r.formatCallFrame(frame), "✗ execution reverted: insufficient balance")
+
+	// Creations have no method
+	create := &chainstore.CallFrame{Type: "CREATE", Input: hexutil.Bytes{0x60, 0x80, 0x60, 0x40}}
+	require.NotContains(t, r.formatCallFrame(create), "0x60806040")
+}
+
+func TestDiffAccounts(t *testing.T) {
+	addrA := common.HexToAddress("0xa")
+	addrB := common.HexToAddress("0xb")
+	addrC := common.HexToAddress("0xc")
+	slot1, slot2 := common.HexToHash("0x1"), common.HexToHash("0x2")
+	balance := func(n int64) *hexutil.Big { return (*hexutil.Big)(big.NewInt(n)) }
+
+	diff := &chainstore.StateDiff{
+		Pre: map[common.Address]*chainstore.AccountState{
+			addrA: {Balance: balance(100), Nonce: 1, Storage: map[common.Hash]common.Hash{slot2: common.HexToHash("0x5"), slot1: common.HexToHash("0x6")}},
+			addrC: {Balance: balance(7), Code: hexutil.Bytes{0x60, 0x00}},
+		},
+		Post: map[common.Address]*chainstore.AccountState{
+			addrA: {Balance: balance(90), Nonce: 2, Storage: map[common.Hash]common.Hash{slot1: common.HexToHash("0x7")}},
+			addrB: {Balance: balance(10), Code: hexutil.Bytes{0x60, 0x80, 0x60}},
+		},
+	}
+
+	accounts := diffAccounts(diff)
+	require.Equal(t, []accountDiff{
+		{
+			address: addrA, balance: true, oldBalance: big.NewInt(100), newBalance: big.NewInt(90),
+			nonce: true, oldNonce: 1, newNonce: 2,
+			storage: []slotDiff{
+				{slot: slot1, oldValue: common.HexToHash("0x6"), newValue: common.HexToHash("0x7")},
+				// A slot missing from post was cleared
+				{slot: slot2, oldValue: common.HexToHash("0x5")},
+			},
+		},
+		{
+			address: addrB, created: true, balance: true, oldBalance: new(big.Int), newBalance: big.NewInt(10),
+			code: true, newCode: 3, storage: []slotDiff{},
+		},
+		{
+			address: addrC, deleted: true, balance: true, oldBalance: big.NewInt(7), newBalance: new(big.Int),
+			code: true, oldCode: 2, storage: []slotDiff{},
+		},
+	}, accounts)
+
+	text := formatStateDiff(diff)
+	require.True(t, strings.HasPrefix(text, "3 account(s) changed"))
+	require.Contains(t, text, "Balance: 0.000000 → 0.000000 ETH (-0.000000)")
+	require.Contains(t, text, "Nonce: 1 → 2")
+	require.Contains(t, text, "(created)")
+	require.Contains(t, text, "(deleted)")
+	require.Contains(t, text, "Code: 0 → 3 bytes")
+	require.Contains(t, text, "Storage (2 slot(s)):")
+}
+
+func TestDiffAccountsEmpty(t *testing.T) {
+	for _, diff := range []*chainstore.StateDiff{nil, {}, {Pre: map[common.Address]*chainstore.AccountState{}}} {
+		require.Empty(t, diffAccounts(diff))
+		require.Equal(t, "No state changes", formatStateDiff(diff))
+	}
+
+	// An account touched without changes to its fields
+	addr := common.HexToAddress("0xa")
+	diff := &chainstore.StateDiff{
+		Pre:  map[common.Address]*chainstore.AccountState{addr: {Nonce: 3}},
+		Post: map[common.Address]*chainstore.AccountState{addr: {}},
+	}
+	accounts := diffAccounts(diff)
+	require.Len(t, accounts, 1)
+	require.False(t, accounts[0].balance || accounts[0].nonce || accounts[0].code)
+	require.Empty(t, accounts[0].storage)
+}
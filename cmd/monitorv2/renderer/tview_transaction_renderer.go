@@ -10,7 +10,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/0xPolygon/polygon-cli/chainstore"
 	"github.com/0xPolygon/polygon-cli/rpctypes"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/rivo/tview"
 	"github.com/rs/zerolog/log"
 )
@@ -61,8 +63,16 @@ func (t *TviewRenderer) showTransactionDetail(tx rpctypes.PolyTransaction, txInd
 		Int("txIndex", txIndex).
 		Msg("showTransactionDetail called")
 
+	// Transactions opened from a block return to the block detail page
+	t.txDetailParent = "block-detail"
+	t.txDetailTx = tx
+
 	// Update pane titles to reflect the transaction content
-	t.txDetailLeft.SetTitle(fmt.Sprintf(" Transaction Details (Index: %d) ", txIndex))
+	if txIndex < 0 {
+		t.txDetailLeft.SetTitle(" Transaction Details (Pending) ")
+	} else {
+		t.txDetailLeft.SetTitle(fmt.Sprintf(" Transaction Details (Index: %d) ", txIndex))
+	}
 	t.txDetailTxJSON.SetTitle(fmt.Sprintf(" Transaction JSON (Hash: %s) ", truncateHash(tx.Hash().Hex(), 8, 8)))
 	t.txDetailRcptJSON.SetTitle(" Receipt JSON ")
 
@@ -88,14 +98,29 @@ func (t *TviewRenderer) showTransactionDetail(tx rpctypes.PolyTransaction, txInd
 	go t.loadReceiptJSONAsync(tx)
 }
 
+// formatTransactionLocation returns the index, hash and block lines of the
+// transaction details. A negative index denotes a pending txpool transaction.
+func formatTransactionLocation(tx rpctypes.PolyTransaction, txIndex int) []string {
+	if txIndex < 0 {
+		return []string{
+			"Transaction Index: [Pending]",
+			fmt.Sprintf("Hash: %s", tx.Hash().Hex()),
+			"Block Number: [Pending]",
+		}
+	}
+	return []string{
+		fmt.Sprintf("Transaction Index: %d", txIndex),
+		fmt.Sprintf("Hash: %s", tx.Hash().Hex()),
+		fmt.Sprintf("Block Number: %s", tx.BlockNumber().String()),
+	}
+}
+
 // createBasicTransactionDetails creates basic transaction details without signature lookup
 func (t *TviewRenderer) createBasicTransactionDetails(tx rpctypes.PolyTransaction, txIndex int) string {
 	var details []string
 
 	// Basic transaction information
-	details = append(details, fmt.Sprintf("Transaction Index: %d", txIndex))
-	details = append(details, fmt.Sprintf("Hash: %s", tx.Hash().Hex()))
-	details = append(details, fmt.Sprintf("Block Number: %s", tx.BlockNumber().String()))
+	details = append(details, formatTransactionLocation(tx, txIndex)...)
 	details = append(details, fmt.Sprintf("Chain ID: %d", tx.ChainID()))
 	details = append(details, "")
 
@@ -178,9 +203,7 @@ func (t *TviewRenderer) createHumanReadableTransactionDetailsSync(tx rpctypes.Po
 	var details []string
 
 	// Basic transaction information
-	details = append(details, fmt.Sprintf("Transaction Index: %d", txIndex))
-	details = append(details, fmt.Sprintf("Hash: %s", tx.Hash().Hex()))
-	details = append(details, fmt.Sprintf("Block Number: %s", tx.BlockNumber().String()))
+	details = append(details, formatTransactionLocation(tx, txIndex)...)
 	details = append(details, fmt.Sprintf("Chain ID: %d", tx.ChainID()))
 	details = append(details, "")
 
@@ -206,6 +229,7 @@ func (t *TviewRenderer) createHumanReadableTransactionDetailsSync(tx rpctypes.Po
 			methodSig := fmt.Sprintf("0x%x", tx.Data()[:4])
 			sigDetails := t.getMethodSignatureDetails(methodSig)
 			details = append(details, fmt.Sprintf("Method Signature: %s", sigDetails))
+			details = append(details, t.formatDecodedCalldata(tx.Data())...)
 		}
 		if len(tx.Data()) <= 32 {
 			details = append(details, fmt.Sprintf("Full Data: 0x%x", tx.Data()))
@@ -415,26 +439,7 @@ func (t *TviewRenderer) loadEnhancedTransactionDetailsAsync(tx rpctypes.PolyTran
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		// Fetch receipt
-		receipt, err := t.indexer.GetReceipt(ctx, tx.Hash())
-		if err != nil {
-			eventLogsChan <- "" // No event logs available
-			return
-		}
-
-		// Extract and look up event signatures from logs
-		eventSignatures := t.extractEventSignatures(receipt)
-		if len(eventSignatures) == 0 {
-			eventLogsChan <- "" // No events to process
-			return
-		}
-
-		// Look up event signature details
-		eventDetails := t.getEventSignatureDetails(eventSignatures)
-
-		// Build event logs section for display
-		eventLogsText := t.buildEventLogsText(receipt, eventDetails)
-		eventLogsChan <- eventLogsText
+		eventLogsChan <- t.buildReceiptText(ctx, tx)
 	}()
 
 	// Wait for both responses and combine them
@@ -460,6 +465,33 @@ func (t *TviewRenderer) loadEnhancedTransactionDetailsAsync(tx rpctypes.PolyTran
 	})
 }
 
+// buildReceiptText fetches the receipt of a transaction and formats its event
+// logs, or its revert reason when the transaction failed
+func (t *TviewRenderer) buildReceiptText(ctx context.Context, tx rpctypes.PolyTransaction) string {
+	// Fetch receipt
+	receipt, err := t.indexer.GetReceipt(ctx, tx.Hash())
+	if err != nil {
+		return "" // No event logs available
+	}
+
+	// Failed transactions have no logs, only a revert reason
+	if receipt.Status() == 0 {
+		return t.buildRevertText(ctx, tx)
+	}
+
+	// Extract and look up event signatures from logs
+	eventSignatures := t.extractEventSignatures(receipt)
+	if len(eventSignatures) == 0 {
+		return "" // No events to process
+	}
+
+	// Look up event signature details
+	eventDetails := t.getEventSignatureDetails(eventSignatures)
+
+	// Build event logs section for display
+	return t.buildEventLogsText(receipt, eventDetails)
+}
+
 // loadReceiptJSONAsync loads and formats receipt JSON asynchronously
 func (t *TviewRenderer) loadReceiptJSONAsync(tx rpctypes.PolyTransaction) {
 	// Create context with timeout
@@ -534,6 +566,17 @@ func (t *TviewRenderer) buildEventLogsText(receipt rpctypes.PolyReceipt, eventDe
 			}
 
 			logLines = append(logLines, logLine)
+
+			// Show named parameters when the event is in the local ABI registry
+			if t.indexer != nil {
+				topics := make([]common.Hash, len(logEntry.Topics))
+				for j := range logEntry.Topics {
+					topics[j] = logEntry.Topics[j].ToHash()
+				}
+				if decoded, err := t.indexer.DecodeLog(topics, logEntry.Data.ToBytes()); err == nil {
+					logLines = append(logLines, formatDecodedArgs(decoded, "      ")...)
+				}
+			}
 		} else {
 			// Anonymous event (no topics)
 			logLine := fmt.Sprintf("  [%d] Anonymous Event from %s", i, contractAddrShort)
@@ -548,3 +591,89 @@ func (t *TviewRenderer) buildEventLogsText(receipt rpctypes.PolyReceipt, eventDe
 
 	return strings.Join(logLines, "\n")
 }
+
+// formatDecodedCalldata returns the decoded input lines for calldata known to
+// the local ABI registry, or nothing when the method isn't known locally
+func (t *TviewRenderer) formatDecodedCalldata(data []byte) []string {
+	if t.indexer == nil {
+		return nil
+	}
+
+	decoded, err := t.indexer.DecodeCalldata(data)
+	if err != nil {
+		log.Debug().Err(err).Msg("Calldata not decoded with local ABIs")
+		return nil
+	}
+
+	lines := []string{fmt.Sprintf("Decoded Input (%s):", decoded.Contract)}
+	lines = append(lines, fmt.Sprintf("  %s", tview.Escape(decoded.Signature)))
+	return append(lines, formatDecodedArgs(decoded, "    ")...)
+}
+
+// buildRevertText replays a failed transaction and formats its revert reason
+func (t *TviewRenderer) buildRevertText(ctx context.Context, tx rpctypes.PolyTransaction) string {
+	if t.indexer == nil {
+		return ""
+	}
+
+	data, err := t.indexer.GetRevertData(ctx, tx)
+	if err != nil {
+		log.Debug().Err(err).Str("tx", tx.Hash().Hex()).Msg("Failed to get revert data")
+		return fmt.Sprintf("Revert Reason: unavailable (%s)", tview.Escape(err.Error()))
+	}
+	if len(data) == 0 {
+		return "Revert Reason: (no data)"
+	}
+
+	decoded, err := t.indexer.DecodeRevert(data)
+	if err != nil {
+		return fmt.Sprintf("Revert Reason: unknown error 0x%x", data)
+	}
+
+	lines := []string{fmt.Sprintf("Revert Reason: %s", tview.Escape(decoded.Signature))}
+	lines = append(lines, formatDecodedArgs(decoded, "  ")...)
+	return strings.Join(lines, "\n")
+}
+
+// revertSummary replays a failed transaction and returns its revert reason on
+// a single line, such as Error("insufficient balance")
+func (t *TviewRenderer) revertSummary(ctx context.Context, tx rpctypes.PolyTransaction) string {
+	if t.indexer == nil {
+		return ""
+	}
+
+	data, err := t.indexer.GetRevertData(ctx, tx)
+	if err != nil || len(data) == 0 {
+		return ""
+	}
+
+	decoded, err := t.indexer.DecodeRevert(data)
+	if err != nil {
+		return truncateHash(fmt.Sprintf("0x%x", data), 10, 4)
+	}
+
+	values := make([]string, 0, len(decoded.Args))
+	for _, arg := range decoded.Args {
+		values = append(values, chainstore.FormatDecodedValue(arg.Value))
+	}
+	return tview.Escape(fmt.Sprintf("%s(%s)", decoded.Name, strings.Join(values, ", ")))
+}
+
+// formatDecodedArgs formats decoded arguments one per line
+func formatDecodedArgs(decoded *chainstore.DecodedCall, indent string) []string {
+	lines := make([]string, 0, len(decoded.Args))
+	for _, arg := range decoded.Args {
+		name := arg.Name
+		if name == "" {
+			name = "_"
+		}
+		typ := arg.Type
+		if arg.Indexed {
+			typ += " indexed"
+		}
+		// Values may contain brackets which tview would read as color tags
+		value := tview.Escape(chainstore.FormatDecodedValue(arg.Value))
+		lines = append(lines, fmt.Sprintf("%s%s (%s): %s", indent, name, typ, value))
+	}
+	return lines
+}
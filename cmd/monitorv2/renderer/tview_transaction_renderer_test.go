@@ -0,0 +1,128 @@
+package renderer
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/0xPolygon/polygon-cli/chainstore"
+	"github.com/0xPolygon/polygon-cli/indexer"
+	"github.com/0xPolygon/polygon-cli/rpctypes"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/stretchr/testify/require"
+)
+
+// revertStore serves a failed receipt and the revert data of its replay,
+// decoded with a local ABI registry
+type revertStore struct {
+	chainstore.ChainStore
+	abis   *chainstore.ABIRegistry
+	revert []byte
+}
+
+func (s *revertStore) GetReceipt(ctx context.Context, txHash common.Hash) (rpctypes.PolyReceipt, error) {
+	return rpctypes.NewPolyReceipt(&rpctypes.RawTxReceipt{
+		TransactionHash: rpctypes.RawData32Response(txHash.Hex()),
+		Status:          "0x0",
+	}), nil
+}
+
+func (s *revertStore) GetRevertData(ctx context.Context, tx rpctypes.PolyTransaction) ([]byte, error) {
+	return s.revert, nil
+}
+
+func (s *revertStore) DecodeRevert(data []byte) (*chainstore.DecodedCall, error) {
+	return s.abis.DecodeRevert(data)
+}
+
+func TestBuildReceiptTextFailedTransaction(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(`[
+		{"type":"error","name":"Error","inputs":[{"name":"reason","type":"string"}]},
+		{"type":"error","name":"InsufficientBalance","inputs":[{"name":"available","type":"uint256"},{"name":"required","type":"uint256"}]}
+	]`))
+	require.NoError(t, err)
+	abis := chainstore.NewABIRegistry()
+	abis.Add("Vault", &parsed)
+
+	reasonData, err := parsed.Errors["Error"].Inputs.Pack("insufficient balance")
+	require.NoError(t, err)
+	reasonData = append(common.FromHex("0x08c379a0"), reasonData...)
+
+	custom := parsed.Errors["InsufficientBalance"]
+	customData, err := custom.Inputs.Pack(big.NewInt(5), big.NewInt(7))
+	require.NoError(t, err)
+	customData = append(custom.ID[:4], customData...)
+
+	tests := []struct {
+		name        string
+		revert      []byte
+		wantText    []string
+		wantSummary string
+	}{
+		{
+			name:        "error string",
+			revert:      reasonData,
+			wantText:    []string{"Revert Reason: Error(string)", `reason (string): "insufficient balance"`},
+			wantSummary: `Error("insufficient balance")`,
+		},
+		{
+			name:   "custom error",
+			revert: customData,
+			wantText: []string{
+				"Revert Reason: InsufficientBalance(uint256,uint256)",
+				"available (uint256): 5",
+				"required (uint256): 7",
+			},
+			wantSummary: "InsufficientBalance(5, 7)",
+		},
+		{
+			name:        "unknown error",
+			revert:      common.FromHex("0xdeadbeef"),
+			wantText:    []string{"Revert Reason: unknown error 0xdeadbeef"},
+			wantSummary: "0xdeadbeef",
+		},
+		{
+			name:        "no data",
+			revert:      nil,
+			wantText:    []string{"Revert Reason: (no data)"},
+			wantSummary: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &revertStore{abis: abis, revert: tt.revert}
+			r := &TviewRenderer{BaseRenderer: NewBaseRenderer(indexer.NewIndexer(store, nil))}
+			tx := rpctypes.NewPolyTransaction(&rpctypes.RawTransactionResponse{
+				Hash: "0x00000000000000000000000000000000000000000000000000000000000000aa",
+			})
+
+			text := r.buildReceiptText(context.Background(), tx)
+			for _, want := range tt.wantText {
+				require.Contains(t, text, want)
+			}
+			require.Equal(t, tt.wantSummary, r.revertSummary(context.Background(), tx))
+		})
+	}
+}
+
+func TestMarkFailedTransactionRow(t *testing.T) {
+	r := &TviewRenderer{}
+	r.createBlockDetailPage()
+	r.currentBlock = testBlock(10, 1)
+	r.blockDetailLeft.SetCell(1, 4, tview.NewTableCell("transfer (0xa9059cbb)"))
+
+	// Rows of a block that isn't shown anymore are left alone
+	r.markFailedTransactionRow(testBlock(10, 2), 0, "Error(\"x\")")
+	require.Equal(t, "transfer (0xa9059cbb)", r.blockDetailLeft.GetCell(1, 4).Text)
+
+	r.markFailedTransactionRow(testBlock(10, 1), 0, `Error("insufficient balance")`)
+	cell := r.blockDetailLeft.GetCell(1, 4)
+	require.Equal(t, `transfer (0xa9059cbb) reverted: Error("insufficient balance")`, cell.Text)
+	fg, _, _ := cell.Style.Decompose()
+	require.Equal(t, tcell.ColorRed, fg)
+}
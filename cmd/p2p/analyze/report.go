@@ -0,0 +1,434 @@
+package analyze
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// report is the result of analysing a dataset. Durations are in milliseconds
+// so the JSON output is easy to plot.
+type report struct {
+	Blocks             int                  `json:"blocks"`
+	Peers              int                  `json:"peers"`
+	Percentiles        []float64            `json:"percentiles"`
+	Propagation        []blockPropagation   `json:"propagation"`
+	PropagationSummary []propagationSummary `json:"propagationSummary"`
+	FirstAnnouncers    []announcerShare     `json:"firstAnnouncers"`
+	FirstClients       []announcerShare     `json:"firstClients"`
+	TxInclusion        txInclusion          `json:"txInclusion"`
+	LaggingPeers       []peerLag            `json:"laggingPeers"`
+	Sensors            []sensorLag          `json:"sensors"`
+}
+
+// blockPropagation is the propagation curve of one block: how long after the
+// first announcement each percentile of the announcing peers had announced it.
+type blockPropagation struct {
+	Hash      common.Hash `json:"hash"`
+	Number    uint64      `json:"number,omitempty"`
+	FirstSeen time.Time   `json:"firstSeen"`
+	FirstPeer string      `json:"firstPeer"`
+	Peers     int         `json:"peers"`
+	Curve     []float64   `json:"curveMs"`
+}
+
+// propagationSummary aggregates one percentile of the block propagation curves.
+type propagationSummary struct {
+	Percentile float64 `json:"percentile"`
+	MedianMs   float64 `json:"medianMs"`
+	P90Ms      float64 `json:"p90Ms"`
+	MaxMs      float64 `json:"maxMs"`
+}
+
+// announcerShare counts the blocks a peer or client announced first.
+type announcerShare struct {
+	Name   string  `json:"name"`
+	Client string  `json:"client,omitempty"`
+	Blocks int     `json:"blocks"`
+	Share  float64 `json:"share"`
+}
+
+// txInclusion is the distribution of the delay between a transaction's first
+// announcement and the first announcement of the block including it.
+type txInclusion struct {
+	Included       int     `json:"included"`
+	SeenAfterBlock int     `json:"seenAfterBlock"`
+	NeverAnnounced int     `json:"neverAnnounced"`
+	MedianMs       float64 `json:"medianMs"`
+	P90Ms          float64 `json:"p90Ms"`
+	P99Ms          float64 `json:"p99Ms"`
+	MaxMs          float64 `json:"maxMs"`
+}
+
+// peerLag is how far behind the first announcer a peer typically announces.
+type peerLag struct {
+	Peer        string  `json:"peer"`
+	Name        string  `json:"name,omitempty"`
+	Client      string  `json:"client"`
+	Blocks      int     `json:"blocks"`
+	FirstBlocks int     `json:"firstBlocks"`
+	MedianMs    float64 `json:"medianMs"`
+	P90Ms       float64 `json:"p90Ms"`
+}
+
+// sensorLag compares the sensors on the blocks seen by more than one of them:
+// how often each saw a block first and how far behind the first it usually was.
+type sensorLag struct {
+	Sensor      string  `json:"sensor"`
+	Blocks      int     `json:"blocks"`
+	FirstBlocks int     `json:"firstBlocks"`
+	Share       float64 `json:"share"`
+	MedianMs    float64 `json:"medianMs"`
+	P90Ms       float64 `json:"p90Ms"`
+}
+
+// analyze computes the report over a dataset.
+func analyze(d *dataset, params analyzeParams) *report {
+	r := &report{Percentiles: params.Percentiles}
+
+	firstSeen := make(map[common.Hash]time.Time, len(d.blockEvents))
+	firstByPeer := make(map[string]int)
+	lags := make(map[string][]float64)
+	curves := make([][]float64, len(params.Percentiles))
+	peers := make(map[string]struct{})
+
+	for hash, announced := range d.blockEvents {
+		times := make([]time.Time, 0, len(announced))
+		for peer, t := range announced {
+			times = append(times, t)
+			peers[peer] = struct{}{}
+		}
+		slices.SortFunc(times, func(a, b time.Time) int { return a.Compare(b) })
+		first := times[0]
+		firstSeen[hash] = first
+
+		if len(times) < params.MinPeers {
+			continue
+		}
+		r.Blocks++
+
+		// Ties for first place all count as first announcers.
+		var firstPeer string
+		for peer, t := range announced {
+			lag := ms(t.Sub(first))
+			lags[peer] = append(lags[peer], lag)
+			if lag == 0 {
+				firstByPeer[peer]++
+				if firstPeer == "" || peer < firstPeer {
+					firstPeer = peer
+				}
+			}
+		}
+
+		propagation := blockPropagation{
+			Hash:      hash,
+			Number:    d.blockNumbers[hash],
+			FirstSeen: first,
+			FirstPeer: firstPeer,
+			Peers:     len(times),
+			Curve:     make([]float64, len(params.Percentiles)),
+		}
+		for i, p := range params.Percentiles {
+			idx := int(math.Ceil(p/100*float64(len(times)))) - 1
+			idx = max(0, min(idx, len(times)-1))
+			propagation.Curve[i] = ms(times[idx].Sub(first))
+			curves[i] = append(curves[i], propagation.Curve[i])
+		}
+		r.Propagation = append(r.Propagation, propagation)
+	}
+	r.Peers = len(peers)
+
+	sort.Slice(r.Propagation, func(i, j int) bool {
+		if r.Propagation[i].Number != r.Propagation[j].Number {
+			return r.Propagation[i].Number < r.Propagation[j].Number
+		}
+		return r.Propagation[i].FirstSeen.Before(r.Propagation[j].FirstSeen)
+	})
+
+	for i, p := range params.Percentiles {
+		values := curves[i]
+		slices.Sort(values)
+		r.PropagationSummary = append(r.PropagationSummary, propagationSummary{
+			Percentile: p,
+			MedianMs:   percentile(values, 50),
+			P90Ms:      percentile(values, 90),
+			MaxMs:      percentile(values, 100),
+		})
+	}
+
+	firstByClient := make(map[string]int)
+	for peer, blocks := range firstByPeer {
+		r.FirstAnnouncers = append(r.FirstAnnouncers, announcerShare{
+			Name:   peer,
+			Client: clientName(d.peerNames[peer]),
+			Blocks: blocks,
+			Share:  share(blocks, r.Blocks),
+		})
+		firstByClient[clientName(d.peerNames[peer])] += blocks
+	}
+	for client, blocks := range firstByClient {
+		r.FirstClients = append(r.FirstClients, announcerShare{
+			Name:   client,
+			Blocks: blocks,
+			Share:  share(blocks, r.Blocks),
+		})
+	}
+	sortShares(r.FirstAnnouncers)
+	sortShares(r.FirstClients)
+
+	r.TxInclusion = analyzeTxInclusion(d, firstSeen)
+
+	for peer, values := range lags {
+		if len(values) < params.MinBlocks {
+			continue
+		}
+		slices.Sort(values)
+		lag := peerLag{
+			Peer:        peer,
+			Name:        d.peerNames[peer],
+			Client:      clientName(d.peerNames[peer]),
+			Blocks:      len(values),
+			FirstBlocks: firstByPeer[peer],
+			MedianMs:    percentile(values, 50),
+			P90Ms:       percentile(values, 90),
+		}
+		if lag.MedianMs >= ms(params.LagThreshold) {
+			r.LaggingPeers = append(r.LaggingPeers, lag)
+		}
+	}
+	sort.Slice(r.LaggingPeers, func(i, j int) bool {
+		return r.LaggingPeers[i].MedianMs > r.LaggingPeers[j].MedianMs
+	})
+
+	r.Sensors = analyzeSensors(d)
+
+	return r
+}
+
+// analyzeSensors ranks the sensors by how early they see blocks. Only blocks
+// seen by at least two sensors are compared.
+func analyzeSensors(d *dataset) []sensorLag {
+	lags := make(map[string][]float64)
+	firsts := make(map[string]int)
+	compared := 0
+
+	for _, sensors := range d.blockSensors {
+		if len(sensors) < 2 {
+			continue
+		}
+		compared++
+
+		var first time.Time
+		for _, t := range sensors {
+			if first.IsZero() || t.Before(first) {
+				first = t
+			}
+		}
+		for sensor, t := range sensors {
+			lag := ms(t.Sub(first))
+			lags[sensor] = append(lags[sensor], lag)
+			if lag == 0 {
+				firsts[sensor]++
+			}
+		}
+	}
+
+	result := make([]sensorLag, 0, len(lags))
+	for sensor, values := range lags {
+		slices.Sort(values)
+		result = append(result, sensorLag{
+			Sensor:      sensor,
+			Blocks:      len(values),
+			FirstBlocks: firsts[sensor],
+			Share:       share(firsts[sensor], compared),
+			MedianMs:    percentile(values, 50),
+			P90Ms:       percentile(values, 90),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].FirstBlocks != result[j].FirstBlocks {
+			return result[i].FirstBlocks > result[j].FirstBlocks
+		}
+		return result[i].Sensor < result[j].Sensor
+	})
+	return result
+}
+
+// analyzeTxInclusion measures the delay from each transaction's first
+// announcement to the first announcement of the earliest block including it.
+func analyzeTxInclusion(d *dataset, blockFirstSeen map[common.Hash]time.Time) txInclusion {
+	// A transaction can be in several blocks across a reorg, the earliest wins.
+	included := make(map[common.Hash]time.Time)
+	for block, txs := range d.blockTxs {
+		seen, ok := blockFirstSeen[block]
+		if !ok {
+			continue
+		}
+		for _, tx := range txs {
+			if t, ok := included[tx]; !ok || seen.Before(t) {
+				included[tx] = seen
+			}
+		}
+	}
+
+	var result txInclusion
+	delays := make([]float64, 0, len(included))
+	for tx, blockSeen := range included {
+		txSeen, ok := d.txFirstSeen[tx]
+		if !ok {
+			result.NeverAnnounced++
+			continue
+		}
+		delay := blockSeen.Sub(txSeen)
+		if delay < 0 {
+			result.SeenAfterBlock++
+			continue
+		}
+		delays = append(delays, ms(delay))
+	}
+
+	slices.Sort(delays)
+	result.Included = len(delays)
+	result.MedianMs = percentile(delays, 50)
+	result.P90Ms = percentile(delays, 90)
+	result.P99Ms = percentile(delays, 99)
+	result.MaxMs = percentile(delays, 100)
+	return result
+}
+
+// percentile returns the nearest-rank percentile of sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	return sorted[max(0, min(idx, len(sorted)-1))]
+}
+
+// clientName extracts the client from a devp2p name such as
+// "bor/v2.0.1-stable/linux-amd64/go1.23.4".
+func clientName(name string) string {
+	if name == "" {
+		return "unknown"
+	}
+	client, _, _ := strings.Cut(name, "/")
+	return strings.ToLower(client)
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func share(count, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) / float64(total)
+}
+
+func sortShares(shares []announcerShare) {
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].Blocks != shares[j].Blocks {
+			return shares[i].Blocks > shares[j].Blocks
+		}
+		return shares[i].Name < shares[j].Name
+	})
+}
+
+// writeJSON writes the full report as indented JSON.
+func (r *report) writeJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// writeText writes a human readable summary, listing at most top rows per table.
+func (r *report) writeText(w io.Writer, top int, showBlocks bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Blocks analysed: %d\nPeers: %d\n\n", r.Blocks, r.Peers)
+
+	fmt.Fprintln(tw, "PROPAGATION\tMEDIAN\tP90\tMAX")
+	for _, s := range r.PropagationSummary {
+		fmt.Fprintf(tw, "%g%% of peers\t%s\t%s\t%s\n", s.Percentile, fmtMs(s.MedianMs), fmtMs(s.P90Ms), fmtMs(s.MaxMs))
+	}
+	fmt.Fprintln(tw)
+
+	if showBlocks {
+		header := []string{"BLOCK", "HASH", "PEERS"}
+		for _, p := range r.Percentiles {
+			header = append(header, fmt.Sprintf("P%g", p))
+		}
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+		for _, b := range r.Propagation {
+			row := []string{fmt.Sprint(b.Number), b.Hash.TerminalString(), fmt.Sprint(b.Peers)}
+			for _, v := range b.Curve {
+				row = append(row, fmtMs(v))
+			}
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		fmt.Fprintln(tw)
+	}
+
+	fmt.Fprintln(tw, "FIRST ANNOUNCER CLIENT\tBLOCKS\tSHARE")
+	for i, s := range r.FirstClients {
+		if i == top {
+			break
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%.1f%%\n", s.Name, s.Blocks, s.Share*100)
+	}
+	fmt.Fprintln(tw)
+
+	fmt.Fprintln(tw, "FIRST ANNOUNCER PEER\tCLIENT\tBLOCKS\tSHARE")
+	for i, s := range r.FirstAnnouncers {
+		if i == top {
+			break
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%.1f%%\n", shortID(s.Name), s.Client, s.Blocks, s.Share*100)
+	}
+	fmt.Fprintln(tw)
+
+	tx := r.TxInclusion
+	fmt.Fprintln(tw, "TX INCLUSION DELAY\tTXS\tMEDIAN\tP90\tP99\tMAX")
+	fmt.Fprintf(tw, "announcement to block\t%d\t%s\t%s\t%s\t%s\n", tx.Included, fmtMs(tx.MedianMs), fmtMs(tx.P90Ms), fmtMs(tx.P99Ms), fmtMs(tx.MaxMs))
+	fmt.Fprintf(tw, "included before announced\t%d\n", tx.SeenAfterBlock)
+	fmt.Fprintf(tw, "included, never announced\t%d\n\n", tx.NeverAnnounced)
+
+	fmt.Fprintln(tw, "LAGGING PEER\tCLIENT\tBLOCKS\tFIRST\tMEDIAN LAG\tP90 LAG")
+	for i, p := range r.LaggingPeers {
+		if i == top {
+			break
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\t%s\n", shortID(p.Peer), p.Client, p.Blocks, p.FirstBlocks, fmtMs(p.MedianMs), fmtMs(p.P90Ms))
+	}
+
+	if len(r.Sensors) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "SENSOR\tBLOCKS\tFIRST\tSHARE\tMEDIAN LAG\tP90 LAG")
+		for _, s := range r.Sensors {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f%%\t%s\t%s\n", s.Sensor, s.Blocks, s.FirstBlocks, s.Share*100, fmtMs(s.MedianMs), fmtMs(s.P90Ms))
+		}
+	}
+
+	return tw.Flush()
+}
+
+func fmtMs(v float64) string {
+	return (time.Duration(v * float64(time.Millisecond))).Round(time.Millisecond).String()
+}
+
+// shortID truncates a node ID for display.
+func shortID(id string) string {
+	if len(id) <= 16 {
+		return id
+	}
+	return id[:16]
+}
@@ -0,0 +1,178 @@
+package analyze
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testStart = time.Unix(1700000000, 0)
+	block1    = common.HexToHash("0xb1")
+	block2    = common.HexToHash("0xb2")
+)
+
+func at(d time.Duration) time.Time {
+	return testStart.Add(d)
+}
+
+// testDataset has block 1 announced by four peers over 400ms and block 2 by a
+// single peer, with block 1 including an announced transaction, one announced
+// after the block and one never announced.
+func testDataset() *dataset {
+	d := newDataset(time.Time{}, time.Time{}, true)
+	d.addBlockEvent("sensor", "a", block1, 1, at(0), 0)
+	d.addBlockEvent("sensor", "b", block1, 1, at(100*time.Millisecond), 0)
+	d.addBlockEvent("sensor", "c", block1, 1, at(200*time.Millisecond), 0)
+	d.addBlockEvent("sensor", "d", block1, 1, at(400*time.Millisecond), 0)
+	d.addBlockEvent("sensor", "a", block2, 2, at(2*time.Second), 0)
+	d.addPeer("a", "bor/v2.0.1-stable/linux-amd64/go1.23.4")
+	d.addPeer("b", "Geth/v1.14.0-stable/linux-amd64/go1.22.0")
+
+	tx1, tx2, tx3 := common.HexToHash("0x01"), common.HexToHash("0x02"), common.HexToHash("0x03")
+	d.addBlockTxs(block1, 1, []common.Hash{tx1, tx2, tx3})
+	d.addTxEvent(tx1, at(-time.Second), 0)
+	d.addTxEvent(tx2, at(50*time.Millisecond), 0)
+	return d
+}
+
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		name   string
+		sorted []float64
+		p      float64
+		want   float64
+	}{
+		{"empty", nil, 50, 0},
+		{"single p50", []float64{7}, 50, 7},
+		{"single p100", []float64{7}, 100, 7},
+		{"p0", []float64{10, 20, 30, 40}, 0, 10},
+		{"p25", []float64{10, 20, 30, 40}, 25, 10},
+		{"p50", []float64{10, 20, 30, 40}, 50, 20},
+		{"p90", []float64{10, 20, 30, 40}, 90, 40},
+		{"p100", []float64{10, 20, 30, 40}, 100, 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, percentile(tt.sorted, tt.p))
+		})
+	}
+}
+
+func TestAnalyzePropagation(t *testing.T) {
+	r := analyze(testDataset(), analyzeParams{Percentiles: []float64{50, 100}, MinPeers: 1})
+
+	require.Equal(t, 2, r.Blocks)
+	require.Equal(t, 4, r.Peers)
+	require.Len(t, r.Propagation, 2)
+
+	require.Equal(t, block1, r.Propagation[0].Hash)
+	require.Equal(t, "a", r.Propagation[0].FirstPeer)
+	require.Equal(t, 4, r.Propagation[0].Peers)
+	require.Equal(t, []float64{100, 400}, r.Propagation[0].Curve)
+
+	// A single announcer is at every percentile at once
+	require.Equal(t, block2, r.Propagation[1].Hash)
+	require.Equal(t, 1, r.Propagation[1].Peers)
+	require.Equal(t, []float64{0, 0}, r.Propagation[1].Curve)
+
+	require.Equal(t, []propagationSummary{
+		{Percentile: 50, MedianMs: 0, P90Ms: 100, MaxMs: 100},
+		{Percentile: 100, MedianMs: 0, P90Ms: 400, MaxMs: 400},
+	}, r.PropagationSummary)
+
+	require.Equal(t, []announcerShare{{Name: "a", Client: "bor", Blocks: 2, Share: 1}}, r.FirstAnnouncers)
+	require.Equal(t, []announcerShare{{Name: "bor", Blocks: 2, Share: 1}}, r.FirstClients)
+
+	require.Equal(t, txInclusion{
+		Included:       1,
+		SeenAfterBlock: 1,
+		NeverAnnounced: 1,
+		MedianMs:       1000,
+		P90Ms:          1000,
+		P99Ms:          1000,
+		MaxMs:          1000,
+	}, r.TxInclusion)
+
+	// Blocks with fewer announcers than --min-peers are left out
+	r = analyze(testDataset(), analyzeParams{Percentiles: []float64{50, 100}, MinPeers: 2})
+	require.Equal(t, 1, r.Blocks)
+	require.Len(t, r.Propagation, 1)
+	require.Equal(t, []announcerShare{{Name: "a", Client: "bor", Blocks: 1, Share: 1}}, r.FirstAnnouncers)
+}
+
+func TestAnalyzeLaggingPeers(t *testing.T) {
+	tests := []struct {
+		name         string
+		minPeers     int
+		minBlocks    int
+		lagThreshold time.Duration
+		want         []string
+	}{
+		{"all peers", 1, 1, 0, []string{"d", "c", "b", "a"}},
+		{"lag threshold", 1, 1, 150 * time.Millisecond, []string{"d", "c"}},
+		{"threshold is inclusive", 1, 1, 200 * time.Millisecond, []string{"d", "c"}},
+		{"min blocks", 1, 2, 0, []string{"a"}},
+		{"min peers drops single announcer blocks", 2, 2, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := analyze(testDataset(), analyzeParams{
+				Percentiles:  []float64{50},
+				MinPeers:     tt.minPeers,
+				MinBlocks:    tt.minBlocks,
+				LagThreshold: tt.lagThreshold,
+			})
+			var peers []string
+			for _, lag := range r.LaggingPeers {
+				peers = append(peers, lag.Peer)
+			}
+			require.Equal(t, tt.want, peers)
+		})
+	}
+
+	r := analyze(testDataset(), analyzeParams{Percentiles: []float64{50}, MinPeers: 1, MinBlocks: 1})
+	require.Equal(t, peerLag{
+		Peer:     "b",
+		Name:     "Geth/v1.14.0-stable/linux-amd64/go1.22.0",
+		Client:   "geth",
+		Blocks:   1,
+		MedianMs: 100,
+		P90Ms:    100,
+	}, r.LaggingPeers[2])
+}
+
+func TestAnalyzeClockCorrection(t *testing.T) {
+	// The clock of the slow sensor is 300ms behind, which it recorded as its
+	// offset, so both peers announced the block at the same time.
+	load := func(correctClocks bool) *report {
+		d := newDataset(time.Time{}, time.Time{}, correctClocks)
+		d.addBlockEvent("fast", "a", block1, 1, at(0), 0)
+		d.addBlockEvent("slow", "b", block1, 1, at(-300*time.Millisecond), 300*time.Millisecond)
+		return analyze(d, analyzeParams{Percentiles: []float64{100}, MinPeers: 1, MinBlocks: 1})
+	}
+
+	r := load(true)
+	require.Equal(t, []float64{0}, r.Propagation[0].Curve)
+	require.Equal(t, testStart, r.Propagation[0].FirstSeen)
+	require.Len(t, r.FirstAnnouncers, 2)
+	require.Len(t, r.LaggingPeers, 2)
+	require.Zero(t, r.LaggingPeers[0].MedianMs+r.LaggingPeers[1].MedianMs)
+	require.Equal(t, []sensorLag{
+		{Sensor: "fast", Blocks: 1, FirstBlocks: 1, Share: 1},
+		{Sensor: "slow", Blocks: 1, FirstBlocks: 1, Share: 1},
+	}, r.Sensors)
+
+	r = load(false)
+	require.Equal(t, []float64{300}, r.Propagation[0].Curve)
+	require.Equal(t, at(-300*time.Millisecond), r.Propagation[0].FirstSeen)
+	require.Equal(t, "b", r.Propagation[0].FirstPeer)
+	require.Equal(t, "a", r.LaggingPeers[0].Peer)
+	require.Equal(t, float64(300), r.LaggingPeers[0].MedianMs)
+	require.Equal(t, []sensorLag{
+		{Sensor: "slow", Blocks: 1, FirstBlocks: 1, Share: 1},
+		{Sensor: "fast", Blocks: 1, MedianMs: 300, P90Ms: 300},
+	}, r.Sensors)
+}
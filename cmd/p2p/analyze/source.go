@@ -0,0 +1,540 @@
+package analyze
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/api/iterator"
+
+	"github.com/0xPolygon/polygon-cli/p2p/database"
+)
+
+// dataset is the subset of sensor observations the analysis needs. Peers are
+// keyed by node ID so the backends, which record either enode URLs or IDs,
+// can be compared. Event times are corrected by the clock offset the sensor
+// recorded with them, unless correctClocks is false.
+type dataset struct {
+	since, until  time.Time
+	correctClocks bool
+
+	// blockEvents holds the first time each peer announced each block, to
+	// any sensor.
+	blockEvents map[common.Hash]map[string]time.Time
+	// blockSensors holds the first time each sensor saw each block.
+	blockSensors map[common.Hash]map[string]time.Time
+	// blockNumbers is best effort, not every event or backend carries heights.
+	blockNumbers map[common.Hash]uint64
+	// blockTxs maps blocks to the transactions they include.
+	blockTxs map[common.Hash][]common.Hash
+	// txFirstSeen is the earliest announcement of each transaction by any peer.
+	txFirstSeen map[common.Hash]time.Time
+	// peerNames maps node IDs to the client names peers advertised.
+	peerNames map[string]string
+}
+
+func newDataset(since, until time.Time, correctClocks bool) *dataset {
+	return &dataset{
+		since:         since,
+		until:         until,
+		correctClocks: correctClocks,
+		blockEvents:   make(map[common.Hash]map[string]time.Time),
+		blockSensors:  make(map[common.Hash]map[string]time.Time),
+		blockNumbers:  make(map[common.Hash]uint64),
+		blockTxs:      make(map[common.Hash][]common.Hash),
+		txFirstSeen:   make(map[common.Hash]time.Time),
+		peerNames:     make(map[string]string),
+	}
+}
+
+// inWindow reports whether t is within the analysis window. Zero bounds are open.
+func (d *dataset) inWindow(t time.Time) bool {
+	if !d.since.IsZero() && t.Before(d.since) {
+		return false
+	}
+	if !d.until.IsZero() && t.After(d.until) {
+		return false
+	}
+	return true
+}
+
+// correct applies a sensor's recorded clock offset to an event time.
+func (d *dataset) correct(t time.Time, offset time.Duration) time.Time {
+	if !d.correctClocks {
+		return t
+	}
+	return t.Add(offset)
+}
+
+func (d *dataset) addBlockEvent(sensor, peer string, hash common.Hash, number uint64, t time.Time, offset time.Duration) {
+	t = d.correct(t, offset)
+	if peer == "" || !d.inWindow(t) {
+		return
+	}
+
+	keepFirst(d.blockEvents, hash, nodeID(peer), t)
+	keepFirst(d.blockSensors, hash, sensor, t)
+	if number > 0 {
+		d.blockNumbers[hash] = number
+	}
+}
+
+// keepFirst records t for key under hash unless an earlier time is recorded.
+func keepFirst(m map[common.Hash]map[string]time.Time, hash common.Hash, key string, t time.Time) {
+	times, ok := m[hash]
+	if !ok {
+		times = make(map[string]time.Time)
+		m[hash] = times
+	}
+	if seen, ok := times[key]; !ok || t.Before(seen) {
+		times[key] = t
+	}
+}
+
+func (d *dataset) addTxEvent(hash common.Hash, t time.Time, offset time.Duration) {
+	t = d.correct(t, offset)
+	if !d.inWindow(t) {
+		return
+	}
+
+	if seen, ok := d.txFirstSeen[hash]; !ok || t.Before(seen) {
+		d.txFirstSeen[hash] = t
+	}
+}
+
+func (d *dataset) addBlockTxs(hash common.Hash, number uint64, txs []common.Hash) {
+	if len(txs) > 0 {
+		d.blockTxs[hash] = txs
+	}
+	if number > 0 {
+		d.blockNumbers[hash] = number
+	}
+}
+
+func (d *dataset) addPeer(peer, name string) {
+	if peer != "" && name != "" {
+		d.peerNames[nodeID(peer)] = name
+	}
+}
+
+// nodeID normalizes an enode URL to its node ID. Anything else, such as the
+// node IDs ClickHouse records, is returned lowercased.
+func nodeID(peer string) string {
+	if strings.HasPrefix(peer, "enode://") {
+		if node, err := enode.ParseV4(peer); err == nil {
+			return node.ID().String()
+		}
+	}
+	return strings.ToLower(peer)
+}
+
+// source loads sensor observations into a dataset.
+type source interface {
+	load(ctx context.Context, d *dataset) error
+}
+
+// newSource returns the source selected by the input parameters.
+func newSource() (source, error) {
+	switch inputAnalyzeParams.Database {
+	case "json":
+		return &jsonSource{path: inputAnalyzeParams.InputFile}, nil
+	case "pebble":
+		return &pebbleSource{path: inputAnalyzeParams.PebblePath}, nil
+	case "clickhouse":
+		return &clickHouseSource{dsn: inputAnalyzeParams.ClickHouseDSN}, nil
+	case "datastore":
+		return &datastoreSource{
+			projectID:  inputAnalyzeParams.ProjectID,
+			databaseID: inputAnalyzeParams.DatabaseID,
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid database option: %s", inputAnalyzeParams.Database)
+	}
+}
+
+// jsonSource reads the newline delimited records of `p2p sensor --database json`.
+type jsonSource struct {
+	path string
+}
+
+// jsonRecord is the union of the sensor's JSON record types fields that the
+// analysis reads. The type field tells them apart.
+type jsonRecord struct {
+	Type          string    `json:"type"`
+	SensorID      string    `json:"sensor_id"`
+	PeerID        string    `json:"peer_id"`
+	Hash          string    `json:"hash"`
+	Number        uint64    `json:"number"`
+	Timestamp     time.Time `json:"timestamp"`
+	Transactions  []string  `json:"transactions"`
+	URL           string    `json:"url"`
+	Name          string    `json:"name"`
+	TimeFirstSeen time.Time `json:"time_first_seen"`
+	ClockOffset   int64     `json:"clock_offset"`
+}
+
+func (s *jsonSource) load(ctx context.Context, d *dataset) error {
+	var r io.Reader = os.Stdin
+	if s.path != "" && s.path != "-" {
+		file, err := os.Open(s.path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var record jsonRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// The sensor's logs may be interleaved with its output.
+			log.Debug().Err(err).Int("line", line).Msg("Skipping non-record line")
+			continue
+		}
+
+		hash := common.HexToHash(record.Hash)
+		switch record.Type {
+		case "block_event", "block_hash":
+			d.addBlockEvent(record.SensorID, record.PeerID, hash, record.Number, record.Timestamp, time.Duration(record.ClockOffset))
+		case "transaction_event":
+			d.addTxEvent(hash, record.Timestamp, time.Duration(record.ClockOffset))
+		case "block", "block_body":
+			txs := make([]common.Hash, 0, len(record.Transactions))
+			for _, tx := range record.Transactions {
+				txs = append(txs, common.HexToHash(tx))
+			}
+			d.addBlockTxs(hash, record.Number, txs)
+		case "peer":
+			d.addPeer(record.URL, record.Name)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// pebbleSource reads a `p2p sensor --database pebble` directory. The sensor
+// must not be running, Pebble only allows one process to open it.
+type pebbleSource struct {
+	path string
+}
+
+func (s *pebbleSource) load(ctx context.Context, d *dataset) error {
+	db, err := database.NewPebble(database.PebbleOptions{Path: s.path})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := db.ForEachBlockEvent(func(event database.PebbleEvent) error {
+		d.addBlockEvent(event.SensorID, event.PeerID, event.Hash, event.Number, event.Time, event.ClockOffset)
+		return ctx.Err()
+	}); err != nil {
+		return err
+	}
+
+	if err := db.ForEachTransactionEvent(func(event database.PebbleEvent) error {
+		d.addTxEvent(event.Hash, event.Time, event.ClockOffset)
+		return ctx.Err()
+	}); err != nil {
+		return err
+	}
+
+	if err := db.ForEachBlock(func(hash common.Hash, block *database.PebbleBlock) error {
+		var number uint64
+		if block.Header != nil {
+			number = block.Header.Number.Uint64()
+		}
+		d.addBlockTxs(hash, number, block.Transactions)
+		return ctx.Err()
+	}); err != nil {
+		return err
+	}
+
+	peers, err := db.Peers()
+	if err != nil {
+		return err
+	}
+	for _, peer := range peers {
+		d.addPeer(peer.URL, peer.Name)
+	}
+
+	return nil
+}
+
+// clickHouseSource queries the event streams of a `p2p sensor --database
+// clickhouse` deployment. Only hash announcements and whole blocks count as
+// peer observations, the other block event sources carry no peer.
+type clickHouseSource struct {
+	dsn string
+}
+
+func (s *clickHouseSource) load(ctx context.Context, d *dataset) error {
+	if s.dsn == "" {
+		return errors.New("--clickhouse-dsn is required with --database=clickhouse")
+	}
+
+	opts, err := clickhouse.ParseDSN(s.dsn)
+	if err != nil {
+		return fmt.Errorf("invalid ClickHouse DSN: %w", err)
+	}
+	conn, err := clickhouse.Open(opts)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// The window is applied to the uncorrected times, widened by a margin that
+	// exceeds any plausible clock offset, and again exactly by the dataset.
+	since, until := d.since, d.until
+	if since.IsZero() {
+		since = time.Unix(0, 0)
+	} else {
+		since = since.Add(-clockOffsetMargin)
+	}
+	if until.IsZero() {
+		until = time.Now()
+	} else {
+		until = until.Add(clockOffsetMargin)
+	}
+
+	// clock_offset_ns only exists once a deployment has enabled clock sync.
+	offset := "toInt64(0)"
+	var columns uint64
+	if err := conn.QueryRow(ctx, `
+		SELECT count() FROM system.columns
+		WHERE database = currentDatabase() AND table IN ('block_events', 'tx_events') AND name = 'clock_offset_ns'`,
+	).Scan(&columns); err != nil {
+		return fmt.Errorf("failed to query columns: %w", err)
+	}
+	if columns == 2 {
+		offset = "clock_offset_ns"
+	}
+
+	rows, err := conn.Query(ctx, `
+		SELECT block_hash, block_number, sensor_id, node_id, min(seen_at), argMin(`+offset+`, seen_at)
+		FROM block_events
+		WHERE seen_at BETWEEN ? AND ? AND node_id != '' AND source IN ('hash_announce', 'new_block')
+		GROUP BY block_hash, block_number, sensor_id, node_id`, since, until)
+	if err != nil {
+		return fmt.Errorf("failed to query block_events: %w", err)
+	}
+	for rows.Next() {
+		var hash, sensor, node string
+		var number uint64
+		var seenAt time.Time
+		var clockOffset int64
+		if err := rows.Scan(&hash, &number, &sensor, &node, &seenAt, &clockOffset); err != nil {
+			rows.Close()
+			return err
+		}
+		d.addBlockEvent(sensor, node, common.HexToHash(hash), number, seenAt, time.Duration(clockOffset))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = conn.Query(ctx, `
+		SELECT tx_hash, min(seen_at), argMin(`+offset+`, seen_at)
+		FROM tx_events
+		WHERE seen_at BETWEEN ? AND ?
+		GROUP BY tx_hash, sensor_id`, since, until)
+	if err != nil {
+		return fmt.Errorf("failed to query tx_events: %w", err)
+	}
+	for rows.Next() {
+		var hash string
+		var seenAt time.Time
+		var clockOffset int64
+		if err := rows.Scan(&hash, &seenAt, &clockOffset); err != nil {
+			rows.Close()
+			return err
+		}
+		d.addTxEvent(common.HexToHash(hash), seenAt, time.Duration(clockOffset))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = conn.Query(ctx, `
+		SELECT block_hash, groupArray(tx_hash)
+		FROM (SELECT block_hash, tx_hash FROM block_txs WHERE seen_date BETWEEN toDate(?) AND toDate(?) ORDER BY tx_index)
+		GROUP BY block_hash`, since, until)
+	if err != nil {
+		return fmt.Errorf("failed to query block_txs: %w", err)
+	}
+	for rows.Next() {
+		var hash string
+		var txHashes []string
+		if err := rows.Scan(&hash, &txHashes); err != nil {
+			rows.Close()
+			return err
+		}
+		txs := make([]common.Hash, 0, len(txHashes))
+		for _, tx := range txHashes {
+			txs = append(txs, common.HexToHash(tx))
+		}
+		d.addBlockTxs(common.HexToHash(hash), 0, txs)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = conn.Query(ctx, `
+		SELECT node_id, argMax(name, seen_at)
+		FROM peers
+		WHERE seen_at BETWEEN ? AND ?
+		GROUP BY node_id`, since, until)
+	if err != nil {
+		return fmt.Errorf("failed to query peers: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var node, name string
+		if err := rows.Scan(&node, &name); err != nil {
+			return err
+		}
+		d.addPeer(node, name)
+	}
+
+	return rows.Err()
+}
+
+// datastoreSource queries the kinds of a `p2p sensor --database datastore`
+// deployment.
+type datastoreSource struct {
+	projectID  string
+	databaseID string
+}
+
+func (s *datastoreSource) load(ctx context.Context, d *dataset) error {
+	if s.projectID == "" {
+		return errors.New("--project-id is required with --database=datastore")
+	}
+
+	client, err := datastore.NewClientWithDatabase(ctx, s.projectID, s.databaseID)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := s.events(ctx, client, database.BlockEventsKind, func(event *database.DatastoreEvent) {
+		d.addBlockEvent(event.SensorId, event.PeerId, common.HexToHash(event.Hash.Name), 0, event.Time, time.Duration(event.ClockOffset))
+	}, d); err != nil {
+		return err
+	}
+
+	if err := s.events(ctx, client, database.TransactionEventsKind, func(event *database.DatastoreEvent) {
+		d.addTxEvent(common.HexToHash(event.Hash.Name), event.Time, time.Duration(event.ClockOffset))
+	}, d); err != nil {
+		return err
+	}
+
+	// Only the blocks that were announced in the window are needed, so look
+	// them up by key rather than scanning the kind.
+	hashes := make([]common.Hash, 0, len(d.blockEvents))
+	for hash := range d.blockEvents {
+		hashes = append(hashes, hash)
+	}
+	for start := 0; start < len(hashes); start += datastoreGetBatch {
+		end := min(start+datastoreGetBatch, len(hashes))
+
+		keys := make([]*datastore.Key, 0, end-start)
+		for _, hash := range hashes[start:end] {
+			keys = append(keys, datastore.NameKey(database.BlocksKind, hash.Hex(), nil))
+		}
+
+		blocks := make([]database.DatastoreBlock, len(keys))
+		err := client.GetMulti(ctx, keys, blocks)
+		var multiErr datastore.MultiError
+		if err != nil && !errors.As(err, &multiErr) {
+			return err
+		}
+
+		for i, block := range blocks {
+			if multiErr != nil && multiErr[i] != nil {
+				continue
+			}
+			txs := make([]common.Hash, 0, len(block.Transactions))
+			for _, key := range block.Transactions {
+				txs = append(txs, common.HexToHash(key.Name))
+			}
+			var number uint64
+			if block.DatastoreHeader != nil {
+				_, _ = fmt.Sscan(block.Number, &number)
+			}
+			d.addBlockTxs(hashes[start+i], number, txs)
+		}
+	}
+
+	iter := client.Run(ctx, datastore.NewQuery(database.PeersKind))
+	for {
+		var peer database.DatastorePeer
+		_, err := iter.Next(&peer)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		d.addPeer(peer.URL, peer.Name)
+	}
+
+	return nil
+}
+
+const (
+	// Maximum number of keys per Datastore GetMulti call.
+	datastoreGetBatch = 1000
+	// clockOffsetMargin widens database side time filters, which see the
+	// uncorrected times, so no corrected event in the window is missed.
+	clockOffsetMargin = time.Minute
+)
+
+// events runs a time bounded query over an event kind.
+func (s *datastoreSource) events(ctx context.Context, client *datastore.Client, kind string, fn func(*database.DatastoreEvent), d *dataset) error {
+	query := datastore.NewQuery(kind)
+	if !d.since.IsZero() {
+		query = query.FilterField("Time", ">=", d.since.Add(-clockOffsetMargin))
+	}
+	if !d.until.IsZero() {
+		query = query.FilterField("Time", "<=", d.until.Add(clockOffsetMargin))
+	}
+
+	iter := client.Run(ctx, query)
+	for {
+		var event database.DatastoreEvent
+		_, err := iter.Next(&event)
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to query %s: %w", kind, err)
+		}
+		if event.Hash != nil {
+			fn(&event)
+		}
+	}
+}
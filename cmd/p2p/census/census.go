@@ -0,0 +1,112 @@
+package census
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/0xPolygon/polygon-cli/p2p"
+)
+
+type (
+	censusParams struct {
+		Chain     string
+		Genesis   string
+		HeadBlock uint64
+		NetworkID uint64
+		Top       int
+		JSON      bool
+		HTMLFile  string
+
+		schedule *p2p.ForkSchedule
+	}
+)
+
+var (
+	//go:embed usage.md
+	censusUsage       string
+	inputCensusParams censusParams
+)
+
+// CensusCmd aggregates the Hello and Status messages collected by `p2p ping`
+// and `p2p crawl` into a report of the clients and forks on the network.
+var CensusCmd = &cobra.Command{
+	Use:   "census [nodes files]",
+	Short: "Report the clients, versions and forks of nodes from ping or crawl output.",
+	Long:  censusUsage,
+	Args:  cobra.MinimumNArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) (err error) {
+		inputCensusParams.schedule, err = p2p.LoadForkSchedule(inputCensusParams.Chain, inputCensusParams.Genesis)
+		return err
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		nodes := make(map[string][]p2p.NodeJSON)
+		for _, file := range args {
+			if err := readNodeSet(file, nodes); err != nil {
+				return err
+			}
+		}
+		log.Info().Int("nodes", len(nodes)).Int("files", len(args)).Msg("Loaded node sets")
+
+		r := newCensus(nodes, inputCensusParams.schedule, censusOptions{
+			HeadBlock: inputCensusParams.HeadBlock,
+			NetworkID: inputCensusParams.NetworkID,
+			Now:       time.Now(),
+		})
+
+		if inputCensusParams.HTMLFile != "" {
+			f, err := os.Create(inputCensusParams.HTMLFile)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			if err := r.writeHTML(f); err != nil {
+				return fmt.Errorf("failed to write HTML report: %w", err)
+			}
+		}
+
+		if inputCensusParams.JSON {
+			return r.writeJSON(os.Stdout)
+		}
+		return r.writeText(os.Stdout, inputCensusParams.Top)
+	},
+}
+
+// readNodeSet merges the node set written by ping or crawl in file into nodes.
+func readNodeSet(file string, nodes map[string][]p2p.NodeJSON) error {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	var ns map[string][]p2p.NodeJSON
+	if err := json.Unmarshal(b, &ns); err != nil {
+		return fmt.Errorf("failed to parse node set %v: %w", file, err)
+	}
+
+	for id, events := range ns {
+		nodes[id] = append(nodes[id], events...)
+	}
+	return nil
+}
+
+func init() {
+	f := CensusCmd.Flags()
+	f.StringVar(&inputCensusParams.Chain, "chain", "",
+		fmt.Sprintf("named network to compute the expected fork ID from (%v)", strings.Join(p2p.ForkNetworks(), ", ")))
+	f.StringVarP(&inputCensusParams.Genesis, "genesis", "g", "", "genesis JSON file to compute the expected fork ID from")
+	CensusCmd.MarkFlagsMutuallyExclusive("chain", "genesis")
+	f.Uint64Var(&inputCensusParams.HeadBlock, "head-block", 0,
+		"current block number for the expected fork ID (default inferred from the latest fork nodes report)")
+	f.Uint64VarP(&inputCensusParams.NetworkID, "network-id", "n", 0, "only count nodes with this network ID (0 for all)")
+	f.IntVar(&inputCensusParams.Top, "top", 20, "maximum rows per table in the text report (-1 for all)")
+	f.BoolVar(&inputCensusParams.JSON, "json", false, "output the full report as JSON")
+	f.StringVar(&inputCensusParams.HTMLFile, "html", "", "file to write an HTML report to")
+}
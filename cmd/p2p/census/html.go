@@ -0,0 +1,41 @@
+package census
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+)
+
+//go:embed template.html
+var censusTemplate string
+
+// section is a table of shares in the HTML report.
+type section struct {
+	Title  string
+	Shares []share
+}
+
+// writeHTML renders the census as a standalone HTML page.
+func (c *census) writeHTML(w io.Writer) error {
+	tmpl, err := template.New("census").Funcs(template.FuncMap{"percent": fmtShare}).Parse(censusTemplate)
+	if err != nil {
+		return err
+	}
+
+	sections := []section{
+		{"Clients", c.Clients},
+		{"Client versions", c.ClientVersions},
+		{"eth protocol versions", c.EthVersions},
+		{"Capabilities", c.Capabilities},
+		{"Network IDs", c.NetworkIDs},
+		{"Genesis hashes", c.Genesis},
+	}
+	if c.ForkStatus != nil {
+		sections = append(sections, section{"Fork status", c.ForkStatus})
+	}
+
+	return tmpl.Execute(w, struct {
+		*census
+		Sections []section
+	}{c, sections})
+}
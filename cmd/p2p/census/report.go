@@ -0,0 +1,323 @@
+package census
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/forkid"
+
+	"github.com/0xPolygon/polygon-cli/p2p"
+)
+
+// forkNextMismatch refines the compatible fork status for nodes on the
+// expected fork that announce a different next fork.
+const forkNextMismatch = "next-mismatch"
+
+// census is the aggregated view of the nodes in the node sets. Shares of
+// clients and capabilities are of the reachable nodes, and shares of status
+// fields are of the nodes that sent a status.
+type census struct {
+	Generated      time.Time     `json:"generated"`
+	Nodes          int           `json:"nodes"`
+	Reachable      int           `json:"reachable"`
+	WithStatus     int           `json:"withStatus"`
+	ExpectedFork   *expectedFork `json:"expectedFork,omitempty"`
+	Clients        []share       `json:"clients"`
+	ClientVersions []share       `json:"clientVersions"`
+	EthVersions    []share       `json:"ethVersions"`
+	Capabilities   []share       `json:"capabilities"`
+	NetworkIDs     []share       `json:"networkIds"`
+	Genesis        []share       `json:"genesis"`
+	ForkStatus     []share       `json:"forkStatus,omitempty"`
+	ForkIDs        []forkShare   `json:"forkIds"`
+	Heads          []share       `json:"heads"`
+}
+
+// share counts the nodes with a value.
+type share struct {
+	Name  string  `json:"name"`
+	Nodes int     `json:"nodes"`
+	Share float64 `json:"share"`
+}
+
+// forkShare counts the nodes advertising a fork ID.
+type forkShare struct {
+	Hash   string  `json:"hash"`
+	Next   uint64  `json:"next"`
+	Status string  `json:"status,omitempty"`
+	Nodes  int     `json:"nodes"`
+	Share  float64 `json:"share"`
+}
+
+// expectedFork is the fork ID nodes on the configured chain should advertise.
+type expectedFork struct {
+	Hash    string `json:"hash"`
+	Next    uint64 `json:"next"`
+	Genesis string `json:"genesis"`
+	Head    uint64 `json:"head"`
+	// Inferred is whether the head was inferred from the latest fork the nodes
+	// advertise rather than given.
+	Inferred bool `json:"inferred"`
+}
+
+type censusOptions struct {
+	HeadBlock uint64
+	NetworkID uint64
+	Now       time.Time
+}
+
+// node is the latest handshake result of a node.
+type node struct {
+	hello  *p2p.Hello
+	status *p2p.Status
+}
+
+// latest returns the latest hello and status of the node's events.
+func latest(events []p2p.NodeJSON) node {
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time < events[j].Time })
+
+	var n node
+	for _, e := range events {
+		if e.Hello != nil {
+			n.hello = e.Hello
+		}
+		if e.Status != nil {
+			n.status = e.Status
+		}
+	}
+	return n
+}
+
+// newCensus aggregates the node sets. The fork schedule is optional and enables
+// classifying the fork IDs.
+func newCensus(nodes map[string][]p2p.NodeJSON, schedule *p2p.ForkSchedule, opts censusOptions) *census {
+	c := &census{Generated: opts.Now.UTC()}
+
+	var (
+		clients      = make(map[string]int)
+		versions     = make(map[string]int)
+		ethVersions  = make(map[string]int)
+		capabilities = make(map[string]int)
+		networkIDs   = make(map[string]int)
+		genesis      = make(map[string]int)
+		heads        = make(map[string]int)
+		forkIDs      = make(map[forkid.ID]int)
+	)
+
+	for _, events := range nodes {
+		n := latest(events)
+		if opts.NetworkID != 0 && (n.status == nil || n.status.NetworkID != opts.NetworkID) {
+			continue
+		}
+		c.Nodes++
+
+		if n.hello != nil {
+			c.Reachable++
+
+			client, version := parseClient(n.hello.Name)
+			clients[client]++
+			versions[client+"/"+version]++
+
+			for _, cp := range n.hello.Caps {
+				capabilities[cp.String()]++
+				if cp.Name == "eth" {
+					ethVersions[cp.String()]++
+				}
+			}
+		}
+
+		if n.status != nil {
+			c.WithStatus++
+			networkIDs[fmt.Sprint(n.status.NetworkID)]++
+			genesis[n.status.Genesis.Hex()]++
+			heads[n.status.Head.Hex()]++
+			forkIDs[n.status.ForkID]++
+		}
+	}
+
+	c.Clients = toShares(clients, c.Reachable)
+	c.ClientVersions = toShares(versions, c.Reachable)
+	c.EthVersions = toShares(ethVersions, c.Reachable)
+	c.Capabilities = toShares(capabilities, c.Reachable)
+	c.NetworkIDs = toShares(networkIDs, c.WithStatus)
+	c.Genesis = toShares(genesis, c.WithStatus)
+	c.Heads = toShares(heads, c.WithStatus)
+
+	var expected forkid.ID
+	if schedule != nil {
+		expected, c.ExpectedFork = expectedID(schedule, forkIDs, opts)
+	}
+	now := uint64(opts.Now.Unix())
+
+	statuses := make(map[string]int)
+	for id, count := range forkIDs {
+		fs := forkShare{
+			Hash:  "0x" + hex.EncodeToString(id.Hash[:]),
+			Next:  id.Next,
+			Nodes: count,
+			Share: ratio(count, c.WithStatus),
+		}
+		if schedule != nil {
+			fs.Status = forkStatus(schedule, expected, id, c.ExpectedFork.Head, now)
+			statuses[fs.Status] += count
+		}
+		c.ForkIDs = append(c.ForkIDs, fs)
+	}
+	sort.Slice(c.ForkIDs, func(i, j int) bool {
+		if c.ForkIDs[i].Nodes != c.ForkIDs[j].Nodes {
+			return c.ForkIDs[i].Nodes > c.ForkIDs[j].Nodes
+		}
+		return c.ForkIDs[i].Hash < c.ForkIDs[j].Hash
+	})
+	if schedule != nil {
+		c.ForkStatus = toShares(statuses, c.WithStatus)
+	}
+
+	return c
+}
+
+// expectedID returns the fork ID of the chain at the head block, or at the
+// latest fork any node advertises when no head block is given.
+func expectedID(schedule *p2p.ForkSchedule, forkIDs map[forkid.ID]int, opts censusOptions) (forkid.ID, *expectedFork) {
+	head, inferred := opts.HeadBlock, false
+	if head == 0 {
+		inferred = true
+		latest := 0
+		for id := range forkIDs {
+			latest = max(latest, schedule.Index(id.Hash))
+		}
+		if fork := schedule.Forks[latest]; fork.Time > 0 {
+			// Time forks activate after every block fork.
+			head = math.MaxUint64
+		} else {
+			head = fork.Block
+		}
+	}
+
+	id := schedule.ID(head, uint64(opts.Now.Unix()))
+	return id, &expectedFork{
+		Hash:     "0x" + hex.EncodeToString(id.Hash[:]),
+		Next:     id.Next,
+		Genesis:  schedule.Genesis.Hex(),
+		Head:     head,
+		Inferred: inferred,
+	}
+}
+
+// forkStatus classifies a fork ID against the schedule at the expected head.
+// Nodes on the expected fork that announce a different next fork have not
+// upgraded for, or do not know about, the upcoming fork.
+func forkStatus(schedule *p2p.ForkSchedule, expected, id forkid.ID, head, now uint64) string {
+	status := schedule.Classify(id, head, now)
+	if status == p2p.ForkCompatible && id.Hash == expected.Hash && id.Next != expected.Next {
+		return forkNextMismatch
+	}
+	return status
+}
+
+// parseClient extracts the client and version from a devp2p name such as
+// "bor/v2.0.1-stable-8a2b2e6e/linux-amd64/go1.23.4". Names may have a custom
+// identity after the client, so the version is the first part that looks like
+// one.
+func parseClient(name string) (string, string) {
+	if name == "" {
+		return "unknown", "unknown"
+	}
+
+	parts := strings.Split(name, "/")
+	client := strings.ToLower(parts[0])
+	for _, part := range parts[1:] {
+		if len(part) > 1 && part[0] == 'v' && part[1] >= '0' && part[1] <= '9' {
+			version, _, _ := strings.Cut(part, "-")
+			return client, version
+		}
+	}
+	return client, "unknown"
+}
+
+func toShares(counts map[string]int, total int) []share {
+	shares := make([]share, 0, len(counts))
+	for name, count := range counts {
+		shares = append(shares, share{Name: name, Nodes: count, Share: ratio(count, total)})
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].Nodes != shares[j].Nodes {
+			return shares[i].Nodes > shares[j].Nodes
+		}
+		return shares[i].Name < shares[j].Name
+	})
+	return shares
+}
+
+func ratio(count, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) / float64(total)
+}
+
+func (c *census) writeJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(c)
+}
+
+// writeText writes a human readable summary, listing at most top rows per table.
+func (c *census) writeText(w io.Writer, top int) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Nodes: %d\nReachable: %d\nWith status: %d\n", c.Nodes, c.Reachable, c.WithStatus)
+	if f := c.ExpectedFork; f != nil {
+		head := fmt.Sprint(f.Head)
+		if f.Inferred {
+			head += " (inferred)"
+		}
+		fmt.Fprintf(tw, "Expected fork ID: %s next %d at head %s\n", f.Hash, f.Next, head)
+	}
+	fmt.Fprintln(tw)
+
+	writeShares(tw, "CLIENT", c.Clients, top)
+	writeShares(tw, "VERSION", c.ClientVersions, top)
+	writeShares(tw, "ETH VERSION", c.EthVersions, top)
+	writeShares(tw, "CAPABILITY", c.Capabilities, top)
+	writeShares(tw, "NETWORK ID", c.NetworkIDs, top)
+	writeShares(tw, "GENESIS", c.Genesis, top)
+	if c.ForkStatus != nil {
+		writeShares(tw, "FORK STATUS", c.ForkStatus, top)
+	}
+
+	fmt.Fprintln(tw, "FORK HASH\tNEXT\tSTATUS\tNODES\tSHARE")
+	for i, f := range c.ForkIDs {
+		if top >= 0 && i >= top {
+			break
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%s\n", f.Hash, f.Next, f.Status, f.Nodes, fmtShare(f.Share))
+	}
+	fmt.Fprintln(tw)
+
+	writeShares(tw, "HEAD", c.Heads, top)
+
+	return tw.Flush()
+}
+
+func writeShares(w io.Writer, header string, shares []share, top int) {
+	fmt.Fprintf(w, "%s\tNODES\tSHARE\n", header)
+	for i, s := range shares {
+		if top >= 0 && i >= top {
+			break
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\n", s.Name, s.Nodes, fmtShare(s.Share))
+	}
+	fmt.Fprintln(w)
+}
+
+func fmtShare(share float64) string {
+	return fmt.Sprintf("%.1f%%", share*100)
+}
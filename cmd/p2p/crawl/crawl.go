@@ -2,11 +2,14 @@ package crawl
 
 import (
 	_ "embed"
+	"encoding/json"
 	"fmt"
+	"math"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/p2p/dnsdisc"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/rs/zerolog/log"
@@ -27,8 +30,22 @@ type (
 		Database             string
 		RevalidationInterval string
 		OnlyURLs             bool
+		Graph                string
+		GraphFormat          string
+		GraphQueries         int
+		TopologyReport       string
+		Top                  int
+		MaxInDegree          int
+		MinTableSize         int
+		Chain                string
+		Genesis              string
+		HeadBlock            uint64
+		DiscV4               bool
+		DiscV5               bool
+		BootnodesV5          string
 
 		revalidationInterval time.Duration
+		forks                *p2p.ForkSchedule
 	}
 )
 
@@ -58,6 +75,21 @@ var CrawlCmd = &cobra.Command{
 			return err
 		}
 
+		switch inputCrawlParams.GraphFormat {
+		case "json", "graphml":
+		default:
+			return fmt.Errorf("invalid graph format %q, must be json or graphml", inputCrawlParams.GraphFormat)
+		}
+
+		inputCrawlParams.forks, err = p2p.LoadForkSchedule(inputCrawlParams.Chain, inputCrawlParams.Genesis)
+		if err != nil {
+			return err
+		}
+		if inputCrawlParams.HeadBlock == 0 {
+			// Without a head, assume every scheduled block fork has passed.
+			inputCrawlParams.HeadBlock = math.MaxUint64
+		}
+
 		return nil
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -76,37 +108,70 @@ var CrawlCmd = &cobra.Command{
 			log.Warn().Err(err).Msgf("Creating nodes file %v because it does not exist", inputCrawlParams.NodesFile)
 		}
 
-		var cfg discover.Config
-		cfg.PrivateKey, _ = crypto.GenerateKey()
-		cfg.Bootnodes, err = p2p.ParseBootnodes(inputCrawlParams.Bootnodes)
+		opts := p2p.DiscoveryOptions{
+			V4: inputCrawlParams.DiscV4,
+			V5: inputCrawlParams.DiscV5,
+		}
+		opts.PrivateKey, _ = crypto.GenerateKey()
+		opts.Bootnodes, err = p2p.ParseBootnodes(inputCrawlParams.Bootnodes)
 		if err != nil {
 			return fmt.Errorf("unable to parse bootnodes: %w", err)
 		}
-
-		db, err := enode.OpenDB(inputCrawlParams.Database)
-		if err != nil {
-			return err
+		if inputCrawlParams.BootnodesV5 != "" {
+			opts.BootnodesV5, err = p2p.ParseBootnodes(inputCrawlParams.BootnodesV5)
+			if err != nil {
+				return fmt.Errorf("unable to parse discv5 bootnodes: %w", err)
+			}
 		}
 
-		ln := enode.NewLocalNode(db, cfg.PrivateKey)
-		socket, err := p2p.Listen(ln)
+		db, err := enode.OpenDB(inputCrawlParams.Database)
 		if err != nil {
 			return err
 		}
 
-		disc, err := discover.ListenV4(socket, ln, cfg)
+		ln := enode.NewLocalNode(db, opts.PrivateKey)
+		disc, err := p2p.ListenDiscovery(ln, opts)
 		if err != nil {
 			return err
 		}
 		defer disc.Close()
 
-		c := newCrawler(nodes, disc, disc.RandomNodes())
+		// The discv5 DHT is shared with consensus clients, so only nodes
+		// advertising eth, on this chain if one is given, are crawled from it.
+		filter := p2p.EthNodeFilter(inputCrawlParams.forks, inputCrawlParams.HeadBlock)
+		c := newCrawler(nodes, disc, disc.RandomNodes(filter)...)
 		c.revalidateInterval = inputCrawlParams.revalidationInterval
 
+		if inputCrawlParams.Graph != "" || inputCrawlParams.TopologyReport != "" {
+			// The routing table queries need their own socket and key, since
+			// replies to the discovery socket would be dropped by it.
+			key, err := crypto.GenerateKey()
+			if err != nil {
+				return err
+			}
+			client, err := p2p.NewDiscv4Client(key)
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+
+			c.topology = &topologyMapper{
+				client:   client,
+				topology: p2p.NewTopology(),
+				queries:  inputCrawlParams.GraphQueries,
+			}
+		}
+
 		log.Info().Msg("Starting crawl")
 
 		output := c.run(inputCrawlParams.timeout, inputCrawlParams.Threads)
 
+		if c.topology != nil {
+			if err := writeTopology(c.topology.topology); err != nil {
+				return err
+			}
+		}
+
 		if inputCrawlParams.OnlyURLs {
 			return p2p.WriteURLs(inputCrawlParams.NodesFile, output)
 		}
@@ -116,6 +181,53 @@ var CrawlCmd = &cobra.Command{
 	},
 }
 
+// writeTopology writes the topology graph and metrics report to the files given
+// by the flags, and logs a summary of the metrics.
+func writeTopology(topology *p2p.Topology) error {
+	if inputCrawlParams.Graph != "" {
+		f, err := os.Create(inputCrawlParams.Graph)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		g := topology.Graph()
+		if inputCrawlParams.GraphFormat == "graphml" {
+			err = g.WriteGraphML(f)
+		} else {
+			err = g.WriteJSON(f)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write graph: %w", err)
+		}
+	}
+
+	metrics := topology.Metrics(p2p.TopologyMetricsOptions{
+		Top:          inputCrawlParams.Top,
+		MaxInDegree:  inputCrawlParams.MaxInDegree,
+		MinTableSize: inputCrawlParams.MinTableSize,
+	})
+	log.Info().
+		Int("nodes", metrics.Nodes).
+		Int("responded", metrics.Responded).
+		Int("edges", metrics.Edges).
+		Int("out_degree_median", metrics.OutDegree.Median).
+		Int("in_degree_median", metrics.InDegree.Median).
+		Float64("clustering", metrics.Clustering).
+		Int("low_in_degree", metrics.LowInDegreeCount).
+		Msg("Mapped topology")
+
+	if inputCrawlParams.TopologyReport == "" {
+		return nil
+	}
+
+	b, err := json.MarshalIndent(metrics, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(inputCrawlParams.TopologyReport, b, 0644)
+}
+
 func init() {
 	f := CrawlCmd.Flags()
 	f.StringVarP(&inputCrawlParams.Bootnodes, "bootnodes", "b", "",
@@ -129,4 +241,19 @@ required, so other nodes in the network can discover each other`)
 	f.StringVarP(&inputCrawlParams.Database, "database", "d", "", "node database for updating and storing client information")
 	f.StringVarP(&inputCrawlParams.RevalidationInterval, "revalidation-interval", "r", "10m", "time before retrying to connect to a failed peer")
 	f.BoolVarP(&inputCrawlParams.OnlyURLs, "only-urls", "u", true, "only writes enode URLs to output")
+	f.StringVar(&inputCrawlParams.Graph, "graph", "", "file to write the graph of discovery routing tables to")
+	f.StringVar(&inputCrawlParams.GraphFormat, "graph-format", "json", "format of the routing table graph (json, graphml)")
+	f.IntVar(&inputCrawlParams.GraphQueries, "graph-queries", 8, "FINDNODE queries with random targets to sample each routing table")
+	f.StringVar(&inputCrawlParams.TopologyReport, "topology-report", "", "file to write the topology metrics JSON report to")
+	f.IntVar(&inputCrawlParams.Top, "top", 20, "most subnets and nodes listed per topology metric")
+	f.IntVar(&inputCrawlParams.MaxInDegree, "max-in-degree", 2, "in-degree at or below which a node counts as reachable only through few others")
+	f.IntVar(&inputCrawlParams.MinTableSize, "min-table-size", 16, "smallest sampled routing table to compute subnet concentration for")
+	f.StringVar(&inputCrawlParams.Chain, "chain", "",
+		fmt.Sprintf("named network to filter discovered nodes by fork ID (%v)", strings.Join(p2p.ForkNetworks(), ", ")))
+	f.StringVar(&inputCrawlParams.Genesis, "genesis", "", "genesis JSON file to filter discovered nodes by fork ID")
+	CrawlCmd.MarkFlagsMutuallyExclusive("chain", "genesis")
+	f.Uint64Var(&inputCrawlParams.HeadBlock, "head-block", 0, "current block number of the chain for fork ID validation (default past every block fork)")
+	f.BoolVar(&inputCrawlParams.DiscV4, "discv4", true, "discover nodes with discv4")
+	f.BoolVar(&inputCrawlParams.DiscV5, "discv5", false, "discover nodes with discv5")
+	f.StringVar(&inputCrawlParams.BootnodesV5, "bootnodes-v5", "", "comma separated nodes used for bootstrapping discv5 (default --bootnodes)")
 }
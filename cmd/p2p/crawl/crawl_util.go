@@ -2,6 +2,7 @@ package crawl
 
 import (
 	"errors"
+	"fmt"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -24,6 +25,32 @@ type crawler struct {
 	// settings
 	revalidateInterval time.Duration
 	mu                 sync.Mutex
+
+	// topology is set when mapping the routing tables of crawled nodes.
+	topology *topologyMapper
+}
+
+// topologyMapper queries the routing tables of crawled nodes to build the
+// topology graph.
+type topologyMapper struct {
+	client   *p2p.Discv4Client
+	topology *p2p.Topology
+	queries  int
+}
+
+// mapNeighbors records the routing table of the node in the topology, once per
+// node.
+func (m *topologyMapper) mapNeighbors(n *enode.Node) {
+	if !m.topology.MarkQueried(n) {
+		return
+	}
+
+	neighbors, err := m.client.Neighbors(n, m.queries)
+	if err != nil && len(neighbors) == 0 {
+		log.Debug().Err(err).Str("node", n.URLv4()).Msg("Failed to query routing table")
+		return
+	}
+	m.topology.AddNeighbors(n, neighbors)
 }
 
 const (
@@ -150,12 +177,17 @@ func (c *crawler) runIterator(done chan<- enode.Iterator, it enode.Iterator) {
 // what changed.
 func (c *crawler) updateNode(n *enode.Node) int {
 	var (
-		hello  *p2p.Hello
-		status *p2p.Status
-		err    error
-		nodes  int
+		hello      *p2p.Hello
+		status     *p2p.Status
+		err        error
+		nodes      int
+		forkStatus string
 	)
 
+	if c.topology != nil {
+		c.topology.mapNeighbors(n)
+	}
+
 	c.mu.Lock()
 	if _, ok := c.output[n.ID()]; !ok {
 		c.output[n.ID()] = []p2p.NodeJSON{}
@@ -167,11 +199,12 @@ func (c *crawler) updateNode(n *enode.Node) int {
 	defer func() {
 		c.mu.Lock()
 		result := p2p.NodeJSON{
-			URL:    n.URLv4(),
-			Hello:  hello,
-			Status: status,
-			Time:   time.Now().Unix(),
-			Nodes:  nodes,
+			URL:        n.URLv4(),
+			Hello:      hello,
+			Status:     status,
+			Time:       time.Now().Unix(),
+			Nodes:      nodes,
+			ForkStatus: forkStatus,
 		}
 		if err != nil {
 			result.Error = err.Error()
@@ -203,6 +236,13 @@ func (c *crawler) updateNode(n *enode.Node) int {
 		err = errors.New("network ID mismatch")
 		return nodeIncompatible
 	}
+	if forks := inputCrawlParams.forks; forks != nil {
+		forkStatus = forks.Classify(status.ForkID, inputCrawlParams.HeadBlock, uint64(time.Now().Unix()))
+		if forkStatus != p2p.ForkCompatible {
+			err = fmt.Errorf("%s fork ID", forkStatus)
+			return nodeIncompatible
+		}
+	}
 
 	return nodeAdded
 }
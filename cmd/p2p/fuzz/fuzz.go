@@ -0,0 +1,169 @@
+package fuzz
+
+import (
+	"crypto/ecdsa"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/0xPolygon/polygon-cli/p2p"
+)
+
+type (
+	fuzzParams struct {
+		Cases       []string
+		List        bool
+		Timeout     time.Duration
+		RedialDelay time.Duration
+		JSON        bool
+		Port        int
+		Addr        net.IP
+		KeyFile     string
+		PrivateKey  string
+
+		cases      []p2p.FuzzCase
+		privateKey *ecdsa.PrivateKey
+	}
+)
+
+var (
+	//go:embed usage.md
+	fuzzUsage       string
+	inputFuzzParams fuzzParams
+)
+
+// FuzzCmd sends valid, edge case and malformed eth messages to a single peer
+// and checks how it reacts to each of them.
+var FuzzCmd = &cobra.Command{
+	Use:   "fuzz [enode/enr]",
+	Short: "Check how a peer handles valid, edge case and malformed eth messages.",
+	Long:  fuzzUsage,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if inputFuzzParams.List {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	PreRunE: func(cmd *cobra.Command, args []string) (err error) {
+		params := &inputFuzzParams
+
+		if params.cases, err = p2p.SelectFuzzCases(params.Cases); err != nil {
+			return err
+		}
+		if params.Timeout <= 0 {
+			return fmt.Errorf("timeout must be positive")
+		}
+
+		params.privateKey, err = p2p.ParsePrivateKey(params.KeyFile, params.PrivateKey)
+		return err
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		params := &inputFuzzParams
+
+		if params.List {
+			return listCases(os.Stdout, params.cases)
+		}
+
+		node, err := p2p.ParseNode(args[0])
+		if err != nil {
+			return err
+		}
+
+		log.Info().Int("cases", len(params.cases)).Str("peer", node.URLv4()).Msg("Fuzzing peer")
+
+		results, fuzzErr := p2p.Fuzz(node, p2p.FuzzOptions{
+			Dial: p2p.DialOpts{
+				Port:       params.Port,
+				Addr:       params.Addr,
+				PrivateKey: params.privateKey,
+			},
+			Cases:       params.cases,
+			Timeout:     params.Timeout,
+			RedialDelay: params.RedialDelay,
+		})
+		if results == nil {
+			return fuzzErr
+		}
+
+		if params.JSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(results)
+		} else {
+			err = writeText(os.Stdout, results)
+		}
+		if err != nil {
+			return err
+		}
+		if fuzzErr != nil {
+			return fuzzErr
+		}
+
+		var failed int
+		for _, r := range results {
+			if r.Verdict == p2p.FuzzVerdictFail {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d cases failed", failed, len(results))
+		}
+		return nil
+	},
+}
+
+func listCases(w io.Writer, cases []p2p.FuzzCase) error {
+	for _, c := range cases {
+		if _, err := fmt.Fprintf(w, "%-26s  %-10s  %s\n", c.Name, c.Expect, c.Description); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeText(w io.Writer, results []p2p.FuzzResult) error {
+	counts := make(map[string]int)
+	for _, r := range results {
+		counts[r.Verdict]++
+		fmt.Fprintf(w, "%-6s  %-26s  expect %-10s  got %-10s  %6dms  %s\n",
+			r.Verdict, r.Case, r.Expect, r.Outcome, r.Latency, detail(r))
+	}
+
+	_, err := fmt.Fprintf(w, "\n%d passed, %d warnings, %d failed, %d crashed, %d skipped\n",
+		counts[p2p.FuzzVerdictPass], counts[p2p.FuzzVerdictWarn], counts[p2p.FuzzVerdictFail], counts[p2p.FuzzVerdictCrash],
+		counts[p2p.FuzzVerdictSkip])
+	return err
+}
+
+// detail describes the disconnect reason and any error of the result.
+func detail(r p2p.FuzzResult) string {
+	switch {
+	case r.Error != "" && r.Reason != "":
+		return fmt.Sprintf("%s: %s", r.Reason, r.Error)
+	case r.Error != "":
+		return r.Error
+	default:
+		return r.Reason
+	}
+}
+
+func init() {
+	f := FuzzCmd.Flags()
+	f.StringSliceVarP(&inputFuzzParams.Cases, "cases", "c", nil, "comma separated cases to run (default all, see --list)")
+	f.BoolVar(&inputFuzzParams.List, "list", false, "list the available cases and exit")
+	f.DurationVarP(&inputFuzzParams.Timeout, "timeout", "t", 5*time.Second, "how long to wait for the peer to react to each case")
+	f.DurationVar(&inputFuzzParams.RedialDelay, "redial-delay", 30*time.Second, "how long to wait before dialing again after the peer disconnects")
+	f.BoolVar(&inputFuzzParams.JSON, "json", false, "output the results as JSON")
+	f.IntVarP(&inputFuzzParams.Port, "port", "P", 30303, "port for discovery protocol")
+	f.IPVarP(&inputFuzzParams.Addr, "addr", "a", net.ParseIP("127.0.0.1"), "address to bind discovery listener")
+	f.StringVarP(&inputFuzzParams.KeyFile, "key-file", "k", "", "private key file (cannot be set with --key)")
+	f.StringVar(&inputFuzzParams.PrivateKey, "key", "", "hex-encoded private key (cannot be set with --key-file)")
+	FuzzCmd.MarkFlagsMutuallyExclusive("key-file", "key")
+}
@@ -0,0 +1,308 @@
+package mempool
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/0xPolygon/polygon-cli/flag"
+	"github.com/0xPolygon/polygon-cli/p2p"
+)
+
+type (
+	mempoolParams struct {
+		SensorURL string
+		Timeout   time.Duration
+		Top       int
+		JSON      bool
+
+		rpcURL string
+	}
+
+	// txpoolTx is a transaction in a txpool_content response.
+	txpoolTx struct {
+		Hash                 common.Hash    `json:"hash"`
+		From                 common.Address `json:"from"`
+		Nonce                hexutil.Uint64 `json:"nonce"`
+		Type                 hexutil.Uint64 `json:"type"`
+		Gas                  hexutil.Uint64 `json:"gas"`
+		GasPrice             *hexutil.Big   `json:"gasPrice"`
+		MaxFeePerGas         *hexutil.Big   `json:"maxFeePerGas"`
+		MaxPriorityFeePerGas *hexutil.Big   `json:"maxPriorityFeePerGas"`
+	}
+
+	// txpoolContent is a txpool_content response, keyed by sender and nonce.
+	txpoolContent struct {
+		Pending map[common.Address]map[string]*txpoolTx `json:"pending"`
+		Queued  map[common.Address]map[string]*txpoolTx `json:"queued"`
+	}
+)
+
+var (
+	//go:embed usage.md
+	mempoolUsage       string
+	inputMempoolParams mempoolParams
+)
+
+// MempoolCmd snapshots the pending transactions seen by a sensor or held by an
+// RPC node, and diffs two snapshots.
+var MempoolCmd = &cobra.Command{
+	Use:   "mempool [snapshot files]",
+	Short: "Snapshot and diff the mempools seen by sensors and RPC nodes.",
+	Long:  mempoolUsage,
+	Args:  cobra.MaximumNArgs(2),
+	PreRunE: func(cmd *cobra.Command, args []string) (err error) {
+		params := &inputMempoolParams
+
+		if params.rpcURL, err = flag.GetRPCURL(cmd); err != nil {
+			return err
+		}
+
+		sources := len(args)
+		if params.SensorURL != "" {
+			sources++
+		}
+		if params.rpcURL != "" {
+			sources++
+		}
+		if sources == 0 || sources > 2 {
+			return fmt.Errorf("one source to snapshot or two to diff is required, got %d", sources)
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		params := &inputMempoolParams
+		ctx := cmd.Context()
+
+		var snapshots []*p2p.MempoolSnapshot
+		if params.SensorURL != "" {
+			s, err := fetchSensorSnapshot(ctx, params.SensorURL, params.Timeout)
+			if err != nil {
+				return err
+			}
+			snapshots = append(snapshots, s)
+		}
+		if params.rpcURL != "" {
+			s, err := fetchRPCSnapshot(ctx, params.rpcURL, params.Timeout)
+			if err != nil {
+				return err
+			}
+			snapshots = append(snapshots, s)
+		}
+		for _, file := range args {
+			s, err := readSnapshot(file)
+			if err != nil {
+				return err
+			}
+			snapshots = append(snapshots, s)
+		}
+
+		for _, s := range snapshots {
+			log.Info().Str("source", s.Source).Int("txs", s.Txs).Int("senders", len(s.Senders)).Msg("Loaded mempool snapshot")
+		}
+
+		if len(snapshots) == 1 {
+			if params.JSON {
+				return writeJSON(os.Stdout, snapshots[0])
+			}
+			return writeSnapshot(os.Stdout, snapshots[0], params.Top)
+		}
+
+		diff := p2p.DiffMempools(snapshots[0], snapshots[1])
+		if params.JSON {
+			return writeJSON(os.Stdout, diff)
+		}
+		return writeDiff(os.Stdout, diff, params.Top)
+	},
+}
+
+// fetchSensorSnapshot gets the mempool snapshot from a sensor's API.
+func fetchSensorSnapshot(ctx context.Context, url string, timeout time.Duration) (*p2p.MempoolSnapshot, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	url = strings.TrimSuffix(url, "/") + "/mempool"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sensor mempool: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get sensor mempool: %s", resp.Status)
+	}
+
+	var s p2p.MempoolSnapshot
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		return nil, fmt.Errorf("failed to decode sensor mempool: %w", err)
+	}
+	s.Source = url
+	return &s, nil
+}
+
+// fetchRPCSnapshot builds a snapshot from the pending and queued transactions
+// of an RPC node's txpool_content.
+func fetchRPCSnapshot(ctx context.Context, url string, timeout time.Duration) (*p2p.MempoolSnapshot, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	client, err := ethrpc.DialContext(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	var content txpoolContent
+	if err := client.CallContext(ctx, &content, "txpool_content"); err != nil {
+		return nil, fmt.Errorf("failed to get txpool content: %w", err)
+	}
+	t := time.Now()
+
+	var txs []p2p.MempoolTx
+	for _, pool := range []map[common.Address]map[string]*txpoolTx{content.Pending, content.Queued} {
+		for _, byNonce := range pool {
+			for _, tx := range byNonce {
+				if tx != nil {
+					txs = append(txs, tx.mempoolTx())
+				}
+			}
+		}
+	}
+
+	return p2p.NewMempoolSnapshot(url, t, txs), nil
+}
+
+// mempoolTx converts a txpool transaction, using the gas price as the fee and
+// tip caps of legacy transactions like the sensor does.
+func (tx *txpoolTx) mempoolTx() p2p.MempoolTx {
+	feeCap, tipCap := tx.GasPrice, tx.GasPrice
+	if tx.MaxFeePerGas != nil {
+		feeCap = tx.MaxFeePerGas
+	}
+	if tx.MaxPriorityFeePerGas != nil {
+		tipCap = tx.MaxPriorityFeePerGas
+	}
+
+	return p2p.MempoolTx{
+		Hash:      tx.Hash,
+		From:      tx.From,
+		Nonce:     uint64(tx.Nonce),
+		Type:      uint8(tx.Type),
+		Gas:       uint64(tx.Gas),
+		GasFeeCap: feeCap.ToInt(),
+		GasTipCap: tipCap.ToInt(),
+	}
+}
+
+// readSnapshot reads a snapshot written with --json.
+func readSnapshot(file string) (*p2p.MempoolSnapshot, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var s p2p.MempoolSnapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot %s: %w", file, err)
+	}
+	return &s, nil
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// gwei formats a wei amount in gwei.
+func gwei(wei *big.Int) string {
+	if wei == nil {
+		return "-"
+	}
+	f, _ := new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(1e9)).Float64()
+	return fmt.Sprintf("%.2f", f)
+}
+
+func writeSnapshot(w io.Writer, s *p2p.MempoolSnapshot, top int) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Source: %s\nTime: %s\nTransactions: %d\nSenders: %d\n\n",
+		s.Source, s.Time.UTC().Format(time.RFC3339), s.Txs, len(s.Senders))
+
+	fmt.Fprintln(tw, "FEES (GWEI)\tMIN\tMEDIAN\tMAX")
+	fmt.Fprintf(tw, "tip cap\t%s\t%s\t%s\n", gwei(s.Tips.Min), gwei(s.Tips.Median), gwei(s.Tips.Max))
+	fmt.Fprintf(tw, "fee cap\t%s\t%s\t%s\n", gwei(s.FeeCaps.Min), gwei(s.FeeCaps.Median), gwei(s.FeeCaps.Max))
+	fmt.Fprintln(tw)
+
+	fmt.Fprintln(tw, "SENDER\tTXS\tNONCES\tMIN TIP\tMEDIAN TIP\tMAX TIP")
+	for i, sender := range s.Senders {
+		if i == top {
+			break
+		}
+		first, last := sender.Txs[0].Nonce, sender.Txs[len(sender.Txs)-1].Nonce
+		fmt.Fprintf(tw, "%s\t%d\t%d-%d\t%s\t%s\t%s\n", sender.Address.Hex(), len(sender.Txs), first, last,
+			gwei(sender.Tips.Min), gwei(sender.Tips.Median), gwei(sender.Tips.Max))
+	}
+
+	return tw.Flush()
+}
+
+func writeDiff(w io.Writer, d *p2p.MempoolDiff, top int) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "A: %s\nB: %s\n\n", d.A, d.B)
+	fmt.Fprintf(tw, "In both: %d\nOnly in A: %d\nOnly in B: %d\nConflicting: %d\nReplaced in A: %d\nReplaced in B: %d\n\n",
+		d.Common, len(d.OnlyA), len(d.OnlyB), len(d.Conflicts), len(d.ReplacedA), len(d.ReplacedB))
+
+	for _, only := range []struct {
+		name string
+		txs  []p2p.MempoolTx
+	}{{"ONLY IN A", d.OnlyA}, {"ONLY IN B", d.OnlyB}, {"REPLACED IN A", d.ReplacedA}, {"REPLACED IN B", d.ReplacedB}} {
+		fmt.Fprintf(tw, "%s\tFROM\tNONCE\tTIP (GWEI)\n", only.name)
+		for i, tx := range only.txs {
+			if i == top {
+				break
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", tx.Hash.Hex(), tx.From.Hex(), tx.Nonce, gwei(tx.GasTipCap))
+		}
+		fmt.Fprintln(tw)
+	}
+
+	fmt.Fprintln(tw, "CONFLICTING IN A\tIN B\tFROM\tNONCE")
+	for i, c := range d.Conflicts {
+		if i == top {
+			break
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", c.A.Hash.Hex(), c.B.Hash.Hex(), c.A.From.Hex(), c.A.Nonce)
+	}
+
+	return tw.Flush()
+}
+
+func init() {
+	f := MempoolCmd.Flags()
+	f.StringVarP(&inputMempoolParams.SensorURL, "sensor-url", "s", "", "sensor API URL to snapshot, e.g. http://localhost:8080")
+	f.StringP(flag.RPCURL, "r", "", "RPC URL to snapshot with txpool_content")
+	f.DurationVarP(&inputMempoolParams.Timeout, "timeout", "t", 30*time.Second, "timeout for fetching each snapshot")
+	f.IntVar(&inputMempoolParams.Top, "top", 20, "maximum rows per table in the text output (-1 for all)")
+	f.BoolVar(&inputMempoolParams.JSON, "json", false, "output the snapshot or diff as JSON")
+}
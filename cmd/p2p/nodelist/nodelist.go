@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"os"
 
-	"github.com/0xPolygon/polygon-cli/flag"
 	"github.com/0xPolygon/polygon-cli/p2p/database"
 	"github.com/spf13/cobra"
 )
@@ -13,6 +12,7 @@ type (
 	nodeListParams struct {
 		ProjectID  string
 		DatabaseID string
+		PebblePath string
 		OutputFile string
 		Limit      int
 	}
@@ -33,10 +33,20 @@ var NodeListCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := cmd.Context()
 
-		db := database.NewDatastore(cmd.Context(), database.DatastoreOptions{
-			ProjectID:  inputNodeListParams.ProjectID,
-			DatabaseID: inputNodeListParams.DatabaseID,
-		})
+		var db database.Database
+		if inputNodeListParams.PebblePath != "" {
+			pebble, err := database.NewPebble(database.PebbleOptions{Path: inputNodeListParams.PebblePath})
+			if err != nil {
+				return err
+			}
+			defer pebble.Close()
+			db = pebble
+		} else {
+			db = database.NewDatastore(cmd.Context(), database.DatastoreOptions{
+				ProjectID:  inputNodeListParams.ProjectID,
+				DatabaseID: inputNodeListParams.DatabaseID,
+			})
+		}
 
 		nodes, err := db.NodeList(ctx, inputNodeListParams.Limit)
 		if err != nil {
@@ -61,5 +71,7 @@ func init() {
 	f.IntVarP(&inputNodeListParams.Limit, "limit", "l", 100, "number of unique nodes to return")
 	f.StringVarP(&inputNodeListParams.ProjectID, "project-id", "p", "", "GCP project ID")
 	f.StringVarP(&inputNodeListParams.DatabaseID, "database-id", "d", "", "datastore database ID")
-	flag.MarkFlagsRequired(NodeListCmd, "project-id")
+	f.StringVar(&inputNodeListParams.PebblePath, "pebble-path", "", "read from a sensor's embedded Pebble database instead of datastore")
+	NodeListCmd.MarkFlagsOneRequired("project-id", "pebble-path")
+	NodeListCmd.MarkFlagsMutuallyExclusive("project-id", "pebble-path")
 }
@@ -3,11 +3,17 @@ package p2p
 import (
 	"github.com/spf13/cobra"
 
+	"github.com/0xPolygon/polygon-cli/cmd/p2p/analyze"
+	"github.com/0xPolygon/polygon-cli/cmd/p2p/census"
 	"github.com/0xPolygon/polygon-cli/cmd/p2p/crawl"
+	"github.com/0xPolygon/polygon-cli/cmd/p2p/fuzz"
+	"github.com/0xPolygon/polygon-cli/cmd/p2p/mempool"
 	"github.com/0xPolygon/polygon-cli/cmd/p2p/nodelist"
 	"github.com/0xPolygon/polygon-cli/cmd/p2p/ping"
 	"github.com/0xPolygon/polygon-cli/cmd/p2p/query"
+	"github.com/0xPolygon/polygon-cli/cmd/p2p/replay"
 	"github.com/0xPolygon/polygon-cli/cmd/p2p/sensor"
+	"github.com/0xPolygon/polygon-cli/cmd/p2p/tracetx"
 )
 
 var P2pCmd = &cobra.Command{
@@ -16,9 +22,15 @@ var P2pCmd = &cobra.Command{
 }
 
 func init() {
+	P2pCmd.AddCommand(analyze.AnalyzeCmd)
+	P2pCmd.AddCommand(census.CensusCmd)
 	P2pCmd.AddCommand(crawl.CrawlCmd)
+	P2pCmd.AddCommand(fuzz.FuzzCmd)
+	P2pCmd.AddCommand(mempool.MempoolCmd)
 	P2pCmd.AddCommand(nodelist.NodeListCmd)
 	P2pCmd.AddCommand(ping.PingCmd)
 	P2pCmd.AddCommand(sensor.SensorCmd)
 	P2pCmd.AddCommand(query.QueryCmd)
+	P2pCmd.AddCommand(replay.ReplayCmd)
+	P2pCmd.AddCommand(tracetx.TraceTxCmd)
 }
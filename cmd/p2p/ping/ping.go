@@ -3,7 +3,10 @@ package ping
 import (
 	"crypto/ecdsa"
 	_ "embed"
+	"fmt"
+	"math"
 	"net"
+	"strings"
 	"sync"
 	"time"
 
@@ -25,8 +28,13 @@ type (
 		KeyFile    string
 		PrivateKey string
 		EnableWit  bool
+		Chain      string
+		Genesis    string
+		HeadBlock  uint64
+		Discv5     bool
 
 		privateKey *ecdsa.PrivateKey
+		forks      *p2p.ForkSchedule
 	}
 )
 
@@ -47,6 +55,15 @@ var PingCmd = &cobra.Command{
 			return err
 		}
 
+		inputPingParams.forks, err = p2p.LoadForkSchedule(inputPingParams.Chain, inputPingParams.Genesis)
+		if err != nil {
+			return err
+		}
+		if inputPingParams.HeadBlock == 0 {
+			// Without a head, assume every scheduled block fork has passed.
+			inputPingParams.HeadBlock = math.MaxUint64
+		}
+
 		return nil
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -61,6 +78,25 @@ var PingCmd = &cobra.Command{
 
 		output := make(p2p.NodeSet)
 
+		var disc *p2p.Discovery
+		if inputPingParams.Discv5 {
+			db, err := enode.OpenDB("")
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			ln := enode.NewLocalNode(db, inputPingParams.privateKey)
+			disc, err = p2p.ListenDiscovery(ln, p2p.DiscoveryOptions{
+				PrivateKey: inputPingParams.privateKey,
+				V5:         true,
+			})
+			if err != nil {
+				return err
+			}
+			defer disc.Close()
+		}
+
 		var (
 			mutex sync.Mutex
 			wg    sync.WaitGroup
@@ -94,8 +130,14 @@ var PingCmd = &cobra.Command{
 				var (
 					hello  *p2p.Hello
 					status *p2p.Status
+					probe  *p2p.Discv5Probe
 				)
 
+				if disc != nil {
+					probe = p2p.ProbeDiscv5(disc.V5, node, inputPingParams.forks, inputPingParams.HeadBlock)
+					log.Info().Str("node", node.URLv4()).Interface("discv5", probe).Msg("Discv5 probe finished")
+				}
+
 				opts := p2p.DialOpts{
 					EnableWit:  inputPingParams.EnableWit,
 					Port:       inputPingParams.Port,
@@ -137,10 +179,14 @@ var PingCmd = &cobra.Command{
 					Hello:  hello,
 					Status: status,
 					Time:   time.Now().Unix(),
+					Discv5: probe,
 				}
 				if err != nil {
 					result.Error = err.Error()
 				}
+				if inputPingParams.forks != nil && status != nil {
+					result.ForkStatus = inputPingParams.forks.Classify(status.ForkID, inputPingParams.HeadBlock, uint64(time.Now().Unix()))
+				}
 
 				output[node.ID()] = append(output[node.ID()], result)
 				mutex.Unlock()
@@ -165,4 +211,10 @@ argument is an enode/enr, not a nodes file`)
 	f.StringVarP(&inputPingParams.KeyFile, "key-file", "k", "", "private key file (cannot be set with --key)")
 	f.StringVar(&inputPingParams.PrivateKey, "key", "", "hex-encoded private key (cannot be set with --key-file)")
 	PingCmd.MarkFlagsMutuallyExclusive("key-file", "key")
+	f.StringVar(&inputPingParams.Chain, "chain", "",
+		fmt.Sprintf("named network to validate fork IDs against (%v)", strings.Join(p2p.ForkNetworks(), ", ")))
+	f.StringVar(&inputPingParams.Genesis, "genesis", "", "genesis JSON file to validate fork IDs against")
+	PingCmd.MarkFlagsMutuallyExclusive("chain", "genesis")
+	f.BoolVar(&inputPingParams.Discv5, "discv5", false, "also ping the node over discv5 and request its node record")
+	f.Uint64Var(&inputPingParams.HeadBlock, "head-block", 0, "current block number of the chain for fork ID validation (default past every block fork)")
 }
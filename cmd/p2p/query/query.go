@@ -5,11 +5,11 @@ import (
 	"fmt"
 	"net"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 
-	"github.com/0xPolygon/polygon-cli/flag"
 	"github.com/0xPolygon/polygon-cli/p2p"
 )
 
@@ -21,6 +21,10 @@ type (
 		Addr       net.IP
 		KeyFile    string
 		PrivateKey string
+		Snap       bool
+		StateRoot  string
+		SnapOrigin string
+		SnapBytes  uint64
 
 		privateKey *ecdsa.PrivateKey
 	}
@@ -32,18 +36,30 @@ var (
 
 var QueryCmd = &cobra.Command{
 	Use:   "query [enode/enr]",
-	Short: "Query block header(s) from node and prints the output.",
+	Short: "Query block header(s) or snap state from node and prints the output.",
 	Long: `Query header of single block or range of blocks given a single enode/enr.
 	
 This command will initially establish a handshake and exchange status message
 from the peer. Then, it will query the node for block(s) given the start block
-and the amount of blocks to query and print the results.`,
+and the amount of blocks to query and print the results.
+
+With --snap, the peer is also asked over the snap/1 protocol for an account
+range, the storage and bytecodes of contracts in that range and the account
+trie root node of --state-root, defaulting to the state of the peer's head
+block. Each response is verified against the state root and printed with its
+latency, showing whether the peer can serve snap sync and how fast.`,
 	Args: cobra.MinimumNArgs(1),
 	PreRunE: func(cmd *cobra.Command, args []string) (err error) {
 		if inputQueryParams.Amount < 1 {
 			return fmt.Errorf("amount must be greater than 0")
 		}
 
+		for _, h := range []string{inputQueryParams.StateRoot, inputQueryParams.SnapOrigin} {
+			if h != "" && len(common.FromHex(h)) != common.HashLength {
+				return fmt.Errorf("invalid hash %q", h)
+			}
+		}
+
 		inputQueryParams.privateKey, err = p2p.ParsePrivateKey(inputQueryParams.KeyFile, inputQueryParams.PrivateKey)
 		if err != nil {
 			return err
@@ -66,6 +82,7 @@ and the amount of blocks to query and print the results.`,
 		)
 
 		opts := p2p.DialOpts{
+			EnableSnap: inputQueryParams.Snap,
 			Port:       inputQueryParams.Port,
 			Addr:       inputQueryParams.Addr,
 			PrivateKey: inputQueryParams.privateKey,
@@ -87,37 +104,83 @@ and the amount of blocks to query and print the results.`,
 		}
 
 		log.Info().Interface("hello", hello).Interface("status", status).Msg("Peering messages received")
-		log.Info().Uint64("start", start).Uint64("amount", amount).Msg("Requesting headers")
 
-		// Handshake completed, now proceed to query headers
-		if err = conn.QueryHeaders(start, amount); err != nil {
-			log.Error().Err(err).Msg("Failed to request header(s)")
-			return
+		if cmd.Flags().Changed("start-block") {
+			log.Info().Uint64("start", start).Uint64("amount", amount).Msg("Requesting headers")
+
+			// Handshake completed, now proceed to query headers
+			if err = conn.QueryHeaders(start, amount); err != nil {
+				log.Error().Err(err).Msg("Failed to request header(s)")
+				return
+			}
+
+			headers, err := conn.ListenHeaders()
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to listen for header(s)")
+				return
+			}
+
+			// Verify requested headers
+			if len(headers) != int(amount) {
+				log.Error().Uint64("want", amount).Int("have", len(headers)).Msg("Received less headers than requested")
+				return
+			}
+
+			var (
+				headerStart = headers[0].Number.Uint64()
+				headerEnd   = headers[len(headers)-1].Number.Uint64()
+				end         = start + amount - 1
+			)
+			if headerStart != start || headerEnd != end {
+				log.Error().Uint64("start", start).Uint64("end", end).Uint64("header start", headerStart).Uint64("header end", headerEnd).Msg("Received headers out of range")
+				return
+			}
+
+			print(headers)
 		}
 
-		headers, err := conn.ListenHeaders()
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to listen for header(s)")
+		if !inputQueryParams.Snap {
 			return
 		}
 
-		// Verify requested headers
-		if len(headers) != int(amount) {
-			log.Error().Uint64("want", amount).Int("have", len(headers)).Msg("Received less headers than requested")
-			return
+		// Probe the state of the peer's head block unless a root was given.
+		root := common.HexToHash(inputQueryParams.StateRoot)
+		if inputQueryParams.StateRoot == "" {
+			if err = conn.QueryHeaderByHash(status.Head); err != nil {
+				log.Error().Err(err).Msg("Failed to request head header")
+				return
+			}
+
+			headers, err := conn.ListenHeaders()
+			if err != nil || len(headers) != 1 {
+				log.Error().Err(err).Int("have", len(headers)).Msg("Failed to get head header")
+				return
+			}
+			root = headers[0].Root
+
+			log.Info().Uint64("number", headers[0].Number.Uint64()).Str("root", root.Hex()).Msg("Using state root of head block")
 		}
 
-		var (
-			headerStart = headers[0].Number.Uint64()
-			headerEnd   = headers[len(headers)-1].Number.Uint64()
-			end         = start + amount - 1
-		)
-		if headerStart != start || headerEnd != end {
-			log.Error().Uint64("start", start).Uint64("end", end).Uint64("header start", headerStart).Uint64("header end", headerEnd).Msg("Received headers out of range")
+		results, err := conn.ProbeSnap(p2p.SnapProbeOptions{
+			Root:   root,
+			Origin: common.HexToHash(inputQueryParams.SnapOrigin),
+			Bytes:  inputQueryParams.SnapBytes,
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to probe snap")
 			return
 		}
 
-		print(headers)
+		for _, r := range results {
+			log.Info().
+				Str("request", r.Request).
+				Str("result", r.Result).
+				Dur("latency", r.Latency).
+				Int("items", r.Items).
+				Int("bytes", r.Bytes).
+				Err(r.Err).
+				Msg("Snap response")
+		}
 	},
 }
 
@@ -136,6 +199,10 @@ func init() {
 	f.IPVar(&inputQueryParams.Addr, "addr", net.ParseIP("127.0.0.1"), "address to bind discovery listener")
 	f.StringVarP(&inputQueryParams.KeyFile, "key-file", "k", "", "private key file (cannot be set with --key)")
 	f.StringVar(&inputQueryParams.PrivateKey, "key", "", "hex-encoded private key (cannot be set with --key-file)")
+	f.BoolVar(&inputQueryParams.Snap, "snap", false, "negotiate snap/1 and probe the peer's state serving")
+	f.StringVar(&inputQueryParams.StateRoot, "state-root", "", "state root to request with --snap (default the peer's head block state root)")
+	f.StringVar(&inputQueryParams.SnapOrigin, "snap-origin", "", "first account hash of the account range requested with --snap")
+	f.Uint64Var(&inputQueryParams.SnapBytes, "snap-bytes", 512*1024, "soft limit in bytes for each snap response")
 	QueryCmd.MarkFlagsMutuallyExclusive("key-file", "key")
-	flag.MarkFlagsRequired(QueryCmd, "start-block")
+	QueryCmd.MarkFlagsOneRequired("start-block", "snap")
 }
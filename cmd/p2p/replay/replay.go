@@ -0,0 +1,163 @@
+package replay
+
+import (
+	"crypto/ecdsa"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/0xPolygon/polygon-cli/p2p"
+)
+
+type (
+	replayParams struct {
+		Speed      float64
+		Peers      []string
+		Linger     time.Duration
+		JSON       bool
+		Port       int
+		Addr       net.IP
+		KeyFile    string
+		PrivateKey string
+
+		privateKey *ecdsa.PrivateKey
+	}
+)
+
+var (
+	//go:embed usage.md
+	replayUsage       string
+	inputReplayParams replayParams
+)
+
+// ReplayCmd plays the messages a sensor captured back to a node as a peer.
+var ReplayCmd = &cobra.Command{
+	Use:   "replay [capture file] [enode/enr]",
+	Short: "Replay messages captured by a sensor to a node as a synthetic peer.",
+	Long:  replayUsage,
+	Args:  cobra.ExactArgs(2),
+	PreRunE: func(cmd *cobra.Command, args []string) (err error) {
+		params := &inputReplayParams
+
+		if params.Speed < 0 {
+			return fmt.Errorf("speed must not be negative")
+		}
+		if params.Linger < 0 {
+			return fmt.Errorf("linger must not be negative")
+		}
+
+		params.privateKey, err = p2p.ParsePrivateKey(params.KeyFile, params.PrivateKey)
+		return err
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		params := &inputReplayParams
+
+		records, err := p2p.ReadCapture(args[0])
+		if err != nil {
+			return err
+		}
+
+		node, err := p2p.ParseNode(args[1])
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
+		log.Info().
+			Int("records", len(records)).
+			Float64("speed", params.Speed).
+			Str("peer", node.URLv4()).
+			Msg("Replaying capture")
+
+		stats, replayErr := p2p.Replay(ctx, node, records, p2p.ReplayOptions{
+			Dial: p2p.DialOpts{
+				Port:       params.Port,
+				Addr:       params.Addr,
+				PrivateKey: params.privateKey,
+			},
+			Speed:  params.Speed,
+			Peers:  params.Peers,
+			Linger: params.Linger,
+		})
+		if stats == nil {
+			return replayErr
+		}
+
+		if params.JSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(stats)
+		} else {
+			err = writeText(os.Stdout, stats)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Interrupting the replay isn't a failure, the stats cover what was
+		// sent until then.
+		if replayErr != nil && ctx.Err() == nil {
+			return replayErr
+		}
+		return nil
+	},
+}
+
+func writeText(w io.Writer, stats *p2p.ReplayStats) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Version\teth/%d\n", stats.Version)
+	fmt.Fprintf(tw, "Elapsed\t%s\n", time.Duration(stats.Elapsed)*time.Millisecond)
+	if stats.Reason != "" {
+		fmt.Fprintf(tw, "Disconnected\t%s\n", stats.Reason)
+	}
+
+	fmt.Fprintln(tw, "\nSENT\tCOUNT")
+	writeCounts(tw, stats.Sent)
+	fmt.Fprintf(tw, "skipped\t%d\n", stats.Skipped)
+
+	fmt.Fprintln(tw, "\nSERVED\tCOUNT")
+	writeCounts(tw, stats.Served)
+	fmt.Fprintf(tw, "missing\t%d\n", stats.Missing)
+
+	return tw.Flush()
+}
+
+// writeCounts writes the counts sorted by message name.
+func writeCounts(w io.Writer, counts map[string]int) {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		fmt.Fprintf(w, "%s\t%d\n", name, counts[name])
+	}
+}
+
+func init() {
+	f := ReplayCmd.Flags()
+	f.Float64VarP(&inputReplayParams.Speed, "speed", "s", 1, "scale of the captured timing, 2 replays twice as fast (0 sends as fast as possible)")
+	f.StringSliceVar(&inputReplayParams.Peers, "peer", nil, "comma separated node IDs whose messages are replayed (default all)")
+	f.DurationVar(&inputReplayParams.Linger, "linger", 10*time.Second, "how long to keep answering the node's requests after the last message")
+	f.BoolVar(&inputReplayParams.JSON, "json", false, "output the stats as JSON")
+	f.IntVarP(&inputReplayParams.Port, "port", "P", 30303, "port for discovery protocol")
+	f.IPVarP(&inputReplayParams.Addr, "addr", "a", net.ParseIP("127.0.0.1"), "address to bind discovery listener")
+	f.StringVarP(&inputReplayParams.KeyFile, "key-file", "k", "", "private key file (cannot be set with --key)")
+	f.StringVar(&inputReplayParams.PrivateKey, "key", "", "hex-encoded private key (cannot be set with --key-file)")
+	ReplayCmd.MarkFlagsMutuallyExclusive("key-file", "key")
+}
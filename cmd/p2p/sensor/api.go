@@ -26,6 +26,76 @@ type peerData struct {
 	PacketsSent     p2p.MessageCount `json:"packets_sent"`
 	ConnectedAt     string           `json:"connected_at"`
 	DurationSeconds float64          `json:"duration_seconds"`
+	Snap            []snapData       `json:"snap,omitempty"`
+	Score           *scoreData       `json:"score,omitempty"`
+}
+
+// scoreData represents a peer's score and the statistics it is computed from.
+type scoreData struct {
+	Score          float64 `json:"score"`
+	Useful         float64 `json:"useful"`
+	Timeliness     float64 `json:"timeliness"`
+	Responsiveness float64 `json:"responsiveness"`
+	Blocks         uint64  `json:"blocks"`
+	DelayMs        float64 `json:"delay_ms"`
+	Invalid        uint64  `json:"invalid"`
+	Requests       uint64  `json:"requests"`
+	Responses      uint64  `json:"responses"`
+	ResponseTimeMs float64 `json:"response_time_ms"`
+}
+
+// newScoreData converts a peer score for the API. Returns nil if scoring is
+// disabled.
+func newScoreData(score p2p.PeerScore, ok bool) *scoreData {
+	if !ok {
+		return nil
+	}
+
+	return &scoreData{
+		Score:          score.Score,
+		Useful:         score.Useful,
+		Timeliness:     score.Timeliness,
+		Responsiveness: score.Responsiveness,
+		Blocks:         score.Blocks,
+		DelayMs:        float64(score.Delay) / float64(time.Millisecond),
+		Invalid:        score.Invalid,
+		Requests:       score.Requests,
+		Responses:      score.Responses,
+		ResponseTimeMs: float64(score.ResponseTime) / float64(time.Millisecond),
+	}
+}
+
+// snapData represents the outcome of a snap request in the latest probe of a
+// peer.
+type snapData struct {
+	Request   string  `json:"request"`
+	Result    string  `json:"result"`
+	LatencyMs float64 `json:"latency_ms"`
+	Items     int     `json:"items"`
+	Bytes     int     `json:"bytes"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// newSnapData converts snap probe results for the API.
+func newSnapData(results []p2p.SnapResult) []snapData {
+	if len(results) == 0 {
+		return nil
+	}
+
+	data := make([]snapData, len(results))
+	for i, r := range results {
+		data[i] = snapData{
+			Request:   r.Request,
+			Result:    r.Result,
+			LatencyMs: float64(r.Latency) / float64(time.Millisecond),
+			Items:     r.Items,
+			Bytes:     r.Bytes,
+		}
+		if r.Err != nil {
+			data[i].Error = r.Err.Error()
+		}
+	}
+	return data
 }
 
 // blockInfo represents basic block information.
@@ -56,13 +126,30 @@ type apiData struct {
 	Peers     map[string]peerData `json:"peers"`
 	Head      *blockInfo          `json:"head_block"`
 	Oldest    *blockInfo          `json:"oldest_block"`
+	// ClockOffsetMs is only set when --clock-reference is.
+	ClockOffsetMs *float64 `json:"clock_offset_ms,omitempty"`
 }
 
 // handleAPI sets up the API for interacting with the sensor. All endpoints
 // return information about the sensor node and all connected peers, including
-// the types and counts of eth packets sent and received by each peer.
-func handleAPI(server *ethp2p.Server, conns *p2p.Conns) {
+// the types and counts of eth packets sent and received by each peer, except
+// /time which other sensors probe to estimate their clock offsets, and
+// /mempool which returns a snapshot of the pending transactions the sensor
+// has seen.
+func handleAPI(server *ethp2p.Server, conns *p2p.Conns, clock *p2p.ClockSync) {
 	mux := http.NewServeMux()
+	mux.HandleFunc("/time", p2p.ClockHandler(clock))
+	mux.HandleFunc("/mempool", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(conns.MempoolSnapshot()); err != nil {
+			log.Error().Err(err).Msg("Failed to encode mempool snapshot")
+		}
+	})
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -105,6 +192,8 @@ func handleAPI(server *ethp2p.Server, conns *p2p.Conns) {
 				PacketsSent:     messages.PacketsSent,
 				ConnectedAt:     connectedAt.UTC().Format(time.RFC3339),
 				DurationSeconds: time.Since(connectedAt).Seconds(),
+				Snap:            newSnapData(conns.GetPeerSnapResults(peerID)),
+				Score:           newScoreData(conns.GetPeerScore(peerID)),
 			}
 		}
 
@@ -125,6 +214,10 @@ func handleAPI(server *ethp2p.Server, conns *p2p.Conns) {
 			Head:      newBlockInfo(headHeader),
 			Oldest:    newBlockInfo(oldest),
 		}
+		if clock != nil {
+			offset := float64(clock.Offset()) / float64(time.Millisecond)
+			data.ClockOffsetMs = &offset
+		}
 
 		if err := json.NewEncoder(w).Encode(data); err != nil {
 			log.Error().Err(err).Msg("Failed to encode sensor data")
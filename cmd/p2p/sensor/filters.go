@@ -0,0 +1,498 @@
+package sensor
+
+import (
+	"crypto/rand"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/0xPolygon/polygon-cli/p2p"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	// filterTimeout is how long a filter is kept without being polled.
+	filterTimeout = 5 * time.Minute
+	// maxFilterChanges is how many changes a filter keeps between polls. Older
+	// changes are dropped.
+	maxFilterChanges = 10000
+	// maxFilterTopics is the number of topic positions a log can have.
+	maxFilterTopics = 4
+	// maxFilters is the maximum number of installed filters across clients.
+	maxFilters = 1024
+)
+
+// logFilter selects logs by block, address and topics, following the
+// eth_getLogs filter object.
+type logFilter struct {
+	blockHash *common.Hash
+	// fromBlock and toBlock are nil for the head block.
+	fromBlock *uint64
+	toBlock   *uint64
+	addresses []common.Address
+	// topics holds the accepted topics per position, where an empty position
+	// accepts any topic.
+	topics [][]common.Hash
+}
+
+// parseLogFilter parses a filter object. A nil filter matches every log.
+func parseLogFilter(param any) (*logFilter, *rpcError) {
+	invalid := func(msg string) (*logFilter, *rpcError) {
+		return nil, &rpcError{Code: -32602, Message: msg}
+	}
+
+	f := &logFilter{}
+	if param == nil {
+		return f, nil
+	}
+
+	obj, ok := param.(map[string]any)
+	if !ok {
+		return invalid("invalid filter parameter")
+	}
+
+	if v, ok := obj["blockHash"]; ok && v != nil {
+		if obj["fromBlock"] != nil || obj["toBlock"] != nil {
+			return invalid("cannot specify both blockHash and fromBlock/toBlock")
+		}
+		hash, err := parseHashParam(v)
+		if err != nil {
+			return invalid("invalid blockHash: " + err.Message)
+		}
+		f.blockHash = &hash
+	}
+
+	for key, dst := range map[string]**uint64{"fromBlock": &f.fromBlock, "toBlock": &f.toBlock} {
+		v, ok := obj[key]
+		if !ok || v == nil {
+			continue
+		}
+		tag, ok := v.(string)
+		if !ok {
+			return invalid("invalid " + key)
+		}
+		switch tag {
+		case "latest", "pending", "safe", "finalized":
+		case "earliest":
+			*dst = new(uint64)
+		default:
+			num, err := hexutil.DecodeUint64(tag)
+			if err != nil {
+				return invalid("invalid " + key + ": " + err.Error())
+			}
+			*dst = &num
+		}
+	}
+
+	switch v := obj["address"].(type) {
+	case nil:
+	case string:
+		if !common.IsHexAddress(v) {
+			return invalid("invalid address: " + v)
+		}
+		f.addresses = []common.Address{common.HexToAddress(v)}
+	case []any:
+		for _, a := range v {
+			s, ok := a.(string)
+			if !ok || !common.IsHexAddress(s) {
+				return invalid("invalid address in list")
+			}
+			f.addresses = append(f.addresses, common.HexToAddress(s))
+		}
+	default:
+		return invalid("invalid address")
+	}
+
+	if v, ok := obj["topics"]; ok && v != nil {
+		topics, ok := v.([]any)
+		if !ok || len(topics) > maxFilterTopics {
+			return invalid("invalid topics")
+		}
+		f.topics = make([][]common.Hash, len(topics))
+		for i, t := range topics {
+			switch t := t.(type) {
+			case nil:
+			case string:
+				hash, err := parseHashParam(t)
+				if err != nil {
+					return invalid("invalid topic: " + err.Message)
+				}
+				f.topics[i] = []common.Hash{hash}
+			case []any:
+				for _, alt := range t {
+					// A null alternative accepts any topic at the position.
+					if alt == nil {
+						f.topics[i] = nil
+						break
+					}
+					hash, err := parseHashParam(alt)
+					if err != nil {
+						return invalid("invalid topic: " + err.Message)
+					}
+					f.topics[i] = append(f.topics[i], hash)
+				}
+			default:
+				return invalid("invalid topics")
+			}
+		}
+	}
+
+	return f, nil
+}
+
+// parseHashParam parses a 32 byte hex hash.
+func parseHashParam(v any) (common.Hash, *rpcError) {
+	s, ok := v.(string)
+	if !ok {
+		return common.Hash{}, &rpcError{Code: -32602, Message: "hash must be a hex string"}
+	}
+	b, err := hexutil.Decode(s)
+	if err != nil || len(b) != common.HashLength {
+		return common.Hash{}, &rpcError{Code: -32602, Message: "hash must be 32 hex encoded bytes"}
+	}
+	return common.BytesToHash(b), nil
+}
+
+// matches reports whether a log is selected by the filter. A nil fromBlock or
+// toBlock doesn't bound the range, since the head moves while a filter is
+// installed.
+func (f *logFilter) matches(l *types.Log) bool {
+	if f.blockHash != nil && l.BlockHash != *f.blockHash {
+		return false
+	}
+	if f.fromBlock != nil && l.BlockNumber < *f.fromBlock {
+		return false
+	}
+	if f.toBlock != nil && l.BlockNumber > *f.toBlock {
+		return false
+	}
+	if len(f.addresses) > 0 && !slices.Contains(f.addresses, l.Address) {
+		return false
+	}
+	if len(f.topics) > len(l.Topics) {
+		return false
+	}
+	for i, accepted := range f.topics {
+		if len(accepted) > 0 && !slices.Contains(accepted, l.Topics[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterLogs returns the logs of canonical blocks that match the filter,
+// ordered by block number and log index. The canonical chain is followed back
+// from the head through the blocks cache, and every block of the range must be
+// cached with its receipts. Unset block bounds default to the head block.
+func filterLogs(f *logFilter, conns *p2p.Conns) ([]*types.Log, *rpcError) {
+	logs := []*types.Log{}
+
+	if f.blockHash != nil {
+		cache, ok := conns.Blocks().Get(*f.blockHash)
+		if !ok {
+			return nil, &rpcError{Code: -32000, Message: "unknown block"}
+		}
+		if cache.Receipts == nil {
+			return nil, &rpcError{Code: -32000, Message: "receipts of block not cached"}
+		}
+		return appendMatchingLogs(logs, f, cache.Receipts), nil
+	}
+
+	head := conns.HeadBlock().Block
+	if head == nil {
+		return logs, nil
+	}
+	from, to := head.NumberU64(), head.NumberU64()
+	if f.fromBlock != nil {
+		from = *f.fromBlock
+	}
+	if f.toBlock != nil {
+		to = *f.toBlock
+	}
+	if from > to {
+		return nil, &rpcError{Code: -32602, Message: "fromBlock is after toBlock"}
+	}
+	if to > head.NumberU64() {
+		to = head.NumberU64()
+	}
+	if from > to {
+		return logs, nil
+	}
+
+	// Walk back from the head, collecting the receipts of the range from the
+	// newest block to the oldest.
+	var receipts []types.Receipts
+	hash := head.Hash()
+	for number := head.NumberU64(); ; number-- {
+		cache, ok := conns.Blocks().Peek(hash)
+		if !ok || cache.Header == nil || (number <= to && cache.Receipts == nil) {
+			return nil, &rpcError{Code: -32000, Message: fmt.Sprintf("block %d is not cached with its receipts", number)}
+		}
+		if number <= to {
+			receipts = append(receipts, cache.Receipts)
+		}
+		if number == from {
+			break
+		}
+		hash = cache.Header.ParentHash
+	}
+
+	for i := len(receipts) - 1; i >= 0; i-- {
+		logs = appendMatchingLogs(logs, f, receipts[i])
+	}
+	return logs, nil
+}
+
+// appendMatchingLogs appends the logs of the receipts that match the filter.
+func appendMatchingLogs(logs []*types.Log, f *logFilter, receipts types.Receipts) []*types.Log {
+	for _, r := range receipts {
+		for _, l := range r.Logs {
+			if f.matches(l) {
+				logs = append(logs, l)
+			}
+		}
+	}
+	return logs
+}
+
+// getLogs handles eth_getLogs.
+func getLogs(req rpcRequest, conns *p2p.Conns) (any, *rpcError) {
+	if len(req.Params) < 1 {
+		return nil, &rpcError{Code: -32602, Message: "missing filter parameter"}
+	}
+
+	f, err := parseLogFilter(req.Params[0])
+	if err != nil {
+		return nil, err
+	}
+	return filterLogs(f, conns)
+}
+
+type filterKind int
+
+const (
+	logsFilter filterKind = iota
+	blocksFilter
+	txsFilter
+)
+
+// filter holds the changes of an installed filter since it was last polled.
+type filter struct {
+	kind     filterKind
+	logs     *logFilter
+	hashes   []common.Hash
+	found    []*types.Log
+	lastPoll time.Time
+}
+
+// filterManager tracks the filters installed with eth_newFilter,
+// eth_newBlockFilter and eth_newPendingTransactionFilter, and collects their
+// changes from the sensor's feeds.
+type filterManager struct {
+	conns   *p2p.Conns
+	filters map[string]*filter
+	mu      sync.Mutex
+}
+
+// newFilterManager creates a filter manager and starts collecting changes.
+func newFilterManager(conns *p2p.Conns) *filterManager {
+	m := &filterManager{
+		conns:   conns,
+		filters: make(map[string]*filter),
+	}
+	go m.loop()
+	return m
+}
+
+// loop appends new heads, logs and transactions to the installed filters and
+// removes filters that haven't been polled for filterTimeout.
+func (m *filterManager) loop() {
+	heads, unsubHeads := m.conns.SubscribeHeads()
+	defer unsubHeads()
+	logs, unsubLogs := m.conns.SubscribeLogs()
+	defer unsubLogs()
+	txs, unsubTxs := m.conns.SubscribeTxs()
+	defer unsubTxs()
+
+	ticker := time.NewTicker(filterTimeout / 5)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case header := <-heads:
+			m.each(blocksFilter, func(f *filter) {
+				f.hashes = appendCapped(f.hashes, header.Hash())
+			})
+		case batch := <-logs:
+			m.each(logsFilter, func(f *filter) {
+				for _, l := range batch {
+					if f.logs.matches(l) {
+						f.found = appendCapped(f.found, l)
+					}
+				}
+			})
+		case batch := <-txs:
+			m.each(txsFilter, func(f *filter) {
+				for _, tx := range batch {
+					f.hashes = appendCapped(f.hashes, tx.Hash())
+				}
+			})
+		case now := <-ticker.C:
+			m.expire(now)
+		}
+	}
+}
+
+// expire removes the filters that haven't been polled for filterTimeout.
+func (m *filterManager) expire(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, f := range m.filters {
+		if now.Sub(f.lastPoll) > filterTimeout {
+			delete(m.filters, id)
+		}
+	}
+}
+
+// each calls fn for every installed filter of a kind.
+func (m *filterManager) each(kind filterKind, fn func(*filter)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, f := range m.filters {
+		if f.kind == kind {
+			fn(f)
+		}
+	}
+}
+
+// appendCapped appends v, dropping the oldest values beyond maxFilterChanges.
+func appendCapped[T any](s []T, v T) []T {
+	s = append(s, v)
+	if len(s) > maxFilterChanges {
+		s = s[len(s)-maxFilterChanges:]
+	}
+	return s
+}
+
+// newID returns a random subscription or filter ID.
+func newID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hexutil.Encode(b[:])
+}
+
+// install adds a filter and returns its ID.
+func (m *filterManager) install(f *filter) (string, *rpcError) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.filters) >= maxFilters {
+		return "", &rpcError{Code: -32000, Message: "too many filters"}
+	}
+
+	id := newID()
+	f.lastPoll = time.Now()
+	m.filters[id] = f
+	return id, nil
+}
+
+// newFilter handles eth_newFilter.
+func (m *filterManager) newFilter(req rpcRequest) (any, *rpcError) {
+	var param any
+	if len(req.Params) > 0 {
+		param = req.Params[0]
+	}
+
+	logs, err := parseLogFilter(param)
+	if err != nil {
+		return nil, err
+	}
+	return m.install(&filter{kind: logsFilter, logs: logs})
+}
+
+// filterIDParam parses the filter ID of a request.
+func filterIDParam(req rpcRequest) (string, *rpcError) {
+	if len(req.Params) < 1 {
+		return "", &rpcError{Code: -32602, Message: "missing filter ID parameter"}
+	}
+	id, ok := req.Params[0].(string)
+	if !ok {
+		return "", &rpcError{Code: -32602, Message: "invalid filter ID parameter"}
+	}
+	return id, nil
+}
+
+// getFilterChanges handles eth_getFilterChanges, returning block or
+// transaction hashes, or logs, since the last poll.
+func (m *filterManager) getFilterChanges(req rpcRequest) (any, *rpcError) {
+	id, err := filterIDParam(req)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, ok := m.filters[id]
+	if !ok {
+		return nil, &rpcError{Code: -32000, Message: "filter not found"}
+	}
+	f.lastPoll = time.Now()
+
+	if f.kind == logsFilter {
+		logs := f.found
+		f.found = nil
+		if logs == nil {
+			logs = []*types.Log{}
+		}
+		return logs, nil
+	}
+
+	hashes := f.hashes
+	f.hashes = nil
+	if hashes == nil {
+		hashes = []common.Hash{}
+	}
+	return hashes, nil
+}
+
+// getFilterLogs handles eth_getFilterLogs, returning every cached log that
+// matches a log filter.
+func (m *filterManager) getFilterLogs(req rpcRequest) (any, *rpcError) {
+	id, err := filterIDParam(req)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	f, ok := m.filters[id]
+	if ok {
+		f.lastPoll = time.Now()
+	}
+	m.mu.Unlock()
+
+	if !ok || f.kind != logsFilter {
+		return nil, &rpcError{Code: -32000, Message: "filter not found"}
+	}
+	return filterLogs(f.logs, m.conns)
+}
+
+// uninstallFilter handles eth_uninstallFilter.
+func (m *filterManager) uninstallFilter(req rpcRequest) (any, *rpcError) {
+	id, err := filterIDParam(req)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.filters[id]
+	delete(m.filters, id)
+	return ok, nil
+}
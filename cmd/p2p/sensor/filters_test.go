@@ -0,0 +1,261 @@
+package sensor
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-cli/p2p"
+	ds "github.com/0xPolygon/polygon-cli/p2p/datastructures"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	addrA  = common.HexToAddress("0x000000000000000000000000000000000000000a")
+	addrB  = common.HexToAddress("0x000000000000000000000000000000000000000b")
+	topic1 = common.HexToHash("0x01")
+	topic2 = common.HexToHash("0x02")
+)
+
+func uint64Ptr(v uint64) *uint64 {
+	return &v
+}
+
+func TestParseLogFilter(t *testing.T) {
+	hash := "0x" + common.Bytes2Hex(topic1[:])
+
+	tests := []struct {
+		name    string
+		param   any
+		want    *logFilter
+		wantErr bool
+	}{
+		{name: "nil", param: nil, want: &logFilter{}},
+		{name: "empty", param: map[string]any{}, want: &logFilter{}},
+		{name: "not an object", param: "latest", wantErr: true},
+		{
+			name:  "block tags",
+			param: map[string]any{"fromBlock": "earliest", "toBlock": "latest"},
+			want:  &logFilter{fromBlock: uint64Ptr(0)},
+		},
+		{
+			name:  "block numbers",
+			param: map[string]any{"fromBlock": "0x10", "toBlock": "0x20"},
+			want:  &logFilter{fromBlock: uint64Ptr(16), toBlock: uint64Ptr(32)},
+		},
+		{name: "pending and safe", param: map[string]any{"fromBlock": "safe", "toBlock": "pending"}, want: &logFilter{}},
+		{name: "invalid block number", param: map[string]any{"fromBlock": "16"}, wantErr: true},
+		{name: "block number not a string", param: map[string]any{"toBlock": 16.0}, wantErr: true},
+		{
+			name:  "block hash",
+			param: map[string]any{"blockHash": hash},
+			want:  &logFilter{blockHash: &topic1},
+		},
+		{name: "block hash and range", param: map[string]any{"blockHash": hash, "fromBlock": "0x1"}, wantErr: true},
+		{name: "short block hash", param: map[string]any{"blockHash": "0x01"}, wantErr: true},
+		{
+			name:  "single address",
+			param: map[string]any{"address": addrA.Hex()},
+			want:  &logFilter{addresses: []common.Address{addrA}},
+		},
+		{
+			name:  "address list",
+			param: map[string]any{"address": []any{addrA.Hex(), addrB.Hex()}},
+			want:  &logFilter{addresses: []common.Address{addrA, addrB}},
+		},
+		{name: "invalid address", param: map[string]any{"address": "0x01"}, wantErr: true},
+		{name: "invalid address in list", param: map[string]any{"address": []any{addrA.Hex(), 1.0}}, wantErr: true},
+		{
+			name:  "topics",
+			param: map[string]any{"topics": []any{topic1.Hex(), nil, []any{topic1.Hex(), topic2.Hex()}}},
+			want:  &logFilter{topics: [][]common.Hash{{topic1}, nil, {topic1, topic2}}},
+		},
+		{
+			name:  "null alternative accepts any topic",
+			param: map[string]any{"topics": []any{[]any{topic1.Hex(), nil}}},
+			want:  &logFilter{topics: [][]common.Hash{nil}},
+		},
+		{name: "too many topics", param: map[string]any{"topics": []any{nil, nil, nil, nil, nil}}, wantErr: true},
+		{name: "invalid topic", param: map[string]any{"topics": []any{"0x01"}}, wantErr: true},
+		{name: "nested topic list", param: map[string]any{"topics": []any{[]any{[]any{topic1.Hex()}}}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLogFilter(tt.param)
+			if tt.wantErr {
+				require.NotNil(t, err)
+				require.Equal(t, -32602, err.Code)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// testChain caches blocks 1 to 5 with one log each, where block 2 has no
+// receipts, and a fork of block 4 that isn't canonical. Logs of odd blocks are
+// emitted by addrA and of even blocks by addrB.
+func testChain(t *testing.T) (*p2p.Conns, map[string]common.Hash) {
+	t.Helper()
+
+	var (
+		blocks  = make(map[common.Hash]p2p.BlockCache)
+		hashes  = make(map[string]common.Hash)
+		parent  common.Hash
+		headers []*types.Header
+	)
+	add := func(name string, number uint64, parent common.Hash, withReceipts bool) common.Hash {
+		header := &types.Header{Number: new(big.Int).SetUint64(number), ParentHash: parent, Extra: []byte(name)}
+		hash := header.Hash()
+		address := addrA
+		if number%2 == 0 {
+			address = addrB
+		}
+		cache := p2p.BlockCache{Header: header}
+		if withReceipts {
+			cache.Receipts = types.Receipts{{Logs: []*types.Log{{
+				Address:     address,
+				Topics:      []common.Hash{topic1},
+				BlockNumber: number,
+				BlockHash:   hash,
+			}}}}
+		}
+		blocks[hash] = cache
+		hashes[name] = hash
+		headers = append(headers, header)
+		return hash
+	}
+
+	for number := uint64(1); number <= 5; number++ {
+		parent = add(string(rune('0'+number)), number, parent, number != 2)
+	}
+	add("4'", 4, hashes["3"], true)
+
+	conns := p2p.NewConns(p2p.ConnsOptions{
+		Head:        p2p.NewBlockPacket{Block: types.NewBlockWithHeader(headers[4]), TD: big.NewInt(5)},
+		BlocksCache: ds.LRUOptions{MaxSize: 100},
+	})
+	for hash, cache := range blocks {
+		conns.Blocks().Add(hash, cache)
+	}
+	return conns, hashes
+}
+
+func TestFilterLogs(t *testing.T) {
+	conns, hashes := testChain(t)
+	hash := func(name string) *common.Hash {
+		h := hashes[name]
+		return &h
+	}
+
+	tests := []struct {
+		name    string
+		filter  logFilter
+		want    []string
+		wantErr bool
+	}{
+		{name: "head", filter: logFilter{}, want: []string{"5"}},
+		{name: "canonical range", filter: logFilter{fromBlock: uint64Ptr(3)}, want: []string{"3", "4", "5"}},
+		{name: "range past head", filter: logFilter{fromBlock: uint64Ptr(4), toBlock: uint64Ptr(100)}, want: []string{"4", "5"}},
+		{name: "range after head", filter: logFilter{fromBlock: uint64Ptr(50), toBlock: uint64Ptr(100)}, want: nil},
+		{name: "address", filter: logFilter{fromBlock: uint64Ptr(3), addresses: []common.Address{addrA}}, want: []string{"3", "5"}},
+		{name: "topic", filter: logFilter{fromBlock: uint64Ptr(3), topics: [][]common.Hash{{topic2}}}, want: nil},
+		{name: "block without receipts", filter: logFilter{fromBlock: uint64Ptr(2), toBlock: uint64Ptr(3)}, wantErr: true},
+		{name: "block not cached", filter: logFilter{fromBlock: uint64Ptr(0), toBlock: uint64Ptr(1)}, wantErr: true},
+		{name: "inverted range", filter: logFilter{fromBlock: uint64Ptr(4), toBlock: uint64Ptr(3)}, wantErr: true},
+		{name: "block hash", filter: logFilter{blockHash: hash("3")}, want: []string{"3"}},
+		{name: "fork by block hash", filter: logFilter{blockHash: hash("4'")}, want: []string{"4'"}},
+		{name: "block hash without receipts", filter: logFilter{blockHash: hash("2")}, wantErr: true},
+		{name: "unknown block hash", filter: logFilter{blockHash: &common.Hash{0xff}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs, err := filterLogs(&tt.filter, conns)
+			if tt.wantErr {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+			got := []common.Hash{}
+			for _, l := range logs {
+				got = append(got, l.BlockHash)
+			}
+			want := []common.Hash{}
+			for _, name := range tt.want {
+				want = append(want, hashes[name])
+			}
+			require.Equal(t, want, got)
+		})
+	}
+}
+
+func TestFilterChanges(t *testing.T) {
+	m := &filterManager{filters: make(map[string]*filter)}
+	changes := func(id string) any {
+		t.Helper()
+		result, err := m.getFilterChanges(rpcRequest{Params: []any{id}})
+		require.Nil(t, err)
+		return result
+	}
+
+	blocks, err := m.install(&filter{kind: blocksFilter})
+	require.Nil(t, err)
+	logs, err := m.newFilter(rpcRequest{Params: []any{map[string]any{"address": addrA.Hex()}}})
+	require.Nil(t, err)
+
+	m.each(blocksFilter, func(f *filter) {
+		f.hashes = appendCapped(f.hashes, common.Hash{1})
+		f.hashes = appendCapped(f.hashes, common.Hash{2})
+	})
+	m.each(logsFilter, func(f *filter) {
+		for _, l := range []*types.Log{{Address: addrA}, {Address: addrB}} {
+			if f.logs.matches(l) {
+				f.found = appendCapped(f.found, l)
+			}
+		}
+	})
+
+	// Changes are returned once, after which the filter is drained.
+	require.Equal(t, []common.Hash{{1}, {2}}, changes(blocks))
+	require.Equal(t, []common.Hash{}, changes(blocks))
+	require.Equal(t, []*types.Log{{Address: addrA}}, changes(logs.(string)))
+	require.Equal(t, []*types.Log{}, changes(logs.(string)))
+
+	// Filters not polled for filterTimeout expire.
+	m.filters[blocks].lastPoll = time.Now().Add(-2 * filterTimeout)
+	m.expire(time.Now())
+	_, rpcErr := m.getFilterChanges(rpcRequest{Params: []any{blocks}})
+	require.NotNil(t, rpcErr)
+	require.Len(t, m.filters, 1)
+
+	removed, err := m.uninstallFilter(rpcRequest{Params: []any{logs}})
+	require.Nil(t, err)
+	require.Equal(t, true, removed)
+	require.Empty(t, m.filters)
+}
+
+func TestMaxFilters(t *testing.T) {
+	m := &filterManager{filters: make(map[string]*filter)}
+	for range maxFilters {
+		_, err := m.install(&filter{kind: txsFilter})
+		require.Nil(t, err)
+	}
+
+	_, err := m.install(&filter{kind: txsFilter})
+	require.NotNil(t, err)
+	require.Equal(t, "too many filters", err.Message)
+
+	// Expired filters make room again.
+	for _, f := range m.filters {
+		f.lastPoll = time.Now().Add(-2 * filterTimeout)
+		break
+	}
+	m.expire(time.Now())
+	_, err = m.install(&filter{kind: txsFilter})
+	require.Nil(t, err)
+}
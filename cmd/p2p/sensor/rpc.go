@@ -54,6 +54,7 @@ type rpcParams struct {
 	gpo      *p2p.GasPriceOracle
 	proxy    *rpcProxy
 	requests *prometheus.CounterVec
+	filters  *filterManager
 }
 
 // handleRPC sets up the JSON-RPC server for receiving and broadcasting transactions.
@@ -71,6 +72,7 @@ func handleRPC(conns *p2p.Conns, networkID uint64) {
 		chainID:  chainID,
 		gpo:      gpo,
 		requests: p2p.NewRPCRequestsCounter(),
+		filters:  newFilterManager(conns),
 	}
 
 	if inputSensorParams.ProxyRPC {
@@ -86,6 +88,10 @@ func handleRPC(conns *p2p.Conns, networkID uint64) {
 			Msg("RPC proxy enabled")
 	}
 
+	if inputSensorParams.ShouldRunWS {
+		go handleWS(params)
+	}
+
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -376,6 +382,42 @@ func processRequest(req rpcRequest, params *rpcParams, txs *types.Transactions)
 		result, err := getUncleCountByBlockHash(req, params.conns)
 		return handleMethodResult(result, err, req.ID)
 
+	case "eth_getTransactionReceipt":
+		result, err := getTransactionReceipt(req, params.conns)
+		return handleMethodResult(result, err, req.ID)
+
+	case "eth_getBlockReceipts":
+		result, err := getBlockReceipts(req, params.conns)
+		return handleMethodResult(result, err, req.ID)
+
+	case "eth_getLogs":
+		result, err := getLogs(req, params.conns)
+		return handleMethodResult(result, err, req.ID)
+
+	case "eth_newFilter":
+		result, err := params.filters.newFilter(req)
+		return handleMethodResult(result, err, req.ID)
+
+	case "eth_newBlockFilter":
+		id, err := params.filters.install(&filter{kind: blocksFilter})
+		return handleMethodResult(id, err, req.ID)
+
+	case "eth_newPendingTransactionFilter":
+		id, err := params.filters.install(&filter{kind: txsFilter})
+		return handleMethodResult(id, err, req.ID)
+
+	case "eth_getFilterChanges":
+		result, err := params.filters.getFilterChanges(req)
+		return handleMethodResult(result, err, req.ID)
+
+	case "eth_getFilterLogs":
+		result, err := params.filters.getFilterLogs(req)
+		return handleMethodResult(result, err, req.ID)
+
+	case "eth_uninstallFilter":
+		result, err := params.filters.uninstallFilter(req)
+		return handleMethodResult(result, err, req.ID)
+
 	default:
 		return newMethodNotFoundResponse(req.ID)
 	}
@@ -479,47 +521,50 @@ func getBlockByNumber(req rpcRequest, conns *p2p.Conns) (any, *rpcError) {
 		return nil, &rpcError{Code: -32602, Message: "invalid block number parameter"}
 	}
 
-	var hash common.Hash
-	var cache p2p.BlockCache
-	var found bool
+	hash, cache, found, err := getBlockCacheByNumber(blockNumParam, conns)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	return formatBlockResponse(hash, cache, parseFullTxParam(req.Params)), nil
+}
 
+// getBlockCacheByNumber resolves a block number or tag to a cached block. If
+// the head block was evicted from the cache it is rebuilt from the head.
+func getBlockCacheByNumber(blockNumParam string, conns *p2p.Conns) (common.Hash, p2p.BlockCache, bool, *rpcError) {
 	switch blockNumParam {
 	case "latest", "pending":
 		head := conns.HeadBlock()
 		if head.Block == nil {
-			return nil, nil
+			return common.Hash{}, p2p.BlockCache{}, false, nil
 		}
-		hash = head.Block.Hash()
-		cache, found = conns.Blocks().Get(hash)
-		if !found {
-			// Construct cache from head block
-			txList, _ := rlp.EncodeToRawList([]*types.Transaction(head.Block.Transactions()))
-			uncleList, _ := rlp.EncodeToRawList(head.Block.Uncles())
-			cache = p2p.BlockCache{
-				Header: head.Block.Header(),
-				Body: &eth.BlockBody{
-					Transactions: txList,
-					Uncles:       uncleList,
-				},
-				TD: head.TD,
-			}
-			found = true
+		hash := head.Block.Hash()
+		if cache, found := conns.Blocks().Get(hash); found {
+			return hash, cache, true, nil
 		}
+		// Construct cache from head block
+		txList, _ := rlp.EncodeToRawList([]*types.Transaction(head.Block.Transactions()))
+		uncleList, _ := rlp.EncodeToRawList(head.Block.Uncles())
+		cache := p2p.BlockCache{
+			Header: head.Block.Header(),
+			Body: &eth.BlockBody{
+				Transactions: txList,
+				Uncles:       uncleList,
+			},
+			TD: head.TD,
+		}
+		return hash, cache, true, nil
 	case "earliest":
-		hash, cache, found = conns.GetBlockByNumber(0)
+		hash, cache, found := conns.GetBlockByNumber(0)
+		return hash, cache, found, nil
 	default:
 		num, err := hexutil.DecodeUint64(blockNumParam)
 		if err != nil {
-			return nil, &rpcError{Code: -32602, Message: "invalid block number: " + err.Error()}
+			return common.Hash{}, p2p.BlockCache{}, false, &rpcError{Code: -32602, Message: "invalid block number: " + err.Error()}
 		}
-		hash, cache, found = conns.GetBlockByNumber(num)
+		hash, cache, found := conns.GetBlockByNumber(num)
+		return hash, cache, found, nil
 	}
-
-	if !found {
-		return nil, nil
-	}
-
-	return formatBlockResponse(hash, cache, parseFullTxParam(req.Params)), nil
 }
 
 // getTransactionByHash retrieves a transaction by its hash from the cache.
@@ -597,6 +642,68 @@ func getTransactionByBlockHashAndIndex(req rpcRequest, conns *p2p.Conns) (any, *
 	return formatTransactionResponse(tx, blockHash, cache.Header, index), nil
 }
 
+// getTransactionReceipt retrieves the receipt of a transaction from the
+// verified receipts of cached blocks.
+func getTransactionReceipt(req rpcRequest, conns *p2p.Conns) (any, *rpcError) {
+	if len(req.Params) < 1 {
+		return nil, &rpcError{Code: -32602, Message: "missing transaction hash parameter"}
+	}
+
+	hashStr, ok := req.Params[0].(string)
+	if !ok {
+		return nil, &rpcError{Code: -32602, Message: "invalid transaction hash parameter"}
+	}
+
+	receipt, cache, ok := conns.GetReceipt(common.HexToHash(hashStr))
+	if !ok || cache.Body == nil {
+		return nil, nil
+	}
+
+	txs, err := cache.Body.Transactions.Items()
+	if err != nil || int(receipt.TransactionIndex) >= len(txs) {
+		return nil, nil
+	}
+
+	return formatReceiptResponse(receipt, txs[receipt.TransactionIndex]), nil
+}
+
+// getBlockReceipts retrieves the receipts of a block by hash, number or tag.
+// It returns null if the block or its receipts aren't cached.
+func getBlockReceipts(req rpcRequest, conns *p2p.Conns) (any, *rpcError) {
+	if len(req.Params) < 1 {
+		return nil, &rpcError{Code: -32602, Message: "missing block parameter"}
+	}
+
+	param, ok := req.Params[0].(string)
+	if !ok {
+		return nil, &rpcError{Code: -32602, Message: "invalid block parameter"}
+	}
+
+	var cache p2p.BlockCache
+	if len(param) == 2+2*common.HashLength {
+		cache, ok = conns.Blocks().Get(common.HexToHash(param))
+	} else {
+		var err *rpcError
+		if _, cache, ok, err = getBlockCacheByNumber(param, conns); err != nil {
+			return nil, err
+		}
+	}
+	if !ok || cache.Receipts == nil {
+		return nil, nil
+	}
+
+	txs, err := cache.Body.Transactions.Items()
+	if err != nil {
+		return nil, nil
+	}
+
+	receipts := make([]map[string]any, len(cache.Receipts))
+	for i, receipt := range cache.Receipts {
+		receipts[i] = formatReceiptResponse(receipt, txs[i])
+	}
+	return receipts, nil
+}
+
 // getBlockCacheByHashParam parses a block hash from params[0] and returns the block cache.
 // Returns the cache and nil error on success, or nil cache and error on parse failure.
 // If the block is not found, returns nil cache with nil error (per JSON-RPC spec).
@@ -810,3 +917,52 @@ func formatTransactionResponse(tx *types.Transaction, blockHash common.Hash, hea
 
 	return result
 }
+
+// formatReceiptResponse formats a receipt into the Ethereum JSON-RPC format.
+func formatReceiptResponse(receipt *types.Receipt, tx *types.Transaction) map[string]any {
+	result := map[string]any{
+		"transactionHash":   receipt.TxHash.Hex(),
+		"transactionIndex":  hexutil.EncodeUint64(uint64(receipt.TransactionIndex)),
+		"blockHash":         receipt.BlockHash.Hex(),
+		"blockNumber":       hexutil.EncodeBig(receipt.BlockNumber),
+		"cumulativeGasUsed": hexutil.EncodeUint64(receipt.CumulativeGasUsed),
+		"gasUsed":           hexutil.EncodeUint64(receipt.GasUsed),
+		"effectiveGasPrice": hexutil.EncodeBig(receipt.EffectiveGasPrice),
+		"logsBloom":         hexutil.Encode(receipt.Bloom.Bytes()),
+		"type":              hexutil.EncodeUint64(uint64(receipt.Type)),
+		"contractAddress":   nil,
+	}
+
+	signer := types.LatestSignerForChainID(tx.ChainId())
+	if from, err := types.Sender(signer, tx); err == nil {
+		result["from"] = from.Hex()
+	}
+
+	if tx.To() != nil {
+		result["to"] = tx.To().Hex()
+	} else {
+		result["to"] = nil
+		result["contractAddress"] = receipt.ContractAddress.Hex()
+	}
+
+	if len(receipt.PostState) > 0 {
+		result["root"] = hexutil.Encode(receipt.PostState)
+	} else {
+		result["status"] = hexutil.EncodeUint64(receipt.Status)
+	}
+
+	if receipt.Logs == nil {
+		result["logs"] = []*types.Log{}
+	} else {
+		result["logs"] = receipt.Logs
+	}
+
+	if tx.Type() == types.BlobTxType {
+		result["blobGasUsed"] = hexutil.EncodeUint64(receipt.BlobGasUsed)
+		if receipt.BlobGasPrice != nil {
+			result["blobGasPrice"] = hexutil.EncodeBig(receipt.BlobGasPrice)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,319 @@
+package sensor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// wsWriteTimeout is how long a write to a WebSocket client may take before
+	// the client is disconnected.
+	wsWriteTimeout = 10 * time.Second
+	// maxWSSubscriptions is the maximum number of subscriptions per client.
+	maxWSSubscriptions = 128
+)
+
+// rpcNotification is a JSON-RPC 2.0 notification sent for a subscription.
+type rpcNotification struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  subscriptionMsg `json:"params"`
+}
+
+// subscriptionMsg is the payload of an eth_subscription notification.
+type subscriptionMsg struct {
+	Subscription string `json:"subscription"`
+	Result       any    `json:"result"`
+}
+
+// wsConn is a WebSocket client of the JSON-RPC server and its subscriptions.
+type wsConn struct {
+	ws     *websocket.Conn
+	params *rpcParams
+
+	// writeMu serializes writes of responses and notifications.
+	writeMu sync.Mutex
+
+	subs   map[string]func()
+	subsMu sync.Mutex
+}
+
+// handleWS runs a JSON-RPC server over WebSocket. It serves the same methods as
+// the HTTP server, plus eth_subscribe and eth_unsubscribe for new heads, logs
+// and pending transactions as the sensor sees them.
+func handleWS(params *rpcParams) {
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(*http.Request) bool { return true },
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Debug().Err(err).Msg("Failed to upgrade WebSocket connection")
+			return
+		}
+
+		c := &wsConn{
+			ws:     ws,
+			params: params,
+			subs:   make(map[string]func()),
+		}
+		c.serve(r.Context())
+	})
+
+	addr := fmt.Sprintf(":%d", inputSensorParams.WSPort)
+	log.Info().Str("addr", addr).Msg("Starting WebSocket JSON-RPC server")
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Error().Err(err).Msg("Failed to start WebSocket server")
+	}
+}
+
+// serve reads requests from the client until it disconnects, then removes its
+// subscriptions.
+func (c *wsConn) serve(ctx context.Context) {
+	defer c.close()
+
+	c.ws.SetReadLimit(5 * 1024 * 1024) // 5MB limit
+
+	for {
+		_, body, err := c.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var txs types.Transactions
+		var resp any
+
+		trimmed := bytes.TrimSpace(body)
+		if len(trimmed) > 0 && trimmed[0] == '[' {
+			var requests []rpcRequest
+			if err := json.Unmarshal(body, &requests); err != nil {
+				resp = newErrorResponse(&rpcError{Code: -32700, Message: "Parse error"}, nil)
+			} else if len(requests) == 0 {
+				resp = newErrorResponse(&rpcError{Code: -32600, Message: "Invalid request: empty batch"}, nil)
+			} else {
+				responses := make([]rpcResponse, len(requests))
+				for i, req := range requests {
+					responses[i] = c.handle(ctx, req, &txs)
+				}
+				resp = responses
+			}
+		} else {
+			var req rpcRequest
+			if err := json.Unmarshal(body, &req); err != nil {
+				resp = newErrorResponse(&rpcError{Code: -32700, Message: "Parse error"}, nil)
+			} else {
+				resp = c.handle(ctx, req, &txs)
+			}
+		}
+
+		// Enqueue transactions for async broadcast
+		if len(txs) > 0 {
+			c.params.conns.EnqueueTxBroadcast(txs)
+		}
+
+		if err := c.write(resp); err != nil {
+			return
+		}
+	}
+}
+
+// handle processes a single request, proxying methods that aren't handled
+// locally if the proxy is enabled.
+func (c *wsConn) handle(ctx context.Context, req rpcRequest, txs *types.Transactions) rpcResponse {
+	var resp rpcResponse
+	switch req.Method {
+	case "eth_subscribe":
+		result, err := c.subscribe(req)
+		resp = handleMethodResult(result, err, req.ID)
+	case "eth_unsubscribe":
+		result, err := c.unsubscribe(req)
+		resp = handleMethodResult(result, err, req.ID)
+	default:
+		resp = processRequest(req, c.params, txs)
+	}
+
+	if isMethodNotFound(resp) && c.params.proxy != nil {
+		c.params.requests.WithLabelValues(req.Method, "true").Inc()
+		return forwardRPCRequest(ctx, req, c.params.proxy)
+	}
+
+	c.params.requests.WithLabelValues(req.Method, "false").Inc()
+	return resp
+}
+
+// subscribe handles eth_subscribe for newHeads, logs with an optional filter
+// object, and newPendingTransactions with full transactions if the second
+// parameter is true.
+func (c *wsConn) subscribe(req rpcRequest) (any, *rpcError) {
+	if len(req.Params) < 1 {
+		return nil, &rpcError{Code: -32602, Message: "missing subscription type parameter"}
+	}
+
+	kind, ok := req.Params[0].(string)
+	if !ok {
+		return nil, &rpcError{Code: -32602, Message: "invalid subscription type parameter"}
+	}
+
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+
+	if len(c.subs) >= maxWSSubscriptions {
+		return nil, &rpcError{Code: -32000, Message: "too many subscriptions"}
+	}
+
+	id := newID()
+	conns := c.params.conns
+
+	switch kind {
+	case "newHeads":
+		ch, unsub := conns.SubscribeHeads()
+		c.subs[id] = unsub
+		go notify(c, id, ch, func(header *types.Header) []any {
+			return []any{header}
+		})
+
+	case "logs":
+		var param any
+		if len(req.Params) > 1 {
+			param = req.Params[1]
+		}
+		f, err := parseLogFilter(param)
+		if err != nil {
+			return nil, err
+		}
+
+		ch, unsub := conns.SubscribeLogs()
+		c.subs[id] = unsub
+		go notify(c, id, ch, func(logs []*types.Log) []any {
+			var results []any
+			for _, l := range logs {
+				if f.matches(l) {
+					results = append(results, l)
+				}
+			}
+			return results
+		})
+
+	case "newPendingTransactions":
+		fullTx := parseFullTxParam(req.Params)
+
+		ch, unsub := conns.SubscribeTxs()
+		c.subs[id] = unsub
+		go notify(c, id, ch, func(txs []*types.Transaction) []any {
+			results := make([]any, len(txs))
+			for i, tx := range txs {
+				if fullTx {
+					results[i] = formatTransactionResponse(tx, common.Hash{}, nil, 0)
+				} else {
+					results[i] = tx.Hash().Hex()
+				}
+			}
+			return results
+		})
+
+	default:
+		return nil, &rpcError{Code: -32602, Message: "unsupported subscription type: " + kind}
+	}
+
+	return id, nil
+}
+
+// unsubscribe handles eth_unsubscribe.
+func (c *wsConn) unsubscribe(req rpcRequest) (any, *rpcError) {
+	id, err := filterIDParam(req)
+	if err != nil {
+		return nil, err
+	}
+
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+
+	unsub, ok := c.subs[id]
+	if ok {
+		unsub()
+		delete(c.subs, id)
+	}
+	return ok, nil
+}
+
+// notify sends a notification for each result of the events of a
+// subscription until it is unsubscribed. The client is disconnected if a
+// notification can't be written.
+func notify[T any](c *wsConn, id string, ch <-chan T, results func(T) []any) {
+	for event := range ch {
+		for _, result := range results(event) {
+			msg := rpcNotification{
+				JSONRPC: "2.0",
+				Method:  "eth_subscription",
+				Params:  subscriptionMsg{Subscription: id, Result: result},
+			}
+			if err := c.write(msg); err != nil {
+				_ = c.ws.Close()
+				return
+			}
+		}
+	}
+}
+
+// write sends a JSON message to the client.
+func (c *wsConn) write(v any) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err := c.ws.SetWriteDeadline(time.Now().Add(wsWriteTimeout)); err != nil {
+		return err
+	}
+	return c.ws.WriteJSON(v)
+}
+
+// close removes the client's subscriptions and closes the connection.
+func (c *wsConn) close() {
+	c.subsMu.Lock()
+	for id, unsub := range c.subs {
+		unsub()
+		delete(c.subs, id)
+	}
+	c.subsMu.Unlock()
+
+	_ = c.ws.Close()
+}
+
+// forwardRPCRequest sends a single request to the upstream RPC server and
+// returns its response.
+func forwardRPCRequest(ctx context.Context, req rpcRequest, proxy *rpcProxy) rpcResponse {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return newErrorResponse(&rpcError{Code: -32603, Message: "Internal error: failed to encode proxy request"}, req.ID)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, proxy.rpcURL, bytes.NewReader(body))
+	if err != nil {
+		return newErrorResponse(&rpcError{Code: -32603, Message: "Internal error: failed to create proxy request"}, req.ID)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := proxy.httpClient.Do(httpReq)
+	if err != nil {
+		log.Error().Err(err).Str("rpc", proxy.rpcURL).Msg("Proxy request failed")
+		return newErrorResponse(&rpcError{Code: -32603, Message: fmt.Sprintf("Upstream RPC error: %v", err)}, req.ID)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var out rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return newErrorResponse(&rpcError{Code: -32603, Message: fmt.Sprintf("Upstream RPC error: %v", err)}, req.ID)
+	}
+	return out
+}
@@ -0,0 +1,391 @@
+package tracetx
+
+import (
+	"context"
+	"crypto/ecdsa"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/0xPolygon/polygon-cli/flag"
+	"github.com/0xPolygon/polygon-cli/p2p"
+)
+
+type (
+	traceTxParams struct {
+		Entry      string
+		ChainID    uint64
+		Nonce      uint64
+		GasPrice   uint64
+		Timeout    time.Duration
+		Threads    int
+		JSON       bool
+		Port       int
+		Addr       net.IP
+		KeyFile    string
+		PrivateKey string
+
+		rpcURL   string
+		entry    *enode.Node
+		txKey    *ecdsa.PrivateKey
+		nodeKey  *ecdsa.PrivateKey
+		hasNonce bool
+	}
+)
+
+var (
+	//go:embed usage.md
+	traceTxUsage       string
+	inputTraceTxParams traceTxParams
+)
+
+// TraceTxCmd sends a tracer transaction to a single entry point and follows its
+// propagation across connections to other peers.
+var TraceTxCmd = &cobra.Command{
+	Use:   "trace-tx [enode/enr or nodes file]",
+	Short: "Send a tracer transaction to one peer and trace its propagation.",
+	Long:  traceTxUsage,
+	Args:  cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) (err error) {
+		params := &inputTraceTxParams
+
+		if params.rpcURL, err = flag.GetRPCURL(cmd); err != nil {
+			return err
+		}
+		if params.Entry == "" && params.rpcURL == "" {
+			return fmt.Errorf("one of --entry or --%s is required", flag.RPCURL)
+		}
+		params.hasNonce = cmd.Flags().Changed("nonce")
+		if params.rpcURL == "" && (params.ChainID == 0 || params.GasPrice == 0 || !params.hasNonce) {
+			return fmt.Errorf("--chain-id, --nonce and --gas-price are required without --%s", flag.RPCURL)
+		}
+
+		if params.Entry != "" {
+			if params.entry, err = p2p.ParseNode(params.Entry); err != nil {
+				return fmt.Errorf("invalid --entry: %w", err)
+			}
+		}
+
+		key, err := flag.GetRequiredPrivateKey(cmd)
+		if err != nil {
+			return err
+		}
+		if params.txKey, err = crypto.HexToECDSA(strings.TrimPrefix(key, "0x")); err != nil {
+			return fmt.Errorf("invalid --%s: %w", flag.PrivateKey, err)
+		}
+
+		params.nodeKey, err = p2p.ParsePrivateKey(params.KeyFile, params.PrivateKey)
+		return err
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		params := &inputTraceTxParams
+
+		nodes, err := p2p.ReadNodeSet(args[0])
+		if err != nil {
+			node, parseErr := p2p.ParseNode(args[0])
+			if parseErr != nil {
+				return parseErr
+			}
+			nodes = []*enode.Node{node}
+		}
+
+		var client *ethclient.Client
+		if params.rpcURL != "" {
+			if client, err = ethclient.Dial(params.rpcURL); err != nil {
+				return err
+			}
+			defer client.Close()
+		}
+
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+
+		tx, err := signTracerTx(ctx, client)
+		if err != nil {
+			return err
+		}
+		from := crypto.PubkeyToAddress(params.txKey.PublicKey)
+		log.Info().Str("hash", tx.Hash().Hex()).Str("from", from.Hex()).Uint64("nonce", tx.Nonce()).Msg("Signed tracer transaction")
+
+		opts := p2p.DialOpts{
+			Port:       params.Port,
+			Addr:       params.Addr,
+			PrivateKey: params.nodeKey,
+		}
+
+		listeners, names := dialListeners(nodes, opts)
+		if len(listeners) == 0 {
+			return fmt.Errorf("failed to connect to any of %d peers", len(nodes))
+		}
+		log.Info().Int("peers", len(listeners)).Int("nodes", len(nodes)).Msg("Connected to listening peers")
+
+		trace := p2p.NewTxTrace(tx.Hash())
+
+		var wg sync.WaitGroup
+		defer func() {
+			cancel()
+			wg.Wait()
+			for _, conn := range listeners {
+				if err := conn.Close(); err != nil {
+					log.Debug().Err(err).Msg("Failed to close connection")
+				}
+			}
+		}()
+
+		follow := func(conn p2p.TxTracer) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := conn.TraceTx(ctx, trace); err != nil {
+					log.Debug().Err(err).Msg("Stopped tracing peer")
+				}
+			}()
+		}
+		for _, conn := range listeners {
+			follow(conn)
+		}
+
+		entry := params.rpcURL
+		if params.entry != nil {
+			entry = params.entry.URLv4()
+			conn, err := p2p.Dial(params.entry, opts)
+			if err != nil {
+				return fmt.Errorf("failed to dial entry peer: %w", err)
+			}
+			hello, _, err := conn.Peer()
+			if err != nil {
+				_ = conn.Close()
+				return fmt.Errorf("failed to peer with entry peer: %w", err)
+			}
+			names[params.entry.URLv4()] = hello.Name
+			listeners = append(listeners, conn)
+
+			trace.MarkSent(time.Now())
+			if err := conn.SendTxs([]*types.Transaction{tx}); err != nil {
+				return fmt.Errorf("failed to send transaction to entry peer: %w", err)
+			}
+			follow(conn)
+		} else {
+			trace.MarkSent(time.Now())
+			if err := client.SendTransaction(ctx, tx); err != nil {
+				return fmt.Errorf("failed to send transaction: %w", err)
+			}
+		}
+		log.Info().Str("entry", entry).Msg("Sent tracer transaction")
+
+		select {
+		case <-trace.Included():
+		case <-time.After(params.Timeout):
+			log.Warn().Dur("timeout", params.Timeout).Msg("Transaction was not seen in a block before the timeout")
+		case <-ctx.Done():
+		}
+
+		r := newReport(trace.Result(), entry, len(listeners), names)
+		if params.JSON {
+			return r.writeJSON(os.Stdout)
+		}
+		return r.writeText(os.Stdout)
+	},
+}
+
+// signTracerTx signs a zero value self-transfer, filling in the chain ID, nonce
+// and gas price from the RPC when they are not set.
+func signTracerTx(ctx context.Context, client *ethclient.Client) (*types.Transaction, error) {
+	params := &inputTraceTxParams
+	from := crypto.PubkeyToAddress(params.txKey.PublicKey)
+
+	chainID := new(big.Int).SetUint64(params.ChainID)
+	if params.ChainID == 0 {
+		id, err := client.ChainID(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get chain ID: %w", err)
+		}
+		chainID = id
+	}
+
+	nonce := params.Nonce
+	if !params.hasNonce {
+		n, err := client.PendingNonceAt(ctx, from)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get nonce: %w", err)
+		}
+		nonce = n
+	}
+
+	gasPrice := new(big.Int).SetUint64(params.GasPrice)
+	if params.GasPrice == 0 {
+		price, err := client.SuggestGasPrice(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get gas price: %w", err)
+		}
+		gasPrice = price
+	}
+
+	tx := types.NewTx(&types.LegacyTx{
+		Nonce:    nonce,
+		To:       &from,
+		Value:    common.Big0,
+		Gas:      21000,
+		GasPrice: gasPrice,
+	})
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), params.txKey)
+}
+
+// dialListeners connects to the nodes in parallel, skipping the entry peer, and
+// returns the connections along with the client names of the peers.
+func dialListeners(nodes []*enode.Node, opts p2p.DialOpts) ([]p2p.TxTracer, map[string]string) {
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		listeners []p2p.TxTracer
+		names     = make(map[string]string)
+		sem       = make(chan struct{}, inputTraceTxParams.Threads)
+	)
+
+	entry := inputTraceTxParams.entry
+	for _, n := range nodes {
+		if entry != nil && n.ID() == entry.ID() {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(node *enode.Node) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			conn, err := p2p.Dial(node, opts)
+			if err != nil {
+				log.Debug().Err(err).Str("peer", node.URLv4()).Msg("Dial failed")
+				return
+			}
+			hello, _, err := conn.Peer()
+			if err != nil {
+				log.Debug().Err(err).Str("peer", node.URLv4()).Msg("Peer failed")
+				_ = conn.Close()
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			listeners = append(listeners, conn)
+			names[node.URLv4()] = hello.Name
+		}(n)
+	}
+	wg.Wait()
+
+	return listeners, names
+}
+
+// report is the outcome of a trace for output.
+type report struct {
+	Hash      common.Hash `json:"hash"`
+	Entry     string      `json:"entry"`
+	SentAt    time.Time   `json:"sent_at"`
+	Peers     int         `json:"peers"`
+	Sightings []sighting  `json:"sightings"`
+	Inclusion *inclusion  `json:"inclusion,omitempty"`
+}
+
+type sighting struct {
+	Peer    string  `json:"peer"`
+	Name    string  `json:"name"`
+	Message string  `json:"message"`
+	DelayMs float64 `json:"delay_ms"`
+}
+
+type inclusion struct {
+	Peer    string      `json:"peer"`
+	Hash    common.Hash `json:"hash"`
+	Number  uint64      `json:"number"`
+	DelayMs float64     `json:"delay_ms"`
+}
+
+func newReport(r p2p.TxTraceResult, entry string, peers int, names map[string]string) report {
+	rep := report{
+		Hash:      r.Hash,
+		Entry:     entry,
+		SentAt:    r.SentAt,
+		Peers:     peers,
+		Sightings: make([]sighting, 0, len(r.Sightings)),
+	}
+	for _, s := range r.Sightings {
+		rep.Sightings = append(rep.Sightings, sighting{
+			Peer:    s.Peer,
+			Name:    names[s.Peer],
+			Message: s.Message,
+			DelayMs: ms(s.Delay),
+		})
+	}
+	if r.Inclusion != nil {
+		rep.Inclusion = &inclusion{
+			Peer:    r.Inclusion.Peer,
+			Hash:    r.Inclusion.Hash,
+			Number:  r.Inclusion.Number,
+			DelayMs: ms(r.Inclusion.Delay),
+		}
+	}
+	return rep
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func (r report) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func (r report) writeText(w io.Writer) error {
+	fmt.Fprintf(w, "Transaction %s sent to %s\n", r.Hash.Hex(), r.Entry)
+	fmt.Fprintf(w, "Seen by %d of %d peers\n\n", len(r.Sightings), r.Peers)
+
+	for i, s := range r.Sightings {
+		fmt.Fprintf(w, "%4d  %10.1fms  %-26s  %-40.40s  %s\n", i+1, s.DelayMs, s.Message, s.Name, s.Peer)
+	}
+
+	if r.Inclusion == nil {
+		_, err := fmt.Fprintf(w, "\nNot seen in a block\n")
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\nIncluded in block %d (%s) after %.1fms\n",
+		r.Inclusion.Number, r.Inclusion.Hash.Hex(), r.Inclusion.DelayMs)
+	return err
+}
+
+func init() {
+	f := TraceTxCmd.Flags()
+	f.StringVarP(&inputTraceTxParams.rpcURL, flag.RPCURL, "r", "", "RPC to send the transaction to, and to fill in the chain ID, nonce and gas price")
+	f.String(flag.PrivateKey, "", "hex-encoded private key of the account sending the tracer transaction")
+	f.StringVarP(&inputTraceTxParams.Entry, "entry", "e", "", "enode/enr of the only peer to send the transaction to (instead of the RPC)")
+	f.Uint64Var(&inputTraceTxParams.ChainID, "chain-id", 0, "chain ID to sign the transaction for (default from the RPC)")
+	f.Uint64Var(&inputTraceTxParams.Nonce, "nonce", 0, "nonce of the transaction (default the pending nonce from the RPC)")
+	f.Uint64Var(&inputTraceTxParams.GasPrice, "gas-price", 0, "gas price in wei (default the suggested gas price from the RPC)")
+	f.DurationVarP(&inputTraceTxParams.Timeout, "timeout", "t", 2*time.Minute, "how long to wait for the transaction to be included")
+	f.IntVarP(&inputTraceTxParams.Threads, "parallel", "p", 16, "how many peers to connect to in parallel")
+	f.BoolVar(&inputTraceTxParams.JSON, "json", false, "output the trace as JSON")
+	f.IntVarP(&inputTraceTxParams.Port, "port", "P", 30303, "port for discovery protocol")
+	f.IPVarP(&inputTraceTxParams.Addr, "addr", "a", net.ParseIP("127.0.0.1"), "address to bind discovery listener")
+	f.StringVarP(&inputTraceTxParams.KeyFile, "key-file", "k", "", "private key file (cannot be set with --key)")
+	f.StringVar(&inputTraceTxParams.PrivateKey, "key", "", "hex-encoded private key (cannot be set with --key-file)")
+	TraceTxCmd.MarkFlagsMutuallyExclusive("key-file", "key")
+}
@@ -0,0 +1,481 @@
+package argfuzz
+
+import (
+	"encoding/json"
+	"math/big"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Kind is the type of an RPC param, inferred from a valid value of it.
+type Kind int
+
+const (
+	KindUnknown Kind = iota
+	KindQuantity
+	KindData
+	KindAddress
+	KindHash
+	KindBlockTag
+	KindBool
+	KindTxObject
+	KindFilterObject
+	KindArray
+	KindObject
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindQuantity:
+		return "quantity"
+	case KindData:
+		return "data"
+	case KindAddress:
+		return "address"
+	case KindHash:
+		return "hash"
+	case KindBlockTag:
+		return "block_tag"
+	case KindBool:
+		return "bool"
+	case KindTxObject:
+		return "tx_object"
+	case KindFilterObject:
+		return "filter_object"
+	case KindArray:
+		return "array"
+	case KindObject:
+		return "object"
+	default:
+		return "unknown"
+	}
+}
+
+// Mutations applied by Mutate.
+const (
+	MutationBoundary      = "boundary"
+	MutationTypeConfusion = "type_confusion"
+	MutationDeepNesting   = "deep_nesting"
+	MutationHugeArray     = "huge_array"
+	MutationHugeValue     = "huge_value"
+	MutationArity         = "arity"
+)
+
+var (
+	mutations = []string{
+		MutationBoundary,
+		MutationTypeConfusion,
+		MutationDeepNesting,
+		MutationHugeArray,
+		MutationHugeValue,
+		MutationArity,
+	}
+	// memberMutations are applied to the members of batches, which are
+	// already large without huge args.
+	memberMutations = []string{
+		MutationBoundary,
+		MutationTypeConfusion,
+		MutationArity,
+	}
+
+	quantityRegex = regexp.MustCompile(`^0x[0-9a-fA-F]+$`)
+	dataRegex     = regexp.MustCompile(`^0x([0-9a-fA-F]{2})*$`)
+
+	blockTags = []string{"latest", "pending", "earliest", "safe", "finalized"}
+
+	txFields     = []string{"from", "to", "gas", "gasPrice", "maxFeePerGas", "maxPriorityFeePerGas", "value", "data", "input", "nonce"}
+	filterFields = []string{"fromBlock", "toBlock", "address", "topics", "blockHash"}
+
+	maxUint64  = "0x" + strings.Repeat("f", 16)
+	maxUint256 = "0x" + strings.Repeat("f", 64)
+	zeroHash   = "0x" + strings.Repeat("0", 64)
+	zeroAddr   = "0x" + strings.Repeat("0", 40)
+)
+
+// InferKind infers the kind of a param from a valid value of it. Values are
+// expected in their JSON form, see Normalize.
+func InferKind(v any) Kind {
+	switch v := v.(type) {
+	case bool:
+		return KindBool
+	case float64:
+		return KindQuantity
+	case string:
+		switch {
+		case slices.Contains(blockTags, v):
+			return KindBlockTag
+		case len(v) == 42 && dataRegex.MatchString(v):
+			return KindAddress
+		case len(v) == 66 && dataRegex.MatchString(v):
+			return KindHash
+		case quantityRegex.MatchString(v) && (len(v) == 3 || v[2] != '0') && len(v) <= 66:
+			return KindQuantity
+		case dataRegex.MatchString(v):
+			return KindData
+		}
+	case []any:
+		return KindArray
+	case map[string]any:
+		switch {
+		case hasAnyKey(v, filterFields):
+			return KindFilterObject
+		case hasAnyKey(v, txFields):
+			return KindTxObject
+		}
+		return KindObject
+	}
+	return KindUnknown
+}
+
+func hasAnyKey(m map[string]any, keys []string) bool {
+	for _, k := range keys {
+		if _, ok := m[k]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Normalize returns a deep copy of the args in their JSON form, so structs
+// become maps and numbers become float64.
+func Normalize(args []any) []any {
+	b, err := json.Marshal(args)
+	if err != nil {
+		return slices.Clone(args)
+	}
+	var normalized []any
+	if err := json.Unmarshal(b, &normalized); err != nil {
+		return slices.Clone(args)
+	}
+	return normalized
+}
+
+// Mutate returns a copy of the args with one structure-aware mutation applied
+// to them, chosen from the kinds of the args, and the name of the mutation.
+// Methods without args can only have args added.
+func Mutate(args []any) ([]any, string) {
+	return mutate(args, mutations)
+}
+
+func mutate(args []any, mutations []string) ([]any, string) {
+	mutated := Normalize(args)
+	if len(mutated) == 0 {
+		return mutateArity(mutated), MutationArity
+	}
+
+	mutation := pick(mutations)
+	i := randSrc.Intn(len(mutated))
+	switch mutation {
+	case MutationBoundary:
+		mutated[i] = boundaryValue(mutated[i])
+	case MutationTypeConfusion:
+		mutated[i] = confuseType(mutated[i])
+	case MutationDeepNesting:
+		mutated[i] = deepNesting(mutated[i])
+	case MutationHugeArray:
+		mutated[i] = hugeArray(mutated[i])
+	case MutationHugeValue:
+		mutated[i] = hugeValue(mutated[i])
+	case MutationArity:
+		mutated = mutateArity(mutated)
+	}
+	return mutated, mutation
+}
+
+// pick returns a random element of values.
+func pick[T any](values []T) T {
+	return values[randSrc.Intn(len(values))]
+}
+
+// boundaryValue returns an edge case of the value's kind, such as a quantity
+// overflowing 256 bits or a block range with its ends swapped.
+func boundaryValue(v any) any {
+	switch InferKind(v) {
+	case KindQuantity:
+		return pick([]any{
+			"0x", "0x0", "0x00", "0x01", "0X1", "-0x1", "0x-1", "1", 0, -1, 1.5,
+			maxUint64, "0x10000000000000000", maxUint256, "0x1" + strings.Repeat("0", 64),
+		})
+	case KindData:
+		return pick([]any{"0x", "0x0", "0xzz", "00", "0x" + strings.Repeat("00", 32), "0X00"})
+	case KindAddress:
+		return pick([]any{
+			zeroAddr, "0x" + strings.Repeat("0", 38), "0x" + strings.Repeat("0", 42),
+			"0x0000000000000000000000000000000000000001", "0x" + strings.Repeat("f", 40),
+			"0xFe3b557e8Fb62b89F4916B721be55cEb828dBd73", strings.Repeat("0", 40),
+		})
+	case KindHash:
+		return pick([]any{zeroHash, "0x" + strings.Repeat("0", 62), "0x" + strings.Repeat("0", 66), "0x" + strings.Repeat("f", 64), "0x"})
+	case KindBlockTag:
+		return pick([]any{
+			"latest", "pending", "earliest", "safe", "finalized", "LATEST", "", "0x0", maxUint64,
+			map[string]any{"blockHash": zeroHash},
+			map[string]any{"blockHash": zeroHash, "requireCanonical": true},
+			map[string]any{"blockNumber": "0x0"},
+			map[string]any{"blockNumber": maxUint64, "blockHash": zeroHash},
+		})
+	case KindBool:
+		return pick([]any{true, false, "true", 1, 0})
+	case KindTxObject:
+		return boundaryObject(v.(map[string]any), []map[string]any{
+			{"gasPrice": "0x1", "maxFeePerGas": "0x1", "maxPriorityFeePerGas": "0x2"},
+			{"data": "0x00", "input": "0x01"},
+			{"gas": maxUint64, "value": maxUint256},
+			{"to": nil, "data": "0x"},
+			{"chainId": maxUint256},
+			{"accessList": []any{map[string]any{"address": zeroAddr, "storageKeys": []any{zeroHash, "0x"}}}},
+			{"blobVersionedHashes": []any{zeroHash}, "maxFeePerBlobGas": "0x0"},
+			{"authorizationList": []any{map[string]any{"chainId": "0x0", "address": zeroAddr, "nonce": maxUint64}}},
+			{"type": "0x7f"},
+			{"unknownField": true},
+		})
+	case KindFilterObject:
+		return boundaryObject(v.(map[string]any), []map[string]any{
+			{"fromBlock": "latest", "toBlock": "earliest"},
+			{"fromBlock": "0x0", "toBlock": maxUint64},
+			{"blockHash": zeroHash, "fromBlock": "0x0"},
+			{"topics": []any{nil, nil, nil, nil, zeroHash}},
+			{"topics": []any{[]any{[]any{zeroHash}}}},
+			{"address": []any{}},
+			{"address": "0x"},
+		})
+	}
+
+	// Arrays and objects of unknown kinds have one of their elements pushed to
+	// a boundary.
+	switch v := v.(type) {
+	case []any:
+		if len(v) > 0 {
+			i := randSrc.Intn(len(v))
+			v[i] = boundaryValue(v[i])
+		}
+		return v
+	case map[string]any:
+		keys := sortedKeys(v)
+		if len(keys) > 0 {
+			k := pick(keys)
+			v[k] = boundaryValue(v[k])
+		}
+		return v
+	}
+	return confuseType(v)
+}
+
+// boundaryObject either pushes one field of the object to a boundary or
+// merges one of the overrides into it.
+func boundaryObject(v map[string]any, overrides []map[string]any) map[string]any {
+	keys := sortedKeys(v)
+	if len(keys) > 0 && randSrc.Intn(2) == 0 {
+		k := pick(keys)
+		v[k] = boundaryValue(v[k])
+		return v
+	}
+	for k, o := range pick(overrides) {
+		v[k] = o
+	}
+	return v
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// confuseType replaces the value with one of another JSON type.
+func confuseType(v any) any {
+	candidates := []any{nil, true, 0, -1, 1e300, 1.5, "", "0x", []any{}, []any{v}, map[string]any{}, map[string]any{"value": v}}
+	for {
+		c := pick(candidates)
+		if jsonType(c) != jsonType(v) {
+			return c
+		}
+	}
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return "number"
+	}
+}
+
+// deepNesting wraps the value in hundreds to thousands of arrays or objects.
+func deepNesting(v any) any {
+	depth := 100 + randSrc.Intn(5000)
+	object := randSrc.Intn(2) == 0
+	for range depth {
+		if object {
+			v = map[string]any{"a": v}
+		} else {
+			v = []any{v}
+		}
+	}
+	return v
+}
+
+// hugeArray repeats the value thousands of times. The array fields of tx and
+// filter objects are filled instead, as they are where lists are expected.
+func hugeArray(v any) any {
+	n := 1000 + randSrc.Intn(100_000)
+	switch m := v.(type) {
+	case map[string]any:
+		switch InferKind(m) {
+		case KindFilterObject:
+			if randSrc.Intn(2) == 0 {
+				m["address"] = repeat(zeroAddr, n)
+			} else {
+				m["topics"] = []any{repeat(zeroHash, n)}
+			}
+			return m
+		case KindTxObject:
+			m["accessList"] = repeat(map[string]any{"address": zeroAddr, "storageKeys": []any{zeroHash}}, n)
+			return m
+		}
+	case []any:
+		if len(m) > 0 {
+			return repeat(m[0], n)
+		}
+	}
+	return repeat(v, n)
+}
+
+func repeat(v any, n int) []any {
+	values := make([]any, n)
+	for i := range values {
+		values[i] = v
+	}
+	return values
+}
+
+// hugeValue replaces the value with a string of up to a few megabytes that is
+// valid for its kind where possible.
+func hugeValue(v any) any {
+	n := 1 << (10 + randSrc.Intn(11))
+	switch InferKind(v) {
+	case KindQuantity:
+		return "0x" + new(big.Int).Lsh(big.NewInt(1), uint(n)).Text(16)
+	case KindData, KindAddress, KindHash, KindTxObject:
+		data := "0x" + strings.Repeat("ab", n)
+		if m, ok := v.(map[string]any); ok {
+			m["data"] = data
+			return m
+		}
+		return data
+	}
+	return strings.Repeat("a", n)
+}
+
+// mutateArity drops args from the end or appends extra ones.
+func mutateArity(args []any) []any {
+	if len(args) > 0 && randSrc.Intn(2) == 0 {
+		return args[:randSrc.Intn(len(args))]
+	}
+	for range 1 + randSrc.Intn(3) {
+		args = append(args, pick([]any{nil, "latest", true, "0x0", map[string]any{}, []any{}}))
+	}
+	return args
+}
+
+// Call is a method and the valid args it was called with, which batches are
+// built from.
+type Call struct {
+	Method string
+	Args   []any
+}
+
+// Batch mutations applied by MutateBatch.
+const (
+	MutationBatchMixed          = "batch_mixed"
+	MutationBatchEmpty          = "batch_empty"
+	MutationBatchHuge           = "batch_huge"
+	MutationBatchDuplicateIDs   = "batch_duplicate_ids"
+	MutationBatchInvalidMembers = "batch_invalid_members"
+	MutationBatchNested         = "batch_nested"
+	MutationBatchNotifications  = "batch_notifications"
+)
+
+var batchMutations = []string{
+	MutationBatchMixed,
+	MutationBatchEmpty,
+	MutationBatchHuge,
+	MutationBatchDuplicateIDs,
+	MutationBatchInvalidMembers,
+	MutationBatchNested,
+	MutationBatchNotifications,
+}
+
+// NewRequest returns a JSON-RPC request object. A nil id makes it a
+// notification.
+func NewRequest(id any, method string, args []any) map[string]any {
+	request := map[string]any{"jsonrpc": "2.0", "method": method, "params": args}
+	if id != nil {
+		request["id"] = id
+	}
+	return request
+}
+
+// MutateBatch returns a JSON-RPC batch built from the calls, some of them
+// with boundary values or confused types, and the name of the batch mutation.
+func MutateBatch(calls []Call) ([]any, string) {
+	mutation := pick(batchMutations)
+
+	request := func(id any) map[string]any {
+		c := pick(calls)
+		args := Normalize(c.Args)
+		if randSrc.Intn(2) == 0 {
+			args, _ = mutate(c.Args, memberMutations)
+		}
+		return NewRequest(id, c.Method, args)
+	}
+
+	var batch []any
+	switch mutation {
+	case MutationBatchMixed:
+		for i := range 2 + randSrc.Intn(31) {
+			batch = append(batch, request(i))
+		}
+	case MutationBatchEmpty:
+		batch = []any{}
+	case MutationBatchHuge:
+		r := request(0)
+		batch = repeat(r, 1000+randSrc.Intn(20_000))
+	case MutationBatchDuplicateIDs:
+		for range 2 + randSrc.Intn(15) {
+			batch = append(batch, request(1))
+		}
+	case MutationBatchInvalidMembers:
+		for i := range 2 + randSrc.Intn(15) {
+			if randSrc.Intn(2) == 0 {
+				batch = append(batch, request(i))
+			} else {
+				batch = append(batch, pick([]any{nil, 1, "", true, []any{}, map[string]any{}, map[string]any{"jsonrpc": "2.0", "id": i}}))
+			}
+		}
+	case MutationBatchNested:
+		batch = []any{[]any{request(0), request(1)}}
+	case MutationBatchNotifications:
+		for i := range 2 + randSrc.Intn(15) {
+			if randSrc.Intn(2) == 0 {
+				batch = append(batch, request(i))
+			} else {
+				batch = append(batch, request(nil))
+			}
+		}
+	}
+	return batch, mutation
+}
@@ -0,0 +1,146 @@
+package argfuzz
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInferKind(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  Kind
+	}{
+		{name: "zero quantity", value: "0x0", want: KindQuantity},
+		{name: "quantity", value: "0x1b4", want: KindQuantity},
+		{name: "upper case quantity", value: "0xABC", want: KindQuantity},
+		{name: "number", value: 21000.0, want: KindQuantity},
+		{name: "leading zero is data", value: "0x01", want: KindData},
+		{name: "empty data", value: "0x", want: KindData},
+		{name: "data", value: "0x00ff00ff", want: KindData},
+		// Without a leading zero, data is indistinguishable from a quantity.
+		{name: "ambiguous data", value: "0xdeadbeef", want: KindQuantity},
+		{name: "odd length with leading zero", value: "0x012", want: KindUnknown},
+		{name: "address", value: "0x" + strings.Repeat("ab", 20), want: KindAddress},
+		{name: "checksummed address", value: "0x85dA99c8a7C2C95964c8EfD687E95E632Fc533D6", want: KindAddress},
+		{name: "hash", value: zeroHash, want: KindHash},
+		{name: "latest", value: "latest", want: KindBlockTag},
+		{name: "finalized", value: "finalized", want: KindBlockTag},
+		{name: "unknown tag", value: "newest", want: KindUnknown},
+		{name: "bool", value: true, want: KindBool},
+		{name: "tx object", value: map[string]any{"to": zeroAddr, "data": "0x"}, want: KindTxObject},
+		{name: "filter object", value: map[string]any{"fromBlock": "0x1", "address": zeroAddr}, want: KindFilterObject},
+		{name: "object", value: map[string]any{"a": 1.0}, want: KindObject},
+		{name: "array", value: []any{"0x1"}, want: KindArray},
+		{name: "null", value: nil, want: KindUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, InferKind(tt.value), "got %s", InferKind(tt.value))
+		})
+	}
+}
+
+func withSeed(seed int64) {
+	SetSeed(&seed)
+}
+
+// testArgs are args of the kinds mutations treat differently.
+func testArgs() []any {
+	return []any{
+		map[string]any{"from": zeroAddr, "to": zeroAddr, "data": "0xdeadbeef", "accessList": []any{}},
+		map[string]any{"fromBlock": "0x1", "toBlock": "latest", "topics": []any{zeroHash}},
+		"0x1b4",
+		"latest",
+		true,
+	}
+}
+
+func TestMutateDeterministic(t *testing.T) {
+	run := func() string {
+		withSeed(42)
+		var b strings.Builder
+		for range 50 {
+			mutated, mutation := Mutate(testArgs())
+			out, err := json.Marshal(mutated)
+			require.NoError(t, err)
+			b.WriteString(mutation)
+			b.Write(out)
+		}
+		for range 10 {
+			batch, mutation := MutateBatch([]Call{{Method: "eth_call", Args: testArgs()}, {Method: "eth_blockNumber"}})
+			out, err := json.Marshal(batch)
+			require.NoError(t, err)
+			b.WriteString(mutation)
+			b.Write(out)
+		}
+		return b.String()
+	}
+
+	require.Equal(t, run(), run())
+
+	withSeed(43)
+	mutated, _ := Mutate(testArgs())
+	withSeed(42)
+	other, _ := Mutate(testArgs())
+	require.NotEqual(t, mutated, other)
+}
+
+func TestMutateKeepsArgs(t *testing.T) {
+	withSeed(1)
+	args := testArgs()
+	seen := make(map[string]bool)
+	for range 200 {
+		_, mutation := Mutate(args)
+		seen[mutation] = true
+		require.Equal(t, testArgs(), args, "after %s", mutation)
+	}
+	for _, mutation := range mutations {
+		require.True(t, seen[mutation], "mutation %s not applied", mutation)
+	}
+
+	calls := []Call{{Method: "eth_call", Args: args}}
+	for range 20 {
+		_, mutation := MutateBatch(calls)
+		require.Equal(t, testArgs(), calls[0].Args, "after %s", mutation)
+	}
+}
+
+func TestMutateWithoutArgs(t *testing.T) {
+	withSeed(1)
+	for range 20 {
+		mutated, mutation := Mutate(nil)
+		require.Equal(t, MutationArity, mutation)
+		require.NotEmpty(t, mutated)
+	}
+}
+
+func TestMutateBatch(t *testing.T) {
+	withSeed(1)
+	calls := []Call{{Method: "eth_getBalance", Args: []any{zeroAddr, "latest"}}}
+	for range 100 {
+		batch, mutation := MutateBatch(calls)
+		require.True(t, slices.Contains(batchMutations, mutation), mutation)
+		switch mutation {
+		case MutationBatchEmpty:
+			require.Empty(t, batch)
+		case MutationBatchNested:
+			require.Len(t, batch, 1)
+			require.IsType(t, []any{}, batch[0])
+		case MutationBatchDuplicateIDs:
+			for _, r := range batch {
+				require.Equal(t, 1, r.(map[string]any)["id"])
+			}
+		case MutationBatchMixed, MutationBatchHuge:
+			require.GreaterOrEqual(t, len(batch), 2)
+			for _, r := range batch {
+				require.Equal(t, "eth_getBalance", r.(map[string]any)["method"])
+			}
+		}
+	}
+}
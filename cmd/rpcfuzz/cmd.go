@@ -4,10 +4,13 @@ import (
 	_ "embed"
 	"fmt"
 	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/0xPolygon/polygon-cli/cmd/rpcfuzz/argfuzz"
 	"github.com/0xPolygon/polygon-cli/flag"
+	"github.com/0xPolygon/polygon-cli/util"
 	"github.com/ethereum/go-ethereum/crypto"
 	fuzz "github.com/google/gofuzz"
 	"github.com/rs/zerolog/log"
@@ -34,6 +37,13 @@ var (
 	outputFilter        string
 	summaryInterval     int
 	quietMode           bool
+	diffRPCURLs         []string
+	diffRuleNames       []string
+	openRPCFile         string
+	fuzzMode            string
+	fuzzTimeout         time.Duration
+	findingsDir         string
+	replayFindingsPath  string
 )
 
 var RPCFuzzCmd = &cobra.Command{
@@ -53,6 +63,9 @@ var RPCFuzzCmd = &cobra.Command{
 		return checkFlags()
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if replayFindingsPath != "" {
+			return runReplayFindings(cmd.Context(), replayFindingsPath)
+		}
 		return runRpcFuzz(cmd.Context())
 	},
 }
@@ -67,6 +80,10 @@ func init() {
 	f.BoolVar(&testFuzz, "fuzz", false, "flag to indicate whether to fuzz input or not")
 	f.IntVar(&testFuzzNum, "fuzzn", 100, "number of times to run fuzzer per test")
 	f.Int64Var(&seed, "seed", 123456, "seed for generating random values within fuzzer")
+	f.StringVar(&fuzzMode, "fuzz-mode", fuzzModeBytes, "how args are fuzzed: bytes mutates their characters, grammar generates invalid values from their types and triages the responses")
+	f.DurationVar(&fuzzTimeout, "fuzz-timeout", 10*time.Second, "time after which a request fuzzed in grammar mode is reported as a timeout")
+	f.StringVar(&findingsDir, "findings-dir", "rpcfuzz-findings", "directory grammar mode findings are saved to as replayable cases (empty to not save them)")
+	f.StringVar(&replayFindingsPath, "replay-findings", "", "send the cases saved in a findings directory or file again instead of running the tests")
 
 	// Streamer type flags (mutually exclusive)
 	f.BoolVar(&streamJSON, "json", false, "stream output in JSON format")
@@ -80,6 +97,12 @@ func init() {
 	f.IntVar(&summaryInterval, "summary-interval", 0, "print summary every N tests (0=disabled)")
 	f.BoolVar(&quietMode, "quiet", false, "only show final summary")
 
+	f.StringVar(&openRPCFile, "openrpc", "", "OpenRPC document, such as execution-apis' openrpc.json, to generate tests from and report method coverage against")
+
+	// Differential mode flags
+	f.StringSliceVar(&diffRPCURLs, "diff-rpc-url", nil, "comma separated RPC endpoints whose responses are compared to --rpc-url's")
+	f.StringSliceVar(&diffRuleNames, "diff-rules", strings.Split(defaultDiffRules, ","), "comma separated rules normalising known-benign differences ("+diffRuleList()+")")
+
 	argfuzz.SetSeed(&seed)
 
 	fuzzer = fuzz.New()
@@ -109,6 +132,32 @@ func checkFlags() (err error) {
 		return fmt.Errorf("only one output format can be specified: --json, --csv, --compact, --html, or --md")
 	}
 
+	// Check fuzzing flags. The seed is applied again now that it's parsed.
+	if !slices.Contains([]string{fuzzModeBytes, fuzzModeGrammar}, fuzzMode) {
+		return fmt.Errorf("invalid --fuzz-mode %s: must be %s or %s", fuzzMode, fuzzModeBytes, fuzzModeGrammar)
+	}
+	if fuzzTimeout <= 0 {
+		return fmt.Errorf("--fuzz-timeout must be positive")
+	}
+	argfuzz.SetSeed(&seed)
+
+	// Check differential mode flags.
+	for _, u := range diffRPCURLs {
+		if err = util.ValidateURL(u); err != nil {
+			return fmt.Errorf("invalid --diff-rpc-url %s: %w", u, err)
+		}
+	}
+	if enabledDiffRules, err = selectDiffRules(diffRuleNames); err != nil {
+		return err
+	}
+
+	// Load the OpenRPC document.
+	if openRPCFile != "" {
+		if openRPCDoc, err = loadOpenRPC(openRPCFile); err != nil {
+			return err
+		}
+	}
+
 	// Check private key flag.
 	trimmedHexPrivateKey := strings.TrimPrefix(testPrivateHexKey, "0x")
 	privateKey, err := crypto.HexToECDSA(trimmedHexPrivateKey)
@@ -0,0 +1,490 @@
+package rpcfuzz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0xPolygon/polygon-cli/cmd/rpcfuzz/streamer"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Kinds of differences between two responses.
+	diffKindResult       = "result"        // the values at the path differ
+	diffKindMissing      = "missing"       // the field is only in one response
+	diffKindError        = "error"         // only one endpoint returned an error
+	diffKindErrorCode    = "error_code"    // both returned errors with different codes
+	diffKindErrorMessage = "error_message" // both returned errors with different messages
+
+	// maxDiffsPerEndpoint bounds the differences reported for one call to one
+	// endpoint, since a single shifted array can differ everywhere.
+	maxDiffsPerEndpoint = 20
+
+	defaultDiffRules = "volatile-methods,hex-case,null-fields,client-fields"
+)
+
+type (
+	// diffEndpoint is an RPC endpoint whose responses are compared.
+	diffEndpoint struct {
+		url  string
+		rpc  *rpc.Client
+		http wrappedHTTPClient
+	}
+
+	// diffResponse is an endpoint's response to a call. Errors are kept in
+	// their JSON-RPC form so they compare like results.
+	diffResponse struct {
+		Result any           `json:"result,omitempty"`
+		Error  *RPCJSONError `json:"error,omitempty"`
+	}
+
+	// diffRule normalises a known-benign difference between clients before
+	// responses are compared.
+	diffRule struct {
+		Name        string
+		Description string
+
+		// skip reports whether responses to the method aren't compared at all.
+		skip func(method string) bool
+		// normalize rewrites a response before it's compared.
+		normalize func(resp *diffResponse)
+	}
+)
+
+var (
+	// volatileMethods return results that differ between nodes by design.
+	volatileMethods = []string{
+		"web3_clientVersion",
+		"net_listening",
+		"net_peerCount",
+		"eth_syncing",
+		"eth_coinbase",
+		"eth_accounts",
+		"eth_mining",
+		"eth_hashrate",
+		"eth_blockNumber",
+		"eth_gasPrice",
+		"eth_maxPriorityFeePerGas",
+		"eth_blobBaseFee",
+		"eth_feeHistory",
+		"eth_newFilter",
+		"eth_newBlockFilter",
+		"eth_newPendingTransactionFilter",
+	}
+
+	// stateChangingMethods are only sent to the reference endpoint, since
+	// sending the same transaction to every node makes all but the first
+	// reject it.
+	stateChangingMethods = []string{
+		"eth_sendRawTransaction",
+		"eth_sendTransaction",
+	}
+
+	// clientFields are fields that only some clients return.
+	clientFields = []string{
+		"totalDifficulty",
+		"author",
+	}
+
+	diffRules = []diffRule{
+		{
+			Name:        "volatile-methods",
+			Description: "skip methods whose results differ between nodes by design, such as peer counts, gas price estimates and filter IDs",
+			skip: func(method string) bool {
+				return slices.Contains(volatileMethods, method) || strings.HasPrefix(method, "txpool_")
+			},
+		},
+		{
+			Name:        "hex-case",
+			Description: "compare hex strings case-insensitively, such as checksummed and lowercase addresses",
+			normalize: func(resp *diffResponse) {
+				lower := func(s string) string {
+					if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+						return strings.ToLower(s)
+					}
+					return s
+				}
+				resp.Result = rewriteStrings(resp.Result, lower)
+				if resp.Error != nil {
+					resp.Error.Data = rewriteStrings(resp.Error.Data, lower)
+				}
+			},
+		},
+		{
+			Name:        "null-fields",
+			Description: "treat fields set to null as missing",
+			normalize: func(resp *diffResponse) {
+				resp.Result = dropFields(resp.Result, func(_ string, v any) bool { return v == nil })
+			},
+		},
+		{
+			Name:        "client-fields",
+			Description: fmt.Sprintf("ignore fields only some clients return: %s", strings.Join(clientFields, ", ")),
+			normalize: func(resp *diffResponse) {
+				resp.Result = dropFields(resp.Result, func(key string, _ any) bool { return slices.Contains(clientFields, key) })
+			},
+		},
+		{
+			Name:        "error-messages",
+			Description: "compare error codes only, since clients word their errors differently",
+			normalize: func(resp *diffResponse) {
+				if resp.Error != nil {
+					resp.Error.Message = ""
+					resp.Error.Data = nil
+				}
+			},
+		},
+	}
+
+	// enabledDiffRules are the rules selected with --diff-rules.
+	enabledDiffRules []diffRule
+)
+
+// selectDiffRules looks up the rules with the given names.
+func selectDiffRules(names []string) ([]diffRule, error) {
+	var rules []diffRule
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		i := slices.IndexFunc(diffRules, func(r diffRule) bool { return r.Name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown diff rule %q", name)
+		}
+		rules = append(rules, diffRules[i])
+	}
+	return rules, nil
+}
+
+// dialDiffEndpoints dials the reference endpoint followed by the endpoints
+// compared to it. The dialed clients are closed with closeDiffEndpoints, and
+// on error before returning.
+func dialDiffEndpoints(ctx context.Context, rpcClient *rpc.Client, httpClient *http.Client) ([]diffEndpoint, error) {
+	endpoints := []diffEndpoint{{url: rpcURL, rpc: rpcClient, http: wrappedHTTPClient{httpClient, rpcURL}}}
+	for _, url := range diffRPCURLs {
+		client, err := rpc.DialContext(ctx, url)
+		if err != nil {
+			closeDiffEndpoints(endpoints)
+			return nil, fmt.Errorf("unable to dial %s: %w", url, err)
+		}
+		endpoints = append(endpoints, diffEndpoint{url: url, rpc: client, http: wrappedHTTPClient{httpClient, url}})
+	}
+	return endpoints, nil
+}
+
+// closeDiffEndpoints closes the clients dialed by dialDiffEndpoints, leaving
+// the reference endpoint's client to its owner.
+func closeDiffEndpoints(endpoints []diffEndpoint) {
+	if len(endpoints) < 2 {
+		return
+	}
+	for _, e := range endpoints[1:] {
+		e.rpc.Close()
+	}
+}
+
+// shouldDiffTest reports whether a test's responses are compared across
+// endpoints. Other tests are validated against the reference endpoint only.
+func shouldDiffTest(t RPCTest) bool {
+	if slices.Contains(stateChangingMethods, t.GetMethod()) {
+		return false
+	}
+	for _, rule := range enabledDiffRules {
+		if rule.skip != nil && rule.skip(t.GetMethod()) {
+			return false
+		}
+	}
+	return true
+}
+
+// call sends the test's method with the args to the endpoint.
+func (e diffEndpoint) call(ctx context.Context, t RPCTest, args []any) diffResponse {
+	if _, ok := t.(*RPCTestRawHTTP); ok {
+		body, err := e.http.post(t.GetMethod(), args)
+		if err != nil {
+			return diffResponse{Error: &RPCJSONError{Message: err.Error()}}
+		}
+		// Raw tests compare the whole body, which may be a batch response.
+		var result any
+		if err := json.Unmarshal(body, &result); err != nil {
+			result = string(body)
+		}
+		return diffResponse{Result: result}
+	}
+
+	var result any
+	err := e.rpc.CallContext(ctx, &result, t.GetMethod(), args...)
+	if err == nil {
+		return diffResponse{Result: result}
+	}
+
+	// Transport errors have no code, so they differ from any RPC error.
+	rpcErr := &RPCJSONError{Message: err.Error()}
+	var codeErr rpc.Error
+	if errors.As(err, &codeErr) {
+		rpcErr.Code = codeErr.ErrorCode()
+	}
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		rpcErr.Data = dataErr.ErrorData()
+	}
+	return diffResponse{Error: rpcErr}
+}
+
+// diffCall sends the same call to every endpoint at once, so they answer for
+// the same chain head as far as possible, and compares each response to the
+// reference endpoint's. It returns the raw responses by endpoint URL.
+func diffCall(ctx context.Context, endpoints []diffEndpoint, t RPCTest, args []any) (map[string]diffResponse, []streamer.Difference) {
+	responses := make([]diffResponse, len(endpoints))
+	var wg sync.WaitGroup
+	for i, e := range endpoints {
+		wg.Go(func() {
+			responses[i] = e.call(ctx, t, args)
+		})
+	}
+	wg.Wait()
+
+	raw := make(map[string]diffResponse, len(endpoints))
+	for i, e := range endpoints {
+		raw[e.url] = responses[i]
+	}
+
+	expected := normalizeResponse(responses[0])
+	var differences []streamer.Difference
+	for i, e := range endpoints[1:] {
+		actual := normalizeResponse(responses[i+1])
+		differences = append(differences, compareResponses(e.url, expected, actual)...)
+	}
+	return raw, differences
+}
+
+// normalizeResponse applies the enabled rules to a copy of the response.
+func normalizeResponse(resp diffResponse) diffResponse {
+	// Round trip through JSON so the rules can modify the copy and results
+	// of every endpoint have the same Go types.
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return resp
+	}
+	var normalized diffResponse
+	if err := json.Unmarshal(b, &normalized); err != nil {
+		return resp
+	}
+
+	for _, rule := range enabledDiffRules {
+		if rule.normalize != nil {
+			rule.normalize(&normalized)
+		}
+	}
+	return normalized
+}
+
+// compareResponses lists the differences of the endpoint's response from the
+// expected one.
+func compareResponses(endpoint string, expected, actual diffResponse) []streamer.Difference {
+	var differences []streamer.Difference
+	add := func(path, kind string, e, a any) {
+		if len(differences) < maxDiffsPerEndpoint {
+			differences = append(differences, streamer.Difference{
+				Endpoint: endpoint,
+				Path:     path,
+				Kind:     kind,
+				Expected: e,
+				Actual:   a,
+			})
+		}
+	}
+
+	switch {
+	case expected.Error == nil && actual.Error == nil:
+		compareValues("result", expected.Result, actual.Result, add)
+	case expected.Error != nil && actual.Error != nil:
+		if expected.Error.Code != actual.Error.Code {
+			add("error.code", diffKindErrorCode, expected.Error.Code, actual.Error.Code)
+		}
+		if expected.Error.Message != actual.Error.Message {
+			add("error.message", diffKindErrorMessage, expected.Error.Message, actual.Error.Message)
+		}
+		compareValues("error.data", expected.Error.Data, actual.Error.Data, add)
+	default:
+		add("error", diffKindError, expected, actual)
+	}
+
+	return differences
+}
+
+// compareValues walks two decoded JSON values and reports where they differ.
+func compareValues(path string, expected, actual any, add func(path, kind string, expected, actual any)) {
+	switch e := expected.(type) {
+	case map[string]any:
+		a, ok := actual.(map[string]any)
+		if !ok {
+			add(path, diffKindResult, expected, actual)
+			return
+		}
+		keys := slices.Sorted(maps.Keys(e))
+		for k := range a {
+			if _, ok := e[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		for _, k := range keys {
+			ev, eok := e[k]
+			av, aok := a[k]
+			if !eok || !aok {
+				add(path+"."+k, diffKindMissing, ev, av)
+				continue
+			}
+			compareValues(path+"."+k, ev, av, add)
+		}
+	case []any:
+		a, ok := actual.([]any)
+		if !ok || len(a) != len(e) {
+			add(path, diffKindResult, expected, actual)
+			return
+		}
+		for i := range e {
+			compareValues(fmt.Sprintf("%s[%d]", path, i), e[i], a[i], add)
+		}
+	default:
+		if !reflect.DeepEqual(expected, actual) {
+			add(path, diffKindResult, expected, actual)
+		}
+	}
+}
+
+// rewriteStrings applies fn to every string in a decoded JSON value.
+func rewriteStrings(v any, fn func(string) string) any {
+	switch v := v.(type) {
+	case string:
+		return fn(v)
+	case map[string]any:
+		for k, e := range v {
+			v[k] = rewriteStrings(e, fn)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = rewriteStrings(e, fn)
+		}
+	}
+	return v
+}
+
+// dropFields removes the object fields of a decoded JSON value for which drop
+// returns true, at any depth.
+func dropFields(v any, drop func(key string, v any) bool) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if drop(k, e) {
+				delete(v, k)
+				continue
+			}
+			v[k] = dropFields(e, drop)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = dropFields(e, drop)
+		}
+	}
+	return v
+}
+
+// diffExecution builds the streamed execution of a compared call. It fails if
+// any endpoint's response differs from the reference endpoint's.
+func diffExecution(name, method string, args []any, responses map[string]diffResponse, differences []streamer.Difference, start time.Time) streamer.TestExecution {
+	execution := streamer.TestExecution{
+		TestName:    name,
+		Method:      method,
+		Args:        args,
+		Result:      responses,
+		Status:      "pass",
+		Duration:    time.Since(start),
+		Timestamp:   time.Now(),
+		Differences: differences,
+	}
+
+	if len(differences) > 0 {
+		execution.Status = "fail"
+		descriptions := make([]string, 0, len(differences))
+		for _, d := range differences {
+			expected, _ := json.Marshal(d.Expected)
+			actual, _ := json.Marshal(d.Actual)
+			descriptions = append(descriptions, fmt.Sprintf("%s %s at %s: %s != %s", d.Endpoint, d.Kind, d.Path, expected, actual))
+		}
+		execution.Error = fmt.Sprintf("%d differences: %s", len(differences), strings.Join(descriptions, "; "))
+	}
+
+	return execution
+}
+
+// CallRPCAndDiff sends the test's args to every endpoint and compares the
+// responses to the reference endpoint's.
+func CallRPCAndDiff(ctx context.Context, endpoints []diffEndpoint, currTest RPCTest) streamer.TestExecution {
+	start := time.Now()
+	args := currTest.GetArgs()
+
+	responses, differences := diffCall(ctx, endpoints, currTest, args)
+	if len(differences) > 0 {
+		log.Debug().Str("name", currTest.GetName()).Int("differences", len(differences)).Msg("Responses differ")
+	}
+	return diffExecution(currTest.GetName(), currTest.GetMethod(), args, responses, differences, start)
+}
+
+// CallRPCWithFuzzAndDiff sends fuzzed variants of the test's args to every
+// endpoint and compares the responses to the reference endpoint's.
+func CallRPCWithFuzzAndDiff(ctx context.Context, endpoints []diffEndpoint, currTest RPCTest, outputStreamer streamer.OutputStreamer) streamer.TestSummary {
+	summary := streamer.TestSummary{
+		TestName: currTest.GetName() + "-FUZZED",
+		Method:   currTest.GetMethod(),
+	}
+
+	originalArgs := currTest.GetArgs()
+	for i := 0; i < testFuzzNum; i++ {
+		args := fuzzArgs(originalArgs)
+
+		start := time.Now()
+		responses, differences := diffCall(ctx, endpoints, currTest, args)
+		execution := diffExecution(summary.TestName, summary.Method, args, responses, differences, start)
+
+		summary.TestsRan++
+		summary.TotalDuration += execution.Duration
+		if execution.Status == "pass" {
+			summary.TestsPassed++
+		} else {
+			summary.TestsFailed++
+		}
+
+		if shouldOutput(execution) {
+			iErr := outputStreamer.StreamTestExecution(execution)
+			if iErr != nil {
+				log.Error().Err(iErr).Msg("Unable to stream test execution")
+			}
+		}
+	}
+
+	if summary.TestsRan > 0 {
+		summary.SuccessRate = float64(summary.TestsPassed) / float64(summary.TestsRan)
+	}
+
+	return summary
+}
+
+// diffRuleList describes the available rules for the --diff-rules flag.
+func diffRuleList() string {
+	names := make([]string, 0, len(diffRules))
+	for _, rule := range diffRules {
+		names = append(names, rule.Name)
+	}
+	return strings.Join(names, ", ")
+}
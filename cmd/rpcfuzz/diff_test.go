@@ -0,0 +1,256 @@
+package rpcfuzz
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/0xPolygon/polygon-cli/cmd/rpcfuzz/streamer"
+	"github.com/stretchr/testify/require"
+)
+
+// decode parses a JSON value the way responses are decoded.
+func decode(t *testing.T, s string) any {
+	t.Helper()
+	var v any
+	require.NoError(t, json.Unmarshal([]byte(s), &v))
+	return v
+}
+
+// withDiffRules enables the named rules for the duration of the test.
+func withDiffRules(t *testing.T, names ...string) {
+	t.Helper()
+	rules, err := selectDiffRules(names)
+	require.NoError(t, err)
+	previous := enabledDiffRules
+	enabledDiffRules = rules
+	t.Cleanup(func() { enabledDiffRules = previous })
+}
+
+func TestCompareValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		actual   string
+		want     []streamer.Difference
+	}{
+		{
+			name:     "equal",
+			expected: `{"number":"0x1","transactions":["0xa","0xb"]}`,
+			actual:   `{"transactions":["0xa","0xb"],"number":"0x1"}`,
+		},
+		{
+			name:     "value",
+			expected: `{"transactions":[{"hash":"0xa"},{"hash":"0xb"}]}`,
+			actual:   `{"transactions":[{"hash":"0xa"},{"hash":"0xc"}]}`,
+			want:     []streamer.Difference{{Path: "result.transactions[1].hash", Kind: diffKindResult, Expected: "0xb", Actual: "0xc"}},
+		},
+		{
+			name:     "missing fields",
+			expected: `{"a":1,"b":2}`,
+			actual:   `{"a":1,"c":3}`,
+			want: []streamer.Difference{
+				{Path: "result.b", Kind: diffKindMissing, Expected: float64(2)},
+				{Path: "result.c", Kind: diffKindMissing, Actual: float64(3)},
+			},
+		},
+		{
+			name:     "array length mismatch",
+			expected: `{"logs":[1,2,3]}`,
+			actual:   `{"logs":[1,2]}`,
+			want: []streamer.Difference{{
+				Path:     "result.logs",
+				Kind:     diffKindResult,
+				Expected: []any{float64(1), float64(2), float64(3)},
+				Actual:   []any{float64(1), float64(2)},
+			}},
+		},
+		{
+			name:     "type mismatch",
+			expected: `{"a":1}`,
+			actual:   `"0x1"`,
+			want:     []streamer.Difference{{Path: "result", Kind: diffKindResult, Expected: map[string]any{"a": float64(1)}, Actual: "0x1"}},
+		},
+		{
+			name:     "null and missing",
+			expected: `{"a":null}`,
+			actual:   `{}`,
+			want:     []streamer.Difference{{Path: "result.a", Kind: diffKindMissing}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []streamer.Difference
+			compareValues("result", decode(t, tt.expected), decode(t, tt.actual), func(path, kind string, expected, actual any) {
+				got = append(got, streamer.Difference{Path: path, Kind: kind, Expected: expected, Actual: actual})
+			})
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCompareResponses(t *testing.T) {
+	result := diffResponse{Result: "0x1"}
+	rpcErr := diffResponse{Error: &RPCJSONError{Code: -32000, Message: "header not found"}}
+
+	t.Run("error and result", func(t *testing.T) {
+		got := compareResponses("b", result, rpcErr)
+		require.Equal(t, []streamer.Difference{{Endpoint: "b", Path: "error", Kind: diffKindError, Expected: result, Actual: rpcErr}}, got)
+
+		got = compareResponses("b", rpcErr, result)
+		require.Len(t, got, 1)
+		require.Equal(t, diffKindError, got[0].Kind)
+	})
+
+	t.Run("different errors", func(t *testing.T) {
+		other := diffResponse{Error: &RPCJSONError{Code: -32602, Message: "invalid argument 0", Data: "0x"}}
+		got := compareResponses("b", rpcErr, other)
+		require.Equal(t, []streamer.Difference{
+			{Endpoint: "b", Path: "error.code", Kind: diffKindErrorCode, Expected: -32000, Actual: -32602},
+			{Endpoint: "b", Path: "error.message", Kind: diffKindErrorMessage, Expected: "header not found", Actual: "invalid argument 0"},
+			{Endpoint: "b", Path: "error.data", Kind: diffKindResult, Expected: nil, Actual: "0x"},
+		}, got)
+	})
+
+	t.Run("capped", func(t *testing.T) {
+		expected := make([]any, 3*maxDiffsPerEndpoint)
+		actual := make([]any, len(expected))
+		for i := range expected {
+			expected[i] = fmt.Sprintf("0x%x", i)
+			actual[i] = fmt.Sprintf("0x%x", i+1)
+		}
+		got := compareResponses("b", diffResponse{Result: expected}, diffResponse{Result: actual})
+		require.Len(t, got, maxDiffsPerEndpoint)
+		require.Equal(t, "result[0]", got[0].Path)
+		require.Equal(t, fmt.Sprintf("result[%d]", maxDiffsPerEndpoint-1), got[maxDiffsPerEndpoint-1].Path)
+	})
+}
+
+func TestNormalizeResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    []string
+		expected diffResponse
+		actual   diffResponse
+		equal    bool
+	}{
+		{
+			name:     "checksummed address",
+			rules:    []string{"hex-case"},
+			expected: diffResponse{Result: map[string]any{"from": "0x85dA99c8a7C2C95964c8EfD687E95E632Fc533D6"}},
+			actual:   diffResponse{Result: map[string]any{"from": "0x85da99c8a7c2c95964c8efd687e95e632fc533d6"}},
+			equal:    true,
+		},
+		{
+			name:     "checksummed address without rule",
+			expected: diffResponse{Result: map[string]any{"from": "0x85dA99c8a7C2C95964c8EfD687E95E632Fc533D6"}},
+			actual:   diffResponse{Result: map[string]any{"from": "0x85da99c8a7c2c95964c8efd687e95e632fc533d6"}},
+		},
+		{
+			name:     "hex case in error data",
+			rules:    []string{"hex-case"},
+			expected: diffResponse{Error: &RPCJSONError{Code: 3, Message: "execution reverted", Data: "0xABCD"}},
+			actual:   diffResponse{Error: &RPCJSONError{Code: 3, Message: "execution reverted", Data: "0xabcd"}},
+			equal:    true,
+		},
+		{
+			name:     "text is not hex",
+			rules:    []string{"hex-case"},
+			expected: diffResponse{Result: "Geth"},
+			actual:   diffResponse{Result: "geth"},
+		},
+		{
+			name:     "null fields",
+			rules:    []string{"null-fields"},
+			expected: diffResponse{Result: map[string]any{"to": nil, "logs": []any{map[string]any{"removed": false, "blockHash": nil}}}},
+			actual:   diffResponse{Result: map[string]any{"logs": []any{map[string]any{"removed": false}}}},
+			equal:    true,
+		},
+		{
+			name:     "client fields",
+			rules:    []string{"client-fields"},
+			expected: diffResponse{Result: map[string]any{"number": "0x1", "totalDifficulty": "0x0"}},
+			actual:   diffResponse{Result: map[string]any{"number": "0x1"}},
+			equal:    true,
+		},
+		{
+			name:     "client fields without rule",
+			rules:    []string{"null-fields"},
+			expected: diffResponse{Result: map[string]any{"number": "0x1", "totalDifficulty": "0x0"}},
+			actual:   diffResponse{Result: map[string]any{"number": "0x1"}},
+		},
+		{
+			name:     "error messages",
+			rules:    []string{"error-messages"},
+			expected: diffResponse{Error: &RPCJSONError{Code: -32602, Message: "invalid argument 0: hex string without 0x prefix"}},
+			actual:   diffResponse{Error: &RPCJSONError{Code: -32602, Message: "invalid params", Data: "x"}},
+			equal:    true,
+		},
+		{
+			name:     "error and result",
+			rules:    strings.Split(defaultDiffRules, ","),
+			expected: diffResponse{Result: nil},
+			actual:   diffResponse{Error: &RPCJSONError{Code: -32000, Message: "not found"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withDiffRules(t, tt.rules...)
+			differences := compareResponses("b", normalizeResponse(tt.expected), normalizeResponse(tt.actual))
+			if tt.equal {
+				require.Empty(t, differences)
+			} else {
+				require.NotEmpty(t, differences)
+			}
+		})
+	}
+}
+
+// TestNormalizeResponseCopies checks the rules leave the raw responses, which
+// are streamed as they are, untouched.
+func TestNormalizeResponseCopies(t *testing.T) {
+	withDiffRules(t, "hex-case", "null-fields", "client-fields")
+
+	raw := diffResponse{Result: map[string]any{"hash": "0xABC", "to": nil, "totalDifficulty": "0x1"}}
+	normalized := normalizeResponse(raw)
+
+	require.Equal(t, map[string]any{"hash": "0xabc"}, normalized.Result)
+	require.Equal(t, map[string]any{"hash": "0xABC", "to": nil, "totalDifficulty": "0x1"}, raw.Result)
+}
+
+func TestRewriteStrings(t *testing.T) {
+	got := rewriteStrings(decode(t, `{"a":["X",{"b":"Y"}],"c":1,"d":null}`), strings.ToLower)
+	require.Equal(t, decode(t, `{"a":["x",{"b":"y"}],"c":1,"d":null}`), got)
+	require.Equal(t, "z", rewriteStrings("Z", strings.ToLower))
+}
+
+func TestDropFields(t *testing.T) {
+	dropNull := func(_ string, v any) bool { return v == nil }
+	got := dropFields(decode(t, `[{"a":null,"b":{"c":null,"d":1}},[{"e":null}],null]`), dropNull)
+	require.Equal(t, decode(t, `[{"b":{"d":1}},[{}],null]`), got)
+}
+
+func TestSelectDiffRules(t *testing.T) {
+	rules, err := selectDiffRules(strings.Split(defaultDiffRules, ","))
+	require.NoError(t, err)
+	require.Len(t, rules, 4)
+
+	_, err = selectDiffRules([]string{"hex-case", "typo"})
+	require.ErrorContains(t, err, `unknown diff rule "typo"`)
+}
+
+func TestShouldDiffTest(t *testing.T) {
+	withDiffRules(t, "volatile-methods")
+
+	require.True(t, shouldDiffTest(&RPCTestGeneric{Method: "eth_getBlockByNumber"}))
+	require.False(t, shouldDiffTest(&RPCTestGeneric{Method: "eth_blockNumber"}))
+	require.False(t, shouldDiffTest(&RPCTestGeneric{Method: "txpool_status"}))
+	require.False(t, shouldDiffTest(&RPCTestGeneric{Method: "eth_sendRawTransaction"}))
+
+	withDiffRules(t)
+	require.True(t, shouldDiffTest(&RPCTestGeneric{Method: "eth_blockNumber"}))
+	require.False(t, shouldDiffTest(&RPCTestGeneric{Method: "eth_sendRawTransaction"}))
+}
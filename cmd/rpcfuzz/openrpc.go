@@ -0,0 +1,265 @@
+package rpcfuzz
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/0xPolygon/polygon-cli/cmd/rpcfuzz/streamer"
+	"github.com/rs/zerolog/log"
+)
+
+// maxOpenRPCRefDepth bounds how many $refs are expanded within each other, so
+// recursive schemas terminate. Deeper refs accept any value.
+const maxOpenRPCRefDepth = 16
+
+type (
+	// openRPCDocument is the part of an OpenRPC document tests are generated
+	// from, such as the execution-apis openrpc.json.
+	openRPCDocument struct {
+		Methods []openRPCMethod `json:"methods"`
+	}
+
+	openRPCMethod struct {
+		Name     string                     `json:"name"`
+		Params   []openRPCContentDescriptor `json:"params"`
+		Result   *openRPCContentDescriptor  `json:"result"`
+		Examples []openRPCExample           `json:"examples"`
+	}
+
+	openRPCContentDescriptor struct {
+		Name     string `json:"name"`
+		Required bool   `json:"required"`
+		Schema   any    `json:"schema"`
+	}
+
+	// openRPCExample pairs example params, in positional order, with the
+	// result they produce.
+	openRPCExample struct {
+		Name   string                  `json:"name"`
+		Params []openRPCExamplePairing `json:"params"`
+		Result *openRPCExamplePairing  `json:"result"`
+	}
+
+	openRPCExamplePairing struct {
+		Name  string `json:"name"`
+		Value any    `json:"value"`
+	}
+)
+
+// openRPCDoc is the specification loaded with --openrpc.
+var openRPCDoc *openRPCDocument
+
+// loadOpenRPC reads an OpenRPC document, expanding every local $ref so
+// schemas can be validated against on their own.
+func loadOpenRPC(path string) (*openRPCDocument, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read OpenRPC document: %w", err)
+	}
+
+	var root any
+	if err = json.Unmarshal(b, &root); err != nil {
+		return nil, fmt.Errorf("unable to parse OpenRPC document: %w", err)
+	}
+
+	resolved, err := resolveOpenRPCRefs(root, root, 0)
+	if err != nil {
+		return nil, err
+	}
+	if b, err = json.Marshal(resolved); err != nil {
+		return nil, err
+	}
+
+	doc := new(openRPCDocument)
+	if err = json.Unmarshal(b, doc); err != nil {
+		return nil, fmt.Errorf("unable to parse OpenRPC methods: %w", err)
+	}
+	if len(doc.Methods) == 0 {
+		return nil, fmt.Errorf("the OpenRPC document %s has no methods", path)
+	}
+	return doc, nil
+}
+
+// resolveOpenRPCRefs replaces the objects holding a "$ref" to a JSON pointer
+// in the document with the value it points to.
+func resolveOpenRPCRefs(v, root any, depth int) (any, error) {
+	switch v := v.(type) {
+	case map[string]any:
+		if ref, ok := v["$ref"].(string); ok {
+			if depth >= maxOpenRPCRefDepth {
+				return map[string]any{}, nil
+			}
+			target, err := lookupJSONPointer(root, ref)
+			if err != nil {
+				return nil, err
+			}
+			return resolveOpenRPCRefs(target, root, depth+1)
+		}
+
+		resolved := make(map[string]any, len(v))
+		for k, e := range v {
+			r, err := resolveOpenRPCRefs(e, root, depth)
+			if err != nil {
+				return nil, err
+			}
+			resolved[k] = r
+		}
+		return resolved, nil
+	case []any:
+		resolved := make([]any, len(v))
+		for i, e := range v {
+			r, err := resolveOpenRPCRefs(e, root, depth)
+			if err != nil {
+				return nil, err
+			}
+			resolved[i] = r
+		}
+		return resolved, nil
+	default:
+		return v, nil
+	}
+}
+
+// lookupJSONPointer returns the value a local reference such as
+// "#/components/schemas/uint" points to.
+func lookupJSONPointer(root any, ref string) (any, error) {
+	pointer, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, fmt.Errorf("unsupported $ref %s: only references within the document are supported", ref)
+	}
+
+	v := root
+	for token := range strings.SplitSeq(strings.TrimPrefix(pointer, "/"), "/") {
+		if token == "" {
+			continue
+		}
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unresolvable $ref %s", ref)
+		}
+		if v, ok = obj[token]; !ok {
+			return nil, fmt.Errorf("unresolvable $ref %s", ref)
+		}
+	}
+	return v, nil
+}
+
+// openRPCTests generates a test for each example of each method, calling it
+// with the example's params and validating the result against the method's
+// result schema. Examples whose params don't match the params' schemas are
+// skipped, since the endpoint would rightly reject them. Example results are
+// taken from whichever chain the document was written against, so only their
+// shape is checked, through the result schema. Methods without examples are
+// called without params if none are required. Methods left without tests are
+// logged as untested.
+func openRPCTests(doc *openRPCDocument) []RPCTest {
+	var tests []RPCTest
+	var untested []string
+
+	for _, m := range doc.Methods {
+		validator := func(any) error { return nil }
+		if m.Result != nil && m.Result.Schema != nil {
+			schema, err := json.Marshal(m.Result.Schema)
+			if err != nil {
+				log.Warn().Err(err).Str("method", m.Name).Msg("Unable to marshal result schema")
+				untested = append(untested, m.Name)
+				continue
+			}
+			validator = ValidateJSONSchema(string(schema))
+		}
+
+		if len(m.Examples) == 0 {
+			if slices.ContainsFunc(m.Params, func(p openRPCContentDescriptor) bool { return p.Required }) {
+				untested = append(untested, m.Name)
+				continue
+			}
+			tests = append(tests, &RPCTestGeneric{
+				Name:      fmt.Sprintf("RPCTestOpenRPC_%s", m.Name),
+				Method:    m.Name,
+				Args:      []any{},
+				Validator: validator,
+			})
+			continue
+		}
+
+		generated := len(tests)
+		for i, example := range m.Examples {
+			args := make([]any, 0, len(example.Params))
+			for _, param := range example.Params {
+				args = append(args, param.Value)
+			}
+			if err := validateOpenRPCParams(m.Params, args); err != nil {
+				log.Warn().Err(err).Str("method", m.Name).Str("example", example.Name).Msg("Skipping example with invalid params")
+				continue
+			}
+			tests = append(tests, &RPCTestGeneric{
+				Name:      fmt.Sprintf("RPCTestOpenRPC_%s_%d", m.Name, i),
+				Method:    m.Name,
+				Args:      args,
+				Validator: validator,
+			})
+		}
+		if len(tests) == generated {
+			untested = append(untested, m.Name)
+		}
+	}
+
+	log.Info().
+		Int("methods", len(doc.Methods)).
+		Int("tests", len(tests)).
+		Strs("untested", untested).
+		Msg("Generated tests from the OpenRPC document")
+
+	return tests
+}
+
+// validateOpenRPCParams checks positional args against the schemas of the
+// params they're passed as.
+func validateOpenRPCParams(params []openRPCContentDescriptor, args []any) error {
+	for i, arg := range args {
+		if i >= len(params) {
+			return fmt.Errorf("unexpected param %d", i)
+		}
+		if params[i].Schema == nil {
+			continue
+		}
+		schema, err := json.Marshal(params[i].Schema)
+		if err != nil {
+			return fmt.Errorf("unable to marshal schema of param %s: %w", params[i].Name, err)
+		}
+		if err = ValidateJSONSchema(string(schema))(arg); err != nil {
+			return fmt.Errorf("param %s: %w", params[i].Name, err)
+		}
+	}
+	return nil
+}
+
+// openRPCCoverage reports which methods of the document were exercised by the
+// tests that ran, whether generated or hand written.
+func openRPCCoverage(doc *openRPCDocument, tests []RPCTest) streamer.Coverage {
+	exercised := make(map[string]bool)
+	for _, t := range tests {
+		if _, raw := t.(*RPCTestRawHTTP); !raw && shouldRunTest(t) {
+			exercised[t.GetMethod()] = true
+		}
+	}
+
+	coverage := streamer.Coverage{Methods: len(doc.Methods)}
+	for _, m := range doc.Methods {
+		if exercised[m.Name] {
+			coverage.Exercised++
+		} else {
+			coverage.Unexercised = append(coverage.Unexercised, m.Name)
+		}
+	}
+	slices.Sort(coverage.Unexercised)
+	if coverage.Methods > 0 {
+		coverage.Rate = float64(coverage.Exercised) / float64(coverage.Methods)
+	}
+
+	return coverage
+}
@@ -0,0 +1,206 @@
+package rpcfuzz
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupJSONPointer(t *testing.T) {
+	root := decode(t, `{
+		"components": {
+			"schemas": {
+				"uint": {"type": "string"},
+				"a/b": {"title": "slash"},
+				"a~b": {"title": "tilde"},
+				"~1": {"title": "escaped tilde then one"}
+			}
+		}
+	}`)
+
+	tests := []struct {
+		name    string
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{name: "nested", ref: "#/components/schemas/uint", want: `{"type":"string"}`},
+		{name: "slash escape", ref: "#/components/schemas/a~1b", want: `{"title":"slash"}`},
+		{name: "tilde escape", ref: "#/components/schemas/a~0b", want: `{"title":"tilde"}`},
+		// ~01 is a literal ~1, not a slash.
+		{name: "escape order", ref: "#/components/schemas/~01", want: `{"title":"escaped tilde then one"}`},
+		{name: "missing path", ref: "#/components/schemas/int", wantErr: true},
+		{name: "through a value", ref: "#/components/schemas/uint/type/x", wantErr: true},
+		{name: "external", ref: "schemas.json#/uint", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := lookupJSONPointer(root, tt.ref)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, decode(t, tt.want), got)
+		})
+	}
+
+	got, err := lookupJSONPointer(root, "#")
+	require.NoError(t, err)
+	require.Equal(t, root, got)
+}
+
+func TestResolveOpenRPCRefs(t *testing.T) {
+	t.Run("nested", func(t *testing.T) {
+		root := decode(t, `{
+			"components": {
+				"schemas": {
+					"uint": {"type": "string", "pattern": "^0x[0-9a-f]+$"},
+					"block": {"type": "object", "properties": {"number": {"$ref": "#/components/schemas/uint"}}}
+				}
+			},
+			"methods": [{"result": {"schema": {"$ref": "#/components/schemas/block"}}}]
+		}`)
+
+		resolved, err := resolveOpenRPCRefs(root, root, 0)
+		require.NoError(t, err)
+		methods := resolved.(map[string]any)["methods"].([]any)
+		require.Equal(t, decode(t, `{"schema": {"type": "object", "properties": {
+			"number": {"type": "string", "pattern": "^0x[0-9a-f]+$"}
+		}}}`), methods[0].(map[string]any)["result"])
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		root := decode(t, `{"node": {"type": "object", "properties": {"next": {"$ref": "#/node"}}}}`)
+
+		resolved, err := resolveOpenRPCRefs(root, root, 0)
+		require.NoError(t, err)
+
+		// The ref is expanded maxOpenRPCRefDepth times, after which the next
+		// one accepts any value.
+		node := resolved.(map[string]any)["node"]
+		for range maxOpenRPCRefDepth + 1 {
+			node = node.(map[string]any)["properties"].(map[string]any)["next"]
+		}
+		require.Equal(t, map[string]any{}, node)
+	})
+
+	t.Run("unresolvable", func(t *testing.T) {
+		root := decode(t, `{"schema": {"$ref": "#/missing"}}`)
+		_, err := resolveOpenRPCRefs(root, root, 0)
+		require.ErrorContains(t, err, "unresolvable $ref #/missing")
+	})
+}
+
+func TestLoadOpenRPC(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "openrpc.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"methods": [{"name": "eth_chainId", "result": {"name": "id", "schema": {"$ref": "#/components/schemas/uint"}}}],
+		"components": {"schemas": {"uint": {"type": "string"}}}
+	}`), 0o644))
+
+	doc, err := loadOpenRPC(path)
+	require.NoError(t, err)
+	require.Len(t, doc.Methods, 1)
+	require.Equal(t, map[string]any{"type": "string"}, doc.Methods[0].Result.Schema)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"methods": []}`), 0o644))
+	_, err = loadOpenRPC(path)
+	require.ErrorContains(t, err, "has no methods")
+}
+
+// testOpenRPCDocument has a method with examples, one without params, one with
+// a required param and no examples, and one whose only example doesn't match
+// its param schema.
+func testOpenRPCDocument() *openRPCDocument {
+	quantity := map[string]any{"type": "string", "pattern": "^0x[0-9a-f]+$"}
+	return &openRPCDocument{Methods: []openRPCMethod{
+		{
+			Name:   "eth_getBlockByNumber",
+			Params: []openRPCContentDescriptor{{Name: "block", Required: true, Schema: quantity}, {Name: "hydrated", Schema: map[string]any{"type": "boolean"}}},
+			Result: &openRPCContentDescriptor{Name: "block", Schema: map[string]any{"type": "object"}},
+			Examples: []openRPCExample{
+				{Name: "latest", Params: []openRPCExamplePairing{{Name: "block", Value: "0x1"}, {Name: "hydrated", Value: false}}},
+				{Name: "genesis", Params: []openRPCExamplePairing{{Name: "block", Value: "0x0"}}},
+			},
+		},
+		{
+			Name:   "eth_chainId",
+			Result: &openRPCContentDescriptor{Name: "id", Schema: quantity},
+		},
+		{
+			Name:   "eth_getBalance",
+			Params: []openRPCContentDescriptor{{Name: "address", Required: true}},
+		},
+		{
+			Name:     "eth_getCode",
+			Params:   []openRPCContentDescriptor{{Name: "address", Required: true, Schema: map[string]any{"type": "string"}}},
+			Examples: []openRPCExample{{Name: "number", Params: []openRPCExamplePairing{{Name: "address", Value: 1.0}}}},
+		},
+	}}
+}
+
+func TestOpenRPCTests(t *testing.T) {
+	tests := openRPCTests(testOpenRPCDocument())
+
+	var names []string
+	for _, test := range tests {
+		names = append(names, test.GetName())
+	}
+	require.Equal(t, []string{
+		"RPCTestOpenRPC_eth_getBlockByNumber_0",
+		"RPCTestOpenRPC_eth_getBlockByNumber_1",
+		"RPCTestOpenRPC_eth_chainId",
+	}, names)
+
+	require.Equal(t, []any{"0x1", false}, tests[0].GetArgs())
+	require.Equal(t, []any{"0x0"}, tests[1].GetArgs())
+	require.Equal(t, []any{}, tests[2].GetArgs())
+
+	// Results are validated against the result schema.
+	require.NoError(t, tests[0].Validate(map[string]any{"number": "0x1"}))
+	require.Error(t, tests[0].Validate("0x1"))
+	require.NoError(t, tests[2].Validate("0x89"))
+	require.Error(t, tests[2].Validate("137"))
+}
+
+func TestValidateOpenRPCParams(t *testing.T) {
+	params := testOpenRPCDocument().Methods[0].Params
+
+	require.NoError(t, validateOpenRPCParams(params, []any{"0x1", true}))
+	require.NoError(t, validateOpenRPCParams(params, []any{"0x1"}))
+	require.ErrorContains(t, validateOpenRPCParams(params, []any{"latest"}), "param block")
+	require.ErrorContains(t, validateOpenRPCParams(params, []any{"0x1", "yes"}), "param hydrated")
+	require.ErrorContains(t, validateOpenRPCParams(params, []any{"0x1", true, 1.0}), "unexpected param 2")
+}
+
+func TestOpenRPCCoverage(t *testing.T) {
+	previous := enabledNamespaces
+	enabledNamespaces = []string{"eth_"}
+	t.Cleanup(func() { enabledNamespaces = previous })
+
+	doc := testOpenRPCDocument()
+	tests := openRPCTests(doc)
+	// Hand written tests count too, unless their namespace is disabled or
+	// they're raw HTTP requests.
+	tests = append(tests,
+		&RPCTestGeneric{Method: "eth_getBalance"},
+		&RPCTestGeneric{Method: "debug_getRawBlock"},
+		&RPCTestRawHTTP{HTTPMethod: "eth_getCode"},
+	)
+
+	coverage := openRPCCoverage(doc, tests)
+	require.Equal(t, 4, coverage.Methods)
+	require.Equal(t, 3, coverage.Exercised)
+	require.Equal(t, 0.75, coverage.Rate)
+	require.Equal(t, []string{"eth_getCode"}, coverage.Unexercised)
+
+	enabledNamespaces = []string{"debug_"}
+	coverage = openRPCCoverage(doc, tests)
+	require.Zero(t, coverage.Exercised)
+	require.Zero(t, coverage.Rate)
+	require.Equal(t, []string{"eth_chainId", "eth_getBalance", "eth_getBlockByNumber", "eth_getCode"}, coverage.Unexercised)
+}
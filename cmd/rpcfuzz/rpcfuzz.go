@@ -29,6 +29,7 @@ import (
 	"time"
 
 	"github.com/0xPolygon/polygon-cli/bindings/tester"
+	"github.com/0xPolygon/polygon-cli/cmd/rpcfuzz/argfuzz"
 	"github.com/0xPolygon/polygon-cli/cmd/rpcfuzz/streamer"
 	"github.com/0xPolygon/polygon-cli/rpctypes"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
@@ -201,21 +202,24 @@ func getConformanceContract(ctx context.Context, rpc *rpc.Client, chainID *big.I
 	return
 }
 
-func runRpcFuzz(ctx context.Context) error {
-	// Setup streamer based on flags
-	var outputStreamer streamer.OutputStreamer
+// newOutputStreamer sets up the streamer selected by the flags.
+func newOutputStreamer() streamer.OutputStreamer {
 	switch {
 	case streamJSON:
-		outputStreamer = streamer.NewJSONStreamer(os.Stdout)
+		return streamer.NewJSONStreamer(os.Stdout)
 	case streamCSV:
-		outputStreamer = streamer.NewCSVStreamer(os.Stdout, quietMode)
+		return streamer.NewCSVStreamer(os.Stdout, quietMode)
 	case streamHTML:
-		outputStreamer = streamer.NewHTMLStreamer(os.Stdout, quietMode)
+		return streamer.NewHTMLStreamer(os.Stdout, quietMode)
 	case streamMarkdown:
-		outputStreamer = streamer.NewMarkdownStreamer(os.Stdout, quietMode)
+		return streamer.NewMarkdownStreamer(os.Stdout, quietMode)
 	default: // compact is default
-		outputStreamer = streamer.NewCompactStreamer(os.Stdout, quietMode)
+		return streamer.NewCompactStreamer(os.Stdout, quietMode)
 	}
+}
+
+func runRpcFuzz(ctx context.Context) error {
+	outputStreamer := newOutputStreamer()
 
 	// KEEP ALL EXISTING SETUP CODE
 	rpcClient, err := rpc.DialContext(ctx, rpcURL)
@@ -246,20 +250,52 @@ func runRpcFuzz(ctx context.Context) error {
 
 	log.Trace().Uint64("nonce", nonce).Uint64("chainID", chainID.Uint64()).Msg("Doing test setup")
 	setupTests(ctx, rpcClient)
+	if openRPCDoc != nil {
+		allTests = append(allTests, openRPCTests(openRPCDoc)...)
+	}
 
 	httpClient := &http.Client{}
 	wrappedHTTPClient := wrappedHTTPClient{httpClient, rpcURL}
 
+	// In differential mode the endpoint given with --rpc-url is the reference
+	// the others are compared to.
+	var diffEndpoints []diffEndpoint
+	if len(diffRPCURLs) > 0 {
+		diffEndpoints, err = dialDiffEndpoints(ctx, rpcClient, httpClient)
+		if err != nil {
+			return err
+		}
+		defer closeDiffEndpoints(diffEndpoints)
+		log.Info().Strs("endpoints", diffRPCURLs).Msg("Comparing responses to the reference endpoint")
+	}
+
+	// In grammar mode fuzzed requests are triaged over raw HTTP rather than
+	// through the RPC client.
+	var tr *triage
+	if testFuzz && fuzzMode == fuzzModeGrammar {
+		tr = newTriage(rpcURL, findingsDir)
+	}
+
 	summaries := make([]streamer.TestSummary, 0)
 
 	for _, t := range allTests {
+		if tr != nil && tr.stopped(ctx) {
+			break
+		}
 		if !shouldRunTest(t) {
 			log.Trace().Str("name", t.GetName()).Str("method", t.GetMethod()).Msg("Skipping test")
 			continue
 		}
 		log.Trace().Str("name", t.GetName()).Str("method", t.GetMethod()).Msg("Running Test")
 
-		execution := CallRPCAndValidate(ctx, rpcClient, wrappedHTTPClient, t)
+		diff := len(diffEndpoints) > 0 && shouldDiffTest(t)
+
+		var execution streamer.TestExecution
+		if diff {
+			execution = CallRPCAndDiff(ctx, diffEndpoints, t)
+		} else {
+			execution = CallRPCAndValidate(ctx, rpcClient, wrappedHTTPClient, t)
+		}
 		if shouldOutput(execution) {
 			iErr := outputStreamer.StreamTestExecution(execution)
 			if iErr != nil {
@@ -269,7 +305,17 @@ func runRpcFuzz(ctx context.Context) error {
 
 		summary := createSummaryFromExecution(t, execution)
 
-		if testFuzz {
+		if testFuzz && diff {
+			fuzzSummary := CallRPCWithFuzzAndDiff(ctx, diffEndpoints, t, outputStreamer)
+			summaries = append(summaries, fuzzSummary)
+		} else if tr != nil {
+			// Raw HTTP tests don't call a method whose args could be fuzzed.
+			if _, raw := t.(*RPCTestRawHTTP); raw {
+				summaries = append(summaries, summary)
+			} else {
+				summaries = append(summaries, CallRPCWithGrammarFuzz(ctx, tr, t, outputStreamer))
+			}
+		} else if testFuzz {
 			fuzzSummary := CallRPCWithFuzzAndValidate(ctx, rpcClient, t, outputStreamer)
 			summaries = append(summaries, fuzzSummary)
 		} else {
@@ -286,12 +332,34 @@ func runRpcFuzz(ctx context.Context) error {
 		}
 	}
 
+	if tr != nil {
+		if !tr.stopped(ctx) {
+			summaries = append(summaries, CallRPCWithBatchFuzz(ctx, tr, outputStreamer))
+		}
+		log.Info().Int("findings", len(tr.findings)).Str("dir", findingsDir).Msg("Finished fuzzing")
+	}
+
+	if openRPCDoc != nil {
+		coverage := openRPCCoverage(openRPCDoc, allTests)
+		log.Info().
+			Int("methods", coverage.Methods).
+			Int("exercised", coverage.Exercised).
+			Strs("unexercised", coverage.Unexercised).
+			Msg("OpenRPC method coverage")
+		if iErr := outputStreamer.StreamCoverage(coverage); iErr != nil {
+			log.Error().Err(iErr).Msg("Unable to stream coverage")
+		}
+	}
+
 	// Final summary
 	err = outputStreamer.StreamFinalSummary(summaries)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable to stream final summary")
 	}
 
+	if tr != nil && tr.unreachable {
+		return fmt.Errorf("the endpoint stopped responding while fuzzing, see the findings in %s", findingsDir)
+	}
 	return nil
 }
 
@@ -320,39 +388,10 @@ func CallRPCAndValidate(ctx context.Context, rpcClient *rpc.Client, wrappedHTTPC
 	// COPY existing RPC call logic from CallRPCAndValidate
 	switch currTest.(type) {
 	case *RPCTestRawHTTP:
-		// Marshal the HTTP request payload.
-		var payload []byte
-		payload, err = json.Marshal(args)
-		if err != nil {
-			log.Fatal().Err(err).Msg("Unable to marshal HTTP request payload")
-		}
-
-		// Create the request.
-		var request *http.Request
-		request, err = http.NewRequest(currTest.GetMethod(), wrappedHTTPClient.url, bytes.NewBuffer(payload))
-		if err != nil {
-			log.Fatal().Err(err).Msg("Unable to create HTTP request")
-		}
-		request.Header.Set("Content-Type", "application/json")
-
-		// Send the request.
-		var response *http.Response
-		response, err = wrappedHTTPClient.client.Do(request)
-		if err != nil {
-			log.Error().Err(err).Msg("Unable to send HTTP request")
-			break
-		}
-		defer func() {
-			if closeErr := response.Body.Close(); closeErr != nil {
-				log.Debug().Err(closeErr).Msg("Failed to close response body")
-			}
-		}()
-
-		// Read the response body.
 		var body []byte
-		body, err = io.ReadAll(response.Body)
+		body, err = wrappedHTTPClient.post(currTest.GetMethod(), args)
 		if err != nil {
-			log.Error().Err(err).Msg("Unable to read HTTP body")
+			log.Error().Err(err).Msg("Unable to send HTTP request")
 			break
 		}
 
@@ -431,22 +470,7 @@ func CallRPCWithFuzzAndValidate(ctx context.Context, rpcClient *rpc.Client, curr
 
 	originalArgs := currTest.GetArgs()
 	for i := 0; i < testFuzzNum; i++ {
-		args := make([]any, len(originalArgs))
-		for j, arg := range originalArgs {
-			// Deep copy each argument using JSON marshal/unmarshal
-			b, err := json.Marshal(arg)
-			if err != nil {
-				args[j] = arg // fallback to shallow copy if marshal fails
-				continue
-			}
-			var copied any
-			if err := json.Unmarshal(b, &copied); err != nil {
-				args[j] = arg // fallback to shallow copy if unmarshal fails
-				continue
-			}
-			args[j] = copied
-		}
-		fuzzer.Fuzz(&args)
+		args := fuzzArgs(originalArgs)
 
 		start := time.Now()
 		var result any
@@ -489,6 +513,32 @@ func CallRPCWithFuzzAndValidate(ctx context.Context, rpcClient *rpc.Client, curr
 	return summary
 }
 
+// fuzzArgs returns a fuzzed copy of the args, leaving the originals intact.
+func fuzzArgs(originalArgs []any) []any {
+	if fuzzMode == fuzzModeGrammar {
+		args, _ := argfuzz.Mutate(originalArgs)
+		return args
+	}
+
+	args := make([]any, len(originalArgs))
+	for j, arg := range originalArgs {
+		// Deep copy each argument using JSON marshal/unmarshal
+		b, err := json.Marshal(arg)
+		if err != nil {
+			args[j] = arg // fallback to shallow copy if marshal fails
+			continue
+		}
+		var copied any
+		if err := json.Unmarshal(b, &copied); err != nil {
+			args[j] = arg // fallback to shallow copy if unmarshal fails
+			continue
+		}
+		args[j] = copied
+	}
+	fuzzer.Fuzz(&args)
+	return args
+}
+
 func createSummaryFromExecution(t RPCTest, execution streamer.TestExecution) streamer.TestSummary {
 	summary := streamer.TestSummary{
 		TestName:      t.GetName(),
@@ -2179,6 +2229,38 @@ type wrappedHTTPClient struct {
 	url    string
 }
 
+// post sends the args as the JSON body of an HTTP request with the given
+// method and returns the response body. Failing to build the request is fatal,
+// as it means the test itself is broken.
+func (w wrappedHTTPClient) post(method string, args []any) ([]byte, error) {
+	payload, err := json.Marshal(args)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Unable to marshal HTTP request payload")
+	}
+
+	request, err := http.NewRequest(method, w.url, bytes.NewBuffer(payload))
+	if err != nil {
+		log.Fatal().Err(err).Msg("Unable to create HTTP request")
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := w.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := response.Body.Close(); closeErr != nil {
+			log.Debug().Err(closeErr).Msg("Failed to close response body")
+		}
+	}()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read HTTP body: %w", err)
+	}
+	return body, nil
+}
+
 func shouldRunTest(t RPCTest) bool {
 	var testNamespace string
 	switch t.(type) {
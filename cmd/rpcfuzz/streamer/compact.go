@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 )
 
 type CompactStreamer struct {
@@ -38,6 +39,16 @@ func (c *CompactStreamer) StreamSummary(summary TestSummary) error {
 	return err
 }
 
+func (c *CompactStreamer) StreamCoverage(coverage Coverage) error {
+	_, err := fmt.Fprintf(c.writer, "\nCoverage: %d/%d methods exercised (%.1f%%)\n",
+		coverage.Exercised, coverage.Methods, coverage.Rate*100)
+	if err != nil || len(coverage.Unexercised) == 0 {
+		return err
+	}
+	_, err = fmt.Fprintf(c.writer, "Not exercised: %s\n", strings.Join(coverage.Unexercised, ", "))
+	return err
+}
+
 func (c *CompactStreamer) StreamFinalSummary(summaries []TestSummary) error {
 	fmt.Fprintf(os.Stderr, "\n") // Clear progress line
 
@@ -60,6 +60,11 @@ func (c *CSVStreamer) StreamSummary(summary TestSummary) error {
 	return nil
 }
 
+func (c *CSVStreamer) StreamCoverage(coverage Coverage) error {
+	// CSV only holds test executions, coverage is logged instead
+	return nil
+}
+
 func (c *CSVStreamer) StreamFinalSummary(summaries []TestSummary) error {
 	c.csvWriter.Flush()
 	return c.csvWriter.Error()
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"html"
 	"io"
+	"strings"
 	"time"
 )
 
@@ -13,6 +14,7 @@ type HTMLStreamer struct {
 	quiet         bool
 	headerWritten bool
 	footerNeeded  bool
+	coverage      *Coverage
 }
 
 func NewHTMLStreamer(writer io.Writer, quiet bool) *HTMLStreamer {
@@ -75,6 +77,12 @@ func (h *HTMLStreamer) StreamSummary(summary TestSummary) error {
 	return nil
 }
 
+func (h *HTMLStreamer) StreamCoverage(coverage Coverage) error {
+	// Written with the summary, after the table is closed
+	h.coverage = &coverage
+	return nil
+}
+
 func (h *HTMLStreamer) StreamFinalSummary(summaries []TestSummary) error {
 	if h.footerNeeded {
 		h.writeFooter(summaries)
@@ -140,6 +148,21 @@ func (h *HTMLStreamer) writeFooter(summaries []TestSummary) {
 		)
 	}
 
+	if h.coverage != nil {
+		_, _ = fmt.Fprintf(h.writer,
+			`        <p><strong>Coverage</strong>: %d/%d methods exercised (%.1f%%)</p>
+  `,
+			h.coverage.Exercised, h.coverage.Methods, h.coverage.Rate*100,
+		)
+		if len(h.coverage.Unexercised) > 0 {
+			_, _ = fmt.Fprintf(h.writer,
+				`        <p><strong>Not exercised</strong>: %s</p>
+  `,
+				html.EscapeString(strings.Join(h.coverage.Unexercised, ", ")),
+			)
+		}
+	}
+
 	overallRate := float64(totalPassed) / float64(totalRan) * 100
 	_, _ = fmt.Fprintf(h.writer,
 		`        <hr>
@@ -29,6 +29,14 @@ func (j *JSONStreamer) StreamSummary(summary TestSummary) error {
 	return json.NewEncoder(j.writer).Encode(data)
 }
 
+func (j *JSONStreamer) StreamCoverage(coverage Coverage) error {
+	data := map[string]any{
+		"type": "coverage",
+		"data": coverage,
+	}
+	return json.NewEncoder(j.writer).Encode(data)
+}
+
 func (j *JSONStreamer) StreamFinalSummary(summaries []TestSummary) error {
 	data := map[string]any{
 		"type": "final_summary",
@@ -13,6 +13,7 @@ type MarkdownStreamer struct {
 	quiet         bool
 	headerWritten bool
 	footerNeeded  bool
+	coverage      *Coverage
 }
 
 func NewMarkdownStreamer(writer io.Writer, quiet bool) *MarkdownStreamer {
@@ -67,6 +68,12 @@ func (m *MarkdownStreamer) StreamSummary(summary TestSummary) error {
 	return nil
 }
 
+func (m *MarkdownStreamer) StreamCoverage(coverage Coverage) error {
+	// Written with the summary, after the table is closed
+	m.coverage = &coverage
+	return nil
+}
+
 func (m *MarkdownStreamer) StreamFinalSummary(summaries []TestSummary) error {
 	if m.footerNeeded {
 		m.writeFooter(summaries)
@@ -106,6 +113,14 @@ func (m *MarkdownStreamer) writeFooter(summaries []TestSummary) {
 		)
 	}
 
+	if m.coverage != nil {
+		_, _ = fmt.Fprintf(m.writer, "\n**Coverage**: %d/%d methods exercised (%.1f%%)\n",
+			m.coverage.Exercised, m.coverage.Methods, m.coverage.Rate*100)
+		if len(m.coverage.Unexercised) > 0 {
+			_, _ = fmt.Fprintf(m.writer, "\n**Not exercised**: %s\n", strings.Join(m.coverage.Unexercised, ", "))
+		}
+	}
+
 	overallRate := float64(totalPassed) / float64(totalRan) * 100
 	_, _ = fmt.Fprintf(m.writer, `
   ---
@@ -14,6 +14,28 @@ type TestExecution struct {
 	Status    string        `json:"status"` // "pass" or "fail"
 	Duration  time.Duration `json:"duration"`
 	Timestamp time.Time     `json:"timestamp"`
+	// Differences are set in differential mode, where Result holds the
+	// response of each endpoint.
+	Differences []Difference `json:"differences,omitempty"`
+}
+
+// Difference between the responses of the reference endpoint and another
+// endpoint to the same call
+type Difference struct {
+	Endpoint string `json:"endpoint"`
+	// Path locates the difference in the response, e.g. "result.transactions[0].hash"
+	Path     string `json:"path"`
+	Kind     string `json:"kind"`
+	Expected any    `json:"expected"`
+	Actual   any    `json:"actual"`
+}
+
+// Coverage of the methods of an OpenRPC document by the tests that ran
+type Coverage struct {
+	Methods     int      `json:"methods"`
+	Exercised   int      `json:"exercised"`
+	Rate        float64  `json:"rate"`
+	Unexercised []string `json:"unexercised"`
 }
 
 // Summary stats - no individual data stored
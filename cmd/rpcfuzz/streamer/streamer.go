@@ -3,5 +3,8 @@ package streamer
 type OutputStreamer interface {
 	StreamTestExecution(exec TestExecution) error
 	StreamSummary(summary TestSummary) error
+	// StreamCoverage is called before StreamFinalSummary when tests were
+	// generated from an OpenRPC document
+	StreamCoverage(coverage Coverage) error
 	StreamFinalSummary(summaries []TestSummary) error
 }
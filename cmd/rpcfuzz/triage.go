@@ -0,0 +1,699 @@
+package rpcfuzz
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/0xPolygon/polygon-cli/cmd/rpcfuzz/argfuzz"
+	"github.com/0xPolygon/polygon-cli/cmd/rpcfuzz/streamer"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	fuzzModeBytes   = "bytes"
+	fuzzModeGrammar = "grammar"
+
+	// Kinds of findings. Requests the endpoint answers, even with an error,
+	// aren't findings unless the error reports a crash.
+	findingTimeout     = "timeout"
+	findingServerError = "server_error"
+	findingConnection  = "connection_drop"
+	findingCrash       = "crash"
+
+	// batchMethod is the method findings of batch requests are reported under.
+	batchMethod = "batch"
+
+	// maxMinimiseAttempts and maxMinimiseDuration bound the requests sent to
+	// minimise a finding, as each one can take up to --fuzz-timeout.
+	maxMinimiseAttempts = 64
+	maxMinimiseDuration = time.Minute
+	// maxShrinkFanout bounds the elements of an array or object shrunk
+	// individually at each step.
+	maxShrinkFanout = 8
+	// maxStreamedBytes bounds the JSON size of the args and results streamed
+	// for fuzzed requests, which can be megabytes.
+	maxStreamedBytes = 4096
+	maxMessageLength = 200
+
+	livenessCheckWait = time.Second
+)
+
+var (
+	crashRegex  = regexp.MustCompile(`(?i)panic|crashed|runtime error|stack overflow|nil pointer`)
+	numberRegex = regexp.MustCompile(`0x[0-9a-fA-F]+|[0-9]+`)
+)
+
+type (
+	// fuzzOutcome is how the endpoint responded to a fuzzed request.
+	fuzzOutcome struct {
+		// Finding is the kind of finding, empty when the endpoint handled the
+		// request.
+		Finding  string
+		Status   int
+		Code     int
+		Message  string
+		Response any
+		Duration time.Duration
+	}
+
+	// fuzzFinding is a request that made the endpoint time out, fail with a
+	// 5xx status, drop the connection or crash. It's saved to the findings
+	// directory as a case that --replay-findings sends again.
+	fuzzFinding struct {
+		Signature string          `json:"signature"`
+		Kind      string          `json:"kind"`
+		Method    string          `json:"method"`
+		Mutation  string          `json:"mutation"`
+		Status    int             `json:"status,omitempty"`
+		Code      int             `json:"code,omitempty"`
+		Message   string          `json:"message,omitempty"`
+		Count     int             `json:"count"`
+		FirstSeen time.Time       `json:"first_seen"`
+		Request   json.RawMessage `json:"request"`
+		// OriginalSize is the size in bytes of the request before it was
+		// minimised.
+		OriginalSize int `json:"original_size"`
+	}
+
+	// triage sends the requests of the structure-aware fuzzer over raw HTTP,
+	// so timeouts, statuses and dropped connections are observed, and keeps
+	// the findings deduplicated by their signature.
+	triage struct {
+		client   *http.Client
+		url      string
+		dir      string
+		nextID   int
+		findings map[string]*fuzzFinding
+		// corpus holds the valid calls of the tests that were fuzzed, which
+		// batches are built from.
+		corpus []argfuzz.Call
+		// unreachable is set when the endpoint stops answering after a
+		// finding, which ends the run.
+		unreachable bool
+	}
+)
+
+func newTriage(url, dir string) *triage {
+	return &triage{
+		// Keep-alives are disabled so a closed idle connection is never
+		// mistaken for a connection dropped by the request.
+		client:   &http.Client{Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, DisableKeepAlives: true}},
+		url:      url,
+		dir:      dir,
+		findings: make(map[string]*fuzzFinding),
+	}
+}
+
+// signature identifies findings caused by the same bug: the same kind of
+// failure of the same method, with numbers stripped from the message.
+func (o fuzzOutcome) signature(method string) string {
+	// Hex and decimal numbers are replaced in one pass, so the placeholder of
+	// a hex number isn't taken for a decimal one.
+	message := numberRegex.ReplaceAllStringFunc(o.Message, func(n string) string {
+		if strings.HasPrefix(n, "0x") {
+			return "0x_"
+		}
+		return "N"
+	})
+	return fmt.Sprintf("%s|%s|%d|%d|%s", o.Finding, method, o.Status, o.Code, message)
+}
+
+func (t *triage) id() int {
+	t.nextID++
+	return t.nextID
+}
+
+func (t *triage) stopped(ctx context.Context) bool {
+	return t.unreachable || ctx.Err() != nil
+}
+
+// send posts the JSON-RPC request body and classifies the response.
+func (t *triage) send(ctx context.Context, body []byte) fuzzOutcome {
+	start := time.Now()
+	reqCtx, cancel := context.WithTimeout(ctx, fuzzTimeout)
+	defer cancel()
+
+	var outcome fuzzOutcome
+	respBody, status, err := t.post(reqCtx, body)
+	outcome.Duration = time.Since(start)
+	switch {
+	case err != nil && ctx.Err() != nil:
+		// The run was interrupted, the endpoint isn't to blame.
+		outcome.Message = ctx.Err().Error()
+		return outcome
+	case err != nil && errors.Is(reqCtx.Err(), context.DeadlineExceeded):
+		outcome.Finding = findingTimeout
+		outcome.Message = fmt.Sprintf("no response within %s", fuzzTimeout)
+		return outcome
+	case err != nil:
+		outcome.Finding = findingConnection
+		outcome.Message = connectionReason(err)
+		return outcome
+	}
+
+	outcome.Status = status
+	if err = json.Unmarshal(respBody, &outcome.Response); err != nil {
+		outcome.Response = truncate(string(respBody), maxMessageLength)
+	}
+
+	if status >= http.StatusInternalServerError {
+		outcome.Finding = findingServerError
+		outcome.Message = truncate(strings.TrimSpace(string(respBody)), maxMessageLength)
+		return outcome
+	}
+
+	if rpcErr := responseError(outcome.Response); rpcErr != nil {
+		outcome.Code = rpcErr.Code
+		outcome.Message = truncate(rpcErr.Message, maxMessageLength)
+		if crashRegex.MatchString(rpcErr.Message) {
+			outcome.Finding = findingCrash
+		}
+	}
+	return outcome
+}
+
+func (t *triage) post(ctx context.Context, body []byte) ([]byte, int, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := t.client.Do(request)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if closeErr := response.Body.Close(); closeErr != nil {
+			log.Debug().Err(closeErr).Msg("Failed to close response body")
+		}
+	}()
+
+	respBody, err := io.ReadAll(response.Body)
+	return respBody, response.StatusCode, err
+}
+
+// connectionReason describes why a connection failed without the addresses
+// and ports, so drops of different requests share a signature.
+func connectionReason(err error) string {
+	switch {
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "connection closed without a response"
+	case errors.Is(err, syscall.ECONNRESET):
+		return "connection reset"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection refused"
+	case errors.Is(err, syscall.EPIPE):
+		return "broken pipe"
+	}
+	return err.Error()
+}
+
+// responseError returns the error of a response, or for a batch the first
+// error reporting a crash, or else its first error.
+func responseError(response any) *RPCJSONError {
+	var errs []*RPCJSONError
+	var collect func(v any)
+	collect = func(v any) {
+		switch v := v.(type) {
+		case []any:
+			for _, e := range v {
+				collect(e)
+			}
+		case map[string]any:
+			obj, ok := v["error"].(map[string]any)
+			if !ok {
+				return
+			}
+			rpcErr := new(RPCJSONError)
+			if code, ok := obj["code"].(float64); ok {
+				rpcErr.Code = int(code)
+			}
+			rpcErr.Message, _ = obj["message"].(string)
+			errs = append(errs, rpcErr)
+		}
+	}
+	collect(response)
+
+	if len(errs) == 0 {
+		return nil
+	}
+	for _, e := range errs {
+		if crashRegex.MatchString(e.Message) {
+			return e
+		}
+	}
+	return errs[0]
+}
+
+// alive reports whether the endpoint still answers a trivial request, giving
+// it a few seconds to recover.
+func (t *triage) alive(ctx context.Context) bool {
+	body, err := json.Marshal(argfuzz.NewRequest(t.id(), "web3_clientVersion", []any{}))
+	if err != nil {
+		return false
+	}
+	for range 3 {
+		if t.send(ctx, body).Finding == "" {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(livenessCheckWait):
+		}
+	}
+	return false
+}
+
+// execute sends a fuzzed request and records it as a finding if the endpoint
+// didn't handle it. Only findings fail, as errors are expected for most
+// fuzzed args.
+func (t *triage) execute(ctx context.Context, name, method, mutation string, args []any, request any) streamer.TestExecution {
+	execution := streamer.TestExecution{
+		TestName:  name,
+		Method:    method,
+		Args:      truncateArgs(args),
+		Status:    "pass",
+		Timestamp: time.Now(),
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		execution.Status = "fail"
+		execution.Error = "unable to marshal request: " + err.Error()
+		return execution
+	}
+
+	outcome := t.send(ctx, body)
+	execution.Result = truncateValue(outcome.Response)
+	execution.Duration = outcome.Duration
+	if outcome.Finding != "" {
+		f := t.record(ctx, method, mutation, request, body, outcome)
+		execution.Status = "fail"
+		execution.Error = fmt.Sprintf("%s after %s mutation (seen %d times): %s", outcome.Finding, mutation, f.Count, outcome.Message)
+	}
+	return execution
+}
+
+// record deduplicates the finding by its signature. New findings are saved,
+// minimised unless they are timeouts, and repeated ones only have their count
+// updated.
+func (t *triage) record(ctx context.Context, method, mutation string, request any, body []byte, outcome fuzzOutcome) *fuzzFinding {
+	signature := outcome.signature(method)
+	if f, ok := t.findings[signature]; ok {
+		f.Count++
+		t.save(f)
+		return f
+	}
+
+	if outcome.Finding == findingTimeout || outcome.Finding == findingConnection {
+		if !t.alive(ctx) {
+			log.Error().Str("method", method).Str("kind", outcome.Finding).Msg("The endpoint stopped responding after a finding")
+			t.unreachable = true
+		}
+	}
+
+	// Timeouts aren't minimised, since every attempt would wait for
+	// --fuzz-timeout on an endpoint that is already struggling.
+	minimised := body
+	if !t.unreachable && outcome.Finding != findingTimeout {
+		var err error
+		if minimised, err = json.Marshal(t.minimise(ctx, signature, method, request)); err != nil {
+			minimised = body
+		}
+	}
+
+	f := &fuzzFinding{
+		Signature:    signature,
+		Kind:         outcome.Finding,
+		Method:       method,
+		Mutation:     mutation,
+		Status:       outcome.Status,
+		Code:         outcome.Code,
+		Message:      outcome.Message,
+		Count:        1,
+		FirstSeen:    time.Now(),
+		Request:      minimised,
+		OriginalSize: len(body),
+	}
+	t.findings[signature] = f
+
+	log.Warn().
+		Str("kind", f.Kind).
+		Str("method", method).
+		Str("mutation", mutation).
+		Str("message", f.Message).
+		Int("size", len(minimised)).
+		Int("originalSize", len(body)).
+		Msg("New fuzzing finding")
+	t.save(f)
+
+	return f
+}
+
+// minimise shrinks the request for as long as it still produces a finding
+// with the same signature, trying smaller candidates greedily.
+func (t *triage) minimise(ctx context.Context, signature, method string, request any) any {
+	current := request
+	attempts := 0
+	deadline := time.Now().Add(maxMinimiseDuration)
+	exhausted := func() bool {
+		return attempts >= maxMinimiseAttempts || time.Now().After(deadline) || t.stopped(ctx)
+	}
+	for !exhausted() {
+		shrunk := false
+		for _, candidate := range requestCandidates(current) {
+			if exhausted() {
+				break
+			}
+			attempts++
+
+			body, err := json.Marshal(candidate)
+			if err != nil {
+				continue
+			}
+			if t.send(ctx, body).signature(method) == signature {
+				current = candidate
+				shrunk = true
+				break
+			}
+		}
+		if !shrunk {
+			break
+		}
+	}
+	return current
+}
+
+// requestCandidates returns smaller variants of a request. Only the params of
+// a single request are shrunk, while batches are shrunk as a whole.
+func requestCandidates(request any) []any {
+	switch r := request.(type) {
+	case map[string]any:
+		var candidates []any
+		for _, params := range shrinkCandidates(r["params"]) {
+			c := maps.Clone(r)
+			c["params"] = params
+			candidates = append(candidates, c)
+		}
+		return candidates
+	case []any:
+		return shrinkCandidates(r)
+	}
+	return nil
+}
+
+// shrinkCandidates returns smaller variants of a JSON value, the smallest
+// first: half as deep nestings, halves of arrays and long strings, the value
+// with one of its elements shrunk, then objects without one of their fields.
+func shrinkCandidates(v any) []any {
+	var candidates []any
+	switch v := v.(type) {
+	case []any:
+		if depth := nestingDepth(v); depth > 1 {
+			candidates = append(candidates, unwrap(v, depth/2))
+		}
+		if len(v) > 1 {
+			candidates = append(candidates, slices.Clone(v[:len(v)/2]), slices.Clone(v[len(v)/2:]))
+		}
+		for i, e := range v[:min(len(v), maxShrinkFanout)] {
+			if shrunk := shrinkCandidates(e); len(shrunk) > 0 {
+				c := slices.Clone(v)
+				c[i] = shrunk[0]
+				candidates = append(candidates, c)
+			}
+		}
+	case map[string]any:
+		if depth := nestingDepth(v); depth > 1 {
+			candidates = append(candidates, unwrap(v, depth/2))
+		}
+		keys := slices.Sorted(maps.Keys(v))
+		for _, k := range keys[:min(len(keys), maxShrinkFanout)] {
+			if shrunk := shrinkCandidates(v[k]); len(shrunk) > 0 {
+				c := maps.Clone(v)
+				c[k] = shrunk[0]
+				candidates = append(candidates, c)
+			}
+		}
+		for _, k := range keys[:min(len(keys), maxShrinkFanout)] {
+			c := maps.Clone(v)
+			delete(c, k)
+			candidates = append(candidates, c)
+		}
+	case string:
+		if len(v) > 16 {
+			candidates = append(candidates, v[:len(v)/2])
+		}
+	}
+	return candidates
+}
+
+// nestingDepth counts the arrays and objects with a single element wrapped in
+// each other, such as [[[1]]].
+func nestingDepth(v any) int {
+	depth := 0
+	for {
+		child, ok := onlyChild(v)
+		if !ok {
+			return depth
+		}
+		depth++
+		v = child
+	}
+}
+
+// unwrap removes n levels of single element nesting.
+func unwrap(v any, n int) any {
+	for range n {
+		v, _ = onlyChild(v)
+	}
+	return v
+}
+
+func onlyChild(v any) (any, bool) {
+	switch v := v.(type) {
+	case []any:
+		if len(v) == 1 {
+			return v[0], true
+		}
+	case map[string]any:
+		if len(v) == 1 {
+			for _, child := range v {
+				return child, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// save writes the finding to the findings directory, named after the hash of
+// its signature so repeated findings overwrite their case.
+func (t *triage) save(f *fuzzFinding) {
+	if t.dir == "" {
+		return
+	}
+	if err := os.MkdirAll(t.dir, 0o755); err != nil {
+		log.Error().Err(err).Msg("Unable to create the findings directory")
+		return
+	}
+
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to marshal finding")
+		return
+	}
+
+	hash := sha256.Sum256([]byte(f.Signature))
+	path := filepath.Join(t.dir, fmt.Sprintf("%s-%s.json", f.Kind, hex.EncodeToString(hash[:6])))
+	if err = os.WriteFile(path, b, 0o644); err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Unable to save finding")
+	}
+}
+
+// CallRPCWithGrammarFuzz sends the test's method with structure-aware
+// mutations of its args and triages the responses.
+func CallRPCWithGrammarFuzz(ctx context.Context, tr *triage, currTest RPCTest, outputStreamer streamer.OutputStreamer) streamer.TestSummary {
+	summary := streamer.TestSummary{
+		TestName: currTest.GetName() + "-FUZZED",
+		Method:   currTest.GetMethod(),
+	}
+
+	originalArgs := currTest.GetArgs()
+	tr.corpus = append(tr.corpus, argfuzz.Call{Method: summary.Method, Args: originalArgs})
+
+	for i := 0; i < testFuzzNum && !tr.stopped(ctx); i++ {
+		args, mutation := argfuzz.Mutate(originalArgs)
+		request := argfuzz.NewRequest(tr.id(), summary.Method, args)
+		execution := tr.execute(ctx, summary.TestName, summary.Method, mutation, args, request)
+		addExecution(&summary, execution, outputStreamer)
+	}
+
+	if summary.TestsRan > 0 {
+		summary.SuccessRate = float64(summary.TestsPassed) / float64(summary.TestsRan)
+	}
+
+	return summary
+}
+
+// CallRPCWithBatchFuzz sends batches of the calls fuzzed so far, with
+// malformed, huge and nested batches among them, and triages the responses.
+func CallRPCWithBatchFuzz(ctx context.Context, tr *triage, outputStreamer streamer.OutputStreamer) streamer.TestSummary {
+	summary := streamer.TestSummary{
+		TestName: "RPCTestBatch-FUZZED",
+		Method:   batchMethod,
+	}
+
+	calls := tr.corpus
+	if len(calls) == 0 {
+		calls = []argfuzz.Call{{Method: "web3_clientVersion", Args: []any{}}}
+	}
+
+	for i := 0; i < testFuzzNum && !tr.stopped(ctx); i++ {
+		batch, mutation := argfuzz.MutateBatch(calls)
+		execution := tr.execute(ctx, summary.TestName, summary.Method, mutation, batch, batch)
+		addExecution(&summary, execution, outputStreamer)
+	}
+
+	if summary.TestsRan > 0 {
+		summary.SuccessRate = float64(summary.TestsPassed) / float64(summary.TestsRan)
+	}
+
+	return summary
+}
+
+// addExecution counts the execution in the summary and streams it.
+func addExecution(summary *streamer.TestSummary, execution streamer.TestExecution, outputStreamer streamer.OutputStreamer) {
+	summary.TestsRan++
+	summary.TotalDuration += execution.Duration
+	if execution.Status == "pass" {
+		summary.TestsPassed++
+	} else {
+		summary.TestsFailed++
+	}
+
+	if shouldOutput(execution) {
+		if err := outputStreamer.StreamTestExecution(execution); err != nil {
+			log.Error().Err(err).Msg("Unable to stream test execution")
+		}
+	}
+}
+
+// runReplayFindings sends the cases saved in a findings directory, or a
+// single case file, again. A case fails when it still reproduces its finding.
+func runReplayFindings(ctx context.Context, path string) error {
+	files := []string{path}
+	if info, err := os.Stat(path); err != nil {
+		return err
+	} else if info.IsDir() {
+		if files, err = filepath.Glob(filepath.Join(path, "*.json")); err != nil {
+			return err
+		}
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no findings in %s", path)
+	}
+
+	outputStreamer := newOutputStreamer()
+	tr := newTriage(rpcURL, "")
+	summaries := make([]streamer.TestSummary, 0, len(files))
+
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		var f fuzzFinding
+		if err = json.Unmarshal(b, &f); err != nil {
+			return fmt.Errorf("unable to parse finding %s: %w", file, err)
+		}
+
+		var request any
+		if err = json.Unmarshal(f.Request, &request); err != nil {
+			return fmt.Errorf("unable to parse the request of finding %s: %w", file, err)
+		}
+		args := []any{request}
+		if r, ok := request.(map[string]any); ok {
+			if params, ok := r["params"].([]any); ok {
+				args = params
+			} else {
+				args = []any{r["params"]}
+			}
+		}
+
+		// Cases are saved indented, they're sent as they were found.
+		var body bytes.Buffer
+		if err = json.Compact(&body, f.Request); err != nil {
+			return fmt.Errorf("unable to compact the request of finding %s: %w", file, err)
+		}
+
+		outcome := tr.send(ctx, body.Bytes())
+		execution := streamer.TestExecution{
+			TestName:  "Replay-" + filepath.Base(file),
+			Method:    f.Method,
+			Args:      truncateArgs(args),
+			Result:    truncateValue(outcome.Response),
+			Status:    "pass",
+			Duration:  outcome.Duration,
+			Timestamp: time.Now(),
+		}
+		switch {
+		case outcome.signature(f.Method) == f.Signature:
+			execution.Status = "fail"
+			execution.Error = fmt.Sprintf("reproduced %s: %s", outcome.Finding, outcome.Message)
+		case outcome.Finding != "":
+			execution.Status = "fail"
+			execution.Error = fmt.Sprintf("%s instead of the saved %s: %s", outcome.Finding, f.Kind, outcome.Message)
+		}
+
+		if shouldOutput(execution) {
+			if iErr := outputStreamer.StreamTestExecution(execution); iErr != nil {
+				log.Error().Err(iErr).Msg("Unable to stream test execution")
+			}
+		}
+		summaries = append(summaries, createSummaryFromExecution(&RPCTestGeneric{Name: execution.TestName, Method: f.Method}, execution))
+	}
+
+	if err := outputStreamer.StreamFinalSummary(summaries); err != nil {
+		log.Error().Err(err).Msg("Unable to stream final summary")
+	}
+	return nil
+}
+
+// truncateArgs replaces args too large to stream with a prefix of their JSON.
+func truncateArgs(args []any) []any {
+	if v, ok := truncateValue(args).(string); ok {
+		return []any{v}
+	}
+	return args
+}
+
+func truncateValue(v any) any {
+	b, err := json.Marshal(v)
+	if err != nil || len(b) <= maxStreamedBytes {
+		return v
+	}
+	return fmt.Sprintf("%s... (%d bytes)", b[:256], len(b))
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,294 @@
+package rpcfuzz
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/0xPolygon/polygon-cli/cmd/rpcfuzz/argfuzz"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFuzzOutcomeSignature(t *testing.T) {
+	crash := func(message string) fuzzOutcome {
+		return fuzzOutcome{Finding: findingCrash, Code: -32603, Message: message}
+	}
+
+	// Numbers in the message don't split a bug into several findings
+	require.Equal(t,
+		crash("method handler crashed: index 12 out of range at 0xDEADbeef").signature("eth_call"),
+		crash("method handler crashed: index 3 out of range at 0x1").signature("eth_call"))
+	require.Equal(t, "crash|eth_call|0|-32603|method handler crashed: index N out of range at 0x_",
+		crash("method handler crashed: index 12 out of range at 0xDEADbeef").signature("eth_call"))
+
+	require.NotEqual(t, crash("crashed").signature("eth_call"), crash("crashed").signature("eth_getLogs"))
+	require.NotEqual(t, crash("crashed").signature("eth_call"), crash("runtime error").signature("eth_call"))
+	require.NotEqual(t,
+		fuzzOutcome{Finding: findingServerError, Status: 500}.signature("eth_call"),
+		fuzzOutcome{Finding: findingServerError, Status: 502}.signature("eth_call"))
+	require.NotEqual(t,
+		fuzzOutcome{Finding: findingTimeout}.signature("eth_call"),
+		fuzzOutcome{Finding: findingConnection}.signature("eth_call"))
+}
+
+func TestResponseError(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     *RPCJSONError
+	}{
+		{
+			name:     "result",
+			response: `{"jsonrpc":"2.0","id":1,"result":"0x1"}`,
+		},
+		{
+			name:     "error",
+			response: `{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"invalid argument 0"}}`,
+			want:     &RPCJSONError{Code: -32602, Message: "invalid argument 0"},
+		},
+		{
+			name: "batch crash after other errors",
+			response: `[
+				{"jsonrpc":"2.0","id":1,"result":"0x1"},
+				{"jsonrpc":"2.0","id":2,"error":{"code":-32602,"message":"invalid argument 0"}},
+				{"jsonrpc":"2.0","id":3,"error":{"code":-32603,"message":"method handler crashed"}}
+			]`,
+			want: &RPCJSONError{Code: -32603, Message: "method handler crashed"},
+		},
+		{
+			name: "batch without crash",
+			response: `[
+				{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"the method foo does not exist"}},
+				{"jsonrpc":"2.0","id":2,"error":{"code":-32602,"message":"invalid argument 0"}}
+			]`,
+			want: &RPCJSONError{Code: -32601, Message: "the method foo does not exist"},
+		},
+		{
+			name:     "batch without errors",
+			response: `[{"jsonrpc":"2.0","id":1,"result":"0x1"},"not a response",null]`,
+		},
+		{
+			name:     "malformed error",
+			response: `{"jsonrpc":"2.0","id":1,"error":"boom"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var response any
+			require.NoError(t, json.Unmarshal([]byte(tt.response), &response))
+			require.Equal(t, tt.want, responseError(response))
+		})
+	}
+}
+
+func TestShrinkCandidates(t *testing.T) {
+	nested := []any{[]any{[]any{[]any{"x"}}}}
+	require.Equal(t, 4, nestingDepth(nested))
+	require.Equal(t, []any{[]any{"x"}}, shrinkCandidates(nested)[0])
+	require.Equal(t, "x", unwrap(nested, 4))
+
+	object := map[string]any{"a": map[string]any{"b": "x"}}
+	require.Equal(t, 2, nestingDepth(object))
+	require.Equal(t, map[string]any{"b": "x"}, shrinkCandidates(object)[0])
+
+	long := strings.Repeat("a", 100)
+	require.Equal(t, []any{long[:50]}, shrinkCandidates(long))
+	require.Empty(t, shrinkCandidates(strings.Repeat("a", 16)))
+
+	require.Equal(t, []any{
+		[]any{"a"},
+		[]any{"b", "c"},
+	}, shrinkCandidates([]any{"a", "b", "c"}))
+}
+
+// crashServer answers requests with a crash error when crashes returns true
+// for their params, and with a result otherwise. It counts the requests.
+func crashServer(t *testing.T, crashes func(params []any) bool) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var request struct {
+			Params []any `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		w.Header().Set("Content-Type", "application/json")
+		if crashes(request.Params) {
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32603,"message":"method handler crashed"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":null}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func withFuzzTimeout(t *testing.T, timeout time.Duration) {
+	t.Helper()
+	previous := fuzzTimeout
+	fuzzTimeout = timeout
+	t.Cleanup(func() { fuzzTimeout = previous })
+}
+
+func TestMinimise(t *testing.T) {
+	withFuzzTimeout(t, 5*time.Second)
+
+	// containsX reports whether the string x is somewhere in the value.
+	var containsX func(v any) bool
+	containsX = func(v any) bool {
+		switch v := v.(type) {
+		case string:
+			return v == "x"
+		case []any:
+			for _, e := range v {
+				if containsX(e) {
+					return true
+				}
+			}
+		case map[string]any:
+			for _, e := range v {
+				if containsX(e) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	paramsContainX := func(params []any) bool { return containsX(params) }
+
+	tests := []struct {
+		name    string
+		crashes func(params []any) bool
+		params  []any
+		want    []any
+	}{
+		{
+			name:    "deep nesting",
+			crashes: paramsContainX,
+			params:  []any{[]any{[]any{[]any{"x"}}}},
+			want:    []any{"x"},
+		},
+		{
+			name: "long string",
+			crashes: func(params []any) bool {
+				if len(params) == 0 {
+					return false
+				}
+				s, ok := params[0].(string)
+				return ok && strings.HasPrefix(s, "0xff")
+			},
+			params: []any{"0x" + strings.Repeat("f", 4096)},
+			want:   []any{"0x" + strings.Repeat("f", 14)},
+		},
+		{
+			name:    "huge array",
+			crashes: paramsContainX,
+			params:  []any{"a", "b", "c", "d", "e", "f", "g", "x"},
+			want:    []any{"x"},
+		},
+		{
+			// Unwrapping the object to its child would lose the crash, so
+			// the object is kept.
+			name: "object structure",
+			crashes: func(params []any) bool {
+				if len(params) == 0 {
+					return false
+				}
+				obj, ok := params[0].(map[string]any)
+				return ok && obj["from"] != nil
+			},
+			params: []any{map[string]any{"from": map[string]any{"a": map[string]any{"b": "c"}}}},
+			want:   []any{map[string]any{"from": map[string]any{"a": map[string]any{"b": "c"}}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := crashServer(t, tt.crashes)
+			tr := newTriage(server.URL, "")
+			ctx := context.Background()
+
+			request := argfuzz.NewRequest(1, "eth_call", tt.params)
+			body, err := json.Marshal(request)
+			require.NoError(t, err)
+			outcome := tr.send(ctx, body)
+			require.Equal(t, findingCrash, outcome.Finding)
+			signature := outcome.signature("eth_call")
+
+			minimised := tr.minimise(ctx, signature, "eth_call", request).(map[string]any)
+			require.Equal(t, tt.want, minimised["params"])
+
+			// The minimised request still reproduces the finding
+			body, err = json.Marshal(minimised)
+			require.NoError(t, err)
+			require.Equal(t, signature, tr.send(ctx, body).signature("eth_call"))
+		})
+	}
+}
+
+func TestRecord(t *testing.T) {
+	withFuzzTimeout(t, 5*time.Second)
+	ctx := context.Background()
+
+	server, requests := crashServer(t, func(params []any) bool { return len(params) > 0 })
+	tr := newTriage(server.URL, t.TempDir())
+
+	request := argfuzz.NewRequest(1, "eth_call", []any{[]any{[]any{"x"}}, "latest"})
+	body, err := json.Marshal(request)
+	require.NoError(t, err)
+	outcome := tr.send(ctx, body)
+	require.Equal(t, findingCrash, outcome.Finding)
+
+	f := tr.record(ctx, "eth_call", "deep_nesting", request, body, outcome)
+	require.Equal(t, 1, f.Count)
+	require.Equal(t, len(body), f.OriginalSize)
+	require.JSONEq(t, `{"id":1,"jsonrpc":"2.0","method":"eth_call","params":["x"]}`, string(f.Request))
+
+	// Repeated findings are counted without being minimised again
+	sent := requests.Load()
+	f = tr.record(ctx, "eth_call", "arity", request, body, outcome)
+	require.Equal(t, 2, f.Count)
+	require.Equal(t, sent, requests.Load())
+	require.Len(t, tr.findings, 1)
+}
+
+// TestRecordTimeout checks timeouts are saved as sent, without minimising
+// them against an endpoint that is already slow.
+func TestRecordTimeout(t *testing.T) {
+	withFuzzTimeout(t, 50*time.Millisecond)
+	ctx := context.Background()
+
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var request struct {
+			Method string `json:"method"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		if request.Method == "eth_getLogs" {
+			time.Sleep(300 * time.Millisecond)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"Geth/v1.14.0"}`))
+	}))
+	defer server.Close()
+	tr := newTriage(server.URL, "")
+
+	request := argfuzz.NewRequest(1, "eth_getLogs", []any{map[string]any{"fromBlock": "0x0", "toBlock": "0xffffffff"}})
+	body, err := json.Marshal(request)
+	require.NoError(t, err)
+	outcome := tr.send(ctx, body)
+	require.Equal(t, findingTimeout, outcome.Finding)
+
+	f := tr.record(ctx, "eth_getLogs", "boundary", request, body, outcome)
+	require.False(t, tr.unreachable)
+	require.JSONEq(t, string(body), string(f.Request))
+	// The timed out request and the liveness check, nothing else
+	require.Equal(t, int64(2), requests.Load())
+}
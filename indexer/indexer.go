@@ -25,8 +25,12 @@ type Indexer struct {
 	maxConcurrency  int           // Maximum concurrent requests to the store
 
 	// State tracking
-	latestHeight int64        // Latest block height we've indexed
-	mu           sync.RWMutex // Protects state fields
+	latestHeight     int64                  // Latest block height we've indexed
+	canonical        map[int64]trackedBlock // Published blocks within reorgDepth of the tip
+	reorgs           []ReorgEvent           // Detected reorgs, oldest first
+	reorgHistorySize int                    // Maximum number of reorgs kept
+	droppedReorgs    int                    // Reorg events dropped on a full channel
+	mu               sync.RWMutex           // Protects state fields
 
 	// Control channels
 	ctx    context.Context
@@ -36,6 +40,9 @@ type Indexer struct {
 	// Block channel for publishing new blocks to renderers
 	blockChan chan rpctypes.PolyBlock
 
+	// Reorg channel for publishing detected reorgs to renderers
+	reorgChan chan ReorgEvent
+
 	// Metrics system
 	metrics *metrics.MetricsSystem
 
@@ -58,6 +65,9 @@ type Config struct {
 
 	// MaxConcurrency limits the number of concurrent requests
 	MaxConcurrency int
+
+	// ReorgHistorySize is how many detected reorgs to keep in memory
+	ReorgHistorySize int
 }
 
 // DefaultConfig returns a default configuration
@@ -67,6 +77,8 @@ func DefaultConfig() *Config {
 		LookbackDepth:   128,
 		ReorgDepth:      128,
 		MaxConcurrency:  10,
+
+		ReorgHistorySize: 100,
 	}
 }
 
@@ -76,6 +88,11 @@ func NewIndexer(store chainstore.ChainStore, cfg *Config) *Indexer {
 		cfg = DefaultConfig()
 	}
 
+	reorgHistorySize := cfg.ReorgHistorySize
+	if reorgHistorySize <= 0 {
+		reorgHistorySize = DefaultConfig().ReorgHistorySize
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	// Create metrics system and register default plugins
@@ -87,18 +104,21 @@ func NewIndexer(store chainstore.ChainStore, cfg *Config) *Indexer {
 	metricsSystem.RegisterPlugin(metrics.NewBaseFeeMetric())
 
 	return &Indexer{
-		store:           store,
-		pollingInterval: cfg.PollingInterval,
-		lookbackDepth:   cfg.LookbackDepth,
-		reorgDepth:      cfg.ReorgDepth,
-		maxConcurrency:  cfg.MaxConcurrency,
-		latestHeight:    -1,
-		ctx:             ctx,
-		cancel:          cancel,
-		done:            make(chan struct{}),
-		blockChan:       make(chan rpctypes.PolyBlock, 100), // Buffered channel
-		metrics:         metricsSystem,
-		workerSem:       make(chan struct{}, cfg.MaxConcurrency),
+		store:            store,
+		pollingInterval:  cfg.PollingInterval,
+		lookbackDepth:    cfg.LookbackDepth,
+		reorgDepth:       cfg.ReorgDepth,
+		maxConcurrency:   cfg.MaxConcurrency,
+		latestHeight:     -1,
+		canonical:        make(map[int64]trackedBlock),
+		reorgHistorySize: reorgHistorySize,
+		ctx:              ctx,
+		cancel:           cancel,
+		done:             make(chan struct{}),
+		blockChan:        make(chan rpctypes.PolyBlock, 100), // Buffered channel
+		reorgChan:        make(chan ReorgEvent, 16),
+		metrics:          metricsSystem,
+		workerSem:        make(chan struct{}, cfg.MaxConcurrency),
 	}
 }
 
@@ -176,6 +196,16 @@ func (i *Indexer) GetTxPoolStatus(ctx context.Context) (map[string]any, error) {
 	return i.store.GetTxPoolStatus(ctx)
 }
 
+// GetTxPoolContent retrieves the full txpool contents
+func (i *Indexer) GetTxPoolContent(ctx context.Context) (*chainstore.TxPoolContent, error) {
+	return i.store.GetTxPoolContent(ctx)
+}
+
+// GetTxPoolInspect retrieves the txpool inspect summary
+func (i *Indexer) GetTxPoolInspect(ctx context.Context) (*chainstore.TxPoolInspect, error) {
+	return i.store.GetTxPoolInspect(ctx)
+}
+
 // GetNetPeerCount retrieves the number of connected peers
 func (i *Indexer) GetNetPeerCount(ctx context.Context) (*big.Int, error) {
 	return i.store.GetNetPeerCount(ctx)
@@ -216,6 +246,36 @@ func (i *Indexer) GetSignature(ctx context.Context, hexSignature string) ([]chai
 	return i.store.GetSignature(ctx, hexSignature)
 }
 
+// DecodeCalldata decodes transaction input using the local ABI registry
+func (i *Indexer) DecodeCalldata(data []byte) (*chainstore.DecodedCall, error) {
+	return i.store.DecodeCalldata(data)
+}
+
+// DecodeLog decodes an event log using the local ABI registry
+func (i *Indexer) DecodeLog(topics []common.Hash, data []byte) (*chainstore.DecodedCall, error) {
+	return i.store.DecodeLog(topics, data)
+}
+
+// DecodeRevert decodes revert data using the local ABI registry
+func (i *Indexer) DecodeRevert(data []byte) (*chainstore.DecodedCall, error) {
+	return i.store.DecodeRevert(data)
+}
+
+// GetRevertData replays a failed transaction to recover its revert data
+func (i *Indexer) GetRevertData(ctx context.Context, tx rpctypes.PolyTransaction) ([]byte, error) {
+	return i.store.GetRevertData(ctx, tx)
+}
+
+// GetCallTrace retrieves the call tree of a transaction
+func (i *Indexer) GetCallTrace(ctx context.Context, txHash common.Hash) (*chainstore.CallFrame, error) {
+	return i.store.GetCallTrace(ctx, txHash)
+}
+
+// GetStateDiff retrieves the state changes of a transaction
+func (i *Indexer) GetStateDiff(ctx context.Context, txHash common.Hash) (*chainstore.StateDiff, error) {
+	return i.store.GetStateDiff(ctx, txHash)
+}
+
 // Start begins the indexing process
 func (i *Indexer) Start() error {
 	log.Info().Msg("Starting indexer")
@@ -230,6 +290,7 @@ func (i *Indexer) Stop() error {
 	i.cancel()
 	close(i.blockChan)
 	<-i.done
+	close(i.reorgChan)
 
 	// Stop the metrics system
 	i.metrics.Stop()
@@ -278,48 +339,73 @@ func (i *Indexer) checkForNewBlocks() error {
 	lastProcessed := i.latestHeight
 	i.mu.Unlock()
 
-	// If we've missed blocks, fetch them all to maintain order
-	if currentTip > lastProcessed {
-		log.Debug().
-			Int64("currentTip", currentTip).
-			Int64("lastProcessed", lastProcessed).
-			Int64("gap", currentTip-lastProcessed).
-			Msg("Catching up missed blocks")
-
-		// Fetch missed blocks in parallel and publish them in order
-		startHeight := lastProcessed + 1
-		blocks, err := i.fetchBlocksInParallel(startHeight, currentTip)
-		if err != nil {
-			return err
+	// A tip at or below what we've processed with a different hash means the
+	// chain was reorganized without growing
+	if currentTip <= lastProcessed {
+		if hash, ok := i.trackedHash(currentTip); ok && hash != latestBlock.Hash() {
+			return i.handleReorg(currentTip)
 		}
+		return nil
+	}
 
-		// Publish blocks to channel in order
-		for height, block := range blocks {
-			if block == nil {
-				// Skip blocks that failed to fetch
-				continue
-			}
+	// If we've missed blocks, fetch them all to maintain order
+	log.Debug().
+		Int64("currentTip", currentTip).
+		Int64("lastProcessed", lastProcessed).
+		Int64("gap", currentTip-lastProcessed).
+		Msg("Catching up missed blocks")
+
+	// Fetch missed blocks in parallel and publish them in order
+	startHeight := lastProcessed + 1
+	blocks, err := i.fetchBlocksInParallel(startHeight, currentTip)
+	if err != nil {
+		return err
+	}
 
-			// Send block to metrics system
-			i.metrics.ProcessBlock(block)
-
-			select {
-			case i.blockChan <- block:
-				blockHeight := startHeight + int64(height)
-				log.Debug().Int64("height", blockHeight).Str("hash", block.Hash().Hex()).Msg("Published block")
-				// Update latest height after successful publish
-				i.mu.Lock()
-				i.latestHeight = blockHeight
-				i.mu.Unlock()
-			case <-i.ctx.Done():
-				return i.ctx.Err()
+	// The first new block must build on the last published one
+	if len(blocks) > 0 && blocks[0] != nil {
+		if hash, ok := i.trackedHash(lastProcessed); ok && blocks[0].ParentHash() != hash {
+			if err := i.handleReorg(lastProcessed); err != nil {
+				return err
 			}
 		}
 	}
 
+	// Publish blocks to channel in order
+	for height, block := range blocks {
+		if block == nil {
+			// Skip blocks that failed to fetch
+			continue
+		}
+
+		if err := i.publishBlock(startHeight+int64(height), block); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// publishBlock sends a block to the metrics system and the block channel,
+// and records it as the canonical block at its height
+func (i *Indexer) publishBlock(height int64, block rpctypes.PolyBlock) error {
+	// Send block to metrics system
+	i.metrics.ProcessBlock(block)
+
+	select {
+	case i.blockChan <- block:
+		log.Debug().Int64("height", height).Str("hash", block.Hash().Hex()).Msg("Published block")
+		// Update latest height after successful publish
+		i.mu.Lock()
+		i.latestHeight = height
+		i.trackBlock(height, block)
+		i.mu.Unlock()
+		return nil
+	case <-i.ctx.Done():
+		return i.ctx.Err()
+	}
+}
+
 // initialCatchup fetches recent blocks to provide context when starting
 func (i *Indexer) initialCatchup() error {
 	// Get the current tip of the chain
@@ -352,14 +438,8 @@ func (i *Indexer) initialCatchup() error {
 			continue
 		}
 
-		// Send block to metrics system
-		i.metrics.ProcessBlock(block)
-
-		select {
-		case i.blockChan <- block:
-			log.Debug().Int64("height", startHeight+int64(height)).Str("hash", block.Hash().Hex()).Msg("Published catchup block")
-		case <-i.ctx.Done():
-			return i.ctx.Err()
+		if err := i.publishBlock(startHeight+int64(height), block); err != nil {
+			return err
 		}
 	}
 
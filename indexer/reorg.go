@@ -0,0 +1,223 @@
+package indexer
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/0xPolygon/polygon-cli/rpctypes"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rs/zerolog/log"
+)
+
+// ReorgEvent describes a chain reorganization detected by the indexer
+type ReorgEvent struct {
+	DetectedAt     time.Time     `json:"detectedAt"`
+	Depth          int           `json:"depth"`          // Number of blocks replaced or removed
+	CommonAncestor int64         `json:"commonAncestor"` // Last block both chains share, -1 if not found
+	FirstBlock     int64         `json:"firstBlock"`     // Number of the first orphaned block
+	OldHashes      []common.Hash `json:"oldHashes"`      // Hashes of the orphaned blocks, lowest first
+	NewHashes      []common.Hash `json:"newHashes"`      // Hashes of the replacement blocks, lowest first
+	OrphanedTxs    []common.Hash `json:"orphanedTxs"`    // Transactions in orphaned blocks missing from the new chain
+	Truncated      bool          `json:"truncated"`      // The fork point is deeper than ReorgDepth
+}
+
+// trackedBlock is the part of a published block needed to detect reorgs
+type trackedBlock struct {
+	hash common.Hash
+	txs  []common.Hash
+}
+
+// newTrackedBlock extracts the reorg tracking data of a block
+func newTrackedBlock(block rpctypes.PolyBlock) trackedBlock {
+	txs := block.Transactions()
+	hashes := make([]common.Hash, 0, len(txs))
+	for _, tx := range txs {
+		hashes = append(hashes, tx.Hash())
+	}
+	return trackedBlock{hash: block.Hash(), txs: hashes}
+}
+
+// trackBlock records a published block and forgets blocks that are too deep
+// to be reorged, keeping the reorgDepth blocks handleReorg walks back over.
+// Callers must hold i.mu.
+func (i *Indexer) trackBlock(height int64, block rpctypes.PolyBlock) {
+	i.canonical[height] = newTrackedBlock(block)
+
+	for h := range i.canonical {
+		if h <= height-i.reorgDepth {
+			delete(i.canonical, h)
+		}
+	}
+}
+
+// trackedHash returns the hash of the published block at height
+func (i *Indexer) trackedHash(height int64) (common.Hash, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	block, ok := i.canonical[height]
+	return block.hash, ok
+}
+
+// handleReorg walks back from fromHeight until the chain matches the
+// published blocks again, records the reorg, and republishes the new
+// canonical blocks. Published blocks above fromHeight are treated as removed.
+func (i *Indexer) handleReorg(fromHeight int64) error {
+	i.mu.RLock()
+	lastProcessed := i.latestHeight
+	i.mu.RUnlock()
+
+	event := ReorgEvent{
+		DetectedAt:     time.Now(),
+		CommonAncestor: -1,
+	}
+
+	var orphaned []trackedBlock
+	var replacements []rpctypes.PolyBlock
+
+	// Blocks above the new tip have no replacement yet
+	i.mu.RLock()
+	for height := lastProcessed; height > fromHeight; height-- {
+		if old, ok := i.canonical[height]; ok {
+			orphaned = append(orphaned, old)
+		}
+	}
+	i.mu.RUnlock()
+
+	for height := fromHeight; height >= 0 && fromHeight-height < i.reorgDepth; height-- {
+		oldHash, ok := i.trackedHash(height)
+		if !ok {
+			event.Truncated = true
+			break
+		}
+
+		// Give up without recording anything on a failed fetch, so the next
+		// poll detects the whole reorg again rather than part of it
+		block, err := i.store.GetBlockByNumber(i.ctx, big.NewInt(height))
+		if err != nil {
+			return err
+		}
+		if block == nil {
+			return fmt.Errorf("block %d not found", height)
+		}
+
+		if block.Hash() == oldHash {
+			event.CommonAncestor = height
+			break
+		}
+
+		i.mu.RLock()
+		orphaned = append(orphaned, i.canonical[height])
+		i.mu.RUnlock()
+		replacements = append(replacements, block)
+	}
+	if event.CommonAncestor < 0 && !event.Truncated {
+		// Ran out of depth without finding the fork point
+		event.Truncated = true
+	}
+
+	// Both lists were collected from the top down
+	reverse(orphaned)
+	reverse(replacements)
+
+	included := make(map[common.Hash]struct{})
+	for _, block := range replacements {
+		event.NewHashes = append(event.NewHashes, block.Hash())
+		for _, tx := range block.Transactions() {
+			included[tx.Hash()] = struct{}{}
+		}
+	}
+	for _, old := range orphaned {
+		event.OldHashes = append(event.OldHashes, old.hash)
+		for _, tx := range old.txs {
+			if _, ok := included[tx]; !ok {
+				event.OrphanedTxs = append(event.OrphanedTxs, tx)
+			}
+		}
+	}
+	event.Depth = len(orphaned)
+	if event.Depth == 0 {
+		// The chain matched after all, e.g. blocks were fetched mid-update
+		return nil
+	}
+
+	log.Warn().
+		Int("depth", event.Depth).
+		Int64("commonAncestor", event.CommonAncestor).
+		Int("orphanedTxs", len(event.OrphanedTxs)).
+		Bool("truncated", event.Truncated).
+		Msg("Chain reorganization detected")
+
+	// Rewind to the fork point before republishing the new chain
+	firstHeight := fromHeight - int64(len(replacements)) + 1
+	event.FirstBlock = firstHeight
+
+	i.mu.Lock()
+	for height := range i.canonical {
+		if height >= firstHeight {
+			delete(i.canonical, height)
+		}
+	}
+	i.latestHeight = firstHeight - 1
+	i.mu.Unlock()
+
+	// Publish the new chain before the event, so consumers of the reorg
+	// already have the blocks it refers to
+	for idx, block := range replacements {
+		if err := i.publishBlock(firstHeight+int64(idx), block); err != nil {
+			return err
+		}
+	}
+
+	i.mu.Lock()
+	i.reorgs = append(i.reorgs, event)
+	if len(i.reorgs) > i.reorgHistorySize {
+		i.reorgs = i.reorgs[len(i.reorgs)-i.reorgHistorySize:]
+	}
+	i.mu.Unlock()
+
+	// Renderers that don't consume reorgs must not stall the indexer. The
+	// event stays available through GetReorgs.
+	select {
+	case i.reorgChan <- event:
+	default:
+		i.mu.Lock()
+		i.droppedReorgs++
+		dropped := i.droppedReorgs
+		i.mu.Unlock()
+		log.Warn().Int("dropped", dropped).Msg("Reorg channel full, dropping event")
+	}
+
+	return nil
+}
+
+// GetReorgs returns the recorded reorgs, oldest first
+func (i *Indexer) GetReorgs() []ReorgEvent {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	reorgs := make([]ReorgEvent, len(i.reorgs))
+	copy(reorgs, i.reorgs)
+	return reorgs
+}
+
+// ReorgChannel returns the channel where detected reorgs are published, after
+// the replacement blocks. Events are dropped rather than blocking the indexer
+// when nobody reads it.
+func (i *Indexer) ReorgChannel() <-chan ReorgEvent {
+	return i.reorgChan
+}
+
+// DroppedReorgs returns the number of reorg events dropped because the reorg
+// channel was full
+func (i *Indexer) DroppedReorgs() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.droppedReorgs
+}
+
+// reverse reverses a slice in place
+func reverse[T any](s []T) {
+	for l, r := 0, len(s)-1; l < r; l, r = l+1, r-1 {
+		s[l], s[r] = s[r], s[l]
+	}
+}
@@ -0,0 +1,287 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygon/polygon-cli/chainstore"
+	"github.com/0xPolygon/polygon-cli/rpctypes"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+// chainStore serves the blocks of a chain that tests replace to fork it
+type chainStore struct {
+	chainstore.ChainStore
+	blocks map[int64]rpctypes.PolyBlock
+	tip    int64
+	// missing heights are served as nil blocks, like a node that lost them
+	missing map[int64]bool
+}
+
+func (s *chainStore) GetLatestBlock(ctx context.Context) (rpctypes.PolyBlock, error) {
+	return s.blocks[s.tip], nil
+}
+
+func (s *chainStore) GetBlockByNumber(ctx context.Context, number *big.Int) (rpctypes.PolyBlock, error) {
+	if s.missing[number.Int64()] {
+		return nil, nil
+	}
+	block, ok := s.blocks[number.Int64()]
+	if !ok {
+		return nil, fmt.Errorf("block %d not found", number)
+	}
+	return block, nil
+}
+
+// testHash derives a distinct hash from a block height, fork and kind
+func testHash(kind byte, height int64, fork byte) common.Hash {
+	return common.BigToHash(new(big.Int).SetBytes([]byte{kind, fork, byte(height >> 8), byte(height)}))
+}
+
+// setChain replaces the served chain with one of blocks 0 to tip, where the
+// blocks from forkAt up belong to fork. Each block has one transaction.
+func (s *chainStore) setChain(tip, forkAt int64, fork byte) {
+	s.blocks = make(map[int64]rpctypes.PolyBlock)
+	s.tip = tip
+	parent := common.Hash{}
+	for height := int64(0); height <= tip; height++ {
+		f := byte(0)
+		if height >= forkAt {
+			f = fork
+		}
+		hash := testHash('b', height, f)
+		s.blocks[height] = rpctypes.NewPolyBlock(&rpctypes.RawBlockResponse{
+			Number:        rpctypes.RawQuantityResponse(fmt.Sprintf("0x%x", height)),
+			Hash:          rpctypes.RawData32Response(hash.Hex()),
+			ParentHash:    rpctypes.RawData32Response(parent.Hex()),
+			Timestamp:     rpctypes.RawQuantityResponse(fmt.Sprintf("0x%x", 1000+2*height)),
+			GasUsed:       "0x5208",
+			GasLimit:      "0x1c9c380",
+			Size:          "0x220",
+			BaseFeePerGas: "0x7",
+			Transactions: []rpctypes.RawTransactionResponse{{
+				Hash: rpctypes.RawData32Response(testHash('t', height, f).Hex()),
+			}},
+		})
+		parent = hash
+	}
+}
+
+// chainUpdate is the chain served before one poll of the indexer
+type chainUpdate struct {
+	tip    int64
+	forkAt int64
+	fork   byte
+}
+
+// publishedBlock identifies a block published by the indexer
+type publishedBlock struct {
+	height int64
+	fork   byte
+}
+
+func TestIndexerReorgs(t *testing.T) {
+	tests := []struct {
+		name       string
+		reorgDepth int64
+		updates    []chainUpdate
+		published  []publishedBlock
+		reorgs     []ReorgEvent
+		latest     int64
+	}{
+		{
+			name:       "same height replacement",
+			reorgDepth: 8,
+			updates:    []chainUpdate{{tip: 10, forkAt: 10, fork: 1}},
+			published:  []publishedBlock{{10, 1}},
+			reorgs: []ReorgEvent{{
+				Depth:          1,
+				CommonAncestor: 9,
+				FirstBlock:     10,
+				OldHashes:      []common.Hash{testHash('b', 10, 0)},
+				NewHashes:      []common.Hash{testHash('b', 10, 1)},
+				OrphanedTxs:    []common.Hash{testHash('t', 10, 0)},
+			}},
+			latest: 10,
+		},
+		{
+			name:       "parent hash mismatch",
+			reorgDepth: 8,
+			updates:    []chainUpdate{{tip: 12, forkAt: 8, fork: 1}},
+			published:  []publishedBlock{{8, 1}, {9, 1}, {10, 1}, {11, 1}, {12, 1}},
+			reorgs: []ReorgEvent{{
+				Depth:          3,
+				CommonAncestor: 7,
+				FirstBlock:     8,
+				OldHashes:      []common.Hash{testHash('b', 8, 0), testHash('b', 9, 0), testHash('b', 10, 0)},
+				NewHashes:      []common.Hash{testHash('b', 8, 1), testHash('b', 9, 1), testHash('b', 10, 1)},
+				OrphanedTxs:    []common.Hash{testHash('t', 8, 0), testHash('t', 9, 0), testHash('t', 10, 0)},
+			}},
+			latest: 12,
+		},
+		{
+			name:       "deeper than tracked window",
+			reorgDepth: 3,
+			updates:    []chainUpdate{{tip: 10, forkAt: 5, fork: 1}},
+			published:  []publishedBlock{{8, 1}, {9, 1}, {10, 1}},
+			reorgs: []ReorgEvent{{
+				Depth:          3,
+				CommonAncestor: -1,
+				FirstBlock:     8,
+				OldHashes:      []common.Hash{testHash('b', 8, 0), testHash('b', 9, 0), testHash('b', 10, 0)},
+				NewHashes:      []common.Hash{testHash('b', 8, 1), testHash('b', 9, 1), testHash('b', 10, 1)},
+				OrphanedTxs:    []common.Hash{testHash('t', 8, 0), testHash('t', 9, 0), testHash('t', 10, 0)},
+				Truncated:      true,
+			}},
+			latest: 10,
+		},
+		{
+			name:       "no duplicates after rewind",
+			reorgDepth: 8,
+			updates: []chainUpdate{
+				{tip: 9, forkAt: 8, fork: 1},
+				{tip: 9, forkAt: 8, fork: 1},
+				{tip: 11, forkAt: 8, fork: 1},
+			},
+			published: []publishedBlock{{8, 1}, {9, 1}, {10, 1}, {11, 1}},
+			reorgs: []ReorgEvent{{
+				Depth:          3,
+				CommonAncestor: 7,
+				FirstBlock:     8,
+				OldHashes:      []common.Hash{testHash('b', 8, 0), testHash('b', 9, 0), testHash('b', 10, 0)},
+				NewHashes:      []common.Hash{testHash('b', 8, 1), testHash('b', 9, 1)},
+				OrphanedTxs:    []common.Hash{testHash('t', 8, 0), testHash('t', 9, 0), testHash('t', 10, 0)},
+			}},
+			latest: 11,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &chainStore{}
+			store.setChain(10, 11, 0)
+			idx := NewIndexer(store, &Config{
+				LookbackDepth:  10,
+				ReorgDepth:     tt.reorgDepth,
+				MaxConcurrency: 4,
+			})
+			defer idx.cancel()
+
+			require.NoError(t, idx.initialCatchup())
+			require.Len(t, drainBlocks(idx), 11)
+
+			for _, update := range tt.updates {
+				store.setChain(update.tip, update.forkAt, update.fork)
+				require.NoError(t, idx.checkForNewBlocks())
+			}
+
+			var published []publishedBlock
+			for _, block := range drainBlocks(idx) {
+				height := block.Number().Int64()
+				fork := byte(0)
+				if block.Hash() != testHash('b', height, 0) {
+					fork = 1
+				}
+				published = append(published, publishedBlock{height, fork})
+			}
+			require.Equal(t, tt.published, published)
+
+			reorgs := idx.GetReorgs()
+			for n := range reorgs {
+				require.False(t, reorgs[n].DetectedAt.IsZero())
+				reorgs[n].DetectedAt = tt.reorgs[n].DetectedAt
+			}
+			require.Equal(t, tt.reorgs, reorgs)
+			require.Len(t, idx.ReorgChannel(), len(tt.reorgs))
+			require.Equal(t, tt.latest, idx.LatestHeight())
+
+			// The tracked blocks are the new chain within the reorg window
+			for height := tt.latest - tt.reorgDepth + 1; height <= tt.latest; height++ {
+				hash, ok := idx.trackedHash(height)
+				require.True(t, ok, "height %d", height)
+				require.Equal(t, store.blocks[height].Hash(), hash, "height %d", height)
+			}
+			_, ok := idx.trackedHash(tt.latest - tt.reorgDepth)
+			require.False(t, ok)
+		})
+	}
+}
+
+// drainBlocks returns the blocks published so far
+func drainBlocks(idx *Indexer) []rpctypes.PolyBlock {
+	var blocks []rpctypes.PolyBlock
+	for {
+		select {
+		case block := <-idx.blockChan:
+			blocks = append(blocks, block)
+		default:
+			return blocks
+		}
+	}
+}
+
+func TestIndexerReorgFetchFailure(t *testing.T) {
+	store := &chainStore{}
+	store.setChain(10, 11, 0)
+	idx := NewIndexer(store, &Config{LookbackDepth: 10, ReorgDepth: 8, MaxConcurrency: 4})
+	defer idx.cancel()
+
+	require.NoError(t, idx.initialCatchup())
+	drainBlocks(idx)
+
+	// A block of the fork can't be fetched, so nothing is recorded
+	store.setChain(10, 8, 1)
+	store.missing = map[int64]bool{8: true}
+	require.Error(t, idx.checkForNewBlocks())
+	require.Empty(t, drainBlocks(idx))
+	require.Empty(t, idx.GetReorgs())
+	require.Empty(t, idx.ReorgChannel())
+	require.Equal(t, int64(10), idx.LatestHeight())
+	hash, ok := idx.trackedHash(10)
+	require.True(t, ok)
+	require.Equal(t, testHash('b', 10, 0), hash)
+
+	// The next poll sees the whole reorg
+	store.missing = nil
+	require.NoError(t, idx.checkForNewBlocks())
+	require.Len(t, drainBlocks(idx), 3)
+	reorgs := idx.GetReorgs()
+	require.Len(t, reorgs, 1)
+	require.Equal(t, 3, reorgs[0].Depth)
+	require.Equal(t, int64(7), reorgs[0].CommonAncestor)
+}
+
+func TestIndexerReorgEventOrder(t *testing.T) {
+	store := &chainStore{}
+	store.setChain(10, 11, 0)
+	idx := NewIndexer(store, &Config{LookbackDepth: 10, ReorgDepth: 8, MaxConcurrency: 4})
+	defer idx.cancel()
+
+	require.NoError(t, idx.initialCatchup())
+	drainBlocks(idx)
+
+	// By the time the event is sent, its replacement blocks are published
+	store.setChain(10, 9, 1)
+	require.NoError(t, idx.checkForNewBlocks())
+	event := <-idx.ReorgChannel()
+	var published []common.Hash
+	for _, block := range drainBlocks(idx) {
+		published = append(published, block.Hash())
+	}
+	require.Equal(t, event.NewHashes, published)
+
+	// Events that don't fit in the channel are counted and kept in history
+	for range cap(idx.reorgChan) {
+		idx.reorgChan <- ReorgEvent{}
+	}
+	store.setChain(10, 9, 2)
+	require.NoError(t, idx.checkForNewBlocks())
+	require.Equal(t, 1, idx.DroppedReorgs())
+	reorgs := idx.GetReorgs()
+	require.Len(t, reorgs, 2)
+	require.Equal(t, []common.Hash{testHash('b', 9, 2), testHash('b', 10, 2)}, reorgs[1].NewHashes)
+	require.Len(t, drainBlocks(idx), 2)
+}
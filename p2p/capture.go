@@ -0,0 +1,162 @@
+package p2p
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+)
+
+// captureBufferSize is the size of the buffers capture files are written and
+// read through. Lines are as long as the largest message, so reads grow the
+// buffer up to captureMaxLine.
+const (
+	captureBufferSize = 1 << 20
+	captureMaxLine    = 64 << 20
+)
+
+// ethMsgNames maps eth message codes, without the devp2p offset, to their
+// names.
+var ethMsgNames = map[uint64]string{
+	eth.StatusMsg:                     "Status",
+	eth.NewBlockHashesMsg:             "NewBlockHashes",
+	eth.TransactionsMsg:               "Transactions",
+	eth.GetBlockHeadersMsg:            "GetBlockHeaders",
+	eth.BlockHeadersMsg:               "BlockHeaders",
+	eth.GetBlockBodiesMsg:             "GetBlockBodies",
+	eth.BlockBodiesMsg:                "BlockBodies",
+	eth.NewBlockMsg:                   "NewBlock",
+	eth.NewPooledTransactionHashesMsg: "NewPooledTransactionHashes",
+	eth.GetPooledTransactionsMsg:      "GetPooledTransactions",
+	eth.PooledTransactionsMsg:         "PooledTransactions",
+	eth.GetReceiptsMsg:                "GetReceipts",
+	eth.ReceiptsMsg:                   "Receipts",
+	eth.BlockRangeUpdateMsg:           "BlockRangeUpdate",
+}
+
+// ethMsgName returns the name of an eth message code.
+func ethMsgName(code uint64) string {
+	if name, ok := ethMsgNames[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%02x", code)
+}
+
+// CaptureRecord is a raw eth message received from a peer.
+type CaptureRecord struct {
+	Time time.Time `json:"time"`
+	// Peer is the ID of the node the message was received from.
+	Peer string `json:"peer"`
+	// Version is the eth protocol version negotiated with the peer.
+	Version uint `json:"version"`
+	// Code is the eth message code, without the devp2p offset.
+	Code    uint64 `json:"code"`
+	Name    string `json:"name"`
+	Payload []byte `json:"payload"`
+}
+
+// CaptureWriter writes the messages a sensor receives to a file, one JSON
+// record per line. It's safe for concurrent use by every peer.
+type CaptureWriter struct {
+	mu   sync.Mutex
+	file *os.File
+	gz   *gzip.Writer
+	buf  *bufio.Writer
+	enc  *json.Encoder
+}
+
+// NewCaptureWriter creates the capture file at path, truncating it if it
+// exists. The file is gzipped if path ends in ".gz".
+func NewCaptureWriter(path string) (*CaptureWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create capture file: %w", err)
+	}
+
+	w := &CaptureWriter{file: file}
+	var out io.Writer = file
+	if strings.HasSuffix(path, ".gz") {
+		w.gz = gzip.NewWriter(file)
+		out = w.gz
+	}
+	w.buf = bufio.NewWriterSize(out, captureBufferSize)
+	w.enc = json.NewEncoder(w.buf)
+
+	return w, nil
+}
+
+// Write records a message received from peer at t.
+func (w *CaptureWriter) Write(t time.Time, peer string, version uint, code uint64, payload []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.enc.Encode(CaptureRecord{
+		Time:    t,
+		Peer:    peer,
+		Version: version,
+		Code:    code,
+		Name:    ethMsgName(code),
+		Payload: payload,
+	})
+}
+
+// Close flushes the buffered records and closes the file.
+func (w *CaptureWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	err := w.buf.Flush()
+	if w.gz != nil {
+		err = errors.Join(err, w.gz.Close())
+	}
+	return errors.Join(err, w.file.Close())
+}
+
+// ReadCapture reads every record of a capture file, in the order they were
+// written. Files ending in ".gz" are decompressed.
+func ReadCapture(path string) ([]CaptureRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open capture file: %w", err)
+	}
+	defer file.Close()
+
+	var in io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress capture file: %w", err)
+		}
+		defer gz.Close()
+		in = gz
+	}
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, captureBufferSize), captureMaxLine)
+
+	var records []CaptureRecord
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record CaptureRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("failed to decode capture record on line %d: %w", line, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read capture file: %w", err)
+	}
+
+	return records, nil
+}
@@ -0,0 +1,377 @@
+package p2p
+
+import (
+	"cmp"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// ntpEpochOffset is the number of seconds between the NTP epoch (1900) and
+	// the Unix epoch (1970).
+	ntpEpochOffset = 2208988800
+	ntpPacketSize  = 48
+	ntpDefaultPort = "123"
+)
+
+// ClockSample is a single NTP-style exchange with a reference clock. Offset is
+// how far the reference clock is ahead of the local clock, and Delay is the
+// round trip time excluding the time the reference spent answering.
+type ClockSample struct {
+	Offset time.Duration
+	Delay  time.Duration
+	Time   time.Time
+}
+
+// newClockSample computes a sample from the four timestamps of an exchange:
+// t0 request sent and t3 response received on the local clock, t1 request
+// received and t2 response sent on the reference clock.
+func newClockSample(t0, t1, t2, t3 time.Time) ClockSample {
+	return ClockSample{
+		Offset: (t1.Sub(t0) + t2.Sub(t3)) / 2,
+		Delay:  t3.Sub(t0) - t2.Sub(t1),
+		Time:   t3,
+	}
+}
+
+// ClockSyncOptions is used when creating a NewClockSync.
+type ClockSyncOptions struct {
+	// References are the clocks to synchronize with, either NTP servers as
+	// ntp://host[:port] or other sensors' APIs as http(s)://host:port.
+	References []string
+	// Interval is the time between probe rounds.
+	Interval time.Duration
+	// Samples is the number of recent samples kept per reference. The one with
+	// the lowest delay is used, since it has the least asymmetric network noise.
+	Samples int
+	// Timeout bounds a single probe.
+	Timeout time.Duration
+	// Metrics are updated with every estimate if set.
+	Metrics *ClockMetrics
+}
+
+// ClockSync estimates the offset of the local clock from a set of reference
+// clocks, so timestamps from sensors on different hosts can be compared. The
+// estimate is the median over references of each reference's lowest-delay
+// recent sample.
+type ClockSync struct {
+	references []string
+	interval   time.Duration
+	samples    int
+	timeout    time.Duration
+	client     *http.Client
+
+	mu      sync.Mutex
+	history map[string][]ClockSample
+	offset  atomic.Int64
+	synced  atomic.Bool
+
+	metrics *ClockMetrics
+}
+
+// NewClockSync validates the references and creates a ClockSync. Call Start to
+// begin probing.
+func NewClockSync(opts ClockSyncOptions) (*ClockSync, error) {
+	if len(opts.References) == 0 {
+		return nil, errors.New("at least one clock reference is required")
+	}
+	for _, ref := range opts.References {
+		u, err := url.Parse(ref)
+		if err != nil {
+			return nil, fmt.Errorf("invalid clock reference %q: %w", ref, err)
+		}
+		switch u.Scheme {
+		case "ntp", "http", "https":
+		default:
+			return nil, fmt.Errorf("invalid clock reference %q: scheme must be ntp, http or https", ref)
+		}
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid clock reference %q: missing host", ref)
+		}
+	}
+
+	if opts.Interval <= 0 {
+		opts.Interval = time.Minute
+	}
+	if opts.Samples <= 0 {
+		opts.Samples = 8
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+
+	return &ClockSync{
+		references: opts.References,
+		interval:   opts.Interval,
+		samples:    opts.Samples,
+		timeout:    opts.Timeout,
+		client:     &http.Client{Timeout: opts.Timeout},
+		history:    make(map[string][]ClockSample),
+		metrics:    opts.Metrics,
+	}, nil
+}
+
+// Start probes the references once before returning, so events recorded from
+// startup carry an offset, and then every interval until the context is
+// cancelled.
+func (c *ClockSync) Start(ctx context.Context) {
+	c.probeAll(ctx)
+	if !c.Synced() {
+		log.Warn().Msg("No clock reference answered, recording a zero clock offset until one does")
+	}
+
+	go func() {
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.probeAll(ctx)
+			}
+		}
+	}()
+}
+
+// Offset returns the current estimate of how far the reference clocks are
+// ahead of the local clock. It is zero for a nil ClockSync or before the first
+// successful probe.
+func (c *ClockSync) Offset() time.Duration {
+	if c == nil {
+		return 0
+	}
+	return time.Duration(c.offset.Load())
+}
+
+// Synced reports whether at least one probe has succeeded.
+func (c *ClockSync) Synced() bool {
+	return c != nil && c.synced.Load()
+}
+
+// Now returns the local time corrected by the current offset.
+func (c *ClockSync) Now() time.Time {
+	return time.Now().Add(c.Offset())
+}
+
+// probeAll probes every reference once and updates the offset estimate.
+func (c *ClockSync) probeAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, ref := range c.references {
+		wg.Add(1)
+		go func(ref string) {
+			defer wg.Done()
+
+			sample, err := c.probe(ctx, ref)
+			if err != nil {
+				log.Warn().Err(err).Str("reference", ref).Msg("Clock probe failed")
+				return
+			}
+
+			c.mu.Lock()
+			history := append(c.history[ref], sample)
+			if len(history) > c.samples {
+				history = history[len(history)-c.samples:]
+			}
+			c.history[ref] = history
+			c.mu.Unlock()
+		}(ref)
+	}
+	wg.Wait()
+
+	c.update()
+}
+
+// update recomputes the offset from the sample history.
+func (c *ClockSync) update() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	offsets := make([]time.Duration, 0, len(c.history))
+	for ref, history := range c.history {
+		if len(history) == 0 {
+			continue
+		}
+		best := slices.MinFunc(history, func(a, b ClockSample) int {
+			return cmp.Compare(a.Delay, b.Delay)
+		})
+		offsets = append(offsets, best.Offset)
+		c.metrics.updateDelay(ref, best.Delay)
+	}
+	if len(offsets) == 0 {
+		return
+	}
+
+	slices.Sort(offsets)
+	offset := offsets[len(offsets)/2]
+	if len(offsets)%2 == 0 {
+		offset = (offsets[len(offsets)/2-1] + offset) / 2
+	}
+
+	c.offset.Store(int64(offset))
+	c.synced.Store(true)
+	c.metrics.updateOffset(offset)
+
+	log.Debug().Dur("offset", offset).Int("references", len(offsets)).Msg("Updated clock offset")
+}
+
+// probe performs one exchange with a reference.
+func (c *ClockSync) probe(ctx context.Context, ref string) (ClockSample, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ClockSample{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	if u.Scheme == "ntp" {
+		return probeNTP(ctx, u.Host)
+	}
+	return c.probeSensor(ctx, strings.TrimSuffix(ref, "/")+"/time")
+}
+
+// ClockResponse is the body of a sensor's /time endpoint, in Unix nanoseconds
+// on the sensor's corrected clock.
+type ClockResponse struct {
+	Receive  int64 `json:"receive"`
+	Transmit int64 `json:"transmit"`
+	Synced   bool  `json:"synced"`
+}
+
+// probeSensor performs an exchange with another sensor's /time endpoint.
+func (c *ClockSync) probeSensor(ctx context.Context, endpoint string) (ClockSample, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return ClockSample{}, err
+	}
+
+	t0 := time.Now()
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return ClockSample{}, err
+	}
+	defer resp.Body.Close()
+
+	var body ClockResponse
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	t3 := time.Now()
+	if err != nil {
+		return ClockSample{}, fmt.Errorf("failed to decode clock response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return ClockSample{}, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return newClockSample(t0, time.Unix(0, body.Receive), time.Unix(0, body.Transmit), t3), nil
+}
+
+// ClockHandler serves the /time endpoint other sensors probe. It answers with
+// the corrected clock, so sensors referencing a synchronized sensor converge on
+// the same reference time.
+func ClockHandler(c *ClockSync) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		receive := c.Now()
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		body := ClockResponse{
+			Receive: receive.UnixNano(),
+			Synced:  c.Synced(),
+		}
+		body.Transmit = c.Now().UnixNano()
+		if err := json.NewEncoder(w).Encode(body); err != nil {
+			log.Error().Err(err).Msg("Failed to encode clock response")
+		}
+	}
+}
+
+// probeNTP performs an SNTP (RFC 4330) exchange with an NTP server.
+func probeNTP(ctx context.Context, host string) (ClockSample, error) {
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, ntpDefaultPort)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", host)
+	if err != nil {
+		return ClockSample{}, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return ClockSample{}, err
+		}
+	}
+
+	// LI = 0, VN = 4, Mode = 3 (client). The transmit timestamp is echoed back
+	// as the originate timestamp, which guards against stale responses.
+	req := make([]byte, ntpPacketSize)
+	req[0] = 0x23
+	t0 := time.Now()
+	putNTPTime(req[40:], t0)
+
+	if _, err := conn.Write(req); err != nil {
+		return ClockSample{}, err
+	}
+
+	resp := make([]byte, ntpPacketSize)
+	n, err := conn.Read(resp)
+	t3 := time.Now()
+	if err != nil {
+		return ClockSample{}, err
+	}
+
+	return parseNTPResponse(resp[:n], req[40:48], t0, t3)
+}
+
+// parseNTPResponse validates an SNTP response and computes the sample.
+func parseNTPResponse(resp, originate []byte, t0, t3 time.Time) (ClockSample, error) {
+	if len(resp) < ntpPacketSize {
+		return ClockSample{}, fmt.Errorf("short NTP response of %d bytes", len(resp))
+	}
+	if mode := resp[0] & 0x7; mode != 4 {
+		return ClockSample{}, fmt.Errorf("unexpected NTP mode %d", mode)
+	}
+	if resp[1] == 0 {
+		return ClockSample{}, fmt.Errorf("NTP kiss-o'-death %q", string(resp[12:16]))
+	}
+	if string(resp[24:32]) != string(originate) {
+		return ClockSample{}, errors.New("NTP response does not match request")
+	}
+
+	t1 := ntpTime(resp[32:40])
+	t2 := ntpTime(resp[40:48])
+	return newClockSample(t0, t1, t2, t3), nil
+}
+
+// ntpTime decodes a 64-bit NTP timestamp.
+func ntpTime(b []byte) time.Time {
+	seconds := int64(binary.BigEndian.Uint32(b[0:4])) - ntpEpochOffset
+	fraction := int64(binary.BigEndian.Uint32(b[4:8]))
+	return time.Unix(seconds, (fraction*int64(time.Second))>>32)
+}
+
+// putNTPTime encodes a 64-bit NTP timestamp.
+func putNTPTime(b []byte, t time.Time) {
+	binary.BigEndian.PutUint32(b[0:4], uint32(t.Unix()+ntpEpochOffset))
+	binary.BigEndian.PutUint32(b[4:8], uint32((int64(t.Nanosecond())<<32)/int64(time.Second)))
+}
@@ -0,0 +1,142 @@
+package p2p
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewClockSample(t *testing.T) {
+	// The reference is 2s ahead, the network takes 10ms each way and the
+	// reference spends 1ms answering.
+	t0 := time.Unix(1000, 0)
+	t1 := t0.Add(2*time.Second + 10*time.Millisecond)
+	t2 := t1.Add(time.Millisecond)
+	t3 := t0.Add(21 * time.Millisecond)
+
+	sample := newClockSample(t0, t1, t2, t3)
+	if sample.Offset != 2*time.Second {
+		t.Fatalf("offset: want 2s got %s", sample.Offset)
+	}
+	if sample.Delay != 20*time.Millisecond {
+		t.Fatalf("delay: want 20ms got %s", sample.Delay)
+	}
+}
+
+func TestNTPTimeRoundTrip(t *testing.T) {
+	want := time.Unix(1700000000, 123456789)
+	b := make([]byte, 8)
+	putNTPTime(b, want)
+
+	// NTP fractions have a resolution of ~233ps, so allow rounding down.
+	if got := ntpTime(b); want.Sub(got) < 0 || want.Sub(got) > time.Nanosecond {
+		t.Fatalf("want %s got %s", want, got)
+	}
+}
+
+// TestClockSyncNTP runs a fake NTP server whose clock is 500ms ahead.
+func TestClockSyncNTP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer conn.Close()
+
+	go func() {
+		buf := make([]byte, ntpPacketSize)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < ntpPacketSize {
+				continue
+			}
+
+			resp := make([]byte, ntpPacketSize)
+			resp[0] = 0x24 // LI = 0, VN = 4, Mode = 4 (server)
+			resp[1] = 1
+			copy(resp[24:32], buf[40:48])
+			putNTPTime(resp[32:40], time.Now().Add(500*time.Millisecond))
+			putNTPTime(resp[40:48], time.Now().Add(500*time.Millisecond))
+			_, _ = conn.WriteTo(resp, addr)
+		}
+	}()
+
+	clock, err := NewClockSync(ClockSyncOptions{
+		References: []string{"ntp://" + conn.LocalAddr().String()},
+		Interval:   time.Hour,
+		Timeout:    time.Second,
+	})
+	if err != nil {
+		t.Fatalf("new clock sync: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock.Start(ctx)
+
+	if !clock.Synced() {
+		t.Fatal("clock should be synced after the first probe")
+	}
+	if offset := clock.Offset(); offset < 490*time.Millisecond || offset > 510*time.Millisecond {
+		t.Fatalf("offset: want ~500ms got %s", offset)
+	}
+}
+
+// TestClockSyncSensor probes a sensor /time endpoint whose clock is 1s behind,
+// and checks that the handler serves the corrected time.
+func TestClockSyncSensor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now().Add(-time.Second).UnixNano()
+		_ = json.NewEncoder(w).Encode(ClockResponse{Receive: now, Transmit: now, Synced: true})
+	}))
+	defer server.Close()
+
+	clock, err := NewClockSync(ClockSyncOptions{
+		References: []string{server.URL},
+		Interval:   time.Hour,
+		Timeout:    time.Second,
+	})
+	if err != nil {
+		t.Fatalf("new clock sync: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock.Start(ctx)
+
+	if offset := clock.Offset(); offset > -990*time.Millisecond || offset < -1010*time.Millisecond {
+		t.Fatalf("offset: want ~-1s got %s", offset)
+	}
+
+	rec := httptest.NewRecorder()
+	ClockHandler(clock)(rec, httptest.NewRequest(http.MethodGet, "/time", nil))
+
+	var resp ClockResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if skew := time.Since(time.Unix(0, resp.Transmit)); skew < 990*time.Millisecond || skew > 1010*time.Millisecond {
+		t.Fatalf("handler should serve the corrected clock, got %s behind", skew)
+	}
+	if !resp.Synced {
+		t.Fatal("handler should report the clock as synced")
+	}
+}
+
+func TestNewClockSyncValidation(t *testing.T) {
+	for _, refs := range [][]string{
+		nil,
+		{"udp://pool.ntp.org"},
+		{"ntp://"},
+	} {
+		if _, err := NewClockSync(ClockSyncOptions{References: refs}); err == nil {
+			t.Fatalf("references %v should be rejected", refs)
+		}
+	}
+}
@@ -21,9 +21,13 @@ import (
 
 // BlockCache stores the actual block data to avoid duplicate fetches and database queries.
 type BlockCache struct {
-	Header *types.Header
-	Body   *eth.BlockBody
-	TD     *big.Int
+	Header   *types.Header
+	Body     *eth.BlockBody
+	TD       *big.Int
+	Receipts types.Receipts
+
+	// receiptsRequested is when the receipts were last requested from a peer.
+	receiptsRequested time.Time
 }
 
 // ConnsOptions contains configuration options for creating a new Conns manager.
@@ -51,6 +55,14 @@ type ConnsOptions struct {
 	// in the serving cache. Unknown-signer blocks are still persisted to the
 	// database but their header/body are not cached or served to peers.
 	CacheOnlyValidatedBlocks bool
+
+	// Score configures peer scoring and eviction. Scoring is disabled if
+	// Score.Interval is zero.
+	Score ScoreOptions
+
+	// Producers configures the monitoring of block producers. Monitoring is
+	// disabled if Producers.BlockPeriod is zero.
+	Producers ProducerOptions
 }
 
 // Conns manages a collection of active peer connections for transaction broadcasting.
@@ -63,6 +75,9 @@ type Conns struct {
 	// to avoid duplicate writes and requests.
 	blocks *ds.LRU[common.Hash, BlockCache]
 
+	// receipts indexes the receipts in the blocks cache by transaction hash.
+	receipts *receiptIndex
+
 	// txs caches transactions for serving to peers and duplicate detection
 	txs *ds.LRU[common.Hash, *types.Transaction]
 
@@ -72,6 +87,10 @@ type Conns struct {
 	// affected by the fetch/serve dedup.
 	announcedTxs *ds.LRU[common.Hash, struct{}]
 
+	// nonces tracks the next nonce of senders of transactions in blocks, to
+	// leave included transactions out of mempool snapshots.
+	nonces *ds.LRU[common.Address, uint64]
+
 	// knownTxsBloom stores bloom filter options for per-peer known tx tracking
 	knownTxsBloom ds.BloomSetOptions
 	// knownBlocksMax stores the maximum size for per-peer known block caches
@@ -107,6 +126,26 @@ type Conns struct {
 	// serving cache (unknown-signer blocks are recorded to the database only).
 	cacheOnlyValidated bool
 
+	// snapResults stores the latest snap probe results per peer ID. It is
+	// guarded by mu.
+	snapResults map[string][]SnapResult
+
+	// scorer, when non-nil, scores peers and evicts low scorers.
+	scorer *scorer
+
+	// producers, when non-nil, detects block producer misbehaviour.
+	producers *producerMonitor
+
+	// closeCh is closed when the connection manager is closed.
+	closeCh chan struct{}
+
+	// Feeds of newly seen heads, verified logs and transactions for RPC
+	// subscriptions. notifiedHead is the number of the last head sent.
+	headsFeed    feed[*types.Header]
+	logsFeed     feed[[]*types.Log]
+	txsFeed      feed[[]*types.Transaction]
+	notifiedHead ds.Locked[*big.Int]
+
 	// metrics tracks broadcast-related Prometheus metrics
 	metrics *metrics
 }
@@ -148,8 +187,10 @@ func NewConns(opts ConnsOptions) *Conns {
 	c := &Conns{
 		conns:                      make(map[string]*conn),
 		blocks:                     ds.NewLRU[common.Hash, BlockCache](opts.BlocksCache),
+		receipts:                   newReceiptIndex(),
 		txs:                        ds.NewLRU[common.Hash, *types.Transaction](opts.TxsCache),
 		announcedTxs:               ds.NewLRU[common.Hash, struct{}](opts.TxsCache),
+		nonces:                     ds.NewLRU[common.Address, uint64](opts.TxsCache),
 		knownTxsBloom:              opts.KnownTxsBloom,
 		knownBlocksMax:             opts.KnownBlocksMax,
 		oldest:                     oldest,
@@ -164,13 +205,28 @@ func NewConns(opts ConnsOptions) *Conns {
 		maxQueuedTxs:               opts.MaxQueuedTxs,
 		validators:                 opts.ValidatorSet,
 		cacheOnlyValidated:         opts.CacheOnlyValidatedBlocks,
+		snapResults:                make(map[string][]SnapResult),
+		closeCh:                    make(chan struct{}),
 		metrics:                    newMetrics(),
 	}
 
+	c.blocks.OnEvict(func(hash common.Hash, cache BlockCache) {
+		c.receipts.remove(hash, cache.Receipts)
+	})
+
 	for i := 0; i < opts.BroadcastWorkers; i++ {
 		go c.txBroadcastLoop()
 	}
 
+	if opts.Score.Interval > 0 {
+		c.scorer = newScorer(opts.Score)
+		go c.scoreLoop()
+	}
+
+	if opts.Producers.BlockPeriod > 0 {
+		c.producers = newProducerMonitor(opts.Producers)
+	}
+
 	return c
 }
 
@@ -408,9 +464,10 @@ func (c *Conns) EnqueueTxBroadcast(txs types.Transactions) {
 	c.txBroadcastCh <- txs
 }
 
-// Close stops the broadcast worker.
+// Close stops the broadcast workers and the scoring loop.
 func (c *Conns) Close() {
 	close(c.txBroadcastCh)
+	close(c.closeCh)
 }
 
 // BroadcastTxHashes enqueues transaction hashes to per-peer broadcast queues.
@@ -643,6 +700,20 @@ func (c *Conns) Blocks() *ds.LRU[common.Hash, BlockCache] {
 	return c.blocks
 }
 
+// GetReceipt returns the receipt of a transaction from the verified receipts
+// of cached blocks, along with the block it is in.
+func (c *Conns) GetReceipt(txHash common.Hash) (*types.Receipt, BlockCache, bool) {
+	loc, ok := c.receipts.get(txHash)
+	if !ok {
+		return nil, BlockCache{}, false
+	}
+	cache, ok := c.blocks.Peek(loc.block)
+	if !ok || loc.index >= len(cache.Receipts) {
+		return nil, BlockCache{}, false
+	}
+	return cache.Receipts[loc.index], cache, true
+}
+
 // OldestBlock returns the oldest block the sensor will fetch parents for.
 // This is set once at initialization to the head block and acts as a floor
 // to prevent the sensor from crawling backwards indefinitely.
@@ -732,3 +803,28 @@ func (c *Conns) GetPeerLatestBlock(peerID string) (common.Hash, uint64) {
 
 	return common.Hash{}, 0
 }
+
+// GetPeerSnapResults returns the latest snap probe results for a peer.
+// Returns nil if the peer has not been probed.
+func (c *Conns) GetPeerSnapResults(peerID string) []SnapResult {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.snapResults[peerID]
+}
+
+// setPeerSnapResults stores the latest snap probe results for a peer.
+func (c *Conns) setPeerSnapResults(peerID string, results []SnapResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.snapResults[peerID] = results
+}
+
+// removePeerSnapResults removes the snap probe results of a disconnected peer.
+func (c *Conns) removePeerSnapResults(peerID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.snapResults, peerID)
+}
@@ -41,6 +41,8 @@ const (
 	chBlockEventBatch  = 50000
 	chTxBatch          = 20000
 	chTxEventBatch     = 50000
+	chReceiptBatch     = 20000
+	chProducerBatch    = 1000
 	chPeerBatch        = 2000
 	// How often to restate that the backend is unreachable.
 	chUnavailableWarnInterval = 1 * time.Minute
@@ -82,8 +84,15 @@ type ClickHouse struct {
 	shouldWriteTransactions          bool
 	shouldWriteTransactionEvents     bool
 	shouldWriteFirstTransactionEvent bool
+	shouldWriteReceipts              bool
+	shouldWriteProducerEvents        bool
 	shouldWritePeers                 bool
 
+	// clockOffset is recorded on every event when the sensor's clock is
+	// synchronized. The clock_offset_ns columns are only written then, so
+	// deployments that don't synchronize clocks need no schema change.
+	clockOffset ClockOffset
+
 	blocks      *rowBatcher[chBlock]
 	blockBodies *rowBatcher[chBlockBody]
 	blockTD     *rowBatcher[chBlockTD]
@@ -91,6 +100,8 @@ type ClickHouse struct {
 	blockEvt    *rowBatcher[chBlockEvent]
 	txs         *rowBatcher[chTx]
 	txEvt       *rowBatcher[chTxEvent]
+	receipts    *rowBatcher[chReceipt]
+	producerEvt *rowBatcher[chProducerEvent]
 	peers       *rowBatcher[chPeerSnapshot]
 
 	// discarded approximates the rows dropped because the backend was never
@@ -123,7 +134,10 @@ type ClickHouseOptions struct {
 	ShouldWriteTransactions          bool
 	ShouldWriteTransactionEvents     bool
 	ShouldWriteFirstTransactionEvent bool
+	ShouldWriteReceipts              bool
+	ShouldWriteProducerEvents        bool
 	ShouldWritePeers                 bool
+	ClockOffset                      ClockOffset
 }
 
 // NewClickHouse connects to ClickHouse, verifies connectivity, and starts the
@@ -141,7 +155,10 @@ func NewClickHouse(ctx context.Context, opts ClickHouseOptions) Database {
 		shouldWriteTransactions:          opts.ShouldWriteTransactions,
 		shouldWriteTransactionEvents:     opts.ShouldWriteTransactionEvents,
 		shouldWriteFirstTransactionEvent: opts.ShouldWriteFirstTransactionEvent,
+		shouldWriteReceipts:              opts.ShouldWriteReceipts,
+		shouldWriteProducerEvents:        opts.ShouldWriteProducerEvents,
 		shouldWritePeers:                 opts.ShouldWritePeers,
+		clockOffset:                      opts.ClockOffset,
 	}
 
 	conn, err := connectClickHouse(ctx, opts.DSN)
@@ -265,20 +282,46 @@ func (c *ClickHouse) startBatchers(ctx context.Context) {
 		func(b driver.Batch, r chBlockTx) error {
 			return b.Append(r.blockHash, r.txIndex, r.txHash, r.seenDate)
 		})
-	c.blockEvt = newInsertBatcher(ctx, c, "block_events", chBlockEventBatch,
-		"INSERT INTO block_events (block_number, block_hash, sensor_id, node_id, source, seen_at, total_difficulty)",
-		func(b driver.Batch, r chBlockEvent) error {
-			return b.Append(r.blockNumber, r.blockHash, c.sensorID, r.nodeID, r.source, r.seenAt, r.totalDifficulty)
-		})
+	if c.clockOffset != nil {
+		c.blockEvt = newInsertBatcher(ctx, c, "block_events", chBlockEventBatch,
+			"INSERT INTO block_events (block_number, block_hash, sensor_id, node_id, source, seen_at, total_difficulty, clock_offset_ns)",
+			func(b driver.Batch, r chBlockEvent) error {
+				return b.Append(r.blockNumber, r.blockHash, c.sensorID, r.nodeID, r.source, r.seenAt, r.totalDifficulty, r.clockOffset)
+			})
+	} else {
+		c.blockEvt = newInsertBatcher(ctx, c, "block_events", chBlockEventBatch,
+			"INSERT INTO block_events (block_number, block_hash, sensor_id, node_id, source, seen_at, total_difficulty)",
+			func(b driver.Batch, r chBlockEvent) error {
+				return b.Append(r.blockNumber, r.blockHash, c.sensorID, r.nodeID, r.source, r.seenAt, r.totalDifficulty)
+			})
+	}
 	c.txs = newInsertBatcher(ctx, c, "transactions", chTxBatch,
 		"INSERT INTO transactions (hash, from_address, to_address, value, gas, gas_price, gas_fee_cap, gas_tip_cap, nonce, tx_type, chain_id, input_selector, input_size, access_list_size, blob_count, auth_list_size, seen_date)",
 		func(b driver.Batch, r chTx) error {
 			return b.Append(r.hash, r.from, r.to, r.value, r.gas, r.gasPrice, r.gasFeeCap, r.gasTipCap, r.nonce, r.txType, r.chainID, r.inputSelector, r.inputSize, r.accessListSize, r.blobCount, r.authListSize, r.seenDate)
 		})
-	c.txEvt = newInsertBatcher(ctx, c, "tx_events", chTxEventBatch,
-		"INSERT INTO tx_events (tx_hash, sensor_id, node_id, source, seen_at)",
-		func(b driver.Batch, r chTxEvent) error {
-			return b.Append(r.txHash, c.sensorID, r.nodeID, r.source, r.seenAt)
+	if c.clockOffset != nil {
+		c.txEvt = newInsertBatcher(ctx, c, "tx_events", chTxEventBatch,
+			"INSERT INTO tx_events (tx_hash, sensor_id, node_id, source, seen_at, clock_offset_ns)",
+			func(b driver.Batch, r chTxEvent) error {
+				return b.Append(r.txHash, c.sensorID, r.nodeID, r.source, r.seenAt, r.clockOffset)
+			})
+	} else {
+		c.txEvt = newInsertBatcher(ctx, c, "tx_events", chTxEventBatch,
+			"INSERT INTO tx_events (tx_hash, sensor_id, node_id, source, seen_at)",
+			func(b driver.Batch, r chTxEvent) error {
+				return b.Append(r.txHash, c.sensorID, r.nodeID, r.source, r.seenAt)
+			})
+	}
+	c.receipts = newInsertBatcher(ctx, c, "receipts", chReceiptBatch,
+		"INSERT INTO receipts (block_hash, tx_index, tx_hash, block_number, tx_type, status, cumulative_gas_used, gas_used, effective_gas_price, contract_address, log_count)",
+		func(b driver.Batch, r chReceipt) error {
+			return b.Append(r.blockHash, r.txIndex, r.txHash, r.blockNumber, r.txType, r.status, r.cumulativeGasUsed, r.gasUsed, r.effectiveGasPrice, r.contractAddress, r.logCount)
+		})
+	c.producerEvt = newInsertBatcher(ctx, c, "producer_events", chProducerBatch,
+		"INSERT INTO producer_events (sensor_id, type, producer, block_hash, block_number, other_hash, other_producer, delay_ms, slots, seen_at)",
+		func(b driver.Batch, r chProducerEvent) error {
+			return b.Append(c.sensorID, r.eventType, r.producer, r.blockHash, r.blockNumber, r.otherHash, r.otherProducer, r.delayMs, r.slots, r.seenAt)
 		})
 	c.peers = newInsertBatcher(ctx, c, "peers", chPeerBatch,
 		"INSERT INTO peers (sensor_id, node_id, name, url, caps, seen_at)",
@@ -386,6 +429,7 @@ type chBlockEvent struct {
 	source          string
 	seenAt          time.Time
 	totalDifficulty *big.Int
+	clockOffset     int64
 }
 
 type chTx struct {
@@ -409,10 +453,37 @@ type chTx struct {
 }
 
 type chTxEvent struct {
-	txHash string
-	nodeID string
-	source string
-	seenAt time.Time
+	txHash      string
+	nodeID      string
+	source      string
+	seenAt      time.Time
+	clockOffset int64
+}
+
+type chReceipt struct {
+	blockHash         string
+	txIndex           uint32
+	txHash            string
+	blockNumber       uint64
+	txType            uint8
+	status            uint8
+	cumulativeGasUsed uint64
+	gasUsed           uint64
+	effectiveGasPrice *big.Int
+	contractAddress   string
+	logCount          uint32
+}
+
+type chProducerEvent struct {
+	eventType     string
+	producer      string
+	blockHash     string
+	blockNumber   uint64
+	otherHash     string
+	otherProducer string
+	delayMs       int64
+	slots         uint64
+	seenAt        time.Time
 }
 
 type chPeerSnapshot struct {
@@ -466,6 +537,7 @@ func (c *ClickHouse) WriteBlock(ctx context.Context, peer *enode.Node, block *ty
 			nodeID:      peer.ID().String(),
 			source:      srcNewBlock,
 			seenAt:      tfs,
+			clockOffset: int64(c.clockOffset.get()),
 			// Copied like the blockTD row below: rows sit in the batcher up to a
 			// second, and this pointer is raw.TD, also held in the block cache for
 			// ~10 minutes and handed to BroadcastBlock. Nothing mutates it today; it
@@ -527,6 +599,7 @@ func (c *ClickHouse) WriteBlockHeaders(ctx context.Context, headers []*types.Hea
 				blockHash:       h.Hash().Hex(),
 				source:          source,
 				seenAt:          tfs,
+				clockOffset:     int64(c.clockOffset.get()),
 				totalDifficulty: big.NewInt(0),
 			})
 		}
@@ -552,6 +625,7 @@ func (c *ClickHouse) WriteBlockBody(ctx context.Context, body *eth.BlockBody, an
 			blockHash:       hash.Hex(),
 			source:          srcBody,
 			seenAt:          tfs,
+			clockOffset:     int64(c.clockOffset.get()),
 			totalDifficulty: big.NewInt(0),
 		})
 	}
@@ -603,6 +677,7 @@ func (c *ClickHouse) WriteBlockEvents(ctx context.Context, peer *enode.Node, ann
 			nodeID:          nodeID,
 			source:          srcHashAnnounce,
 			seenAt:          tfs,
+			clockOffset:     int64(c.clockOffset.get()),
 			totalDifficulty: big.NewInt(0),
 		})
 	}
@@ -624,10 +699,11 @@ func (c *ClickHouse) WriteTransactionEvents(ctx context.Context, peer *enode.Nod
 	nodeID := peer.ID().String()
 	for _, hash := range hashes {
 		c.txEvt.add(chTxEvent{
-			txHash: hash.Hex(),
-			nodeID: nodeID,
-			source: srcHashAnnounce,
-			seenAt: tfs,
+			txHash:      hash.Hex(),
+			nodeID:      nodeID,
+			source:      srcHashAnnounce,
+			seenAt:      tfs,
+			clockOffset: int64(c.clockOffset.get()),
 		})
 	}
 }
@@ -644,10 +720,11 @@ func (c *ClickHouse) WriteTransactions(ctx context.Context, peer *enode.Node, tx
 		nodeID := peer.ID().String()
 		for _, tx := range txs {
 			c.txEvt.add(chTxEvent{
-				txHash: tx.Hash().Hex(),
-				nodeID: nodeID,
-				source: srcFullTx,
-				seenAt: tfs,
+				txHash:      tx.Hash().Hex(),
+				nodeID:      nodeID,
+				source:      srcFullTx,
+				seenAt:      tfs,
+				clockOffset: int64(c.clockOffset.get()),
 			})
 		}
 	}
@@ -657,6 +734,37 @@ func (c *ClickHouse) WriteTransactions(ctx context.Context, peer *enode.Node, tx
 	c.writeTxs(txs, tfs)
 }
 
+// WriteReceipts writes one receipts row per transaction. Receipts are only
+// written once verified against the header's receipts root, so like block_txs
+// every column is a function of (block_hash, tx_index).
+func (c *ClickHouse) WriteReceipts(ctx context.Context, receipts types.Receipts, tfs time.Time) {
+	if c.conn == nil {
+		c.discarded.Add(uint64(len(receipts)))
+		return
+	}
+	if !c.shouldWriteReceipts {
+		return
+	}
+	for _, receipt := range receipts {
+		c.receipts.add(newChReceipt(receipt))
+	}
+}
+
+// WriteProducerEvents writes one producer_events row per event. The other hash
+// and producer are empty when the event type doesn't set them.
+func (c *ClickHouse) WriteProducerEvents(ctx context.Context, events []ProducerEvent, tfs time.Time) {
+	if c.conn == nil {
+		c.discarded.Add(uint64(len(events)))
+		return
+	}
+	if !c.shouldWriteProducerEvents {
+		return
+	}
+	for _, event := range events {
+		c.producerEvt.add(newChProducerEvent(event, tfs))
+	}
+}
+
 func (c *ClickHouse) WritePeers(ctx context.Context, peers []*p2p.Peer, tls time.Time) {
 	if c.conn == nil {
 		c.discarded.Add(uint64(len(peers)))
@@ -773,7 +881,9 @@ func (c *ClickHouse) ShouldWriteTransactionEvents() bool { return c.shouldWriteT
 func (c *ClickHouse) ShouldWriteFirstTransactionEvent() bool {
 	return c.shouldWriteFirstTransactionEvent
 }
-func (c *ClickHouse) ShouldWritePeers() bool { return c.shouldWritePeers }
+func (c *ClickHouse) ShouldWriteReceipts() bool       { return c.shouldWriteReceipts }
+func (c *ClickHouse) ShouldWriteProducerEvents() bool { return c.shouldWriteProducerEvents }
+func (c *ClickHouse) ShouldWritePeers() bool          { return c.shouldWritePeers }
 
 // --- helpers ---------------------------------------------------------------
 
@@ -884,47 +994,98 @@ func (c *ClickHouse) writeBlockTxs(hash common.Hash, txs []*types.Transaction, t
 func (c *ClickHouse) writeTxs(txs []*types.Transaction, tfs time.Time) {
 	seenDate := tfs.UTC().Truncate(24 * time.Hour)
 	for _, tx := range txs {
-		var from, to string
-		chainID := tx.ChainId()
-		if chainID == nil || chainID.Sign() <= 0 {
-			chainID = c.chainID
-		}
-		if addr, err := types.Sender(types.LatestSignerForChainID(chainID), tx); err == nil {
-			from = addressHex(addr)
-		}
-		if tx.To() != nil {
-			to = addressHex(*tx.To())
-		}
-		// Selector and size but not the calldata: enough for contract-interaction
-		// analysis at negligible cost.
-		var selector string
-		data := tx.Data()
-		if len(data) >= 4 {
-			selector = "0x" + hex.EncodeToString(data[:4])
-		}
-		txChainID := uint64(0)
-		if id := tx.ChainId(); id != nil && id.IsUint64() {
-			txChainID = id.Uint64()
-		}
-		c.txs.add(chTx{
-			hash:           tx.Hash().Hex(),
-			from:           from,
-			to:             to,
-			value:          new(big.Int).Set(tx.Value()),
-			gas:            tx.Gas(),
-			gasPrice:       new(big.Int).Set(tx.GasPrice()),
-			gasFeeCap:      new(big.Int).Set(tx.GasFeeCap()),
-			gasTipCap:      new(big.Int).Set(tx.GasTipCap()),
-			nonce:          tx.Nonce(),
-			txType:         tx.Type(),
-			chainID:        txChainID,
-			inputSelector:  selector,
-			inputSize:      uint32(len(data)),
-			accessListSize: uint16(len(tx.AccessList())),
-			blobCount:      uint8(len(tx.BlobHashes())),
-			authListSize:   uint8(len(tx.SetCodeAuthorizations())),
-			seenDate:       seenDate,
-		})
+		c.txs.add(newChTx(tx, c.chainID, seenDate))
+	}
+}
+
+// newChTx maps a transaction to a transactions-table row. The sender is
+// recovered with the transaction's own chain ID, falling back to chainID for
+// pre-EIP-155 transactions, and left empty when it can't be recovered.
+func newChTx(tx *types.Transaction, chainID *big.Int, seenDate time.Time) chTx {
+	var from, to string
+	signerChainID := tx.ChainId()
+	if signerChainID == nil || signerChainID.Sign() <= 0 {
+		signerChainID = chainID
+	}
+	if addr, err := types.Sender(types.LatestSignerForChainID(signerChainID), tx); err == nil {
+		from = addressHex(addr)
+	}
+	if tx.To() != nil {
+		to = addressHex(*tx.To())
+	}
+	// Selector and size but not the calldata: enough for contract-interaction
+	// analysis at negligible cost.
+	var selector string
+	data := tx.Data()
+	if len(data) >= 4 {
+		selector = "0x" + hex.EncodeToString(data[:4])
+	}
+	txChainID := uint64(0)
+	if id := tx.ChainId(); id != nil && id.IsUint64() {
+		txChainID = id.Uint64()
+	}
+	return chTx{
+		hash:           tx.Hash().Hex(),
+		from:           from,
+		to:             to,
+		value:          new(big.Int).Set(tx.Value()),
+		gas:            tx.Gas(),
+		gasPrice:       new(big.Int).Set(tx.GasPrice()),
+		gasFeeCap:      new(big.Int).Set(tx.GasFeeCap()),
+		gasTipCap:      new(big.Int).Set(tx.GasTipCap()),
+		nonce:          tx.Nonce(),
+		txType:         tx.Type(),
+		chainID:        txChainID,
+		inputSelector:  selector,
+		inputSize:      uint32(len(data)),
+		accessListSize: uint16(len(tx.AccessList())),
+		blobCount:      uint8(len(tx.BlobHashes())),
+		authListSize:   uint8(len(tx.SetCodeAuthorizations())),
+		seenDate:       seenDate,
+	}
+}
+
+// newChReceipt maps a derived receipt to a receipts-table row. The contract
+// address is empty unless the transaction created a contract.
+func newChReceipt(receipt *types.Receipt) chReceipt {
+	var contract string
+	if receipt.ContractAddress != (common.Address{}) {
+		contract = addressHex(receipt.ContractAddress)
+	}
+	return chReceipt{
+		blockHash:         receipt.BlockHash.Hex(),
+		txIndex:           uint32(receipt.TransactionIndex),
+		txHash:            receipt.TxHash.Hex(),
+		blockNumber:       receipt.BlockNumber.Uint64(),
+		txType:            receipt.Type,
+		status:            uint8(receipt.Status),
+		cumulativeGasUsed: receipt.CumulativeGasUsed,
+		gasUsed:           receipt.GasUsed,
+		effectiveGasPrice: copyBig(receipt.EffectiveGasPrice),
+		contractAddress:   contract,
+		logCount:          uint32(len(receipt.Logs)),
+	}
+}
+
+// newChProducerEvent maps a producer event to a producer_events-table row.
+func newChProducerEvent(event ProducerEvent, tfs time.Time) chProducerEvent {
+	var otherHash, otherProducer string
+	if event.OtherHash != (common.Hash{}) {
+		otherHash = event.OtherHash.Hex()
+	}
+	if event.OtherProducer != (common.Address{}) {
+		otherProducer = addressHex(event.OtherProducer)
+	}
+	return chProducerEvent{
+		eventType:     event.Type,
+		producer:      addressHex(event.Producer),
+		blockHash:     event.BlockHash.Hex(),
+		blockNumber:   event.BlockNumber,
+		otherHash:     otherHash,
+		otherProducer: otherProducer,
+		delayMs:       event.Delay.Milliseconds(),
+		slots:         event.Slots,
+		seenAt:        tfs,
 	}
 }
 
@@ -32,6 +32,55 @@ func Hashes(anns []BlockAnnouncement) []common.Hash {
 	return hashes
 }
 
+// ClockOffset returns how far the sensor's reference clocks are ahead of its
+// local clock, see p2p.ClockSync. Backends store it with every event so the
+// timestamps of sensors on different hosts can be corrected before they're
+// compared. A nil ClockOffset means the clock isn't synchronized.
+type ClockOffset func() time.Duration
+
+// get returns the current offset, or zero if the clock isn't synchronized.
+func (f ClockOffset) get() time.Duration {
+	if f == nil {
+		return 0
+	}
+	return f()
+}
+
+// Producer event types, see ProducerEvent.
+const (
+	// ProducerEventEquivocation is a producer signing two blocks at one height.
+	ProducerEventEquivocation = "equivocation"
+	// ProducerEventSidechain is a block at a height another producer already
+	// has a block at, such as a backup producer's block after a missed slot.
+	ProducerEventSidechain = "sidechain"
+	// ProducerEventLate is a block first seen long after its timestamp, which
+	// happens when a producer withholds it.
+	ProducerEventLate = "late"
+	// ProducerEventMissedSlot is a gap between a block and its parent of more
+	// than one block period.
+	ProducerEventMissedSlot = "missed_slot"
+)
+
+// ProducerEvent is misbehaviour of a Bor block producer detected from the
+// blocks the sensor sees. Which fields are set depends on Type.
+type ProducerEvent struct {
+	Type string `json:"type"`
+	// Producer is the signer of the block, or of the parent for a missed slot.
+	Producer    common.Address `json:"producer"`
+	BlockHash   common.Hash    `json:"blockHash"`
+	BlockNumber uint64         `json:"blockNumber"`
+	// OtherHash is the block already seen at the same height for equivocation
+	// and sidechain events, and the parent for missed slots.
+	OtherHash common.Hash `json:"otherHash,omitzero"`
+	// OtherProducer is the signer of the block at OtherHash for sidechain
+	// events, and of the block after the gap for missed slots.
+	OtherProducer common.Address `json:"otherProducer,omitzero"`
+	// Delay is how long after its timestamp a late block was first seen.
+	Delay time.Duration `json:"delay,omitempty"`
+	// Slots is the number of block periods missed.
+	Slots uint64 `json:"slots,omitempty"`
+}
+
 // Database represents a database solution to write block and transaction data
 // to. To use another database solution, just implement these methods and
 // update the sensor to use the new connection.
@@ -79,6 +128,15 @@ type Database interface {
 	// stream, or just the first-seen ones); the backend only appends.
 	WriteTransactionEvents(context.Context, *enode.Node, []common.Hash, time.Time)
 
+	// WriteReceipts writes the verified receipts of a block if
+	// ShouldWriteReceipts returns true. The receipts carry their block hash,
+	// number and transaction hashes.
+	WriteReceipts(context.Context, types.Receipts, time.Time)
+
+	// WriteProducerEvents writes detected producer misbehaviour if
+	// ShouldWriteProducerEvents returns true.
+	WriteProducerEvents(context.Context, []ProducerEvent, time.Time)
+
 	// WritePeers will write the connected peers to the database.
 	WritePeers(context.Context, []*p2p.Peer, time.Time)
 
@@ -93,6 +151,8 @@ type Database interface {
 	ShouldWriteTransactions() bool
 	ShouldWriteTransactionEvents() bool
 	ShouldWriteFirstTransactionEvent() bool
+	ShouldWriteReceipts() bool
+	ShouldWriteProducerEvents() bool
 	ShouldWritePeers() bool
 
 	// NodeList will return a list of enode URLs.
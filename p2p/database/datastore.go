@@ -22,8 +22,13 @@ const (
 	BlockEventsKind       = "block_events"
 	TransactionsKind      = "transactions"
 	TransactionEventsKind = "transaction_events"
+	ReceiptsKind          = "receipts"
+	ProducerEventsKind    = "producer_events"
 	PeersKind             = "peers"
 	MaxAttempts           = 5
+
+	// maxPutMulti is the most entities Datastore accepts in one PutMulti.
+	maxPutMulti = 500
 )
 
 // Datastore wraps the datastore client, stores the sensorID, and other
@@ -39,21 +44,26 @@ type Datastore struct {
 	shouldWriteTransactions          bool
 	shouldWriteTransactionEvents     bool
 	shouldWriteFirstTransactionEvent bool
+	shouldWriteReceipts              bool
+	shouldWriteProducerEvents        bool
 	shouldWritePeers                 bool
 	ttl                              time.Duration
+	clockOffset                      ClockOffset
 	jobs                             chan struct{}
 }
 
 // DatastoreEvent can represent a peer sending the sensor a transaction hash or
 // a block hash. In this implementation, the block and transactions are written
 // to different tables by specifying a kind during key creation see writeEvents
-// for more.
+// for more. ClockOffset is the sensor's clock offset in nanoseconds, add it to
+// Time to compare events across sensors.
 type DatastoreEvent struct {
-	SensorId string
-	PeerId   string
-	Hash     *datastore.Key
-	Time     time.Time
-	TTL      time.Time
+	SensorId    string
+	PeerId      string
+	Hash        *datastore.Key
+	Time        time.Time
+	TTL         time.Time
+	ClockOffset int64 `datastore:",noindex"`
 }
 
 // DatastoreHeader stores the data in manner that can be easily written without
@@ -112,6 +122,40 @@ type DatastoreTransaction struct {
 	SensorFirstSeen string
 }
 
+// DatastoreReceipt represents a transaction receipt stored in datastore, keyed
+// by the transaction hash.
+type DatastoreReceipt struct {
+	Block             *datastore.Key
+	BlockNumber       string
+	TransactionIndex  int64 `datastore:",noindex"`
+	Status            int64
+	CumulativeGasUsed string `datastore:",noindex"`
+	GasUsed           string `datastore:",noindex"`
+	EffectiveGasPrice string `datastore:",noindex"`
+	ContractAddress   string
+	LogCount          int64 `datastore:",noindex"`
+	TimeFirstSeen     time.Time
+	TTL               time.Time
+	SensorFirstSeen   string
+}
+
+// DatastoreProducerEvent represents producer misbehaviour detected by a
+// sensor. Each sensor writes its own events, so they're keyed like the other
+// events.
+type DatastoreProducerEvent struct {
+	Type          string
+	Producer      string
+	Block         *datastore.Key
+	BlockNumber   string
+	Other         *datastore.Key
+	OtherProducer string
+	DelayMs       int64 `datastore:",noindex"`
+	Slots         int64 `datastore:",noindex"`
+	SensorId      string
+	Time          time.Time
+	TTL           time.Time
+}
+
 type DatastorePeer struct {
 	Name         string
 	Caps         []string `datastore:",noindex"`
@@ -134,8 +178,11 @@ type DatastoreOptions struct {
 	ShouldWriteTransactions          bool
 	ShouldWriteTransactionEvents     bool
 	ShouldWriteFirstTransactionEvent bool
+	ShouldWriteReceipts              bool
+	ShouldWriteProducerEvents        bool
 	ShouldWritePeers                 bool
 	TTL                              time.Duration
+	ClockOffset                      ClockOffset
 }
 
 // NewDatastore connects to datastore and creates the client. This should
@@ -157,9 +204,12 @@ func NewDatastore(ctx context.Context, opts DatastoreOptions) Database {
 		shouldWriteTransactions:          opts.ShouldWriteTransactions,
 		shouldWriteTransactionEvents:     opts.ShouldWriteTransactionEvents,
 		shouldWriteFirstTransactionEvent: opts.ShouldWriteFirstTransactionEvent,
+		shouldWriteReceipts:              opts.ShouldWriteReceipts,
+		shouldWriteProducerEvents:        opts.ShouldWriteProducerEvents,
 		shouldWritePeers:                 opts.ShouldWritePeers,
 		jobs:                             make(chan struct{}, opts.MaxConcurrency),
 		ttl:                              opts.TTL,
+		clockOffset:                      opts.ClockOffset,
 	}
 }
 
@@ -323,6 +373,93 @@ func (d *Datastore) WriteTransactionEvents(ctx context.Context, peer *enode.Node
 	})
 }
 
+// WriteReceipts writes a receipt entity per transaction. Receipts are verified
+// against the block's receipts root before they're written, so every sensor
+// writes the same values and they're written without a transaction.
+func (d *Datastore) WriteReceipts(ctx context.Context, receipts types.Receipts, tfs time.Time) {
+	if d.client == nil || !d.ShouldWriteReceipts() || len(receipts) == 0 {
+		return
+	}
+
+	d.runAsync(func() {
+		keys := make([]*datastore.Key, 0, len(receipts))
+		dsReceipts := make([]*DatastoreReceipt, 0, len(receipts))
+
+		for _, receipt := range receipts {
+			var contract string
+			if receipt.ContractAddress != (common.Address{}) {
+				contract = receipt.ContractAddress.Hex()
+			}
+
+			keys = append(keys, datastore.NameKey(ReceiptsKind, receipt.TxHash.Hex(), nil))
+			dsReceipts = append(dsReceipts, &DatastoreReceipt{
+				Block:             datastore.NameKey(BlocksKind, receipt.BlockHash.Hex(), nil),
+				BlockNumber:       receipt.BlockNumber.String(),
+				TransactionIndex:  int64(receipt.TransactionIndex),
+				Status:            int64(receipt.Status),
+				CumulativeGasUsed: fmt.Sprint(receipt.CumulativeGasUsed),
+				GasUsed:           fmt.Sprint(receipt.GasUsed),
+				EffectiveGasPrice: receipt.EffectiveGasPrice.String(),
+				ContractAddress:   contract,
+				LogCount:          int64(len(receipt.Logs)),
+				TimeFirstSeen:     tfs,
+				TTL:               tfs.Add(d.ttl),
+				SensorFirstSeen:   d.sensorID,
+			})
+		}
+
+		for start := 0; start < len(keys); start += maxPutMulti {
+			end := min(start+maxPutMulti, len(keys))
+			if _, err := d.client.PutMulti(ctx, keys[start:end], dsReceipts[start:end]); err != nil {
+				log.Error().Err(err).Str("hash", receipts[0].BlockHash.Hex()).Msg("Failed to write receipts")
+			}
+		}
+	})
+}
+
+// WriteProducerEvents writes a producer event entity per event.
+func (d *Datastore) WriteProducerEvents(ctx context.Context, events []ProducerEvent, tfs time.Time) {
+	if d.client == nil || !d.ShouldWriteProducerEvents() || len(events) == 0 {
+		return
+	}
+
+	d.runAsync(func() {
+		keys := make([]*datastore.Key, 0, len(events))
+		dsEvents := make([]*DatastoreProducerEvent, 0, len(events))
+
+		for _, event := range events {
+			var other *datastore.Key
+			if event.OtherHash != (common.Hash{}) {
+				other = datastore.NameKey(BlocksKind, event.OtherHash.Hex(), nil)
+			}
+
+			var otherProducer string
+			if event.OtherProducer != (common.Address{}) {
+				otherProducer = event.OtherProducer.Hex()
+			}
+
+			keys = append(keys, datastore.IncompleteKey(ProducerEventsKind, nil))
+			dsEvents = append(dsEvents, &DatastoreProducerEvent{
+				Type:          event.Type,
+				Producer:      event.Producer.Hex(),
+				Block:         datastore.NameKey(BlocksKind, event.BlockHash.Hex(), nil),
+				BlockNumber:   fmt.Sprint(event.BlockNumber),
+				Other:         other,
+				OtherProducer: otherProducer,
+				DelayMs:       event.Delay.Milliseconds(),
+				Slots:         int64(event.Slots),
+				SensorId:      d.sensorID,
+				Time:          tfs,
+				TTL:           tfs.Add(d.ttl),
+			})
+		}
+
+		if _, err := d.client.PutMulti(ctx, keys, dsEvents); err != nil {
+			log.Error().Err(err).Msg("Failed to write producer events")
+		}
+	})
+}
+
 // WritePeers writes the connected peers to datastore.
 func (d *Datastore) WritePeers(ctx context.Context, peers []*p2p.Peer, tls time.Time) {
 	if d.client == nil || !d.ShouldWritePeers() {
@@ -372,6 +509,14 @@ func (d *Datastore) ShouldWriteTransactionEvents() bool {
 	return d.shouldWriteTransactionEvents
 }
 
+func (d *Datastore) ShouldWriteReceipts() bool {
+	return d.shouldWriteReceipts
+}
+
+func (d *Datastore) ShouldWriteProducerEvents() bool {
+	return d.shouldWriteProducerEvents
+}
+
 func (d *Datastore) ShouldWritePeers() bool {
 	return d.shouldWritePeers
 }
@@ -532,11 +677,12 @@ func (d *Datastore) writeEvent(peer *enode.Node, eventKind string, hash common.H
 	key := datastore.IncompleteKey(eventKind, nil)
 
 	event := DatastoreEvent{
-		SensorId: d.sensorID,
-		PeerId:   peer.URLv4(),
-		Hash:     datastore.NameKey(hashKind, hash.Hex(), nil),
-		Time:     tfs,
-		TTL:      tfs.Add(d.ttl),
+		SensorId:    d.sensorID,
+		PeerId:      peer.URLv4(),
+		Hash:        datastore.NameKey(hashKind, hash.Hex(), nil),
+		Time:        tfs,
+		TTL:         tfs.Add(d.ttl),
+		ClockOffset: int64(d.clockOffset.get()),
 	}
 	if _, err := d.client.Put(context.Background(), key, &event); err != nil {
 		log.Error().Err(err).Msgf("Failed to write to %v", eventKind)
@@ -554,11 +700,12 @@ func (d *Datastore) writeEvents(ctx context.Context, peer *enode.Node, eventKind
 		keys = append(keys, datastore.IncompleteKey(eventKind, nil))
 
 		event := DatastoreEvent{
-			SensorId: d.sensorID,
-			PeerId:   peer.URLv4(),
-			Hash:     datastore.NameKey(hashKind, hash.Hex(), nil),
-			Time:     tfs,
-			TTL:      tfs.Add(d.ttl),
+			SensorId:    d.sensorID,
+			PeerId:      peer.URLv4(),
+			Hash:        datastore.NameKey(hashKind, hash.Hex(), nil),
+			Time:        tfs,
+			TTL:         tfs.Add(d.ttl),
+			ClockOffset: int64(d.clockOffset.get()),
 		}
 		events = append(events, &event)
 	}
@@ -25,7 +25,10 @@ type JSONDatabase struct {
 	shouldWriteBlockEvents       bool
 	shouldWriteTransactions      bool
 	shouldWriteTransactionEvents bool
+	shouldWriteReceipts          bool
+	shouldWriteProducerEvents    bool
 	shouldWritePeers             bool
+	clockOffset                  ClockOffset
 }
 
 // JSONDatabaseOptions is used when creating a NewJSONDatabase.
@@ -37,7 +40,10 @@ type JSONDatabaseOptions struct {
 	ShouldWriteBlockEvents       bool
 	ShouldWriteTransactions      bool
 	ShouldWriteTransactionEvents bool
+	ShouldWriteReceipts          bool
+	ShouldWriteProducerEvents    bool
 	ShouldWritePeers             bool
+	ClockOffset                  ClockOffset
 }
 
 // NewJSONDatabase creates a new JSONDatabase instance.
@@ -49,7 +55,10 @@ func NewJSONDatabase(opts JSONDatabaseOptions) Database {
 		shouldWriteBlockEvents:       opts.ShouldWriteBlockEvents,
 		shouldWriteTransactions:      opts.ShouldWriteTransactions,
 		shouldWriteTransactionEvents: opts.ShouldWriteTransactionEvents,
+		shouldWriteReceipts:          opts.ShouldWriteReceipts,
+		shouldWriteProducerEvents:    opts.ShouldWriteProducerEvents,
 		shouldWritePeers:             opts.ShouldWritePeers,
+		clockOffset:                  opts.ClockOffset,
 	}
 }
 
@@ -68,18 +77,22 @@ type JSONBlock struct {
 	BaseFee         string    `json:"base_fee,omitempty"`
 	TxCount         int       `json:"tx_count"`
 	UncleCount      int       `json:"uncle_count"`
+	Transactions    []string  `json:"transactions,omitempty"`
 	TimeFirstSeen   time.Time `json:"time_first_seen"`
 	IsParent        bool      `json:"is_parent"`
 }
 
-// JSONBlockEvent represents a block event in JSON format.
+// JSONBlockEvent represents a block event in JSON format. ClockOffset is the
+// sensor's clock offset in nanoseconds, add it to Timestamp to compare events
+// across sensors.
 type JSONBlockEvent struct {
-	Type      string    `json:"type"`
-	SensorID  string    `json:"sensor_id"`
-	PeerID    string    `json:"peer_id"`
-	Hash      string    `json:"hash"`
-	Number    uint64    `json:"number,omitempty"`
-	Timestamp time.Time `json:"timestamp"`
+	Type        string    `json:"type"`
+	SensorID    string    `json:"sensor_id"`
+	PeerID      string    `json:"peer_id"`
+	Hash        string    `json:"hash"`
+	Number      uint64    `json:"number,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+	ClockOffset int64     `json:"clock_offset,omitempty"`
 }
 
 // JSONBlockHashFirstSeen represents a block hash announcement in JSON format.
@@ -109,11 +122,45 @@ type JSONTransaction struct {
 
 // JSONTransactionEvent represents a transaction event in JSON format.
 type JSONTransactionEvent struct {
-	Type      string    `json:"type"`
-	SensorID  string    `json:"sensor_id"`
-	PeerID    string    `json:"peer_id"`
-	Hash      string    `json:"hash"`
-	Timestamp time.Time `json:"timestamp"`
+	Type        string    `json:"type"`
+	SensorID    string    `json:"sensor_id"`
+	PeerID      string    `json:"peer_id"`
+	Hash        string    `json:"hash"`
+	Timestamp   time.Time `json:"timestamp"`
+	ClockOffset int64     `json:"clock_offset,omitempty"`
+}
+
+// JSONReceipt represents a transaction receipt in JSON format.
+type JSONReceipt struct {
+	Type              string    `json:"type"`
+	SensorID          string    `json:"sensor_id"`
+	BlockHash         string    `json:"block_hash"`
+	BlockNumber       uint64    `json:"block_number"`
+	TxHash            string    `json:"tx_hash"`
+	TxIndex           uint      `json:"tx_index"`
+	TxType            uint8     `json:"tx_type"`
+	Status            uint64    `json:"status"`
+	CumulativeGasUsed uint64    `json:"cumulative_gas_used"`
+	GasUsed           uint64    `json:"gas_used"`
+	EffectiveGasPrice string    `json:"effective_gas_price,omitempty"`
+	ContractAddress   string    `json:"contract_address,omitempty"`
+	LogCount          int       `json:"log_count"`
+	TimeFirstSeen     time.Time `json:"time_first_seen"`
+}
+
+// JSONProducerEvent represents a producer event in JSON format.
+type JSONProducerEvent struct {
+	Type          string    `json:"type"`
+	SensorID      string    `json:"sensor_id"`
+	EventType     string    `json:"event_type"`
+	Producer      string    `json:"producer"`
+	BlockHash     string    `json:"block_hash"`
+	BlockNumber   uint64    `json:"block_number"`
+	OtherHash     string    `json:"other_hash,omitempty"`
+	OtherProducer string    `json:"other_producer,omitempty"`
+	DelayMs       int64     `json:"delay_ms,omitempty"`
+	Slots         uint64    `json:"slots,omitempty"`
+	Timestamp     time.Time `json:"timestamp"`
 }
 
 // JSONPeer represents a peer in JSON format.
@@ -150,11 +197,12 @@ func (j *JSONDatabase) writeBlockEvent(peer *enode.Node, block *types.Block, tfs
 	}
 
 	event := JSONBlockEvent{
-		Type:      "block_event",
-		SensorID:  j.sensorID,
-		PeerID:    peer.URLv4(),
-		Hash:      block.Hash().Hex(),
-		Timestamp: tfs,
+		Type:        "block_event",
+		SensorID:    j.sensorID,
+		PeerID:      peer.URLv4(),
+		Hash:        block.Hash().Hex(),
+		Timestamp:   tfs,
+		ClockOffset: int64(j.clockOffset.get()),
 	}
 
 	j.Write(event)
@@ -178,6 +226,7 @@ func (j *JSONDatabase) writeBlock(block *types.Block, td *big.Int, tfs time.Time
 		TotalDifficulty: td.String(),
 		TxCount:         len(block.Transactions()),
 		UncleCount:      len(block.Uncles()),
+		Transactions:    txHashes(block.Transactions()),
 		TimeFirstSeen:   tfs,
 	}
 
@@ -226,12 +275,13 @@ func (j *JSONDatabase) WriteBlockEvents(ctx context.Context, peer *enode.Node, a
 
 	for _, ann := range anns {
 		event := JSONBlockEvent{
-			Type:      "block_hash",
-			SensorID:  j.sensorID,
-			PeerID:    peer.URLv4(),
-			Hash:      ann.Hash.Hex(),
-			Number:    ann.Number,
-			Timestamp: tfs,
+			Type:        "block_hash",
+			SensorID:    j.sensorID,
+			PeerID:      peer.URLv4(),
+			Hash:        ann.Hash.Hex(),
+			Number:      ann.Number,
+			Timestamp:   tfs,
+			ClockOffset: int64(j.clockOffset.get()),
 		}
 
 		j.Write(event)
@@ -269,6 +319,15 @@ func (j *JSONDatabase) WriteBlockBody(ctx context.Context, body *eth.BlockBody,
 		"time_first_seen": tfs,
 	}
 
+	if body.Transactions.Len() > 0 {
+		txs, err := body.Transactions.Items()
+		if err != nil {
+			log.Error().Err(err).Str("hash", hash.Hex()).Msg("Failed to decode transactions from block body")
+		} else {
+			jsonBody["transactions"] = txHashes(txs)
+		}
+	}
+
 	j.Write(jsonBody)
 }
 
@@ -324,17 +383,83 @@ func (j *JSONDatabase) WriteTransactionEvents(ctx context.Context, peer *enode.N
 
 	for _, hash := range hashes {
 		event := JSONTransactionEvent{
-			Type:      "transaction_event",
-			SensorID:  j.sensorID,
-			PeerID:    peer.URLv4(),
-			Hash:      hash.Hex(),
-			Timestamp: tfs,
+			Type:        "transaction_event",
+			SensorID:    j.sensorID,
+			PeerID:      peer.URLv4(),
+			Hash:        hash.Hex(),
+			Timestamp:   tfs,
+			ClockOffset: int64(j.clockOffset.get()),
 		}
 
 		j.Write(event)
 	}
 }
 
+// WriteReceipts writes the block's receipts as JSON.
+func (j *JSONDatabase) WriteReceipts(ctx context.Context, receipts types.Receipts, tfs time.Time) {
+	if !j.ShouldWriteReceipts() {
+		return
+	}
+
+	for _, receipt := range receipts {
+		jsonReceipt := JSONReceipt{
+			Type:              "receipt",
+			SensorID:          j.sensorID,
+			BlockHash:         receipt.BlockHash.Hex(),
+			BlockNumber:       receipt.BlockNumber.Uint64(),
+			TxHash:            receipt.TxHash.Hex(),
+			TxIndex:           receipt.TransactionIndex,
+			TxType:            receipt.Type,
+			Status:            receipt.Status,
+			CumulativeGasUsed: receipt.CumulativeGasUsed,
+			GasUsed:           receipt.GasUsed,
+			LogCount:          len(receipt.Logs),
+			TimeFirstSeen:     tfs,
+		}
+
+		if receipt.EffectiveGasPrice != nil {
+			jsonReceipt.EffectiveGasPrice = receipt.EffectiveGasPrice.String()
+		}
+
+		if receipt.ContractAddress != (common.Address{}) {
+			jsonReceipt.ContractAddress = receipt.ContractAddress.Hex()
+		}
+
+		j.Write(jsonReceipt)
+	}
+}
+
+// WriteProducerEvents writes the producer events as JSON.
+func (j *JSONDatabase) WriteProducerEvents(ctx context.Context, events []ProducerEvent, tfs time.Time) {
+	if !j.ShouldWriteProducerEvents() {
+		return
+	}
+
+	for _, event := range events {
+		jsonEvent := JSONProducerEvent{
+			Type:        "producer_event",
+			SensorID:    j.sensorID,
+			EventType:   event.Type,
+			Producer:    event.Producer.Hex(),
+			BlockHash:   event.BlockHash.Hex(),
+			BlockNumber: event.BlockNumber,
+			DelayMs:     event.Delay.Milliseconds(),
+			Slots:       event.Slots,
+			Timestamp:   tfs,
+		}
+
+		if event.OtherHash != (common.Hash{}) {
+			jsonEvent.OtherHash = event.OtherHash.Hex()
+		}
+
+		if event.OtherProducer != (common.Address{}) {
+			jsonEvent.OtherProducer = event.OtherProducer.Hex()
+		}
+
+		j.Write(jsonEvent)
+	}
+}
+
 // WritePeers writes the connected peers as JSON.
 func (j *JSONDatabase) WritePeers(ctx context.Context, peers []*p2p.Peer, tls time.Time) {
 	if !j.ShouldWritePeers() {
@@ -396,6 +521,16 @@ func (j *JSONDatabase) ShouldWriteTransactionEvents() bool {
 	return j.shouldWriteTransactionEvents
 }
 
+// ShouldWriteReceipts returns the configured value.
+func (j *JSONDatabase) ShouldWriteReceipts() bool {
+	return j.shouldWriteReceipts
+}
+
+// ShouldWriteProducerEvents returns the configured value.
+func (j *JSONDatabase) ShouldWriteProducerEvents() bool {
+	return j.shouldWriteProducerEvents
+}
+
 // ShouldWritePeers returns the configured value.
 func (j *JSONDatabase) ShouldWritePeers() bool {
 	return j.shouldWritePeers
@@ -410,3 +545,17 @@ func (j *JSONDatabase) NodeList(ctx context.Context, limit int) ([]string, error
 func (j *JSONDatabase) Close() error {
 	return nil
 }
+
+// txHashes returns the hex hashes of the transactions, so block records can be
+// joined with transaction events.
+func txHashes(txs []*types.Transaction) []string {
+	if len(txs) == 0 {
+		return nil
+	}
+
+	hashes := make([]string, 0, len(txs))
+	for _, tx := range txs {
+		hashes = append(hashes, tx.Hash().Hex())
+	}
+	return hashes
+}
@@ -49,6 +49,14 @@ func (n *nodb) WriteTransactions(ctx context.Context, peer *enode.Node, txs []*t
 func (n *nodb) WriteTransactionEvents(ctx context.Context, peer *enode.Node, hashes []common.Hash, tfs time.Time) {
 }
 
+// WriteReceipts does nothing.
+func (n *nodb) WriteReceipts(ctx context.Context, receipts types.Receipts, tfs time.Time) {
+}
+
+// WriteProducerEvents does nothing.
+func (n *nodb) WriteProducerEvents(ctx context.Context, events []ProducerEvent, tfs time.Time) {
+}
+
 // WritePeers does nothing.
 func (n *nodb) WritePeers(ctx context.Context, peers []*p2p.Peer, tls time.Time) {
 }
@@ -93,6 +101,16 @@ func (n *nodb) ShouldWriteFirstTransactionEvent() bool {
 	return false
 }
 
+// ShouldWriteReceipts returns false.
+func (n *nodb) ShouldWriteReceipts() bool {
+	return false
+}
+
+// ShouldWriteProducerEvents returns false.
+func (n *nodb) ShouldWriteProducerEvents() bool {
+	return false
+}
+
 // ShouldWritePeers returns false.
 func (n *nodb) ShouldWritePeers() bool {
 	return false
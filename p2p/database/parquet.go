@@ -0,0 +1,676 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/parquet-go/parquet-go"
+	"github.com/rs/zerolog/log"
+)
+
+// Parquet table directories. The names and columns match the ClickHouse tables
+// of the same name, so queries can move between the two.
+const (
+	pqBlocksTable      = "blocks"
+	pqBlockEventsTable = "block_events"
+	pqTxsTable         = "transactions"
+	pqTxEventsTable    = "tx_events"
+	pqReceiptsTable    = "receipts"
+	pqProducerTable    = "producer_events"
+	pqPeersTable       = "peers"
+)
+
+// DefaultParquetRotateInterval is how long a Parquet file is written to before
+// it is closed and a new one started.
+const DefaultParquetRotateInterval = time.Hour
+
+// parquetRowGroupRows is the number of rows buffered in memory before they are
+// written out as a row group, so a long rotation interval doesn't hold every
+// row of the file in memory.
+const parquetRowGroupRows = 10_000
+
+// Parquet implements the Database interface by writing rolling Parquet files,
+// one directory per table, for offline analysis in tools like DuckDB or pandas.
+// The rows are derived exactly as the ClickHouse backend derives them, so the
+// write flags gate the same tables and the files hold the same data.
+//
+// A Parquet file is only readable once its footer is written, so rows are
+// buffered into a hidden temporary file that is renamed into place when it is
+// rotated or the database is closed. Globbing *.parquet therefore only ever
+// picks up complete files.
+type Parquet struct {
+	sensorID                         string
+	chainID                          *big.Int
+	maxConcurrency                   int
+	shouldWriteBlocks                bool
+	shouldWriteBlockEvents           bool
+	shouldWriteFirstBlockEvent       bool
+	shouldWriteTransactions          bool
+	shouldWriteTransactionEvents     bool
+	shouldWriteFirstTransactionEvent bool
+	shouldWriteReceipts              bool
+	shouldWriteProducerEvents        bool
+	shouldWritePeers                 bool
+	clockOffset                      ClockOffset
+
+	blocks      *parquetTable[pqBlock]
+	blockEvents *parquetTable[pqBlockEvent]
+	txs         *parquetTable[pqTx]
+	txEvents    *parquetTable[pqTxEvent]
+	receipts    *parquetTable[pqReceipt]
+	producerEvt *parquetTable[pqProducerEvent]
+	peers       *parquetTable[pqPeer]
+
+	// cancel stops the rotation goroutine; wg tracks it so Close doesn't race
+	// a rotation in progress.
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// ParquetOptions is used when creating a NewParquet.
+type ParquetOptions struct {
+	Dir                              string
+	RotateInterval                   time.Duration
+	SensorID                         string
+	ChainID                          uint64
+	MaxConcurrency                   int
+	ShouldWriteBlocks                bool
+	ShouldWriteBlockEvents           bool
+	ShouldWriteFirstBlockEvent       bool
+	ShouldWriteTransactions          bool
+	ShouldWriteTransactionEvents     bool
+	ShouldWriteFirstTransactionEvent bool
+	ShouldWriteReceipts              bool
+	ShouldWriteProducerEvents        bool
+	ShouldWritePeers                 bool
+	ClockOffset                      ClockOffset
+}
+
+// pqBlock is a blocks row, see chBlock. Big integers are decimal strings, since
+// Parquet has no 256-bit integer type; cast them with ::HUGEINT in DuckDB.
+type pqBlock struct {
+	Number      uint64    `parquet:"number"`
+	Hash        string    `parquet:"hash"`
+	ParentHash  string    `parquet:"parent_hash"`
+	BlockTime   time.Time `parquet:"block_time,timestamp(millisecond)"`
+	Signer      string    `parquet:"signer,dict"`
+	Coinbase    string    `parquet:"coinbase,dict"`
+	Difficulty  uint64    `parquet:"difficulty"`
+	GasUsed     uint64    `parquet:"gas_used"`
+	GasLimit    uint64    `parquet:"gas_limit"`
+	BaseFee     string    `parquet:"base_fee"`
+	UncleHash   string    `parquet:"uncle_hash,dict"`
+	StateRoot   string    `parquet:"state_root"`
+	TxRoot      string    `parquet:"tx_root"`
+	ReceiptRoot string    `parquet:"receipt_root"`
+	LogsBloom   []byte    `parquet:"logs_bloom"`
+	ExtraData   []byte    `parquet:"extra_data"`
+	MixDigest   string    `parquet:"mix_digest,dict"`
+	Nonce       uint64    `parquet:"nonce"`
+}
+
+// pqBlockEvent is a block_events row, see chBlockEvent. ClockOffsetNS is null
+// when the sensor's clock isn't synchronized, where ClickHouse omits the column.
+type pqBlockEvent struct {
+	BlockNumber     uint64    `parquet:"block_number"`
+	BlockHash       string    `parquet:"block_hash"`
+	SensorID        string    `parquet:"sensor_id,dict"`
+	NodeID          string    `parquet:"node_id,dict"`
+	Source          string    `parquet:"source,dict"`
+	SeenAt          time.Time `parquet:"seen_at,timestamp(microsecond)"`
+	TotalDifficulty string    `parquet:"total_difficulty"`
+	ClockOffsetNS   *int64    `parquet:"clock_offset_ns,optional"`
+}
+
+// pqTx is a transactions row, see chTx.
+type pqTx struct {
+	Hash           string    `parquet:"hash"`
+	FromAddress    string    `parquet:"from_address"`
+	ToAddress      string    `parquet:"to_address"`
+	Value          string    `parquet:"value"`
+	Gas            uint64    `parquet:"gas"`
+	GasPrice       string    `parquet:"gas_price"`
+	GasFeeCap      string    `parquet:"gas_fee_cap"`
+	GasTipCap      string    `parquet:"gas_tip_cap"`
+	Nonce          uint64    `parquet:"nonce"`
+	TxType         uint8     `parquet:"tx_type"`
+	ChainID        uint64    `parquet:"chain_id"`
+	InputSelector  string    `parquet:"input_selector,dict"`
+	InputSize      uint32    `parquet:"input_size"`
+	AccessListSize uint16    `parquet:"access_list_size"`
+	BlobCount      uint8     `parquet:"blob_count"`
+	AuthListSize   uint8     `parquet:"auth_list_size"`
+	SeenDate       time.Time `parquet:"seen_date,date"`
+}
+
+// pqTxEvent is a tx_events row, see chTxEvent.
+type pqTxEvent struct {
+	TxHash        string    `parquet:"tx_hash"`
+	SensorID      string    `parquet:"sensor_id,dict"`
+	NodeID        string    `parquet:"node_id,dict"`
+	Source        string    `parquet:"source,dict"`
+	SeenAt        time.Time `parquet:"seen_at,timestamp(microsecond)"`
+	ClockOffsetNS *int64    `parquet:"clock_offset_ns,optional"`
+}
+
+// pqReceipt is a receipts row, see chReceipt.
+type pqReceipt struct {
+	BlockHash         string `parquet:"block_hash"`
+	TxIndex           uint32 `parquet:"tx_index"`
+	TxHash            string `parquet:"tx_hash"`
+	BlockNumber       uint64 `parquet:"block_number"`
+	TxType            uint8  `parquet:"tx_type"`
+	Status            uint8  `parquet:"status"`
+	CumulativeGasUsed uint64 `parquet:"cumulative_gas_used"`
+	GasUsed           uint64 `parquet:"gas_used"`
+	EffectiveGasPrice string `parquet:"effective_gas_price"`
+	ContractAddress   string `parquet:"contract_address"`
+	LogCount          uint32 `parquet:"log_count"`
+}
+
+// pqProducerEvent is a producer_events row, see chProducerEvent.
+type pqProducerEvent struct {
+	SensorID      string    `parquet:"sensor_id,dict"`
+	Type          string    `parquet:"type,dict"`
+	Producer      string    `parquet:"producer,dict"`
+	BlockHash     string    `parquet:"block_hash"`
+	BlockNumber   uint64    `parquet:"block_number"`
+	OtherHash     string    `parquet:"other_hash"`
+	OtherProducer string    `parquet:"other_producer,dict"`
+	DelayMs       int64     `parquet:"delay_ms"`
+	Slots         uint64    `parquet:"slots"`
+	SeenAt        time.Time `parquet:"seen_at,timestamp(microsecond)"`
+}
+
+// pqPeer is a peers row, see chPeerSnapshot.
+type pqPeer struct {
+	SensorID string    `parquet:"sensor_id,dict"`
+	NodeID   string    `parquet:"node_id"`
+	Name     string    `parquet:"name,dict"`
+	URL      string    `parquet:"url"`
+	Caps     []string  `parquet:"caps,list"`
+	SeenAt   time.Time `parquet:"seen_at,timestamp(microsecond)"`
+}
+
+// NewParquet creates the table directories under opts.Dir and starts rotating
+// files every opts.RotateInterval. Like the Pebble backend an unusable local
+// directory is a configuration error, so it's returned rather than degrading to
+// no-op writes. Callers should defer Close so the open files are completed.
+func NewParquet(ctx context.Context, opts ParquetOptions) (*Parquet, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("parquet output directory is required")
+	}
+	if opts.RotateInterval <= 0 {
+		opts.RotateInterval = DefaultParquetRotateInterval
+	}
+
+	p := &Parquet{
+		sensorID:                         opts.SensorID,
+		chainID:                          new(big.Int).SetUint64(opts.ChainID),
+		maxConcurrency:                   opts.MaxConcurrency,
+		shouldWriteBlocks:                opts.ShouldWriteBlocks,
+		shouldWriteBlockEvents:           opts.ShouldWriteBlockEvents,
+		shouldWriteFirstBlockEvent:       opts.ShouldWriteFirstBlockEvent,
+		shouldWriteTransactions:          opts.ShouldWriteTransactions,
+		shouldWriteTransactionEvents:     opts.ShouldWriteTransactionEvents,
+		shouldWriteFirstTransactionEvent: opts.ShouldWriteFirstTransactionEvent,
+		shouldWriteReceipts:              opts.ShouldWriteReceipts,
+		shouldWriteProducerEvents:        opts.ShouldWriteProducerEvents,
+		shouldWritePeers:                 opts.ShouldWritePeers,
+		clockOffset:                      opts.ClockOffset,
+	}
+
+	var err error
+	if p.blocks, err = newParquetTable[pqBlock](opts.Dir, pqBlocksTable, opts.SensorID); err != nil {
+		return nil, err
+	}
+	if p.blockEvents, err = newParquetTable[pqBlockEvent](opts.Dir, pqBlockEventsTable, opts.SensorID); err != nil {
+		return nil, err
+	}
+	if p.txs, err = newParquetTable[pqTx](opts.Dir, pqTxsTable, opts.SensorID); err != nil {
+		return nil, err
+	}
+	if p.txEvents, err = newParquetTable[pqTxEvent](opts.Dir, pqTxEventsTable, opts.SensorID); err != nil {
+		return nil, err
+	}
+	if p.receipts, err = newParquetTable[pqReceipt](opts.Dir, pqReceiptsTable, opts.SensorID); err != nil {
+		return nil, err
+	}
+	if p.producerEvt, err = newParquetTable[pqProducerEvent](opts.Dir, pqProducerTable, opts.SensorID); err != nil {
+		return nil, err
+	}
+	if p.peers, err = newParquetTable[pqPeer](opts.Dir, pqPeersTable, opts.SensorID); err != nil {
+		return nil, err
+	}
+
+	// Detached from the caller's context for the same reason as the ClickHouse
+	// batchers: peers keep writing after the sensor's context is cancelled, and
+	// only Close may complete the files.
+	rctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	p.cancel = cancel
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.rotateLoop(rctx, opts.RotateInterval)
+	}()
+
+	return p, nil
+}
+
+// rotateLoop completes the open files on every tick, so each file holds the
+// rows of at most one interval and becomes readable when it ends.
+func (p *Parquet) rotateLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.rotate(); err != nil {
+				log.Error().Err(err).Msg("Failed to rotate Parquet files")
+			}
+		}
+	}
+}
+
+// rotate completes the open file of every table. The next write to a table
+// opens a new one, so idle tables don't produce empty files.
+func (p *Parquet) rotate() error {
+	return errors.Join(
+		p.blocks.rotate(),
+		p.blockEvents.rotate(),
+		p.txs.rotate(),
+		p.txEvents.rotate(),
+		p.receipts.rotate(),
+		p.producerEvt.rotate(),
+		p.peers.rotate(),
+	)
+}
+
+// Close stops rotation and completes the open files.
+func (p *Parquet) Close() error {
+	p.cancel()
+	p.wg.Wait()
+	return p.rotate()
+}
+
+// recordsBlockEvents reports whether block events other than hash
+// announcements should be written. See ClickHouse.recordsBlockEvents.
+func (p *Parquet) recordsBlockEvents() bool {
+	return p.shouldWriteBlockEvents || p.shouldWriteFirstBlockEvent
+}
+
+// recordsTxEvents is the transaction mirror of recordsBlockEvents.
+func (p *Parquet) recordsTxEvents() bool {
+	return p.shouldWriteTransactionEvents || p.shouldWriteFirstTransactionEvent
+}
+
+// clockOffsetNS returns the clock offset to record on an event, or nil when the
+// sensor's clock isn't synchronized.
+func (p *Parquet) clockOffsetNS() *int64 {
+	if p.clockOffset == nil {
+		return nil
+	}
+	offset := int64(p.clockOffset.get())
+	return &offset
+}
+
+// WriteBlock writes the block, its transactions and the new_block event.
+func (p *Parquet) WriteBlock(ctx context.Context, peer *enode.Node, block *types.Block, td *big.Int, tfs time.Time) {
+	if p.recordsBlockEvents() && peer != nil {
+		p.writeBlockEvent(block.NumberU64(), block.Hash(), peer, srcNewBlock, tfs, td)
+	}
+	if p.shouldWriteBlocks {
+		p.blocks.write(newPqBlock(block.Header()))
+	}
+	if p.shouldWriteTransactions {
+		p.writeTxs(block.Transactions(), tfs)
+	}
+}
+
+// WriteBlockHeaders writes the headers and their header or header_backfill
+// events.
+func (p *Parquet) WriteBlockHeaders(ctx context.Context, headers []*types.Header, tfs time.Time, isParent bool) {
+	source := srcHeader
+	if isParent {
+		source = srcHeaderBackfill
+	}
+	for _, h := range headers {
+		if p.shouldWriteBlocks {
+			p.blocks.write(newPqBlock(h))
+		}
+		if p.recordsBlockEvents() {
+			p.writeBlockEvent(h.Number.Uint64(), h.Hash(), nil, source, tfs, nil)
+		}
+	}
+}
+
+// WriteBlockBody writes the body event and the body's transactions.
+func (p *Parquet) WriteBlockBody(ctx context.Context, body *eth.BlockBody, ann BlockAnnouncement, tfs time.Time) {
+	if p.recordsBlockEvents() {
+		p.writeBlockEvent(ann.Number, ann.Hash, nil, srcBody, tfs, nil)
+	}
+	if !p.shouldWriteTransactions {
+		return
+	}
+	txs, err := body.Transactions.Items()
+	if err != nil {
+		log.Error().Err(err).Str("hash", ann.Hash.Hex()).Msg("Failed to decode transactions from block body")
+		return
+	}
+	p.writeTxs(txs, tfs)
+}
+
+// WriteBlockEvents writes a hash_announce event per announcement.
+func (p *Parquet) WriteBlockEvents(ctx context.Context, peer *enode.Node, anns []BlockAnnouncement, tfs time.Time) {
+	if peer == nil {
+		return
+	}
+	for _, ann := range anns {
+		p.writeBlockEvent(ann.Number, ann.Hash, peer, srcHashAnnounce, tfs, nil)
+	}
+}
+
+// WriteBlockHashFirstSeen is a no-op: as with ClickHouse, the earliest
+// first-seen time is derived from block_events.
+func (p *Parquet) WriteBlockHashFirstSeen(ctx context.Context, peer *enode.Node, hash common.Hash, tfsh time.Time) {
+}
+
+// WriteTransactions writes the full_tx events and the transactions.
+func (p *Parquet) WriteTransactions(ctx context.Context, peer *enode.Node, txs []*types.Transaction, tfs time.Time) {
+	if p.recordsTxEvents() && peer != nil {
+		hashes := make([]common.Hash, 0, len(txs))
+		for _, tx := range txs {
+			hashes = append(hashes, tx.Hash())
+		}
+		p.writeTxEvents(peer, hashes, srcFullTx, tfs)
+	}
+	if p.shouldWriteTransactions {
+		p.writeTxs(txs, tfs)
+	}
+}
+
+// WriteTransactionEvents writes a hash_announce event per transaction hash.
+func (p *Parquet) WriteTransactionEvents(ctx context.Context, peer *enode.Node, hashes []common.Hash, tfs time.Time) {
+	if peer == nil {
+		return
+	}
+	p.writeTxEvents(peer, hashes, srcHashAnnounce, tfs)
+}
+
+// WriteReceipts writes a row per verified receipt.
+func (p *Parquet) WriteReceipts(ctx context.Context, receipts types.Receipts, tfs time.Time) {
+	if !p.shouldWriteReceipts {
+		return
+	}
+	rows := make([]pqReceipt, 0, len(receipts))
+	for _, receipt := range receipts {
+		r := newChReceipt(receipt)
+		rows = append(rows, pqReceipt{
+			BlockHash:         r.blockHash,
+			TxIndex:           r.txIndex,
+			TxHash:            r.txHash,
+			BlockNumber:       r.blockNumber,
+			TxType:            r.txType,
+			Status:            r.status,
+			CumulativeGasUsed: r.cumulativeGasUsed,
+			GasUsed:           r.gasUsed,
+			EffectiveGasPrice: r.effectiveGasPrice.String(),
+			ContractAddress:   r.contractAddress,
+			LogCount:          r.logCount,
+		})
+	}
+	p.receipts.write(rows...)
+}
+
+// WriteProducerEvents writes a row per producer event.
+func (p *Parquet) WriteProducerEvents(ctx context.Context, events []ProducerEvent, tfs time.Time) {
+	if !p.shouldWriteProducerEvents {
+		return
+	}
+	rows := make([]pqProducerEvent, 0, len(events))
+	for _, event := range events {
+		r := newChProducerEvent(event, tfs)
+		rows = append(rows, pqProducerEvent{
+			SensorID:      p.sensorID,
+			Type:          r.eventType,
+			Producer:      r.producer,
+			BlockHash:     r.blockHash,
+			BlockNumber:   r.blockNumber,
+			OtherHash:     r.otherHash,
+			OtherProducer: r.otherProducer,
+			DelayMs:       r.delayMs,
+			Slots:         r.slots,
+			SeenAt:        r.seenAt,
+		})
+	}
+	p.producerEvt.write(rows...)
+}
+
+// WritePeers writes a snapshot row per connected peer.
+func (p *Parquet) WritePeers(ctx context.Context, peers []*p2p.Peer, tls time.Time) {
+	if !p.shouldWritePeers {
+		return
+	}
+	rows := make([]pqPeer, 0, len(peers))
+	for _, peer := range peers {
+		rows = append(rows, pqPeer{
+			SensorID: p.sensorID,
+			NodeID:   peer.ID().String(),
+			Name:     peer.Fullname(),
+			URL:      peer.Node().URLv4(),
+			Caps:     peer.Info().Caps,
+			SeenAt:   tls,
+		})
+	}
+	p.peers.write(rows...)
+}
+
+func (p *Parquet) writeBlockEvent(number uint64, hash common.Hash, peer *enode.Node, source string, tfs time.Time, td *big.Int) {
+	var nodeID string
+	if peer != nil {
+		nodeID = peer.ID().String()
+	}
+	totalDifficulty := "0"
+	if td != nil {
+		totalDifficulty = td.String()
+	}
+	p.blockEvents.write(pqBlockEvent{
+		BlockNumber:     number,
+		BlockHash:       hash.Hex(),
+		SensorID:        p.sensorID,
+		NodeID:          nodeID,
+		Source:          source,
+		SeenAt:          tfs,
+		TotalDifficulty: totalDifficulty,
+		ClockOffsetNS:   p.clockOffsetNS(),
+	})
+}
+
+func (p *Parquet) writeTxEvents(peer *enode.Node, hashes []common.Hash, source string, tfs time.Time) {
+	nodeID := peer.ID().String()
+	offset := p.clockOffsetNS()
+	rows := make([]pqTxEvent, 0, len(hashes))
+	for _, hash := range hashes {
+		rows = append(rows, pqTxEvent{
+			TxHash:        hash.Hex(),
+			SensorID:      p.sensorID,
+			NodeID:        nodeID,
+			Source:        source,
+			SeenAt:        tfs,
+			ClockOffsetNS: offset,
+		})
+	}
+	p.txEvents.write(rows...)
+}
+
+func (p *Parquet) writeTxs(txs []*types.Transaction, tfs time.Time) {
+	seenDate := tfs.UTC().Truncate(24 * time.Hour)
+	rows := make([]pqTx, 0, len(txs))
+	for _, tx := range txs {
+		r := newChTx(tx, p.chainID, seenDate)
+		rows = append(rows, pqTx{
+			Hash:           r.hash,
+			FromAddress:    r.from,
+			ToAddress:      r.to,
+			Value:          r.value.String(),
+			Gas:            r.gas,
+			GasPrice:       r.gasPrice.String(),
+			GasFeeCap:      r.gasFeeCap.String(),
+			GasTipCap:      r.gasTipCap.String(),
+			Nonce:          r.nonce,
+			TxType:         r.txType,
+			ChainID:        r.chainID,
+			InputSelector:  r.inputSelector,
+			InputSize:      r.inputSize,
+			AccessListSize: r.accessListSize,
+			BlobCount:      r.blobCount,
+			AuthListSize:   r.authListSize,
+			SeenDate:       r.seenDate,
+		})
+	}
+	p.txs.write(rows...)
+}
+
+// newPqBlock maps a header to a blocks row through newChBlock, so the signer
+// and address formatting match ClickHouse.
+func newPqBlock(h *types.Header) pqBlock {
+	r := newChBlock(h)
+	return pqBlock{
+		Number:      r.number,
+		Hash:        r.hash,
+		ParentHash:  r.parentHash,
+		BlockTime:   r.blockTime,
+		Signer:      r.signer,
+		Coinbase:    r.coinbase,
+		Difficulty:  r.difficulty,
+		GasUsed:     r.gasUsed,
+		GasLimit:    r.gasLimit,
+		BaseFee:     r.baseFee.String(),
+		UncleHash:   r.uncleHash,
+		StateRoot:   r.stateRoot,
+		TxRoot:      r.txRoot,
+		ReceiptRoot: r.receiptRoot,
+		LogsBloom:   r.logsBloom,
+		ExtraData:   r.extraData,
+		MixDigest:   r.mixDigest,
+		Nonce:       r.nonce,
+	}
+}
+
+// HasBlock always returns false, since the files aren't read back. Parent
+// backfill is still bounded by the sensor's oldest block.
+func (p *Parquet) HasBlock(ctx context.Context, hash common.Hash) bool {
+	return false
+}
+
+// NodeList returns an empty list as the files aren't read back.
+func (p *Parquet) NodeList(ctx context.Context, limit int) ([]string, error) {
+	return []string{}, nil
+}
+
+func (p *Parquet) MaxConcurrentWrites() int           { return p.maxConcurrency }
+func (p *Parquet) ShouldWriteBlocks() bool            { return p.shouldWriteBlocks }
+func (p *Parquet) ShouldWriteBlockEvents() bool       { return p.shouldWriteBlockEvents }
+func (p *Parquet) ShouldWriteFirstBlockEvent() bool   { return p.shouldWriteFirstBlockEvent }
+func (p *Parquet) ShouldWriteTransactions() bool      { return p.shouldWriteTransactions }
+func (p *Parquet) ShouldWriteTransactionEvents() bool { return p.shouldWriteTransactionEvents }
+func (p *Parquet) ShouldWriteFirstTransactionEvent() bool {
+	return p.shouldWriteFirstTransactionEvent
+}
+func (p *Parquet) ShouldWriteReceipts() bool       { return p.shouldWriteReceipts }
+func (p *Parquet) ShouldWriteProducerEvents() bool { return p.shouldWriteProducerEvents }
+func (p *Parquet) ShouldWritePeers() bool          { return p.shouldWritePeers }
+
+// parquetTable writes the rows of one table to a Parquet file that is replaced
+// on every rotation. Files are named after the sensor and the time they were
+// opened, e.g. blocks/sensor-20250102T150405.000Z.parquet.
+type parquetTable[T any] struct {
+	dir          string
+	prefix       string
+	rowGroupRows int64
+
+	mu     sync.Mutex
+	file   *os.File
+	writer *parquet.GenericWriter[T]
+	path   string
+}
+
+func newParquetTable[T any](root, name, sensorID string) (*parquetTable[T], error) {
+	dir := filepath.Join(root, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create parquet directory %s: %w", dir, err)
+	}
+	return &parquetTable[T]{dir: dir, prefix: sensorID, rowGroupRows: parquetRowGroupRows}, nil
+}
+
+// write appends the rows to the open file, opening one if needed. Errors are
+// logged rather than returned, like every other backend's writes.
+func (t *parquetTable[T]) write(rows ...T) {
+	if len(rows) == 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.writer == nil {
+		if err := t.open(); err != nil {
+			log.Error().Err(err).Str("table", filepath.Base(t.dir)).Msg("Failed to open Parquet file")
+			return
+		}
+	}
+	if _, err := t.writer.Write(rows); err != nil {
+		log.Error().Err(err).Str("path", t.path).Int("rows", len(rows)).Msg("Failed to write Parquet rows")
+	}
+}
+
+// open starts a new temporary file. The caller must hold t.mu.
+func (t *parquetTable[T]) open() error {
+	name := fmt.Sprintf("%s-%s.parquet", t.prefix, time.Now().UTC().Format("20060102T150405.000Z"))
+	path := filepath.Join(t.dir, name)
+	file, err := os.Create(filepath.Join(t.dir, "."+name+".tmp"))
+	if err != nil {
+		return err
+	}
+	t.file = file
+	t.path = path
+	t.writer = parquet.NewGenericWriter[T](file,
+		parquet.Compression(&parquet.Zstd),
+		parquet.MaxRowsPerRowGroup(t.rowGroupRows),
+	)
+	return nil
+}
+
+// rotate writes the footer of the open file and renames it into place.
+func (t *parquetTable[T]) rotate() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.writer == nil {
+		return nil
+	}
+	tmp := t.file.Name()
+	err := errors.Join(t.writer.Close(), t.file.Close())
+	if err == nil {
+		err = os.Rename(tmp, t.path)
+	}
+	t.file, t.writer = nil, nil
+	if err != nil {
+		return fmt.Errorf("failed to complete parquet file %s: %w", t.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/parquet-go/parquet-go"
+)
+
+func newTestParquet(t *testing.T, dir string) *Parquet {
+	t.Helper()
+	db, err := NewParquet(context.Background(), ParquetOptions{
+		Dir:                          dir,
+		RotateInterval:               time.Hour,
+		SensorID:                     "parquet-test",
+		ChainID:                      137,
+		ShouldWriteBlocks:            true,
+		ShouldWriteBlockEvents:       true,
+		ShouldWriteTransactions:      true,
+		ShouldWriteTransactionEvents: true,
+		ShouldWriteReceipts:          true,
+		ShouldWriteProducerEvents:    true,
+		ShouldWritePeers:             true,
+		ClockOffset:                  func() time.Duration { return 5 * time.Millisecond },
+	})
+	if err != nil {
+		t.Fatalf("open parquet: %v", err)
+	}
+	return db
+}
+
+// readParquetTable reads every completed file of a table.
+func readParquetTable[T any](t *testing.T, dir, table string) []T {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, table, "*.parquet"))
+	if err != nil {
+		t.Fatalf("glob %s: %v", table, err)
+	}
+	var rows []T
+	for _, file := range files {
+		r, err := parquet.ReadFile[T](file)
+		if err != nil {
+			t.Fatalf("read %s: %v", file, err)
+		}
+		rows = append(rows, r...)
+	}
+	return rows
+}
+
+// TestParquetWrites writes a whole block and a transaction announcement, and
+// checks the rows of each table once the files are completed.
+func TestParquetWrites(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	db := newTestParquet(t, dir)
+	peer := testPeer(t)
+	tfs := time.Now().UTC().Truncate(time.Microsecond)
+
+	tx := types.NewTx(&types.LegacyTx{Nonce: 1, Gas: 21000, GasPrice: big.NewInt(1), Value: big.NewInt(1), Data: []byte{0xa9, 0x05, 0x9c, 0xbb, 0x00}})
+	header := &types.Header{Number: big.NewInt(100), Difficulty: big.NewInt(1), BaseFee: big.NewInt(7), Time: uint64(tfs.Unix())}
+	block := types.NewBlockWithHeader(header).WithBody(types.Body{Transactions: []*types.Transaction{tx}})
+
+	db.WriteBlock(ctx, peer, block, big.NewInt(100), tfs)
+	db.WriteTransactionEvents(ctx, peer, []common.Hash{tx.Hash()}, tfs)
+	db.WriteReceipts(ctx, types.Receipts{{
+		Status:            types.ReceiptStatusSuccessful,
+		CumulativeGasUsed: 21000,
+		GasUsed:           21000,
+		EffectiveGasPrice: big.NewInt(7),
+		TxHash:            tx.Hash(),
+		BlockHash:         block.Hash(),
+		BlockNumber:       big.NewInt(100),
+		Logs:              []*types.Log{{}},
+	}}, tfs)
+	db.WriteProducerEvents(ctx, []ProducerEvent{{
+		Type:        ProducerEventLate,
+		Producer:    common.HexToAddress("0xAB"),
+		BlockHash:   block.Hash(),
+		BlockNumber: 100,
+		Delay:       9 * time.Second,
+	}}, tfs)
+
+	if files, _ := filepath.Glob(filepath.Join(dir, pqBlocksTable, "*.parquet")); len(files) != 0 {
+		t.Fatalf("open file should not be visible before rotation: %v", files)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("close parquet: %v", err)
+	}
+
+	blocks := readParquetTable[pqBlock](t, dir, pqBlocksTable)
+	if len(blocks) != 1 || blocks[0].Hash != block.Hash().Hex() || blocks[0].BaseFee != "7" || blocks[0].Number != 100 {
+		t.Fatalf("unexpected blocks: %+v", blocks)
+	}
+
+	events := readParquetTable[pqBlockEvent](t, dir, pqBlockEventsTable)
+	if len(events) != 1 {
+		t.Fatalf("expected 1 block event, got %d", len(events))
+	}
+	event := events[0]
+	if event.Source != srcNewBlock || event.TotalDifficulty != "100" || event.NodeID != peer.ID().String() || !event.SeenAt.Equal(tfs) {
+		t.Fatalf("unexpected block event: %+v", event)
+	}
+	if event.ClockOffsetNS == nil || *event.ClockOffsetNS != int64(5*time.Millisecond) {
+		t.Fatalf("unexpected clock offset: %v", event.ClockOffsetNS)
+	}
+
+	txs := readParquetTable[pqTx](t, dir, pqTxsTable)
+	if len(txs) != 1 || txs[0].Hash != tx.Hash().Hex() || txs[0].InputSelector != "0xa9059cbb" || txs[0].InputSize != 5 {
+		t.Fatalf("unexpected transactions: %+v", txs)
+	}
+
+	txEvents := readParquetTable[pqTxEvent](t, dir, pqTxEventsTable)
+	if len(txEvents) != 1 || txEvents[0].Source != srcHashAnnounce || txEvents[0].TxHash != tx.Hash().Hex() {
+		t.Fatalf("unexpected tx events: %+v", txEvents)
+	}
+
+	receipts := readParquetTable[pqReceipt](t, dir, pqReceiptsTable)
+	if len(receipts) != 1 || receipts[0].TxHash != tx.Hash().Hex() || receipts[0].Status != 1 ||
+		receipts[0].EffectiveGasPrice != "7" || receipts[0].LogCount != 1 || receipts[0].ContractAddress != "" {
+		t.Fatalf("unexpected receipts: %+v", receipts)
+	}
+
+	producerEvents := readParquetTable[pqProducerEvent](t, dir, pqProducerTable)
+	if len(producerEvents) != 1 || producerEvents[0].Type != ProducerEventLate || producerEvents[0].DelayMs != 9000 ||
+		producerEvents[0].Producer != "0x00000000000000000000000000000000000000ab" || producerEvents[0].OtherHash != "" {
+		t.Fatalf("unexpected producer events: %+v", producerEvents)
+	}
+}
+
+// TestParquetRotate checks that each rotation completes a file and that idle
+// tables produce none.
+func TestParquetRotate(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	db := newTestParquet(t, dir)
+	peer := testPeer(t)
+
+	db.WriteBlockEvents(ctx, peer, []BlockAnnouncement{{Hash: common.HexToHash("0x01"), Number: 1}}, time.Now())
+	if err := db.rotate(); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	// File names have millisecond precision.
+	time.Sleep(2 * time.Millisecond)
+	db.WriteBlockEvents(ctx, peer, []BlockAnnouncement{{Hash: common.HexToHash("0x02"), Number: 2}}, time.Now())
+	if err := db.Close(); err != nil {
+		t.Fatalf("close parquet: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, pqBlockEventsTable, "*.parquet"))
+	if len(files) != 2 {
+		t.Fatalf("expected 2 block event files, got %v", files)
+	}
+	if events := readParquetTable[pqBlockEvent](t, dir, pqBlockEventsTable); len(events) != 2 {
+		t.Fatalf("expected 2 block events, got %d", len(events))
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, pqBlocksTable, "*")); len(files) != 0 {
+		t.Fatalf("idle table should have no files: %v", files)
+	}
+}
+
+// TestParquetRowGroups checks that rows are flushed in bounded row groups over
+// several writes and read back in order.
+func TestParquetRowGroups(t *testing.T) {
+	dir := t.TempDir()
+	table, err := newParquetTable[pqPeer](dir, pqPeersTable, "parquet-test")
+	if err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	table.rowGroupRows = 3
+
+	var want []string
+	for i := range 4 {
+		rows := make([]pqPeer, 0, 2)
+		for j := range 2 {
+			id := fmt.Sprintf("peer-%d", 2*i+j)
+			rows = append(rows, pqPeer{NodeID: id})
+			want = append(want, id)
+		}
+		table.write(rows...)
+	}
+	if err := table.rotate(); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, pqPeersTable, "*.parquet"))
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file, got %v", files)
+	}
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatalf("open %s: %v", files[0], err)
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		t.Fatalf("stat %s: %v", files[0], err)
+	}
+	pf, err := parquet.OpenFile(f, stat.Size())
+	if err != nil {
+		t.Fatalf("open parquet %s: %v", files[0], err)
+	}
+	if groups := pf.RowGroups(); len(groups) != 3 {
+		t.Fatalf("expected 3 row groups, got %d", len(groups))
+	}
+
+	peers := readParquetTable[pqPeer](t, dir, pqPeersTable)
+	if len(peers) != len(want) {
+		t.Fatalf("expected %d peers, got %d", len(want), len(peers))
+	}
+	for i, peer := range peers {
+		if peer.NodeID != want[i] {
+			t.Fatalf("peer %d: want %s, got %s", i, want[i], peer.NodeID)
+		}
+	}
+}
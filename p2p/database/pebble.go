@@ -0,0 +1,853 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/rs/zerolog/log"
+)
+
+// Key prefixes of the Pebble backend. Blocks, transactions and peers are keyed
+// by hash or ID, and receipts by the hash of their block. Events are keyed by
+// hash first so every observation of one block or transaction is a single range
+// scan, and block events are also indexed by time so NodeList can walk the most
+// recent ones. Producer events are keyed by producer, then time.
+var (
+	pebbleBlockPrefix          = []byte("b/")
+	pebbleTransactionPrefix    = []byte("t/")
+	pebblePeerPrefix           = []byte("p/")
+	pebbleReceiptsPrefix       = []byte("r/")
+	pebbleBlockEventPrefix     = []byte("eb/")
+	pebbleTxEventPrefix        = []byte("et/")
+	pebbleBlockEventTimePrefix = []byte("ib/")
+	pebbleProducerEventPrefix  = []byte("pe/")
+)
+
+// Pebble implements the Database interface with an embedded Pebble key-value
+// store, so a single sensor can persist and query its observations without
+// cloud credentials or a database server. Writes are synchronous but not
+// fsynced; Close flushes them to disk.
+//
+// Records mirror the Datastore backend: one entity per block hash that is
+// filled in as headers, bodies and whole blocks arrive, keeping the earliest
+// first-seen time, and append-only events per peer observation.
+type Pebble struct {
+	db                               *pebble.DB
+	sensorID                         string
+	chainID                          *big.Int
+	maxConcurrency                   int
+	shouldWriteBlocks                bool
+	shouldWriteBlockEvents           bool
+	shouldWriteFirstBlockEvent       bool
+	shouldWriteTransactions          bool
+	shouldWriteTransactionEvents     bool
+	shouldWriteFirstTransactionEvent bool
+	shouldWriteReceipts              bool
+	shouldWriteProducerEvents        bool
+	shouldWritePeers                 bool
+	clockOffset                      ClockOffset
+
+	// mu serializes the read-modify-write updates of block and transaction
+	// entities. Events and peers are blind writes and don't take it.
+	mu sync.Mutex
+}
+
+// PebbleOptions is used when creating a NewPebble.
+type PebbleOptions struct {
+	Path                             string
+	SensorID                         string
+	ChainID                          uint64
+	MaxConcurrency                   int
+	ShouldWriteBlocks                bool
+	ShouldWriteBlockEvents           bool
+	ShouldWriteFirstBlockEvent       bool
+	ShouldWriteTransactions          bool
+	ShouldWriteTransactionEvents     bool
+	ShouldWriteFirstTransactionEvent bool
+	ShouldWriteReceipts              bool
+	ShouldWriteProducerEvents        bool
+	ShouldWritePeers                 bool
+	ClockOffset                      ClockOffset
+}
+
+// PebbleBlock is a block entity. Header is nil when only the hash has been
+// seen, and Transactions and Uncles are nil until the body arrives.
+type PebbleBlock struct {
+	Header              *types.Header `json:"header,omitempty"`
+	TotalDifficulty     string        `json:"totalDifficulty,omitempty"`
+	Transactions        []common.Hash `json:"transactions,omitempty"`
+	Uncles              []common.Hash `json:"uncles,omitempty"`
+	TimeFirstSeen       time.Time     `json:"timeFirstSeen"`
+	SensorFirstSeen     string        `json:"sensorFirstSeen,omitempty"`
+	TimeFirstSeenHash   time.Time     `json:"timeFirstSeenHash"`
+	SensorFirstSeenHash string        `json:"sensorFirstSeenHash,omitempty"`
+	IsParent            bool          `json:"isParent"`
+}
+
+// PebbleTransaction is a transaction entity.
+type PebbleTransaction struct {
+	Transaction     *types.Transaction `json:"transaction"`
+	From            string             `json:"from,omitempty"`
+	TimeFirstSeen   time.Time          `json:"timeFirstSeen"`
+	SensorFirstSeen string             `json:"sensorFirstSeen,omitempty"`
+}
+
+// PebbleReceipts are the verified receipts of a block.
+type PebbleReceipts struct {
+	Receipts        types.Receipts `json:"receipts"`
+	TimeFirstSeen   time.Time      `json:"timeFirstSeen"`
+	SensorFirstSeen string         `json:"sensorFirstSeen,omitempty"`
+}
+
+// PebbleEvent is a single peer sending the sensor a block or transaction hash.
+// Number is only set for block events that carried a height. ClockOffset is
+// the sensor's clock offset when the event was recorded.
+type PebbleEvent struct {
+	SensorID    string        `json:"sensorId"`
+	PeerID      string        `json:"peerId"`
+	Hash        common.Hash   `json:"hash"`
+	Number      uint64        `json:"number,omitempty"`
+	Time        time.Time     `json:"time"`
+	ClockOffset time.Duration `json:"clockOffset,omitempty"`
+}
+
+// PebbleProducerEvent is producer misbehaviour detected by the sensor, see
+// ProducerEvent.
+type PebbleProducerEvent struct {
+	ProducerEvent
+	SensorID string    `json:"sensorId"`
+	Time     time.Time `json:"time"`
+}
+
+// PebblePeer is the last known state of a connected peer.
+type PebblePeer struct {
+	Name         string    `json:"name"`
+	Caps         []string  `json:"caps"`
+	URL          string    `json:"url"`
+	LastSeenBy   string    `json:"lastSeenBy"`
+	TimeLastSeen time.Time `json:"timeLastSeen"`
+}
+
+// NewPebble opens or creates the Pebble database at opts.Path. Unlike the
+// network backends an unusable local path is a configuration error, so it's
+// returned rather than degrading to no-op writes.
+func NewPebble(opts PebbleOptions) (*Pebble, error) {
+	if opts.Path == "" {
+		return nil, errors.New("pebble database path is required")
+	}
+
+	db, err := pebble.Open(opts.Path, &pebble.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pebble database at %s: %w", opts.Path, err)
+	}
+
+	return &Pebble{
+		db:                               db,
+		sensorID:                         opts.SensorID,
+		chainID:                          new(big.Int).SetUint64(opts.ChainID),
+		maxConcurrency:                   opts.MaxConcurrency,
+		shouldWriteBlocks:                opts.ShouldWriteBlocks,
+		shouldWriteBlockEvents:           opts.ShouldWriteBlockEvents,
+		shouldWriteFirstBlockEvent:       opts.ShouldWriteFirstBlockEvent,
+		shouldWriteTransactions:          opts.ShouldWriteTransactions,
+		shouldWriteTransactionEvents:     opts.ShouldWriteTransactionEvents,
+		shouldWriteFirstTransactionEvent: opts.ShouldWriteFirstTransactionEvent,
+		shouldWriteReceipts:              opts.ShouldWriteReceipts,
+		shouldWriteProducerEvents:        opts.ShouldWriteProducerEvents,
+		shouldWritePeers:                 opts.ShouldWritePeers,
+		clockOffset:                      opts.ClockOffset,
+	}, nil
+}
+
+// recordsBlockEvents reports whether a block event should be written for a
+// block delivered whole. See Datastore.recordsBlockEvents for why this follows
+// either flag.
+func (p *Pebble) recordsBlockEvents() bool {
+	return p.shouldWriteBlockEvents || p.shouldWriteFirstBlockEvent
+}
+
+// WriteBlock writes the block and the block event.
+func (p *Pebble) WriteBlock(ctx context.Context, peer *enode.Node, block *types.Block, td *big.Int, tfs time.Time) {
+	if p.recordsBlockEvents() && peer != nil {
+		p.writeEvents(peer, pebbleBlockEventPrefix, []BlockAnnouncement{{Hash: block.Hash(), Number: block.NumberU64()}}, tfs)
+	}
+
+	if !p.ShouldWriteBlocks() {
+		return
+	}
+
+	if p.shouldWriteTransactions && len(block.Transactions()) > 0 {
+		p.writeTransactions(block.Transactions(), tfs)
+	}
+	for _, uncle := range block.Uncles() {
+		p.writeBlockHeader(uncle, tfs, false)
+	}
+
+	p.updateBlock(block.Hash(), func(b *PebbleBlock) bool {
+		modified := false
+
+		if b.Header == nil || tfs.Before(b.TimeFirstSeen) {
+			modified = true
+			b.Header = block.Header()
+			b.TimeFirstSeen = tfs
+			b.SensorFirstSeen = p.sensorID
+			b.IsParent = false
+			p.writeFirstSeen(b, tfs)
+		}
+
+		if len(b.TotalDifficulty) == 0 && td != nil {
+			modified = true
+			b.TotalDifficulty = td.String()
+		}
+
+		if b.Transactions == nil && len(block.Transactions()) > 0 {
+			modified = true
+			b.Transactions = make([]common.Hash, 0, len(block.Transactions()))
+			for _, tx := range block.Transactions() {
+				b.Transactions = append(b.Transactions, tx.Hash())
+			}
+		}
+
+		if b.Uncles == nil && len(block.Uncles()) > 0 {
+			modified = true
+			b.Uncles = make([]common.Hash, 0, len(block.Uncles()))
+			for _, uncle := range block.Uncles() {
+				b.Uncles = append(b.Uncles, uncle.Hash())
+			}
+		}
+
+		return modified
+	})
+}
+
+// WriteBlockHeaders writes the block headers. Block events are written when
+// the hash is received instead, since headers are only sent when requested.
+func (p *Pebble) WriteBlockHeaders(ctx context.Context, headers []*types.Header, tfs time.Time, isParent bool) {
+	if !p.ShouldWriteBlocks() {
+		return
+	}
+
+	for _, header := range headers {
+		p.writeBlockHeader(header, tfs, isParent)
+	}
+}
+
+// WriteBlockBody links the transactions and uncles of the body onto the block
+// entity, writing the transactions themselves if ShouldWriteTransactions.
+func (p *Pebble) WriteBlockBody(ctx context.Context, body *eth.BlockBody, ann BlockAnnouncement, tfs time.Time) {
+	if !p.ShouldWriteBlocks() {
+		return
+	}
+
+	var txs []*types.Transaction
+	if body.Transactions.Len() > 0 {
+		var err error
+		if txs, err = body.Transactions.Items(); err != nil {
+			log.Error().Err(err).Str("hash", ann.Hash.Hex()).Msg("Failed to decode transactions from block body")
+		} else if p.shouldWriteTransactions {
+			p.writeTransactions(txs, tfs)
+		}
+	}
+
+	var uncles []*types.Header
+	if body.Uncles.Len() > 0 {
+		var err error
+		if uncles, err = body.Uncles.Items(); err != nil {
+			log.Error().Err(err).Str("hash", ann.Hash.Hex()).Msg("Failed to decode uncles from block body")
+		}
+		for _, uncle := range uncles {
+			p.writeBlockHeader(uncle, tfs, false)
+		}
+	}
+
+	p.updateBlock(ann.Hash, func(b *PebbleBlock) bool {
+		modified := false
+
+		if b.Transactions == nil && len(txs) > 0 {
+			modified = true
+			b.Transactions = make([]common.Hash, 0, len(txs))
+			for _, tx := range txs {
+				b.Transactions = append(b.Transactions, tx.Hash())
+			}
+		}
+
+		if b.Uncles == nil && len(uncles) > 0 {
+			modified = true
+			b.Uncles = make([]common.Hash, 0, len(uncles))
+			for _, uncle := range uncles {
+				b.Uncles = append(b.Uncles, uncle.Hash())
+			}
+		}
+
+		return modified
+	})
+}
+
+// WriteBlockEvents appends an inbound block event per announcement for the
+// given peer.
+func (p *Pebble) WriteBlockEvents(ctx context.Context, peer *enode.Node, anns []BlockAnnouncement, tfs time.Time) {
+	if peer == nil || len(anns) == 0 {
+		return
+	}
+
+	p.writeEvents(peer, pebbleBlockEventPrefix, anns, tfs)
+}
+
+// WriteBlockHashFirstSeen stamps the block entity's earliest hash-first-seen
+// time, creating a partial entity if the block hasn't been seen yet.
+func (p *Pebble) WriteBlockHashFirstSeen(ctx context.Context, peer *enode.Node, hash common.Hash, tfsh time.Time) {
+	if !p.ShouldWriteBlocks() {
+		return
+	}
+
+	p.updateBlock(hash, func(b *PebbleBlock) bool {
+		if !b.TimeFirstSeenHash.IsZero() && !tfsh.Before(b.TimeFirstSeenHash) {
+			return false
+		}
+		b.TimeFirstSeenHash = tfsh
+		b.SensorFirstSeenHash = p.sensorID
+		return true
+	})
+}
+
+// WriteTransactions writes the transaction bodies. Transaction events are
+// recorded separately via WriteTransactionEvents.
+func (p *Pebble) WriteTransactions(ctx context.Context, peer *enode.Node, txs []*types.Transaction, tfs time.Time) {
+	if !p.ShouldWriteTransactions() {
+		return
+	}
+
+	p.writeTransactions(txs, tfs)
+}
+
+// WriteTransactionEvents appends an inbound transaction event per hash for the
+// given peer.
+func (p *Pebble) WriteTransactionEvents(ctx context.Context, peer *enode.Node, hashes []common.Hash, tfs time.Time) {
+	if peer == nil || len(hashes) == 0 {
+		return
+	}
+
+	anns := make([]BlockAnnouncement, 0, len(hashes))
+	for _, hash := range hashes {
+		anns = append(anns, BlockAnnouncement{Hash: hash})
+	}
+	p.writeEvents(peer, pebbleTxEventPrefix, anns, tfs)
+}
+
+// WriteReceipts writes the receipts of a block. Receipts are verified against
+// the block's receipts root before they're written, so an existing record is
+// never replaced.
+func (p *Pebble) WriteReceipts(ctx context.Context, receipts types.Receipts, tfs time.Time) {
+	if !p.ShouldWriteReceipts() || len(receipts) == 0 {
+		return
+	}
+
+	key := pebbleKey(pebbleReceiptsPrefix, receipts[0].BlockHash.Bytes())
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, closer, err := p.db.Get(key); err == nil {
+		closer.Close()
+		return
+	}
+
+	if err := p.set(key, &PebbleReceipts{
+		Receipts:        receipts,
+		TimeFirstSeen:   tfs,
+		SensorFirstSeen: p.sensorID,
+	}); err != nil {
+		log.Error().Err(err).Str("hash", receipts[0].BlockHash.Hex()).Msg("Failed to write receipts")
+	}
+}
+
+// WriteProducerEvents appends the producer events.
+func (p *Pebble) WriteProducerEvents(ctx context.Context, events []ProducerEvent, tfs time.Time) {
+	if !p.ShouldWriteProducerEvents() {
+		return
+	}
+
+	batch := p.db.NewBatch()
+	defer batch.Close()
+
+	ts := pebbleTime(tfs)
+	for _, event := range events {
+		value, err := json.Marshal(&PebbleProducerEvent{
+			ProducerEvent: event,
+			SensorID:      p.sensorID,
+			Time:          tfs,
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to encode producer event")
+			continue
+		}
+
+		key := pebbleKey(pebbleProducerEventPrefix, event.Producer.Bytes(), ts, event.BlockHash.Bytes(), []byte(event.Type))
+		if err := batch.Set(key, value, nil); err != nil {
+			log.Error().Err(err).Msg("Failed to write producer event")
+		}
+	}
+
+	if err := batch.Commit(pebble.NoSync); err != nil {
+		log.Error().Err(err).Msg("Failed to write producer events")
+	}
+}
+
+// WritePeers writes the connected peers, replacing their previous state.
+func (p *Pebble) WritePeers(ctx context.Context, peers []*p2p.Peer, tls time.Time) {
+	if !p.ShouldWritePeers() {
+		return
+	}
+
+	batch := p.db.NewBatch()
+	defer batch.Close()
+
+	for _, peer := range peers {
+		value, err := json.Marshal(&PebblePeer{
+			Name:         peer.Fullname(),
+			Caps:         peer.Info().Caps,
+			URL:          peer.Node().URLv4(),
+			LastSeenBy:   p.sensorID,
+			TimeLastSeen: tls,
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to encode peer")
+			continue
+		}
+		if err := batch.Set(pebbleKey(pebblePeerPrefix, peer.ID().Bytes()), value, nil); err != nil {
+			log.Error().Err(err).Msg("Failed to write peer")
+		}
+	}
+
+	if err := batch.Commit(pebble.NoSync); err != nil {
+		log.Error().Err(err).Msg("Failed to write peers")
+	}
+}
+
+func (p *Pebble) MaxConcurrentWrites() int {
+	return p.maxConcurrency
+}
+
+func (p *Pebble) ShouldWriteBlocks() bool {
+	return p.shouldWriteBlocks
+}
+
+func (p *Pebble) ShouldWriteBlockEvents() bool {
+	return p.shouldWriteBlockEvents
+}
+
+func (p *Pebble) ShouldWriteFirstBlockEvent() bool {
+	return p.shouldWriteFirstBlockEvent
+}
+
+func (p *Pebble) ShouldWriteTransactions() bool {
+	return p.shouldWriteTransactions
+}
+
+func (p *Pebble) ShouldWriteTransactionEvents() bool {
+	return p.shouldWriteTransactionEvents
+}
+
+func (p *Pebble) ShouldWriteFirstTransactionEvent() bool {
+	return p.shouldWriteFirstTransactionEvent
+}
+
+func (p *Pebble) ShouldWriteReceipts() bool {
+	return p.shouldWriteReceipts
+}
+
+func (p *Pebble) ShouldWriteProducerEvents() bool {
+	return p.shouldWriteProducerEvents
+}
+
+func (p *Pebble) ShouldWritePeers() bool {
+	return p.shouldWritePeers
+}
+
+// HasBlock reports whether the block header has been written.
+func (p *Pebble) HasBlock(ctx context.Context, hash common.Hash) bool {
+	block, err := p.Block(hash)
+	return err == nil && block.Header != nil
+}
+
+// NodeList returns the enode URLs of the peers that most recently sent block
+// events, up to limit unique nodes.
+func (p *Pebble) NodeList(ctx context.Context, limit int) ([]string, error) {
+	iter, err := p.db.NewIter(prefixIterOptions(pebbleBlockEventTimePrefix))
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	enodes := make(map[string]struct{})
+	nodelist := []string{}
+	for valid := iter.Last(); valid && len(enodes) < limit; valid = iter.Prev() {
+		url := string(iter.Value())
+		if _, ok := enodes[url]; ok {
+			continue
+		}
+		enodes[url] = struct{}{}
+		nodelist = append(nodelist, url)
+	}
+
+	log.Info().Int("enodes", len(enodes)).Send()
+
+	return nodelist, iter.Error()
+}
+
+// Close flushes the memtable to disk and closes the database.
+func (p *Pebble) Close() error {
+	if err := p.db.Flush(); err != nil {
+		log.Error().Err(err).Msg("Failed to flush pebble database")
+	}
+	return p.db.Close()
+}
+
+// Block returns the block entity for a hash.
+func (p *Pebble) Block(hash common.Hash) (*PebbleBlock, error) {
+	var block PebbleBlock
+	if err := p.get(pebbleKey(pebbleBlockPrefix, hash.Bytes()), &block); err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+// Transaction returns the transaction entity for a hash.
+func (p *Pebble) Transaction(hash common.Hash) (*PebbleTransaction, error) {
+	var tx PebbleTransaction
+	if err := p.get(pebbleKey(pebbleTransactionPrefix, hash.Bytes()), &tx); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// Receipts returns the receipts of a block hash.
+func (p *Pebble) Receipts(hash common.Hash) (*PebbleReceipts, error) {
+	var receipts PebbleReceipts
+	if err := p.get(pebbleKey(pebbleReceiptsPrefix, hash.Bytes()), &receipts); err != nil {
+		return nil, err
+	}
+	return &receipts, nil
+}
+
+// ForEachBlock calls fn for every block entity. Iteration stops at the first
+// error.
+func (p *Pebble) ForEachBlock(fn func(common.Hash, *PebbleBlock) error) error {
+	iter, err := p.db.NewIter(prefixIterOptions(pebbleBlockPrefix))
+	if err != nil {
+		return err
+	}
+	defer iter.Close()
+
+	for valid := iter.First(); valid; valid = iter.Next() {
+		var block PebbleBlock
+		if err := json.Unmarshal(iter.Value(), &block); err != nil {
+			return err
+		}
+		if err := fn(common.BytesToHash(iter.Key()[len(pebbleBlockPrefix):]), &block); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
+// BlockEvents returns every recorded announcement of a block hash, ordered by time.
+func (p *Pebble) BlockEvents(hash common.Hash) ([]PebbleEvent, error) {
+	return p.events(pebbleKey(pebbleBlockEventPrefix, hash.Bytes()))
+}
+
+// TransactionEvents returns every recorded announcement of a transaction hash,
+// ordered by time.
+func (p *Pebble) TransactionEvents(hash common.Hash) ([]PebbleEvent, error) {
+	return p.events(pebbleKey(pebbleTxEventPrefix, hash.Bytes()))
+}
+
+// ForEachBlockEvent calls fn for every block event, grouped by block hash and
+// ordered by time within a hash. Iteration stops at the first error.
+func (p *Pebble) ForEachBlockEvent(fn func(PebbleEvent) error) error {
+	return p.forEachEvent(pebbleBlockEventPrefix, fn)
+}
+
+// ForEachTransactionEvent calls fn for every transaction event, grouped by
+// transaction hash and ordered by time within a hash. Iteration stops at the
+// first error.
+func (p *Pebble) ForEachTransactionEvent(fn func(PebbleEvent) error) error {
+	return p.forEachEvent(pebbleTxEventPrefix, fn)
+}
+
+// ProducerEvents returns the producer events of a producer, ordered by time.
+func (p *Pebble) ProducerEvents(producer common.Address) ([]PebbleProducerEvent, error) {
+	iter, err := p.db.NewIter(prefixIterOptions(pebbleKey(pebbleProducerEventPrefix, producer.Bytes())))
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var events []PebbleProducerEvent
+	for valid := iter.First(); valid; valid = iter.Next() {
+		var event PebbleProducerEvent
+		if err := json.Unmarshal(iter.Value(), &event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, iter.Error()
+}
+
+// Peers returns the last known state of every peer.
+func (p *Pebble) Peers() ([]PebblePeer, error) {
+	iter, err := p.db.NewIter(prefixIterOptions(pebblePeerPrefix))
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var peers []PebblePeer
+	for valid := iter.First(); valid; valid = iter.Next() {
+		var peer PebblePeer
+		if err := json.Unmarshal(iter.Value(), &peer); err != nil {
+			return nil, err
+		}
+		peers = append(peers, peer)
+	}
+	return peers, iter.Error()
+}
+
+// writeFirstSeen sets hash timing if it doesn't exist or if new timestamp is earlier.
+func (p *Pebble) writeFirstSeen(block *PebbleBlock, tfs time.Time) {
+	if block.TimeFirstSeenHash.IsZero() || tfs.Before(block.TimeFirstSeenHash) {
+		block.TimeFirstSeenHash = tfs
+		block.SensorFirstSeenHash = p.sensorID
+	}
+}
+
+// writeBlockHeader writes the block header if it doesn't exist or was first
+// seen later than tfs.
+func (p *Pebble) writeBlockHeader(header *types.Header, tfs time.Time, isParent bool) {
+	p.updateBlock(header.Hash(), func(b *PebbleBlock) bool {
+		if b.Header != nil && !tfs.Before(b.TimeFirstSeen) {
+			return false
+		}
+		b.Header = header
+		b.TimeFirstSeen = tfs
+		b.SensorFirstSeen = p.sensorID
+		b.IsParent = isParent
+		p.writeFirstSeen(b, tfs)
+		return true
+	})
+}
+
+// updateBlock applies update to the block entity for hash, creating it if
+// needed, and writes it back if update reports a modification.
+func (p *Pebble) updateBlock(hash common.Hash, update func(*PebbleBlock) bool) {
+	key := pebbleKey(pebbleBlockPrefix, hash.Bytes())
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var block PebbleBlock
+	if err := p.get(key, &block); err != nil && !errors.Is(err, pebble.ErrNotFound) {
+		log.Error().Err(err).Str("hash", hash.Hex()).Msg("Failed to read block")
+		return
+	}
+
+	if !update(&block) {
+		return
+	}
+
+	if err := p.set(key, &block); err != nil {
+		log.Error().Err(err).Str("hash", hash.Hex()).Msg("Failed to write block")
+	}
+}
+
+// writeTransactions writes the transactions, skipping those that already
+// exist with an earlier or equal TimeFirstSeen.
+func (p *Pebble) writeTransactions(txs []*types.Transaction, tfs time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	batch := p.db.NewBatch()
+	defer batch.Close()
+
+	for _, tx := range txs {
+		key := pebbleKey(pebbleTransactionPrefix, tx.Hash().Bytes())
+
+		var existing PebbleTransaction
+		if err := p.get(key, &existing); err == nil && !existing.TimeFirstSeen.IsZero() && !tfs.Before(existing.TimeFirstSeen) {
+			continue
+		}
+
+		value, err := json.Marshal(p.newPebbleTransaction(tx, tfs))
+		if err != nil {
+			log.Error().Err(err).Str("hash", tx.Hash().Hex()).Msg("Failed to encode transaction")
+			continue
+		}
+		if err := batch.Set(key, value, nil); err != nil {
+			log.Error().Err(err).Str("hash", tx.Hash().Hex()).Msg("Failed to write transaction")
+		}
+	}
+
+	if err := batch.Commit(pebble.NoSync); err != nil {
+		log.Error().Err(err).Msg("Failed to write transactions")
+	}
+}
+
+// newPebbleTransaction creates a PebbleTransaction, recovering the sender.
+func (p *Pebble) newPebbleTransaction(tx *types.Transaction, tfs time.Time) *PebbleTransaction {
+	chainID := tx.ChainId()
+	if chainID == nil || chainID.Sign() <= 0 {
+		chainID = p.chainID
+	}
+
+	var from string
+	if address, err := types.Sender(types.LatestSignerForChainID(chainID), tx); err == nil {
+		from = address.Hex()
+	}
+
+	return &PebbleTransaction{
+		Transaction:     tx,
+		From:            from,
+		TimeFirstSeen:   tfs,
+		SensorFirstSeen: p.sensorID,
+	}
+}
+
+// writeEvents appends an event per announcement under the given prefix. Block
+// events are also added to the time index used by NodeList.
+func (p *Pebble) writeEvents(peer *enode.Node, prefix []byte, anns []BlockAnnouncement, tfs time.Time) {
+	batch := p.db.NewBatch()
+	defer batch.Close()
+
+	peerID := peer.ID().Bytes()
+	peerURL := peer.URLv4()
+	ts := pebbleTime(tfs)
+	offset := p.clockOffset.get()
+
+	for _, ann := range anns {
+		value, err := json.Marshal(&PebbleEvent{
+			SensorID:    p.sensorID,
+			PeerID:      peerURL,
+			Hash:        ann.Hash,
+			Number:      ann.Number,
+			Time:        tfs,
+			ClockOffset: offset,
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to encode event")
+			continue
+		}
+
+		if err := batch.Set(pebbleKey(prefix, ann.Hash.Bytes(), ts, peerID), value, nil); err != nil {
+			log.Error().Err(err).Msg("Failed to write event")
+			continue
+		}
+
+		if bytes.Equal(prefix, pebbleBlockEventPrefix) {
+			if err := batch.Set(pebbleKey(pebbleBlockEventTimePrefix, ts, peerID), []byte(peerURL), nil); err != nil {
+				log.Error().Err(err).Msg("Failed to index event")
+			}
+		}
+	}
+
+	if err := batch.Commit(pebble.NoSync); err != nil {
+		log.Error().Err(err).Msgf("Failed to write events %s", prefix)
+	}
+}
+
+// events returns the events stored under a hash prefix.
+func (p *Pebble) events(prefix []byte) ([]PebbleEvent, error) {
+	var events []PebbleEvent
+	err := p.forEachEvent(prefix, func(event PebbleEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	return events, err
+}
+
+// forEachEvent decodes and visits every event under prefix in key order.
+func (p *Pebble) forEachEvent(prefix []byte, fn func(PebbleEvent) error) error {
+	iter, err := p.db.NewIter(prefixIterOptions(prefix))
+	if err != nil {
+		return err
+	}
+	defer iter.Close()
+
+	for valid := iter.First(); valid; valid = iter.Next() {
+		var event PebbleEvent
+		if err := json.Unmarshal(iter.Value(), &event); err != nil {
+			return err
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
+// get decodes the JSON value stored at key into v.
+func (p *Pebble) get(key []byte, v any) error {
+	value, closer, err := p.db.Get(key)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+	return json.Unmarshal(value, v)
+}
+
+// set stores v as JSON at key.
+func (p *Pebble) set(key []byte, v any) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return p.db.Set(key, value, pebble.NoSync)
+}
+
+// pebbleKey concatenates a prefix and key parts.
+func pebbleKey(prefix []byte, parts ...[]byte) []byte {
+	size := len(prefix)
+	for _, part := range parts {
+		size += len(part)
+	}
+
+	key := make([]byte, 0, size)
+	key = append(key, prefix...)
+	for _, part := range parts {
+		key = append(key, part...)
+	}
+	return key
+}
+
+// pebbleTime encodes a time so keys sort chronologically.
+func pebbleTime(t time.Time) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(t.UnixNano()))
+	return b
+}
+
+// prefixIterOptions bounds an iterator to the keys starting with prefix.
+func prefixIterOptions(prefix []byte) *pebble.IterOptions {
+	upper := make([]byte, len(prefix))
+	copy(upper, prefix)
+	for i := len(upper) - 1; i >= 0; i-- {
+		upper[i]++
+		if upper[i] != 0 {
+			upper = upper[:i+1]
+			break
+		}
+	}
+	return &pebble.IterOptions{LowerBound: prefix, UpperBound: upper}
+}
@@ -0,0 +1,212 @@
+package database
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func newTestPebble(t *testing.T) *Pebble {
+	t.Helper()
+	db, err := NewPebble(PebbleOptions{
+		Path:                         t.TempDir(),
+		SensorID:                     "pebble-test",
+		ChainID:                      137,
+		ShouldWriteBlocks:            true,
+		ShouldWriteBlockEvents:       true,
+		ShouldWriteTransactions:      true,
+		ShouldWriteTransactionEvents: true,
+		ShouldWriteReceipts:          true,
+		ShouldWriteProducerEvents:    true,
+		ShouldWritePeers:             true,
+	})
+	if err != nil {
+		t.Fatalf("open pebble: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Fatalf("close pebble: %v", err)
+		}
+	})
+	return db
+}
+
+// TestPebbleWrites writes a whole block and checks the block, transaction and
+// event records it produces.
+func TestPebbleWrites(t *testing.T) {
+	ctx := context.Background()
+	db := newTestPebble(t)
+	peer := testPeer(t)
+	tfs := time.Now()
+
+	tx := types.NewTx(&types.LegacyTx{Nonce: 1, Gas: 21000, GasPrice: big.NewInt(1), Value: big.NewInt(1)})
+	header := &types.Header{Number: big.NewInt(100), Difficulty: big.NewInt(1), Time: uint64(tfs.Unix())}
+	block := types.NewBlockWithHeader(header).WithBody(types.Body{Transactions: []*types.Transaction{tx}})
+
+	if db.HasBlock(ctx, block.Hash()) {
+		t.Fatal("block should not exist before it is written")
+	}
+
+	db.WriteBlock(ctx, peer, block, big.NewInt(100), tfs)
+	db.WriteTransactionEvents(ctx, peer, []common.Hash{tx.Hash()}, tfs)
+
+	if !db.HasBlock(ctx, block.Hash()) {
+		t.Fatal("block should exist after it is written")
+	}
+
+	stored, err := db.Block(block.Hash())
+	if err != nil {
+		t.Fatalf("read block: %v", err)
+	}
+	if stored.Header.Number.Uint64() != 100 || stored.TotalDifficulty != "100" {
+		t.Fatalf("unexpected block fields: number=%d td=%s", stored.Header.Number.Uint64(), stored.TotalDifficulty)
+	}
+	if len(stored.Transactions) != 1 || stored.Transactions[0] != tx.Hash() {
+		t.Fatalf("unexpected block transactions: %v", stored.Transactions)
+	}
+
+	if _, err := db.Transaction(tx.Hash()); err != nil {
+		t.Fatalf("read transaction: %v", err)
+	}
+
+	events, err := db.BlockEvents(block.Hash())
+	if err != nil {
+		t.Fatalf("read block events: %v", err)
+	}
+	if len(events) != 1 || events[0].PeerID != peer.URLv4() || events[0].Number != 100 {
+		t.Fatalf("unexpected block events: %+v", events)
+	}
+
+	txEvents, err := db.TransactionEvents(tx.Hash())
+	if err != nil {
+		t.Fatalf("read transaction events: %v", err)
+	}
+	if len(txEvents) != 1 {
+		t.Fatalf("want 1 transaction event, got %d", len(txEvents))
+	}
+}
+
+// TestPebbleFirstSeen checks that a later header or hash announcement doesn't
+// overwrite the earliest first-seen times.
+func TestPebbleFirstSeen(t *testing.T) {
+	ctx := context.Background()
+	db := newTestPebble(t)
+	early := time.Unix(1000, 0)
+	late := time.Unix(2000, 0)
+
+	header := &types.Header{Number: big.NewInt(200), Difficulty: big.NewInt(1)}
+	hash := header.Hash()
+
+	db.WriteBlockHashFirstSeen(ctx, nil, hash, late)
+	db.WriteBlockHeaders(ctx, []*types.Header{header}, early, false)
+	db.WriteBlockHeaders(ctx, []*types.Header{header}, late, true)
+	db.WriteBlockHashFirstSeen(ctx, nil, hash, late)
+
+	stored, err := db.Block(hash)
+	if err != nil {
+		t.Fatalf("read block: %v", err)
+	}
+	if !stored.TimeFirstSeen.Equal(early) || !stored.TimeFirstSeenHash.Equal(early) {
+		t.Fatalf("first seen overwritten: header=%s hash=%s", stored.TimeFirstSeen, stored.TimeFirstSeenHash)
+	}
+	if stored.IsParent {
+		t.Fatal("later parent fetch should not overwrite the header")
+	}
+}
+
+// TestPebbleReceipts checks that receipts round trip and that a later copy
+// doesn't replace the first one.
+func TestPebbleReceipts(t *testing.T) {
+	ctx := context.Background()
+	db := newTestPebble(t)
+	early := time.Unix(1000, 0)
+	late := time.Unix(2000, 0)
+
+	blockHash := common.HexToHash("0x01")
+	receipt := &types.Receipt{
+		Status:            types.ReceiptStatusSuccessful,
+		CumulativeGasUsed: 21000,
+		GasUsed:           21000,
+		TxHash:            common.HexToHash("0x02"),
+		BlockHash:         blockHash,
+		BlockNumber:       big.NewInt(300),
+		Logs:              []*types.Log{{Address: common.HexToAddress("0x03"), Topics: []common.Hash{}, TxHash: common.HexToHash("0x02")}},
+	}
+	receipt.Bloom = types.CreateBloom(receipt)
+
+	if _, err := db.Receipts(blockHash); err == nil {
+		t.Fatal("receipts should not exist before they are written")
+	}
+
+	db.WriteReceipts(ctx, types.Receipts{receipt}, early)
+	db.WriteReceipts(ctx, types.Receipts{receipt}, late)
+
+	stored, err := db.Receipts(blockHash)
+	if err != nil {
+		t.Fatalf("read receipts: %v", err)
+	}
+	if !stored.TimeFirstSeen.Equal(early) {
+		t.Fatalf("first seen overwritten: %s", stored.TimeFirstSeen)
+	}
+	if len(stored.Receipts) != 1 || stored.Receipts[0].TxHash != receipt.TxHash ||
+		stored.Receipts[0].GasUsed != 21000 || len(stored.Receipts[0].Logs) != 1 {
+		t.Fatalf("unexpected receipts: %+v", stored.Receipts)
+	}
+}
+
+// TestPebbleProducerEvents checks that producer events are returned per
+// producer in time order.
+func TestPebbleProducerEvents(t *testing.T) {
+	ctx := context.Background()
+	db := newTestPebble(t)
+	alice, bob := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+
+	db.WriteProducerEvents(ctx, []ProducerEvent{
+		{Type: ProducerEventMissedSlot, Producer: alice, BlockHash: common.HexToHash("0x02"), BlockNumber: 2, Slots: 1},
+		{Type: ProducerEventLate, Producer: bob, BlockHash: common.HexToHash("0x02"), BlockNumber: 2, Delay: time.Second},
+	}, time.Unix(2000, 0))
+	db.WriteProducerEvents(ctx, []ProducerEvent{
+		{Type: ProducerEventEquivocation, Producer: alice, BlockHash: common.HexToHash("0x01"), BlockNumber: 1, OtherHash: common.HexToHash("0x03")},
+	}, time.Unix(1000, 0))
+
+	events, err := db.ProducerEvents(alice)
+	if err != nil {
+		t.Fatalf("read producer events: %v", err)
+	}
+	if len(events) != 2 || events[0].Type != ProducerEventEquivocation || events[0].OtherHash != common.HexToHash("0x03") ||
+		events[1].Type != ProducerEventMissedSlot || events[1].Slots != 1 || events[1].SensorID != "pebble-test" {
+		t.Fatalf("unexpected producer events: %+v", events)
+	}
+}
+
+// TestPebbleNodeList checks that NodeList returns unique peers, most recent first.
+func TestPebbleNodeList(t *testing.T) {
+	ctx := context.Background()
+	db := newTestPebble(t)
+	first, second := testPeer(t), testPeer(t)
+	hash := common.HexToHash("0x01")
+
+	db.WriteBlockEvents(ctx, first, []BlockAnnouncement{{Hash: hash, Number: 1}}, time.Unix(1000, 0))
+	db.WriteBlockEvents(ctx, second, []BlockAnnouncement{{Hash: hash, Number: 1}}, time.Unix(2000, 0))
+	db.WriteBlockEvents(ctx, first, []BlockAnnouncement{{Hash: hash, Number: 1}}, time.Unix(3000, 0))
+
+	nodes, err := db.NodeList(ctx, 10)
+	if err != nil {
+		t.Fatalf("node list: %v", err)
+	}
+	if len(nodes) != 2 || nodes[0] != first.URLv4() || nodes[1] != second.URLv4() {
+		t.Fatalf("unexpected node list: %v", nodes)
+	}
+
+	nodes, err = db.NodeList(ctx, 1)
+	if err != nil {
+		t.Fatalf("node list: %v", err)
+	}
+	if len(nodes) != 1 {
+		t.Fatalf("want 1 node, got %d", len(nodes))
+	}
+}
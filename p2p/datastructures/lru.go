@@ -19,6 +19,7 @@ type LRU[K comparable, V any] struct {
 	ttl     time.Duration
 	items   map[K]*list.Element
 	list    *list.List
+	onEvict func(K, V)
 }
 
 type entry[K comparable, V any] struct {
@@ -39,6 +40,26 @@ func NewLRU[K comparable, V any](opts LRUOptions) *LRU[K, V] {
 	}
 }
 
+// OnEvict sets a function called with each entry that leaves the cache because
+// it is full, the entry expired or it was removed. It is called with the cache
+// locked, so it must not use the cache.
+func (c *LRU[K, V]) OnEvict(fn func(K, V)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onEvict = fn
+}
+
+// remove deletes an element from the cache. The caller must hold the lock.
+func (c *LRU[K, V]) remove(elem *list.Element) *entry[K, V] {
+	e := elem.Value.(*entry[K, V])
+	c.list.Remove(elem)
+	delete(c.items, e.key)
+	if c.onEvict != nil {
+		c.onEvict(e.key, e.value)
+	}
+	return e
+}
+
 // Add adds or updates a value in the cache.
 func (c *LRU[K, V]) Add(key K, value V) {
 	c.mu.Lock()
@@ -67,11 +88,8 @@ func (c *LRU[K, V]) Add(key K, value V) {
 	c.items[key] = elem
 
 	if c.maxSize > 0 && c.list.Len() > c.maxSize {
-		back := c.list.Back()
-		if back != nil {
-			c.list.Remove(back)
-			e := back.Value.(*entry[K, V])
-			delete(c.items, e.key)
+		if back := c.list.Back(); back != nil {
+			c.remove(back)
 		}
 	}
 }
@@ -90,8 +108,7 @@ func (c *LRU[K, V]) Get(key K) (V, bool) {
 	e := elem.Value.(*entry[K, V])
 
 	if e.expiresAt != nil && time.Now().After(*e.expiresAt) {
-		c.list.Remove(elem)
-		delete(c.items, key)
+		c.remove(elem)
 		var zero V
 		return zero, false
 	}
@@ -246,8 +263,7 @@ func (c *LRU[K, V]) Update(key K, updateFn func(V) V) (existed bool) {
 			return true
 		}
 		// Entry expired, remove it
-		c.list.Remove(elem)
-		delete(c.items, key)
+		c.remove(elem)
 	}
 
 	// Create new entry
@@ -262,11 +278,8 @@ func (c *LRU[K, V]) Update(key K, updateFn func(V) V) (existed bool) {
 
 	// Enforce size limit
 	if c.maxSize > 0 && c.list.Len() > c.maxSize {
-		back := c.list.Back()
-		if back != nil {
-			c.list.Remove(back)
-			e := back.Value.(*entry[K, V])
-			delete(c.items, e.key)
+		if back := c.list.Back(); back != nil {
+			c.remove(back)
 		}
 	}
 
@@ -279,10 +292,7 @@ func (c *LRU[K, V]) Remove(key K) (V, bool) {
 	defer c.mu.Unlock()
 
 	if elem, ok := c.items[key]; ok {
-		e := elem.Value.(*entry[K, V])
-		c.list.Remove(elem)
-		delete(c.items, key)
-		return e.value, true
+		return c.remove(elem).value, true
 	}
 
 	var zero V
@@ -345,8 +355,6 @@ func (c *LRU[K, V]) AddBatch(keys []K, values []V) {
 		if back == nil {
 			break
 		}
-		c.list.Remove(back)
-		e := back.Value.(*entry[K, V])
-		delete(c.items, e.key)
+		c.remove(back)
 	}
 }
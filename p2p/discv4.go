@@ -0,0 +1,273 @@
+package p2p
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/discover/v4wire"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// discv4Expiration is how long sent discovery packets are valid for.
+	discv4Expiration = 20 * time.Second
+	// discv4BondTimeout is how long to wait for a node to answer a ping and to
+	// ping back, which it needs before it answers FINDNODE.
+	discv4BondTimeout = time.Second
+	// discv4ResponseTimeout is how long to wait for more NEIGHBORS packets.
+	discv4ResponseTimeout = 500 * time.Millisecond
+	// discv4BucketSize is the most nodes returned for a FINDNODE query.
+	discv4BucketSize = 16
+)
+
+// Discv4Client queries the routing tables of discv4 nodes with FINDNODE. Unlike
+// the discover package, which only queries nodes as part of lookups, it asks a
+// given node for the nodes it knows, which is what mapping the network needs.
+type Discv4Client struct {
+	conn *net.UDPConn
+	key  *ecdsa.PrivateKey
+	self v4wire.Endpoint
+
+	mu      sync.Mutex
+	pending map[enode.ID]chan v4wire.Packet
+
+	closeCh chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewDiscv4Client creates a client on its own UDP socket. The key must not be
+// one used by another discovery socket, or replies would go to the wrong one.
+func NewDiscv4Client(key *ecdsa.PrivateKey) (*Discv4Client, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Discv4Client{
+		conn:    conn,
+		key:     key,
+		self:    v4wire.NewEndpoint(conn.LocalAddr().(*net.UDPAddr).AddrPort(), 0),
+		pending: make(map[enode.ID]chan v4wire.Packet),
+		closeCh: make(chan struct{}),
+	}
+
+	c.wg.Add(1)
+	go c.readLoop()
+
+	return c, nil
+}
+
+// Close closes the socket and waits for the read loop to stop.
+func (c *Discv4Client) Close() error {
+	close(c.closeCh)
+	err := c.conn.Close()
+	c.wg.Wait()
+	return err
+}
+
+// readLoop answers pings and delivers other packets to pending queries.
+func (c *Discv4Client) readLoop() {
+	defer c.wg.Done()
+
+	buf := make([]byte, 1280)
+	for {
+		n, from, err := c.conn.ReadFromUDPAddrPort(buf)
+		if err != nil {
+			select {
+			case <-c.closeCh:
+				return
+			default:
+			}
+			log.Debug().Err(err).Msg("Failed to read discovery packet")
+			return
+		}
+
+		packet, fromKey, hash, err := v4wire.Decode(buf[:n])
+		if err != nil {
+			log.Trace().Err(err).Str("from", from.String()).Msg("Invalid discovery packet")
+			continue
+		}
+		fromID := fromKey.ID()
+
+		if ping, ok := packet.(*v4wire.Ping); ok {
+			if v4wire.Expired(ping.Expiration) {
+				continue
+			}
+			pong := &v4wire.Pong{
+				To:         v4wire.NewEndpoint(from, 0),
+				ReplyTok:   hash,
+				Expiration: uint64(time.Now().Add(discv4Expiration).Unix()),
+			}
+			if err := c.send(from, pong); err != nil {
+				log.Debug().Err(err).Str("from", from.String()).Msg("Failed to send pong")
+			}
+		}
+
+		c.mu.Lock()
+		ch, ok := c.pending[fromID]
+		c.mu.Unlock()
+		if !ok {
+			continue
+		}
+
+		select {
+		case ch <- packet:
+		default:
+		}
+	}
+}
+
+// send encodes and sends a packet.
+func (c *Discv4Client) send(to netip.AddrPort, packet v4wire.Packet) error {
+	b, _, err := v4wire.Encode(c.key, packet)
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.WriteToUDPAddrPort(b, to)
+	return err
+}
+
+// Neighbors asks the node for the nodes in its routing table closest to each of
+// queries random targets, and returns every distinct node it answered with.
+// Each query returns up to 16 nodes, so more queries sample more of the table.
+func (c *Discv4Client) Neighbors(n *enode.Node, queries int) ([]*enode.Node, error) {
+	addr, ok := n.UDPEndpoint()
+	if !ok {
+		return nil, fmt.Errorf("node %v has no UDP endpoint", n.ID())
+	}
+
+	ch := make(chan v4wire.Packet, 16)
+	c.mu.Lock()
+	if _, ok := c.pending[n.ID()]; ok {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("node %v is already being queried", n.ID())
+	}
+	c.pending[n.ID()] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, n.ID())
+		c.mu.Unlock()
+	}()
+
+	if err := c.bond(addr, n, ch); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[enode.ID]struct{})
+	var nodes []*enode.Node
+	for range queries {
+		var target v4wire.Pubkey
+		if _, err := rand.Read(target[:]); err != nil {
+			return nil, err
+		}
+
+		found, err := c.findnode(addr, target, ch)
+		if err != nil {
+			return nodes, err
+		}
+		for _, node := range found {
+			if _, ok := seen[node.ID()]; ok {
+				continue
+			}
+			seen[node.ID()] = struct{}{}
+			nodes = append(nodes, node)
+		}
+	}
+
+	return nodes, nil
+}
+
+// bond pings the node and waits for its pong and for it to ping back, so it
+// holds the endpoint proof it needs to answer FINDNODE.
+func (c *Discv4Client) bond(addr netip.AddrPort, n *enode.Node, ch <-chan v4wire.Packet) error {
+	ping := &v4wire.Ping{
+		Version:    4,
+		From:       c.self,
+		To:         v4wire.NewEndpoint(addr, uint16(n.TCP())),
+		Expiration: uint64(time.Now().Add(discv4Expiration).Unix()),
+	}
+	if err := c.send(addr, ping); err != nil {
+		return err
+	}
+
+	var pong, pinged bool
+	timeout := time.NewTimer(discv4BondTimeout)
+	defer timeout.Stop()
+
+	for !pong || !pinged {
+		select {
+		case packet := <-ch:
+			switch packet.(type) {
+			case *v4wire.Pong:
+				pong = true
+			case *v4wire.Ping:
+				pinged = true
+			}
+		case <-timeout.C:
+			// The node may not ping back if it still holds a recent endpoint
+			// proof, so only a missing pong is an error.
+			if !pong {
+				return errors.New("ping timed out")
+			}
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// findnode sends a FINDNODE query and collects the NEIGHBORS replies.
+func (c *Discv4Client) findnode(addr netip.AddrPort, target v4wire.Pubkey, ch <-chan v4wire.Packet) ([]*enode.Node, error) {
+	req := &v4wire.Findnode{
+		Target:     target,
+		Expiration: uint64(time.Now().Add(discv4Expiration).Unix()),
+	}
+	if err := c.send(addr, req); err != nil {
+		return nil, err
+	}
+
+	var (
+		nodes    []*enode.Node
+		received int
+		replied  bool
+	)
+	timeout := time.NewTimer(discv4ResponseTimeout)
+	defer timeout.Stop()
+
+	for received < discv4BucketSize {
+		select {
+		case packet := <-ch:
+			neighbors, ok := packet.(*v4wire.Neighbors)
+			if !ok {
+				continue
+			}
+			replied = true
+			for _, rn := range neighbors.Nodes {
+				received++
+				key, err := v4wire.DecodePubkey(crypto.S256(), rn.ID)
+				if err != nil {
+					continue
+				}
+				nodes = append(nodes, enode.NewV4(key, rn.IP, int(rn.TCP), int(rn.UDP)))
+			}
+			timeout.Reset(discv4ResponseTimeout)
+		case <-timeout.C:
+			if !replied {
+				return nil, errors.New("findnode timed out")
+			}
+			return nodes, nil
+		}
+	}
+
+	return nodes, nil
+}
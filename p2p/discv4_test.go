@@ -0,0 +1,93 @@
+package p2p
+
+import (
+	"net"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/discover/v4wire"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// serveDiscv4 answers discv4 pings and FINDNODE queries with the neighbors, in
+// packets of at most v4wire.MaxNeighbors nodes like geth.
+func serveDiscv4(t *testing.T, neighbors []v4wire.Node) *enode.Node {
+	key, _ := crypto.GenerateKey()
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	send := func(to *net.UDPAddr, packet v4wire.Packet) {
+		b, _, err := v4wire.Encode(key, packet)
+		if err != nil {
+			t.Errorf("encode: %v", err)
+			return
+		}
+		_, _ = conn.WriteToUDP(b, to)
+	}
+
+	go func() {
+		buf := make([]byte, 1280)
+		for {
+			n, from, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			packet, _, hash, err := v4wire.Decode(buf[:n])
+			if err != nil {
+				continue
+			}
+
+			switch packet.(type) {
+			case *v4wire.Ping:
+				send(from, &v4wire.Pong{ReplyTok: hash, Expiration: ^uint64(0)})
+				send(from, &v4wire.Ping{Version: 4, Expiration: ^uint64(0)})
+			case *v4wire.Findnode:
+				for i := 0; i < len(neighbors); i += v4wire.MaxNeighbors {
+					chunk := neighbors[i:min(i+v4wire.MaxNeighbors, len(neighbors))]
+					send(from, &v4wire.Neighbors{Nodes: chunk, Expiration: ^uint64(0)})
+				}
+			}
+		}
+	}()
+
+	addr := conn.LocalAddr().(*net.UDPAddr)
+	return enode.NewV4(&key.PublicKey, addr.IP, addr.Port, addr.Port)
+}
+
+func TestDiscv4ClientNeighbors(t *testing.T) {
+	var neighbors []v4wire.Node
+	for i := range 14 {
+		key, _ := crypto.GenerateKey()
+		neighbors = append(neighbors, v4wire.Node{
+			IP:  net.IPv4(10, 0, 0, byte(i+1)),
+			UDP: 30303,
+			TCP: 30303,
+			ID:  v4wire.EncodePubkey(&key.PublicKey),
+		})
+	}
+	node := serveDiscv4(t, neighbors)
+
+	key, _ := crypto.GenerateKey()
+	c, err := NewDiscv4Client(key)
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	// Every query returns the same nodes, so they are only counted once.
+	nodes, err := c.Neighbors(node, 2)
+	if err != nil {
+		t.Fatalf("neighbors: %v", err)
+	}
+	if len(nodes) != len(neighbors) {
+		t.Fatalf("want %d nodes, got %d", len(neighbors), len(nodes))
+	}
+	for i, n := range nodes {
+		if n.ID() != neighbors[i].ID.ID() || !n.IP().Equal(neighbors[i].IP) {
+			t.Fatalf("node %d: want %v got %v", i, neighbors[i].ID.ID(), n.ID())
+		}
+	}
+}
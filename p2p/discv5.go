@@ -0,0 +1,259 @@
+package p2p
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/rs/zerolog/log"
+)
+
+// EthENREntry is the "eth" entry of a node record, which advertises the eth
+// protocol and the node's EIP-2124 fork ID on the discovery DHT. Consensus
+// clients share the discv5 DHT with execution clients but don't have it.
+type EthENREntry struct {
+	ForkID forkid.ID
+
+	// Ignore additional fields, for forward compatibility.
+	Rest []rlp.RawValue `rlp:"tail"`
+}
+
+// ENRKey implements enr.Entry.
+func (e EthENREntry) ENRKey() string {
+	return "eth"
+}
+
+// NodeForkID returns the fork ID the node advertises in its record, and false
+// if the record has no eth entry.
+func NodeForkID(n *enode.Node) (forkid.ID, bool) {
+	var entry EthENREntry
+	if err := n.Load(&entry); err != nil {
+		return forkid.ID{}, false
+	}
+	return entry.ForkID, true
+}
+
+// TrackENRForkID keeps the fork ID in the local node record's eth entry at the
+// head, the way status messages compute it, so peers filtering discovered
+// nodes by fork ID keep accepting the node after a fork activates. The record
+// is only re-signed when the fork ID changes. It returns when heads is closed.
+func TrackENRForkID(ln *enode.LocalNode, forks *ForkSchedule, heads <-chan *types.Header) {
+	current, _ := NodeForkID(ln.Node())
+	for head := range heads {
+		id := forks.ID(head.Number.Uint64(), head.Time)
+		if id == current {
+			continue
+		}
+		log.Info().
+			Str("old", hex.EncodeToString(current.Hash[:])).
+			Str("new", hex.EncodeToString(id.Hash[:])).
+			Uint64("head", head.Number.Uint64()).
+			Msg("Updating fork ID in node record")
+		ln.Set(EthENREntry{ForkID: id})
+		current = id
+	}
+}
+
+// EthNodeFilter returns a filter accepting the nodes that advertise the eth
+// protocol in their record. With a fork schedule, the advertised fork ID must
+// also be compatible with it at the head block, so nodes of other chains are
+// dropped before they are dialed.
+func EthNodeFilter(forks *ForkSchedule, head uint64) func(*enode.Node) bool {
+	return func(n *enode.Node) bool {
+		id, ok := NodeForkID(n)
+		if !ok {
+			return false
+		}
+		if forks == nil {
+			return true
+		}
+		return forks.Classify(id, head, uint64(time.Now().Unix())) == ForkCompatible
+	}
+}
+
+// Discv5Probe is the result of probing a node over discv5: a PING, and a
+// FINDNODE for its own record.
+type Discv5Probe struct {
+	// Latency is the PING round trip in milliseconds.
+	Latency int64 `json:"latency_ms,omitempty"`
+	// Endpoint is our address as the node saw it.
+	Endpoint string `json:"endpoint,omitempty"`
+	ENRSeq   uint64 `json:"enr_seq,omitempty"`
+	ENR      string `json:"enr,omitempty"`
+	// ForkID is the fork ID in the record's eth entry, and ForkStatus its
+	// compatibility with the fork schedule, if one was given.
+	ForkID     *forkid.ID `json:"fork_id,omitempty"`
+	ForkStatus string     `json:"fork_status,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// ProbeDiscv5 pings the node over discv5 and requests its record. With a fork
+// schedule, the fork ID in the record is classified at the head block.
+func ProbeDiscv5(disc *discover.UDPv5, n *enode.Node, forks *ForkSchedule, head uint64) *Discv5Probe {
+	var probe Discv5Probe
+
+	start := time.Now()
+	pong, err := disc.Ping(n)
+	if err != nil {
+		probe.Error = err.Error()
+		return &probe
+	}
+	probe.Latency = time.Since(start).Milliseconds()
+	probe.Endpoint = (&net.UDPAddr{IP: pong.ToIP, Port: int(pong.ToPort)}).String()
+	probe.ENRSeq = pong.ENRSeq
+
+	record, err := disc.RequestENR(n)
+	if err != nil {
+		probe.Error = err.Error()
+		return &probe
+	}
+	probe.ENR = record.String()
+	if id, ok := NodeForkID(record); ok {
+		probe.ForkID = &id
+		if forks != nil {
+			probe.ForkStatus = forks.Classify(id, head, uint64(time.Now().Unix()))
+		}
+	}
+
+	return &probe
+}
+
+// DiscoveryOptions configures ListenDiscovery.
+type DiscoveryOptions struct {
+	PrivateKey *ecdsa.PrivateKey
+	V4         bool
+	V5         bool
+	// Bootnodes are used by discv4, and by discv5 when BootnodesV5 is empty.
+	Bootnodes   []*enode.Node
+	BootnodesV5 []*enode.Node
+}
+
+// Discovery runs discv4, discv5 or both. Like a geth node, both share one UDP
+// socket, with discv5 reading the packets discv4 can't decode.
+type Discovery struct {
+	V4 *discover.UDPv4
+	V5 *discover.UDPv5
+}
+
+// ListenDiscovery starts the enabled discovery protocols on a new socket, see
+// Listen.
+func ListenDiscovery(ln *enode.LocalNode, opts DiscoveryOptions) (*Discovery, error) {
+	if !opts.V4 && !opts.V5 {
+		return nil, errors.New("at least one of discv4 and discv5 must be enabled")
+	}
+
+	socket, err := Listen(ln)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		d         Discovery
+		conn      discover.UDPConn = socket
+		unhandled chan discover.ReadPacket
+	)
+	if opts.V4 && opts.V5 {
+		unhandled = make(chan discover.ReadPacket, 100)
+		conn = &sharedUDPConn{UDPConn: socket, unhandled: unhandled}
+	}
+
+	if opts.V4 {
+		d.V4, err = discover.ListenV4(socket, ln, discover.Config{
+			PrivateKey: opts.PrivateKey,
+			Bootnodes:  opts.Bootnodes,
+			Unhandled:  unhandled,
+		})
+		if err != nil {
+			socket.Close()
+			return nil, err
+		}
+	}
+
+	if opts.V5 {
+		bootnodes := opts.BootnodesV5
+		if len(bootnodes) == 0 {
+			bootnodes = opts.Bootnodes
+		}
+		d.V5, err = discover.ListenV5(conn, ln, discover.Config{
+			PrivateKey: opts.PrivateKey,
+			Bootnodes:  bootnodes,
+		})
+		if err != nil {
+			if d.V4 != nil {
+				d.V4.Close()
+			} else {
+				socket.Close()
+			}
+			return nil, err
+		}
+	}
+
+	return &d, nil
+}
+
+// RandomNodes returns an iterator over random nodes of each enabled protocol.
+// The discv5 iterator is filtered with filter when it's not nil, since the
+// discv5 DHT is shared with consensus clients.
+func (d *Discovery) RandomNodes(filter func(*enode.Node) bool) []enode.Iterator {
+	var iters []enode.Iterator
+	if d.V4 != nil {
+		iters = append(iters, d.V4.RandomNodes())
+	}
+	if d.V5 != nil {
+		var it enode.Iterator = d.V5.RandomNodes()
+		if filter != nil {
+			it = enode.Filter(it, filter)
+		}
+		iters = append(iters, it)
+	}
+	return iters
+}
+
+// RequestENR requests the node's record over discv4 if it's enabled, and over
+// discv5 otherwise.
+func (d *Discovery) RequestENR(n *enode.Node) (*enode.Node, error) {
+	if d.V4 != nil {
+		return d.V4.RequestENR(n)
+	}
+	return d.V5.RequestENR(n)
+}
+
+// Close stops the enabled protocols. discv4 is closed first, since that closes
+// the socket and the channel discv5 reads from when they share it.
+func (d *Discovery) Close() {
+	if d.V4 != nil {
+		d.V4.Close()
+	}
+	if d.V5 != nil {
+		d.V5.Close()
+	}
+}
+
+// sharedUDPConn lets discv5 read the packets discv4 passes on as unhandled,
+// while writing to the socket directly.
+type sharedUDPConn struct {
+	*net.UDPConn
+	unhandled chan discover.ReadPacket
+}
+
+// ReadFromUDPAddrPort implements discover.UDPConn.
+func (s *sharedUDPConn) ReadFromUDPAddrPort(b []byte) (int, netip.AddrPort, error) {
+	packet, ok := <-s.unhandled
+	if !ok {
+		return 0, netip.AddrPort{}, errors.New("connection was closed")
+	}
+	return copy(b, packet.Data), packet.Addr, nil
+}
+
+// Close implements discover.UDPConn. The socket is closed by discv4.
+func (s *sharedUDPConn) Close() error {
+	return nil
+}
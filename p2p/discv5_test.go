@@ -0,0 +1,130 @@
+package p2p
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// listenTestDiscovery starts discovery on a fresh key and local node, with the
+// eth entry set when id is not nil.
+func listenTestDiscovery(t *testing.T, opts DiscoveryOptions, id *forkid.ID) (*Discovery, *enode.LocalNode) {
+	t.Helper()
+	opts.PrivateKey, _ = crypto.GenerateKey()
+	db, err := enode.OpenDB("")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(db.Close)
+
+	ln := enode.NewLocalNode(db, opts.PrivateKey)
+	if id != nil {
+		ln.Set(EthENREntry{ForkID: *id})
+	}
+	d, err := ListenDiscovery(ln, opts)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(d.Close)
+	return d, ln
+}
+
+func TestEthNodeFilter(t *testing.T) {
+	s := NewForkSchedule([32]byte{1}, 0, []uint64{100, 200}, nil)
+	current := s.ID(300, 0)
+
+	_, eth := listenTestDiscovery(t, DiscoveryOptions{V5: true}, &current)
+	_, other := listenTestDiscovery(t, DiscoveryOptions{V5: true}, &forkid.ID{Hash: [4]byte{1, 2, 3, 4}})
+	_, consensus := listenTestDiscovery(t, DiscoveryOptions{V5: true}, nil)
+
+	if id, ok := NodeForkID(eth.Node()); !ok || id != current {
+		t.Fatalf("unexpected fork ID %v (found %v)", id, ok)
+	}
+	if _, ok := NodeForkID(consensus.Node()); ok {
+		t.Fatal("node without an eth entry should have no fork ID")
+	}
+
+	tests := []struct {
+		name   string
+		forks  *ForkSchedule
+		node   *enode.Node
+		accept bool
+	}{
+		{"eth any chain", nil, other.Node(), true},
+		{"no eth entry", nil, consensus.Node(), false},
+		{"compatible", s, eth.Node(), true},
+		{"other chain", s, other.Node(), false},
+		{"no eth entry with schedule", s, consensus.Node(), false},
+	}
+	for _, tt := range tests {
+		if got := EthNodeFilter(tt.forks, 300)(tt.node); got != tt.accept {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.accept)
+		}
+	}
+}
+
+// TestDiscoveryV5 pings a discv5 node and requests its record, with the
+// listener sharing its socket with discv4.
+func TestDiscoveryV5(t *testing.T) {
+	id := forkid.ID{Hash: [4]byte{0x22, 0xd5, 0x23, 0xb2}}
+	_, remote := listenTestDiscovery(t, DiscoveryOptions{V5: true}, &id)
+	local, _ := listenTestDiscovery(t, DiscoveryOptions{V4: true, V5: true}, nil)
+
+	pong, err := local.V5.Ping(remote.Node())
+	if err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	if pong.ENRSeq != remote.Node().Seq() {
+		t.Fatalf("unexpected ENR seq %d, want %d", pong.ENRSeq, remote.Node().Seq())
+	}
+
+	n, err := local.V5.RequestENR(remote.Node())
+	if err != nil {
+		t.Fatalf("request ENR: %v", err)
+	}
+	if got, ok := NodeForkID(n); !ok || got != id {
+		t.Fatalf("unexpected fork ID %v (found %v)", got, ok)
+	}
+
+	probe := ProbeDiscv5(local.V5, remote.Node(), nil, 0)
+	if probe.Error != "" || probe.ForkID == nil || *probe.ForkID != id || probe.ENR != n.String() {
+		t.Fatalf("unexpected probe: %+v", probe)
+	}
+
+	if iters := local.RandomNodes(EthNodeFilter(nil, 0)); len(iters) != 2 {
+		t.Fatalf("expected an iterator per protocol, got %d", len(iters))
+	}
+}
+
+func TestTrackENRForkID(t *testing.T) {
+	s := NewForkSchedule([32]byte{1}, 0, []uint64{100, 200}, nil)
+	initial := s.ID(50, 0)
+	_, ln := listenTestDiscovery(t, DiscoveryOptions{V5: true}, &initial)
+	seq := ln.Node().Seq()
+
+	heads := make(chan *types.Header)
+	done := make(chan struct{})
+	go func() {
+		TrackENRForkID(ln, s, heads)
+		close(done)
+	}()
+
+	// Heads within the same fork leave the record alone.
+	heads <- &types.Header{Number: big.NewInt(60)}
+	heads <- &types.Header{Number: big.NewInt(150)}
+	heads <- &types.Header{Number: big.NewInt(160)}
+	close(heads)
+	<-done
+
+	id, ok := NodeForkID(ln.Node())
+	if !ok || id != s.ID(160, 0) {
+		t.Fatalf("want fork ID %v, got %v", s.ID(160, 0), id)
+	}
+	if got := ln.Node().Seq(); got != seq+1 {
+		t.Fatalf("record should be updated once, seq %d -> %d", seq, got)
+	}
+}
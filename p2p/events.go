@@ -0,0 +1,117 @@
+package p2p
+
+import (
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// subscriptionBuffer is how many events a subscriber can fall behind by before
+// events are dropped for it.
+const subscriptionBuffer = 256
+
+// feed fans out events to subscribers. Sends never block, so a slow subscriber
+// misses events instead of stalling the peer connections that produce them.
+type feed[T any] struct {
+	mu   sync.RWMutex
+	subs map[chan T]struct{}
+}
+
+// subscribe returns a channel receiving every event sent after the call, and a
+// function that unsubscribes and closes the channel.
+func (f *feed[T]) subscribe() (<-chan T, func()) {
+	ch := make(chan T, subscriptionBuffer)
+
+	f.mu.Lock()
+	if f.subs == nil {
+		f.subs = make(map[chan T]struct{})
+	}
+	f.subs[ch] = struct{}{}
+	f.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			f.mu.Lock()
+			delete(f.subs, ch)
+			f.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// send delivers an event to every subscriber with room in its buffer and
+// returns the number of subscribers it was dropped for.
+func (f *feed[T]) send(event T) int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var dropped int
+	for ch := range f.subs {
+		select {
+		case ch <- event:
+		default:
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// SubscribeHeads returns a channel receiving the header of each block that is
+// higher than any seen before, as soon as the sensor first sees it. Call the
+// returned function to unsubscribe.
+func (c *Conns) SubscribeHeads() (<-chan *types.Header, func()) {
+	return c.headsFeed.subscribe()
+}
+
+// SubscribeLogs returns a channel receiving the logs of each block once its
+// receipts are fetched and verified. Logs are only sent when receipts are
+// fetched. Call the returned function to unsubscribe.
+func (c *Conns) SubscribeLogs() (<-chan []*types.Log, func()) {
+	return c.logsFeed.subscribe()
+}
+
+// SubscribeTxs returns a channel receiving transactions the first time any
+// peer sends them. Call the returned function to unsubscribe.
+func (c *Conns) SubscribeTxs() (<-chan []*types.Transaction, func()) {
+	return c.txsFeed.subscribe()
+}
+
+// notifyHead sends a header to head subscribers if it is higher than every
+// header sent before.
+func (c *Conns) notifyHead(header *types.Header) {
+	higher := c.notifiedHead.Update(func(current *big.Int) (*big.Int, bool) {
+		if current != nil && header.Number.Cmp(current) <= 0 {
+			return current, false
+		}
+		return header.Number, true
+	})
+	if higher {
+		c.countDropped("heads", c.headsFeed.send(header))
+	}
+}
+
+// notifyLogs sends the logs of verified receipts to log subscribers.
+func (c *Conns) notifyLogs(receipts types.Receipts) {
+	var logs []*types.Log
+	for _, r := range receipts {
+		logs = append(logs, r.Logs...)
+	}
+	if len(logs) > 0 {
+		c.countDropped("logs", c.logsFeed.send(logs))
+	}
+}
+
+// notifyTxs sends newly seen transactions to transaction subscribers.
+func (c *Conns) notifyTxs(txs []*types.Transaction) {
+	if len(txs) > 0 {
+		c.countDropped("txs", c.txsFeed.send(txs))
+	}
+}
+
+func (c *Conns) countDropped(feed string, dropped int) {
+	if dropped > 0 {
+		c.metrics.dropped.WithLabelValues(feed).Add(float64(dropped))
+	}
+}
@@ -0,0 +1,60 @@
+package p2p
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// TestFeedDropsForSlowSubscribers checks that sends don't block once a
+// subscriber's buffer is full and that unsubscribing closes the channel.
+func TestFeedDropsForSlowSubscribers(t *testing.T) {
+	var f feed[int]
+	ch, unsub := f.subscribe()
+
+	for i := range subscriptionBuffer {
+		if dropped := f.send(i); dropped != 0 {
+			t.Fatalf("send %d: dropped for %d subscribers", i, dropped)
+		}
+	}
+	if dropped := f.send(subscriptionBuffer); dropped != 1 {
+		t.Fatalf("full buffer: dropped for %d subscribers, want 1", dropped)
+	}
+
+	unsub()
+	unsub()
+	var received int
+	for range ch {
+		received++
+	}
+	if received != subscriptionBuffer {
+		t.Fatalf("received %d events, want %d", received, subscriptionBuffer)
+	}
+	if dropped := f.send(0); dropped != 0 {
+		t.Fatalf("send after unsubscribe: dropped for %d subscribers", dropped)
+	}
+}
+
+// TestNotifyHeadOnlyHigher checks that heads are only sent when higher than
+// every head sent before.
+func TestNotifyHeadOnlyHigher(t *testing.T) {
+	c := &Conns{}
+	heads, unsub := c.SubscribeHeads()
+	defer unsub()
+
+	for _, n := range []int64{10, 9, 10, 12, 11} {
+		c.notifyHead(&types.Header{Number: big.NewInt(n)})
+	}
+
+	for _, want := range []uint64{10, 12} {
+		if got := (<-heads).Number.Uint64(); got != want {
+			t.Fatalf("got head %d, want %d", got, want)
+		}
+	}
+	select {
+	case h := <-heads:
+		t.Fatalf("unexpected head %d", h.Number.Uint64())
+	default:
+	}
+}
@@ -0,0 +1,290 @@
+package p2p
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Fork compatibility of a peer's fork ID with the local fork schedule.
+const (
+	// ForkCompatible peers are on the local fork, on an earlier one while
+	// announcing the fork that follows it, as if syncing, or on a later fork
+	// of the schedule, as if the local node were syncing.
+	ForkCompatible = "compatible"
+	// ForkStale peers are on an earlier fork and do not know the next one.
+	ForkStale = "stale"
+	// ForkFuture peers are on the local fork but announce a next fork the
+	// local head has passed without forking.
+	ForkFuture = "future"
+	// ForkUnknown peers advertise a fork hash that is not in the schedule, such
+	// as that of another chain.
+	ForkUnknown = "unknown"
+)
+
+// Fork is a point in a chain's fork schedule and the fork ID nodes advertise
+// once it is active. Exactly one of Block and Time is set, except for the
+// genesis entry where both are zero.
+type Fork struct {
+	Block uint64
+	Time  uint64
+	ID    forkid.ID
+}
+
+// activation returns the block number or time the fork activates at.
+func (f Fork) activation() uint64 {
+	return max(f.Block, f.Time)
+}
+
+// ForkSchedule is the sequence of EIP-2124 fork IDs of a chain, starting at
+// genesis.
+type ForkSchedule struct {
+	Genesis common.Hash
+	Forks   []Fork
+}
+
+// NewForkSchedule computes the fork schedule of a chain from its genesis hash
+// and the block numbers and times its forks activate at. Block forks at genesis
+// and time forks at or before the genesis time are part of the genesis rules,
+// so they are skipped like in go-ethereum.
+func NewForkSchedule(genesis common.Hash, genesisTime uint64, blocks, times []uint64) *ForkSchedule {
+	blocks = slices.Compact(slices.Sorted(slices.Values(blocks)))
+	times = slices.Compact(slices.Sorted(slices.Values(times)))
+	blocks = slices.DeleteFunc(blocks, func(b uint64) bool { return b == 0 })
+	times = slices.DeleteFunc(times, func(t uint64) bool { return t <= genesisTime })
+
+	s := &ForkSchedule{Genesis: genesis}
+	hash := crc32.ChecksumIEEE(genesis[:])
+	s.Forks = append(s.Forks, Fork{})
+
+	add := func(fork Fork) {
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], fork.activation())
+		hash = crc32.Update(hash, crc32.IEEETable, b[:])
+		fork.ID.Hash = checksum(hash)
+		s.Forks = append(s.Forks, fork)
+	}
+	for _, block := range blocks {
+		add(Fork{Block: block})
+	}
+	for _, time := range times {
+		add(Fork{Time: time})
+	}
+
+	s.Forks[0].ID.Hash = checksum(crc32.ChecksumIEEE(genesis[:]))
+	for i := range len(s.Forks) - 1 {
+		s.Forks[i].ID.Next = s.Forks[i+1].activation()
+	}
+
+	return s
+}
+
+func checksum(hash uint32) [4]byte {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], hash)
+	return b
+}
+
+// configForks gathers the fork block numbers and times from a chain config in
+// its JSON form, where forks are the fields ending in Block and Time. This is
+// what go-ethereum does on its own config type, and also picks up the block
+// based forks of Bor configs, which go-ethereum does not know.
+func configForks(config map[string]json.RawMessage) (blocks, times []uint64, err error) {
+	for key, value := range config {
+		isTime := strings.HasSuffix(key, "Time")
+		if !isTime && !strings.HasSuffix(key, "Block") {
+			continue
+		}
+
+		var n json.Number
+		if err := json.Unmarshal(value, &n); err != nil || n == "" {
+			// Not a fork, such as a null or nested field.
+			continue
+		}
+		fork, err := strconv.ParseUint(n.String(), 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid fork %v: %w", key, err)
+		}
+
+		if isTime {
+			times = append(times, fork)
+		} else {
+			blocks = append(blocks, fork)
+		}
+	}
+	return blocks, times, nil
+}
+
+// scheduleFromConfig computes the fork schedule of a go-ethereum chain config.
+func scheduleFromConfig(config *params.ChainConfig, genesis common.Hash, genesisTime uint64) *ForkSchedule {
+	b, err := json.Marshal(config)
+	if err != nil {
+		panic(err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		panic(err)
+	}
+	blocks, times, err := configForks(fields)
+	if err != nil {
+		panic(err)
+	}
+	return NewForkSchedule(genesis, genesisTime, blocks, times)
+}
+
+// forkNetworks are the named networks whose fork schedules are built in.
+var forkNetworks = map[string]func() *ForkSchedule{
+	"mainnet": func() *ForkSchedule {
+		return scheduleFromConfig(params.MainnetChainConfig, params.MainnetGenesisHash, core.DefaultGenesisBlock().Timestamp)
+	},
+	"sepolia": func() *ForkSchedule {
+		return scheduleFromConfig(params.SepoliaChainConfig, params.SepoliaGenesisHash, core.DefaultSepoliaGenesisBlock().Timestamp)
+	},
+	"holesky": func() *ForkSchedule {
+		return scheduleFromConfig(params.HoleskyChainConfig, params.HoleskyGenesisHash, core.DefaultHoleskyGenesisBlock().Timestamp)
+	},
+	"hoodi": func() *ForkSchedule {
+		return scheduleFromConfig(params.HoodiChainConfig, params.HoodiGenesisHash, core.DefaultHoodiGenesisBlock().Timestamp)
+	},
+	// Polygon PoS mainnet. Bor schedules its forks by block, up to Prague
+	// (Bhilai) at 73440256.
+	"polygon": func() *ForkSchedule {
+		genesis := common.HexToHash("0xa9c28ce2141b56c474f1dc504bee9b01eb1bd7d1a507580d5519d4437a97de1b")
+		blocks := []uint64{3395000, 14750000, 23850000, 50523000, 54876000, 73440256}
+		return NewForkSchedule(genesis, 0, blocks, nil)
+	},
+}
+
+// ForkNetworks returns the names of the built-in fork schedules.
+func ForkNetworks() []string {
+	names := make([]string, 0, len(forkNetworks))
+	for name := range forkNetworks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ReadForkSchedule reads a genesis JSON file and computes its fork schedule.
+func ReadForkSchedule(file string) (*ForkSchedule, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var genesis core.Genesis
+	if err := json.Unmarshal(b, &genesis); err != nil {
+		return nil, fmt.Errorf("failed to parse genesis: %w", err)
+	}
+	if genesis.Config == nil {
+		return nil, fmt.Errorf("genesis %v has no chain config", file)
+	}
+
+	// The config is parsed again as raw fields, since go-ethereum drops the
+	// fields of other clients such as the Bor fork blocks.
+	var raw struct {
+		Config map[string]json.RawMessage `json:"config"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse genesis: %w", err)
+	}
+	blocks, times, err := configForks(raw.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewForkSchedule(genesis.ToBlock().Hash(), genesis.Timestamp, blocks, times), nil
+}
+
+// LoadForkSchedule returns the fork schedule of the named network or of the
+// genesis file, whichever is set, or nil if neither is.
+func LoadForkSchedule(network, genesisFile string) (*ForkSchedule, error) {
+	switch {
+	case network != "" && genesisFile != "":
+		return nil, fmt.Errorf("only one of a network and a genesis file can be set")
+	case genesisFile != "":
+		return ReadForkSchedule(genesisFile)
+	case network != "":
+		schedule, ok := forkNetworks[network]
+		if !ok {
+			return nil, fmt.Errorf("unknown network %q, must be one of %v", network, ForkNetworks())
+		}
+		return schedule(), nil
+	default:
+		return nil, nil
+	}
+}
+
+// ID returns the fork ID of the chain at the given head block number and time.
+func (s *ForkSchedule) ID(head, time uint64) forkid.ID {
+	return s.Forks[s.active(head, time)].ID
+}
+
+// active returns the index of the last fork passed at the head and time.
+func (s *ForkSchedule) active(head, time uint64) int {
+	i := 0
+	for i+1 < len(s.Forks) {
+		next := s.Forks[i+1]
+		if (next.Time == 0 && next.Block > head) || (next.Time > 0 && next.Time > time) {
+			break
+		}
+		i++
+	}
+	return i
+}
+
+// forkTimestampThreshold is the value above which a fork activation is a time
+// rather than a block number, as in go-ethereum.
+const forkTimestampThreshold = 1438269973
+
+// forkPassed returns whether a fork activation is passed at the head and time.
+func forkPassed(fork, head, time uint64) bool {
+	if fork > forkTimestampThreshold {
+		return time >= fork
+	}
+	return head >= fork
+}
+
+// Index returns the position of the fork with the given hash in the schedule,
+// or -1 if the hash is not part of it.
+func (s *ForkSchedule) Index(hash [4]byte) int {
+	return slices.IndexFunc(s.Forks, func(f Fork) bool { return f.ID.Hash == hash })
+}
+
+// Classify returns the compatibility of a peer's fork ID with the chain at the
+// given head block number and time, following the EIP-2124 validation rules.
+func (s *ForkSchedule) Classify(id forkid.ID, head, time uint64) string {
+	local, remote := s.active(head, time), s.Index(id.Hash)
+
+	switch {
+	case remote < 0:
+		return ForkUnknown
+	case remote == local:
+		// A remote next fork the local head passed without forking means the
+		// peer knows a fork the local schedule does not.
+		if id.Next > 0 && forkPassed(id.Next, head, time) {
+			return ForkFuture
+		}
+		return ForkCompatible
+	case remote < local:
+		// Peers that are syncing announce the fork that follows theirs.
+		if id.Next == s.Forks[remote+1].activation() {
+			return ForkCompatible
+		}
+		return ForkStale
+	default:
+		// The local node is behind on a schedule the peer agrees with.
+		return ForkCompatible
+	}
+}
@@ -0,0 +1,83 @@
+package p2p
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/forkid"
+)
+
+func TestForkScheduleMainnet(t *testing.T) {
+	s, err := LoadForkSchedule("mainnet", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fork hashes from EIP-2124 and the go-ethereum forkid tests.
+	want := []struct {
+		block uint64
+		hash  [4]byte
+	}{
+		{0, [4]byte{0xfc, 0x64, 0xec, 0x04}},
+		{1150000, [4]byte{0x97, 0xc2, 0xc3, 0x4c}},
+		{1920000, [4]byte{0x91, 0xd1, 0xf9, 0x48}},
+		{2463000, [4]byte{0x7a, 0x64, 0xda, 0x13}},
+	}
+	for i, w := range want {
+		if f := s.Forks[i]; f.Block != w.block || f.ID.Hash != w.hash {
+			t.Fatalf("fork %d: want block %d hash %x, got %+v", i, w.block, w.hash, f)
+		}
+	}
+
+	// Shanghai and Cancun from the go-ethereum forkid tests.
+	if id := s.ID(20000000, 1681338455); id != (forkid.ID{Hash: [4]byte{0xdc, 0xe9, 0x6c, 0x2d}, Next: 1710338135}) {
+		t.Fatalf("unexpected shanghai ID %+v", id)
+	}
+	if id := s.ID(20000000, 1710338135); id.Hash != [4]byte{0x9f, 0x3d, 0x22, 0x54} {
+		t.Fatalf("unexpected cancun ID %+v", id)
+	}
+
+	last := s.Forks[len(s.Forks)-1]
+	if last.Time == 0 || last.ID.Next != 0 {
+		t.Fatalf("want the last fork to be a time fork with no next, got %+v", last)
+	}
+	if i := s.Index([4]byte{1, 2, 3, 4}); i != -1 {
+		t.Fatalf("want unknown hash at -1, got %d", i)
+	}
+}
+
+func TestForkSchedulePolygon(t *testing.T) {
+	s, err := LoadForkSchedule("polygon", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The fork ID of Polygon mainnet since the Bhilai hard fork.
+	if id := s.ID(75000000, 0); id != (forkid.ID{Hash: [4]byte{0x22, 0xd5, 0x23, 0xb2}}) {
+		t.Fatalf("unexpected fork ID %+v", id)
+	}
+}
+
+func TestForkScheduleClassify(t *testing.T) {
+	s := NewForkSchedule([32]byte{1}, 0, []uint64{100, 200}, nil)
+	genesis, first, second := s.Forks[0].ID, s.Forks[1].ID, s.Forks[2].ID
+
+	tests := []struct {
+		name string
+		id   forkid.ID
+		head uint64
+		want string
+	}{
+		{"same fork", first, 150, ForkCompatible},
+		{"same fork other next", forkid.ID{Hash: first.Hash, Next: 300}, 150, ForkCompatible},
+		{"next passed locally", forkid.ID{Hash: first.Hash, Next: 120}, 150, ForkFuture},
+		{"syncing", genesis, 150, ForkCompatible},
+		{"stale", forkid.ID{Hash: genesis.Hash}, 150, ForkStale},
+		{"ahead", second, 150, ForkCompatible},
+		{"unknown", forkid.ID{Hash: [4]byte{1, 2, 3, 4}}, 150, ForkUnknown},
+	}
+	for _, tt := range tests {
+		if got := s.Classify(tt.id, tt.head, 0); got != tt.want {
+			t.Errorf("%s: want %s got %s", tt.name, tt.want, got)
+		}
+	}
+}
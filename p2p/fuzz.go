@@ -0,0 +1,706 @@
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	ethp2p "github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// fuzzMaxHeaders and fuzzMaxBodies are the most headers and bodies a peer
+	// serves in a single response, as in geth and bor.
+	fuzzMaxHeaders = 1024
+	fuzzMaxBodies  = 1024
+	// fuzzMaxMessageSize is the largest eth message a peer accepts.
+	fuzzMaxMessageSize = 10 * 1024 * 1024
+)
+
+// Expected reactions of a conforming peer to a fuzz case.
+const (
+	// FuzzExpectResponse means the peer answers every request of the case.
+	FuzzExpectResponse = "response"
+	// FuzzExpectDisconnect means the peer drops the connection because the case
+	// violates the protocol.
+	FuzzExpectDisconnect = "disconnect"
+)
+
+// Outcomes of a fuzz case.
+const (
+	FuzzOutcomeResponse   = "response"
+	FuzzOutcomeDisconnect = "disconnect"
+	// FuzzOutcomeClosed means the connection was closed without a disconnect
+	// message.
+	FuzzOutcomeClosed = "closed"
+	// FuzzOutcomeTimeout means the peer neither answered nor disconnected.
+	FuzzOutcomeTimeout = "timeout"
+	// FuzzOutcomeSkipped means the case doesn't apply to the negotiated eth
+	// version and wasn't sent.
+	FuzzOutcomeSkipped = "skipped"
+)
+
+// Verdicts of a fuzz case.
+const (
+	FuzzVerdictPass = "pass"
+	// FuzzVerdictWarn means the peer rejected the case, but not with a protocol
+	// error disconnect.
+	FuzzVerdictWarn = "warn"
+	FuzzVerdictFail = "fail"
+	// FuzzVerdictCrash means the peer could not be dialed again after the case.
+	FuzzVerdictCrash = "crash"
+	FuzzVerdictSkip  = "skip"
+)
+
+// fuzzMsg is a raw message, written without encoding so it can be malformed.
+type fuzzMsg struct {
+	code    uint64
+	payload []byte
+}
+
+// FuzzCase is a sequence of messages sent to a peer and the way a conforming
+// peer reacts to them.
+type FuzzCase struct {
+	Name        string
+	Description string
+	Expect      string
+
+	// msgs builds the messages of the case, using id as the request ID.
+	msgs func(id uint64, status *Status) ([]fuzzMsg, error)
+	// response is the message code of the expected responses, and responses
+	// how many of them are expected.
+	response  int
+	responses int
+	// check validates each response.
+	check func(msg Message, status *Status) error
+	// minVersion and maxVersion bound the eth versions the case applies to,
+	// zero meaning no bound.
+	minVersion uint32
+	maxVersion uint32
+}
+
+// appliesTo reports whether the case can be run on a session of the given eth
+// version.
+func (fc FuzzCase) appliesTo(version uint32) bool {
+	return (fc.minVersion == 0 || version >= fc.minVersion) &&
+		(fc.maxVersion == 0 || version <= fc.maxVersion)
+}
+
+// FuzzResult is the outcome of a fuzz case.
+type FuzzResult struct {
+	Case    string `json:"case"`
+	Expect  string `json:"expect"`
+	Outcome string `json:"outcome"`
+	// Reason is the disconnect reason sent by the peer.
+	Reason    string `json:"reason,omitempty"`
+	Responses int    `json:"responses,omitempty"`
+	// Latency is the time until the outcome in milliseconds.
+	Latency int64  `json:"latency_ms"`
+	Verdict string `json:"verdict"`
+	Error   string `json:"error,omitempty"`
+}
+
+// connLost reports whether the peer dropped the connection during the case.
+func (r FuzzResult) connLost() bool {
+	return r.Outcome == FuzzOutcomeDisconnect || r.Outcome == FuzzOutcomeClosed
+}
+
+// FuzzOptions configures Fuzz.
+type FuzzOptions struct {
+	Dial DialOpts
+	// Cases are the cases to run, in order.
+	Cases []FuzzCase
+	// Timeout is how long to wait for the outcome of each case.
+	Timeout time.Duration
+	// RedialDelay is how long to wait before dialing the peer again after it
+	// dropped the connection. Peers refuse inbound connections from the same
+	// non-LAN IP within 30 seconds.
+	RedialDelay time.Duration
+}
+
+// fuzzConn is a peered connection fuzz cases are run on.
+type fuzzConn interface {
+	Write(msg Message) error
+	writeRaw(code uint64, payload []byte) error
+	readUntil(deadline time.Time) Message
+}
+
+// writeRaw implements fuzzConn.
+func (c *rlpxConn) writeRaw(code uint64, payload []byte) error {
+	_, err := c.Conn.Write(code, payload)
+	return err
+}
+
+// readUntil implements fuzzConn.
+func (c *rlpxConn) readUntil(deadline time.Time) Message {
+	if err := c.SetReadDeadline(deadline); err != nil {
+		return errorf("could not set read deadline: %v", err)
+	}
+	return c.Read()
+}
+
+// FuzzCases returns the built-in fuzz cases. Valid cases come first, followed by
+// edge cases the peer must still answer and finally malformed messages the
+// peer must disconnect on. Connections are negotiated up to eth/69, and cases
+// for messages of one eth version are skipped on sessions of another.
+func FuzzCases() []FuzzCase {
+	return []FuzzCase{
+		{
+			Name:        "headers-genesis",
+			Description: "GetBlockHeaders for the genesis block",
+			Expect:      FuzzExpectResponse,
+			msgs: func(id uint64, _ *Status) ([]fuzzMsg, error) {
+				return getHeadersMsgs(id, eth.HashOrNumber{Number: 0}, 1, 0, false)
+			},
+			response:  BlockHeaders{}.Code(),
+			responses: 1,
+			check: func(msg Message, status *Status) error {
+				headers, err := msg.(*BlockHeaders).List.Items()
+				if err != nil {
+					return err
+				}
+				if len(headers) != 1 {
+					return fmt.Errorf("got %d headers, want 1", len(headers))
+				}
+				if hash := headers[0].Hash(); hash != status.Genesis {
+					return fmt.Errorf("got header %v, want genesis %v", hash, status.Genesis)
+				}
+				return nil
+			},
+		},
+		{
+			Name:        "headers-head",
+			Description: "GetBlockHeaders for the head block by hash",
+			Expect:      FuzzExpectResponse,
+			msgs: func(id uint64, status *Status) ([]fuzzMsg, error) {
+				return getHeadersMsgs(id, eth.HashOrNumber{Hash: status.Head}, 1, 0, false)
+			},
+			response:  BlockHeaders{}.Code(),
+			responses: 1,
+			check:     checkHeaders(1),
+		},
+		{
+			Name:        "headers-oversized-range",
+			Description: "GetBlockHeaders for 2^62 headers, which must be capped",
+			Expect:      FuzzExpectResponse,
+			msgs: func(id uint64, _ *Status) ([]fuzzMsg, error) {
+				return getHeadersMsgs(id, eth.HashOrNumber{Number: 0}, 1<<62, 0, false)
+			},
+			response:  BlockHeaders{}.Code(),
+			responses: 1,
+			check:     checkHeaders(fuzzMaxHeaders),
+		},
+		{
+			Name:        "headers-skip-overflow",
+			Description: "GetBlockHeaders with a skip overflowing the block number",
+			Expect:      FuzzExpectResponse,
+			msgs: func(id uint64, _ *Status) ([]fuzzMsg, error) {
+				return getHeadersMsgs(id, eth.HashOrNumber{Number: 1}, 3, math.MaxUint64, false)
+			},
+			response:  BlockHeaders{}.Code(),
+			responses: 1,
+			check:     checkHeaders(1),
+		},
+		{
+			Name:        "headers-reverse-underflow",
+			Description: "GetBlockHeaders walking back past the genesis block",
+			Expect:      FuzzExpectResponse,
+			msgs: func(id uint64, _ *Status) ([]fuzzMsg, error) {
+				return getHeadersMsgs(id, eth.HashOrNumber{Number: 1}, 3, 10, true)
+			},
+			response:  BlockHeaders{}.Code(),
+			responses: 1,
+			check:     checkHeaders(1),
+		},
+		{
+			Name:        "headers-unknown-hash",
+			Description: "GetBlockHeaders for a block the peer does not have",
+			Expect:      FuzzExpectResponse,
+			msgs: func(id uint64, _ *Status) ([]fuzzMsg, error) {
+				return getHeadersMsgs(id, eth.HashOrNumber{Hash: common.Hash{0xff}}, 1, 0, false)
+			},
+			response:  BlockHeaders{}.Code(),
+			responses: 1,
+			check:     checkHeaders(0),
+		},
+		{
+			Name:        "duplicate-request-ids",
+			Description: "two GetBlockHeaders with the same request ID, which must both be answered",
+			Expect:      FuzzExpectResponse,
+			msgs: func(id uint64, _ *Status) ([]fuzzMsg, error) {
+				msgs, err := getHeadersMsgs(id, eth.HashOrNumber{Number: 0}, 1, 0, false)
+				if err != nil {
+					return nil, err
+				}
+				return append(msgs, msgs...), nil
+			},
+			response:  BlockHeaders{}.Code(),
+			responses: 2,
+			check:     checkHeaders(1),
+		},
+		{
+			Name:        "bodies-oversized",
+			Description: "GetBlockBodies for 4096 unknown blocks",
+			Expect:      FuzzExpectResponse,
+			msgs: func(id uint64, _ *Status) ([]fuzzMsg, error) {
+				hashes := make([]common.Hash, 4*fuzzMaxBodies)
+				for i := range hashes {
+					rand.Read(hashes[i][:])
+				}
+				return encodeFuzzMsg(GetBlockBodies{}.Code(), &eth.GetBlockBodiesPacket{
+					RequestId:             id,
+					GetBlockBodiesRequest: hashes,
+				})
+			},
+			response:  BlockBodies{}.Code(),
+			responses: 1,
+			check: func(msg Message, _ *Status) error {
+				if n := msg.(*BlockBodies).List.Len(); n > fuzzMaxBodies {
+					return fmt.Errorf("got %d bodies, want at most %d", n, fuzzMaxBodies)
+				}
+				return nil
+			},
+		},
+		{
+			Name:        "pooled-txs-empty",
+			Description: "GetPooledTransactions without hashes",
+			Expect:      FuzzExpectResponse,
+			msgs: func(id uint64, _ *Status) ([]fuzzMsg, error) {
+				return encodeFuzzMsg(GetPooledTransactions{}.Code(), &eth.GetPooledTransactionsPacket{RequestId: id})
+			},
+			response:  PooledTransactions{}.Code(),
+			responses: 1,
+			check: func(msg Message, _ *Status) error {
+				if n := msg.(*PooledTransactions).List.Len(); n != 0 {
+					return fmt.Errorf("got %d transactions, want 0", n)
+				}
+				return nil
+			},
+		},
+		{
+			Name:        "eth69-receipts",
+			Description: "GetReceipts for the head block, answered without blooms on eth/69",
+			Expect:      FuzzExpectResponse,
+			msgs: func(id uint64, status *Status) ([]fuzzMsg, error) {
+				return encodeFuzzMsg(GetReceipts{}.Code(), &eth.GetReceiptsPacket69{
+					RequestId:          id,
+					GetReceiptsRequest: []common.Hash{status.Head},
+				})
+			},
+			response:   Receipts{}.Code(),
+			responses:  1,
+			check:      checkReceipts69,
+			minVersion: eth.ETH69,
+		},
+		{
+			Name:        "eth69-range-update",
+			Description: "a valid BlockRangeUpdate followed by GetBlockHeaders for the genesis block",
+			Expect:      FuzzExpectResponse,
+			msgs: func(id uint64, status *Status) ([]fuzzMsg, error) {
+				update, err := encodeFuzzMsg(BlockRangeUpdate{}.Code(), &eth.BlockRangeUpdatePacket{
+					EarliestBlock:   status.EarliestBlock,
+					LatestBlock:     status.LatestBlock,
+					LatestBlockHash: status.Head,
+				})
+				if err != nil {
+					return nil, err
+				}
+				headers, err := getHeadersMsgs(id, eth.HashOrNumber{Number: 0}, 1, 0, false)
+				if err != nil {
+					return nil, err
+				}
+				return append(update, headers...), nil
+			},
+			response:   BlockHeaders{}.Code(),
+			responses:  1,
+			check:      checkHeaders(1),
+			minVersion: eth.ETH69,
+		},
+		{
+			Name:        "headers-bad-rlp",
+			Description: "GetBlockHeaders with a truncated RLP list",
+			Expect:      FuzzExpectDisconnect,
+			msgs: func(id uint64, _ *Status) ([]fuzzMsg, error) {
+				msgs, err := getHeadersMsgs(id, eth.HashOrNumber{Number: 0}, 1, 0, false)
+				if err != nil {
+					return nil, err
+				}
+				msgs[0].payload = msgs[0].payload[:len(msgs[0].payload)-2]
+				return msgs, nil
+			},
+		},
+		{
+			Name:        "headers-empty-list",
+			Description: "GetBlockHeaders with an empty RLP list",
+			Expect:      FuzzExpectDisconnect,
+			msgs: func(uint64, *Status) ([]fuzzMsg, error) {
+				return []fuzzMsg{{code: uint64(GetBlockHeaders{}.Code()), payload: []byte{0xc0}}}, nil
+			},
+		},
+		{
+			Name:        "pooled-txs-unknown-type",
+			Description: "PooledTransactions with a transaction of unknown type 0x7f",
+			Expect:      FuzzExpectDisconnect,
+			msgs: func(id uint64, _ *Status) ([]fuzzMsg, error) {
+				return encodeFuzzMsg(PooledTransactions{}.Code(), []any{id, []any{unknownTypeTx()}})
+			},
+		},
+		{
+			Name:        "txs-unknown-type",
+			Description: "Transactions with a transaction of unknown type 0x7f",
+			Expect:      FuzzExpectDisconnect,
+			msgs: func(uint64, *Status) ([]fuzzMsg, error) {
+				return encodeFuzzMsg(Transactions{}.Code(), []any{unknownTypeTx()})
+			},
+		},
+		{
+			Name:        "tx-hashes-mismatched",
+			Description: "NewPooledTransactionHashes with fewer sizes than hashes",
+			Expect:      FuzzExpectDisconnect,
+			msgs: func(uint64, *Status) ([]fuzzMsg, error) {
+				return encodeFuzzMsg(NewPooledTransactionHashes{}.Code(), &NewPooledTransactionHashes{
+					Types:  []byte{0, 2},
+					Sizes:  []uint32{100},
+					Hashes: []common.Hash{{1}, {2}},
+				})
+			},
+		},
+		{
+			Name:        "status-again",
+			Description: "a second Status message after the handshake",
+			Expect:      FuzzExpectDisconnect,
+			msgs: func(_ uint64, status *Status) ([]fuzzMsg, error) {
+				payload, err := status.encode()
+				if err != nil {
+					return nil, err
+				}
+				return []fuzzMsg{{code: uint64(Status{}.Code()), payload: payload}}, nil
+			},
+		},
+		{
+			Name:        "eth69-status-no-td",
+			Description: "a second Status in the upstream eth/69 format, without TD",
+			Expect:      FuzzExpectDisconnect,
+			msgs: func(_ uint64, status *Status) ([]fuzzMsg, error) {
+				return encodeFuzzMsg(Status{}.Code(), &eth.StatusPacket{
+					ProtocolVersion: status.ProtocolVersion,
+					NetworkID:       status.NetworkID,
+					Genesis:         status.Genesis,
+					ForkID:          status.ForkID,
+					EarliestBlock:   status.EarliestBlock,
+					LatestBlock:     status.LatestBlock,
+					LatestBlockHash: status.Head,
+				})
+			},
+			minVersion: eth.ETH69,
+		},
+		{
+			Name:        "eth69-block-range-update",
+			Description: "an eth/69 BlockRangeUpdate on eth/68, where its code is out of range",
+			Expect:      FuzzExpectDisconnect,
+			msgs: func(_ uint64, status *Status) ([]fuzzMsg, error) {
+				return encodeFuzzMsg(BlockRangeUpdate{}.Code(), []any{uint64(0), uint64(1), status.Head})
+			},
+			maxVersion: 68,
+		},
+		{
+			Name:        "eth69-range-update-inverted",
+			Description: "a BlockRangeUpdate whose earliest block is after its latest",
+			Expect:      FuzzExpectDisconnect,
+			msgs: func(_ uint64, status *Status) ([]fuzzMsg, error) {
+				return encodeFuzzMsg(BlockRangeUpdate{}.Code(), &eth.BlockRangeUpdatePacket{
+					EarliestBlock:   status.LatestBlock + 1,
+					LatestBlock:     status.LatestBlock,
+					LatestBlockHash: status.Head,
+				})
+			},
+			minVersion: eth.ETH69,
+		},
+		{
+			Name:        "unknown-code",
+			Description: "a message with an unassigned message code",
+			Expect:      FuzzExpectDisconnect,
+			msgs: func(uint64, *Status) ([]fuzzMsg, error) {
+				return []fuzzMsg{{code: 0x7f, payload: []byte{0xc0}}}, nil
+			},
+		},
+		{
+			Name:        "oversized-message",
+			Description: "a GetBlockHeaders message larger than the 10 MiB limit",
+			Expect:      FuzzExpectDisconnect,
+			msgs: func(uint64, *Status) ([]fuzzMsg, error) {
+				return []fuzzMsg{{code: uint64(GetBlockHeaders{}.Code()), payload: make([]byte, fuzzMaxMessageSize+1)}}, nil
+			},
+		},
+	}
+}
+
+// SelectFuzzCases returns the built-in cases with the given names, or all of
+// them when no names are given.
+func SelectFuzzCases(names []string) ([]FuzzCase, error) {
+	cases := FuzzCases()
+	if len(names) == 0 {
+		return cases, nil
+	}
+
+	var selected []FuzzCase
+	for _, name := range names {
+		i := slices.IndexFunc(cases, func(c FuzzCase) bool { return c.Name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown fuzz case %q", name)
+		}
+		selected = append(selected, cases[i])
+	}
+	return selected, nil
+}
+
+func getHeadersMsgs(id uint64, origin eth.HashOrNumber, amount, skip uint64, reverse bool) ([]fuzzMsg, error) {
+	return encodeFuzzMsg(GetBlockHeaders{}.Code(), &eth.GetBlockHeadersPacket{
+		RequestId: id,
+		GetBlockHeadersRequest: &eth.GetBlockHeadersRequest{
+			Origin:  origin,
+			Amount:  amount,
+			Skip:    skip,
+			Reverse: reverse,
+		},
+	})
+}
+
+func encodeFuzzMsg(code int, val any) ([]fuzzMsg, error) {
+	payload, err := rlp.EncodeToBytes(val)
+	if err != nil {
+		return nil, err
+	}
+	return []fuzzMsg{{code: uint64(code), payload: payload}}, nil
+}
+
+// unknownTypeTx is a typed transaction envelope with an unassigned type.
+func unknownTypeTx() []byte {
+	return []byte{0x7f, 0xc3, 0x01, 0x02, 0x03}
+}
+
+// checkHeaders returns a check accepting header responses with at most max
+// headers.
+func checkHeaders(max int) func(Message, *Status) error {
+	return func(msg Message, _ *Status) error {
+		if n := msg.(*BlockHeaders).List.Len(); n > max {
+			return fmt.Errorf("got %d headers, want at most %d", n, max)
+		}
+		return nil
+	}
+}
+
+// receipt69 is a receipt in the eth/69 network format, which replaces the
+// bloom of the eth/68 format with the transaction type.
+type receipt69 struct {
+	TxType            uint8
+	PostStateOrStatus []byte
+	GasUsed           uint64
+	Logs              []rlp.RawValue
+}
+
+// checkReceipts69 accepts receipt responses whose receipts are all in the
+// eth/69 format.
+func checkReceipts69(msg Message, _ *Status) error {
+	for i, list := range msg.(*Receipts).ReceiptsRLPResponse {
+		var receipts []receipt69
+		if err := rlp.DecodeBytes(list, &receipts); err != nil {
+			return fmt.Errorf("receipts of block %d are not in the eth/69 format: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// Fuzz runs the cases against the node in order. Cases share a connection until
+// the peer drops it, after which the node is dialed again, so a peer that can't
+// be dialed after a case is reported as crashed and the remaining cases are
+// skipped.
+func Fuzz(n *enode.Node, opts FuzzOptions) ([]FuzzResult, error) {
+	var (
+		conn    *rlpxConn
+		status  *Status
+		results []FuzzResult
+	)
+	defer func() {
+		if conn != nil {
+			_ = conn.Close()
+		}
+	}()
+
+	// connect dials the peer, waiting first if it dropped the last connection.
+	connect := func() error {
+		if len(results) > 0 && opts.RedialDelay > 0 {
+			time.Sleep(opts.RedialDelay)
+		}
+
+		c, err := Dial(n, opts.Dial)
+		if err != nil {
+			return err
+		}
+		if _, status, err = c.Peer(); err != nil {
+			_ = c.Close()
+			return err
+		}
+		conn = c
+		return nil
+	}
+
+	// crashed marks the last case as a crash when the peer can't be dialed
+	// again after it.
+	crashed := func(err error) error {
+		last := &results[len(results)-1]
+		last.Verdict = FuzzVerdictCrash
+		last.Error = fmt.Sprintf("peer unreachable after case: %v", err)
+		return fmt.Errorf("peer unreachable after case %s: %w", last.Case, err)
+	}
+
+	for _, fc := range opts.Cases {
+		if conn == nil {
+			if err := connect(); err != nil {
+				if len(results) == 0 {
+					return nil, err
+				}
+				return results, crashed(err)
+			}
+		}
+
+		result := runFuzzCase(conn, status, fc, opts.Timeout)
+		log.Debug().Interface("result", result).Msg("Fuzz case done")
+		results = append(results, result)
+
+		if result.connLost() {
+			_ = conn.Close()
+			conn = nil
+		}
+	}
+
+	// Make sure the peer survived the last case.
+	if conn == nil && len(results) > 0 {
+		if err := connect(); err != nil {
+			return results, crashed(err)
+		}
+	}
+
+	return results, nil
+}
+
+// runFuzzCase sends the messages of the case and reads until its outcome is
+// known or the timeout passes.
+func runFuzzCase(conn fuzzConn, status *Status, fc FuzzCase, timeout time.Duration) FuzzResult {
+	result := FuzzResult{Case: fc.Name, Expect: fc.Expect}
+	if !fc.appliesTo(status.ProtocolVersion) {
+		result.Outcome = FuzzOutcomeSkipped
+		result.Verdict = FuzzVerdictSkip
+		result.Error = fmt.Sprintf("does not apply to eth/%d", status.ProtocolVersion)
+		return result
+	}
+
+	id := rand.Uint64()
+	msgs, err := fc.msgs(id, status)
+	if err != nil {
+		result.Outcome = FuzzOutcomeTimeout
+		result.Verdict = FuzzVerdictFail
+		result.Error = fmt.Sprintf("could not build messages: %v", err)
+		return result
+	}
+
+	start := time.Now()
+	deadline := start.Add(timeout)
+	var checkErr error
+
+	for _, msg := range msgs {
+		if err := conn.writeRaw(msg.code, msg.payload); err != nil {
+			result.Outcome = FuzzOutcomeClosed
+			result.Error = err.Error()
+			break
+		}
+	}
+
+	for result.Outcome == "" {
+		switch msg := conn.readUntil(deadline).(type) {
+		case *Ping:
+			if err := conn.Write(&Pong{}); err != nil {
+				result.Outcome = FuzzOutcomeClosed
+				result.Error = err.Error()
+			}
+		case *Disconnect:
+			result.Outcome = FuzzOutcomeDisconnect
+			result.Reason = msg.Reason.String()
+		case *Disconnects:
+			result.Outcome = FuzzOutcomeDisconnect
+			if len(*msg) > 0 {
+				result.Reason = (*msg)[0].String()
+			}
+		case *Error:
+			switch {
+			case strings.Contains(msg.Error(), "timeout") && result.Responses > 0:
+				result.Outcome = FuzzOutcomeResponse
+			case strings.Contains(msg.Error(), "timeout"):
+				result.Outcome = FuzzOutcomeTimeout
+			case strings.Contains(msg.Error(), "could not read from connection"):
+				result.Outcome = FuzzOutcomeClosed
+				result.Error = msg.Error()
+			default:
+				// Messages we can't decode are skipped, since the case could
+				// still be answered.
+				log.Trace().Err(msg).Msg("Skipping undecodable message")
+			}
+		case *GetBlockHeaders:
+			// Keep the peer from dropping us for not serving headers.
+			if err := conn.Write(&BlockHeaders{RequestId: msg.RequestId}); err != nil {
+				result.Outcome = FuzzOutcomeClosed
+				result.Error = err.Error()
+			}
+		default:
+			if fc.Expect != FuzzExpectResponse || msg.Code() != fc.response || msg.ReqID() != id {
+				continue
+			}
+			result.Responses++
+			if err := fc.check(msg, status); err != nil && checkErr == nil {
+				checkErr = err
+			}
+			if result.Responses == fc.responses {
+				result.Outcome = FuzzOutcomeResponse
+			}
+		}
+	}
+
+	result.Latency = time.Since(start).Milliseconds()
+	if checkErr != nil {
+		result.Error = checkErr.Error()
+	}
+	if result.Outcome == FuzzOutcomeResponse && result.Responses < fc.responses {
+		checkErr = errors.New("missing responses")
+		result.Error = fmt.Sprintf("got %d of %d responses", result.Responses, fc.responses)
+	}
+	result.Verdict = fuzzVerdict(fc.Expect, result.Outcome, result.Reason, checkErr)
+
+	return result
+}
+
+// fuzzVerdict compares the outcome of a case with the expected reaction.
+func fuzzVerdict(expect, outcome, reason string, err error) string {
+	switch expect {
+	case FuzzExpectResponse:
+		if outcome == FuzzOutcomeResponse && err == nil {
+			return FuzzVerdictPass
+		}
+	case FuzzExpectDisconnect:
+		switch {
+		case outcome == FuzzOutcomeDisconnect && (reason == ethp2p.DiscProtocolError.String() || reason == ethp2p.DiscSubprotocolError.String()):
+			return FuzzVerdictPass
+		case outcome == FuzzOutcomeDisconnect || outcome == FuzzOutcomeClosed:
+			return FuzzVerdictWarn
+		}
+	}
+	return FuzzVerdictFail
+}
@@ -0,0 +1,291 @@
+package p2p
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	ethp2p "github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// fuzzTestConn answers the messages of a case with the messages returned by
+// reply, and times out once they are read.
+type fuzzTestConn struct {
+	written []fuzzMsg
+	reply   func(written []fuzzMsg) []Message
+	queue   []Message
+}
+
+func (c *fuzzTestConn) Write(msg Message) error {
+	return nil
+}
+
+func (c *fuzzTestConn) writeRaw(code uint64, payload []byte) error {
+	c.written = append(c.written, fuzzMsg{code: code, payload: payload})
+	return nil
+}
+
+func (c *fuzzTestConn) readUntil(time.Time) Message {
+	if c.reply != nil {
+		c.queue = c.reply(c.written)
+		c.reply = nil
+	}
+	if len(c.queue) == 0 {
+		return errorf("could not read from connection: i/o timeout")
+	}
+	msg := c.queue[0]
+	c.queue = c.queue[1:]
+	return msg
+}
+
+func fuzzTestCase(t *testing.T, name string) FuzzCase {
+	t.Helper()
+	cases, err := SelectFuzzCases([]string{name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cases[0]
+}
+
+// serveHeaders answers each GetBlockHeaders with the given headers.
+func serveHeaders(t *testing.T, headers ...*types.Header) func([]fuzzMsg) []Message {
+	return func(written []fuzzMsg) []Message {
+		var msgs []Message
+		for _, w := range written {
+			if w.code != uint64(GetBlockHeaders{}.Code()) {
+				continue
+			}
+			var req eth.GetBlockHeadersPacket
+			if err := rlp.DecodeBytes(w.payload, &req); err != nil {
+				t.Fatalf("decode request: %v", err)
+			}
+			res := BlockHeaders{RequestId: req.RequestId}
+			for _, h := range headers {
+				if err := res.List.Append(h); err != nil {
+					t.Fatalf("append header: %v", err)
+				}
+			}
+			msgs = append(msgs, &res)
+		}
+		return msgs
+	}
+}
+
+// serveReceipts answers each GetReceipts with one receipt per block, in the
+// eth/68 format with a bloom when withBloom is set and in the eth/69 format
+// otherwise.
+func serveReceipts(t *testing.T, withBloom bool) func([]fuzzMsg) []Message {
+	return func(written []fuzzMsg) []Message {
+		var msgs []Message
+		for _, w := range written {
+			var req eth.GetReceiptsPacket69
+			if err := rlp.DecodeBytes(w.payload, &req); err != nil {
+				t.Fatalf("decode request: %v", err)
+			}
+			res := Receipts{RequestId: req.RequestId}
+			for range req.GetReceiptsRequest {
+				receipts := []*types.Receipt{{Status: types.ReceiptStatusSuccessful, CumulativeGasUsed: 21000}}
+				var (
+					list []byte
+					err  error
+				)
+				if withBloom {
+					list, err = rlp.EncodeToBytes(types.Receipts(receipts))
+				} else {
+					list, err = rlp.EncodeToBytes(eth.NewReceiptList(receipts))
+				}
+				if err != nil {
+					t.Fatalf("encode receipts: %v", err)
+				}
+				res.ReceiptsRLPResponse = append(res.ReceiptsRLPResponse, list)
+			}
+			msgs = append(msgs, &res)
+		}
+		return msgs
+	}
+}
+
+func disconnect(reason ethp2p.DiscReason) func([]fuzzMsg) []Message {
+	return func([]fuzzMsg) []Message {
+		return []Message{&Disconnects{reason}}
+	}
+}
+
+func TestRunFuzzCase(t *testing.T) {
+	genesis := &types.Header{Number: big.NewInt(0), Difficulty: big.NewInt(1)}
+	other := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(1)}
+	status := &Status{Genesis: genesis.Hash()}
+
+	tests := []struct {
+		name    string
+		fuzz    string
+		reply   func([]fuzzMsg) []Message
+		outcome string
+		verdict string
+	}{
+		{"genesis", "headers-genesis", serveHeaders(t, genesis), FuzzOutcomeResponse, FuzzVerdictPass},
+		{"wrong genesis", "headers-genesis", serveHeaders(t, other), FuzzOutcomeResponse, FuzzVerdictFail},
+		{"too many headers", "headers-skip-overflow", serveHeaders(t, genesis, other), FuzzOutcomeResponse, FuzzVerdictFail},
+		{"both duplicates answered", "duplicate-request-ids", serveHeaders(t, genesis), FuzzOutcomeResponse, FuzzVerdictPass},
+		{"unanswered", "headers-genesis", nil, FuzzOutcomeTimeout, FuzzVerdictFail},
+		{"protocol error", "headers-bad-rlp", disconnect(ethp2p.DiscSubprotocolError), FuzzOutcomeDisconnect, FuzzVerdictPass},
+		{"other reason", "headers-bad-rlp", disconnect(ethp2p.DiscTooManyPeers), FuzzOutcomeDisconnect, FuzzVerdictWarn},
+		{"accepted", "pooled-txs-unknown-type", nil, FuzzOutcomeTimeout, FuzzVerdictFail},
+		{"valid request dropped", "headers-genesis", disconnect(ethp2p.DiscSubprotocolError), FuzzOutcomeDisconnect, FuzzVerdictFail},
+	}
+	for _, tt := range tests {
+		conn := &fuzzTestConn{reply: tt.reply}
+		result := runFuzzCase(conn, status, fuzzTestCase(t, tt.fuzz), time.Second)
+		if result.Outcome != tt.outcome || result.Verdict != tt.verdict {
+			t.Errorf("%s: got %s/%s, want %s/%s (%s)", tt.name, result.Outcome, result.Verdict, tt.outcome, tt.verdict, result.Error)
+		}
+	}
+}
+
+func TestRunFuzzCaseEth69(t *testing.T) {
+	genesis := &types.Header{Number: big.NewInt(0), Difficulty: big.NewInt(1)}
+	eth68 := &Status{ProtocolVersion: 68, Genesis: genesis.Hash()}
+	eth69 := &Status{ProtocolVersion: 69, Genesis: genesis.Hash(), Head: common.Hash{1}, LatestBlock: 10}
+
+	tests := []struct {
+		name    string
+		fuzz    string
+		status  *Status
+		reply   func([]fuzzMsg) []Message
+		outcome string
+		verdict string
+	}{
+		{"receipts without bloom", "eth69-receipts", eth69, serveReceipts(t, false), FuzzOutcomeResponse, FuzzVerdictPass},
+		{"receipts with bloom", "eth69-receipts", eth69, serveReceipts(t, true), FuzzOutcomeResponse, FuzzVerdictFail},
+		{"receipts on eth/68", "eth69-receipts", eth68, nil, FuzzOutcomeSkipped, FuzzVerdictSkip},
+		{"range update accepted", "eth69-range-update", eth69, serveHeaders(t, genesis), FuzzOutcomeResponse, FuzzVerdictPass},
+		{"range update dropped", "eth69-range-update", eth69, disconnect(ethp2p.DiscSubprotocolError), FuzzOutcomeDisconnect, FuzzVerdictFail},
+		{"inverted range update", "eth69-range-update-inverted", eth69, disconnect(ethp2p.DiscSubprotocolError), FuzzOutcomeDisconnect, FuzzVerdictPass},
+		{"status without td", "eth69-status-no-td", eth69, disconnect(ethp2p.DiscSubprotocolError), FuzzOutcomeDisconnect, FuzzVerdictPass},
+		{"range update on eth/68", "eth69-block-range-update", eth68, disconnect(ethp2p.DiscSubprotocolError), FuzzOutcomeDisconnect, FuzzVerdictPass},
+		{"eth/68 range update on eth/69", "eth69-block-range-update", eth69, nil, FuzzOutcomeSkipped, FuzzVerdictSkip},
+	}
+	for _, tt := range tests {
+		conn := &fuzzTestConn{reply: tt.reply}
+		result := runFuzzCase(conn, tt.status, fuzzTestCase(t, tt.fuzz), time.Second)
+		if result.Outcome != tt.outcome || result.Verdict != tt.verdict {
+			t.Errorf("%s: got %s/%s, want %s/%s (%s)", tt.name, result.Outcome, result.Verdict, tt.outcome, tt.verdict, result.Error)
+		}
+		if tt.outcome == FuzzOutcomeSkipped && len(conn.written) > 0 {
+			t.Errorf("%s: skipped case sent %d messages", tt.name, len(conn.written))
+		}
+	}
+}
+
+func TestDecodeStatus(t *testing.T) {
+	td := big.NewInt(100)
+	genesis, head := common.Hash{1}, common.Hash{2}
+	encode := func(val any) []byte {
+		data, err := rlp.EncodeToBytes(val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	tests := []struct {
+		name    string
+		version uint
+		data    []byte
+		want    Status
+	}{
+		{
+			name:    "eth/68",
+			version: 68,
+			data:    encode(&StatusPacket68{ProtocolVersion: 68, NetworkID: 137, TD: td, Head: head, Genesis: genesis}),
+			want:    Status{ProtocolVersion: 68, NetworkID: 137, TD: td, Head: head, Genesis: genesis},
+		},
+		{
+			name:    "bor eth/69",
+			version: 69,
+			data: encode(&BorStatusPacket69{
+				ProtocolVersion: 69, NetworkID: 137, TD: td, Genesis: genesis,
+				EarliestBlock: 5, LatestBlock: 10, LatestBlockHash: head,
+			}),
+			want: Status{ProtocolVersion: 69, NetworkID: 137, TD: td, Head: head, Genesis: genesis, EarliestBlock: 5, LatestBlock: 10},
+		},
+		{
+			name:    "upstream eth/69 without td",
+			version: 69,
+			data: encode(&eth.StatusPacket{
+				ProtocolVersion: 69, NetworkID: 1, Genesis: genesis,
+				EarliestBlock: 5, LatestBlock: 10, LatestBlockHash: head,
+			}),
+			want: Status{ProtocolVersion: 69, NetworkID: 1, Head: head, Genesis: genesis, EarliestBlock: 5, LatestBlock: 10},
+		},
+	}
+	for _, tt := range tests {
+		status, err := decodeStatus(tt.version, tt.data)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if payload, _ := status.encode(); !bytes.Equal(payload, tt.data) {
+			t.Errorf("%s: status is not echoed as received", tt.name)
+		}
+
+		// Without the received message the status is encoded in its own format.
+		status.raw = nil
+		if !reflect.DeepEqual(*status, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, *status, tt.want)
+		}
+		if payload, err := status.encode(); err != nil || !bytes.Equal(payload, tt.data) {
+			t.Errorf("%s: status encodes differently (%v)", tt.name, err)
+		}
+	}
+
+	if _, err := decodeStatus(69, encode(&StatusPacket68{TD: td})); err == nil {
+		t.Error("eth/68 status should not decode on eth/69")
+	}
+}
+
+// TestFuzzCaseMessages checks that valid cases encode decodable messages and
+// malformed cases don't.
+func TestFuzzCaseMessages(t *testing.T) {
+	status := &Status{}
+	decodes := func(m fuzzMsg) bool {
+		var err error
+		switch int(m.code) {
+		case GetBlockHeaders{}.Code():
+			err = rlp.DecodeBytes(m.payload, new(eth.GetBlockHeadersPacket))
+		case PooledTransactions{}.Code():
+			var p eth.PooledTransactionsPacket
+			if err = rlp.DecodeBytes(m.payload, &p); err == nil {
+				_, err = p.List.Items()
+			}
+		case Transactions{}.Code():
+			var p eth.TransactionsPacket
+			if err = rlp.DecodeBytes(m.payload, &p); err == nil {
+				_, err = p.Items()
+			}
+		}
+		return err == nil
+	}
+
+	for _, name := range []string{"headers-genesis", "headers-oversized-range", "headers-skip-overflow", "duplicate-request-ids"} {
+		msgs, err := fuzzTestCase(t, name).msgs(1, status)
+		if err != nil || len(msgs) == 0 || !decodes(msgs[0]) {
+			t.Errorf("%s: messages should decode (%v)", name, err)
+		}
+	}
+	for _, name := range []string{"headers-bad-rlp", "headers-empty-list", "pooled-txs-unknown-type", "txs-unknown-type"} {
+		msgs, err := fuzzTestCase(t, name).msgs(1, status)
+		if err != nil || len(msgs) != 1 || decodes(msgs[0]) {
+			t.Errorf("%s: messages should not decode (%v)", name, err)
+		}
+	}
+
+	if _, err := SelectFuzzCases([]string{"nope"}); err == nil {
+		t.Error("unknown case should be rejected")
+	}
+}
@@ -0,0 +1,289 @@
+package p2p
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// MempoolTx is a pending transaction in a mempool snapshot.
+type MempoolTx struct {
+	Hash      common.Hash    `json:"hash"`
+	From      common.Address `json:"from"`
+	Nonce     uint64         `json:"nonce"`
+	Type      uint8          `json:"type"`
+	Gas       uint64         `json:"gas"`
+	GasFeeCap *big.Int       `json:"gas_fee_cap"`
+	GasTipCap *big.Int       `json:"gas_tip_cap"`
+}
+
+// NewMempoolTx converts a transaction for a mempool snapshot. It returns false
+// if the sender can't be recovered.
+func NewMempoolTx(tx *types.Transaction) (MempoolTx, bool) {
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return MempoolTx{}, false
+	}
+
+	return MempoolTx{
+		Hash:      tx.Hash(),
+		From:      from,
+		Nonce:     tx.Nonce(),
+		Type:      tx.Type(),
+		Gas:       tx.Gas(),
+		GasFeeCap: tx.GasFeeCap(),
+		GasTipCap: tx.GasTipCap(),
+	}, true
+}
+
+// FeeStats summarizes a set of fees in wei.
+type FeeStats struct {
+	Min    *big.Int `json:"min"`
+	Median *big.Int `json:"median"`
+	Max    *big.Int `json:"max"`
+}
+
+// newFeeStats computes fee stats of the non-nil fees, leaving them nil if there
+// are none.
+func newFeeStats(fees []*big.Int) FeeStats {
+	sorted := make([]*big.Int, 0, len(fees))
+	for _, fee := range fees {
+		if fee != nil {
+			sorted = append(sorted, fee)
+		}
+	}
+	if len(sorted) == 0 {
+		return FeeStats{}
+	}
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Cmp(sorted[j]) < 0 })
+
+	return FeeStats{
+		Min:    sorted[0],
+		Median: sorted[len(sorted)/2],
+		Max:    sorted[len(sorted)-1],
+	}
+}
+
+// MempoolSender groups the pending transactions of a sender by nonce.
+type MempoolSender struct {
+	Address common.Address `json:"address"`
+	Txs     []MempoolTx    `json:"txs"`
+	Tips    FeeStats       `json:"tips"`
+}
+
+// MempoolSnapshot is a point in time view of pending transactions, grouped by
+// sender with the most transactions first.
+type MempoolSnapshot struct {
+	Source  string          `json:"source"`
+	Time    time.Time       `json:"time"`
+	Txs     int             `json:"txs"`
+	Tips    FeeStats        `json:"tips"`
+	FeeCaps FeeStats        `json:"fee_caps"`
+	Senders []MempoolSender `json:"senders"`
+}
+
+// NewMempoolSnapshot groups transactions by sender and computes their fee
+// stats.
+func NewMempoolSnapshot(source string, t time.Time, txs []MempoolTx) *MempoolSnapshot {
+	bySender := make(map[common.Address][]MempoolTx)
+	tips := make([]*big.Int, 0, len(txs))
+	feeCaps := make([]*big.Int, 0, len(txs))
+	for _, tx := range txs {
+		bySender[tx.From] = append(bySender[tx.From], tx)
+		tips = append(tips, tx.GasTipCap)
+		feeCaps = append(feeCaps, tx.GasFeeCap)
+	}
+
+	senders := make([]MempoolSender, 0, len(bySender))
+	for addr, txs := range bySender {
+		sort.Slice(txs, func(i, j int) bool { return txs[i].Nonce < txs[j].Nonce })
+
+		senderTips := make([]*big.Int, len(txs))
+		for i, tx := range txs {
+			senderTips[i] = tx.GasTipCap
+		}
+		senders = append(senders, MempoolSender{Address: addr, Txs: txs, Tips: newFeeStats(senderTips)})
+	}
+	sort.Slice(senders, func(i, j int) bool {
+		if len(senders[i].Txs) != len(senders[j].Txs) {
+			return len(senders[i].Txs) > len(senders[j].Txs)
+		}
+		return bytes.Compare(senders[i].Address[:], senders[j].Address[:]) < 0
+	})
+
+	return &MempoolSnapshot{
+		Source:  source,
+		Time:    t,
+		Txs:     len(txs),
+		Tips:    newFeeStats(tips),
+		FeeCaps: newFeeStats(feeCaps),
+		Senders: senders,
+	}
+}
+
+// MempoolConflict is a pair of different transactions with the same sender and
+// nonce, such as a replacement one source hasn't seen.
+type MempoolConflict struct {
+	A MempoolTx `json:"a"`
+	B MempoolTx `json:"b"`
+}
+
+// MempoolDiff compares two mempool snapshots.
+type MempoolDiff struct {
+	A         string            `json:"a"`
+	B         string            `json:"b"`
+	Common    int               `json:"common"`
+	OnlyA     []MempoolTx       `json:"only_a"`
+	OnlyB     []MempoolTx       `json:"only_b"`
+	Conflicts []MempoolConflict `json:"conflicts"`
+	// ReplacedA and ReplacedB are the transactions left out of the comparison
+	// because the same snapshot has a replacement with the same sender and
+	// nonce.
+	ReplacedA []MempoolTx `json:"replaced_a"`
+	ReplacedB []MempoolTx `json:"replaced_b"`
+}
+
+type senderNonce struct {
+	from  common.Address
+	nonce uint64
+}
+
+// replaces reports whether x would replace y, a transaction with the same
+// sender and nonce. Replacements pay a higher tip and then fee cap, and ties
+// are broken by hash so the choice doesn't depend on snapshot order.
+func (x MempoolTx) replaces(y MempoolTx) bool {
+	fee := func(v *big.Int) *big.Int {
+		if v == nil {
+			return new(big.Int)
+		}
+		return v
+	}
+	if c := fee(x.GasTipCap).Cmp(fee(y.GasTipCap)); c != 0 {
+		return c > 0
+	}
+	if c := fee(x.GasFeeCap).Cmp(fee(y.GasFeeCap)); c != 0 {
+		return c > 0
+	}
+	return bytes.Compare(x.Hash[:], y.Hash[:]) < 0
+}
+
+// DiffMempools returns the transactions in only one of two snapshots, ordered
+// by sender and nonce. Transactions whose sender and nonce are in both
+// snapshots with different hashes are reported as conflicts instead. A
+// snapshot with several transactions of a sender and nonce is compared by the
+// one that replaces the others, which are reported as replaced.
+func DiffMempools(a, b *MempoolSnapshot) *MempoolDiff {
+	index := func(s *MempoolSnapshot) (map[senderNonce]MempoolTx, []MempoolTx) {
+		m := make(map[senderNonce]MempoolTx, s.Txs)
+		replaced := []MempoolTx{}
+		for _, sender := range s.Senders {
+			for _, tx := range sender.Txs {
+				key := senderNonce{tx.From, tx.Nonce}
+				current, ok := m[key]
+				switch {
+				case !ok:
+					m[key] = tx
+				case current.Hash == tx.Hash:
+				case tx.replaces(current):
+					m[key] = tx
+					replaced = append(replaced, current)
+				default:
+					replaced = append(replaced, tx)
+				}
+			}
+		}
+		return m, replaced
+	}
+	ia, replacedA := index(a)
+	ib, replacedB := index(b)
+
+	diff := &MempoolDiff{
+		A:         a.Source,
+		B:         b.Source,
+		OnlyA:     []MempoolTx{},
+		OnlyB:     []MempoolTx{},
+		Conflicts: []MempoolConflict{},
+		ReplacedA: replacedA,
+		ReplacedB: replacedB,
+	}
+	for key, txa := range ia {
+		txb, ok := ib[key]
+		switch {
+		case !ok:
+			diff.OnlyA = append(diff.OnlyA, txa)
+		case txa.Hash == txb.Hash:
+			diff.Common++
+		default:
+			diff.Conflicts = append(diff.Conflicts, MempoolConflict{A: txa, B: txb})
+		}
+	}
+	for key, txb := range ib {
+		if _, ok := ia[key]; !ok {
+			diff.OnlyB = append(diff.OnlyB, txb)
+		}
+	}
+
+	less := func(x, y MempoolTx) bool {
+		if c := bytes.Compare(x.From[:], y.From[:]); c != 0 {
+			return c < 0
+		}
+		return x.Nonce < y.Nonce
+	}
+	sort.Slice(diff.OnlyA, func(i, j int) bool { return less(diff.OnlyA[i], diff.OnlyA[j]) })
+	sort.Slice(diff.OnlyB, func(i, j int) bool { return less(diff.OnlyB[i], diff.OnlyB[j]) })
+	sort.Slice(diff.Conflicts, func(i, j int) bool { return less(diff.Conflicts[i].A, diff.Conflicts[j].A) })
+	for _, replaced := range [][]MempoolTx{diff.ReplacedA, diff.ReplacedB} {
+		sort.Slice(replaced, func(i, j int) bool {
+			if replaced[i].From != replaced[j].From || replaced[i].Nonce != replaced[j].Nonce {
+				return less(replaced[i], replaced[j])
+			}
+			return bytes.Compare(replaced[i].Hash[:], replaced[j].Hash[:]) < 0
+		})
+	}
+
+	return diff
+}
+
+// markIncluded records the next nonce of the senders of transactions in a
+// block, so their included and replaced transactions are left out of mempool
+// snapshots.
+func (c *Conns) markIncluded(txs []*types.Transaction) {
+	for _, tx := range txs {
+		from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+		if err != nil {
+			continue
+		}
+
+		next := tx.Nonce() + 1
+		c.nonces.Update(from, func(current uint64) uint64 {
+			return max(current, next)
+		})
+	}
+}
+
+// MempoolSnapshot returns the cached transactions that haven't been included
+// in a block the sensor has seen. Transactions are dropped from the cache as
+// it fills or expires, and ones included in blocks the sensor missed remain
+// until then.
+func (c *Conns) MempoolSnapshot() *MempoolSnapshot {
+	_, txs := c.txs.PeekManyWithKeys(c.txs.Keys())
+
+	pending := make([]MempoolTx, 0, len(txs))
+	for _, tx := range txs {
+		mtx, ok := NewMempoolTx(tx)
+		if !ok {
+			continue
+		}
+		if next, ok := c.nonces.Peek(mtx.From); ok && mtx.Nonce < next {
+			continue
+		}
+		pending = append(pending, mtx)
+	}
+
+	return NewMempoolSnapshot("sensor", time.Now(), pending)
+}
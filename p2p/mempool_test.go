@@ -0,0 +1,100 @@
+package p2p
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func testMempoolTx(from string, nonce uint64, hash byte, tip int64) MempoolTx {
+	return MempoolTx{
+		Hash:      common.Hash{hash},
+		From:      common.HexToAddress(from),
+		Nonce:     nonce,
+		GasFeeCap: big.NewInt(tip * 2),
+		GasTipCap: big.NewInt(tip),
+	}
+}
+
+func TestNewMempoolSnapshot(t *testing.T) {
+	s := NewMempoolSnapshot("test", time.Unix(0, 0), []MempoolTx{
+		testMempoolTx("0x02", 1, 1, 30),
+		testMempoolTx("0x01", 5, 2, 10),
+		testMempoolTx("0x02", 0, 3, 20),
+	})
+
+	if s.Txs != 3 || len(s.Senders) != 2 {
+		t.Fatalf("got %d txs from %d senders, want 3 from 2", s.Txs, len(s.Senders))
+	}
+	sender := s.Senders[0]
+	if sender.Address != common.HexToAddress("0x02") || sender.Txs[0].Nonce != 0 || sender.Txs[1].Nonce != 1 {
+		t.Fatalf("sender with the most txs should be first with txs by nonce: %+v", sender)
+	}
+	if sender.Tips.Min.Int64() != 20 || sender.Tips.Max.Int64() != 30 {
+		t.Errorf("sender tips %v-%v, want 20-30", sender.Tips.Min, sender.Tips.Max)
+	}
+	if s.Tips.Min.Int64() != 10 || s.Tips.Median.Int64() != 20 || s.FeeCaps.Max.Int64() != 60 {
+		t.Errorf("unexpected fee stats: tips %+v, fee caps %+v", s.Tips, s.FeeCaps)
+	}
+}
+
+func TestDiffMempools(t *testing.T) {
+	a := NewMempoolSnapshot("a", time.Unix(0, 0), []MempoolTx{
+		testMempoolTx("0x01", 0, 1, 1),
+		testMempoolTx("0x01", 1, 2, 1),
+		testMempoolTx("0x02", 0, 3, 1),
+	})
+	b := NewMempoolSnapshot("b", time.Unix(0, 0), []MempoolTx{
+		testMempoolTx("0x01", 0, 1, 1),
+		testMempoolTx("0x02", 0, 4, 2),
+		testMempoolTx("0x03", 7, 5, 1),
+	})
+
+	d := DiffMempools(a, b)
+	if d.Common != 1 {
+		t.Errorf("got %d common txs, want 1", d.Common)
+	}
+	if len(d.OnlyA) != 1 || d.OnlyA[0].Hash != (common.Hash{2}) {
+		t.Errorf("unexpected txs only in a: %+v", d.OnlyA)
+	}
+	if len(d.OnlyB) != 1 || d.OnlyB[0].Hash != (common.Hash{5}) {
+		t.Errorf("unexpected txs only in b: %+v", d.OnlyB)
+	}
+	if len(d.Conflicts) != 1 || d.Conflicts[0].A.Hash != (common.Hash{3}) || d.Conflicts[0].B.Hash != (common.Hash{4}) {
+		t.Errorf("unexpected conflicts: %+v", d.Conflicts)
+	}
+}
+
+func TestDiffMempoolsReplacements(t *testing.T) {
+	original := testMempoolTx("0x01", 0, 1, 1)
+	replacement := testMempoolTx("0x01", 0, 2, 2)
+	a := NewMempoolSnapshot("a", time.Unix(0, 0), []MempoolTx{original, replacement})
+	b := NewMempoolSnapshot("b", time.Unix(0, 0), []MempoolTx{replacement})
+
+	// The result doesn't depend on the order the replacement was seen in.
+	reversed := NewMempoolSnapshot("a", time.Unix(0, 0), []MempoolTx{replacement, original})
+	for _, snapshot := range []*MempoolSnapshot{a, reversed} {
+		d := DiffMempools(snapshot, b)
+		if d.Common != 1 || len(d.OnlyA) != 0 || len(d.OnlyB) != 0 || len(d.Conflicts) != 0 {
+			t.Errorf("replacement should be common: %+v", d)
+		}
+		if len(d.ReplacedA) != 1 || d.ReplacedA[0].Hash != original.Hash || len(d.ReplacedB) != 0 {
+			t.Errorf("original should be replaced in a: %+v", d.ReplacedA)
+		}
+	}
+
+	// Against the original, the replacement conflicts.
+	d := DiffMempools(a, NewMempoolSnapshot("b", time.Unix(0, 0), []MempoolTx{original}))
+	if len(d.Conflicts) != 1 || d.Conflicts[0].A.Hash != replacement.Hash || d.Conflicts[0].B.Hash != original.Hash {
+		t.Errorf("unexpected conflicts: %+v", d.Conflicts)
+	}
+
+	// Equal fees are broken by hash.
+	twin := testMempoolTx("0x01", 0, 3, 2)
+	d = DiffMempools(NewMempoolSnapshot("a", time.Unix(0, 0), []MempoolTx{twin, replacement}), b)
+	if d.Common != 1 || len(d.ReplacedA) != 1 || d.ReplacedA[0].Hash != twin.Hash {
+		t.Errorf("lowest hash should be kept on equal fees: %+v", d)
+	}
+}
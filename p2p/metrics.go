@@ -1,6 +1,7 @@
 package p2p
 
 import (
+	"strconv"
 	"time"
 
 	"github.com/ethereum/go-ethereum/core/types"
@@ -73,6 +74,75 @@ func (m *BlockMetrics) Update(block *types.Block, oldest *types.Header) {
 	m.blockRange.Set(float64(hn - on))
 }
 
+// ClockMetrics contains Prometheus metrics for the clock synchronization.
+type ClockMetrics struct {
+	offset prometheus.Gauge
+	delay  *prometheus.GaugeVec
+}
+
+// NewClockMetrics creates and registers the clock synchronization metrics.
+func NewClockMetrics() *ClockMetrics {
+	return &ClockMetrics{
+		offset: promauto.NewGauge(prometheus.GaugeOpts{
+			Namespace: "sensor",
+			Name:      "clock_offset_seconds",
+			Help:      "Estimated offset of the reference clocks from the local clock",
+		}),
+		delay: promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "sensor",
+			Name:      "clock_delay_seconds",
+			Help:      "Round trip delay of the best recent clock sample per reference",
+		}, []string{"reference"}),
+	}
+}
+
+func (m *ClockMetrics) updateOffset(offset time.Duration) {
+	if m != nil {
+		m.offset.Set(offset.Seconds())
+	}
+}
+
+func (m *ClockMetrics) updateDelay(reference string, delay time.Duration) {
+	if m != nil {
+		m.delay.WithLabelValues(reference).Set(delay.Seconds())
+	}
+}
+
+// SnapMetrics contains Prometheus metrics for the snap probes of peers.
+type SnapMetrics struct {
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+}
+
+// NewSnapMetrics creates and registers the snap probe metrics.
+func NewSnapMetrics() *SnapMetrics {
+	return &SnapMetrics{
+		requests: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "sensor",
+			Name:      "snap_requests",
+			Help:      "Number of snap requests sent to peers by result",
+		}, []string{"request", "result"}),
+		latency: promauto.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "sensor",
+			Name:      "snap_request_duration_seconds",
+			Help:      "Time for peers to answer snap requests",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+		}, []string{"request"}),
+	}
+}
+
+func (m *SnapMetrics) update(results []SnapResult) {
+	if m == nil {
+		return
+	}
+	for _, r := range results {
+		m.requests.WithLabelValues(r.Request, r.Result).Inc()
+		if r.Result != SnapResultFailed {
+			m.latency.WithLabelValues(r.Request).Observe(r.Latency.Seconds())
+		}
+	}
+}
+
 // NewPeersGauge creates and registers the peers gauge metric.
 func NewPeersGauge() prometheus.Gauge {
 	return promauto.NewGauge(prometheus.GaugeOpts{
@@ -98,6 +168,16 @@ type metrics struct {
 	queueDepth prometheus.Gauge
 	batchSize  prometheus.Histogram
 	sendErrors prometheus.Counter
+
+	scores    *prometheus.GaugeVec
+	evictions prometheus.Counter
+	invalid   prometheus.Counter
+
+	forks    *prometheus.CounterVec
+	receipts *prometheus.CounterVec
+	dropped  *prometheus.CounterVec
+
+	producerEvents *prometheus.CounterVec
 }
 
 // newMetrics creates and registers all message and broadcast-related Prometheus metrics.
@@ -124,5 +204,48 @@ func newMetrics() *metrics {
 			Name:      "broadcast_send_errors",
 			Help:      "Number of failed broadcast sends",
 		}),
+		scores: promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "sensor",
+			Name:      "peer_score",
+			Help:      "Quantiles of the scores of connected peers",
+		}, []string{"quantile"}),
+		evictions: promauto.NewCounter(prometheus.CounterOpts{
+			Namespace: "sensor",
+			Name:      "peer_evictions",
+			Help:      "Number of low scoring peers evicted",
+		}),
+		invalid: promauto.NewCounter(prometheus.CounterOpts{
+			Namespace: "sensor",
+			Name:      "peer_invalid_messages",
+			Help:      "Number of messages from peers that failed to decode or verify",
+		}),
+		forks: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "sensor",
+			Name:      "peer_forks",
+			Help:      "Number of peer status messages by fork ID compatibility",
+		}, []string{"status"}),
+		receipts: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "sensor",
+			Name:      "block_receipts",
+			Help:      "Number of fetched block receipt lists by verification status",
+		}, []string{"status"}),
+		dropped: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "sensor",
+			Name:      "subscription_dropped_events",
+			Help:      "Number of events dropped for RPC subscribers that fell behind",
+		}, []string{"feed"}),
+		producerEvents: promauto.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "sensor",
+			Name:      "producer_events",
+			Help:      "Number of detected block producer equivocations, sidechain blocks, late blocks and missed slots",
+		}, []string{"type", "producer"}),
+	}
+}
+
+// updateScores sets the score quantile gauges from scores sorted in ascending
+// order.
+func (m *metrics) updateScores(scores []float64) {
+	for _, q := range scoreQuantiles {
+		m.scores.WithLabelValues(strconv.FormatFloat(q, 'f', -1, 64)).Set(quantile(scores, q))
 	}
 }
@@ -20,6 +20,11 @@ type NodeJSON struct {
 	Error  string  `json:"error,omitempty"`
 	Time   int64   `json:"time,omitempty"`
 	Nodes  int     `json:"nodes,omitempty"`
+	// ForkStatus is the compatibility of the node's fork ID with the fork
+	// schedule, if one was given.
+	ForkStatus string `json:"fork_status,omitempty"`
+	// Discv5 is the result of probing the node over discv5, if requested.
+	Discv5 *Discv5Probe `json:"discv5,omitempty"`
 }
 
 type NodeSet map[enode.ID][]NodeJSON
@@ -0,0 +1,224 @@
+package p2p
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/0xPolygon/polygon-cli/p2p/database"
+	ds "github.com/0xPolygon/polygon-cli/p2p/datastructures"
+	"github.com/0xPolygon/polygon-cli/util"
+)
+
+const (
+	// maxProducerHeights bounds the heights whose blocks are compared for
+	// equivocation and sidechains.
+	maxProducerHeights = 1024
+	// maxProducerBlocks bounds the blocks tracked for deduplication, parent
+	// lookups and first-seen times.
+	maxProducerBlocks = 4096
+)
+
+// ProducerOptions configures the monitoring of Bor block producers.
+type ProducerOptions struct {
+	// BlockPeriod is the chain's block time. Monitoring is disabled if zero.
+	BlockPeriod time.Duration
+	// LateThreshold is how long after its timestamp a block may first be seen
+	// before it's reported as late. Defaults to three block periods.
+	LateThreshold time.Duration
+	// SprintLength is the number of consecutive blocks a Bor producer signs.
+	// Missed slots aren't attributed across sprint boundaries, where the next
+	// producer isn't the parent's signer. Defaults to 16.
+	SprintLength uint64
+	// ClockOffset corrects first-seen times for the sensor's clock offset, so
+	// late blocks are measured against the reference clocks.
+	ClockOffset database.ClockOffset
+}
+
+// producerBlock is a block observed by the producer monitor.
+type producerBlock struct {
+	hash   common.Hash
+	signer common.Address
+	time   uint64
+}
+
+// producerMonitor detects producers signing several blocks at one height,
+// withholding blocks and missing slots, from the blocks the sensor sees.
+type producerMonitor struct {
+	opts ProducerOptions
+
+	// heights maps block numbers to the blocks seen at them.
+	heights *ds.LRU[uint64, []producerBlock]
+	// blocks maps hashes to observed blocks, so each block is checked once and
+	// its children can find it.
+	blocks *ds.LRU[common.Hash, producerBlock]
+	// firstSeen maps hashes to when the sensor first received them.
+	firstSeen *ds.LRU[common.Hash, time.Time]
+}
+
+// newProducerMonitor applies defaults to the options and creates a monitor.
+func newProducerMonitor(opts ProducerOptions) *producerMonitor {
+	if opts.LateThreshold <= 0 {
+		opts.LateThreshold = 3 * opts.BlockPeriod
+	}
+	if opts.SprintLength == 0 {
+		opts.SprintLength = 16
+	}
+
+	return &producerMonitor{
+		opts:      opts,
+		heights:   ds.NewLRU[uint64, []producerBlock](ds.LRUOptions{MaxSize: maxProducerHeights}),
+		blocks:    ds.NewLRU[common.Hash, producerBlock](ds.LRUOptions{MaxSize: maxProducerBlocks}),
+		firstSeen: ds.NewLRU[common.Hash, time.Time](ds.LRUOptions{MaxSize: maxProducerBlocks}),
+	}
+}
+
+// seen records that the sensor received a block or its hash at t, keeping the
+// earliest time.
+func (m *producerMonitor) seen(hash common.Hash, t time.Time) {
+	m.firstSeen.Update(hash, func(v time.Time) time.Time {
+		if !v.IsZero() && v.Before(t) {
+			return v
+		}
+		return t
+	})
+}
+
+// observe checks a block signed by signer against the blocks seen before it.
+// Each block is only checked the first time it's observed.
+//
+// A missed slot is reported when the block's timestamp is at least two block
+// periods after its parent's, and is attributed to the parent's signer, who is
+// expected to produce the next block within a sprint. Gaps before the first
+// block of a sprint aren't reported, since the producer of the new sprint
+// depends on the validator set's span and can't be told from the parent.
+func (m *producerMonitor) observe(header *types.Header, signer common.Address) []database.ProducerEvent {
+	hash := header.Hash()
+	number := header.Number.Uint64()
+	block := producerBlock{hash: hash, signer: signer, time: header.Time}
+
+	if m.blocks.Update(hash, func(producerBlock) producerBlock { return block }) {
+		return nil
+	}
+
+	var others []producerBlock
+	m.heights.Update(number, func(blocks []producerBlock) []producerBlock {
+		others = blocks
+		return append(slices.Clip(blocks), block)
+	})
+
+	var events []database.ProducerEvent
+	for _, other := range others {
+		event := database.ProducerEvent{
+			Type:        database.ProducerEventSidechain,
+			Producer:    signer,
+			BlockHash:   hash,
+			BlockNumber: number,
+			OtherHash:   other.hash,
+		}
+		if other.signer == signer {
+			event.Type = database.ProducerEventEquivocation
+		} else {
+			event.OtherProducer = other.signer
+		}
+		events = append(events, event)
+	}
+
+	if first, ok := m.firstSeen.Peek(hash); ok {
+		if m.opts.ClockOffset != nil {
+			first = first.Add(m.opts.ClockOffset())
+		}
+		if delay := first.Sub(time.Unix(int64(header.Time), 0)); delay > m.opts.LateThreshold {
+			events = append(events, database.ProducerEvent{
+				Type:        database.ProducerEventLate,
+				Producer:    signer,
+				BlockHash:   hash,
+				BlockNumber: number,
+				Delay:       delay,
+			})
+		}
+	}
+
+	if number%m.opts.SprintLength == 0 {
+		return events
+	}
+	if parent, ok := m.blocks.Peek(header.ParentHash); ok && header.Time > parent.time {
+		gap := time.Duration(header.Time-parent.time) * time.Second
+		if periods := uint64(gap / m.opts.BlockPeriod); periods > 1 {
+			events = append(events, database.ProducerEvent{
+				Type:          database.ProducerEventMissedSlot,
+				Producer:      parent.signer,
+				BlockHash:     hash,
+				BlockNumber:   number,
+				OtherHash:     parent.hash,
+				OtherProducer: signer,
+				Slots:         periods - 1,
+			})
+		}
+	}
+
+	return events
+}
+
+// blockSeen records when the sensor first received a block or its hash, for
+// detecting withheld blocks.
+func (c *Conns) blockSeen(hash common.Hash, t time.Time) {
+	if c.producers != nil {
+		c.producers.seen(hash, t)
+	}
+}
+
+// observeProducer checks the producer of a block for misbehaviour. Blocks
+// whose signer can't be recovered, or isn't a known validator when a validator
+// set is configured, are ignored so peers can't fabricate events.
+func (c *Conns) observeProducer(header *types.Header) []database.ProducerEvent {
+	if c.producers == nil {
+		return nil
+	}
+
+	sig, err := util.Ecrecover(header)
+	if err != nil {
+		return nil
+	}
+
+	signer := common.BytesToAddress(sig)
+	if c.validators != nil && !c.validators.HasSigner(signer) {
+		return nil
+	}
+
+	events := c.producers.observe(header, signer)
+	for _, event := range events {
+		c.metrics.producerEvents.WithLabelValues(event.Type, event.Producer.Hex()).Inc()
+	}
+	return events
+}
+
+// checkProducer records and logs misbehaviour of the producer of a block
+// announced by the peer.
+func (c *conn) checkProducer(ctx context.Context, header *types.Header) {
+	events := c.conns.observeProducer(header)
+	if len(events) == 0 {
+		return
+	}
+
+	for _, event := range events {
+		logEvent := c.logger.Debug()
+		if event.Type == database.ProducerEventEquivocation {
+			logEvent = c.logger.Warn()
+		}
+		logEvent.
+			Str("type", event.Type).
+			Str("producer", event.Producer.Hex()).
+			Str("hash", event.BlockHash.Hex()).
+			Uint64("number", event.BlockNumber).
+			Str("other_hash", event.OtherHash.Hex()).
+			Dur("delay", event.Delay).
+			Uint64("slots", event.Slots).
+			Msg("Detected producer event")
+	}
+
+	c.db.WriteProducerEvents(ctx, events, time.Now())
+}
@@ -0,0 +1,132 @@
+package p2p
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/0xPolygon/polygon-cli/p2p/database"
+)
+
+func producerHeader(number, ts uint64, parent common.Hash, extra byte) *types.Header {
+	return &types.Header{
+		Number:     new(big.Int).SetUint64(number),
+		Time:       ts,
+		ParentHash: parent,
+		Extra:      []byte{extra},
+	}
+}
+
+func TestProducerMonitorSameHeight(t *testing.T) {
+	m := newProducerMonitor(ProducerOptions{BlockPeriod: 2 * time.Second, LateThreshold: time.Hour})
+	alice, bob := common.Address{1}, common.Address{2}
+
+	a := producerHeader(10, 100, common.Hash{}, 1)
+	if events := m.observe(a, alice); len(events) != 0 {
+		t.Fatalf("first block: %+v", events)
+	}
+	if events := m.observe(a, alice); len(events) != 0 {
+		t.Fatalf("repeated block: %+v", events)
+	}
+
+	b := producerHeader(10, 100, common.Hash{}, 2)
+	events := m.observe(b, alice)
+	if len(events) != 1 || events[0].Type != database.ProducerEventEquivocation ||
+		events[0].Producer != alice || events[0].BlockHash != b.Hash() || events[0].OtherHash != a.Hash() {
+		t.Fatalf("equivocation: %+v", events)
+	}
+
+	c := producerHeader(10, 104, common.Hash{}, 3)
+	events = m.observe(c, bob)
+	if len(events) != 2 {
+		t.Fatalf("want a sidechain event per block at the height, got %+v", events)
+	}
+	for _, event := range events {
+		if event.Type != database.ProducerEventSidechain || event.Producer != bob || event.OtherProducer != alice {
+			t.Fatalf("sidechain: %+v", event)
+		}
+	}
+}
+
+func TestProducerMonitorLateAndMissedSlots(t *testing.T) {
+	m := newProducerMonitor(ProducerOptions{BlockPeriod: 2 * time.Second, LateThreshold: 5 * time.Second})
+	alice, bob := common.Address{1}, common.Address{2}
+
+	parent := producerHeader(10, 100, common.Hash{}, 0)
+	m.seen(parent.Hash(), time.Unix(101, 0))
+	if events := m.observe(parent, alice); len(events) != 0 {
+		t.Fatalf("timely block: %+v", events)
+	}
+
+	// Three seconds after the parent is within two periods, so no slot was
+	// missed.
+	child := producerHeader(11, 103, parent.Hash(), 0)
+	if events := m.observe(child, alice); len(events) != 0 {
+		t.Fatalf("next slot: %+v", events)
+	}
+
+	late := producerHeader(12, 109, child.Hash(), 0)
+	m.seen(late.Hash(), time.Unix(120, 0))
+	m.seen(late.Hash(), time.Unix(130, 0)) // The earliest time is kept.
+	events := m.observe(late, bob)
+	if len(events) != 2 {
+		t.Fatalf("want late and missed slot events, got %+v", events)
+	}
+
+	if e := events[0]; e.Type != database.ProducerEventLate || e.Producer != bob || e.Delay != 11*time.Second {
+		t.Fatalf("late: %+v", e)
+	}
+	if e := events[1]; e.Type != database.ProducerEventMissedSlot || e.Producer != alice ||
+		e.OtherProducer != bob || e.OtherHash != child.Hash() || e.Slots != 2 {
+		t.Fatalf("missed slot: %+v", e)
+	}
+}
+
+func TestProducerMonitorSprintBoundary(t *testing.T) {
+	m := newProducerMonitor(ProducerOptions{BlockPeriod: 2 * time.Second, LateThreshold: time.Hour, SprintLength: 16})
+	alice, bob := common.Address{1}, common.Address{2}
+
+	last := producerHeader(15, 100, common.Hash{}, 0)
+	m.observe(last, alice)
+
+	// The first block of the next sprint is bob's, so alice didn't miss it.
+	first := producerHeader(16, 110, last.Hash(), 0)
+	if events := m.observe(first, bob); len(events) != 0 {
+		t.Fatalf("sprint boundary: %+v", events)
+	}
+
+	// Within the sprint the gap is bob's.
+	next := producerHeader(17, 116, first.Hash(), 0)
+	events := m.observe(next, alice)
+	if len(events) != 1 || events[0].Type != database.ProducerEventMissedSlot ||
+		events[0].Producer != bob || events[0].Slots != 2 {
+		t.Fatalf("missed slot: %+v", events)
+	}
+}
+
+func TestProducerMonitorClockOffset(t *testing.T) {
+	// The local clock is 10s ahead of the references.
+	offset := -10 * time.Second
+	m := newProducerMonitor(ProducerOptions{
+		BlockPeriod:   2 * time.Second,
+		LateThreshold: 5 * time.Second,
+		ClockOffset:   func() time.Duration { return offset },
+	})
+	alice := common.Address{1}
+
+	header := producerHeader(10, 100, common.Hash{}, 0)
+	m.seen(header.Hash(), time.Unix(112, 0))
+	if events := m.observe(header, alice); len(events) != 0 {
+		t.Fatalf("timely block: %+v", events)
+	}
+
+	late := producerHeader(11, 102, header.Hash(), 0)
+	m.seen(late.Hash(), time.Unix(120, 0))
+	events := m.observe(late, alice)
+	if len(events) != 1 || events[0].Type != database.ProducerEventLate || events[0].Delay != 8*time.Second {
+		t.Fatalf("late: %+v", events)
+	}
+}
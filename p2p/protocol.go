@@ -1,6 +1,7 @@
 package p2p
 
 import (
+	"bytes"
 	"context"
 	"encoding/hex"
 	"errors"
@@ -75,6 +76,9 @@ type conn struct {
 	shouldBroadcastBlocks      bool
 	shouldBroadcastBlockHashes bool
 
+	// fetchReceipts requests the receipts of cached blocks from eth/69 peers.
+	fetchReceipts bool
+
 	// Known caches track what this peer has seen to avoid redundant sends.
 	// knownTxs uses a bloom filter for memory efficiency (~40KB vs ~4MB per peer).
 	// knownBlocks uses a simple bounded set for lower memory overhead than the generic LRU.
@@ -98,6 +102,14 @@ type conn struct {
 	// peer via NewBlock, NewBlockHashes, or BlockRangeUpdate messages.
 	// Hash and number are combined in a single struct to ensure consistency.
 	latestBlock *ds.Locked[latestBlock]
+
+	// score tracks the peer's behaviour for scoring. It is nil if scoring is
+	// disabled.
+	score *peerScore
+
+	// forks validates the peer's fork ID. It is nil if only the fork hash is
+	// compared.
+	forks *ForkSchedule
 }
 
 // latestBlock holds the hash and number of the latest block from a peer.
@@ -117,6 +129,14 @@ type EthProtocolOptions struct {
 	Conns       *Conns
 	ForkID      forkid.ID
 
+	// ForkSchedule, if set, replaces ForkID: the advertised fork ID is computed
+	// from the head block and peers are validated against the schedule.
+	ForkSchedule *ForkSchedule
+
+	// DialCandidates, if set, is the source of nodes the server dials instead
+	// of its default discovery iterators.
+	DialCandidates enode.Iterator
+
 	// Cache configurations
 	RequestsCache ds.LRUOptions
 	ParentsCache  ds.LRUOptions
@@ -126,6 +146,14 @@ type EthProtocolOptions struct {
 	ShouldBroadcastTxHashes    bool
 	ShouldBroadcastBlocks      bool
 	ShouldBroadcastBlockHashes bool
+
+	// FetchReceipts requests the receipts of cached blocks from eth/69 peers
+	// and verifies them against the header's receipts root.
+	FetchReceipts bool
+
+	// Capture, if set, records every eth message received after the status
+	// exchange so it can be replayed.
+	Capture *CaptureWriter
 }
 
 // NewEthProtocol creates the new eth protocol. This will handle writing the
@@ -135,7 +163,17 @@ func NewEthProtocol(version uint, opts EthProtocolOptions) ethp2p.Protocol {
 		Name:    "eth",
 		Version: version,
 		Length:  protocolLengths[version],
+		// The server adds dial candidates once per protocol name, so every eth
+		// version shares them.
+		DialCandidates: opts.DialCandidates,
 		Run: func(p *ethp2p.Peer, rw ethp2p.MsgReadWriter) error {
+			// Refuse evicted peers until their cooldown expires so their slots
+			// go to other nodes.
+			if opts.Conns.scorer.isEvicted(p.ID().String()) {
+				log.Debug().Str("peer", p.Node().URLv4()).Msg("Refusing evicted peer")
+				return ethp2p.DiscUselessPeer
+			}
+
 			peerURL := p.Node().URLv4()
 			c := &conn{
 				sensorID:                   opts.SensorID,
@@ -154,6 +192,7 @@ func NewEthProtocol(version uint, opts EthProtocolOptions) ethp2p.Protocol {
 				shouldBroadcastTxHashes:    opts.ShouldBroadcastTxHashes,
 				shouldBroadcastBlocks:      opts.ShouldBroadcastBlocks,
 				shouldBroadcastBlockHashes: opts.ShouldBroadcastBlockHashes,
+				fetchReceipts:              opts.FetchReceipts,
 				knownTxs:                   ds.NewBloomSet(opts.Conns.knownTxsBloom),
 				knownBlocks:                ds.NewBoundedSet[common.Hash](opts.Conns.knownBlocksMax),
 				messages:                   NewPeerMessages(),
@@ -162,6 +201,8 @@ func NewEthProtocol(version uint, opts EthProtocolOptions) ethp2p.Protocol {
 				closeCh:                    make(chan struct{}),
 				version:                    version,
 				latestBlock:                &ds.Locked[latestBlock]{},
+				score:                      opts.Conns.scorer.newPeerScore(),
+				forks:                      opts.ForkSchedule,
 			}
 
 			// Ensure cleanup happens on any exit path (including statusExchange failure)
@@ -197,6 +238,12 @@ func NewEthProtocol(version uint, opts EthProtocolOptions) ethp2p.Protocol {
 					return err
 				}
 
+				if opts.Capture != nil {
+					if err = c.capture(opts.Capture, &msg); err != nil {
+						return err
+					}
+				}
+
 				switch msg.Code {
 				case eth.NewBlockHashesMsg:
 					err = c.handleNewBlockHashes(ctx, msg)
@@ -220,6 +267,8 @@ func NewEthProtocol(version uint, opts EthProtocolOptions) ethp2p.Protocol {
 					err = c.handlePooledTransactions(ctx, msg)
 				case eth.GetReceiptsMsg:
 					err = c.handleGetReceipts(msg)
+				case eth.ReceiptsMsg:
+					err = c.handleReceipts(ctx, msg)
 				case eth.BlockRangeUpdateMsg:
 					err = c.handleBlockRangeUpdate(msg)
 				default:
@@ -246,6 +295,9 @@ func NewEthProtocol(version uint, opts EthProtocolOptions) ethp2p.Protocol {
 // status packet format based on the negotiated protocol version.
 func (c *conn) statusExchange(version uint, opts EthProtocolOptions) error {
 	head := c.conns.HeadBlock()
+	if c.forks != nil {
+		opts.ForkID = c.forks.ID(head.Block.NumberU64(), head.Block.Time())
+	}
 
 	if version >= eth.ETH69 {
 		status := BorStatusPacket69{
@@ -348,6 +400,22 @@ func (c *conn) countMsgSent(messageName string, count float64) {
 	c.messages.IncrementSent(messageName, int64(count))
 }
 
+// capture records a received message, replacing its payload with the bytes read
+// so it can still be handled. Failing to write the capture file is logged
+// rather than dropping the peer.
+func (c *conn) capture(w *CaptureWriter, msg *ethp2p.Msg) error {
+	payload, err := io.ReadAll(msg.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to read message payload: %w", err)
+	}
+	msg.Payload = bytes.NewReader(payload)
+
+	if err := w.Write(msg.ReceivedAt, c.node.ID().String(), c.version, msg.Code, payload); err != nil {
+		c.logger.Error().Err(err).Msg("Failed to capture message")
+	}
+	return nil
+}
+
 func (c *conn) readStatus68(packet *StatusPacket68) error {
 	msg, err := c.rw.ReadMsg()
 	if err != nil {
@@ -369,8 +437,8 @@ func (c *conn) readStatus68(packet *StatusPacket68) error {
 	if status.Genesis != packet.Genesis {
 		return fmt.Errorf("genesis mismatch: %v (!= %v)", status.Genesis, packet.Genesis)
 	}
-	if status.ForkID.Hash != packet.ForkID.Hash {
-		return fmt.Errorf("fork ID mismatch: %v (!= %v)", status.ForkID, packet.ForkID)
+	if err := c.validateForkID(status.ForkID, packet.ForkID); err != nil {
+		return err
 	}
 
 	c.logger.Info().
@@ -402,8 +470,8 @@ func (c *conn) readStatus69(packet *BorStatusPacket69) error {
 	if status.Genesis != packet.Genesis {
 		return fmt.Errorf("genesis mismatch: %v (!= %v)", status.Genesis, packet.Genesis)
 	}
-	if status.ForkID.Hash != packet.ForkID.Hash {
-		return fmt.Errorf("fork ID mismatch: %v (!= %v)", status.ForkID, packet.ForkID)
+	if err := c.validateForkID(status.ForkID, packet.ForkID); err != nil {
+		return err
 	}
 
 	c.logger.Info().
@@ -416,6 +484,26 @@ func (c *conn) readStatus69(packet *BorStatusPacket69) error {
 	return nil
 }
 
+// validateForkID checks the peer's fork ID against ours. Without a fork
+// schedule the hashes must match, otherwise the peer must be compatible with
+// the schedule at our head block.
+func (c *conn) validateForkID(remote, local forkid.ID) error {
+	if c.forks == nil {
+		if remote.Hash != local.Hash {
+			return fmt.Errorf("fork ID mismatch: %v (!= %v)", remote, local)
+		}
+		return nil
+	}
+
+	head := c.conns.HeadBlock().Block
+	status := c.forks.Classify(remote, head.NumberU64(), head.Time())
+	c.conns.metrics.forks.WithLabelValues(status).Inc()
+	if status != ForkCompatible {
+		return fmt.Errorf("%s fork ID: %x next %d (local %x next %d)", status, remote.Hash, remote.Next, local.Hash, local.Next)
+	}
+	return nil
+}
+
 // handleBlockRangeUpdate handles BlockRangeUpdateMsg (ETH69).
 // This message announces the peer's available block range.
 func (c *conn) handleBlockRangeUpdate(msg ethp2p.Msg) error {
@@ -451,7 +539,11 @@ func (c *conn) getBlockData(ann database.BlockAnnouncement, cache BlockCache, is
 	hash := ann.Hash
 	// Only request header if we don't have it
 	if cache.Header == nil {
+		c.requestNum++
+		c.score.requestSent(c.requestNum)
+
 		headersRequest := &GetBlockHeaders{
+			RequestId: c.requestNum,
 			GetBlockHeadersRequest: &eth.GetBlockHeadersRequest{
 				// Providing both the hash and number will result in a `both origin
 				// hash and number` error.
@@ -474,6 +566,7 @@ func (c *conn) getBlockData(ann database.BlockAnnouncement, cache BlockCache, is
 	if cache.Body == nil {
 		c.requestNum++
 		c.requests.Add(c.requestNum, ann)
+		c.score.requestSent(c.requestNum)
 
 		bodiesRequest := &GetBlockBodies{
 			RequestId:             c.requestNum,
@@ -570,6 +663,8 @@ func (c *conn) handleNewBlockHashes(ctx context.Context, msg ethp2p.Msg) error {
 
 	for _, entry := range packet {
 		hash := entry.Hash
+		c.score.blockAnnounced(hash, tfs)
+		c.conns.blockSeen(hash, tfs)
 
 		// Update latest block info atomically if this block is newer
 		c.latestBlock.Update(func(current latestBlock) (latestBlock, bool) {
@@ -589,6 +684,11 @@ func (c *conn) handleNewBlockHashes(ctx context.Context, msg ethp2p.Msg) error {
 		// marker.
 		cache, ok := c.conns.Blocks().Peek(hash)
 		if ok && cache.Header != nil && cache.Body != nil {
+			// The peer announces blocks after importing them, so it can serve
+			// the receipts too.
+			if err := c.requestReceipts(entry); err != nil {
+				return err
+			}
 			continue
 		}
 
@@ -860,6 +960,7 @@ func (c *conn) decodeTx(raw []byte) *types.Transaction {
 			Int("size", len(bytes)).
 			Str("hash", crypto.Keccak256Hash(bytes).Hex()).
 			Msg("Failed to decode transaction")
+		c.invalidMessage()
 
 		return nil
 	}
@@ -884,6 +985,7 @@ func (c *conn) decodeTx(raw []byte) *types.Transaction {
 		Int("size", len(raw)).
 		Str("hash", crypto.Keccak256Hash(raw).Hex()).
 		Msg("Failed to decode transaction")
+	c.invalidMessage()
 
 	return nil
 }
@@ -952,6 +1054,7 @@ func (c *conn) processTransactions(ctx context.Context, txs []*types.Transaction
 	// Only write NEW transactions (cache miss = needs DB write)
 	if len(newTxs) > 0 {
 		c.db.WriteTransactions(ctx, c.node, newTxs, tfs)
+		c.conns.notifyTxs(newTxs)
 	}
 
 	// Broadcast transactions or hashes to other peers asynchronously
@@ -993,6 +1096,7 @@ func (c *conn) handleTransactions(ctx context.Context, msg ethp2p.Msg) error {
 	var rawTxs []rlp.RawValue
 	if err := rlp.DecodeBytes(payload, &rawTxs); err != nil {
 		c.logger.Warn().Err(err).Msg("Failed to decode transactions")
+		c.invalidMessage()
 		return nil
 	}
 
@@ -1041,6 +1145,7 @@ func (c *conn) handleBlockHeaders(ctx context.Context, msg ethp2p.Msg) error {
 	if err != nil {
 		return fmt.Errorf("failed to decode block headers: %w", err)
 	}
+	c.score.responseReceived(packet.RequestId, len(headers) == 0)
 	if len(headers) == 0 {
 		return nil
 	}
@@ -1063,6 +1168,15 @@ func (c *conn) handleBlockHeaders(ctx context.Context, msg ethp2p.Msg) error {
 	var head *types.Header
 	for i, header := range headers {
 		c.cacheAndAnnounceHeader(header, isParent, i == 0)
+		if !isParent {
+			c.checkProducer(ctx, header)
+		}
+
+		// Completes the block if its body arrived first.
+		ann := database.BlockAnnouncement{Hash: header.Hash(), Number: header.Number.Uint64()}
+		if err := c.requestReceipts(ann); err != nil {
+			return err
+		}
 
 		if head == nil || header.Number.Cmp(head.Number) > 0 {
 			head = header
@@ -1070,6 +1184,7 @@ func (c *conn) handleBlockHeaders(ctx context.Context, msg ethp2p.Msg) error {
 	}
 
 	if head != nil {
+		c.conns.notifyHead(head)
 		c.conns.UpdateHeadBlock(NewBlockPacket{
 			Block: types.NewBlockWithHeader(head),
 			TD:    c.conns.HeadBlock().TD,
@@ -1166,6 +1281,7 @@ func (c *conn) handleBlockBodies(ctx context.Context, msg ethp2p.Msg) error {
 
 	tfs := time.Now()
 
+	c.score.responseReceived(packet.RequestId, len(packet.BlockBodiesRLPResponse) == 0)
 	if len(packet.BlockBodiesRLPResponse) == 0 {
 		return nil
 	}
@@ -1193,6 +1309,10 @@ func (c *conn) handleBlockBodies(ctx context.Context, msg ethp2p.Msg) error {
 
 	c.db.WriteBlockBody(ctx, body, ann, tfs)
 
+	if txs, err := body.Transactions.Items(); err == nil {
+		c.conns.markIncluded(txs)
+	}
+
 	// When cache-only-validated is enabled, only retain the body if the block
 	// already has a cache entry (the announcement marker, or a cached header).
 	// The header and body responses can arrive in any order, so we do NOT gate
@@ -1233,6 +1353,8 @@ func (c *conn) handleBlockBodies(ctx context.Context, msg ethp2p.Msg) error {
 			Block: block,
 			TD:    c.conns.HeadBlock().TD,
 		})
+
+		return c.requestReceipts(ann)
 	}
 
 	return nil
@@ -1243,6 +1365,7 @@ func (c *conn) handleBlockBodies(ctx context.Context, msg ethp2p.Msg) error {
 func (c *conn) buildBlockBody(raw []byte) (*eth.BlockBody, error) {
 	var decoded rawBlockBody
 	if err := rlp.DecodeBytes(raw, &decoded); err != nil {
+		c.invalidMessage()
 		return nil, fmt.Errorf("failed to decode block body: %w", err)
 	}
 
@@ -1284,6 +1407,7 @@ func (c *conn) handleNewBlock(ctx context.Context, msg ethp2p.Msg) error {
 	var raw rawNewBlockPacket
 	if err = rlp.DecodeBytes(payload, &raw); err != nil {
 		c.logger.Warn().Err(err).Msg("Failed to decode new block")
+		c.invalidMessage()
 		return nil
 	}
 
@@ -1314,6 +1438,7 @@ func (c *conn) handleNewBlock(ctx context.Context, msg ethp2p.Msg) error {
 			Str("got_tx_root", root.Hex()).
 			Int("txs", len(txs)).
 			Msg("Dropping new block whose body does not match its header")
+		c.invalidMessage()
 		return nil
 	}
 	packet := &NewBlockPacket{Block: block, TD: raw.TD}
@@ -1322,6 +1447,8 @@ func (c *conn) handleNewBlock(ctx context.Context, msg ethp2p.Msg) error {
 	hash := packet.Block.Hash()
 
 	c.countMsgReceived(packet.Name(), 1)
+	c.score.blockAnnounced(hash, tfs)
+	c.conns.blockSeen(hash, tfs)
 
 	// Update latest block info atomically if this block is newer
 	blockNum := packet.Block.Number().Uint64()
@@ -1336,6 +1463,7 @@ func (c *conn) handleNewBlock(ctx context.Context, msg ethp2p.Msg) error {
 	c.addKnownBlock(hash)
 
 	// Set the head block if newer.
+	c.conns.notifyHead(packet.Block.Header())
 	if c.conns.UpdateHeadBlock(*packet) {
 		c.logger.Info().
 			Str("hash", hash.Hex()).
@@ -1347,6 +1475,7 @@ func (c *conn) handleNewBlock(ctx context.Context, msg ethp2p.Msg) error {
 	if err = c.getParentBlock(ctx, packet.Block.Header()); err != nil {
 		return err
 	}
+	c.checkProducer(ctx, packet.Block.Header())
 
 	// Recover the block signer up front. Unknown-signer blocks are recorded to
 	// the database below but, when cache-only-validated is enabled, are neither
@@ -1363,6 +1492,7 @@ func (c *conn) handleNewBlock(ctx context.Context, msg ethp2p.Msg) error {
 	}
 
 	c.db.WriteBlock(ctx, c.node, packet.Block, packet.TD, tfs)
+	c.conns.markIncluded(packet.Block.Transactions())
 
 	// Only rebroadcast blocks signed by a known validator. Persistence above is
 	// unconditional; signer validation gates rebroadcast (and caching) only.
@@ -1521,6 +1651,7 @@ func (c *conn) handlePooledTransactions(ctx context.Context, msg ethp2p.Msg) err
 	var raw rawPooledTransactionsPacket
 	if err := rlp.DecodeBytes(payload, &raw); err != nil {
 		c.logger.Warn().Err(err).Msg("Failed to decode pooled transactions")
+		c.invalidMessage()
 		return nil
 	}
 
@@ -1530,16 +1661,3 @@ func (c *conn) handlePooledTransactions(ctx context.Context, msg ethp2p.Msg) err
 
 	return nil
 }
-
-func (c *conn) handleGetReceipts(msg ethp2p.Msg) error {
-	var request eth.GetReceiptsPacket69
-	if err := msg.Decode(&request); err != nil {
-		return err
-	}
-
-	c.countMsgReceived(request.Name(), float64(len(request.GetReceiptsRequest)))
-
-	response := &ReceiptsRLPPacket{RequestId: request.RequestId}
-	c.countMsgSent((&eth.ReceiptsRLPResponse{}).Name(), 0)
-	return ethp2p.Send(c.rw, eth.ReceiptsMsg, response)
-}
@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/forkid"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	ethp2p "github.com/ethereum/go-ethereum/p2p"
@@ -276,3 +277,37 @@ func assertContains(t *testing.T, codes []uint64, want ...uint64) {
 		}
 	}
 }
+
+// TestValidateForkID verifies peers are checked by fork hash without a fork
+// schedule, and by compatibility with the schedule at the head block with one.
+func TestValidateForkID(t *testing.T) {
+	c := newTestConn(&recordRW{}, sharedTestConns(t, false))
+	local := forkid.ID{Hash: [4]byte{1}}
+
+	if err := c.validateForkID(forkid.ID{Hash: [4]byte{1}, Next: 5}, local); err != nil {
+		t.Fatalf("want matching hash accepted, got %v", err)
+	}
+	if err := c.validateForkID(forkid.ID{Hash: [4]byte{2}}, local); err == nil {
+		t.Fatal("want mismatching hash rejected")
+	}
+
+	// The test head is at block 1, so the first fork has passed.
+	c.forks = NewForkSchedule(common.Hash{1}, 0, []uint64{1, 100}, nil)
+	genesis, first, second := c.forks.Forks[0].ID, c.forks.Forks[1].ID, c.forks.Forks[2].ID
+
+	if err := c.validateForkID(first, first); err != nil {
+		t.Fatalf("want current fork accepted, got %v", err)
+	}
+	if err := c.validateForkID(genesis, first); err != nil {
+		t.Fatalf("want syncing peer accepted, got %v", err)
+	}
+	if err := c.validateForkID(forkid.ID{Hash: genesis.Hash}, first); err == nil {
+		t.Fatal("want stale peer rejected")
+	}
+	if err := c.validateForkID(second, first); err != nil {
+		t.Fatalf("want peer on a later fork accepted, got %v", err)
+	}
+	if err := c.validateForkID(forkid.ID{Hash: first.Hash, Next: 1}, first); err == nil {
+		t.Fatal("want peer announcing a passed fork rejected")
+	}
+}
@@ -0,0 +1,260 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	ethp2p "github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+
+	"github.com/0xPolygon/polygon-cli/p2p/database"
+)
+
+// receiptsRetryInterval is how long to wait for the receipts of a block before
+// asking another peer for them.
+const receiptsRetryInterval = 5 * time.Second
+
+// receiptLocation is where the receipt of a transaction is in the blocks cache.
+type receiptLocation struct {
+	block common.Hash
+	index int
+}
+
+// receiptIndex maps the hashes of transactions to their receipts in the blocks
+// cache, so receipts are looked up without scanning the cache. Entries are
+// added with the receipts of a block and removed when the block is evicted.
+type receiptIndex struct {
+	mu  sync.RWMutex
+	txs map[common.Hash]receiptLocation
+}
+
+func newReceiptIndex() *receiptIndex {
+	return &receiptIndex{txs: make(map[common.Hash]receiptLocation)}
+}
+
+// add indexes the receipts of a block.
+func (x *receiptIndex) add(block common.Hash, receipts types.Receipts) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for i, r := range receipts {
+		x.txs[r.TxHash] = receiptLocation{block: block, index: i}
+	}
+}
+
+// remove drops the receipts of a block from the index. Transactions that are
+// indexed in another block, such as one of a reorg, are left alone.
+func (x *receiptIndex) remove(block common.Hash, receipts types.Receipts) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for _, r := range receipts {
+		if x.txs[r.TxHash].block == block {
+			delete(x.txs, r.TxHash)
+		}
+	}
+}
+
+// get returns where the receipt of a transaction is.
+func (x *receiptIndex) get(tx common.Hash) (receiptLocation, bool) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	loc, ok := x.txs[tx]
+	return loc, ok
+}
+
+// requestReceipts sends a GetReceipts request for a block once both its header
+// and body are cached, so the receipts can be verified against the header's
+// receipts root and derived from the transactions. Receipts are only requested
+// over eth/69, where they are sent without blooms, and from one peer at a time
+// across all connections.
+func (c *conn) requestReceipts(ann database.BlockAnnouncement) error {
+	if !c.fetchReceipts || c.version < eth.ETH69 {
+		return nil
+	}
+
+	// Peek first so a block evicted from the cache isn't added back by Update.
+	if _, ok := c.conns.Blocks().Peek(ann.Hash); !ok {
+		return nil
+	}
+
+	now := time.Now()
+	var send bool
+	c.conns.Blocks().Update(ann.Hash, func(cache BlockCache) BlockCache {
+		if cache.Header == nil || cache.Body == nil || cache.Receipts != nil ||
+			now.Sub(cache.receiptsRequested) < receiptsRetryInterval {
+			return cache
+		}
+		cache.receiptsRequested = now
+		send = true
+		return cache
+	})
+	if !send {
+		return nil
+	}
+
+	c.requestNum++
+	c.requests.Add(c.requestNum, ann)
+	c.score.requestSent(c.requestNum)
+
+	request := &eth.GetReceiptsPacket69{
+		RequestId:          c.requestNum,
+		GetReceiptsRequest: []common.Hash{ann.Hash},
+	}
+
+	c.countMsgSent(request.Name(), 1)
+	return ethp2p.Send(c.rw, eth.GetReceiptsMsg, request)
+}
+
+func (c *conn) handleReceipts(ctx context.Context, msg ethp2p.Msg) error {
+	var packet eth.ReceiptsPacket69
+	if err := msg.Decode(&packet); err != nil {
+		return err
+	}
+
+	tfs := time.Now()
+
+	lists, err := packet.List.Items()
+	if err != nil {
+		return fmt.Errorf("failed to decode receipts: %w", err)
+	}
+	c.score.responseReceived(packet.RequestId, len(lists) == 0)
+	if len(lists) == 0 {
+		return nil
+	}
+
+	c.countMsgReceived((*eth.ReceiptsRLPResponse)(nil).Name(), float64(len(lists)))
+
+	ann, ok := c.requests.Get(packet.RequestId)
+	if !ok {
+		c.logger.Warn().Msg("No block hash found for receipts")
+		return nil
+	}
+	c.requests.Remove(packet.RequestId)
+
+	cache, ok := c.conns.Blocks().Peek(ann.Hash)
+	if !ok || cache.Header == nil || cache.Body == nil || cache.Receipts != nil {
+		return nil
+	}
+
+	txs, err := cache.Body.Transactions.Items()
+	if err != nil {
+		return nil
+	}
+
+	receipts, err := deriveReceipts(lists[0], cache.Header, txs)
+	if err != nil {
+		c.logger.Warn().
+			Err(err).
+			Str("hash", ann.Hash.Hex()).
+			Uint64("number", cache.Header.Number.Uint64()).
+			Msg("Dropping receipts that do not match the block")
+		c.conns.metrics.receipts.WithLabelValues("invalid").Inc()
+		c.invalidMessage()
+		return nil
+	}
+	c.conns.metrics.receipts.WithLabelValues("verified").Inc()
+
+	var stored bool
+	c.conns.Blocks().Update(ann.Hash, func(cache BlockCache) BlockCache {
+		if cache.Receipts == nil {
+			cache.Receipts = receipts
+			stored = true
+			// Indexed under the cache lock, so the receipts can't be evicted
+			// before they are indexed.
+			c.conns.receipts.add(ann.Hash, receipts)
+		}
+		return cache
+	})
+
+	// Only the first verified copy is written, so concurrent responses from
+	// other peers don't duplicate rows.
+	if stored {
+		c.db.WriteReceipts(ctx, receipts, tfs)
+		c.conns.notifyLogs(receipts)
+	}
+
+	return nil
+}
+
+// handleGetReceipts serves the receipts of cached blocks to eth/69 peers. Other
+// versions expect receipts with blooms and are sent an empty response.
+func (c *conn) handleGetReceipts(msg ethp2p.Msg) error {
+	var request eth.GetReceiptsPacket69
+	if err := msg.Decode(&request); err != nil {
+		return err
+	}
+
+	c.countMsgReceived(request.Name(), float64(len(request.GetReceiptsRequest)))
+
+	response := &ReceiptsRLPPacket{RequestId: request.RequestId}
+	if c.version >= eth.ETH69 {
+		for _, hash := range request.GetReceiptsRequest {
+			cache, ok := c.conns.Blocks().Peek(hash)
+			if !ok || cache.Receipts == nil {
+				continue
+			}
+
+			encoded, err := rlp.EncodeToBytes(eth.NewReceiptList(cache.Receipts))
+			if err != nil {
+				return fmt.Errorf("failed to encode receipts: %w", err)
+			}
+			response.ReceiptsRLPResponse = append(response.ReceiptsRLPResponse, encoded)
+		}
+	}
+
+	c.countMsgSent((&eth.ReceiptsRLPResponse{}).Name(), float64(len(response.ReceiptsRLPResponse)))
+	return ethp2p.Send(c.rw, eth.ReceiptsMsg, response)
+}
+
+// deriveReceipts verifies an eth/69 receipt list against the header's receipts
+// root and fills in the fields that aren't sent over the network, such as the
+// transaction hash, gas used, contract address and log positions.
+func deriveReceipts(list *eth.ReceiptList, header *types.Header, txs []*types.Transaction) (types.Receipts, error) {
+	if root := types.DeriveSha(list.Derivable(), trie.NewStackTrie(nil)); root != header.ReceiptHash {
+		return nil, fmt.Errorf("receipts root %v does not match header %v", root, header.ReceiptHash)
+	}
+
+	enc, err := list.EncodeForStorage()
+	if err != nil {
+		return nil, err
+	}
+	var stored []*types.ReceiptForStorage
+	if err = rlp.DecodeBytes(enc, &stored); err != nil {
+		return nil, fmt.Errorf("failed to decode receipts: %w", err)
+	}
+	if len(stored) != len(txs) {
+		return nil, fmt.Errorf("got %d receipts for %d transactions", len(stored), len(txs))
+	}
+
+	receipts := make(types.Receipts, len(stored))
+	var cumulativeGasUsed uint64
+	var logIndex uint
+	for i, r := range stored {
+		receipt := (*types.Receipt)(r)
+		if receipt.CumulativeGasUsed < cumulativeGasUsed {
+			return nil, fmt.Errorf("receipt %d has decreasing cumulative gas used", i)
+		}
+
+		receipt.DeriveFields(types.LatestSignerForChainID(txs[i].ChainId()), types.DeriveReceiptContext{
+			BlockHash:   header.Hash(),
+			BlockNumber: header.Number.Uint64(),
+			BlockTime:   header.Time,
+			BaseFee:     header.BaseFee,
+			GasUsed:     receipt.CumulativeGasUsed - cumulativeGasUsed,
+			LogIndex:    logIndex,
+			Tx:          txs[i],
+			TxIndex:     uint(i),
+		})
+
+		cumulativeGasUsed = receipt.CumulativeGasUsed
+		logIndex += uint(len(receipt.Logs))
+		receipts[i] = receipt
+	}
+
+	return receipts, nil
+}
@@ -0,0 +1,151 @@
+package p2p
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/trie"
+
+	ds "github.com/0xPolygon/polygon-cli/p2p/datastructures"
+)
+
+// testReceiptBlock builds a header and signed transactions whose receipts each
+// emit one log, with a receipts root matching the returned receipts.
+func testReceiptBlock(t *testing.T, n int) (*types.Header, []*types.Transaction, types.Receipts) {
+	t.Helper()
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := types.LatestSignerForChainID(big.NewInt(137))
+	to := common.HexToAddress("0x01")
+
+	var txs []*types.Transaction
+	var receipts types.Receipts
+	for i := range n {
+		tx, err := types.SignNewTx(key, signer, &types.DynamicFeeTx{
+			ChainID:   big.NewInt(137),
+			Nonce:     uint64(i),
+			GasTipCap: big.NewInt(1),
+			GasFeeCap: big.NewInt(2),
+			Gas:       21000,
+			To:        &to,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		txs = append(txs, tx)
+		receipts = append(receipts, &types.Receipt{
+			Type:              tx.Type(),
+			Status:            types.ReceiptStatusSuccessful,
+			CumulativeGasUsed: uint64(i+1) * 21000,
+			Logs:              []*types.Log{{Address: to, Topics: []common.Hash{{byte(i)}}}},
+		})
+	}
+	for _, r := range receipts {
+		r.Bloom = types.CreateBloom(r)
+	}
+
+	header := &types.Header{
+		Number:      big.NewInt(100),
+		Difficulty:  big.NewInt(1),
+		BaseFee:     big.NewInt(1),
+		ReceiptHash: types.DeriveSha(receipts, trie.NewStackTrie(nil)),
+	}
+	return header, txs, receipts
+}
+
+func TestDeriveReceipts(t *testing.T) {
+	header, txs, receipts := testReceiptBlock(t, 3)
+
+	derived, err := deriveReceipts(eth.NewReceiptList(receipts), header, txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(derived) != len(txs) {
+		t.Fatalf("got %d receipts, want %d", len(derived), len(txs))
+	}
+	for i, r := range derived {
+		if r.TxHash != txs[i].Hash() {
+			t.Errorf("receipt %d: tx hash %v, want %v", i, r.TxHash, txs[i].Hash())
+		}
+		if r.GasUsed != 21000 {
+			t.Errorf("receipt %d: gas used %d, want 21000", i, r.GasUsed)
+		}
+		if r.BlockHash != header.Hash() || r.TransactionIndex != uint(i) {
+			t.Errorf("receipt %d: wrong block hash or index", i)
+		}
+		if r.Logs[0].Index != uint(i) || r.Logs[0].TxHash != txs[i].Hash() {
+			t.Errorf("receipt %d: log index %d, want %d", i, r.Logs[0].Index, i)
+		}
+		if r.EffectiveGasPrice.Cmp(big.NewInt(2)) != 0 {
+			t.Errorf("receipt %d: effective gas price %v, want 2", i, r.EffectiveGasPrice)
+		}
+	}
+}
+
+func TestDeriveReceiptsInvalid(t *testing.T) {
+	header, txs, receipts := testReceiptBlock(t, 2)
+
+	tampered := *header
+	tampered.ReceiptHash = common.Hash{1}
+	if _, err := deriveReceipts(eth.NewReceiptList(receipts), &tampered, txs); err == nil {
+		t.Error("receipts with a mismatched root should be rejected")
+	}
+
+	if _, err := deriveReceipts(eth.NewReceiptList(receipts), header, txs[:1]); err == nil {
+		t.Error("receipts for a different number of transactions should be rejected")
+	}
+}
+
+func TestReceiptIndex(t *testing.T) {
+	index := newReceiptIndex()
+	blocks := ds.NewLRU[common.Hash, BlockCache](ds.LRUOptions{MaxSize: 2})
+	blocks.OnEvict(func(hash common.Hash, cache BlockCache) {
+		index.remove(hash, cache.Receipts)
+	})
+	store := func(hash common.Hash, receipts types.Receipts) {
+		blocks.Update(hash, func(cache BlockCache) BlockCache {
+			cache.Receipts = receipts
+			index.add(hash, receipts)
+			return cache
+		})
+	}
+	receipt := func(tx common.Hash) *types.Receipt {
+		return &types.Receipt{TxHash: tx}
+	}
+
+	a, b, c := common.Hash{0xa}, common.Hash{0xb}, common.Hash{0xc}
+	tx1, tx2, tx3 := common.Hash{1}, common.Hash{2}, common.Hash{3}
+
+	store(a, types.Receipts{receipt(tx1), receipt(tx2)})
+	if loc, ok := index.get(tx2); !ok || loc != (receiptLocation{block: a, index: 1}) {
+		t.Fatalf("tx2: got %+v %v, want block a index 1", loc, ok)
+	}
+
+	// A reorg includes tx1 in block b, which the index follows.
+	store(b, types.Receipts{receipt(tx1)})
+	if loc, _ := index.get(tx1); loc.block != b {
+		t.Errorf("tx1: got block %v, want b", loc.block)
+	}
+
+	// Evicting block a leaves tx1 in block b.
+	store(c, types.Receipts{receipt(tx3)})
+	if _, ok := index.get(tx2); ok {
+		t.Error("tx2 should be evicted with block a")
+	}
+	if loc, ok := index.get(tx1); !ok || loc.block != b {
+		t.Errorf("tx1: got %+v %v, want block b", loc, ok)
+	}
+
+	blocks.Remove(b)
+	blocks.Remove(c)
+	if len(index.txs) != 0 {
+		t.Errorf("got %d indexed receipts after removing every block, want 0", len(index.txs))
+	}
+}
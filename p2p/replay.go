@@ -0,0 +1,467 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	ethp2p "github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/rs/zerolog/log"
+)
+
+// ethOffset is the code of the first eth message, which follows the 16 devp2p
+// base codes.
+const ethOffset = 16
+
+// ReplayOptions configures Replay.
+type ReplayOptions struct {
+	Dial DialOpts
+	// Speed scales the captured timing, so 2 replays twice as fast. Messages
+	// are sent as fast as possible if zero.
+	Speed float64
+	// Peers limits the replayed messages to those received from these node
+	// IDs. Every peer's messages are replayed if empty.
+	Peers []string
+	// Linger is how long to keep answering the target's requests after the
+	// last message is sent.
+	Linger time.Duration
+}
+
+// ReplayStats summarizes a replay.
+type ReplayStats struct {
+	// Version is the eth protocol version negotiated with the target.
+	Version uint `json:"version"`
+	// Sent counts the messages sent, by name.
+	Sent map[string]int `json:"sent"`
+	// Skipped counts the captured messages from the replayed peers that were
+	// not sent: requests, responses and announcements in a format the
+	// negotiated version doesn't use.
+	Skipped int `json:"skipped"`
+	// Served counts the target's requests answered, by name.
+	Served map[string]int `json:"served"`
+	// Missing counts the requested bodies and transactions the capture doesn't
+	// hold, and the header requests it couldn't answer at all.
+	Missing int `json:"missing"`
+	// Elapsed is the duration of the replay in milliseconds.
+	Elapsed int64 `json:"elapsed_ms"`
+	// Reason is the disconnect reason sent by the target.
+	Reason string `json:"reason,omitempty"`
+}
+
+// replayBodyKey identifies a block body by the roots its header commits to.
+type replayBodyKey struct {
+	txHash    common.Hash
+	uncleHash common.Hash
+}
+
+// replayIndex holds the blocks and transactions of a capture, to answer the
+// target's requests with.
+type replayIndex struct {
+	headers map[common.Hash]*types.Header
+	numbers map[uint64]common.Hash
+	bodies  map[replayBodyKey]rlp.RawValue
+	txs     map[common.Hash]rlp.RawValue
+}
+
+// rawTxList is a list of encoded transactions whose root can be derived
+// without decoding them.
+type rawTxList []rlp.RawValue
+
+// Len implements types.DerivableList.
+func (l rawTxList) Len() int { return len(l) }
+
+// EncodeIndex implements types.DerivableList.
+func (l rawTxList) EncodeIndex(i int, w *bytes.Buffer) {
+	w.Write(txEnvelope(l[i]))
+}
+
+// txEnvelope returns the consensus encoding of a transaction in a list, which
+// wraps typed transactions in an RLP string.
+func txEnvelope(raw rlp.RawValue) []byte {
+	kind, content, _, err := rlp.Split(raw)
+	if err == nil && kind == rlp.String {
+		return content
+	}
+	return raw
+}
+
+// newReplayIndex indexes the headers, bodies and transactions of every record,
+// skipping payloads that don't decode.
+func newReplayIndex(records []CaptureRecord) *replayIndex {
+	idx := &replayIndex{
+		headers: make(map[common.Hash]*types.Header),
+		numbers: make(map[uint64]common.Hash),
+		bodies:  make(map[replayBodyKey]rlp.RawValue),
+		txs:     make(map[common.Hash]rlp.RawValue),
+	}
+
+	for _, r := range records {
+		switch r.Code {
+		case eth.BlockHeadersMsg:
+			var packet struct {
+				RequestId uint64
+				Headers   []*types.Header
+			}
+			if rlp.DecodeBytes(r.Payload, &packet) == nil {
+				for _, header := range packet.Headers {
+					idx.addHeader(header)
+				}
+			}
+		case eth.BlockBodiesMsg:
+			var packet struct {
+				RequestId uint64
+				Bodies    []rlp.RawValue
+			}
+			if rlp.DecodeBytes(r.Payload, &packet) == nil {
+				for _, raw := range packet.Bodies {
+					var body rawBlockBody
+					if rlp.DecodeBytes(raw, &body) != nil {
+						continue
+					}
+					idx.addBody(body, raw)
+				}
+			}
+		case eth.NewBlockMsg:
+			var packet rawNewBlockPacket
+			if rlp.DecodeBytes(r.Payload, &packet) != nil || packet.Block.Header == nil {
+				continue
+			}
+			body := rawBlockBody{
+				Transactions: packet.Block.Txs,
+				Uncles:       packet.Block.Uncles,
+				Withdrawals:  packet.Block.Withdrawals,
+			}
+			raw, err := rlp.EncodeToBytes(body)
+			if err != nil {
+				continue
+			}
+			idx.addHeader(packet.Block.Header)
+			idx.addBody(body, raw)
+		case eth.TransactionsMsg:
+			var txs []rlp.RawValue
+			if rlp.DecodeBytes(r.Payload, &txs) == nil {
+				idx.addTxs(txs)
+			}
+		case eth.PooledTransactionsMsg:
+			var packet rawPooledTransactionsPacket
+			if rlp.DecodeBytes(r.Payload, &packet) == nil {
+				idx.addTxs(packet.Txs)
+			}
+		}
+	}
+
+	return idx
+}
+
+func (idx *replayIndex) addHeader(header *types.Header) {
+	hash := header.Hash()
+	idx.headers[hash] = header
+	if _, ok := idx.numbers[header.Number.Uint64()]; !ok {
+		idx.numbers[header.Number.Uint64()] = hash
+	}
+}
+
+func (idx *replayIndex) addBody(body rawBlockBody, raw rlp.RawValue) {
+	key := replayBodyKey{
+		txHash:    types.DeriveSha(rawTxList(body.Transactions), trie.NewStackTrie(nil)),
+		uncleHash: types.CalcUncleHash(body.Uncles),
+	}
+	idx.bodies[key] = raw
+	idx.addTxs(body.Transactions)
+}
+
+func (idx *replayIndex) addTxs(txs []rlp.RawValue) {
+	for _, raw := range txs {
+		idx.txs[crypto.Keccak256Hash(txEnvelope(raw))] = raw
+	}
+}
+
+// blockHeaders answers a header query from the indexed headers, stopping at the
+// first block the capture doesn't hold.
+func (idx *replayIndex) blockHeaders(req *eth.GetBlockHeadersRequest) []*types.Header {
+	if req == nil || req.Amount == 0 {
+		return nil
+	}
+
+	var headers []*types.Header
+	number := req.Origin.Number
+	if req.Origin.Hash != (common.Hash{}) {
+		header, ok := idx.headers[req.Origin.Hash]
+		if !ok {
+			return nil
+		}
+		headers = append(headers, header)
+		number = header.Number.Uint64()
+	} else if hash, ok := idx.numbers[number]; ok {
+		headers = append(headers, idx.headers[hash])
+	} else {
+		return nil
+	}
+
+	amount := min(req.Amount, fuzzMaxHeaders)
+	step := req.Skip + 1
+	for uint64(len(headers)) < amount && step > 0 {
+		if req.Reverse {
+			if number < step {
+				break
+			}
+			number -= step
+		} else {
+			if number+step < number {
+				break
+			}
+			number += step
+		}
+
+		hash, ok := idx.numbers[number]
+		if !ok {
+			break
+		}
+		headers = append(headers, idx.headers[hash])
+	}
+
+	return headers
+}
+
+// ethVersion returns the highest eth version negotiated with the peer.
+func (c *rlpxConn) ethVersion() uint {
+	var version uint
+	for _, cap := range c.caps {
+		if cap.Name == "eth" && cap.Version > version && c.hasCap(cap.Name, cap.Version) {
+			version = cap.Version
+		}
+	}
+	return version
+}
+
+// replayConn serializes the writes of the replay and of the goroutine
+// answering the target's requests.
+type replayConn struct {
+	*rlpxConn
+	mu sync.Mutex
+}
+
+func (c *replayConn) send(code uint64, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.writeRaw(code, payload)
+}
+
+func (c *replayConn) sendMsg(msg Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Write(msg)
+}
+
+// replayable reports whether a captured message is an announcement or
+// broadcast the target can receive on the negotiated version. Transaction
+// hash announcements changed format in eth/68.
+func replayable(r CaptureRecord, version uint) bool {
+	switch r.Code {
+	case eth.NewBlockHashesMsg, eth.TransactionsMsg, eth.NewBlockMsg:
+		return true
+	case eth.NewPooledTransactionHashesMsg:
+		return (r.Version >= 68) == (version >= 68)
+	}
+	return false
+}
+
+// Replay dials a node and plays the announcements and broadcasts of a capture
+// back to it as a peer, with the captured timing scaled by the speed. The
+// target's requests for headers, bodies and pooled transactions are answered
+// with the responses and blocks in the capture.
+func Replay(ctx context.Context, n *enode.Node, records []CaptureRecord, opts ReplayOptions) (*ReplayStats, error) {
+	stats := &ReplayStats{
+		Sent:   make(map[string]int),
+		Served: make(map[string]int),
+	}
+	start := time.Now()
+	defer func() { stats.Elapsed = time.Since(start).Milliseconds() }()
+
+	idx := newReplayIndex(records)
+
+	var replayed []CaptureRecord
+	for _, r := range records {
+		if len(opts.Peers) == 0 || slices.Contains(opts.Peers, r.Peer) {
+			replayed = append(replayed, r)
+		}
+	}
+	slices.SortStableFunc(replayed, func(a, b CaptureRecord) int { return a.Time.Compare(b.Time) })
+
+	rlpx, err := Dial(n, opts.Dial)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := rlpx.Peer(); err != nil {
+		_ = rlpx.Close()
+		return nil, err
+	}
+	conn := &replayConn{rlpxConn: rlpx}
+	stats.Version = rlpx.ethVersion()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		serveReplay(conn, idx, stats)
+	}()
+
+	defer func() {
+		_ = conn.sendMsg(&Disconnect{Reason: ethp2p.DiscRequested})
+		_ = conn.Close()
+		<-done
+	}()
+
+	// wait blocks until the deadline, returning an error if the replay must
+	// stop first.
+	wait := func(deadline time.Time) error {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-done:
+			return fmt.Errorf("peer disconnected")
+		}
+	}
+
+	sendStart := time.Now()
+	for _, r := range replayed {
+		if !replayable(r, stats.Version) {
+			stats.Skipped++
+			continue
+		}
+
+		if opts.Speed > 0 {
+			offset := time.Duration(float64(r.Time.Sub(replayed[0].Time)) / opts.Speed)
+			if err := wait(sendStart.Add(offset)); err != nil {
+				return stats, err
+			}
+		} else {
+			select {
+			case <-ctx.Done():
+				return stats, ctx.Err()
+			case <-done:
+				return stats, fmt.Errorf("peer disconnected")
+			default:
+			}
+		}
+
+		if err := conn.send(ethOffset+r.Code, r.Payload); err != nil {
+			return stats, fmt.Errorf("failed to send %s: %w", ethMsgName(r.Code), err)
+		}
+		stats.Sent[ethMsgName(r.Code)]++
+		log.Trace().Str("msg", ethMsgName(r.Code)).Str("from", r.Peer).Msg("Replayed message")
+	}
+
+	if err := wait(time.Now().Add(opts.Linger)); err != nil && ctx.Err() == nil {
+		return stats, err
+	}
+
+	return stats, nil
+}
+
+// serveReplay answers the target's requests until the connection is closed.
+// The stats it updates must only be read after it returns.
+func serveReplay(conn *replayConn, idx *replayIndex, stats *ReplayStats) {
+	for {
+		var (
+			name string
+			err  error
+		)
+
+		switch msg := conn.Read().(type) {
+		case *Ping:
+			err = conn.sendMsg(&Pong{})
+		case *Disconnect:
+			stats.Reason = msg.Reason.String()
+			return
+		case *Disconnects:
+			if len(*msg) > 0 {
+				stats.Reason = (*msg)[0].String()
+			}
+			return
+		case *GetBlockHeaders:
+			name = "GetBlockHeaders"
+			headers := idx.blockHeaders(msg.GetBlockHeadersRequest)
+			if len(headers) == 0 {
+				stats.Missing++
+			}
+			var list rlp.RawList[*types.Header]
+			if list, err = rlp.EncodeToRawList(headers); err == nil {
+				err = conn.sendMsg(&BlockHeaders{RequestId: msg.RequestId, List: list})
+			}
+		case *GetBlockBodies:
+			name = "GetBlockBodies"
+			var bodies []rlp.RawValue
+			for _, hash := range msg.GetBlockBodiesRequest {
+				header, ok := idx.headers[hash]
+				if !ok {
+					stats.Missing++
+					continue
+				}
+				body, ok := idx.bodies[replayBodyKey{header.TxHash, header.UncleHash}]
+				if !ok {
+					stats.Missing++
+					continue
+				}
+				bodies = append(bodies, body)
+			}
+			err = conn.sendRLP(BlockBodies{}.Code(), eth.BlockBodiesRLPPacket{
+				RequestId:              msg.RequestId,
+				BlockBodiesRLPResponse: bodies,
+			})
+		case *GetPooledTransactions:
+			name = "GetPooledTransactions"
+			var txs []rlp.RawValue
+			for _, hash := range msg.GetPooledTransactionsRequest {
+				if tx, ok := idx.txs[hash]; ok {
+					txs = append(txs, tx)
+				} else {
+					stats.Missing++
+				}
+			}
+			err = conn.sendRLP(PooledTransactions{}.Code(), eth.PooledTransactionsRLPPacket{
+				RequestId:                     msg.RequestId,
+				PooledTransactionsRLPResponse: txs,
+			})
+		case *Error:
+			if strings.Contains(msg.Error(), "could not read from connection") {
+				return
+			}
+			// Messages we can't decode are skipped, since they may be ones the
+			// replay doesn't need to understand.
+			log.Trace().Err(msg).Msg("Skipping undecodable message")
+		}
+
+		if err != nil {
+			log.Debug().Err(err).Msg("Failed to answer target")
+			return
+		}
+		if name != "" {
+			stats.Served[name]++
+		}
+	}
+}
+
+// sendRLP encodes a response and sends it with the given wire code.
+func (c *replayConn) sendRLP(code int, val any) error {
+	payload, err := rlp.EncodeToBytes(val)
+	if err != nil {
+		return err
+	}
+	return c.send(uint64(code), payload)
+}
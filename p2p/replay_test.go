@@ -0,0 +1,153 @@
+package p2p
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+func TestCaptureRoundTrip(t *testing.T) {
+	for _, name := range []string{"capture.jsonl", "capture.jsonl.gz"} {
+		path := filepath.Join(t.TempDir(), name)
+		w, err := NewCaptureWriter(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		at := time.Unix(100, 5).UTC()
+		if err := w.Write(at, "a", 68, eth.TransactionsMsg, []byte{0xc0}); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Write(at.Add(time.Second), "b", 69, eth.NewBlockHashesMsg, []byte{0xc1, 0xc0}); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		records, err := ReadCapture(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(records) != 2 {
+			t.Fatalf("%s: want 2 records, got %d", name, len(records))
+		}
+		if r := records[0]; !r.Time.Equal(at) || r.Peer != "a" || r.Version != 68 ||
+			r.Code != eth.TransactionsMsg || r.Name != "Transactions" || string(r.Payload) != "\xc0" {
+			t.Fatalf("%s: first record: %+v", name, r)
+		}
+		if r := records[1]; r.Peer != "b" || r.Name != "NewBlockHashes" || len(r.Payload) != 2 {
+			t.Fatalf("%s: second record: %+v", name, r)
+		}
+	}
+}
+
+func replayTestBlock(t *testing.T, number uint64, parent common.Hash) (*types.Block, *types.Transaction) {
+	t.Helper()
+
+	tx := types.NewTx(&types.DynamicFeeTx{Nonce: number, Gas: 21000})
+	header := &types.Header{Number: new(big.Int).SetUint64(number), ParentHash: parent}
+	block := types.NewBlock(header, &types.Body{Transactions: []*types.Transaction{tx}}, nil, trie.NewStackTrie(nil))
+	return block, tx
+}
+
+func TestReplayIndex(t *testing.T) {
+	first, firstTx := replayTestBlock(t, 10, common.Hash{})
+	second, secondTx := replayTestBlock(t, 11, first.Hash())
+
+	newBlock, err := rlp.EncodeToBytes(&NewBlockPacket{Block: first, TD: big.NewInt(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers, err := rlp.EncodeToBytes(&eth.BlockHeadersRLPPacket{
+		RequestId:               1,
+		BlockHeadersRLPResponse: []rlp.RawValue{mustEncode(t, second.Header())},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bodies, err := rlp.EncodeToBytes(&eth.BlockBodiesRLPPacket{
+		RequestId:              2,
+		BlockBodiesRLPResponse: []rlp.RawValue{mustEncode(t, &rawBlockBody{Transactions: []rlp.RawValue{mustEncode(t, secondTx)}})},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx := newReplayIndex([]CaptureRecord{
+		{Code: eth.NewBlockMsg, Payload: newBlock},
+		{Code: eth.BlockHeadersMsg, Payload: headers},
+		{Code: eth.BlockBodiesMsg, Payload: bodies},
+	})
+
+	for _, block := range []*types.Block{first, second} {
+		header, ok := idx.headers[block.Hash()]
+		if !ok {
+			t.Fatalf("block %d: header not indexed", block.NumberU64())
+		}
+		raw, ok := idx.bodies[replayBodyKey{header.TxHash, header.UncleHash}]
+		if !ok {
+			t.Fatalf("block %d: body not indexed", block.NumberU64())
+		}
+		var body rawBlockBody
+		if err := rlp.DecodeBytes(raw, &body); err != nil || len(body.Transactions) != 1 {
+			t.Fatalf("block %d: body %x: %v", block.NumberU64(), raw, err)
+		}
+	}
+	for _, tx := range []*types.Transaction{firstTx, secondTx} {
+		if _, ok := idx.txs[tx.Hash()]; !ok {
+			t.Fatalf("transaction %s not indexed", tx.Hash())
+		}
+	}
+
+	got := idx.blockHeaders(&eth.GetBlockHeadersRequest{Origin: eth.HashOrNumber{Number: 10}, Amount: 5})
+	if len(got) != 2 || got[0].Hash() != first.Hash() || got[1].Hash() != second.Hash() {
+		t.Fatalf("forward headers: %v", got)
+	}
+	got = idx.blockHeaders(&eth.GetBlockHeadersRequest{Origin: eth.HashOrNumber{Hash: second.Hash()}, Amount: 2, Reverse: true})
+	if len(got) != 2 || got[0].Hash() != second.Hash() || got[1].Hash() != first.Hash() {
+		t.Fatalf("reverse headers: %v", got)
+	}
+	if got := idx.blockHeaders(&eth.GetBlockHeadersRequest{Origin: eth.HashOrNumber{Number: 12}, Amount: 1}); len(got) != 0 {
+		t.Fatalf("unknown origin: %v", got)
+	}
+}
+
+func TestReplayable(t *testing.T) {
+	tests := []struct {
+		record  CaptureRecord
+		version uint
+		want    bool
+	}{
+		{CaptureRecord{Code: eth.NewBlockMsg, Version: 69}, 68, true},
+		{CaptureRecord{Code: eth.NewPooledTransactionHashesMsg, Version: 69}, 68, true},
+		{CaptureRecord{Code: eth.NewPooledTransactionHashesMsg, Version: 67}, 68, false},
+		{CaptureRecord{Code: eth.NewPooledTransactionHashesMsg, Version: 66}, 67, true},
+		{CaptureRecord{Code: eth.BlockBodiesMsg, Version: 68}, 68, false},
+		{CaptureRecord{Code: eth.GetBlockHeadersMsg, Version: 68}, 68, false},
+	}
+
+	for _, tt := range tests {
+		if got := replayable(tt.record, tt.version); got != tt.want {
+			t.Errorf("replayable(%d from eth/%d, eth/%d) = %v, want %v",
+				tt.record.Code, tt.record.Version, tt.version, got, tt.want)
+		}
+	}
+}
+
+func mustEncode(t *testing.T, val any) rlp.RawValue {
+	t.Helper()
+
+	b, err := rlp.EncodeToBytes(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
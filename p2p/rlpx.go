@@ -24,6 +24,7 @@ var (
 
 type DialOpts struct {
 	EnableWit  bool
+	EnableSnap bool
 	Port       int
 	Addr       net.IP
 	PrivateKey *ecdsa.PrivateKey
@@ -37,6 +38,7 @@ func NewDialOpts() DialOpts {
 
 	return DialOpts{
 		EnableWit:  false,
+		EnableSnap: false,
 		Port:       30303,
 		Addr:       net.ParseIP("127.0.0.1"),
 		PrivateKey: privateKey,
@@ -55,6 +57,11 @@ func Dial(n *enode.Node, opts DialOpts) (*rlpxConn, error) {
 		{Name: "eth", Version: 66},
 		{Name: "eth", Version: 67},
 		{Name: "eth", Version: 68},
+		{Name: "eth", Version: 69},
+	}
+
+	if opts.EnableSnap {
+		caps = append(caps, p2p.Cap{Name: "snap", Version: 1})
 	}
 
 	if opts.EnableWit {
@@ -160,7 +167,11 @@ loop:
 		}
 	}
 
-	if err := c.Write(status); err != nil {
+	payload, err := status.encode()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.writeRaw(uint64(status.Code()), payload); err != nil {
 		return nil, fmt.Errorf("write to connection failed: %v", err)
 	}
 
@@ -403,6 +414,25 @@ func (c *rlpxConn) QueryHeaders(start, count uint64) error {
 	return nil
 }
 
+// QueryHeaderByHash requests the block header with the given hash.
+func (c *rlpxConn) QueryHeaderByHash(hash common.Hash) error {
+	c.logger.Trace().Msgf("Querying header %v", hash)
+
+	req := &GetBlockHeaders{
+		RequestId: rand.Uint64(),
+		GetBlockHeadersRequest: &eth.GetBlockHeadersRequest{
+			Origin: eth.HashOrNumber{Hash: hash},
+			Amount: 1,
+		},
+	}
+	if err := c.Write(req); err != nil {
+		c.logger.Error().Err(err).Msg("Failed to write GetBlockHeaders request")
+		return err
+	}
+
+	return nil
+}
+
 // ListenHeaders keeps listening for requested headers from the p2p connection.
 func (c *rlpxConn) ListenHeaders() (eth.BlockHeadersRequest, error) {
 	for {
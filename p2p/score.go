@@ -0,0 +1,431 @@
+package p2p
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethp2p "github.com/ethereum/go-ethereum/p2p"
+	"github.com/rs/zerolog/log"
+
+	ds "github.com/0xPolygon/polygon-cli/p2p/datastructures"
+)
+
+const (
+	// Weights of the score components. They sum to 1 so a well behaved peer
+	// scores 1 before the invalid message penalty.
+	usefulWeight         = 0.4
+	timelinessWeight     = 0.3
+	responsivenessWeight = 0.3
+
+	// delayAlpha is the smoothing factor of the announcement delay and response
+	// time averages.
+	delayAlpha = 0.2
+	// maxDelaySample caps a single delay sample, so a re-announcement of an old
+	// block does not dominate the average.
+	maxDelaySample = time.Minute
+	// responseTimeout is how long a request may go unanswered before it counts
+	// against the peer's responsiveness.
+	responseTimeout = 10 * time.Second
+	// maxPendingRequests bounds the requests tracked per peer.
+	maxPendingRequests = 1024
+	// maxAnnouncedBlocks bounds the block hashes tracked per peer to count each
+	// announced block once.
+	maxAnnouncedBlocks = 1024
+)
+
+// ScoreOptions configures peer scoring and eviction.
+type ScoreOptions struct {
+	// Interval is the time between scoring rounds. Scoring is disabled if zero.
+	Interval time.Duration
+	// Evict enables disconnecting low scoring peers.
+	Evict bool
+	// MinAge is how long a peer is connected before it can be evicted, so it has
+	// had the chance to announce blocks.
+	MinAge time.Duration
+	// Threshold is the score below which a peer can be evicted.
+	Threshold float64
+	// MaxEvictions is the most peers evicted per round.
+	MaxEvictions int
+	// MaxPeers is the sensor's peer limit. Peers are only evicted when the
+	// sensor is within MaxEvictions of it, since otherwise there is room for
+	// new peers anyway.
+	MaxPeers int
+	// Cooldown is how long an evicted peer is refused, so its slot goes to a
+	// newly discovered node.
+	Cooldown time.Duration
+	// LatencyScale is the announcement delay at which the timeliness component
+	// drops to half.
+	LatencyScale time.Duration
+}
+
+// PeerScore is a snapshot of a peer's score and the statistics it is computed
+// from. Each component is between 0 and 1.
+type PeerScore struct {
+	// Score combines the components, divided by one plus the number of invalid
+	// messages.
+	Score float64
+	// Useful is the share of the blocks first seen by the sensor since the peer
+	// connected that the peer also announced.
+	Useful float64
+	// Timeliness decreases with the average delay of the peer's block
+	// announcements behind the first announcement the sensor received.
+	Timeliness float64
+	// Responsiveness is the share of header and body requests the peer
+	// answered.
+	Responsiveness float64
+
+	Blocks       uint64
+	Delay        time.Duration
+	Invalid      uint64
+	Requests     uint64
+	Responses    uint64
+	ResponseTime time.Duration
+}
+
+// scorer holds the sensor-wide state peers are scored against.
+type scorer struct {
+	opts ScoreOptions
+
+	// firstSeen maps block hashes to when the sensor first received an
+	// announcement of them, and blocks counts them.
+	firstSeen *ds.LRU[common.Hash, time.Time]
+	blocks    atomic.Uint64
+
+	// evicted holds the IDs of evicted peers until their cooldown expires.
+	evicted *ds.LRU[string, struct{}]
+}
+
+// newScorer applies defaults to the options and creates a scorer.
+func newScorer(opts ScoreOptions) *scorer {
+	if opts.MinAge <= 0 {
+		opts.MinAge = 5 * time.Minute
+	}
+	if opts.MaxEvictions <= 0 {
+		opts.MaxEvictions = 10
+	}
+	if opts.LatencyScale <= 0 {
+		opts.LatencyScale = 500 * time.Millisecond
+	}
+
+	return &scorer{
+		opts:      opts,
+		firstSeen: ds.NewLRU[common.Hash, time.Time](ds.LRUOptions{MaxSize: 4096}),
+		evicted:   ds.NewLRU[string, struct{}](ds.LRUOptions{MaxSize: 10000, TTL: opts.Cooldown}),
+	}
+}
+
+// firstSeenAt records an announcement of a block at t and returns when the
+// sensor first received an announcement of it.
+func (s *scorer) firstSeenAt(hash common.Hash, t time.Time) time.Time {
+	first := t
+	existed := s.firstSeen.Update(hash, func(v time.Time) time.Time {
+		if !v.IsZero() {
+			first = v
+			return v
+		}
+		return t
+	})
+	if !existed {
+		s.blocks.Add(1)
+	}
+	return first
+}
+
+// isEvicted reports whether the peer was evicted and its cooldown has not
+// expired.
+func (s *scorer) isEvicted(peerID string) bool {
+	if s == nil || s.opts.Cooldown <= 0 {
+		return false
+	}
+	_, ok := s.evicted.Peek(peerID)
+	return ok
+}
+
+// peerScore tracks the behaviour of a single peer. A nil peerScore ignores all
+// updates.
+type peerScore struct {
+	mu sync.Mutex
+
+	scorer      *scorer
+	startBlocks uint64
+	announced   *ds.BoundedSet[common.Hash]
+
+	blocks       uint64
+	delay        time.Duration
+	invalid      uint64
+	pending      map[uint64]time.Time
+	requests     uint64
+	responses    uint64
+	unanswered   uint64
+	responseTime time.Duration
+}
+
+// newPeerScore starts scoring a peer that has just connected.
+func (s *scorer) newPeerScore() *peerScore {
+	if s == nil {
+		return nil
+	}
+
+	return &peerScore{
+		scorer:      s,
+		startBlocks: s.blocks.Load(),
+		announced:   ds.NewBoundedSet[common.Hash](maxAnnouncedBlocks),
+		pending:     make(map[uint64]time.Time),
+	}
+}
+
+// ewma folds a sample into a smoothed average, starting from the first sample.
+func ewma(avg, sample time.Duration, first bool) time.Duration {
+	if first {
+		return sample
+	}
+	return avg + time.Duration(delayAlpha*float64(sample-avg))
+}
+
+// blockAnnounced records an announcement of a block received at t.
+func (p *peerScore) blockAnnounced(hash common.Hash, t time.Time) {
+	if p == nil {
+		return
+	}
+
+	delay := min(t.Sub(p.scorer.firstSeenAt(hash, t)), maxDelaySample)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.announced.Contains(hash) {
+		return
+	}
+	p.announced.Add(hash)
+
+	p.delay = ewma(p.delay, delay, p.blocks == 0)
+	p.blocks++
+}
+
+// invalidMessage records a message or transaction that failed to decode or
+// verify.
+func (p *peerScore) invalidMessage() {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.invalid++
+}
+
+// invalidMessage records a message or transaction from the peer that failed to
+// decode or verify.
+func (c *conn) invalidMessage() {
+	c.score.invalidMessage()
+	if c.conns != nil {
+		c.conns.metrics.invalid.Inc()
+	}
+}
+
+// requestSent records a header or body request.
+func (p *peerScore) requestSent(id uint64) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.expire(time.Now())
+	if len(p.pending) >= maxPendingRequests {
+		p.unanswered++
+		return
+	}
+	p.pending[id] = time.Now()
+	p.requests++
+}
+
+// responseReceived records a response to a request. Empty responses do not
+// count as answered.
+func (p *peerScore) responseReceived(id uint64, empty bool) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sent, ok := p.pending[id]
+	if !ok {
+		return
+	}
+	delete(p.pending, id)
+
+	if empty {
+		p.unanswered++
+		return
+	}
+
+	p.responseTime = ewma(p.responseTime, time.Since(sent), p.responses == 0)
+	p.responses++
+}
+
+// expire counts requests pending for longer than responseTimeout as
+// unanswered. The caller must hold the lock.
+func (p *peerScore) expire(now time.Time) {
+	for id, sent := range p.pending {
+		if now.Sub(sent) > responseTimeout {
+			delete(p.pending, id)
+			p.unanswered++
+		}
+	}
+}
+
+// snapshot computes the peer's current score.
+func (p *peerScore) snapshot() PeerScore {
+	if p == nil {
+		return PeerScore{}
+	}
+
+	// Read the sensor-wide count before locking, like blockAnnounced, which
+	// updates it before taking the lock.
+	blocks := p.scorer.blocks.Load()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.expire(time.Now())
+
+	s := PeerScore{
+		Useful:         1,
+		Timeliness:     1,
+		Responsiveness: 1,
+		Blocks:         p.blocks,
+		Delay:          p.delay,
+		Invalid:        p.invalid,
+		Requests:       p.requests,
+		Responses:      p.responses,
+		ResponseTime:   p.responseTime,
+	}
+
+	// Without new blocks since the peer connected there is nothing it could
+	// have announced, so the announcement components stay neutral.
+	if expected := blocks - p.startBlocks; expected > 0 {
+		s.Useful = min(1, float64(p.blocks)/float64(expected))
+		s.Timeliness = 0
+		if p.blocks > 0 {
+			s.Timeliness = 1 / (1 + float64(p.delay)/float64(p.scorer.opts.LatencyScale))
+		}
+	}
+
+	if answered := p.responses + p.unanswered; answered > 0 {
+		s.Responsiveness = float64(p.responses) / float64(answered)
+	}
+
+	s.Score = (usefulWeight*s.Useful + timelinessWeight*s.Timeliness + responsivenessWeight*s.Responsiveness) /
+		float64(1+p.invalid)
+
+	return s
+}
+
+// GetPeerScore returns the current score of a peer. Returns false if the peer is
+// not found or scoring is disabled.
+func (c *Conns) GetPeerScore(peerID string) (PeerScore, bool) {
+	c.mu.RLock()
+	cn, ok := c.conns[peerID]
+	c.mu.RUnlock()
+
+	if !ok || cn.score == nil {
+		return PeerScore{}, false
+	}
+	return cn.score.snapshot(), true
+}
+
+// scoreLoop scores peers every interval until the connection manager is closed.
+func (c *Conns) scoreLoop() {
+	ticker := time.NewTicker(c.scorer.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.closeCh:
+			return
+		case <-ticker.C:
+			c.scorePeers()
+		}
+	}
+}
+
+// scoredConn pairs a connection with its score for sorting.
+type scoredConn struct {
+	conn  *conn
+	score PeerScore
+}
+
+// scorePeers updates the score metrics and, if enabled, evicts the lowest
+// scoring peers below the threshold.
+func (c *Conns) scorePeers() {
+	peers := c.snapshotPeers()
+	if len(peers) == 0 {
+		return
+	}
+
+	scored := make([]scoredConn, 0, len(peers))
+	for _, cn := range peers {
+		scored = append(scored, scoredConn{conn: cn, score: cn.score.snapshot()})
+	}
+	sort.Slice(scored, func(i, j int) bool { return scored[i].score.Score < scored[j].score.Score })
+
+	scores := make([]float64, len(scored))
+	for i, s := range scored {
+		scores[i] = s.score.Score
+	}
+	c.metrics.updateScores(scores)
+
+	opts := c.scorer.opts
+	if !opts.Evict || len(peers) < opts.MaxPeers-opts.MaxEvictions {
+		return
+	}
+
+	evicted := 0
+	for _, s := range scored {
+		if evicted == opts.MaxEvictions || s.score.Score >= opts.Threshold {
+			break
+		}
+		if time.Since(s.conn.connectedAt) < opts.MinAge {
+			continue
+		}
+		if info := s.conn.peer.Info(); info.Network.Static || info.Network.Trusted {
+			continue
+		}
+
+		peerID := s.conn.node.ID().String()
+		c.scorer.evicted.Add(peerID, struct{}{})
+		s.conn.peer.Disconnect(ethp2p.DiscUselessPeer)
+		c.metrics.evictions.Inc()
+		evicted++
+
+		s.conn.logger.Info().
+			Float64("score", s.score.Score).
+			Float64("useful", s.score.Useful).
+			Float64("timeliness", s.score.Timeliness).
+			Float64("responsiveness", s.score.Responsiveness).
+			Uint64("invalid", s.score.Invalid).
+			Msg("Evicting low scoring peer")
+	}
+
+	if evicted > 0 {
+		log.Info().Int("evicted", evicted).Int("peers", len(peers)).Msg("Evicted low scoring peers")
+	}
+}
+
+// scoreQuantiles are the quantiles of peer scores exposed as metrics.
+var scoreQuantiles = []float64{0, 0.1, 0.5, 0.9, 1}
+
+// quantile returns the q-quantile of sorted values using the nearest rank.
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(q * float64(len(sorted)-1))
+	return sorted[i]
+}
@@ -0,0 +1,155 @@
+package p2p
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethp2p "github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/rs/zerolog"
+)
+
+func TestPeerScoreNeutral(t *testing.T) {
+	s := newScorer(ScoreOptions{Interval: time.Minute})
+
+	// A peer that has had nothing to announce or answer is not penalised.
+	if got := s.newPeerScore().snapshot().Score; got != 1 {
+		t.Fatalf("want neutral score 1, got %v", got)
+	}
+
+	var nilScore *peerScore
+	nilScore.blockAnnounced(common.Hash{1}, time.Now())
+	nilScore.invalidMessage()
+	if got := nilScore.snapshot(); got != (PeerScore{}) {
+		t.Fatalf("want zero score from nil, got %+v", got)
+	}
+}
+
+func TestPeerScoreAnnouncements(t *testing.T) {
+	s := newScorer(ScoreOptions{Interval: time.Minute, LatencyScale: time.Second})
+	fast, slow, silent := s.newPeerScore(), s.newPeerScore(), s.newPeerScore()
+
+	now := time.Now()
+	for i := range 4 {
+		hash := common.Hash{byte(i + 1)}
+		fast.blockAnnounced(hash, now)
+		fast.blockAnnounced(hash, now) // Re-announcements count once.
+		if i%2 == 0 {
+			slow.blockAnnounced(hash, now.Add(time.Second))
+		}
+	}
+
+	f := fast.snapshot()
+	if f.Blocks != 4 || f.Useful != 1 || f.Timeliness != 1 || f.Score != 1 {
+		t.Fatalf("fast peer: %+v", f)
+	}
+
+	sl := slow.snapshot()
+	if sl.Blocks != 2 || sl.Useful != 0.5 || sl.Delay != time.Second || sl.Timeliness != 0.5 {
+		t.Fatalf("slow peer: %+v", sl)
+	}
+	if want := usefulWeight*0.5 + timelinessWeight*0.5 + responsivenessWeight; math.Abs(sl.Score-want) > 1e-9 {
+		t.Fatalf("slow peer: want score %v got %v", want, sl.Score)
+	}
+
+	si := silent.snapshot()
+	if si.Useful != 0 || si.Timeliness != 0 || si.Score != responsivenessWeight {
+		t.Fatalf("silent peer: %+v", si)
+	}
+
+	// A peer connecting later is only measured against blocks seen since.
+	if late := s.newPeerScore().snapshot(); late.Score != 1 {
+		t.Fatalf("late peer: %+v", late)
+	}
+}
+
+func TestPeerScoreResponses(t *testing.T) {
+	s := newScorer(ScoreOptions{Interval: time.Minute})
+	p := s.newPeerScore()
+
+	p.requestSent(1)
+	p.requestSent(2)
+	p.requestSent(3)
+	p.responseReceived(1, false)
+	p.responseReceived(2, true)
+	p.responseReceived(4, false) // Unrequested responses are ignored.
+
+	// Requests pending past the timeout count as unanswered.
+	p.mu.Lock()
+	p.pending[3] = time.Now().Add(-2 * responseTimeout)
+	p.mu.Unlock()
+
+	got := p.snapshot()
+	if got.Requests != 3 || got.Responses != 1 || math.Abs(got.Responsiveness-1.0/3) > 1e-9 {
+		t.Fatalf("want 1 of 3 requests answered, got %+v", got)
+	}
+
+	p.invalidMessage()
+	if after := p.snapshot(); math.Abs(after.Score-got.Score/2) > 1e-9 {
+		t.Fatalf("want an invalid message to halve the score, got %v from %v", after.Score, got.Score)
+	}
+}
+
+func TestScorePeersEvicts(t *testing.T) {
+	s := newScorer(ScoreOptions{
+		Interval:     time.Minute,
+		Evict:        true,
+		Threshold:    0.5,
+		MaxEvictions: 1,
+		MaxPeers:     3,
+		Cooldown:     time.Hour,
+	})
+	c := &Conns{
+		conns:   make(map[string]*conn),
+		scorer:  s,
+		metrics: sharedTestConns(t, false).metrics,
+	}
+
+	add := func(id byte, age time.Duration) *conn {
+		node := enode.SignNull(new(enr.Record), enode.ID{id})
+		cn := &conn{
+			node:        node,
+			peer:        ethp2p.NewPeer(node.ID(), "test", nil),
+			logger:      zerolog.Nop(),
+			connectedAt: time.Now().Add(-age),
+			score:       s.newPeerScore(),
+		}
+		c.conns[node.ID().String()] = cn
+		return cn
+	}
+	good := add(1, time.Hour)
+	bad := add(2, time.Hour)
+	young := add(3, time.Minute)
+
+	s.firstSeenAt(common.Hash{1}, time.Now())
+	good.score.blockAnnounced(common.Hash{1}, time.Now())
+	for _, cn := range []*conn{bad, young} {
+		cn.score.invalidMessage()
+	}
+
+	c.scorePeers()
+
+	for _, cn := range []*conn{good, young} {
+		if s.isEvicted(cn.node.ID().String()) {
+			t.Fatalf("peer %v should not be evicted", cn.node.ID())
+		}
+	}
+	if !s.isEvicted(bad.node.ID().String()) {
+		t.Fatal("low scoring peer should be evicted")
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	scores := []float64{0.1, 0.2, 0.3, 0.4, 0.5}
+	for q, want := range map[float64]float64{0: 0.1, 0.5: 0.3, 1: 0.5} {
+		if got := quantile(scores, q); got != want {
+			t.Fatalf("quantile %v: want %v got %v", q, want, got)
+		}
+	}
+	if got := quantile(nil, 0.5); got != 0 {
+		t.Fatalf("want 0 for no scores, got %v", got)
+	}
+}
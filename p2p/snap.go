@@ -0,0 +1,532 @@
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/protocols/snap"
+	"github.com/ethereum/go-ethereum/ethdb"
+	ethp2p "github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/trie/trienode"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// maxSnapCodes is the most bytecodes requested in a single probe.
+	maxSnapCodes = 16
+	// snapProbeDelay is how long the sensor waits after a peer connects before
+	// probing it, because peers only serve snap once the eth handshake is done.
+	snapProbeDelay = 10 * time.Second
+)
+
+// Results of a snap request.
+const (
+	// SnapResultOK means the response was non-empty and verified.
+	SnapResultOK = "ok"
+	// SnapResultEmpty means the peer answered without data, which is how snap
+	// peers signal they do not have the requested state.
+	SnapResultEmpty = "empty"
+	// SnapResultInvalid means the response failed verification.
+	SnapResultInvalid = "invalid"
+	// SnapResultFailed means no response was received.
+	SnapResultFailed = "failed"
+)
+
+// SnapProbeOptions configures the snap requests issued against a peer.
+type SnapProbeOptions struct {
+	// Root is the state root to request data for. Peers only keep the state of
+	// recent blocks, so this should be the root of a block close to the head.
+	Root common.Hash
+	// Origin is the first account hash of the requested account range.
+	Origin common.Hash
+	// Bytes is the soft limit on the size of each response.
+	Bytes uint64
+}
+
+// SnapResult is the outcome of a single snap request.
+type SnapResult struct {
+	Request string
+	Result  string
+	Latency time.Duration
+	Items   int
+	Bytes   int
+	Err     error
+}
+
+// snapRequester sends a snap request and returns the response with the same
+// request ID.
+type snapRequester interface {
+	requestSnap(req Message) (Message, error)
+}
+
+// snapAccount is an account returned by an account range request.
+type snapAccount struct {
+	Hash    common.Hash
+	Account *types.StateAccount
+}
+
+// ProbeSnap issues GetAccountRange, GetStorageRanges, GetByteCodes and
+// GetTrieNodes requests against the peer and verifies the responses. The peer
+// must have been dialed with EnableSnap.
+func (c *rlpxConn) ProbeSnap(opts SnapProbeOptions) ([]SnapResult, error) {
+	if !c.hasCap("snap", 1) {
+		return nil, errors.New("peer does not support snap/1")
+	}
+	return probeSnap(c, opts), nil
+}
+
+// requestSnap implements snapRequester.
+func (c *rlpxConn) requestSnap(req Message) (Message, error) {
+	if err := c.Write(req); err != nil {
+		return nil, err
+	}
+	return c.ReadSnap(req.ReqID())
+}
+
+// probeSnap requests an account range, then the storage of the first contract
+// and the bytecodes of the contracts in that range, and finally the root node of
+// the account trie.
+func probeSnap(r snapRequester, opts SnapProbeOptions) []SnapResult {
+	accounts, result := probeAccountRange(r, opts)
+	results := []SnapResult{result}
+
+	var codes []common.Hash
+	for _, account := range accounts {
+		hash := common.BytesToHash(account.Account.CodeHash)
+		if len(codes) < maxSnapCodes && hash != types.EmptyCodeHash && !slices.Contains(codes, hash) {
+			codes = append(codes, hash)
+		}
+	}
+
+	for _, account := range accounts {
+		if account.Account.Root != types.EmptyRootHash {
+			results = append(results, probeStorageRanges(r, opts, account))
+			break
+		}
+	}
+
+	if len(codes) > 0 {
+		results = append(results, probeByteCodes(r, opts, codes))
+	}
+
+	return append(results, probeTrieNodes(r, opts))
+}
+
+// sendSnap sends a request and times it, returning the response if it has the
+// expected type.
+func sendSnap[T Message](r snapRequester, name string, req Message) (T, SnapResult) {
+	var res T
+	result := SnapResult{Request: name}
+
+	start := time.Now()
+	msg, err := r.requestSnap(req)
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Result, result.Err = SnapResultFailed, err
+		return res, result
+	}
+
+	res, ok := msg.(T)
+	if !ok {
+		result.Result, result.Err = SnapResultInvalid, fmt.Errorf("unexpected response %T", msg)
+		return res, result
+	}
+
+	if payload, err := rlp.EncodeToBytes(res); err == nil {
+		result.Bytes = len(payload)
+	}
+
+	return res, result
+}
+
+// check sets the result from the verification error, or from whether the
+// response was empty.
+func (r *SnapResult) check(empty bool, err error) {
+	switch {
+	case err != nil:
+		r.Result, r.Err = SnapResultInvalid, err
+	case empty:
+		r.Result = SnapResultEmpty
+	default:
+		r.Result = SnapResultOK
+	}
+}
+
+func probeAccountRange(r snapRequester, opts SnapProbeOptions) ([]snapAccount, SnapResult) {
+	req := &GetAccountRange{
+		ID:     rand.Uint64(),
+		Root:   opts.Root,
+		Origin: opts.Origin,
+		Limit:  common.MaxHash,
+		Bytes:  opts.Bytes,
+	}
+
+	res, result := sendSnap[*AccountRange](r, "GetAccountRange", req)
+	if result.Err != nil {
+		return nil, result
+	}
+
+	result.Items = len(res.Accounts)
+	empty := len(res.Accounts) == 0 && len(res.Proof) == 0
+	if empty {
+		result.check(true, nil)
+		return nil, result
+	}
+
+	accounts, err := verifyAccountRange(opts.Root, opts.Origin, res)
+	result.check(false, err)
+	return accounts, result
+}
+
+func probeStorageRanges(r snapRequester, opts SnapProbeOptions, account snapAccount) SnapResult {
+	req := &GetStorageRanges{
+		ID:       rand.Uint64(),
+		Root:     opts.Root,
+		Accounts: []common.Hash{account.Hash},
+		Bytes:    opts.Bytes,
+	}
+
+	res, result := sendSnap[*StorageRanges](r, "GetStorageRanges", req)
+	if result.Err != nil {
+		return result
+	}
+
+	if len(res.Slots) > 0 {
+		result.Items = len(res.Slots[0])
+	}
+	if len(res.Slots) == 0 {
+		result.check(true, nil)
+		return result
+	}
+
+	result.check(false, verifyStorageRanges(account.Account.Root, res))
+	return result
+}
+
+func probeByteCodes(r snapRequester, opts SnapProbeOptions, hashes []common.Hash) SnapResult {
+	req := &GetByteCodes{
+		ID:     rand.Uint64(),
+		Hashes: hashes,
+		Bytes:  opts.Bytes,
+	}
+
+	res, result := sendSnap[*ByteCodes](r, "GetByteCodes", req)
+	if result.Err != nil {
+		return result
+	}
+
+	result.Items = len(res.Codes)
+	result.check(len(res.Codes) == 0, verifyByteCodes(hashes, res))
+	return result
+}
+
+func probeTrieNodes(r snapRequester, opts SnapProbeOptions) SnapResult {
+	// The compact encoding of the empty path addresses the account trie root.
+	paths, err := rlp.EncodeToRawList([]snap.TrieNodePathSet{{[]byte{0}}})
+	if err != nil {
+		return SnapResult{Request: "GetTrieNodes", Result: SnapResultFailed, Err: err}
+	}
+
+	req := &GetTrieNodes{
+		ID:    rand.Uint64(),
+		Root:  opts.Root,
+		Paths: paths,
+		Bytes: opts.Bytes,
+	}
+
+	res, result := sendSnap[*TrieNodes](r, "GetTrieNodes", req)
+	if result.Err != nil {
+		return result
+	}
+
+	result.Items = len(res.Nodes)
+	result.check(len(res.Nodes) == 0, verifyTrieNodes(opts.Root, res))
+	return result
+}
+
+// verifyAccountRange checks the accounts against the range proof for the root
+// and returns them decoded.
+func verifyAccountRange(root, origin common.Hash, res *AccountRange) ([]snapAccount, error) {
+	packet := (*snap.AccountRangePacket)(res)
+	hashes, values, err := packet.Unpack()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([][]byte, len(hashes))
+	accounts := make([]snapAccount, len(hashes))
+	for i, hash := range hashes {
+		keys[i] = common.CopyBytes(hash[:])
+
+		account, err := types.FullAccount(res.Accounts[i].Body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid account %v: %w", hash, err)
+		}
+		accounts[i] = snapAccount{Hash: hash, Account: account}
+	}
+
+	if _, err := trie.VerifyRangeProof(root, origin[:], keys, values, proofSet(res.Proof)); err != nil {
+		return nil, fmt.Errorf("invalid account range proof: %w", err)
+	}
+
+	return accounts, nil
+}
+
+// verifyStorageRanges checks the storage slots of a single account against its
+// storage root. A response without a proof must contain all of the slots.
+func verifyStorageRanges(root common.Hash, res *StorageRanges) error {
+	if len(res.Slots) != 1 {
+		return fmt.Errorf("expected slots for 1 account, got %d", len(res.Slots))
+	}
+
+	packet := (*snap.StorageRangesPacket)(res)
+	hashes, values := packet.Unpack()
+
+	keys := make([][]byte, len(hashes[0]))
+	for i, hash := range hashes[0] {
+		keys[i] = common.CopyBytes(hash[:])
+	}
+
+	var origin common.Hash
+	if _, err := trie.VerifyRangeProof(root, origin[:], keys, values[0], proofSet(res.Proof)); err != nil {
+		return fmt.Errorf("invalid storage range proof: %w", err)
+	}
+
+	return nil
+}
+
+// verifyByteCodes checks that each code hashes to one of the requested hashes,
+// in request order. Peers may skip codes they do not have.
+func verifyByteCodes(hashes []common.Hash, res *ByteCodes) error {
+	j := 0
+	for _, code := range res.Codes {
+		hash := crypto.Keccak256Hash(code)
+		for j < len(hashes) && hashes[j] != hash {
+			j++
+		}
+		if j == len(hashes) {
+			return fmt.Errorf("unrequested bytecode %v", hash)
+		}
+		j++
+	}
+	return nil
+}
+
+// verifyTrieNodes checks that the returned node is the requested root.
+func verifyTrieNodes(root common.Hash, res *TrieNodes) error {
+	if len(res.Nodes) > 1 {
+		return fmt.Errorf("expected 1 trie node, got %d", len(res.Nodes))
+	}
+	for _, node := range res.Nodes {
+		if hash := crypto.Keccak256Hash(node); hash != root {
+			return fmt.Errorf("trie node hash mismatch: %v (!= %v)", hash, root)
+		}
+	}
+	return nil
+}
+
+// proofSet converts proof nodes into a database for VerifyRangeProof, which
+// expects nil when there is no proof.
+func proofSet(proof [][]byte) ethdb.KeyValueReader {
+	if len(proof) == 0 {
+		return nil
+	}
+
+	nodes := make(trienode.ProofList, len(proof))
+	for i, node := range proof {
+		nodes[i] = node
+	}
+	return nodes.Set()
+}
+
+// SnapProtocolOptions is the options used when creating a new snap protocol.
+type SnapProtocolOptions struct {
+	Conns *Conns
+	// ProbeInterval is the time between probes of a peer. Each peer is probed
+	// once after connecting, and only once if this is zero.
+	ProbeInterval time.Duration
+	// Bytes is the soft limit on the size of each response.
+	Bytes uint64
+	// Timeout bounds each request.
+	Timeout time.Duration
+	// Metrics are updated with every probe if set.
+	Metrics *SnapMetrics
+}
+
+// snapConn represents the snap protocol of an individual peer connection.
+type snapConn struct {
+	rw      ethp2p.MsgReadWriter
+	logger  zerolog.Logger
+	timeout time.Duration
+
+	mu      sync.Mutex
+	pending map[uint64]chan Message
+
+	closeCh chan struct{}
+}
+
+// NewSnapProtocol creates the snap/1 protocol. The sensor holds no state, so
+// requests from peers are answered with empty responses, while peers are probed
+// for the state of the current head block to measure whether and how fast they
+// serve it.
+func NewSnapProtocol(opts SnapProtocolOptions) ethp2p.Protocol {
+	if opts.Timeout <= 0 {
+		opts.Timeout = timeout
+	}
+
+	return ethp2p.Protocol{
+		Name:    "snap",
+		Version: snap.SNAP1,
+		Length:  snapLength,
+		Run: func(p *ethp2p.Peer, rw ethp2p.MsgReadWriter) error {
+			peerID := p.Node().ID().String()
+			c := &snapConn{
+				rw:      rw,
+				logger:  log.With().Str("peer", p.Node().URLv4()).Str("protocol", "snap").Logger(),
+				timeout: opts.Timeout,
+				pending: make(map[uint64]chan Message),
+				closeCh: make(chan struct{}),
+			}
+
+			defer func() {
+				close(c.closeCh)
+				opts.Conns.removePeerSnapResults(peerID)
+			}()
+
+			go c.probeLoop(peerID, opts)
+
+			for {
+				msg, err := rw.ReadMsg()
+				if err != nil {
+					return err
+				}
+
+				if err = c.handle(msg); err != nil {
+					c.logger.Error().Err(err).Send()
+					return err
+				}
+
+				if err = msg.Discard(); err != nil {
+					return err
+				}
+			}
+		},
+	}
+}
+
+// handle answers snap requests with empty responses and delivers responses to
+// the pending request with the same ID.
+func (c *snapConn) handle(msg ethp2p.Msg) error {
+	payload, err := io.ReadAll(msg.Payload)
+	if err != nil {
+		return err
+	}
+
+	packet, err := decodeSnap(snapOffset+int(msg.Code), payload)
+	if err != nil {
+		return err
+	}
+
+	switch packet := packet.(type) {
+	case *GetAccountRange:
+		return ethp2p.Send(c.rw, snap.AccountRangeMsg, &AccountRange{ID: packet.ID})
+	case *GetStorageRanges:
+		return ethp2p.Send(c.rw, snap.StorageRangesMsg, &StorageRanges{ID: packet.ID})
+	case *GetByteCodes:
+		return ethp2p.Send(c.rw, snap.ByteCodesMsg, &ByteCodes{ID: packet.ID})
+	case *GetTrieNodes:
+		return ethp2p.Send(c.rw, snap.TrieNodesMsg, &TrieNodes{ID: packet.ID})
+	}
+
+	c.mu.Lock()
+	ch, ok := c.pending[packet.ReqID()]
+	delete(c.pending, packet.ReqID())
+	c.mu.Unlock()
+
+	if !ok {
+		c.logger.Trace().Uint64("id", packet.ReqID()).Msg("Received unrequested snap response")
+		return nil
+	}
+
+	ch <- packet
+	return nil
+}
+
+// requestSnap implements snapRequester.
+func (c *snapConn) requestSnap(req Message) (Message, error) {
+	ch := make(chan Message, 1)
+
+	c.mu.Lock()
+	c.pending[req.ReqID()] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, req.ReqID())
+		c.mu.Unlock()
+	}()
+
+	if err := ethp2p.Send(c.rw, uint64(req.Code()-snapOffset), req); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+
+	select {
+	case msg := <-ch:
+		return msg, nil
+	case <-timer.C:
+		return nil, errors.New("request timed out")
+	case <-c.closeCh:
+		return nil, errors.New("peer disconnected")
+	}
+}
+
+// probeLoop probes the peer for the state of the head block after it connects
+// and then every probe interval.
+func (c *snapConn) probeLoop(peerID string, opts SnapProtocolOptions) {
+	timer := time.NewTimer(snapProbeDelay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-c.closeCh:
+			return
+		case <-timer.C:
+		}
+
+		head := opts.Conns.HeadBlock()
+		if head.Block != nil {
+			results := probeSnap(c, SnapProbeOptions{Root: head.Block.Root(), Bytes: opts.Bytes})
+			opts.Metrics.update(results)
+			opts.Conns.setPeerSnapResults(peerID, results)
+
+			for _, result := range results {
+				c.logger.Debug().
+					Str("request", result.Request).
+					Str("result", result.Result).
+					Dur("latency", result.Latency).
+					Int("items", result.Items).
+					Err(result.Err).
+					Msg("Snap probe")
+			}
+		}
+
+		if opts.ProbeInterval <= 0 {
+			return
+		}
+		timer.Reset(opts.ProbeInterval)
+	}
+}
@@ -0,0 +1,288 @@
+package p2p
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/protocols/snap"
+	ethp2p "github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/trie/trienode"
+	"github.com/ethereum/go-ethereum/triedb"
+	"github.com/holiman/uint256"
+)
+
+// snapTestPeer serves snap requests from an in-memory state.
+type snapTestPeer struct {
+	accounts *trie.Trie
+	keys     []common.Hash
+	bodies   map[common.Hash][]byte
+	slots    []*snap.StorageData
+	code     []byte
+
+	// maxAccounts limits account ranges so they need edge proofs.
+	maxAccounts int
+	// tamper corrupts the first account of each account range.
+	tamper bool
+	// empty answers every request without data.
+	empty bool
+}
+
+func newSnapTestPeer(t *testing.T) *snapTestPeer {
+	db := triedb.NewDatabase(rawdb.NewMemoryDatabase(), nil)
+	p := &snapTestPeer{
+		bodies:      make(map[common.Hash][]byte),
+		code:        []byte{0x60, 0x00, 0x60, 0x00, 0xf3},
+		maxAccounts: 8,
+	}
+
+	storage := trie.NewEmpty(db)
+	for i := range 5 {
+		key := crypto.Keccak256Hash([]byte(fmt.Sprintf("slot-%d", i)))
+		value, _ := rlp.EncodeToBytes(uint64(i + 1))
+		if err := storage.Update(key[:], value); err != nil {
+			t.Fatalf("update storage: %v", err)
+		}
+		p.slots = append(p.slots, &snap.StorageData{Hash: key, Body: value})
+	}
+	slices.SortFunc(p.slots, func(a, b *snap.StorageData) int { return bytes.Compare(a.Hash[:], b.Hash[:]) })
+
+	p.accounts = trie.NewEmpty(db)
+	for i := range 20 {
+		account := types.StateAccount{
+			Nonce:    uint64(i),
+			Balance:  uint256.NewInt(uint64(i) * 1000),
+			Root:     types.EmptyRootHash,
+			CodeHash: types.EmptyCodeHash[:],
+		}
+		if i%4 == 0 {
+			account.Root = storage.Hash()
+			account.CodeHash = crypto.Keccak256(p.code)
+		}
+
+		key := crypto.Keccak256Hash([]byte(fmt.Sprintf("account-%d", i)))
+		value, _ := rlp.EncodeToBytes(&account)
+		if err := p.accounts.Update(key[:], value); err != nil {
+			t.Fatalf("update accounts: %v", err)
+		}
+		p.keys = append(p.keys, key)
+		p.bodies[key] = types.SlimAccountRLP(account)
+	}
+	slices.SortFunc(p.keys, func(a, b common.Hash) int { return bytes.Compare(a[:], b[:]) })
+
+	return p
+}
+
+func (p *snapTestPeer) prove(t *testing.T, key common.Hash, proof *trienode.ProofList) {
+	if err := p.accounts.Prove(key[:], proof); err != nil {
+		t.Fatalf("prove: %v", err)
+	}
+}
+
+func (p *snapTestPeer) requester(t *testing.T) snapRequester {
+	return snapRequesterFunc(func(req Message) (Message, error) {
+		switch req := req.(type) {
+		case *GetAccountRange:
+			res := &AccountRange{ID: req.ID}
+			if p.empty || req.Root != p.accounts.Hash() {
+				return res, nil
+			}
+
+			var proof trienode.ProofList
+			p.prove(t, req.Origin, &proof)
+			for _, key := range p.keys {
+				if bytes.Compare(key[:], req.Origin[:]) < 0 || len(res.Accounts) == p.maxAccounts {
+					continue
+				}
+				res.Accounts = append(res.Accounts, &snap.AccountData{Hash: key, Body: p.bodies[key]})
+			}
+			p.prove(t, res.Accounts[len(res.Accounts)-1].Hash, &proof)
+			for _, node := range proof {
+				res.Proof = append(res.Proof, node)
+			}
+
+			if p.tamper {
+				account, _ := types.FullAccount(res.Accounts[0].Body)
+				account.Balance = uint256.NewInt(1)
+				res.Accounts[0].Body = types.SlimAccountRLP(*account)
+			}
+			return res, nil
+		case *GetStorageRanges:
+			res := &StorageRanges{ID: req.ID}
+			if !p.empty {
+				res.Slots = [][]*snap.StorageData{p.slots}
+			}
+			return res, nil
+		case *GetByteCodes:
+			res := &ByteCodes{ID: req.ID}
+			if !p.empty {
+				res.Codes = [][]byte{p.code}
+			}
+			return res, nil
+		case *GetTrieNodes:
+			res := &TrieNodes{ID: req.ID}
+			if !p.empty {
+				var proof trienode.ProofList
+				p.prove(t, p.keys[0], &proof)
+				res.Nodes = [][]byte{proof[0]}
+			}
+			return res, nil
+		}
+		return nil, fmt.Errorf("unexpected request %T", req)
+	})
+}
+
+type snapRequesterFunc func(req Message) (Message, error)
+
+func (f snapRequesterFunc) requestSnap(req Message) (Message, error) { return f(req) }
+
+func TestProbeSnap(t *testing.T) {
+	p := newSnapTestPeer(t)
+
+	results := probeSnap(p.requester(t), SnapProbeOptions{Root: p.accounts.Hash(), Bytes: 1 << 20})
+
+	want := []string{"GetAccountRange", "GetStorageRanges", "GetByteCodes", "GetTrieNodes"}
+	if len(results) != len(want) {
+		t.Fatalf("want %d results, got %d: %+v", len(want), len(results), results)
+	}
+	for i, r := range results {
+		if r.Request != want[i] {
+			t.Fatalf("result %d: want %s got %s", i, want[i], r.Request)
+		}
+		if r.Result != SnapResultOK {
+			t.Fatalf("%s: want %s got %s (%v)", r.Request, SnapResultOK, r.Result, r.Err)
+		}
+	}
+	if results[0].Items != p.maxAccounts {
+		t.Fatalf("want %d accounts, got %d", p.maxAccounts, results[0].Items)
+	}
+}
+
+func TestProbeSnapOrigin(t *testing.T) {
+	p := newSnapTestPeer(t)
+
+	// Starting past the first accounts needs a non-empty left edge proof.
+	results := probeSnap(p.requester(t), SnapProbeOptions{Root: p.accounts.Hash(), Origin: p.keys[5]})
+	if results[0].Result != SnapResultOK {
+		t.Fatalf("want %s got %s (%v)", SnapResultOK, results[0].Result, results[0].Err)
+	}
+}
+
+func TestProbeSnapInvalid(t *testing.T) {
+	p := newSnapTestPeer(t)
+	p.tamper = true
+
+	results := probeSnap(p.requester(t), SnapProbeOptions{Root: p.accounts.Hash()})
+	if results[0].Result != SnapResultInvalid {
+		t.Fatalf("want %s got %s", SnapResultInvalid, results[0].Result)
+	}
+}
+
+func TestProbeSnapEmpty(t *testing.T) {
+	p := newSnapTestPeer(t)
+	p.empty = true
+
+	results := probeSnap(p.requester(t), SnapProbeOptions{Root: p.accounts.Hash()})
+
+	// Without accounts there is nothing to request storage or bytecodes for.
+	if len(results) != 2 {
+		t.Fatalf("want 2 results, got %d: %+v", len(results), results)
+	}
+	for _, r := range results {
+		if r.Result != SnapResultEmpty {
+			t.Fatalf("%s: want %s got %s (%v)", r.Request, SnapResultEmpty, r.Result, r.Err)
+		}
+	}
+}
+
+func TestVerifyTrieNodes(t *testing.T) {
+	node := []byte{0xc0}
+	if err := verifyTrieNodes(crypto.Keccak256Hash(node), &TrieNodes{Nodes: [][]byte{node}}); err != nil {
+		t.Fatalf("valid node: %v", err)
+	}
+	if err := verifyTrieNodes(common.Hash{1}, &TrieNodes{Nodes: [][]byte{node}}); err == nil {
+		t.Fatal("node with the wrong hash should be rejected")
+	}
+}
+
+func TestVerifyByteCodes(t *testing.T) {
+	a, b, c := []byte{1}, []byte{2}, []byte{3}
+	hashes := []common.Hash{crypto.Keccak256Hash(a), crypto.Keccak256Hash(b), crypto.Keccak256Hash(c)}
+
+	// Peers may skip codes they do not have, but must keep request order.
+	if err := verifyByteCodes(hashes, &ByteCodes{Codes: [][]byte{a, c}}); err != nil {
+		t.Fatalf("valid codes: %v", err)
+	}
+	if err := verifyByteCodes(hashes, &ByteCodes{Codes: [][]byte{c, a}}); err == nil {
+		t.Fatal("out of order codes should be rejected")
+	}
+	if err := verifyByteCodes(hashes, &ByteCodes{Codes: [][]byte{{4}}}); err == nil {
+		t.Fatal("unrequested code should be rejected")
+	}
+}
+
+func TestSnapConn(t *testing.T) {
+	local, remote := ethp2p.MsgPipe()
+	defer local.Close()
+
+	c := &snapConn{
+		rw:      local,
+		timeout: time.Second,
+		pending: make(map[uint64]chan Message),
+		closeCh: make(chan struct{}),
+	}
+	go func() {
+		for {
+			msg, err := local.ReadMsg()
+			if err != nil {
+				return
+			}
+			if err := c.handle(msg); err != nil {
+				t.Errorf("handle: %v", err)
+			}
+		}
+	}()
+
+	// The sensor holds no state, so requests are answered empty.
+	if err := ethp2p.Send(remote, snap.GetByteCodesMsg, &GetByteCodes{ID: 7, Hashes: []common.Hash{{1}}}); err != nil {
+		t.Fatalf("send request: %v", err)
+	}
+	msg, err := remote.ReadMsg()
+	if err != nil {
+		t.Fatalf("read response: %v", err)
+	}
+	var codes ByteCodes
+	if err := msg.Decode(&codes); err != nil || msg.Code != snap.ByteCodesMsg || codes.ID != 7 || len(codes.Codes) != 0 {
+		t.Fatalf("want empty ByteCodes for 7, got code %d %+v (%v)", msg.Code, codes, err)
+	}
+
+	// Responses are delivered to the request with the same ID.
+	go func() {
+		msg, err := remote.ReadMsg()
+		if err != nil {
+			return
+		}
+		var req GetTrieNodes
+		if err := msg.Decode(&req); err != nil {
+			return
+		}
+		_ = ethp2p.Send(remote, snap.TrieNodesMsg, &TrieNodes{ID: req.ID + 1})
+		_ = ethp2p.Send(remote, snap.TrieNodesMsg, &TrieNodes{ID: req.ID, Nodes: [][]byte{{0xc0}}})
+	}()
+
+	res, err := c.requestSnap(&GetTrieNodes{ID: 42})
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if nodes, ok := res.(*TrieNodes); !ok || nodes.ID != 42 || len(nodes.Nodes) != 1 {
+		t.Fatalf("want TrieNodes for 42, got %+v", res)
+	}
+}
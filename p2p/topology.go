@@ -0,0 +1,450 @@
+package p2p
+
+import (
+	"cmp"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// Topology is a graph of the discovery routing tables of nodes. There is an
+// edge from a node to each node its routing table returned. It is safe for
+// concurrent use.
+type Topology struct {
+	mu      sync.Mutex
+	nodes   map[enode.ID]*enode.Node
+	edges   map[enode.ID]map[enode.ID]struct{}
+	queried map[enode.ID]struct{}
+}
+
+// NewTopology creates an empty topology.
+func NewTopology() *Topology {
+	return &Topology{
+		nodes:   make(map[enode.ID]*enode.Node),
+		edges:   make(map[enode.ID]map[enode.ID]struct{}),
+		queried: make(map[enode.ID]struct{}),
+	}
+}
+
+// MarkQueried records that the node's routing table is being queried, and
+// returns false if it already was.
+func (t *Topology) MarkQueried(n *enode.Node) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.queried[n.ID()]; ok {
+		return false
+	}
+	t.queried[n.ID()] = struct{}{}
+	t.nodes[n.ID()] = n
+	return true
+}
+
+// AddNeighbors records the nodes returned from the node's routing table.
+func (t *Topology) AddNeighbors(n *enode.Node, neighbors []*enode.Node) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.nodes[n.ID()] = n
+	out, ok := t.edges[n.ID()]
+	if !ok {
+		out = make(map[enode.ID]struct{})
+		t.edges[n.ID()] = out
+	}
+
+	for _, neighbor := range neighbors {
+		if neighbor.ID() == n.ID() {
+			continue
+		}
+		if _, ok := t.nodes[neighbor.ID()]; !ok {
+			t.nodes[neighbor.ID()] = neighbor
+		}
+		out[neighbor.ID()] = struct{}{}
+	}
+}
+
+// TopologyNode is a node of the topology graph.
+type TopologyNode struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	IP  string `json:"ip,omitempty"`
+	// Responded is whether the node answered routing table queries.
+	Responded bool `json:"responded"`
+	OutDegree int  `json:"out_degree"`
+	InDegree  int  `json:"in_degree"`
+}
+
+// TopologyEdge is an edge from a node to a node in its routing table.
+type TopologyEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+// TopologyGraph is a snapshot of the topology.
+type TopologyGraph struct {
+	Nodes []TopologyNode `json:"nodes"`
+	Edges []TopologyEdge `json:"edges"`
+}
+
+// Graph returns a snapshot of the topology, with nodes and edges sorted by ID.
+func (t *Topology) Graph() TopologyGraph {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	in := make(map[enode.ID]int)
+	var g TopologyGraph
+	for from, out := range t.edges {
+		for to := range out {
+			in[to]++
+			g.Edges = append(g.Edges, TopologyEdge{Source: from.String(), Target: to.String()})
+		}
+	}
+
+	for id, n := range t.nodes {
+		_, responded := t.edges[id]
+		node := TopologyNode{
+			ID:        id.String(),
+			URL:       n.URLv4(),
+			Responded: responded,
+			OutDegree: len(t.edges[id]),
+			InDegree:  in[id],
+		}
+		if ip := n.IP(); ip != nil {
+			node.IP = ip.String()
+		}
+		g.Nodes = append(g.Nodes, node)
+	}
+
+	slices.SortFunc(g.Nodes, func(a, b TopologyNode) int { return cmp.Compare(a.ID, b.ID) })
+	slices.SortFunc(g.Edges, func(a, b TopologyEdge) int {
+		return cmp.Or(cmp.Compare(a.Source, b.Source), cmp.Compare(a.Target, b.Target))
+	})
+	return g
+}
+
+// WriteJSON writes the graph as JSON.
+func (g TopologyGraph) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// WriteGraphML writes the graph as GraphML, which graph tools like Gephi and
+// networkx can read.
+func (g TopologyGraph) WriteGraphML(w io.Writer) error {
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "url", For: "node", Name: "url", Type: "string"},
+			{ID: "ip", For: "node", Name: "ip", Type: "string"},
+			{ID: "responded", For: "node", Name: "responded", Type: "boolean"},
+		},
+		Graph: graphMLGraph{ID: "discovery", EdgeDefault: "directed"},
+	}
+
+	for _, n := range g.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{
+			ID: n.ID,
+			Data: []graphMLData{
+				{Key: "url", Value: n.URL},
+				{Key: "ip", Value: n.IP},
+				{Key: "responded", Value: fmt.Sprint(n.Responded)},
+			},
+		})
+	}
+	for _, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge(e))
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// DegreeStats summarizes a degree distribution.
+type DegreeStats struct {
+	Min    int     `json:"min"`
+	Median int     `json:"median"`
+	Mean   float64 `json:"mean"`
+	Max    int     `json:"max"`
+}
+
+func newDegreeStats(degrees []int) DegreeStats {
+	if len(degrees) == 0 {
+		return DegreeStats{}
+	}
+
+	slices.Sort(degrees)
+	sum := 0
+	for _, d := range degrees {
+		sum += d
+	}
+	return DegreeStats{
+		Min:    degrees[0],
+		Median: degrees[len(degrees)/2],
+		Mean:   float64(sum) / float64(len(degrees)),
+		Max:    degrees[len(degrees)-1],
+	}
+}
+
+// PrefixShare is the number and share of nodes in an IP prefix.
+type PrefixShare struct {
+	Prefix string  `json:"prefix"`
+	Nodes  int     `json:"nodes"`
+	Share  float64 `json:"share"`
+}
+
+// TableConcentration is the largest share of a node's routing table in a
+// single IP prefix.
+type TableConcentration struct {
+	Node   string  `json:"node"`
+	Prefix string  `json:"prefix"`
+	Nodes  int     `json:"nodes"`
+	Share  float64 `json:"share"`
+}
+
+// TopologyMetricsOptions configures the topology metrics.
+type TopologyMetricsOptions struct {
+	// Top is the most prefixes and nodes listed per metric.
+	Top int
+	// MaxInDegree is the in-degree at or below which a node counts as reachable
+	// only through few others.
+	MaxInDegree int
+	// MinTableSize is the smallest routing table that table concentration is
+	// computed for, since small samples are trivially concentrated.
+	MinTableSize int
+}
+
+// TopologyMetrics are the metrics of a topology relevant to eclipse attacks.
+type TopologyMetrics struct {
+	Nodes     int `json:"nodes"`
+	Responded int `json:"responded"`
+	Edges     int `json:"edges"`
+
+	OutDegree DegreeStats `json:"out_degree"`
+	InDegree  DegreeStats `json:"in_degree"`
+	// Clustering is the average local clustering coefficient of the responding
+	// nodes, treating edges as undirected.
+	Clustering float64 `json:"clustering"`
+
+	// Subnets24 and Subnets16 are the IPv4 prefixes with the most nodes. They
+	// stand in for hosting providers, since nodes in the same prefix are
+	// usually under the same operator.
+	Subnets24 []PrefixShare `json:"subnets_24"`
+	Subnets16 []PrefixShare `json:"subnets_16"`
+
+	// LowInDegree lists the nodes in at most MaxInDegree routing tables of the
+	// responding nodes, which are discoverable only through few others.
+	LowInDegree      []TopologyNode `json:"low_in_degree"`
+	LowInDegreeCount int            `json:"low_in_degree_count"`
+
+	// TableConcentration lists the responding nodes whose routing tables have
+	// the largest share of nodes in a single /24, which are the easiest to
+	// eclipse from one subnet.
+	TableConcentration []TableConcentration `json:"table_concentration"`
+}
+
+// prefix returns the IPv4 prefix of the given length of the node's IP, or an
+// empty string for IPv6 or unknown addresses.
+func prefix(ip net.IP, bits int) string {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return ""
+	}
+	network := &net.IPNet{IP: ip4.Mask(net.CIDRMask(bits, 32)), Mask: net.CIDRMask(bits, 32)}
+	return network.String()
+}
+
+// topPrefixes counts nodes per prefix and returns the top prefixes by count.
+func topPrefixes(nodes []*enode.Node, bits, top int) []PrefixShare {
+	counts := make(map[string]int)
+	for _, n := range nodes {
+		if p := prefix(n.IP(), bits); p != "" {
+			counts[p]++
+		}
+	}
+
+	shares := make([]PrefixShare, 0, len(counts))
+	for p, count := range counts {
+		shares = append(shares, PrefixShare{Prefix: p, Nodes: count, Share: float64(count) / float64(len(nodes))})
+	}
+	slices.SortFunc(shares, func(a, b PrefixShare) int {
+		return cmp.Or(cmp.Compare(b.Nodes, a.Nodes), cmp.Compare(a.Prefix, b.Prefix))
+	})
+	if top >= 0 && len(shares) > top {
+		shares = shares[:top]
+	}
+	return shares
+}
+
+// Metrics computes the topology metrics.
+func (t *Topology) Metrics(opts TopologyMetricsOptions) TopologyMetrics {
+	g := t.Graph()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	m := TopologyMetrics{
+		Nodes:     len(t.nodes),
+		Responded: len(t.edges),
+		Edges:     len(g.Edges),
+	}
+
+	nodes := make([]*enode.Node, 0, len(t.nodes))
+	for _, n := range t.nodes {
+		nodes = append(nodes, n)
+	}
+	m.Subnets24 = topPrefixes(nodes, 24, opts.Top)
+	m.Subnets16 = topPrefixes(nodes, 16, opts.Top)
+
+	var outDegrees, inDegrees []int
+	for _, n := range g.Nodes {
+		if n.Responded {
+			outDegrees = append(outDegrees, n.OutDegree)
+		}
+		inDegrees = append(inDegrees, n.InDegree)
+
+		// Nodes that did not respond cannot be told apart from nodes that are
+		// barely known, so only list the responding ones.
+		if n.Responded && n.InDegree <= opts.MaxInDegree {
+			m.LowInDegreeCount++
+			m.LowInDegree = append(m.LowInDegree, n)
+		}
+	}
+	m.OutDegree = newDegreeStats(outDegrees)
+	m.InDegree = newDegreeStats(inDegrees)
+
+	slices.SortFunc(m.LowInDegree, func(a, b TopologyNode) int {
+		return cmp.Or(cmp.Compare(a.InDegree, b.InDegree), cmp.Compare(a.ID, b.ID))
+	})
+	if opts.Top >= 0 && len(m.LowInDegree) > opts.Top {
+		m.LowInDegree = m.LowInDegree[:opts.Top]
+	}
+
+	m.Clustering = t.clustering()
+
+	for id, out := range t.edges {
+		if len(out) < opts.MinTableSize || len(out) == 0 {
+			continue
+		}
+
+		table := make([]*enode.Node, 0, len(out))
+		for neighbor := range out {
+			table = append(table, t.nodes[neighbor])
+		}
+		if top := topPrefixes(table, 24, 1); len(top) > 0 {
+			m.TableConcentration = append(m.TableConcentration, TableConcentration{
+				Node:   id.String(),
+				Prefix: top[0].Prefix,
+				Nodes:  top[0].Nodes,
+				Share:  top[0].Share,
+			})
+		}
+	}
+	slices.SortFunc(m.TableConcentration, func(a, b TableConcentration) int {
+		return cmp.Or(cmp.Compare(b.Share, a.Share), cmp.Compare(a.Node, b.Node))
+	})
+	if opts.Top >= 0 && len(m.TableConcentration) > opts.Top {
+		m.TableConcentration = m.TableConcentration[:opts.Top]
+	}
+
+	return m
+}
+
+// clustering returns the average local clustering coefficient of the responding
+// nodes on the undirected graph. The caller must hold the lock.
+func (t *Topology) clustering() float64 {
+	adj := make(map[enode.ID]map[enode.ID]struct{})
+	link := func(a, b enode.ID) {
+		if adj[a] == nil {
+			adj[a] = make(map[enode.ID]struct{})
+		}
+		adj[a][b] = struct{}{}
+	}
+	for from, out := range t.edges {
+		for to := range out {
+			link(from, to)
+			link(to, from)
+		}
+	}
+
+	var (
+		sum   float64
+		count int
+	)
+	for id := range t.edges {
+		neighbors := make([]enode.ID, 0, len(adj[id]))
+		for n := range adj[id] {
+			neighbors = append(neighbors, n)
+		}
+		k := len(neighbors)
+		if k < 2 {
+			continue
+		}
+
+		links := 0
+		for i := range neighbors {
+			for j := i + 1; j < k; j++ {
+				if _, ok := adj[neighbors[i]][neighbors[j]]; ok {
+					links++
+				}
+			}
+		}
+		sum += float64(2*links) / float64(k*(k-1))
+		count++
+	}
+
+	if count == 0 {
+		return 0
+	}
+	return sum / float64(count)
+}
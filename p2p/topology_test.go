@@ -0,0 +1,96 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding/xml"
+	"math"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+func newTopologyNode(t *testing.T, ip string) *enode.Node {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return enode.NewV4(&key.PublicKey, net.ParseIP(ip), 30303, 30303)
+}
+
+func TestTopologyMetrics(t *testing.T) {
+	a := newTopologyNode(t, "10.0.0.1")
+	b := newTopologyNode(t, "10.0.0.2")
+	c := newTopologyNode(t, "10.0.1.1")
+	d := newTopologyNode(t, "192.168.0.1")
+
+	// a, b and c form a triangle and only a knows d, which does not respond.
+	topo := NewTopology()
+	topo.AddNeighbors(a, []*enode.Node{b, c, d, a})
+	topo.AddNeighbors(b, []*enode.Node{a, c})
+	topo.AddNeighbors(c, []*enode.Node{a})
+
+	m := topo.Metrics(TopologyMetricsOptions{Top: 10, MaxInDegree: 1})
+
+	if m.Nodes != 4 || m.Responded != 3 || m.Edges != 6 {
+		t.Fatalf("want 4 nodes, 3 responded and 6 edges, got %+v", m)
+	}
+	if m.OutDegree.Min != 1 || m.OutDegree.Max != 3 || m.InDegree.Max != 2 {
+		t.Fatalf("unexpected degrees: out %+v in %+v", m.OutDegree, m.InDegree)
+	}
+
+	// a has neighbors b, c and d of which only b-c are linked; b and c only
+	// have neighbors that are linked.
+	if want := (1.0/3 + 1 + 1) / 3; math.Abs(m.Clustering-want) > 1e-9 {
+		t.Fatalf("want clustering %v got %v", want, m.Clustering)
+	}
+
+	if len(m.Subnets24) == 0 || m.Subnets24[0].Prefix != "10.0.0.0/24" || m.Subnets24[0].Nodes != 2 {
+		t.Fatalf("unexpected /24 subnets %+v", m.Subnets24)
+	}
+	if m.Subnets16[0].Prefix != "10.0.0.0/16" || m.Subnets16[0].Nodes != 3 {
+		t.Fatalf("unexpected /16 subnets %+v", m.Subnets16)
+	}
+
+	// b is only in a's table, d too but it never responded.
+	if m.LowInDegreeCount != 1 || m.LowInDegree[0].ID != b.ID().String() {
+		t.Fatalf("want b to be the only low in-degree node, got %+v", m.LowInDegree)
+	}
+
+	if len(m.TableConcentration) != 3 || m.TableConcentration[0].Share != 1 {
+		t.Fatalf("unexpected table concentration %+v", m.TableConcentration)
+	}
+
+	if !topo.MarkQueried(d) || topo.MarkQueried(d) {
+		t.Fatal("want a node to be queried once")
+	}
+}
+
+func TestTopologyGraphML(t *testing.T) {
+	a := newTopologyNode(t, "10.0.0.1")
+	b := newTopologyNode(t, "10.0.0.2")
+
+	topo := NewTopology()
+	topo.AddNeighbors(a, []*enode.Node{b})
+
+	var buf bytes.Buffer
+	if err := topo.Graph().WriteGraphML(&buf); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	var doc graphML
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(doc.Graph.Nodes) != 2 || len(doc.Graph.Edges) != 1 {
+		t.Fatalf("want 2 nodes and 1 edge, got %+v", doc.Graph)
+	}
+	if e := doc.Graph.Edges[0]; e.Source != a.ID().String() || e.Target != b.ID().String() {
+		t.Fatalf("unexpected edge %+v", e)
+	}
+	if !strings.HasPrefix(buf.String(), "<?xml") {
+		t.Fatal("missing XML header")
+	}
+}
@@ -0,0 +1,309 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+
+	"github.com/0xPolygon/polygon-cli/p2p/database"
+)
+
+const (
+	// maxTraceBodyRequests bounds the block body requests a tracing connection
+	// waits on.
+	maxTraceBodyRequests = 256
+	// traceBodyTimeout is how long a block body request is waited on before it
+	// no longer counts towards maxTraceBodyRequests.
+	traceBodyTimeout = 10 * time.Second
+)
+
+// TxSighting is the first time a peer announced or sent the traced
+// transaction.
+type TxSighting struct {
+	Peer    string
+	Message string
+	Time    time.Time
+	// Delay is the time since the transaction was sent.
+	Delay time.Duration
+}
+
+// TxInclusion is the first block including the traced transaction that a peer
+// announced or served.
+type TxInclusion struct {
+	Peer   string
+	Hash   common.Hash
+	Number uint64
+	Time   time.Time
+	// Delay is the time since the transaction was sent.
+	Delay time.Duration
+}
+
+// TxTraceResult is the outcome of tracing a transaction.
+type TxTraceResult struct {
+	Hash   common.Hash
+	SentAt time.Time
+	// Sightings holds the first sighting per peer, in order.
+	Sightings []TxSighting
+	Inclusion *TxInclusion
+}
+
+// TxTracer is a peer connection that can trace a transaction.
+type TxTracer interface {
+	TraceTx(ctx context.Context, trace *TxTrace) error
+	Close() error
+}
+
+// TxTrace follows a transaction across connections. It is safe for concurrent
+// use, so every connection can report to the same trace.
+type TxTrace struct {
+	hash common.Hash
+
+	mu        sync.Mutex
+	sent      time.Time
+	sightings map[enode.ID]TxSighting
+	inclusion *TxInclusion
+
+	included chan struct{}
+}
+
+// NewTxTrace creates a trace of the transaction with the given hash.
+func NewTxTrace(hash common.Hash) *TxTrace {
+	return &TxTrace{
+		hash:      hash,
+		sightings: make(map[enode.ID]TxSighting),
+		included:  make(chan struct{}),
+	}
+}
+
+// Hash returns the hash of the traced transaction.
+func (t *TxTrace) Hash() common.Hash {
+	return t.hash
+}
+
+// MarkSent records when the transaction was sent. Delays are measured from it.
+func (t *TxTrace) MarkSent(at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sent = at
+}
+
+// Included returns a channel that is closed once the transaction is seen in a
+// block.
+func (t *TxTrace) Included() <-chan struct{} {
+	return t.included
+}
+
+// delay returns the time from sending to at. The caller must hold the lock.
+func (t *TxTrace) delay(at time.Time) time.Duration {
+	if t.sent.IsZero() {
+		return 0
+	}
+	return at.Sub(t.sent)
+}
+
+// sighted records a peer announcing or sending the transaction. Only the first
+// sighting per peer is kept.
+func (t *TxTrace) sighted(node *enode.Node, message string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.sightings[node.ID()]; ok {
+		return
+	}
+	t.sightings[node.ID()] = TxSighting{
+		Peer:    node.URLv4(),
+		Message: message,
+		Time:    at,
+		Delay:   t.delay(at),
+	}
+}
+
+// includedIn records a peer announcing or serving a block that includes the
+// transaction. Only the first inclusion is kept.
+func (t *TxTrace) includedIn(node *enode.Node, hash common.Hash, number uint64, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.inclusion != nil {
+		return
+	}
+	t.inclusion = &TxInclusion{
+		Peer:   node.URLv4(),
+		Hash:   hash,
+		Number: number,
+		Time:   at,
+		Delay:  t.delay(at),
+	}
+	close(t.included)
+}
+
+// Result returns the sightings and inclusion so far.
+func (t *TxTrace) Result() TxTraceResult {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r := TxTraceResult{Hash: t.hash, SentAt: t.sent}
+	for _, s := range t.sightings {
+		r.Sightings = append(r.Sightings, s)
+	}
+	slices.SortFunc(r.Sightings, func(a, b TxSighting) int { return a.Time.Compare(b.Time) })
+
+	if t.inclusion != nil {
+		inclusion := *t.inclusion
+		r.Inclusion = &inclusion
+	}
+	return r
+}
+
+// containsTx reports whether any of the transactions has the hash.
+func containsTx(txs []*types.Transaction, hash common.Hash) bool {
+	return slices.ContainsFunc(txs, func(tx *types.Transaction) bool { return tx.Hash() == hash })
+}
+
+// bodyRequest is a block body request a tracing connection waits on.
+type bodyRequest struct {
+	ann  database.BlockAnnouncement
+	sent time.Time
+}
+
+// bodyRequests tracks the pending block body requests of a tracing
+// connection. Peers don't always answer, so unanswered requests expire.
+type bodyRequests map[uint64]bodyRequest
+
+// add records a request for the announced block sent at now. It returns false
+// if maxTraceBodyRequests requests are still pending after expiring the ones
+// sent more than traceBodyTimeout ago.
+func (r bodyRequests) add(id uint64, ann database.BlockAnnouncement, now time.Time) bool {
+	if len(r) >= maxTraceBodyRequests {
+		for id, req := range r {
+			if now.Sub(req.sent) > traceBodyTimeout {
+				delete(r, id)
+			}
+		}
+	}
+	if len(r) >= maxTraceBodyRequests {
+		return false
+	}
+	r[id] = bodyRequest{ann: ann, sent: now}
+	return true
+}
+
+// take removes the request with the id and returns its announced block.
+func (r bodyRequests) take(id uint64) (database.BlockAnnouncement, bool) {
+	req, ok := r[id]
+	if ok {
+		delete(r, id)
+	}
+	return req.ann, ok
+}
+
+// SendTxs sends transactions to the peer.
+func (c *rlpxConn) SendTxs(txs []*types.Transaction) error {
+	list, err := rlp.EncodeToRawList(txs)
+	if err != nil {
+		return fmt.Errorf("failed to encode transactions: %w", err)
+	}
+	return c.Write(&Transactions{RawList: list})
+}
+
+// TraceTx reads messages from the peer until the context is done, reporting
+// announcements of the traced transaction and blocks including it to the trace.
+// Block bodies are requested for announced block hashes to find the inclusion.
+// Requests from the peer are answered with empty responses.
+func (c *rlpxConn) TraceTx(ctx context.Context, trace *TxTrace) error {
+	hash := trace.Hash()
+	bodies := make(bodyRequests)
+
+	for ctx.Err() == nil {
+		if err := c.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+			c.logger.Error().Err(err).Msg("Failed to set read deadline")
+		}
+
+		msg := c.Read()
+		now := time.Now()
+
+		switch msg := msg.(type) {
+		case *Ping:
+			if err := c.Write(&Pong{}); err != nil {
+				c.logger.Error().Err(err).Msg("Failed to write Pong response")
+			}
+		case *GetBlockHeaders:
+			if err := c.Write(&BlockHeaders{RequestId: msg.RequestId}); err != nil {
+				c.logger.Error().Err(err).Msg("Failed to write BlockHeaders response")
+			}
+		case *GetBlockBodies:
+			if err := c.Write(&BlockBodies{RequestId: msg.RequestId}); err != nil {
+				c.logger.Error().Err(err).Msg("Failed to write BlockBodies response")
+			}
+		case *GetPooledTransactions:
+			if err := c.Write(&PooledTransactions{RequestId: msg.RequestId}); err != nil {
+				c.logger.Error().Err(err).Msg("Failed to write PooledTransactions response")
+			}
+		case *NewPooledTransactionHashes:
+			if slices.Contains(msg.Hashes, hash) {
+				trace.sighted(c.node, "NewPooledTransactionHashes", now)
+			}
+		case *NewPooledTransactionHashes66:
+			if slices.Contains(*msg, hash) {
+				trace.sighted(c.node, "NewPooledTransactionHashes", now)
+			}
+		case *Transactions:
+			if txs, err := msg.Items(); err == nil && containsTx(txs, hash) {
+				trace.sighted(c.node, "Transactions", now)
+			}
+		case *PooledTransactions:
+			if txs, err := msg.List.Items(); err == nil && containsTx(txs, hash) {
+				trace.sighted(c.node, "PooledTransactions", now)
+			}
+		case *NewBlock:
+			if containsTx(msg.Block.Transactions(), hash) {
+				trace.includedIn(c.node, msg.Block.Hash(), msg.Block.NumberU64(), now)
+			}
+		case *NewBlockHashes:
+			for _, ann := range *msg {
+				id := rand.Uint64()
+				if !bodies.add(id, ann, now) {
+					c.logger.Debug().Msg("Too many pending block body requests")
+					break
+				}
+
+				req := &GetBlockBodies{RequestId: id, GetBlockBodiesRequest: []common.Hash{ann.Hash}}
+				if err := c.Write(req); err != nil {
+					c.logger.Error().Err(err).Msg("Failed to write GetBlockBodies request")
+				}
+			}
+		case *BlockBodies:
+			ann, ok := bodies.take(msg.RequestId)
+			if !ok {
+				continue
+			}
+
+			items, err := msg.List.Items()
+			if err != nil || len(items) == 0 {
+				continue
+			}
+			if txs, err := items[0].Transactions.Items(); err == nil && containsTx(txs, hash) {
+				trace.includedIn(c.node, ann.Hash, ann.Number, now)
+			}
+		case *Error:
+			if !strings.Contains(msg.Error(), "timeout") {
+				return msg.Unwrap()
+			}
+		case *Disconnect:
+			return fmt.Errorf("disconnect received: %v", msg)
+		case *Disconnects:
+			return fmt.Errorf("disconnect received: %v", msg)
+		}
+	}
+
+	return nil
+}
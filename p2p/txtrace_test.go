@@ -0,0 +1,90 @@
+package p2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+
+	"github.com/0xPolygon/polygon-cli/p2p/database"
+)
+
+func TestTxTrace(t *testing.T) {
+	trace := NewTxTrace(common.Hash{1})
+	a := enode.SignNull(new(enr.Record), enode.ID{1})
+	b := enode.SignNull(new(enr.Record), enode.ID{2})
+
+	sent := time.Now()
+	trace.MarkSent(sent)
+
+	trace.sighted(b, "Transactions", sent.Add(300*time.Millisecond))
+	trace.sighted(a, "NewPooledTransactionHashes", sent.Add(100*time.Millisecond))
+	// Later sightings from the same peer are ignored.
+	trace.sighted(a, "PooledTransactions", sent.Add(200*time.Millisecond))
+
+	select {
+	case <-trace.Included():
+		t.Fatal("trace should not be included yet")
+	default:
+	}
+
+	trace.includedIn(b, common.Hash{2}, 10, sent.Add(2*time.Second))
+	trace.includedIn(a, common.Hash{3}, 11, sent.Add(3*time.Second))
+
+	select {
+	case <-trace.Included():
+	default:
+		t.Fatal("trace should be included")
+	}
+
+	r := trace.Result()
+	if len(r.Sightings) != 2 {
+		t.Fatalf("want 2 sightings, got %+v", r.Sightings)
+	}
+	if s := r.Sightings[0]; s.Peer != a.URLv4() || s.Message != "NewPooledTransactionHashes" || s.Delay != 100*time.Millisecond {
+		t.Fatalf("unexpected first sighting %+v", s)
+	}
+	if s := r.Sightings[1]; s.Peer != b.URLv4() || s.Delay != 300*time.Millisecond {
+		t.Fatalf("unexpected second sighting %+v", s)
+	}
+	if r.Inclusion == nil || r.Inclusion.Hash != (common.Hash{2}) || r.Inclusion.Number != 10 || r.Inclusion.Delay != 2*time.Second {
+		t.Fatalf("unexpected inclusion %+v", r.Inclusion)
+	}
+}
+
+func TestBodyRequestsLimit(t *testing.T) {
+	bodies := make(bodyRequests)
+	start := time.Now()
+
+	for i := range maxTraceBodyRequests {
+		if !bodies.add(uint64(i), database.BlockAnnouncement{Number: uint64(i)}, start) {
+			t.Fatalf("request %d should be added", i)
+		}
+	}
+	if bodies.add(maxTraceBodyRequests, database.BlockAnnouncement{}, start.Add(traceBodyTimeout)) {
+		t.Fatal("request over the limit should be dropped")
+	}
+
+	// An answered request frees its slot.
+	if ann, ok := bodies.take(7); !ok || ann.Number != 7 {
+		t.Fatalf("unexpected taken request %+v, %v", ann, ok)
+	}
+	if _, ok := bodies.take(7); ok {
+		t.Fatal("request should only be taken once")
+	}
+	if !bodies.add(1000, database.BlockAnnouncement{}, start) {
+		t.Fatal("request should be added in the freed slot")
+	}
+
+	// Unanswered requests expire, so tracing doesn't stop once the limit is
+	// reached.
+	later := start.Add(traceBodyTimeout + time.Second)
+	if !bodies.add(1001, database.BlockAnnouncement{Number: 1001}, later) {
+		t.Fatal("request should be added after the pending ones expired")
+	}
+	if len(bodies) != 1 {
+		t.Fatalf("want only the new request pending, got %d", len(bodies))
+	}
+}
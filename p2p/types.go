@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math/big"
 	"slices"
+	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -82,11 +83,100 @@ func (msg Pong) Code() int     { return 0x03 }
 func (msg Pong) ReqID() uint64 { return 0 }
 
 // Status is the network packet for the status message for eth/64 and later.
-type Status StatusPacket68
+// On eth/69 the head is the latest block of the range the peer serves, and the
+// TD is only set by Bor, which kept it when upstream go-ethereum dropped it.
+type Status struct {
+	ProtocolVersion uint32
+	NetworkID       uint64
+	TD              *big.Int
+	Head            common.Hash
+	Genesis         common.Hash
+	ForkID          forkid.ID
+	EarliestBlock   uint64 `rlp:"-"`
+	LatestBlock     uint64 `rlp:"-"`
+
+	// raw is the message as received, which is what the status exchange
+	// echoes back.
+	raw []byte
+}
 
 func (msg Status) Code() int     { return 16 }
 func (msg Status) ReqID() uint64 { return 0 }
 
+// decodeStatus decodes a Status message sent on the given eth version. eth/69
+// statuses are decoded in the Bor format first and then in the upstream one.
+func decodeStatus(version uint, data []byte) (*Status, error) {
+	if version < eth.ETH69 {
+		status := &Status{raw: data}
+		if err := rlp.DecodeBytes(data, status); err != nil {
+			return nil, err
+		}
+		return status, nil
+	}
+
+	var bor BorStatusPacket69
+	if err := rlp.DecodeBytes(data, &bor); err == nil {
+		return &Status{
+			ProtocolVersion: bor.ProtocolVersion,
+			NetworkID:       bor.NetworkID,
+			TD:              bor.TD,
+			Head:            bor.LatestBlockHash,
+			Genesis:         bor.Genesis,
+			ForkID:          bor.ForkID,
+			EarliestBlock:   bor.EarliestBlock,
+			LatestBlock:     bor.LatestBlock,
+			raw:             data,
+		}, nil
+	}
+
+	var upstream eth.StatusPacket
+	if err := rlp.DecodeBytes(data, &upstream); err != nil {
+		return nil, err
+	}
+	return &Status{
+		ProtocolVersion: upstream.ProtocolVersion,
+		NetworkID:       upstream.NetworkID,
+		Head:            upstream.LatestBlockHash,
+		Genesis:         upstream.Genesis,
+		ForkID:          upstream.ForkID,
+		EarliestBlock:   upstream.EarliestBlock,
+		LatestBlock:     upstream.LatestBlock,
+		raw:             data,
+	}, nil
+}
+
+// encode returns the status in the format of its eth version. Statuses read
+// from a peer are returned as received.
+func (msg *Status) encode() ([]byte, error) {
+	switch {
+	case msg.raw != nil:
+		return msg.raw, nil
+	case msg.ProtocolVersion < eth.ETH69:
+		return rlp.EncodeToBytes(msg)
+	case msg.TD != nil:
+		return rlp.EncodeToBytes(&BorStatusPacket69{
+			ProtocolVersion: msg.ProtocolVersion,
+			NetworkID:       msg.NetworkID,
+			TD:              msg.TD,
+			Genesis:         msg.Genesis,
+			ForkID:          msg.ForkID,
+			EarliestBlock:   msg.EarliestBlock,
+			LatestBlock:     msg.LatestBlock,
+			LatestBlockHash: msg.Head,
+		})
+	default:
+		return rlp.EncodeToBytes(&eth.StatusPacket{
+			ProtocolVersion: msg.ProtocolVersion,
+			NetworkID:       msg.NetworkID,
+			Genesis:         msg.Genesis,
+			ForkID:          msg.ForkID,
+			EarliestBlock:   msg.EarliestBlock,
+			LatestBlock:     msg.LatestBlock,
+			LatestBlockHash: msg.Head,
+		})
+	}
+}
+
 // BorStatusPacket69 is the Bor-compatible status packet for ETH69.
 // Bor's implementation includes the TD field which upstream go-ethereum removed.
 type BorStatusPacket69 struct {
@@ -207,6 +297,25 @@ type PooledTransactions eth.PooledTransactionsPacket
 func (msg PooledTransactions) Code() int     { return 26 }
 func (msg PooledTransactions) ReqID() uint64 { return msg.RequestId }
 
+// GetReceipts represents an eth/69 block receipts query.
+type GetReceipts eth.GetReceiptsPacket69
+
+func (msg GetReceipts) Code() int     { return 31 }
+func (msg GetReceipts) ReqID() uint64 { return msg.RequestId }
+
+// Receipts is the network packet for block receipts. The receipts of each
+// block are left encoded, since their format depends on the eth version.
+type Receipts ReceiptsRLPPacket
+
+func (msg Receipts) Code() int     { return 32 }
+func (msg Receipts) ReqID() uint64 { return msg.RequestId }
+
+// BlockRangeUpdate is the eth/69 announcement of the blocks a peer serves.
+type BlockRangeUpdate eth.BlockRangeUpdatePacket
+
+func (msg BlockRangeUpdate) Code() int     { return 33 }
+func (msg BlockRangeUpdate) ReqID() uint64 { return 0 }
+
 // rlpxConn represents an individual connection with a peer.
 type rlpxConn struct {
 	*rlpx.Conn
@@ -225,6 +334,32 @@ func (c *rlpxConn) Read() Message {
 		return errorf("could not read from connection: %v, code: %d", err, code)
 	}
 
+	// eth/69 adds BlockRangeUpdate after the eth/68 messages, which moves the
+	// codes of the capabilities that follow eth up.
+	if shift := c.ethCodeShift(); shift > 0 && code >= snapOffset {
+		if code == uint64(BlockRangeUpdate{}.Code()) {
+			msg := new(BlockRangeUpdate)
+			if err := rlp.DecodeBytes(rawData, msg); err != nil {
+				return errorf("could not rlp decode message: %v", err)
+			}
+			return msg
+		}
+		code -= shift
+	}
+
+	// Capabilities are assigned message codes in name order, so when snap is
+	// negotiated it sits between eth and wit and shifts the wit codes.
+	if c.hasCap("snap", 1) && code >= snapOffset {
+		if code < snapOffset+snapLength {
+			msg, err := decodeSnap(int(code), rawData)
+			if err != nil {
+				return errorf("%v", err)
+			}
+			return msg
+		}
+		code -= snapLength
+	}
+
 	var msg Message
 	switch int(code) {
 	case (Hello{}).Code():
@@ -241,7 +376,11 @@ func (c *rlpxConn) Read() Message {
 			msg = new(Disconnect)
 		}
 	case (Status{}).Code():
-		msg = new(Status)
+		status, err := decodeStatus(c.ethVersion(), rawData)
+		if err != nil {
+			return errorf("could not rlp decode message: %v", err)
+		}
+		return status
 	case (GetBlockHeaders{}).Code():
 		ethMsg := new(eth.GetBlockHeadersPacket)
 		if err := rlp.DecodeBytes(rawData, ethMsg); err != nil {
@@ -291,6 +430,14 @@ func (c *rlpxConn) Read() Message {
 			return errorf("could not rlp decode message: %v", err)
 		}
 		return (*PooledTransactions)(ethMsg)
+	case (GetReceipts{}.Code()):
+		ethMsg := new(eth.GetReceiptsPacket69)
+		if err := rlp.DecodeBytes(rawData, ethMsg); err != nil {
+			return errorf("could not rlp decode message: %v", err)
+		}
+		return (*GetReceipts)(ethMsg)
+	case (Receipts{}.Code()):
+		msg = new(Receipts)
 	case (NewWitnessPacket{}.Code()):
 		msg = new(NewWitnessPacket)
 	case (NewWitnessHashesPacket{}.Code()):
@@ -318,49 +465,124 @@ func (c *rlpxConn) Write(msg Message) error {
 	if err != nil {
 		return err
 	}
-	_, err = c.Conn.Write(uint64(msg.Code()), payload)
+
+	code := uint64(msg.Code())
+	if code >= snapOffset && !isBlockRangeUpdate(msg) {
+		if c.hasCap("snap", 1) && !isSnap(msg) {
+			code += snapLength
+		}
+		code += c.ethCodeShift()
+	}
+
+	_, err = c.Conn.Write(code, payload)
 	return err
 }
 
-// ReadSnap reads a snap/1 response with the given id from the connection.
+// ReadSnap reads messages until the snap/1 response to the request with the
+// given id arrives, answering pings and skipping other messages meanwhile.
 func (c *rlpxConn) ReadSnap(id uint64) (Message, error) {
-	respID := id + 1
 	start := time.Now()
-	for respID != id && time.Since(start) < timeout {
-		code, rawData, _, err := c.Conn.Read()
-		if err != nil {
-			return nil, fmt.Errorf("could not read from connection: %v", err)
+	for time.Since(start) < timeout {
+		if err := c.SetReadDeadline(time.Now().Add(10 * time.Second)); err != nil {
+			c.logger.Error().Err(err).Msg("Failed to set read deadline")
 		}
-		var snpMsg any
-		switch int(code) {
-		case (GetAccountRange{}).Code():
-			snpMsg = new(GetAccountRange)
-		case (AccountRange{}).Code():
-			snpMsg = new(AccountRange)
-		case (GetStorageRanges{}).Code():
-			snpMsg = new(GetStorageRanges)
-		case (StorageRanges{}).Code():
-			snpMsg = new(StorageRanges)
-		case (GetByteCodes{}).Code():
-			snpMsg = new(GetByteCodes)
-		case (ByteCodes{}).Code():
-			snpMsg = new(ByteCodes)
-		case (GetTrieNodes{}).Code():
-			snpMsg = new(GetTrieNodes)
-		case (TrieNodes{}).Code():
-			snpMsg = new(TrieNodes)
+
+		switch msg := c.Read().(type) {
+		case *Ping:
+			if err := c.Write(&Pong{}); err != nil {
+				c.logger.Error().Err(err).Msg("Failed to write Pong response")
+			}
+		case *Error:
+			c.logger.Trace().Err(msg.Unwrap()).Msg("Received Error")
+
+			if !strings.Contains(msg.Error(), "timeout") {
+				return nil, msg.Unwrap()
+			}
+		case *Disconnect:
+			return nil, fmt.Errorf("disconnect received: %v", msg)
+		case *Disconnects:
+			return nil, fmt.Errorf("disconnect received: %v", msg)
 		default:
-			//return nil, fmt.Errorf("invalid message code: %d", code)
-			continue
-		}
-		if err := rlp.DecodeBytes(rawData, snpMsg); err != nil {
-			return nil, fmt.Errorf("could not rlp decode message: %v", err)
+			if isSnap(msg) && msg.ReqID() == id {
+				return msg, nil
+			}
+			c.logger.Trace().Interface("msg", msg).Int("code", msg.Code()).Msg("Received message")
 		}
-		return snpMsg.(Message), nil
 	}
 	return nil, fmt.Errorf("request timed out")
 }
 
+// decodeSnap decodes a snap/1 message given its code on a connection where
+// snap follows eth/66-68.
+func decodeSnap(code int, rawData []byte) (Message, error) {
+	var msg Message
+	switch code {
+	case (GetAccountRange{}).Code():
+		msg = new(GetAccountRange)
+	case (AccountRange{}).Code():
+		msg = new(AccountRange)
+	case (GetStorageRanges{}).Code():
+		msg = new(GetStorageRanges)
+	case (StorageRanges{}).Code():
+		msg = new(StorageRanges)
+	case (GetByteCodes{}).Code():
+		msg = new(GetByteCodes)
+	case (ByteCodes{}).Code():
+		msg = new(ByteCodes)
+	case (GetTrieNodes{}).Code():
+		msg = new(GetTrieNodes)
+	case (TrieNodes{}).Code():
+		msg = new(TrieNodes)
+	default:
+		return nil, fmt.Errorf("invalid snap message code: %d", code)
+	}
+	if err := rlp.DecodeBytes(rawData, msg); err != nil {
+		return nil, fmt.Errorf("could not rlp decode message: %v", err)
+	}
+	return msg, nil
+}
+
+// isSnap reports whether msg is a snap/1 message, whose codes overlap with
+// wit's when snap is not negotiated.
+func isSnap(msg Message) bool {
+	switch msg.(type) {
+	case *GetAccountRange, *AccountRange, *GetStorageRanges, *StorageRanges,
+		*GetByteCodes, *ByteCodes, *GetTrieNodes, *TrieNodes,
+		GetAccountRange, AccountRange, GetStorageRanges, StorageRanges,
+		GetByteCodes, ByteCodes, GetTrieNodes, TrieNodes:
+		return true
+	}
+	return false
+}
+
+// isBlockRangeUpdate reports whether msg is an eth/69 BlockRangeUpdate, whose
+// code overlaps with the first snap/1 and wit messages.
+func isBlockRangeUpdate(msg Message) bool {
+	switch msg.(type) {
+	case *BlockRangeUpdate, BlockRangeUpdate:
+		return true
+	}
+	return false
+}
+
+// ethCodeShift returns how far the codes of the capabilities after eth move up
+// from where they are on eth/66-68.
+func (c *rlpxConn) ethCodeShift() uint64 {
+	version := c.ethVersion()
+	if version < eth.ETH69 {
+		return 0
+	}
+	return protocolLengths[version] - protocolLengths[68]
+}
+
+const (
+	// snapOffset is the code of the first snap/1 message, which follows the 16
+	// devp2p base codes and the 17 eth/66-68 messages.
+	snapOffset = 33
+	// snapLength is the number of snap/1 messages.
+	snapLength = 8
+)
+
 // GetAccountRange represents an account range query.
 type GetAccountRange snap.GetAccountRangePacket
 